@@ -1,17 +1,63 @@
 package api
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"net/http"
+	"time"
 
 	"kbswitch/docs"
 	"kbswitch/internal/app"
+	alertscontroller "kbswitch/internal/app/api/controllers/alerts"
+	apikeyscontroller "kbswitch/internal/app/api/controllers/apikeys"
+	autocompletecontroller "kbswitch/internal/app/api/controllers/autocomplete"
+	collectionscontroller "kbswitch/internal/app/api/controllers/collections"
+	listingscontroller "kbswitch/internal/app/api/controllers/listings"
+	reviewscontroller "kbswitch/internal/app/api/controllers/reviews"
+	submissionscontroller "kbswitch/internal/app/api/controllers/submissions"
+	suggestionscontroller "kbswitch/internal/app/api/controllers/suggestions"
 	"kbswitch/internal/app/api/controllers/switches"
 	"kbswitch/internal/app/api/controllers/system"
+	tokenscontroller "kbswitch/internal/app/api/controllers/tokens"
+	userscontroller "kbswitch/internal/app/api/controllers/users"
 	"kbswitch/internal/app/api/middlewares"
 	"kbswitch/internal/app/api/router"
+	"kbswitch/internal/app/api/versioning"
+	corealerts "kbswitch/internal/core/alerts"
+	alertmodels "kbswitch/internal/core/alerts/models"
+	"kbswitch/internal/core/authz"
+	collectionmodels "kbswitch/internal/core/collections/models"
+	"kbswitch/internal/core/common/database"
+	"kbswitch/internal/core/common/logger"
+	"kbswitch/internal/core/mail"
+	alertsservice "kbswitch/internal/pkg/alerts"
+	alertsrepo "kbswitch/internal/pkg/alerts/repo"
+	apikeysservice "kbswitch/internal/pkg/apikeys"
+	apikeysrepo "kbswitch/internal/pkg/apikeys/repo"
+	authzpolicy "kbswitch/internal/pkg/authz"
+	"kbswitch/internal/pkg/autocomplete"
+	collectionsservice "kbswitch/internal/pkg/collections"
+	collectionsrepo "kbswitch/internal/pkg/collections/repo"
+	"kbswitch/internal/pkg/contentfilter"
+	idempotencyrepo "kbswitch/internal/pkg/idempotency/repo"
+	listingsservice "kbswitch/internal/pkg/listings"
+	listingsrepo "kbswitch/internal/pkg/listings/repo"
+	mailsender "kbswitch/internal/pkg/mail"
+	reviewsservice "kbswitch/internal/pkg/reviews"
+	reviewsrepo "kbswitch/internal/pkg/reviews/repo"
+	submissionsservice "kbswitch/internal/pkg/submissions"
+	submissionsrepo "kbswitch/internal/pkg/submissions/repo"
+	suggestionsservice "kbswitch/internal/pkg/suggestions"
+	suggestionsrepo "kbswitch/internal/pkg/suggestions/repo"
 	switchservice "kbswitch/internal/pkg/switches"
 	switchesrepo "kbswitch/internal/pkg/switches/repo"
+	tokensservice "kbswitch/internal/pkg/tokens"
+	tokensrepo "kbswitch/internal/pkg/tokens/repo"
+	usersservice "kbswitch/internal/pkg/users"
+	usersrepo "kbswitch/internal/pkg/users/repo"
 
 	httpSwagger "github.com/swaggo/http-swagger"
 )
@@ -22,55 +68,587 @@ func InitRouter(app app.Application) *router.CustomMux {
 	docs.SwaggerInfo.Description = "This is a backend of upcoming website"
 	docs.SwaggerInfo.Version = "0.0.1"
 
+	lg := logger.Adapter{}
+
+	pool, err := database.NewPool(context.Background(), app.DbConfig)
+	if err != nil {
+		logger.Fatal(err.Error())
+		panic(err)
+	}
+
+	// autocomplete is served from memory, built once here and kept fresh by observed services
+	index := autocomplete.New()
+	if err := index.Load(context.Background(), switchservice.New(lg, switchesrepo.New(lg, pool))); err != nil {
+		logger.Error("could not load autocomplete index: " + err.Error())
+	}
+
+	keys := apikeysservice.New(lg, apikeysrepo.New(lg, pool))
+	if app.Config.AdminKey != "" {
+		if err := keys.Bootstrap(context.Background(), app.Config.AdminKey); err != nil {
+			logger.Error("could not register admin api key: " + err.Error())
+		}
+	}
+
+	var sender mail.Sender = mailsender.NewLogSender(lg)
+	if app.Config.SMTPAddr != "" {
+		sender = mailsender.NewSMTPSender(mailsender.SMTPConfig{
+			Addr:     app.Config.SMTPAddr,
+			From:     app.Config.SMTPFrom,
+			Username: app.Config.SMTPUser,
+			Password: app.Config.SMTPPass,
+		})
+	} else if app.Config.MailDir != "" {
+		if sender, err = mailsender.NewFileSender(app.Config.MailDir); err != nil {
+			logger.Fatal(err.Error())
+			panic(err)
+		}
+	}
+	// refresh tokens are revoked on password reset as well as sessions are
+	refresh := tokensrepo.New(lg, pool)
+	accounts := usersservice.New(lg, usersrepo.New(lg, pool), sender, app.Config.PublicURL, refresh)
+
+	seed, err := base64.StdEncoding.DecodeString(app.Config.JWTSeed)
+	if err != nil {
+		logger.Fatal("could not decode jwt seed: " + err.Error())
+		panic(err)
+	}
+	if len(seed) == 0 {
+		// tokens signed with a generated key don't outlive the process, fine for development only
+		logger.Error("jwt seed is not set, access tokens are signed with a key generated on start")
+		seed = make([]byte, ed25519.SeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			logger.Fatal(err.Error())
+			panic(err)
+		}
+	}
+	signing, err := tokensservice.NewKeys(app.Config.PublicURL, app.Config.JWTAlg, seed, []byte(app.Config.JWTSecret))
+	if err != nil {
+		logger.Fatal(err.Error())
+		panic(err)
+	}
+	tokens := tokensservice.New(lg, refresh, accounts, signing)
+
+	// read routes stay public, routes changing anything need a principal the policy grants the permission to.
+	// switches service checks the same policy again, whichever route reaches it
+	policy := authzpolicy.NewRolePolicy()
+	can := func(perm authz.Permission) func(http.Handler) http.Handler {
+		return middlewares.RequirePermission(policy, perm)
+	}
+
+	// every text users write goes through the same filter, so rate limits and duplicates count across all of them
+	filterConfig := contentfilter.DefaultConfig()
+	filterConfig.Blocked = app.Config.ContentBlocked
+	filterConfig.Flagged = app.Config.ContentFlagged
+	filterConfig.MaxLinks = app.Config.ContentLinks
+	filterConfig.RateLimit = app.Config.ContentRate
+	filter := contentfilter.New(filterConfig)
+
+	// approved submissions and suggestions reach the catalog through the same guarded service as direct changes do
+	catalog := authzpolicy.GuardSwitches(autocomplete.Observe(switchservice.New(lg, switchesrepo.New(lg, pool)), index), policy)
+	submissions := submissionsservice.New(lg, submissionsrepo.New(lg, pool), catalog, policy, filter)
+	suggestions := suggestionsservice.New(lg, suggestionsrepo.New(lg, pool), catalog, policy, filter)
+	// ratings are kept next to reviews and joined into switches on every read
+	reviews := reviewsservice.New(lg, reviewsrepo.New(lg, pool), switchesrepo.New(lg, pool), policy, filter)
+
+	collections := collectionsservice.New(lg, collectionsrepo.New(lg, pool), switchesrepo.New(lg, pool))
+	listings := listingsservice.New(lg, listingsrepo.New(lg, pool), switchesrepo.New(lg, pool), policy)
+
+	webhooks := alertsservice.PublicClient(10 * time.Second)
+	if app.Config.WebhookPrivate {
+		webhooks = &http.Client{Timeout: 10 * time.Second}
+	}
+	notifiers := map[alertmodels.Channel]corealerts.Notifier{
+		alertmodels.ChannelEmail:   alertsservice.NewMailNotifier(sender),
+		alertmodels.ChannelWebhook: alertsservice.NewWebhookNotifier(webhooks),
+	}
+	// alerts are evaluated whenever price and stock of a switch change
+	evaluator := alertsservice.NewEvaluator(lg, alertsrepo.New(lg, pool), switchesrepo.New(lg, pool), notifiers, app.Config.PublicURL)
+	alerts := alertsservice.Watch(alertsservice.New(lg, alertsrepo.New(lg, pool), switchesrepo.New(lg, pool), accounts, policy, sender,
+		app.Config.PublicURL), evaluator, lg)
+
+	idempotent := middlewares.Idempotency(idempotencyrepo.New(lg, pool), time.Duration(app.Config.IdempotencyTTL)*time.Second)
+
 	router := router.CreateAndSetup(func(this *router.CustomMux) *router.CustomMux {
-		this.Use(middlewares.ContentTypeJSON)
 		this.Use(middlewares.Timeout((app.Config.Timeout)))
 		this.Use(middlewares.RequestID)
-		this.Use(middlewares.LogHttpCycle)
+		this.Use(middlewares.RealIP(app.Config.TrustedProxies))
+		// passwords, issued and mailed tokens and once shown api keys must not end up in the log
+		this.Use(middlewares.LogHttpCycle("/api/admin/keys/", "/api/account/", "/api/auth/", "/api/alerts/", "/api/me/alerts"))
+		// middlewares run in reverse order of registration, Session and Bearer must find the user before Identify runs
+		this.Use(middlewares.Identify(middlewares.APIKeyAuthenticator(keys), middlewares.BearerAuthenticator(), middlewares.SessionAuthenticator()))
+		this.Use(middlewares.Bearer(tokens))
+		this.Use(middlewares.Session(accounts))
 
 		this.AddGroup("/api/system/", func(ng *router.Group) {
 			c := system.New(app.BuildDate)
 
+			ng.Use(middlewares.ContentTypeJSON)
+
 			ng.HandleRouteFunc("GET /about", func(w http.ResponseWriter, r *http.Request) {
 				c.HandleAbout(w, r)
 			})
 		})
 
-		this.AddGroup("/api/switches/", func(ng *router.Group) {
-			c := switches.New(nil)
+		this.AddGroup("/api/account/", func(ng *router.Group) {
+			c := userscontroller.New(accounts, app.Config.CookieSecure)
+
+			ng.Use(middlewares.ContentTypeJSON)
+
+			ng.HandleRouteFunc("POST /register", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleRegister(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleLogin(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("POST /logout", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleLogout(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("GET /me", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleMe(r.Context(), w, r)
+			})
 
-			// this is equivalent of .net scoped injection
-			ng.Use(func(next http.Handler) http.Handler {
-				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					repo := switchesrepo.NewObsolete(app.DbConfig)
-					service := switchservice.New(nil, repo)
-					c = switches.New(service)
+			ng.HandleRouteFunc("POST /verify", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleVerifyEmail(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("POST /password/forgot", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleForgotPassword(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("POST /password/reset", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleResetPassword(r.Context(), w, r)
+			})
+		})
+
+		this.AddGroup("/api/auth/", func(ng *router.Group) {
+			c := tokenscontroller.New(tokens)
+
+			ng.Use(middlewares.ContentTypeJSON)
+
+			ng.HandleRouteFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleToken(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("POST /revoke", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleRevoke(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleJWKS(w, r)
+			})
+		})
+
+		// lists belong to the principal, the service refuses anonymous callers
+		this.AddGroup("/api/me/", func(ng *router.Group) {
+			c := collectionscontroller.New(collections, app.Config.PublicURL)
+
+			ng.Use(middlewares.ContentTypeJSON)
+
+			ng.HandleRouteFunc("GET /collection", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleCollection(r.Context(), w, r)
+			})
+
+			ng.HandleRoute("POST /collection", idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.HandleItemAdd(r.Context(), w, r)
+			})))
+
+			ng.HandleRouteFunc("PUT /collection/{id}", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleItemUpdate(r.Context(), w, r)
+			})
 
-					next.ServeHTTP(w, r)
+			ng.HandleRouteFunc("DELETE /collection/{id}", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleItemRemove(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("GET /wishlist", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleWishlist(r.Context(), w, r)
+			})
+
+			ng.HandleRoute("POST /wishlist", idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.HandleWishAdd(r.Context(), w, r)
+			})))
+
+			ng.HandleRouteFunc("PUT /wishlist/{id}", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleWishUpdate(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("DELETE /wishlist/{id}", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleWishRemove(r.Context(), w, r)
+			})
+
+			ac := alertscontroller.New(alerts)
+			ng.HandleRouteFunc("GET /alerts", func(w http.ResponseWriter, r *http.Request) {
+				ac.HandleSubscriptions(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("DELETE /alerts/{id}", func(w http.ResponseWriter, r *http.Request) {
+				ac.HandleUnsubscribe(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("GET /inbox", func(w http.ResponseWriter, r *http.Request) {
+				ac.HandleInbox(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("POST /inbox/{id}/read", func(w http.ResponseWriter, r *http.Request) {
+				ac.HandleRead(r.Context(), w, r)
+			})
+
+			for _, list := range []collectionmodels.List{collectionmodels.ListCollection, collectionmodels.ListWishlist} {
+				ng.HandleRouteFunc("GET /"+string(list)+"/share", func(w http.ResponseWriter, r *http.Request) {
+					c.HandleShare(r.Context(), w, r, list)
 				})
+
+				ng.HandleRouteFunc("PUT /"+string(list)+"/share", func(w http.ResponseWriter, r *http.Request) {
+					c.HandleShareSet(r.Context(), w, r, list)
+				})
+
+				ng.HandleRouteFunc("DELETE /"+string(list)+"/share", func(w http.ResponseWriter, r *http.Request) {
+					c.HandleShareRemove(r.Context(), w, r, list)
+				})
+			}
+		})
+
+		// subscriptions are keyed by where notifications go, email and webhook ones need no account
+		this.AddGroup("/api/alerts/", func(ng *router.Group) {
+			c := alertscontroller.New(alerts)
+
+			ng.Use(middlewares.ContentTypeJSON)
+
+			ng.HandleRoute("POST /", idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.HandleSubscribe(r.Context(), w, r)
+			})))
+
+			ng.HandleRouteFunc("POST /confirm", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleConfirm(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("POST /unsubscribe", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleUnsubscribeToken(r.Context(), w, r)
+			})
+		})
+
+		this.AddGroup("/api/shared/", func(ng *router.Group) {
+			c := collectionscontroller.New(collections, app.Config.PublicURL)
+
+			ng.Use(middlewares.ContentTypeJSON)
+
+			ng.HandleRouteFunc("GET /{token}", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleShared(r.Context(), w, r)
+			})
+		})
+
+		this.AddGroup("/api/vendors/", func(ng *router.Group) {
+			c := listingscontroller.New(listings)
+
+			ng.Use(middlewares.ContentTypeJSON)
+
+			ng.HandleRouteFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleVendors(r.Context(), w, r)
+			})
+
+			ng.HandleRoute("POST /", can(authz.PermListingsManage)(idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.HandleVendorAdd(r.Context(), w, r)
+			}))))
+
+			ng.HandleRoute("DELETE /{id}", can(authz.PermListingsManage)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.HandleVendorRemove(r.Context(), w, r)
+			})))
+		})
+
+		this.AddGroup("/api/submissions/", func(ng *router.Group) {
+			c := submissionscontroller.New(submissions)
+
+			ng.Use(can(authz.PermSwitchesPropose))
+			ng.Use(middlewares.ContentTypeJSON)
+
+			ng.HandleRoute("POST /", idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.HandleSubmit(r.Context(), w, r)
+			})))
+
+			ng.HandleRouteFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleMySubmissions(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("GET /{id}", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleSubmission(r.Context(), w, r)
 			})
 
+			ng.HandleRouteFunc("PUT /{id}", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleRevise(r.Context(), w, r)
+			})
+		})
+
+		this.AddGroup("/api/moderation/queue/", func(ng *router.Group) {
+			c := submissionscontroller.New(submissions)
+
+			ng.Use(can(authz.PermSwitchesApprove))
+			ng.Use(middlewares.ContentTypeJSON)
+
 			ng.HandleRouteFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
-				c.HandleSwitches(r.Context(), w, r)
+				c.HandleQueue(r.Context(), w, r)
 			})
 
-			ng.HandleRouteFunc("GET /{brand}/{name}", func(w http.ResponseWriter, r *http.Request) {
-				c.HandleSingleSwitch(r.Context(), w, r)
+			ng.HandleRouteFunc("POST /{id}/{action}", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleReview(r.Context(), w, r)
 			})
+		})
+
+		this.AddGroup("/api/moderation/suggestions/", func(ng *router.Group) {
+			c := suggestionscontroller.New(suggestions)
+
+			ng.Use(can(authz.PermSwitchesApprove))
+			ng.Use(middlewares.ContentTypeJSON)
+
+			ng.HandleRouteFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleQueue(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("GET /{id}", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleSuggestion(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("POST /{id}/accept", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleAccept(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("POST /{id}/reject", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleReject(r.Context(), w, r)
+			})
+		})
+
+		this.AddGroup("/api/admin/users/", func(ng *router.Group) {
+			c := userscontroller.New(accounts, app.Config.CookieSecure)
+
+			ng.Use(can(authz.PermUsersManage))
+			ng.Use(middlewares.ContentTypeJSON)
+
+			ng.HandleRouteFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleUsers(r.Context(), w, r)
+			})
+
+			ng.HandleRouteFunc("PUT /{id}/role", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleUserRole(r.Context(), w, r)
+			})
+		})
+
+		this.AddGroup("/api/admin/keys/", func(ng *router.Group) {
+			c := apikeyscontroller.New(keys)
+
+			ng.Use(can(authz.PermKeysManage))
+			ng.Use(middlewares.ContentTypeJSON)
 
 			ng.HandleRouteFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
-				c.HandleSwitchAdd(r.Context(), w, r)
+				c.HandleKeyCreate(r.Context(), w, r)
 			})
 
-			ng.HandleRouteFunc("DELETE /{brand}/{name}", func(w http.ResponseWriter, r *http.Request) {
-				c.HandleSwitchRemove(r.Context(), w, r)
+			ng.HandleRouteFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleKeys(r.Context(), w, r)
 			})
 
-			ng.HandleRouteFunc("PATCH /{brand}/{name}", func(w http.ResponseWriter, r *http.Request) {
-				c.HandleSwitchUpdate(r.Context(), w, r)
+			ng.HandleRouteFunc("DELETE /{id}", func(w http.ResponseWriter, r *http.Request) {
+				c.HandleKeyRevoke(r.Context(), w, r)
 			})
 		})
 
+		// switch resources are served under every version prefix and unversioned as well,
+		// where Accept-Version header picks the version
+		mounts := []struct {
+			prefix  string
+			version versioning.Version
+		}{
+			{"/api/", 0},
+			{"/api/v1/", versioning.V1},
+			{"/api/v2/", versioning.V2},
+		}
+
+		for _, m := range mounts {
+			versioned := middlewares.APIVersion(m.version)
+
+			this.AddGroup(m.prefix+"switches/", func(ng *router.Group) {
+				c := switches.New(nil)
+
+				ng.Use(versioned)
+
+				// this is equivalent of .net scoped injection
+				ng.Use(func(next http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						repo := switchesrepo.New(lg, pool)
+						service := authzpolicy.GuardSwitches(autocomplete.Observe(reviewsservice.WithRatings(switchservice.New(lg, repo), reviews), index), policy)
+						c = switches.New(service)
+
+						next.ServeHTTP(w, r)
+					})
+				})
+
+				// read endpoints negotiate representation through Accept header and set content type themselves,
+				// everything else answers with json
+				jsonOnly := func(h http.HandlerFunc) http.Handler {
+					return middlewares.ContentTypeJSON(h)
+				}
+
+				ng.HandleRouteFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+					c.HandleSwitches(r.Context(), w, r)
+				})
+
+				// exports of the whole catalog outlast the request timeout, they get a deadline of their own
+				ng.HandleRoute("GET /export", middlewares.Stream(time.Duration(app.Config.ExportTimeout)*time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					c.HandleExport(r.Context(), w, r)
+				})))
+
+				ng.HandleRoute("GET /facets", jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					c.HandleFacets(r.Context(), w, r)
+				}))
+
+				ac := autocompletecontroller.New(index)
+				ng.HandleRoute("GET /autocomplete", jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					ac.HandleAutocomplete(w, r)
+				}))
+
+				ng.HandleRouteFunc("GET /{brand}/{name}", func(w http.ResponseWriter, r *http.Request) {
+					c.HandleSingleSwitch(r.Context(), w, r)
+				})
+
+				ng.HandleRoute("GET /{brand}/{name}/similar", jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					c.HandleSimilarSwitches(r.Context(), w, r)
+				}))
+
+				ng.HandleRoute("GET /{brand}/{name}/aliases", jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					c.HandleAliases(r.Context(), w, r)
+				}))
+
+				ng.HandleRoute("POST /{brand}/{name}/aliases", can(authz.PermSwitchesEdit)(idempotent(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					c.HandleAliasAdd(r.Context(), w, r)
+				}))))
+
+				ng.HandleRoute("DELETE /{brand}/{name}/aliases/{aliasBrand}/{aliasName}", can(authz.PermSwitchesEdit)(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					c.HandleAliasRemove(r.Context(), w, r)
+				})))
+
+				sc := suggestionscontroller.New(suggestions)
+				ng.HandleRoute("GET /{brand}/{name}/suggestions", can(authz.PermSwitchesApprove)(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					sc.HandleSwitchSuggestions(r.Context(), w, r)
+				})))
+
+				ng.HandleRoute("POST /{brand}/{name}/suggestions", can(authz.PermSwitchesPropose)(idempotent(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					sc.HandleSuggest(r.Context(), w, r)
+				}))))
+
+				rc := reviewscontroller.New(reviews)
+				ng.HandleRoute("GET /{brand}/{name}/reviews", jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					rc.HandleReviews(r.Context(), w, r)
+				}))
+
+				ng.HandleRoute("POST /{brand}/{name}/reviews", can(authz.PermReviewsWrite)(idempotent(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					rc.HandleReviewAdd(r.Context(), w, r)
+				}))))
+
+				ng.HandleRoute("PUT /{brand}/{name}/reviews/{id}", can(authz.PermReviewsWrite)(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					rc.HandleReviewUpdate(r.Context(), w, r)
+				})))
+
+				ng.HandleRoute("DELETE /{brand}/{name}/reviews/{id}", can(authz.PermReviewsWrite)(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					rc.HandleReviewRemove(r.Context(), w, r)
+				})))
+
+				lc := listingscontroller.New(listings)
+				ng.HandleRoute("GET /{brand}/{name}/listings", jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					lc.HandleListings(r.Context(), w, r)
+				}))
+
+				ng.HandleRoute("POST /{brand}/{name}/listings", can(authz.PermListingsManage)(idempotent(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					lc.HandleListingAdd(r.Context(), w, r)
+				}))))
+
+				ng.HandleRoute("PUT /{brand}/{name}/listings/{id}", can(authz.PermListingsManage)(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					lc.HandleListingUpdate(r.Context(), w, r)
+				})))
+
+				ng.HandleRoute("DELETE /{brand}/{name}/listings/{id}", can(authz.PermListingsManage)(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					lc.HandleListingRemove(r.Context(), w, r)
+				})))
+
+				ng.HandleRoute("GET /{brand}/{name}/prices", jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					lc.HandlePrices(r.Context(), w, r)
+				}))
+
+				stc := alertscontroller.New(alerts)
+				ng.HandleRoute("GET /{brand}/{name}/stock", jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					stc.HandleStock(r.Context(), w, r)
+				}))
+
+				ng.HandleRoute("PUT /{brand}/{name}/stock", can(authz.PermListingsManage)(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					stc.HandleStockSet(r.Context(), w, r)
+				})))
+
+				ng.HandleRoute("POST /import", can(authz.PermSwitchesCreate)(idempotent(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					c.HandleImport(r.Context(), w, r)
+				}))))
+
+				ng.HandleRouteFunc("POST /lookup", func(w http.ResponseWriter, r *http.Request) {
+					c.HandleLookup(r.Context(), w, r)
+				})
+
+				ng.HandleRoute("POST /batch", can(authz.PermSwitchesEdit)(idempotent(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					c.HandleBatch(r.Context(), w, r)
+				}))))
+
+				ng.HandleRoute("POST /", can(authz.PermSwitchesCreate)(idempotent(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					c.HandleSwitchAdd(r.Context(), w, r)
+				}))))
+
+				ng.HandleRoute("DELETE /{brand}/{name}", can(authz.PermSwitchesDelete)(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					c.HandleSwitchRemove(r.Context(), w, r)
+				})))
+
+				ng.HandleRoute("PATCH /{brand}/{name}", can(authz.PermSwitchesEdit)(jsonOnly(func(w http.ResponseWriter, r *http.Request) {
+					c.HandleSwitchUpdate(r.Context(), w, r)
+				})))
+			})
+
+			this.AddGroup(m.prefix+"search/", func(ng *router.Group) {
+				c := switches.New(nil)
+
+				ng.Use(versioned)
+				ng.Use(middlewares.ContentTypeJSON)
+				ng.Use(func(next http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						repo := switchesrepo.New(lg, pool)
+						service := reviewsservice.WithRatings(switchservice.New(lg, repo), reviews)
+						c = switches.New(service)
+
+						next.ServeHTTP(w, r)
+					})
+				})
+
+				ng.HandleRouteFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+					c.HandleSearch(r.Context(), w, r)
+				})
+			})
+
+			this.AddGroup(m.prefix+"stats/", func(ng *router.Group) {
+				c := switches.New(nil)
+
+				ng.Use(versioned)
+				ng.Use(middlewares.ContentTypeJSON)
+				ng.Use(func(next http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						repo := switchesrepo.New(lg, pool)
+						service := switchservice.New(lg, repo)
+						c = switches.New(service)
+
+						next.ServeHTTP(w, r)
+					})
+				})
+
+				ng.HandleRouteFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+					c.HandleStats(r.Context(), w, r)
+				})
+			})
+		}
+
 		this.HandleFunc("GET /swagger/*", httpSwagger.Handler(
 			httpSwagger.URL(fmt.Sprintf("http://localhost:%d/swagger/doc.json", app.Config.Port)),
 		))
@@ -0,0 +1,140 @@
+package alerts
+
+import (
+	"kbswitch/internal/core/alerts/models"
+	"math"
+	"time"
+)
+
+type MoneyDTO struct {
+	Amount   float64 `json:"amount" example:"0.55"`
+	Currency string  `json:"currency" example:"USD"`
+}
+
+type SwitchDTO struct {
+	Brand string `json:"brand"`
+	Name  string `json:"name"`
+}
+
+type NewStockDTO struct {
+	// Price of a single switch, left out when not known
+	Price   *MoneyDTO `json:"price,omitempty"`
+	InStock bool      `json:"inStock"`
+}
+
+type StockDTO struct {
+	Switch    SwitchDTO `json:"switch"`
+	Price     *MoneyDTO `json:"price,omitempty"`
+	InStock   bool      `json:"inStock"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type NewSubscriptionDTO struct {
+	Switch  SwitchDTO `json:"switch"`
+	Kind    string    `json:"kind" enums:"price_drop,back_in_stock"`
+	Channel string    `json:"channel" enums:"inbox,email,webhook"`
+	// Target is the email address or webhook url, inbox alerts need none
+	Target string `json:"target,omitempty"`
+	// Price of a single switch at or below which price drops are notified about
+	Price *MoneyDTO `json:"price,omitempty"`
+}
+
+type SubscriptionDTO struct {
+	ID         int       `json:"id"`
+	Switch     SwitchDTO `json:"switch"`
+	Kind       string    `json:"kind" enums:"price_drop,back_in_stock"`
+	Channel    string    `json:"channel" enums:"inbox,email,webhook"`
+	Subscriber string    `json:"subscriber"`
+	Price      *MoneyDTO `json:"price,omitempty"`
+	// Token removes the subscription without an account, email addresses get it mailed until they confirm
+	Token string `json:"token,omitempty"`
+	// Confirmed is off until the email address confirms the subscription, only confirmed ones notify
+	Confirmed bool `json:"confirmed"`
+	// Triggered tells the awaited change holds now, the next notification comes once it is undone and happens again
+	Triggered bool      `json:"triggered"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type TokenDTO struct {
+	Token string `json:"token"`
+}
+
+type NotificationDTO struct {
+	ID        int        `json:"id"`
+	Kind      string     `json:"kind" enums:"price_drop,back_in_stock"`
+	Switch    SwitchDTO  `json:"switch"`
+	Subject   string     `json:"subject"`
+	Body      string     `json:"body"`
+	Price     *MoneyDTO  `json:"price,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+}
+
+func (dto *MoneyDTO) toModel() *models.Money {
+	if dto == nil {
+		return nil
+	}
+	return &models.Money{Amount: int64(math.Round(dto.Amount * 100)), Currency: dto.Currency}
+}
+
+func asMoneyDTO(m *models.Money) *MoneyDTO {
+	if m == nil {
+		return nil
+	}
+	return &MoneyDTO{Amount: float64(m.Amount) / 100, Currency: m.Currency}
+}
+
+func (dto NewStockDTO) toModel() models.NewStock {
+	return models.NewStock{
+		Price:   dto.Price.toModel(),
+		InStock: dto.InStock,
+	}
+}
+
+func (dto NewSubscriptionDTO) toModel() models.NewSubscription {
+	return models.NewSubscription{
+		Brand:   dto.Switch.Brand,
+		Name:    dto.Switch.Name,
+		Kind:    models.Kind(dto.Kind),
+		Channel: models.Channel(dto.Channel),
+		Target:  dto.Target,
+		Price:   dto.Price.toModel(),
+	}
+}
+
+func AsStockDTO(entity models.Stock) StockDTO {
+	return StockDTO{
+		Switch:    SwitchDTO{Brand: entity.Brand, Name: entity.Name},
+		Price:     asMoneyDTO(entity.Price),
+		InStock:   entity.InStock,
+		UpdatedAt: entity.UpdatedAt,
+	}
+}
+
+func AsSubscriptionDTO(entity models.Subscription) SubscriptionDTO {
+	return SubscriptionDTO{
+		ID:         entity.ID,
+		Switch:     SwitchDTO{Brand: entity.Brand, Name: entity.Name},
+		Kind:       string(entity.Kind),
+		Channel:    string(entity.Channel),
+		Subscriber: entity.Subscriber,
+		Price:      asMoneyDTO(entity.Price),
+		Token:      entity.Token,
+		Confirmed:  entity.Confirmed,
+		Triggered:  entity.Triggered,
+		CreatedAt:  entity.CreatedAt,
+	}
+}
+
+func AsNotificationDTO(entity models.Notification) NotificationDTO {
+	return NotificationDTO{
+		ID:        entity.ID,
+		Kind:      string(entity.Kind),
+		Switch:    SwitchDTO{Brand: entity.Brand, Name: entity.Name},
+		Subject:   entity.Subject,
+		Body:      entity.Body,
+		Price:     asMoneyDTO(entity.Price),
+		CreatedAt: entity.CreatedAt,
+		ReadAt:    entity.ReadAt,
+	}
+}
@@ -0,0 +1,314 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kbswitch/internal/core/alerts"
+	"kbswitch/internal/core/common"
+	"net/http"
+	"strconv"
+)
+
+const maxBodySize = 1 << 16
+
+type controller struct {
+	service alerts.Service
+}
+
+func New(service alerts.Service) controller {
+	return controller{
+		service: service,
+	}
+}
+
+func writeErr(err string, status int, w http.ResponseWriter) {
+	e := common.APIError{
+		Status:  status,
+		Message: err,
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprint(w, e)
+}
+
+func writeResult(w http.ResponseWriter, status int, dto any) {
+	json, _ := json.Marshal(dto)
+
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+func writeAppErr(w http.ResponseWriter, err common.AppError) {
+	e := common.ToAPIErr(err)
+	writeErr(e.Message, e.Status, w)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, dst any) bool {
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil && err != io.EOF {
+		writeErr("invalid request model", http.StatusBadRequest, w)
+		return false
+	}
+	return true
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErr("request parameter 'id' must be a number", http.StatusBadRequest, w)
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleStock godoc
+//
+//	@Summary		Price and stock of a switch
+//	@Tags			alerts
+//	@Produce		json
+//	@Param			brand	path		string	true	"brand of the switch"
+//	@Param			name	path		string	true	"name of the switch"
+//	@Success		200		{object}	StockDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/stock [get]
+func (c controller) HandleStock(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.Stock(ctx, r.PathValue("brand"), r.PathValue("name"))
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusOK, AsStockDTO(*resp))
+}
+
+// HandleStockSet godoc
+//
+//	@Summary		Set price and stock of a switch
+//	@Description	Replaces what is known about the switch, alert subscriptions to it are evaluated against the new price and stock
+//	@Tags			alerts
+//	@Accept			json
+//	@Produce		json
+//	@Param			brand	path		string		true	"brand of the switch"
+//	@Param			name	path		string		true	"name of the switch"
+//	@Param			stock	body		NewStockDTO	true	"price of a single switch and whether it is in stock"
+//	@Success		200		{object}	StockDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		403		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/stock [put]
+func (c controller) HandleStockSet(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req NewStockDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.SetStock(ctx, r.PathValue("brand"), r.PathValue("name"), req.toModel())
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusOK, AsStockDTO(*resp))
+}
+
+// HandleSubscriptions godoc
+//
+//	@Summary		Own alert subscriptions
+//	@Tags			alerts
+//	@Produce		json
+//	@Success		200	{array}		SubscriptionDTO
+//	@Failure		500	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Router			/api/me/alerts [get]
+func (c controller) HandleSubscriptions(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.Subscriptions(ctx)
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	dtos := make([]SubscriptionDTO, len(resp))
+	for i, s := range resp {
+		dtos[i] = AsSubscriptionDTO(s)
+	}
+	writeResult(w, http.StatusOK, dtos)
+}
+
+// HandleSubscribe godoc
+//
+//	@Summary		Subscribe to a price drop or restock
+//	@Description	Notifies once whenever the change happens after subscribing. Email and webhook alerts need no account, email ones notify once the address confirms them unless it is the verified address of the caller. The inbox needs being logged in
+//	@Tags			alerts
+//	@Accept			json
+//	@Produce		json
+//	@Param			subscription	body		NewSubscriptionDTO	true	"awaited change"
+//	@Success		201				{object}	SubscriptionDTO
+//	@Failure		500				{object}	common.APIError
+//	@Failure		400				{object}	common.APIError
+//	@Failure		401				{object}	common.APIError
+//	@Failure		404				{object}	common.APIError
+//	@Failure		409				{object}	common.APIError
+//	@Router			/api/alerts [post]
+func (c controller) HandleSubscribe(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req NewSubscriptionDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Subscribe(ctx, req.toModel())
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusCreated, AsSubscriptionDTO(*resp))
+}
+
+// HandleConfirm godoc
+//
+//	@Summary		Confirm an email alert subscription
+//	@Tags			alerts
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	body		TokenDTO	true	"token from the confirmation mail"
+//	@Success		200		{object}	SubscriptionDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/alerts/confirm [post]
+func (c controller) HandleConfirm(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req TokenDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Confirm(ctx, req.Token)
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusOK, AsSubscriptionDTO(*resp))
+}
+
+// HandleUnsubscribeToken godoc
+//
+//	@Summary		Remove an alert subscription by its token
+//	@Description	Needs no account, the token comes with the subscription, its confirmation mail and every notification
+//	@Tags			alerts
+//	@Accept			json
+//	@Param			token	body	TokenDTO	true	"token of the subscription"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Router			/api/alerts/unsubscribe [post]
+func (c controller) HandleUnsubscribeToken(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req TokenDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if err := c.service.UnsubscribeToken(ctx, req.Token); err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleUnsubscribe godoc
+//
+//	@Summary		Remove an own alert subscription
+//	@Description	Notifications of the subscription stay in the inbox
+//	@Tags			alerts
+//	@Param			id	path	int	true	"id of the subscription"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Router			/api/me/alerts/{id} [delete]
+func (c controller) HandleUnsubscribe(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.Unsubscribe(ctx, id); err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleInbox godoc
+//
+//	@Summary		Alert inbox
+//	@Description	Gives notifications of subscriptions made by the account through all channels, newest first
+//	@Tags			alerts
+//	@Produce		json
+//	@Param			unread	query		bool	false	"only notifications not read yet"
+//	@Success		200		{array}		NotificationDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Router			/api/me/inbox [get]
+func (c controller) HandleInbox(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var unread bool
+	if v := r.URL.Query().Get("unread"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			writeErr("query parameter 'unread' must be a boolean", http.StatusBadRequest, w)
+			return
+		}
+		unread = parsed
+	}
+
+	resp, err := c.service.Inbox(ctx, unread)
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	dtos := make([]NotificationDTO, len(resp))
+	for i, n := range resp {
+		dtos[i] = AsNotificationDTO(n)
+	}
+	writeResult(w, http.StatusOK, dtos)
+}
+
+// HandleRead godoc
+//
+//	@Summary		Mark a notification read
+//	@Tags			alerts
+//	@Param			id	path	int	true	"id of the notification"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Router			/api/me/inbox/{id}/read [post]
+func (c controller) HandleRead(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.MarkRead(ctx, id); err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
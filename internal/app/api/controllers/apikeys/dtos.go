@@ -0,0 +1,61 @@
+package apikeys
+
+import (
+	"kbswitch/internal/core/apikeys/models"
+	"time"
+)
+
+type NewKeyDTO struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes" enums:"read,write,admin"`
+	// ExpiresAt is optional, keys without it never expire
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func (d NewKeyDTO) toModel() models.NewKey {
+	scopes := make([]models.Scope, len(d.Scopes))
+	for i, s := range d.Scopes {
+		scopes[i] = models.Scope(s)
+	}
+
+	return models.NewKey{
+		Name:      d.Name,
+		Scopes:    scopes,
+		ExpiresAt: d.ExpiresAt,
+	}
+}
+
+type KeyDTO struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+func AsDTO(entity models.Key) KeyDTO {
+	scopes := make([]string, len(entity.Scopes))
+	for i, s := range entity.Scopes {
+		scopes[i] = string(s)
+	}
+
+	return KeyDTO{
+		ID:         entity.ID,
+		Name:       entity.Name,
+		Prefix:     entity.Prefix,
+		Scopes:     scopes,
+		CreatedAt:  entity.CreatedAt,
+		ExpiresAt:  entity.ExpiresAt,
+		RevokedAt:  entity.RevokedAt,
+		LastUsedAt: entity.LastUsedAt,
+	}
+}
+
+type CreatedKeyDTO struct {
+	Key KeyDTO `json:"key"`
+	// Secret is shown only once, in response to creation
+	Secret string `json:"secret"`
+}
@@ -0,0 +1,131 @@
+package apikeys
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kbswitch/internal/core/apikeys"
+	"kbswitch/internal/core/common"
+	"net/http"
+	"strconv"
+)
+
+type controller struct {
+	service apikeys.Service
+}
+
+func New(service apikeys.Service) controller {
+	return controller{
+		service: service,
+	}
+}
+
+func writeErr(err string, status int, w http.ResponseWriter) {
+	e := common.APIError{
+		Status:  status,
+		Message: err,
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprint(w, e)
+}
+
+// HandleKeyCreate godoc
+//
+//	@Summary		Create an api key
+//	@Description	Creates a key with given scopes, secret of the key is given only in this response
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			key			body		NewKeyDTO	true	"key to create"
+//	@Param			X-API-Key	header		string		true	"api key with admin scope"
+//	@Success		201			{object}	CreatedKeyDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		400			{object}	common.APIError
+//	@Failure		401			{object}	common.APIError
+//	@Failure		403			{object}	common.APIError
+//	@Router			/api/admin/keys [post]
+func (c controller) HandleKeyCreate(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req NewKeyDTO
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeErr("invalid request model", http.StatusBadRequest, w)
+		return
+	}
+
+	resp, err := c.service.Create(ctx, req.toModel())
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	json, _ := json.Marshal(CreatedKeyDTO{Key: AsDTO(resp.Key), Secret: resp.Secret})
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleKeys godoc
+//
+//	@Summary		List api keys
+//	@Description	Gives every key including revoked and expired ones, secrets are never shown
+//	@Tags			admin
+//	@Produce		json
+//	@Param			X-API-Key	header		string	true	"api key with admin scope"
+//	@Success		200			{array}		KeyDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		401			{object}	common.APIError
+//	@Failure		403			{object}	common.APIError
+//	@Router			/api/admin/keys [get]
+func (c controller) HandleKeys(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.List(ctx)
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	dtos := make([]KeyDTO, len(resp))
+	for i, k := range resp {
+		dtos[i] = AsDTO(k)
+	}
+
+	json, _ := json.Marshal(dtos)
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleKeyRevoke godoc
+//
+//	@Summary		Revoke an api key
+//	@Description	Revoked key is refused right away, it stays listed for the record
+//	@Tags			admin
+//	@Param			id			path	int		true	"id of the key"
+//	@Param			X-API-Key	header	string	true	"api key with admin scope"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		403	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Router			/api/admin/keys/{id} [delete]
+func (c controller) HandleKeyRevoke(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErr("request parameter 'id' must be a number", http.StatusBadRequest, w)
+		return
+	}
+
+	if err := c.service.Revoke(ctx, id); err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,7 @@
+package autocomplete
+
+type suggestionDTO struct {
+	Brand string  `json:"brand"`
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
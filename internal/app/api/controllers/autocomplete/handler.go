@@ -0,0 +1,83 @@
+package autocomplete
+
+import (
+	"encoding/json"
+	"fmt"
+	"kbswitch/internal/core/autocomplete"
+	"kbswitch/internal/core/common"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultLimit = 8
+	maxLimit     = 25
+)
+
+type controller struct {
+	suggester autocomplete.Suggester
+}
+
+func New(suggester autocomplete.Suggester) controller {
+	return controller{
+		suggester: suggester,
+	}
+}
+
+func writeErr(err string, status int, w http.ResponseWriter) {
+	e := common.APIError{
+		Status:  status,
+		Message: err,
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprint(w, e)
+}
+
+// HandleAutocomplete godoc
+//
+//	@Summary		Suggest switches while typing
+//	@Description	Gives brand/name suggestions for a partial, possibly misspelled query. Served from memory
+//	@Tags			switches
+//	@Produce		json
+//	@Param			q		query		string	true	"partial query, e.g. 'gatron yelow'"
+//	@Param			limit	query		int		false	"max number of suggestions, 8 by default"
+//	@Success		200		{array}		suggestionDTO
+//	@Failure		400		{object}	common.APIError
+//	@Router			/api/switches/autocomplete [get]
+func (c controller) HandleAutocomplete(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	q := query.Get("q")
+	if q == "" {
+		writeErr("query parameter 'q' is missing", http.StatusBadRequest, w)
+		return
+	}
+
+	limit := defaultLimit
+	if l := query.Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 || parsed > maxLimit {
+			writeErr(fmt.Sprintf("query parameter 'limit' must be a number between 1 and %d", maxLimit), http.StatusBadRequest, w)
+			return
+		}
+		limit = parsed
+	}
+
+	suggestions := c.suggester.Suggest(q, limit)
+
+	dtos := make([]suggestionDTO, len(suggestions))
+	for i, s := range suggestions {
+		dtos[i] = suggestionDTO{
+			Brand: s.Brand,
+			Name:  s.Name,
+			Score: math.Round(s.Score*100) / 100,
+		}
+	}
+
+	json, _ := json.Marshal(dtos)
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
@@ -0,0 +1,230 @@
+package collections
+
+import (
+	"kbswitch/internal/core/collections"
+	"kbswitch/internal/core/collections/models"
+	"math"
+	"time"
+)
+
+// SwitchRefDTO points at a switch by id, or by brand and name when id is left out
+type SwitchRefDTO struct {
+	ID    *int   `json:"id,omitempty"`
+	Brand string `json:"brand,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+type SwitchDTO struct {
+	ID    int    `json:"id"`
+	Brand string `json:"brand"`
+	Name  string `json:"name"`
+}
+
+type MoneyDTO struct {
+	Amount   float64 `json:"amount" example:"0.65"`
+	Currency string  `json:"currency" example:"USD"`
+}
+
+type NewItemDTO struct {
+	Switch    SwitchRefDTO `json:"switch"`
+	Quantity  int          `json:"quantity" minimum:"1" default:"1"`
+	Condition string       `json:"condition" enums:"new,like_new,used,worn" default:"new"`
+	Lubed     bool         `json:"lubed"`
+	// Price of a single switch
+	Price *MoneyDTO `json:"price,omitempty"`
+	Note  string    `json:"note"`
+}
+
+// ItemChangeDTO replaces an item, the switch it is about stays the same
+type ItemChangeDTO struct {
+	Quantity  int       `json:"quantity" minimum:"1" default:"1"`
+	Condition string    `json:"condition" enums:"new,like_new,used,worn" default:"new"`
+	Lubed     bool      `json:"lubed"`
+	Price     *MoneyDTO `json:"price,omitempty"`
+	Note      string    `json:"note"`
+}
+
+type ItemDTO struct {
+	ID        int       `json:"id"`
+	Switch    SwitchDTO `json:"switch"`
+	Quantity  int       `json:"quantity"`
+	Condition string    `json:"condition" enums:"new,like_new,used,worn"`
+	Lubed     bool      `json:"lubed"`
+	Price     *MoneyDTO `json:"price,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ValueDTO struct {
+	// Totals of prices, one per currency
+	Totals   []MoneyDTO `json:"totals"`
+	Switches int        `json:"switches"`
+	Quantity int        `json:"quantity"`
+	// Unpriced counts switches of unknown price, they are left out of totals
+	Unpriced int `json:"unpriced"`
+}
+
+type CollectionDTO struct {
+	Items []ItemDTO `json:"items"`
+	Value ValueDTO  `json:"value"`
+}
+
+type NewWishDTO struct {
+	Switch   SwitchRefDTO `json:"switch"`
+	Quantity int          `json:"quantity" minimum:"1" default:"1"`
+	Note     string       `json:"note"`
+}
+
+type WishChangeDTO struct {
+	Quantity int    `json:"quantity" minimum:"1" default:"1"`
+	Note     string `json:"note"`
+}
+
+type WishDTO struct {
+	ID        int       `json:"id"`
+	Switch    SwitchDTO `json:"switch"`
+	Quantity  int       `json:"quantity"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ShareRequestDTO struct {
+	// Public makes the link fit to be published, private links are unlisted and kept out of search engines and caches
+	Public bool `json:"public"`
+}
+
+type ShareDTO struct {
+	List   string `json:"list" enums:"collection,wishlist"`
+	Public bool   `json:"public"`
+	URL    string `json:"url"`
+}
+
+type SharedDTO struct {
+	List   string    `json:"list" enums:"collection,wishlist"`
+	Items  []ItemDTO `json:"items,omitempty"`
+	Wishes []WishDTO `json:"wishes,omitempty"`
+}
+
+func (dto SwitchRefDTO) toModel() models.SwitchRef {
+	return models.SwitchRef{ID: dto.ID, Brand: dto.Brand, Name: dto.Name}
+}
+
+func (dto *MoneyDTO) toModel() *models.Money {
+	if dto == nil {
+		return nil
+	}
+	return &models.Money{Amount: int64(math.Round(dto.Amount * 100)), Currency: dto.Currency}
+}
+
+func (dto NewItemDTO) toModel() models.NewItem {
+	return models.NewItem{
+		Switch:    dto.Switch.toModel(),
+		Quantity:  dto.Quantity,
+		Condition: models.Condition(dto.Condition),
+		Lubed:     dto.Lubed,
+		Price:     dto.Price.toModel(),
+		Note:      dto.Note,
+	}
+}
+
+func (dto ItemChangeDTO) toModel() models.NewItem {
+	return models.NewItem{
+		Quantity:  dto.Quantity,
+		Condition: models.Condition(dto.Condition),
+		Lubed:     dto.Lubed,
+		Price:     dto.Price.toModel(),
+		Note:      dto.Note,
+	}
+}
+
+func (dto NewWishDTO) toModel() models.NewWish {
+	return models.NewWish{Switch: dto.Switch.toModel(), Quantity: dto.Quantity, Note: dto.Note}
+}
+
+func (dto WishChangeDTO) toModel() models.NewWish {
+	return models.NewWish{Quantity: dto.Quantity, Note: dto.Note}
+}
+
+func asMoneyDTO(m models.Money) MoneyDTO {
+	return MoneyDTO{Amount: float64(m.Amount) / 100, Currency: m.Currency}
+}
+
+func AsItemDTO(i models.Item) ItemDTO {
+	res := ItemDTO{
+		ID:        i.ID,
+		Switch:    SwitchDTO{ID: i.SwitchID, Brand: i.Brand, Name: i.Name},
+		Quantity:  i.Quantity,
+		Condition: string(i.Condition),
+		Lubed:     i.Lubed,
+		Note:      i.Note,
+		CreatedAt: i.CreatedAt,
+		UpdatedAt: i.UpdatedAt,
+	}
+	if i.Price != nil {
+		price := asMoneyDTO(*i.Price)
+		res.Price = &price
+	}
+	return res
+}
+
+func AsItemDTOs(items []models.Item) []ItemDTO {
+	res := make([]ItemDTO, len(items))
+	for i, item := range items {
+		res[i] = AsItemDTO(item)
+	}
+	return res
+}
+
+func AsCollectionDTO(c collections.Collection) CollectionDTO {
+	value := ValueDTO{
+		Totals:   make([]MoneyDTO, len(c.Value.Totals)),
+		Switches: c.Value.Switches,
+		Quantity: c.Value.Quantity,
+		Unpriced: c.Value.Unpriced,
+	}
+	for i, m := range c.Value.Totals {
+		value.Totals[i] = asMoneyDTO(m)
+	}
+
+	return CollectionDTO{
+		Items: AsItemDTOs(c.Items),
+		Value: value,
+	}
+}
+
+func AsWishDTO(w models.Wish) WishDTO {
+	return WishDTO{
+		ID:        w.ID,
+		Switch:    SwitchDTO{ID: w.SwitchID, Brand: w.Brand, Name: w.Name},
+		Quantity:  w.Quantity,
+		Note:      w.Note,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+	}
+}
+
+func AsWishDTOs(wishes []models.Wish) []WishDTO {
+	res := make([]WishDTO, len(wishes))
+	for i, w := range wishes {
+		res[i] = AsWishDTO(w)
+	}
+	return res
+}
+
+func AsShareDTO(s models.Share, baseURL string) ShareDTO {
+	return ShareDTO{
+		List:   string(s.List),
+		Public: s.Public,
+		URL:    baseURL + "/api/shared/" + s.Token,
+	}
+}
+
+func AsSharedDTO(s models.Shared) SharedDTO {
+	return SharedDTO{
+		List:   string(s.List),
+		Items:  AsItemDTOs(s.Items),
+		Wishes: AsWishDTOs(s.Wishes),
+	}
+}
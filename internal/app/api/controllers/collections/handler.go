@@ -0,0 +1,385 @@
+package collections
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kbswitch/internal/core/collections"
+	"kbswitch/internal/core/collections/models"
+	"kbswitch/internal/core/common"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxBodySize = 1 << 16
+
+type controller struct {
+	service collections.Service
+	// baseURL is where share links point to
+	baseURL string
+}
+
+func New(service collections.Service, baseURL string) controller {
+	return controller{
+		service: service,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func writeErr(err string, status int, w http.ResponseWriter) {
+	e := common.APIError{
+		Status:  status,
+		Message: err,
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprint(w, e)
+}
+
+func writeResult(w http.ResponseWriter, status int, dto any) {
+	json, _ := json.Marshal(dto)
+
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+func writeAppErr(w http.ResponseWriter, err common.AppError) {
+	e := common.ToAPIErr(err)
+	writeErr(e.Message, e.Status, w)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, dst any) bool {
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil && err != io.EOF {
+		writeErr("invalid request model", http.StatusBadRequest, w)
+		return false
+	}
+	return true
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErr("request parameter 'id' must be a number", http.StatusBadRequest, w)
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleCollection godoc
+//
+//	@Summary		Own collection
+//	@Description	Gives switches the user owns together with value of all of them
+//	@Tags			collections
+//	@Produce		json
+//	@Success		200	{object}	CollectionDTO
+//	@Failure		500	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Router			/api/me/collection [get]
+func (c controller) HandleCollection(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.Collection(ctx)
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusOK, AsCollectionDTO(*resp))
+}
+
+// HandleItemAdd godoc
+//
+//	@Summary		Add to collection
+//	@Description	Switch is given by id or by brand and name, the same switch can be added many times, e.g. in other condition
+//	@Tags			collections
+//	@Accept			json
+//	@Produce		json
+//	@Param			item	body		NewItemDTO	true	"owned switches"
+//	@Success		201		{object}	ItemDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/me/collection [post]
+func (c controller) HandleItemAdd(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req NewItemDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.AddItem(ctx, req.toModel())
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusCreated, AsItemDTO(*resp))
+}
+
+// HandleItemUpdate godoc
+//
+//	@Summary		Change collection item
+//	@Tags			collections
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"id of the item"
+//	@Param			item	body		ItemChangeDTO	true	"owned switches"
+//	@Success		200		{object}	ItemDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/me/collection/{id} [put]
+func (c controller) HandleItemUpdate(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req ItemChangeDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.UpdateItem(ctx, id, req.toModel())
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusOK, AsItemDTO(*resp))
+}
+
+// HandleItemRemove godoc
+//
+//	@Summary		Remove from collection
+//	@Tags			collections
+//	@Param			id	path	int	true	"id of the item"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Router			/api/me/collection/{id} [delete]
+func (c controller) HandleItemRemove(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.RemoveItem(ctx, id); err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleWishlist godoc
+//
+//	@Summary		Own wishlist
+//	@Tags			collections
+//	@Produce		json
+//	@Success		200	{array}		WishDTO
+//	@Failure		500	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Router			/api/me/wishlist [get]
+func (c controller) HandleWishlist(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.Wishlist(ctx)
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusOK, AsWishDTOs(resp))
+}
+
+// HandleWishAdd godoc
+//
+//	@Summary		Add to wishlist
+//	@Description	Switch is given by id or by brand and name, every switch can be on the wishlist once
+//	@Tags			collections
+//	@Accept			json
+//	@Produce		json
+//	@Param			wish	body		NewWishDTO	true	"wanted switches"
+//	@Success		201		{object}	WishDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Failure		409		{object}	common.APIError
+//	@Router			/api/me/wishlist [post]
+func (c controller) HandleWishAdd(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req NewWishDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.AddWish(ctx, req.toModel())
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusCreated, AsWishDTO(*resp))
+}
+
+// HandleWishUpdate godoc
+//
+//	@Summary		Change wishlist entry
+//	@Tags			collections
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"id of the entry"
+//	@Param			wish	body		WishChangeDTO	true	"wanted switches"
+//	@Success		200		{object}	WishDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/me/wishlist/{id} [put]
+func (c controller) HandleWishUpdate(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req WishChangeDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.UpdateWish(ctx, id, req.toModel())
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusOK, AsWishDTO(*resp))
+}
+
+// HandleWishRemove godoc
+//
+//	@Summary		Remove from wishlist
+//	@Tags			collections
+//	@Param			id	path	int	true	"id of the entry"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Router			/api/me/wishlist/{id} [delete]
+func (c controller) HandleWishRemove(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.RemoveWish(ctx, id); err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleShare godoc
+//
+//	@Summary		Link of a list
+//	@Tags			collections
+//	@Produce		json
+//	@Param			list	path		string	true	"shared list"	Enums(collection, wishlist)
+//	@Success		200		{object}	ShareDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/me/{list}/share [get]
+func (c controller) HandleShare(ctx context.Context, w http.ResponseWriter, r *http.Request, list models.List) {
+	resp, err := c.service.Share(ctx, list)
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusOK, AsShareDTO(*resp, c.baseURL))
+}
+
+// HandleShareSet godoc
+//
+//	@Summary		Share a list
+//	@Description	Creates link of the list or changes who it works for, the link stays the same
+//	@Tags			collections
+//	@Accept			json
+//	@Produce		json
+//	@Param			list	path		string			true	"shared list"	Enums(collection, wishlist)
+//	@Param			share	body		ShareRequestDTO	true	"visibility of the link"
+//	@Success		200		{object}	ShareDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Router			/api/me/{list}/share [put]
+func (c controller) HandleShareSet(ctx context.Context, w http.ResponseWriter, r *http.Request, list models.List) {
+	var req ShareRequestDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.SetShare(ctx, list, req.Public)
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusOK, AsShareDTO(*resp, c.baseURL))
+}
+
+// HandleShareRemove godoc
+//
+//	@Summary		Stop sharing a list
+//	@Description	Link stops working, sharing the list again gives another one
+//	@Tags			collections
+//	@Param			list	path	string	true	"shared list"	Enums(collection, wishlist)
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Router			/api/me/{list}/share [delete]
+func (c controller) HandleShareRemove(ctx context.Context, w http.ResponseWriter, r *http.Request, list models.List) {
+	if err := c.service.Unshare(ctx, list); err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleShared godoc
+//
+//	@Summary		Shared list
+//	@Description	Gives the list behind a link without prices and notes to whoever holds its token, private links are unlisted
+//	@Tags			collections
+//	@Produce		json
+//	@Param			token	path		string	true	"token of the link"
+//	@Success		200		{object}	SharedDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/shared/{token} [get]
+func (c controller) HandleShared(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.Shared(ctx, r.PathValue("token"))
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	if !resp.Public {
+		// the token is all that guards an unlisted link, it is not to be indexed or kept by shared caches
+		w.Header().Set("X-Robots-Tag", "noindex")
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+	writeResult(w, http.StatusOK, AsSharedDTO(*resp))
+}
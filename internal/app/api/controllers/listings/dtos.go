@@ -0,0 +1,143 @@
+package listings
+
+import (
+	"kbswitch/internal/core/listings/models"
+	"math"
+	"time"
+)
+
+type NewVendorDTO struct {
+	Name string `json:"name" example:"Divinikey"`
+	URL  string `json:"url" example:"https://divinikey.com"`
+}
+
+type VendorDTO struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func AsVendorDTO(entity models.Vendor) VendorDTO {
+	return VendorDTO{
+		ID:   entity.ID,
+		Name: entity.Name,
+		URL:  entity.URL,
+	}
+}
+
+func (dto NewVendorDTO) toModel() models.NewVendor {
+	return models.NewVendor{Name: dto.Name, URL: dto.URL}
+}
+
+// NewListingDTO is what a vendor offers, price is for the whole pack
+type NewListingDTO struct {
+	// VendorID can be left out when a listing changes, its vendor stays the same
+	VendorID int     `json:"vendorId"`
+	URL      string  `json:"url" example:"https://divinikey.com/products/gateron-oil-king"`
+	Price    float64 `json:"price" example:"6.50"`
+	Currency string  `json:"currency" example:"USD"`
+	PackSize int     `json:"packSize" minimum:"1" default:"1" example:"10"`
+	InStock  bool    `json:"inStock"`
+}
+
+func (dto NewListingDTO) toModel() models.NewListing {
+	packSize := dto.PackSize
+	if packSize == 0 {
+		packSize = 1
+	}
+
+	return models.NewListing{
+		VendorID: dto.VendorID,
+		URL:      dto.URL,
+		Price:    int64(math.Round(dto.Price * 100)),
+		Currency: dto.Currency,
+		PackSize: packSize,
+		InStock:  dto.InStock,
+	}
+}
+
+type ListingDTO struct {
+	ID     int       `json:"id"`
+	Vendor VendorRef `json:"vendor"`
+	URL    string    `json:"url"`
+	// Price is for the whole pack
+	Price     float64   `json:"price"`
+	Currency  string    `json:"currency"`
+	PackSize  int       `json:"packSize"`
+	UnitPrice float64   `json:"unitPrice"`
+	InStock   bool      `json:"inStock"`
+	CreatedAt time.Time `json:"createdAt"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+type VendorRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func AsListingDTO(entity models.Listing) ListingDTO {
+	return ListingDTO{
+		ID:        entity.ID,
+		Vendor:    VendorRef{ID: entity.VendorID, Name: entity.VendorName},
+		URL:       entity.URL,
+		Price:     float64(entity.Price) / 100,
+		Currency:  entity.Currency,
+		PackSize:  entity.PackSize,
+		UnitPrice: round(entity.UnitPrice),
+		InStock:   entity.InStock,
+		CreatedAt: entity.CreatedAt,
+		CheckedAt: entity.CheckedAt,
+	}
+}
+
+type PricePointDTO struct {
+	At        time.Time `json:"at"`
+	Price     float64   `json:"price"`
+	Currency  string    `json:"currency"`
+	PackSize  int       `json:"packSize"`
+	UnitPrice float64   `json:"unitPrice"`
+	InStock   bool      `json:"inStock"`
+}
+
+type ListingHistoryDTO struct {
+	Listing ListingDTO `json:"listing"`
+	// History holds a point per change, oldest first, the first one may precede the period as it tells the state at its start
+	History []PricePointDTO `json:"history"`
+}
+
+type PriceReportDTO struct {
+	// Best holds the cheapest in stock listing per unit for every currency, prices are never converted
+	Best     []ListingDTO        `json:"best"`
+	Listings []ListingHistoryDTO `json:"listings"`
+}
+
+func AsPriceReportDTO(entity models.PriceReport) PriceReportDTO {
+	res := PriceReportDTO{
+		Best:     make([]ListingDTO, len(entity.Best)),
+		Listings: make([]ListingHistoryDTO, len(entity.Listings)),
+	}
+	for i, l := range entity.Best {
+		res.Best[i] = AsListingDTO(l)
+	}
+	for i, l := range entity.Listings {
+		history := make([]PricePointDTO, len(l.History))
+		for j, p := range l.History {
+			history[j] = PricePointDTO{
+				At:        p.At,
+				Price:     float64(p.Price) / 100,
+				Currency:  p.Currency,
+				PackSize:  p.PackSize,
+				UnitPrice: round(p.UnitPrice),
+				InStock:   p.InStock,
+			}
+		}
+		res.Listings[i] = ListingHistoryDTO{Listing: AsListingDTO(l.Listing), History: history}
+	}
+
+	return res
+}
+
+// round keeps unit prices of switches sold in large packs to a hundredth of a cent
+func round(x float64) float64 {
+	return math.Round(x*10000) / 10000
+}
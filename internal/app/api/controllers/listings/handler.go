@@ -0,0 +1,314 @@
+package listings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/listings"
+	"kbswitch/internal/core/listings/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	maxBodySize = 1 << 16
+	defaultDays = 90
+	maxDays     = 730
+)
+
+type controller struct {
+	service listings.Service
+}
+
+func New(service listings.Service) controller {
+	return controller{
+		service: service,
+	}
+}
+
+func writeErr(err string, status int, w http.ResponseWriter) {
+	e := common.APIError{
+		Status:  status,
+		Message: err,
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprint(w, e)
+}
+
+func writeResult(w http.ResponseWriter, status int, dto any) {
+	json, _ := json.Marshal(dto)
+
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+func writeAppErr(w http.ResponseWriter, err common.AppError) {
+	e := common.ToAPIErr(err)
+	writeErr(e.Message, e.Status, w)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, dst any) bool {
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil && err != io.EOF {
+		writeErr("invalid request model", http.StatusBadRequest, w)
+		return false
+	}
+	return true
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErr("request parameter 'id' must be a number", http.StatusBadRequest, w)
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleVendors godoc
+//
+//	@Summary		List vendors
+//	@Tags			listings
+//	@Produce		json
+//	@Success		200	{array}		VendorDTO
+//	@Failure		500	{object}	common.APIError
+//	@Router			/api/vendors [get]
+func (c controller) HandleVendors(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.Vendors(ctx)
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	dtos := make([]VendorDTO, len(resp))
+	for i, v := range resp {
+		dtos[i] = AsVendorDTO(v)
+	}
+	writeResult(w, http.StatusOK, dtos)
+}
+
+// HandleVendorAdd godoc
+//
+//	@Summary		Add a vendor
+//	@Tags			listings
+//	@Accept			json
+//	@Produce		json
+//	@Param			vendor	body		NewVendorDTO	true	"vendor"
+//	@Success		201		{object}	VendorDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		403		{object}	common.APIError
+//	@Failure		409		{object}	common.APIError
+//	@Router			/api/vendors [post]
+func (c controller) HandleVendorAdd(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req NewVendorDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.AddVendor(ctx, req.toModel())
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusCreated, AsVendorDTO(*resp))
+}
+
+// HandleVendorRemove godoc
+//
+//	@Summary		Remove a vendor
+//	@Description	Listings of the vendor are removed together with their price history
+//	@Tags			listings
+//	@Param			id	path	int	true	"id of the vendor"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		403	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Router			/api/vendors/{id} [delete]
+func (c controller) HandleVendorRemove(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.RemoveVendor(ctx, id); err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListings godoc
+//
+//	@Summary		List vendor listings of a switch
+//	@Tags			listings
+//	@Produce		json
+//	@Param			brand	path		string	true	"brand of the switch"
+//	@Param			name	path		string	true	"name of the switch"
+//	@Success		200		{array}		ListingDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/listings [get]
+func (c controller) HandleListings(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.Listings(ctx, r.PathValue("brand"), r.PathValue("name"))
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	dtos := make([]ListingDTO, len(resp))
+	for i, l := range resp {
+		dtos[i] = AsListingDTO(l)
+	}
+	writeResult(w, http.StatusOK, dtos)
+}
+
+// HandleListingAdd godoc
+//
+//	@Summary		Add a vendor listing of a switch
+//	@Description	The first price snapshot of the listing is recorded as well
+//	@Tags			listings
+//	@Accept			json
+//	@Produce		json
+//	@Param			brand	path		string			true	"brand of the switch"
+//	@Param			name	path		string			true	"name of the switch"
+//	@Param			listing	body		NewListingDTO	true	"offer of the vendor"
+//	@Success		201		{object}	ListingDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		403		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Failure		409		{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/listings [post]
+func (c controller) HandleListingAdd(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req NewListingDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.AddListing(ctx, r.PathValue("brand"), r.PathValue("name"), req.toModel())
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusCreated, AsListingDTO(*resp))
+}
+
+// HandleListingUpdate godoc
+//
+//	@Summary		Change a vendor listing of a switch
+//	@Description	Records a price snapshot when price or availability changed, marks the listing as checked in any case
+//	@Tags			listings
+//	@Accept			json
+//	@Produce		json
+//	@Param			brand	path		string			true	"brand of the switch"
+//	@Param			name	path		string			true	"name of the switch"
+//	@Param			id		path		int				true	"id of the listing"
+//	@Param			listing	body		NewListingDTO	true	"offer of the vendor"
+//	@Success		200		{object}	ListingDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		403		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/listings/{id} [put]
+func (c controller) HandleListingUpdate(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req NewListingDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.UpdateListing(ctx, r.PathValue("brand"), r.PathValue("name"), id, req.toModel())
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusOK, AsListingDTO(*resp))
+}
+
+// HandleListingRemove godoc
+//
+//	@Summary		Remove a vendor listing of a switch
+//	@Tags			listings
+//	@Param			brand	path	string	true	"brand of the switch"
+//	@Param			name	path	string	true	"name of the switch"
+//	@Param			id		path	int		true	"id of the listing"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		403	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/listings/{id} [delete]
+func (c controller) HandleListingRemove(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.RemoveListing(ctx, r.PathValue("brand"), r.PathValue("name"), id); err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePrices godoc
+//
+//	@Summary		Prices of a switch
+//	@Description	Gives the best price per unit in stock for every currency and price history of each listing for charting
+//	@Tags			listings
+//	@Produce		json
+//	@Param			brand		path		string	true	"brand of the switch"
+//	@Param			name		path		string	true	"name of the switch"
+//	@Param			currency	query		string	false	"only listings priced in the currency"
+//	@Param			days		query		int		false	"days of history, 90 by default and 730 at most"
+//	@Success		200			{object}	PriceReportDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		400			{object}	common.APIError
+//	@Failure		404			{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/prices [get]
+func (c controller) HandlePrices(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	days := defaultDays
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxDays {
+			writeErr(fmt.Sprintf("query parameter 'days' must be a number from 1 to %d", maxDays), http.StatusBadRequest, w)
+			return
+		}
+		days = n
+	}
+
+	resp, err := c.service.Prices(ctx, r.PathValue("brand"), r.PathValue("name"), models.PriceQuery{
+		Currency: r.URL.Query().Get("currency"),
+		Since:    time.Now().AddDate(0, 0, -days),
+	})
+	if err != nil {
+		writeAppErr(w, *err)
+		return
+	}
+
+	writeResult(w, http.StatusOK, AsPriceReportDTO(*resp))
+}
@@ -0,0 +1,106 @@
+package reviews
+
+import (
+	"kbswitch/internal/core/reviews"
+	"kbswitch/internal/core/reviews/models"
+	switchmodels "kbswitch/internal/core/switches/models"
+	"math"
+	"time"
+)
+
+type ScoresDTO struct {
+	Overall    int `json:"overall" minimum:"1" maximum:"5"`
+	Smoothness int `json:"smoothness" minimum:"1" maximum:"5"`
+	Sound      int `json:"sound" minimum:"1" maximum:"5"`
+	Feel       int `json:"feel" minimum:"1" maximum:"5"`
+}
+
+type NewReviewDTO struct {
+	Scores ScoresDTO `json:"scores"`
+	Body   string    `json:"body"`
+}
+
+type ReviewDTO struct {
+	ID        int       `json:"id"`
+	Author    string    `json:"author"`
+	Scores    ScoresDTO `json:"scores"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type SummaryDTO struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean"`
+	// Histogram counts reviews per overall score, from 1 star to 5 stars
+	Histogram  []int   `json:"histogram"`
+	Smoothness float64 `json:"smoothness"`
+	Sound      float64 `json:"sound"`
+	Feel       float64 `json:"feel"`
+}
+
+type ReviewPageDTO struct {
+	Reviews  []ReviewDTO `json:"reviews"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"pageSize"`
+	Total    int         `json:"total"`
+	Summary  SummaryDTO  `json:"summary"`
+}
+
+func round(x float64) float64 {
+	return math.Round(x*10000) / 10000
+}
+
+func (dto NewReviewDTO) toModel() models.NewReview {
+	return models.NewReview{
+		Scores: models.Scores{
+			Overall:    dto.Scores.Overall,
+			Smoothness: dto.Scores.Smoothness,
+			Sound:      dto.Scores.Sound,
+			Feel:       dto.Scores.Feel,
+		},
+		Body: dto.Body,
+	}
+}
+
+func AsDTO(r models.Review) ReviewDTO {
+	return ReviewDTO{
+		ID:     r.ID,
+		Author: r.Author,
+		Scores: ScoresDTO{
+			Overall:    r.Scores.Overall,
+			Smoothness: r.Scores.Smoothness,
+			Sound:      r.Scores.Sound,
+			Feel:       r.Scores.Feel,
+		},
+		Body:      r.Body,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+}
+
+func AsSummaryDTO(s switchmodels.RatingSummary) SummaryDTO {
+	return SummaryDTO{
+		Count:      s.Count,
+		Mean:       round(s.Mean),
+		Histogram:  s.Histogram[:],
+		Smoothness: round(s.Smoothness),
+		Sound:      round(s.Sound),
+		Feel:       round(s.Feel),
+	}
+}
+
+func AsPageDTO(p reviews.ReviewPage) ReviewPageDTO {
+	dtos := make([]ReviewDTO, len(p.Reviews))
+	for i, r := range p.Reviews {
+		dtos[i] = AsDTO(r)
+	}
+
+	return ReviewPageDTO{
+		Reviews:  dtos,
+		Page:     p.Page,
+		PageSize: p.PageSize,
+		Total:    p.Total,
+		Summary:  AsSummaryDTO(p.Summary),
+	}
+}
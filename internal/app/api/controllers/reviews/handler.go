@@ -0,0 +1,224 @@
+package reviews
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/reviews"
+	"kbswitch/internal/core/reviews/models"
+	"net/http"
+	"strconv"
+)
+
+const maxBodySize = 1 << 16
+
+type controller struct {
+	service reviews.Service
+}
+
+func New(service reviews.Service) controller {
+	return controller{
+		service: service,
+	}
+}
+
+func writeErr(err string, status int, w http.ResponseWriter) {
+	e := common.APIError{
+		Status:  status,
+		Message: err,
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprint(w, e)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, dst any) bool {
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil && err != io.EOF {
+		writeErr("invalid request model", http.StatusBadRequest, w)
+		return false
+	}
+	return true
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErr("request parameter 'id' must be a number", http.StatusBadRequest, w)
+		return 0, false
+	}
+	return id, true
+}
+
+// queryInt reads an optional number from query, zero when it is absent
+func queryInt(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		writeErr(fmt.Sprintf("query parameter '%s' must be a number", name), http.StatusBadRequest, w)
+		return 0, false
+	}
+	return n, true
+}
+
+// HandleReviews godoc
+//
+//	@Summary		List reviews of a switch
+//	@Description	Gives a page of reviews together with rating of the switch
+//	@Tags			reviews
+//	@Produce		json
+//	@Param			brand		path		string	true	"brand of the switch"
+//	@Param			name		path		string	true	"name of the switch"
+//	@Param			page		query		int		false	"page number, 1 by default"
+//	@Param			pageSize	query		int		false	"reviews per page, 20 by default and 100 at most"
+//	@Param			sort		query		string	false	"order of reviews, newest by default"	Enums(newest, oldest, highest, lowest)
+//	@Success		200			{object}	ReviewPageDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		400			{object}	common.APIError
+//	@Failure		404			{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/reviews [get]
+func (c controller) HandleReviews(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	page, ok := queryInt(w, r, "page")
+	if !ok {
+		return
+	}
+	pageSize, ok := queryInt(w, r, "pageSize")
+	if !ok {
+		return
+	}
+
+	resp, err := c.service.List(ctx, r.PathValue("brand"), r.PathValue("name"), models.PageRequest{
+		Page:     page,
+		PageSize: pageSize,
+		Sort:     models.Sort(r.URL.Query().Get("sort")),
+	})
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	json, _ := json.Marshal(AsPageDTO(*resp))
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleReviewAdd godoc
+//
+//	@Summary		Review a switch
+//	@Description	Everybody can review a switch once, later changes go to the same review
+//	@Tags			reviews
+//	@Accept			json
+//	@Produce		json
+//	@Param			brand	path		string			true	"brand of the switch"
+//	@Param			name	path		string			true	"name of the switch"
+//	@Param			review	body		NewReviewDTO	true	"scores from 1 to 5 and an optional text"
+//	@Success		201		{object}	ReviewDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		403		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Failure		409		{object}	common.APIError
+//	@Failure		429		{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/reviews [post]
+func (c controller) HandleReviewAdd(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req NewReviewDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Create(ctx, r.PathValue("brand"), r.PathValue("name"), req.toModel())
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	json, _ := json.Marshal(AsDTO(*resp))
+
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleReviewUpdate godoc
+//
+//	@Summary		Change own review
+//	@Tags			reviews
+//	@Accept			json
+//	@Produce		json
+//	@Param			brand	path		string			true	"brand of the switch"
+//	@Param			name	path		string			true	"name of the switch"
+//	@Param			id		path		int				true	"id of the review"
+//	@Param			review	body		NewReviewDTO	true	"scores from 1 to 5 and an optional text"
+//	@Success		200		{object}	ReviewDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		403		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Failure		429		{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/reviews/{id} [put]
+func (c controller) HandleReviewUpdate(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req NewReviewDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Update(ctx, r.PathValue("brand"), r.PathValue("name"), id, req.toModel())
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	json, _ := json.Marshal(AsDTO(*resp))
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleReviewRemove godoc
+//
+//	@Summary		Remove a review
+//	@Description	Authors can remove their own reviews, moderators any of them
+//	@Tags			reviews
+//	@Param			brand	path	string	true	"brand of the switch"
+//	@Param			name	path	string	true	"name of the switch"
+//	@Param			id		path	int		true	"id of the review"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		403	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/reviews/{id} [delete]
+func (c controller) HandleReviewRemove(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.service.Delete(ctx, r.PathValue("brand"), r.PathValue("name"), id); err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package submissions
+
+import (
+	"kbswitch/internal/core/submissions/models"
+	switchmodels "kbswitch/internal/core/switches/models"
+	"time"
+)
+
+type RevisionDTO struct {
+	Switch  switchmodels.SwitchRequestBody `json:"switch"`
+	Comment string                         `json:"comment"`
+}
+
+type CommentDTO struct {
+	Comment string `json:"comment"`
+}
+
+type SubmissionCommentDTO struct {
+	Author    string    `json:"author"`
+	Action    string    `json:"action"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type SubmissionDTO struct {
+	ID        int                            `json:"id"`
+	Status    string                         `json:"status" enums:"pending,changes_requested,approved,rejected"`
+	Submitter string                         `json:"submitter"`
+	Switch    switchmodels.SwitchRequestBody `json:"switch"`
+	// SwitchID is there once the submission is approved
+	SwitchID  *int                   `json:"switchId,omitempty"`
+	CreatedAt time.Time              `json:"createdAt"`
+	UpdatedAt time.Time              `json:"updatedAt"`
+	Comments  []SubmissionCommentDTO `json:"comments"`
+}
+
+func AsDTO(s models.Submission) SubmissionDTO {
+	comments := make([]SubmissionCommentDTO, len(s.Comments))
+	for i, c := range s.Comments {
+		comments[i] = SubmissionCommentDTO{
+			Author:    c.Author,
+			Action:    string(c.Action),
+			Comment:   c.Comment,
+			CreatedAt: c.CreatedAt,
+		}
+	}
+
+	return SubmissionDTO{
+		ID:        s.ID,
+		Status:    string(s.Status),
+		Submitter: s.SubmitterName,
+		Switch:    s.Body,
+		SwitchID:  s.SwitchID,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		Comments:  comments,
+	}
+}
+
+func AsDTOs(s []models.Submission) []SubmissionDTO {
+	res := make([]SubmissionDTO, len(s))
+	for i, sub := range s {
+		res[i] = AsDTO(sub)
+	}
+	return res
+}
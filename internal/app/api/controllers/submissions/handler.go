@@ -0,0 +1,241 @@
+package submissions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/submissions"
+	"kbswitch/internal/core/submissions/models"
+	switchmodels "kbswitch/internal/core/switches/models"
+	"net/http"
+	"strconv"
+)
+
+const maxBodySize = 1 << 16
+
+type controller struct {
+	service submissions.Service
+}
+
+func New(service submissions.Service) controller {
+	return controller{
+		service: service,
+	}
+}
+
+func writeErr(err string, status int, w http.ResponseWriter) {
+	e := common.APIError{
+		Status:  status,
+		Message: err,
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprint(w, e)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, dst any) bool {
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil && err != io.EOF {
+		writeErr("invalid request model", http.StatusBadRequest, w)
+		return false
+	}
+	return true
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErr("request parameter 'id' must be a number", http.StatusBadRequest, w)
+		return 0, false
+	}
+	return id, true
+}
+
+func writeSubmission(w http.ResponseWriter, status int, resp *models.Submission, err *common.AppError) {
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	json, _ := json.Marshal(AsDTO(*resp))
+
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+func writeSubmissions(w http.ResponseWriter, resp []models.Submission, err *common.AppError) {
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	json, _ := json.Marshal(AsDTOs(resp))
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleSubmit godoc
+//
+//	@Summary		Submit a new switch
+//	@Description	Proposes a switch for the catalog, it is published once a moderator approves it
+//	@Tags			submissions
+//	@Accept			json
+//	@Produce		json
+//	@Param			newswitch	body		models.SwitchRequestBody	true	"switch to propose"
+//	@Success		201			{object}	SubmissionDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		400			{object}	common.APIError
+//	@Failure		401			{object}	common.APIError
+//	@Failure		403			{object}	common.APIError
+//	@Failure		429			{object}	common.APIError
+//	@Router			/api/submissions [post]
+func (c controller) HandleSubmit(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req switchmodels.SwitchRequestBody
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Submit(ctx, req)
+	writeSubmission(w, http.StatusCreated, resp, err)
+}
+
+// HandleMySubmissions godoc
+//
+//	@Summary		List own submissions
+//	@Description	Submissions of the caller with their status, newest first
+//	@Tags			submissions
+//	@Produce		json
+//	@Success		200	{array}		SubmissionDTO
+//	@Failure		500	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Router			/api/submissions [get]
+func (c controller) HandleMySubmissions(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.Mine(ctx)
+	writeSubmissions(w, resp, err)
+}
+
+// HandleSubmission godoc
+//
+//	@Summary		Get a submission
+//	@Description	Status and review comments of a submission, visible to its submitter and to moderators
+//	@Tags			submissions
+//	@Produce		json
+//	@Param			id	path		int	true	"id of the submission"
+//	@Success		200	{object}	SubmissionDTO
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Router			/api/submissions/{id} [get]
+func (c controller) HandleSubmission(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := c.service.Get(ctx, id)
+	writeSubmission(w, http.StatusOK, resp, err)
+}
+
+// HandleRevise godoc
+//
+//	@Summary		Revise a submission
+//	@Description	Replaces the proposed switch after a moderator requested changes and puts the submission back into the queue
+//	@Tags			submissions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int			true	"id of the submission"
+//	@Param			revision	body		RevisionDTO	true	"revised switch with an optional comment"
+//	@Success		200			{object}	SubmissionDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		400			{object}	common.APIError
+//	@Failure		401			{object}	common.APIError
+//	@Failure		403			{object}	common.APIError
+//	@Failure		404			{object}	common.APIError
+//	@Failure		409			{object}	common.APIError
+//	@Failure		429			{object}	common.APIError
+//	@Router			/api/submissions/{id} [put]
+func (c controller) HandleRevise(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req RevisionDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Revise(ctx, id, req.Switch, req.Comment)
+	writeSubmission(w, http.StatusOK, resp, err)
+}
+
+// HandleQueue godoc
+//
+//	@Summary		List the moderation queue
+//	@Description	Pending submissions, oldest first
+//	@Tags			moderation
+//	@Produce		json
+//	@Success		200	{array}		SubmissionDTO
+//	@Failure		500	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		403	{object}	common.APIError
+//	@Router			/api/moderation/queue [get]
+func (c controller) HandleQueue(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.Queue(ctx)
+	writeSubmissions(w, resp, err)
+}
+
+// HandleReview godoc
+//
+//	@Summary		Decide on a submission
+//	@Description	Approving adds the switch to the catalog, rejecting and requesting changes need a comment for the submitter
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int			true	"id of the submission"
+//	@Param			action	path		string		true	"decision"	Enums(approve, reject, request-changes)
+//	@Param			comment	body		CommentDTO	false	"comment for the submitter"
+//	@Success		200		{object}	SubmissionDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		403		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Failure		409		{object}	common.APIError
+//	@Router			/api/moderation/queue/{id}/{action} [post]
+func (c controller) HandleReview(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var action models.Action
+	switch r.PathValue("action") {
+	case "approve":
+		action = models.ActionApprove
+	case "reject":
+		action = models.ActionReject
+	case "request-changes":
+		action = models.ActionRequestChanges
+	default:
+		writeErr("action must be one of approve, reject or request-changes", http.StatusNotFound, w)
+		return
+	}
+
+	var req CommentDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Review(ctx, id, models.Decision{Action: action, Comment: req.Comment})
+	writeSubmission(w, http.StatusOK, resp, err)
+}
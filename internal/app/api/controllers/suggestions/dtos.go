@@ -0,0 +1,87 @@
+package suggestions
+
+import (
+	"kbswitch/internal/core/suggestions/models"
+	"time"
+)
+
+type NewSuggestionDTO struct {
+	Changes   models.Patch `json:"changes"`
+	SourceURL string       `json:"sourceUrl"`
+}
+
+type AcceptDTO struct {
+	// Fields to apply, every proposed one when empty
+	Fields []string `json:"fields"`
+	// Force applies fields changed since the suggestion was made as well
+	Force   bool   `json:"force"`
+	Comment string `json:"comment"`
+}
+
+type CommentDTO struct {
+	Comment string `json:"comment"`
+}
+
+type FieldDiffDTO struct {
+	Field string `json:"field"`
+	// Base is the value the author saw
+	Base     any  `json:"base"`
+	Current  any  `json:"current"`
+	Proposed any  `json:"proposed"`
+	Conflict bool `json:"conflict"`
+}
+
+type SuggestionDTO struct {
+	ID             int            `json:"id"`
+	Brand          string         `json:"brand"`
+	Name           string         `json:"name"`
+	Changes        models.Patch   `json:"changes"`
+	SourceURL      string         `json:"sourceUrl"`
+	Author         string         `json:"author"`
+	Status         string         `json:"status" enums:"pending,accepted,partially_accepted,rejected"`
+	AcceptedFields []string       `json:"acceptedFields,omitempty"`
+	Reviewer       string         `json:"reviewer,omitempty"`
+	Comment        string         `json:"comment,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	ReviewedAt     *time.Time     `json:"reviewedAt,omitempty"`
+	Diff           []FieldDiffDTO `json:"diff,omitempty"`
+	Conflicting    bool           `json:"conflicting"`
+}
+
+func AsDTO(s models.Suggestion) SuggestionDTO {
+	var diff []FieldDiffDTO
+	for _, d := range s.Diff {
+		diff = append(diff, FieldDiffDTO{
+			Field:    d.Field,
+			Base:     d.Base,
+			Current:  d.Current,
+			Proposed: d.Proposed,
+			Conflict: d.Conflict,
+		})
+	}
+
+	return SuggestionDTO{
+		ID:             s.ID,
+		Brand:          s.Brand,
+		Name:           s.Name,
+		Changes:        s.Patch,
+		SourceURL:      s.SourceURL,
+		Author:         s.Author,
+		Status:         string(s.Status),
+		AcceptedFields: s.AcceptedFields,
+		Reviewer:       s.Reviewer,
+		Comment:        s.Comment,
+		CreatedAt:      s.CreatedAt,
+		ReviewedAt:     s.ReviewedAt,
+		Diff:           diff,
+		Conflicting:    s.Conflicting,
+	}
+}
+
+func AsDTOs(s []models.Suggestion) []SuggestionDTO {
+	res := make([]SuggestionDTO, len(s))
+	for i, sug := range s {
+		res[i] = AsDTO(sug)
+	}
+	return res
+}
@@ -0,0 +1,240 @@
+package suggestions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/suggestions"
+	"kbswitch/internal/core/suggestions/models"
+	"net/http"
+	"strconv"
+)
+
+const maxBodySize = 1 << 16
+
+type controller struct {
+	service suggestions.Service
+}
+
+func New(service suggestions.Service) controller {
+	return controller{
+		service: service,
+	}
+}
+
+func writeErr(err string, status int, w http.ResponseWriter) {
+	e := common.APIError{
+		Status:  status,
+		Message: err,
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprint(w, e)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, dst any) bool {
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil && err != io.EOF {
+		writeErr("invalid request model", http.StatusBadRequest, w)
+		return false
+	}
+	return true
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErr("request parameter 'id' must be a number", http.StatusBadRequest, w)
+		return 0, false
+	}
+	return id, true
+}
+
+func writeSuggestion(w http.ResponseWriter, status int, resp *models.Suggestion, err *common.AppError) {
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	json, _ := json.Marshal(AsDTO(*resp))
+
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+func writeSuggestions(w http.ResponseWriter, resp []models.Suggestion, err *common.AppError) {
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	json, _ := json.Marshal(AsDTOs(resp))
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleSuggest godoc
+//
+//	@Summary		Suggest an edit of a switch
+//	@Description	Proposes new values for some fields of a switch, backed by a source. Moderators review it before anything changes
+//	@Tags			suggestions
+//	@Accept			json
+//	@Produce		json
+//	@Param			brand		path		string				true	"brand of the switch"
+//	@Param			name		path		string				true	"name of the switch"
+//	@Param			suggestion	body		NewSuggestionDTO	true	"changed fields and where the values come from"
+//	@Success		201			{object}	SuggestionDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		400			{object}	common.APIError
+//	@Failure		401			{object}	common.APIError
+//	@Failure		403			{object}	common.APIError
+//	@Failure		404			{object}	common.APIError
+//	@Failure		429			{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/suggestions [post]
+func (c controller) HandleSuggest(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req NewSuggestionDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Suggest(ctx, r.PathValue("brand"), r.PathValue("name"), models.NewSuggestion{
+		Patch:     req.Changes,
+		SourceURL: req.SourceURL,
+	})
+	writeSuggestion(w, http.StatusCreated, resp, err)
+}
+
+// HandleSwitchSuggestions godoc
+//
+//	@Summary		List suggestions of a switch
+//	@Description	Every suggestion made for the switch, newest first. Accepted ones tell who contributed which values
+//	@Tags			suggestions
+//	@Produce		json
+//	@Param			brand	path		string	true	"brand of the switch"
+//	@Param			name	path		string	true	"name of the switch"
+//	@Success		200		{array}		SuggestionDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		403		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/suggestions [get]
+func (c controller) HandleSwitchSuggestions(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.ForSwitch(ctx, r.PathValue("brand"), r.PathValue("name"))
+	writeSuggestions(w, resp, err)
+}
+
+// HandleQueue godoc
+//
+//	@Summary		List pending suggestions
+//	@Description	Pending suggestions with a field level diff against the switch as it is now, oldest first.
+//	@Description	Fields changed since the suggestion was made are flagged as conflicts
+//	@Tags			moderation
+//	@Produce		json
+//	@Success		200	{array}		SuggestionDTO
+//	@Failure		500	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		403	{object}	common.APIError
+//	@Router			/api/moderation/suggestions [get]
+func (c controller) HandleQueue(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.Queue(ctx)
+	writeSuggestions(w, resp, err)
+}
+
+// HandleSuggestion godoc
+//
+//	@Summary		Get a suggestion
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			id	path		int	true	"id of the suggestion"
+//	@Success		200	{object}	SuggestionDTO
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		403	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Router			/api/moderation/suggestions/{id} [get]
+func (c controller) HandleSuggestion(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := c.service.Get(ctx, id)
+	writeSuggestion(w, http.StatusOK, resp, err)
+}
+
+// HandleAccept godoc
+//
+//	@Summary		Accept a suggestion
+//	@Description	Applies all or some of the suggested fields to the switch. Conflicting fields are refused unless forced
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int			true	"id of the suggestion"
+//	@Param			acceptance	body		AcceptDTO	false	"fields to apply, all by default"
+//	@Success		200			{object}	SuggestionDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		400			{object}	common.APIError
+//	@Failure		401			{object}	common.APIError
+//	@Failure		403			{object}	common.APIError
+//	@Failure		404			{object}	common.APIError
+//	@Failure		409			{object}	common.APIError
+//	@Router			/api/moderation/suggestions/{id}/accept [post]
+func (c controller) HandleAccept(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req AcceptDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Accept(ctx, id, models.Acceptance{
+		Fields:  req.Fields,
+		Force:   req.Force,
+		Comment: req.Comment,
+	})
+	writeSuggestion(w, http.StatusOK, resp, err)
+}
+
+// HandleReject godoc
+//
+//	@Summary		Reject a suggestion
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int			true	"id of the suggestion"
+//	@Param			comment	body		CommentDTO	false	"why it was rejected"
+//	@Success		200		{object}	SuggestionDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		403		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Failure		409		{object}	common.APIError
+//	@Router			/api/moderation/suggestions/{id}/reject [post]
+func (c controller) HandleReject(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req CommentDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Reject(ctx, id, req.Comment)
+	writeSuggestion(w, http.StatusOK, resp, err)
+}
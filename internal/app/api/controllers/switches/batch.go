@@ -0,0 +1,42 @@
+package switches
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kbswitch/internal/core/switches/models"
+	"strings"
+)
+
+// batch operations are small, this leaves plenty of room for images
+const maxBatchBodySize = 10 << 20
+
+func parseBatch(body io.Reader) ([]models.BatchOperation, error) {
+	var raw []BatchOperationDTO
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, errors.New("request body must be a json array of operations")
+	}
+
+	ops := make([]models.BatchOperation, len(raw))
+	for i, item := range raw {
+		ops[i] = models.BatchOperation{
+			Action: models.BatchAction(item.Op),
+			Body:   item.Switch,
+		}
+		if item.Target == "" {
+			continue
+		}
+
+		brand, name, ok := strings.Cut(item.Target, "/")
+		if !ok || brand == "" || name == "" {
+			return nil, fmt.Errorf("operation %d: target must be in form 'brand/name'", i)
+		}
+		ops[i].Brand, ops[i].Name = brand, name
+	}
+
+	return ops, nil
+}
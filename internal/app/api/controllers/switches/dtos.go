@@ -1,36 +1,88 @@
 package switches
 
 import (
-	"fmt"
+	"encoding/xml"
+	"kbswitch/internal/core/common"
 	"kbswitch/internal/core/switches/models"
-	"strconv"
+	"kbswitch/internal/pkg/units"
+	"math"
 )
 
 type SwitchDTO struct {
-	Brand            string `json:"brand"`
-	ActuationType    string `json:"actuationType"`
-	Lifespan         string `json:"lifespan"`
-	Name             string `json:"name"`
-	Image            string `json:"image"`
-	OperatingForce   string `json:"operatingForce"`
-	ActivationTravel string `json:"activationTravel"`
-	TotalTravel      string `json:"totalTravel"`
-	SoundProfile     string `json:"SoundProfile"`
-	Triggermethod    string `json:"triggermethod"`
-	Profile          string `json:"profile"`
-}
-
-func AsDTO(entity models.Switch) SwitchDTO {
-	lifespan := fmt.Sprintf("%dM", entity.Lifespan)
-	opforce := fmt.Sprintf("%dgf", entity.OperatingForce)
-	alltravel := strconv.FormatFloat(entity.TotalTravel, 'f', -1, 64) + "mm"
-	travel := strconv.FormatFloat(entity.ActivationTravel, 'f', -1, 64) + "mm"
-
-	return SwitchDTO{
-		ActivationTravel: travel,
-		OperatingForce:   opforce,
-		Lifespan:         lifespan,
-		TotalTravel:      alltravel,
+	XMLName          xml.Name `json:"-" yaml:"-" xml:"switch"`
+	Brand            string   `json:"brand" yaml:"brand" xml:"brand"`
+	ActuationType    string   `json:"actuationType" yaml:"actuationType" xml:"actuationType"`
+	Lifespan         string   `json:"lifespan" yaml:"lifespan" xml:"lifespan"`
+	Name             string   `json:"name" yaml:"name" xml:"name"`
+	Image            string   `json:"image" yaml:"image" xml:"image"`
+	OperatingForce   string   `json:"operatingForce" yaml:"operatingForce" xml:"operatingForce"`
+	ActivationTravel string   `json:"activationTravel" yaml:"activationTravel" xml:"activationTravel"`
+	TotalTravel      string   `json:"totalTravel" yaml:"totalTravel" xml:"totalTravel"`
+	SoundProfile     string   `json:"SoundProfile" yaml:"SoundProfile" xml:"SoundProfile"`
+	Triggermethod    string   `json:"triggermethod" yaml:"triggermethod" xml:"triggermethod"`
+	Profile          string   `json:"profile" yaml:"profile" xml:"profile"`
+	Description      string   `json:"description" yaml:"description" xml:"description"`
+	Canonical        string   `json:"canonical,omitempty" yaml:"canonical,omitempty" xml:"canonical,omitempty"`
+	// Values and Units are given only when raw numbers were asked for
+	Values *SwitchValuesDTO `json:"values,omitempty" yaml:"values,omitempty" xml:"values,omitempty"`
+	Units  *UnitsDTO        `json:"units,omitempty" yaml:"units,omitempty" xml:"units,omitempty"`
+	// Rating is given for reviewed switches only
+	Rating *RatingDTO `json:"rating,omitempty" yaml:"rating,omitempty" xml:"rating,omitempty"`
+}
+
+type RatingDTO struct {
+	Count int     `json:"count" yaml:"count" xml:"count"`
+	Mean  float64 `json:"mean" yaml:"mean" xml:"mean"`
+	// Histogram counts reviews per overall score, from 1 star to 5 stars
+	Histogram  []int   `json:"histogram" yaml:"histogram" xml:"histogram>stars"`
+	Smoothness float64 `json:"smoothness" yaml:"smoothness" xml:"smoothness"`
+	Sound      float64 `json:"sound" yaml:"sound" xml:"sound"`
+	Feel       float64 `json:"feel" yaml:"feel" xml:"feel"`
+}
+
+// AsRatingDTO gives nil for switches without reviews
+func AsRatingDTO(r *models.RatingSummary) *RatingDTO {
+	if r == nil || r.Count == 0 {
+		return nil
+	}
+
+	return &RatingDTO{
+		Count:      r.Count,
+		Mean:       round(r.Mean),
+		Histogram:  r.Histogram[:],
+		Smoothness: round(r.Smoothness),
+		Sound:      round(r.Sound),
+		Feel:       round(r.Feel),
+	}
+}
+
+type SwitchValuesDTO struct {
+	Lifespan         int     `json:"lifespan" yaml:"lifespan" xml:"lifespan"`
+	OperatingForce   float64 `json:"operatingForce" yaml:"operatingForce" xml:"operatingForce"`
+	ActivationTravel float64 `json:"activationTravel" yaml:"activationTravel" xml:"activationTravel"`
+	TotalTravel      float64 `json:"totalTravel" yaml:"totalTravel" xml:"totalTravel"`
+}
+
+type UnitsDTO struct {
+	Force    string `json:"force" yaml:"force" xml:"force" enums:"gf,cN,g,oz"`
+	Travel   string `json:"travel" yaml:"travel" xml:"travel" enums:"mm,in"`
+	Lifespan string `json:"lifespan" yaml:"lifespan" xml:"lifespan" enums:"M"`
+}
+
+func asUnitsDTO(opts units.Options) UnitsDTO {
+	return UnitsDTO{
+		Force:    string(opts.Force()),
+		Travel:   string(opts.Length()),
+		Lifespan: units.LifespanUnit,
+	}
+}
+
+func AsDTO(entity models.Switch, opts units.Options) SwitchDTO {
+	res := SwitchDTO{
+		ActivationTravel: opts.FormatLength(entity.ActivationTravel),
+		OperatingForce:   opts.FormatForce(float64(entity.OperatingForce)),
+		Lifespan:         units.FormatLifespan(entity.Lifespan),
+		TotalTravel:      opts.FormatLength(entity.TotalTravel),
 		Name:             entity.Name,
 		Image:            entity.Image,
 		Brand:            entity.Brand,
@@ -38,5 +90,366 @@ func AsDTO(entity models.Switch) SwitchDTO {
 		SoundProfile:     entity.SoundProfile,
 		Triggermethod:    entity.TriggerMethod,
 		ActuationType:    entity.ActuationType,
+		Description:      entity.Description,
+		Rating:           AsRatingDTO(entity.Rating),
 	}
+
+	if opts.Raw {
+		u := asUnitsDTO(opts)
+		res.Units = &u
+		res.Values = &SwitchValuesDTO{
+			Lifespan:         entity.Lifespan,
+			OperatingForce:   opts.ConvertForce(float64(entity.OperatingForce)),
+			ActivationTravel: opts.ConvertLength(entity.ActivationTravel),
+			TotalTravel:      opts.ConvertLength(entity.TotalTravel),
+		}
+	}
+
+	return res
+}
+
+type AttributeMatchDTO struct {
+	Attribute    string  `json:"attribute"`
+	Similarity   float64 `json:"similarity"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+}
+
+type SimilarSwitchDTO struct {
+	Switch  SwitchDTO           `json:"switch"`
+	Score   float64             `json:"score"`
+	Matches []AttributeMatchDTO `json:"matches"`
+}
+
+func round(x float64) float64 {
+	return math.Round(x*10000) / 10000
+}
+
+func AsSimilarDTO(entity models.SimilarSwitch, opts units.Options) SimilarSwitchDTO {
+	matches := make([]AttributeMatchDTO, len(entity.Matches))
+	for i, m := range entity.Matches {
+		matches[i] = AttributeMatchDTO{
+			Attribute:    m.Attribute,
+			Similarity:   round(m.Similarity),
+			Weight:       m.Weight,
+			Contribution: round(m.Contribution),
+		}
+	}
+
+	return SimilarSwitchDTO{
+		Switch:  AsDTO(entity.Switch, opts),
+		Score:   round(entity.Score),
+		Matches: matches,
+	}
+}
+
+type SearchResultDTO struct {
+	Switch     SwitchDTO         `json:"switch"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+func AsSearchResultDTO(entity models.SearchResult, opts units.Options) SearchResultDTO {
+	return SearchResultDTO{
+		Switch:     AsDTO(entity.Switch, opts),
+		Rank:       round(entity.Rank),
+		Highlights: entity.Highlights,
+	}
+}
+
+type AliasDTO struct {
+	Brand string `json:"brand"`
+	Name  string `json:"name"`
+}
+
+type FacetCountDTO struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type HistogramBucketDTO struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+type FacetsDTO struct {
+	Terms      map[string][]FacetCountDTO      `json:"terms"`
+	Histograms map[string][]HistogramBucketDTO `json:"histograms"`
+	Units      UnitsDTO                        `json:"units"`
+}
+
+// convertFunc picks conversion for values of an attribute, attributes without units are left as they are
+func convertFunc(attr string, opts units.Options) func(float64) float64 {
+	switch attr {
+	case models.AttrOperatingForce:
+		return opts.ConvertForce
+	case models.AttrActivationTravel, models.AttrTotalTravel:
+		return opts.ConvertLength
+	default:
+		return func(x float64) float64 { return x }
+	}
+}
+
+func AsFacetsDTO(entity models.Facets, opts units.Options) FacetsDTO {
+	res := FacetsDTO{
+		Terms:      make(map[string][]FacetCountDTO, len(entity.Terms)),
+		Histograms: make(map[string][]HistogramBucketDTO, len(entity.Histograms)),
+		Units:      asUnitsDTO(opts),
+	}
+
+	for attr, counts := range entity.Terms {
+		res.Terms[attr] = asFacetCountDTOs(counts)
+	}
+
+	for attr, buckets := range entity.Histograms {
+		convert := convertFunc(attr, opts)
+		dtos := make([]HistogramBucketDTO, len(buckets))
+		for i, b := range buckets {
+			dtos[i] = HistogramBucketDTO{From: round(convert(b.From)), To: round(convert(b.To)), Count: b.Count}
+		}
+		res.Histograms[attr] = dtos
+	}
+
+	return res
+}
+
+type DistributionDTO struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Avg    float64 `json:"avg"`
+	Median float64 `json:"median"`
+	P25    float64 `json:"p25"`
+	P75    float64 `json:"p75"`
+	P90    float64 `json:"p90"`
+}
+
+type ActuationStatsDTO struct {
+	ActuationType    string          `json:"actuationType"`
+	Count            int             `json:"count"`
+	OperatingForce   DistributionDTO `json:"operatingForce"`
+	TotalTravel      DistributionDTO `json:"totalTravel"`
+	ActivationTravel DistributionDTO `json:"activationTravel"`
+}
+
+type SpringWeightDTO struct {
+	OperatingForce float64 `json:"operatingForce"`
+	Count          int     `json:"count"`
+}
+
+type MonthCountDTO struct {
+	Month string `json:"month" example:"2024-09"`
+	Count int    `json:"count"`
+}
+
+type StatsDTO struct {
+	Total            int                 `json:"total"`
+	PerBrand         []FacetCountDTO     `json:"perBrand"`
+	PerProfile       []FacetCountDTO     `json:"perProfile"`
+	PerActuationType []ActuationStatsDTO `json:"perActuationType"`
+	SpringWeights    []SpringWeightDTO   `json:"springWeights"`
+	AddedPerMonth    []MonthCountDTO     `json:"addedPerMonth"`
+	Units            UnitsDTO            `json:"units"`
+}
+
+func asDistributionDTO(d models.Distribution, convert func(float64) float64) DistributionDTO {
+	return DistributionDTO{
+		Min:    round(convert(d.Min)),
+		Max:    round(convert(d.Max)),
+		Avg:    round(convert(d.Avg)),
+		Median: round(convert(d.Median)),
+		P25:    round(convert(d.P25)),
+		P75:    round(convert(d.P75)),
+		P90:    round(convert(d.P90)),
+	}
+}
+
+func asFacetCountDTOs(counts []models.FacetCount) []FacetCountDTO {
+	res := make([]FacetCountDTO, len(counts))
+	for i, c := range counts {
+		res[i] = FacetCountDTO{Value: c.Value, Count: c.Count}
+	}
+	return res
+}
+
+func AsStatsDTO(entity models.CatalogStats, opts units.Options) StatsDTO {
+	res := StatsDTO{
+		Total:            entity.Total,
+		PerBrand:         asFacetCountDTOs(entity.PerBrand),
+		PerProfile:       asFacetCountDTOs(entity.PerProfile),
+		PerActuationType: make([]ActuationStatsDTO, len(entity.PerActuationType)),
+		SpringWeights:    make([]SpringWeightDTO, len(entity.SpringWeights)),
+		AddedPerMonth:    make([]MonthCountDTO, len(entity.AddedPerMonth)),
+		Units:            asUnitsDTO(opts),
+	}
+
+	for i, s := range entity.PerActuationType {
+		res.PerActuationType[i] = ActuationStatsDTO{
+			ActuationType:    s.ActuationType,
+			Count:            s.Count,
+			OperatingForce:   asDistributionDTO(s.OperatingForce, opts.ConvertForce),
+			TotalTravel:      asDistributionDTO(s.TotalTravel, opts.ConvertLength),
+			ActivationTravel: asDistributionDTO(s.ActivationTravel, opts.ConvertLength),
+		}
+	}
+	for i, s := range entity.SpringWeights {
+		res.SpringWeights[i] = SpringWeightDTO{OperatingForce: opts.ConvertForce(float64(s.OperatingForce)), Count: s.Count}
+	}
+	for i, m := range entity.AddedPerMonth {
+		res.AddedPerMonth[i] = MonthCountDTO{Month: m.Month.Format("2006-01"), Count: m.Count}
+	}
+
+	return res
+}
+
+type ImportRowResultDTO struct {
+	Row     int      `json:"row"`
+	Brand   string   `json:"brand"`
+	Name    string   `json:"name"`
+	Status  string   `json:"status" enums:"created,updated,skipped,invalid,failed"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+type ImportReportDTO struct {
+	DryRun     bool                 `json:"dryRun"`
+	Atomic     bool                 `json:"atomic"`
+	RolledBack bool                 `json:"rolledBack"`
+	Summary    map[string]int       `json:"summary"`
+	Rows       []ImportRowResultDTO `json:"rows"`
+}
+
+func AsImportReportDTO(entity models.ImportReport) ImportReportDTO {
+	res := ImportReportDTO{
+		DryRun:     entity.DryRun,
+		Atomic:     entity.Atomic,
+		RolledBack: entity.RolledBack,
+		Summary:    map[string]int{},
+		Rows:       make([]ImportRowResultDTO, len(entity.Results)),
+	}
+
+	for i, r := range entity.Results {
+		res.Summary[string(r.Status)]++
+		res.Rows[i] = ImportRowResultDTO{
+			Row:     r.Row,
+			Brand:   r.Brand,
+			Name:    r.Name,
+			Status:  string(r.Status),
+			Reasons: r.Reasons,
+		}
+	}
+
+	return res
+}
+
+// ExportRecordDTO keeps numbers numeric, unlike SwitchDTO, so exported datasets need no parsing
+type ExportRecordDTO struct {
+	Brand            string  `json:"brand"`
+	Name             string  `json:"name"`
+	ActuationType    string  `json:"actuationType"`
+	Lifespan         int     `json:"lifespan"`
+	OperatingForce   float64 `json:"operatingForce"`
+	ActivationTravel float64 `json:"activationTravel"`
+	TotalTravel      float64 `json:"totalTravel"`
+	SoundProfile     string  `json:"soundProfile"`
+	TriggerMethod    string  `json:"triggerMethod"`
+	Profile          string  `json:"profile"`
+	Description      string  `json:"description"`
+}
+
+func AsExportRecordDTO(entity models.Switch, opts units.Options) ExportRecordDTO {
+	return ExportRecordDTO{
+		Brand:            entity.Brand,
+		Name:             entity.Name,
+		ActuationType:    entity.ActuationType,
+		Lifespan:         entity.Lifespan,
+		OperatingForce:   opts.ConvertForce(float64(entity.OperatingForce)),
+		ActivationTravel: opts.ConvertLength(entity.ActivationTravel),
+		TotalTravel:      opts.ConvertLength(entity.TotalTravel),
+		SoundProfile:     entity.SoundProfile,
+		TriggerMethod:    entity.TriggerMethod,
+		Profile:          entity.Profile,
+		Description:      entity.Description,
+	}
+}
+
+// BatchOperationDTO targets existing switch as "brand/name" on update and delete
+type BatchOperationDTO struct {
+	Op     string                    `json:"op" enums:"create,update,delete"`
+	Target string                    `json:"target,omitempty" example:"Cherry/MX Red"`
+	Switch *models.SwitchRequestBody `json:"switch,omitempty"`
+}
+
+type BatchOperationResultDTO struct {
+	Index  int              `json:"index"`
+	Op     string           `json:"op"`
+	Target string           `json:"target"`
+	Status string           `json:"status" enums:"created,updated,deleted,failed,skipped,rolledBack"`
+	ID     *int             `json:"id,omitempty"`
+	Switch *SwitchDTO       `json:"switch,omitempty"`
+	Error  *common.APIError `json:"error,omitempty"`
+}
+
+type BatchReportDTO struct {
+	Atomic     bool                      `json:"atomic"`
+	RolledBack bool                      `json:"rolledBack"`
+	Summary    map[string]int            `json:"summary"`
+	Operations []BatchOperationResultDTO `json:"operations"`
+}
+
+func AsBatchReportDTO(entity models.BatchReport, opts units.Options) BatchReportDTO {
+	res := BatchReportDTO{
+		Atomic:     entity.Atomic,
+		RolledBack: entity.RolledBack,
+		Summary:    map[string]int{},
+		Operations: make([]BatchOperationResultDTO, len(entity.Results)),
+	}
+
+	for i, r := range entity.Results {
+		res.Summary[string(r.Status)]++
+
+		op := BatchOperationResultDTO{
+			Index:  r.Index,
+			Op:     string(r.Action),
+			Target: r.Brand + "/" + r.Name,
+			Status: string(r.Status),
+			ID:     r.ID,
+		}
+		if r.Switch != nil {
+			dto := AsDTO(*r.Switch, opts)
+			op.Switch = &dto
+		}
+		if r.Err != nil {
+			e := common.ToAPIErr(*r.Err)
+			op.Error = &e
+		}
+		res.Operations[i] = op
+	}
+
+	return res
+}
+
+type SwitchKeyDTO struct {
+	Brand string `json:"brand" yaml:"brand" xml:"brand"`
+	Name  string `json:"name" yaml:"name" xml:"name"`
+}
+
+type LookupDTO struct {
+	XMLName xml.Name       `json:"-" yaml:"-" xml:"lookup"`
+	Found   []SwitchDTO    `json:"found" yaml:"found" xml:"found>switch"`
+	Missing []SwitchKeyDTO `json:"missing" yaml:"missing" xml:"missing>key"`
+}
+
+func AsLookupDTO(entity models.LookupResult, opts units.Options) LookupDTO {
+	res := LookupDTO{
+		Found:   make([]SwitchDTO, len(entity.Found)),
+		Missing: make([]SwitchKeyDTO, len(entity.Missing)),
+	}
+	for i, sw := range entity.Found {
+		res.Found[i] = AsDTO(sw, opts)
+	}
+	for i, k := range entity.Missing {
+		res.Missing[i] = SwitchKeyDTO{Brand: k.Brand, Name: k.Name}
+	}
+
+	return res
 }
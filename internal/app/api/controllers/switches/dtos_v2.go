@@ -0,0 +1,143 @@
+package switches
+
+import (
+	"encoding/xml"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+	"kbswitch/internal/pkg/units"
+)
+
+// SwitchV2DTO is v2 representation of a switch, numbers are given as numbers in units named next to them
+type SwitchV2DTO struct {
+	XMLName          xml.Name `json:"-" yaml:"-" xml:"switch"`
+	Brand            string   `json:"brand" yaml:"brand" xml:"brand"`
+	Name             string   `json:"name" yaml:"name" xml:"name"`
+	ActuationType    string   `json:"actuationType" yaml:"actuationType" xml:"actuationType"`
+	Lifespan         int      `json:"lifespan" yaml:"lifespan" xml:"lifespan"`
+	OperatingForce   float64  `json:"operatingForce" yaml:"operatingForce" xml:"operatingForce"`
+	ActivationTravel float64  `json:"activationTravel" yaml:"activationTravel" xml:"activationTravel"`
+	TotalTravel      float64  `json:"totalTravel" yaml:"totalTravel" xml:"totalTravel"`
+	SoundProfile     string   `json:"soundProfile" yaml:"soundProfile" xml:"soundProfile"`
+	TriggerMethod    string   `json:"triggerMethod" yaml:"triggerMethod" xml:"triggerMethod"`
+	Profile          string   `json:"profile" yaml:"profile" xml:"profile"`
+	Image            string   `json:"image" yaml:"image" xml:"image"`
+	Description      string   `json:"description" yaml:"description" xml:"description"`
+	Units            UnitsDTO `json:"units" yaml:"units" xml:"units"`
+	Canonical        string   `json:"canonical,omitempty" yaml:"canonical,omitempty" xml:"canonical,omitempty"`
+	// Rating is given for reviewed switches only
+	Rating *RatingDTO `json:"rating,omitempty" yaml:"rating,omitempty" xml:"rating,omitempty"`
+}
+
+func AsV2DTO(entity models.Switch, opts units.Options) SwitchV2DTO {
+	return SwitchV2DTO{
+		Brand:            entity.Brand,
+		Name:             entity.Name,
+		ActuationType:    entity.ActuationType,
+		Lifespan:         entity.Lifespan,
+		OperatingForce:   opts.ConvertForce(float64(entity.OperatingForce)),
+		ActivationTravel: opts.ConvertLength(entity.ActivationTravel),
+		TotalTravel:      opts.ConvertLength(entity.TotalTravel),
+		SoundProfile:     entity.SoundProfile,
+		TriggerMethod:    entity.TriggerMethod,
+		Profile:          entity.Profile,
+		Image:            entity.Image,
+		Description:      entity.Description,
+		Units:            asUnitsDTO(opts),
+		Rating:           AsRatingDTO(entity.Rating),
+	}
+}
+
+type SimilarSwitchV2DTO struct {
+	Switch  SwitchV2DTO         `json:"switch"`
+	Score   float64             `json:"score"`
+	Matches []AttributeMatchDTO `json:"matches"`
+}
+
+func AsSimilarV2DTO(entity models.SimilarSwitch, opts units.Options) SimilarSwitchV2DTO {
+	v1 := AsSimilarDTO(entity, opts)
+
+	return SimilarSwitchV2DTO{
+		Switch:  AsV2DTO(entity.Switch, opts),
+		Score:   v1.Score,
+		Matches: v1.Matches,
+	}
+}
+
+type SearchResultV2DTO struct {
+	Switch     SwitchV2DTO       `json:"switch"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+func AsSearchResultV2DTO(entity models.SearchResult, opts units.Options) SearchResultV2DTO {
+	return SearchResultV2DTO{
+		Switch:     AsV2DTO(entity.Switch, opts),
+		Rank:       round(entity.Rank),
+		Highlights: entity.Highlights,
+	}
+}
+
+type LookupV2DTO struct {
+	XMLName xml.Name       `json:"-" yaml:"-" xml:"lookup"`
+	Found   []SwitchV2DTO  `json:"found" yaml:"found" xml:"found>switch"`
+	Missing []SwitchKeyDTO `json:"missing" yaml:"missing" xml:"missing>key"`
+}
+
+func AsLookupV2DTO(entity models.LookupResult, opts units.Options) LookupV2DTO {
+	res := LookupV2DTO{
+		Found:   make([]SwitchV2DTO, len(entity.Found)),
+		Missing: make([]SwitchKeyDTO, len(entity.Missing)),
+	}
+	for i, sw := range entity.Found {
+		res.Found[i] = AsV2DTO(sw, opts)
+	}
+	for i, k := range entity.Missing {
+		res.Missing[i] = SwitchKeyDTO{Brand: k.Brand, Name: k.Name}
+	}
+
+	return res
+}
+
+type BatchOperationResultV2DTO struct {
+	Index  int              `json:"index"`
+	Op     string           `json:"op"`
+	Target string           `json:"target"`
+	Status string           `json:"status" enums:"created,updated,deleted,failed,skipped,rolledBack"`
+	ID     *int             `json:"id,omitempty"`
+	Switch *SwitchV2DTO     `json:"switch,omitempty"`
+	Error  *common.APIError `json:"error,omitempty"`
+}
+
+type BatchReportV2DTO struct {
+	Atomic     bool                        `json:"atomic"`
+	RolledBack bool                        `json:"rolledBack"`
+	Summary    map[string]int              `json:"summary"`
+	Operations []BatchOperationResultV2DTO `json:"operations"`
+}
+
+func AsBatchReportV2DTO(entity models.BatchReport, opts units.Options) BatchReportV2DTO {
+	v1 := AsBatchReportDTO(entity, opts)
+
+	res := BatchReportV2DTO{
+		Atomic:     v1.Atomic,
+		RolledBack: v1.RolledBack,
+		Summary:    v1.Summary,
+		Operations: make([]BatchOperationResultV2DTO, len(v1.Operations)),
+	}
+	for i, op := range v1.Operations {
+		res.Operations[i] = BatchOperationResultV2DTO{
+			Index:  op.Index,
+			Op:     op.Op,
+			Target: op.Target,
+			Status: op.Status,
+			ID:     op.ID,
+			Error:  op.Error,
+		}
+		if sw := entity.Results[i].Switch; sw != nil {
+			dto := AsV2DTO(*sw, opts)
+			res.Operations[i].Switch = &dto
+		}
+	}
+
+	return res
+}
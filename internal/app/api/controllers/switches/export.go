@@ -0,0 +1,115 @@
+package switches
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+type exportFormat struct {
+	contentType string
+	extension   string
+	new         func(io.Writer) exportEncoder
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv":    {"text/csv; charset=utf-8", "csv", newCSVEncoder},
+	"ndjson": {"application/x-ndjson", "ndjson", newNDJSONEncoder},
+	"json":   {"application/json; charset=utf-8", "json", newJSONEncoder},
+}
+
+// exportEncoder writes records one by one, begin is called once before the first record and end after the last one
+type exportEncoder interface {
+	begin() error
+	encode(ExportRecordDTO) error
+	end() error
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) exportEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) begin() error {
+	return e.w.Write([]string{
+		"brand", "name", "actuationType", "lifespan", "operatingForce", "activationTravel",
+		"totalTravel", "soundProfile", "triggerMethod", "profile", "description",
+	})
+}
+
+func (e *csvEncoder) encode(r ExportRecordDTO) error {
+	err := e.w.Write([]string{
+		r.Brand, r.Name, r.ActuationType,
+		strconv.Itoa(r.Lifespan), strconv.FormatFloat(r.OperatingForce, 'f', -1, 64),
+		strconv.FormatFloat(r.ActivationTravel, 'f', -1, 64),
+		strconv.FormatFloat(r.TotalTravel, 'f', -1, 64),
+		r.SoundProfile, r.TriggerMethod, r.Profile, r.Description,
+	})
+	if err != nil {
+		return err
+	}
+	// csv.Writer buffers internally, flushing keeps memory flat on large exports
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func newNDJSONEncoder(w io.Writer) exportEncoder {
+	return &ndjsonEncoder{enc: json.NewEncoder(w)}
+}
+
+func (e *ndjsonEncoder) begin() error {
+	return nil
+}
+
+func (e *ndjsonEncoder) encode(r ExportRecordDTO) error {
+	return e.enc.Encode(r)
+}
+
+func (e *ndjsonEncoder) end() error {
+	return nil
+}
+
+type jsonEncoder struct {
+	w     io.Writer
+	first bool
+}
+
+func newJSONEncoder(w io.Writer) exportEncoder {
+	return &jsonEncoder{w: w, first: true}
+}
+
+func (e *jsonEncoder) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonEncoder) encode(r ExportRecordDTO) error {
+	if !e.first {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.first = false
+
+	j, _ := json.Marshal(r)
+	_, err := e.w.Write(j)
+	return err
+}
+
+func (e *jsonEncoder) end() error {
+	_, err := io.WriteString(e.w, "]")
+	return err
+}
@@ -0,0 +1,55 @@
+package switches
+
+import (
+	"fmt"
+	"kbswitch/internal/core/switches/models"
+	"net/url"
+	"strconv"
+)
+
+// parseFilter reads list filters from query parameters:
+// brand, profile, triggerMethod, soundProfile, actuationType,
+// minOperatingForce, maxOperatingForce, minTotalTravel, maxTotalTravel
+func parseFilter(query url.Values) (models.SwitchFilter, error) {
+	filter := models.SwitchFilter{
+		Brand:         query.Get("brand"),
+		Profile:       query.Get("profile"),
+		TriggerMethod: query.Get("triggerMethod"),
+		SoundProfile:  query.Get("soundProfile"),
+		ActuationType: query.Get("actuationType"),
+	}
+
+	ints := map[string]**int{
+		"minOperatingForce": &filter.MinOperatingForce,
+		"maxOperatingForce": &filter.MaxOperatingForce,
+	}
+	for key, dest := range ints {
+		v := query.Get(key)
+		if v == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fmt.Errorf("query parameter '%s' must be an integer", key)
+		}
+		*dest = &parsed
+	}
+
+	floats := map[string]**float64{
+		"minTotalTravel": &filter.MinTotalTravel,
+		"maxTotalTravel": &filter.MaxTotalTravel,
+	}
+	for key, dest := range floats {
+		v := query.Get(key)
+		if v == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return filter, fmt.Errorf("query parameter '%s' must be a number", key)
+		}
+		*dest = &parsed
+	}
+
+	return filter, nil
+}
@@ -4,28 +4,37 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"kbswitch/internal/app/api/render"
 	"kbswitch/internal/core/common"
 	"kbswitch/internal/core/switches"
 	"kbswitch/internal/core/switches/models"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type controller struct {
-	service switches.Service
+	service  switches.Service
+	encoders *render.Registry
 }
 
 func New(service switches.Service) controller {
 	return controller{
-		service: service,
+		service:  service,
+		encoders: render.Default(),
 	}
 }
 
+// errors are always json, whatever representation of the resource was asked for
 func writeErr(err string, status int, w http.ResponseWriter) {
 	e := common.APIError{
 		Status:  status,
 		Message: err,
 	}
 
+	w.Header().Set("Content-Type", render.JSON{}.ContentType())
 	w.WriteHeader(status)
 	fmt.Fprint(w, e)
 }
@@ -33,18 +42,49 @@ func writeErr(err string, status int, w http.ResponseWriter) {
 // HandleSwitches godoc
 //
 //	@Summary		Get all switches
-//	@Description	Gives array of all keyboard switches
+//	@Description	Gives array of all keyboard switches, representation is chosen by Accept header.
+//	@Description	When ids are given responds with LookupDTO of just those switches instead, same as POST /api/switches/lookup
 //	@Tags			switches
 //	@Produce		json
-//	@Success		200	{array}		SwitchDTO
-//	@Failure		500	{object}	common.APIError
+//	@Produce		application/yaml
+//	@Produce		text/csv
+//	@Produce		application/xml
+//	@Param			ids				query		[]string	false	"comma separated brand/name pairs, aliases are resolved too"	collectionFormat(csv)
+//	@Param			units			query		string		false	"metric (default) or imperial"
+//	@Param			forceUnit		query		string		false	"gf, cN, g or oz, overrides force unit of the units system"
+//	@Param			raw				query		bool		false	"adds plain numbers with their units next to formatted values"
+//	@Param			Accept-Version	header		string		false	"1 (default, deprecated) or 2 with SwitchV2DTO, routes under /api/v1 and /api/v2 ignore it"
+//	@Success		200				{array}		SwitchDTO
+//	@Failure		500				{object}	common.APIError
+//	@Failure		400				{object}	common.APIError
+//	@Failure		406				{object}	common.APIError
 //	@Router			/api/switches [get]
 func (c controller) HandleSwitches(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if ids, ok := r.URL.Query()["ids"]; ok {
+		keys, err := parseIDs(ids)
+		if err != nil {
+			writeErr(err.Error(), http.StatusBadRequest, w)
+			return
+		}
+
+		c.lookup(ctx, w, r, keys)
+		return
+	}
+
+	unitOpts, ok := unitOptions(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := c.encoders.Negotiate(r.Header.Get("Accept")); !ok {
+		render.NotAcceptable(w, c.encoders.MediaTypes())
+		return
+	}
+
 	resp, err := c.service.GetAll(ctx)
 	if err != nil {
 		e := common.ToAPIErr(*err)
-		w.WriteHeader(e.Status)
-		fmt.Fprint(w, e.Error())
+		writeErr(e.Message, e.Status, w)
 		return
 	}
 	if resp == nil {
@@ -56,30 +96,43 @@ func (c controller) HandleSwitches(ctx context.Context, w http.ResponseWriter, r
 		return
 	}
 
-	dtos := make([]SwitchDTO, len(resp))
-	for i, item := range resp {
-		dtos[i] = AsDTO(item)
-	}
-
-	json, _ := json.Marshal(dtos)
-
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "%s", string(json[:]))
+	c.encoders.Render(w, r, http.StatusOK, presenterFor(ctx).switches(resp, unitOpts))
 }
 
 // HandleSingleSwitch godoc
 //
-//	@Summary		Get switch by ID
-//	@Description	Gives a single switch by database ID
-//	@Param			id	path	int	true	"Switch ID"
+//	@Summary		Get switch by brand and name
+//	@Description	Gives a single switch by its brand and name or by any registered alias of them.
+//	@Description	When an alias is used, response carries canonical link and redirect=true responds with 301 to canonical resource
+//	@Param			brand			path	string	true	"brand of the switch or of its alias"
+//	@Param			name			path	string	true	"name of the switch or of its alias"
+//	@Param			redirect		query	bool	false	"redirect to canonical resource when alias is used"
+//	@Param			units			query	string	false	"metric (default) or imperial"
+//	@Param			forceUnit		query	string	false	"gf, cN, g or oz, overrides force unit of the units system"
+//	@Param			raw				query	bool	false	"adds plain numbers with their units next to formatted values"
+//	@Param			Accept-Version	header	string	false	"1 (default, deprecated) or 2 with SwitchV2DTO, routes under /api/v1 and /api/v2 ignore it"
 //	@Tags			switches
 //	@Produce		json
+//	@Produce		application/yaml
+//	@Produce		text/csv
+//	@Produce		application/xml
 //	@Success		200	{object}	SwitchDTO
 //	@Failure		500	{object}	common.APIError
 //	@Failure		400	{object}	common.APIError
 //	@Failure		404	{object}	common.APIError
+//	@Failure		406	{object}	common.APIError
 //	@Router			/api/switches/{brand}/{name} [get]
 func (c controller) HandleSingleSwitch(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	unitOpts, ok := unitOptions(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := c.encoders.Negotiate(r.Header.Get("Accept")); !ok {
+		render.NotAcceptable(w, c.encoders.MediaTypes())
+		return
+	}
+
 	brand := r.PathValue("brand")
 	name := r.PathValue("name")
 	if brand == "" && name == "" {
@@ -101,8 +154,7 @@ func (c controller) HandleSingleSwitch(ctx context.Context, w http.ResponseWrite
 	resp, err := c.service.GetSingle(ctx, brand, name)
 	if err != nil {
 		e := common.ToAPIErr(*err)
-		w.WriteHeader(e.Status)
-		fmt.Fprint(w, e.Error())
+		writeErr(e.Message, e.Status, w)
 		return
 	}
 	if resp == nil {
@@ -110,11 +162,75 @@ func (c controller) HandleSingleSwitch(ctx context.Context, w http.ResponseWrite
 		return
 	}
 
-	dto := AsDTO(*resp)
-	json, _ := json.Marshal(dto)
+	p := presenterFor(ctx)
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "%s", string(json[:]))
+	// brand and name were resolved through an alias
+	canonical := ""
+	if !strings.EqualFold(brand, resp.Brand) || !strings.EqualFold(name, resp.Name) {
+		canonical = p.canonicalPath(resp.Brand, resp.Name)
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"canonical\"", canonical))
+
+		if redirect, _ := strconv.ParseBool(r.FormValue("redirect")); redirect {
+			w.Header().Set("Location", canonical)
+			w.WriteHeader(http.StatusMovedPermanently)
+			return
+		}
+	}
+
+	c.encoders.Render(w, r, http.StatusOK, p.single(*resp, canonical, unitOpts))
+}
+
+// HandleLookup godoc
+//
+//	@Summary		Get many switches at once
+//	@Description	Resolves brand and name pairs, or aliases of them, in a single query.
+//	@Description	Found switches keep order of the request, pairs resolving to nothing are listed as missing
+//	@Tags			switches
+//	@Accept			json
+//	@Produce		json
+//	@Produce		application/yaml
+//	@Produce		application/xml
+//	@Param			keys		body		[]SwitchKeyDTO	true	"brand and name pairs to look up"
+//	@Param			units		query		string			false	"metric (default) or imperial"
+//	@Param			forceUnit	query		string			false	"gf, cN, g or oz, overrides force unit of the units system"
+//	@Param			raw			query		bool			false	"adds plain numbers with their units next to formatted values"
+//	@Success		200			{object}	LookupDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		400			{object}	common.APIError
+//	@Failure		406			{object}	common.APIError
+//	@Router			/api/switches/lookup [post]
+func (c controller) HandleLookup(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, maxLookupBodySize)
+	defer body.Close()
+
+	keys, err := parseLookup(body)
+	if err != nil {
+		writeErr(err.Error(), http.StatusBadRequest, w)
+		return
+	}
+
+	c.lookup(ctx, w, r, keys)
+}
+
+func (c controller) lookup(ctx context.Context, w http.ResponseWriter, r *http.Request, keys []models.SwitchKey) {
+	unitOpts, ok := unitOptions(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := lookupEncoders.Negotiate(r.Header.Get("Accept")); !ok {
+		render.NotAcceptable(w, lookupEncoders.MediaTypes())
+		return
+	}
+
+	resp, err := c.service.Lookup(ctx, keys)
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	lookupEncoders.Render(w, r, http.StatusOK, presenterFor(ctx).lookup(*resp, unitOpts))
 }
 
 // RemoveSwitch godoc
@@ -124,12 +240,15 @@ func (c controller) HandleSingleSwitch(ctx context.Context, w http.ResponseWrite
 //	@Tags			switches
 //	@Accept			json
 //	@Produce		json
-//	@Param			brand	path	int	true	"brand of the switch to delete"
-//	@Param			name	path	int	true	"name of the switch to delete"
+//	@Param			brand		path	int		true	"brand of the switch to delete"
+//	@Param			name		path	int		true	"name of the switch to delete"
+//	@Param			X-API-Key	header	string	false	"api key with write scope, not needed within a session of a moderator"
 //	@Success		204
 //	@Failure		500	{object}	common.APIError
 //	@Failure		400	{object}	common.APIError
 //	@Failure		404	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		403	{object}	common.APIError
 //	@Router			/api/switches/{brand}/{name} [delete]
 func (c controller) HandleSwitchRemove(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	brand := r.PathValue("brand")
@@ -164,13 +283,24 @@ func (c controller) HandleSwitchRemove(ctx context.Context, w http.ResponseWrite
 //	@Tags			switches
 //	@Produce		json
 //	@Accept			json
-//	@Param			brand	path		int	true	"brand of the switch to update"
-//	@Param			name	path		int	true	"name of the switch to update"
-//	@Success		200		{object}	SwitchDTO
-//	@Failure		500		{object}	common.APIError
-//	@Failure		400		{object}	common.APIError
+//	@Param			brand		path		int		true	"brand of the switch to update"
+//	@Param			name		path		int		true	"name of the switch to update"
+//	@Param			units		query		string	false	"metric (default) or imperial"
+//	@Param			forceUnit	query		string	false	"gf, cN, g or oz, overrides force unit of the units system"
+//	@Param			raw			query		bool	false	"adds plain numbers with their units next to formatted values"
+//	@Param			X-API-Key	header		string	false	"api key with write scope, not needed within a session of a moderator"
+//	@Success		200			{object}	SwitchDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		400			{object}	common.APIError
+//	@Failure		401			{object}	common.APIError
+//	@Failure		403			{object}	common.APIError
 //	@Router			/api/switches/{brand}/{name} [patch]
 func (c controller) HandleSwitchUpdate(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	unitOpts, ok := unitOptions(w, r)
+	if !ok {
+		return
+	}
+
 	brand := r.PathValue("brand")
 	name := r.PathValue("name")
 	if brand == "" && name == "" {
@@ -212,7 +342,7 @@ func (c controller) HandleSwitchUpdate(ctx context.Context, w http.ResponseWrite
 		fmt.Fprint(w, e.Error())
 		return
 	}
-	j, _ := json.Marshal(AsDTO(*resp))
+	j, _ := json.Marshal(presenterFor(ctx).single(*resp, "", unitOpts))
 	result := string(j[:])
 
 	w.WriteHeader(http.StatusOK)
@@ -226,10 +356,16 @@ func (c controller) HandleSwitchUpdate(ctx context.Context, w http.ResponseWrite
 //	@Tags			switches
 //	@Produce		json
 //	@Accept			json
-//	@Param			newswitch	body		models.SwitchRequestBody	true	"Switch to add"
-//	@Success		200			{object}	string
-//	@Failure		500			{object}	common.APIError
-//	@Failure		400			{object}	common.APIError
+//	@Param			newswitch		body		models.SwitchRequestBody	true	"Switch to add"
+//	@Param			Idempotency-Key	header		string						false	"retries with the same key replay the first response"
+//	@Param			X-API-Key		header		string						false	"api key with write scope, not needed within a session of a moderator"
+//	@Success		200				{object}	string
+//	@Failure		500				{object}	common.APIError
+//	@Failure		400				{object}	common.APIError
+//	@Failure		409				{object}	common.APIError
+//	@Failure		422				{object}	common.APIError
+//	@Failure		401				{object}	common.APIError
+//	@Failure		403				{object}	common.APIError
 //	@Router			/api/switches [post]
 func (c controller) HandleSwitchAdd(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	var req models.SwitchRequestBody
@@ -254,3 +390,610 @@ func (c controller) HandleSwitchAdd(ctx context.Context, w http.ResponseWriter,
 	fmt.Fprintf(w, "%s%s/%d", r.Host, r.URL.Path, *id)
 
 }
+
+const (
+	defaultSimilarLimit = 10
+	maxSimilarLimit     = 50
+)
+
+// HandleSimilarSwitches godoc
+//
+//	@Summary		Get switches similar to the given one
+//	@Description	Ranks other switches by weighted distance over numeric specs and category matches.
+//	@Description	Every attribute name (operatingForce, activationTravel, totalTravel, lifespan,
+//	@Description	profile, triggerMethod, soundProfile, actuationType) can be passed as a query parameter to override its weight
+//	@Tags			switches
+//	@Produce		json
+//	@Param			brand		path		string	true	"brand of the switch"
+//	@Param			name		path		string	true	"name of the switch"
+//	@Param			limit		query		int		false	"max number of results, 10 by default"
+//	@Param			units		query		string	false	"metric (default) or imperial"
+//	@Param			forceUnit	query		string	false	"gf, cN, g or oz, overrides force unit of the units system"
+//	@Param			raw			query		bool	false	"adds plain numbers with their units next to formatted values"
+//	@Success		200			{array}		SimilarSwitchDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		400			{object}	common.APIError
+//	@Failure		404			{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/similar [get]
+func (c controller) HandleSimilarSwitches(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	unitOpts, ok := unitOptions(w, r)
+	if !ok {
+		return
+	}
+
+	brand := r.PathValue("brand")
+	name := r.PathValue("name")
+	if brand == "" || name == "" {
+		msg := "request parameters 'name' and 'brand' are missing"
+		if brand != "" {
+			msg = "request parameter 'name' is missing"
+		} else if name != "" {
+			msg = "request parameter 'brand' is missing"
+		}
+		writeErr(msg, http.StatusBadRequest, w)
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit := defaultSimilarLimit
+	if l := query.Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 || parsed > maxSimilarLimit {
+			writeErr(fmt.Sprintf("query parameter 'limit' must be a number between 1 and %d", maxSimilarLimit), http.StatusBadRequest, w)
+			return
+		}
+		limit = parsed
+	}
+
+	weights := models.DefaultSimilarityWeights()
+	for attr := range weights {
+		v := query.Get(attr)
+		if v == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			writeErr(fmt.Sprintf("weight '%s' must be a number", attr), http.StatusBadRequest, w)
+			return
+		}
+		weights[attr] = parsed
+	}
+
+	resp, err := c.service.GetSimilar(ctx, brand, name, limit, weights)
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		w.WriteHeader(e.Status)
+		fmt.Fprint(w, e.Error())
+		return
+	}
+
+	json, _ := json.Marshal(presenterFor(ctx).similar(resp, unitOpts))
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// HandleSearch godoc
+//
+//	@Summary		Full-text search over switches
+//	@Description	Searches switch names, brands and descriptions, results are ranked and matched terms are wrapped in <mark></mark> within otherwise html escaped text.
+//	@Description	Search can be narrowed down with the same filters as the switch list
+//	@Tags			switches
+//	@Produce		json
+//	@Param			q					query		string	true	"search query, supports quoted phrases, OR and -exclusion"
+//	@Param			limit				query		int		false	"max number of results, 20 by default"
+//	@Param			brand				query		string	false	"brand filter"
+//	@Param			profile				query		string	false	"profile filter"
+//	@Param			triggerMethod		query		string	false	"trigger method filter"
+//	@Param			soundProfile		query		string	false	"sound profile filter"
+//	@Param			actuationType		query		string	false	"actuation type filter"
+//	@Param			minOperatingForce	query		int		false	"min operating force in gf"
+//	@Param			maxOperatingForce	query		int		false	"max operating force in gf"
+//	@Param			minTotalTravel		query		number	false	"min total travel in mm"
+//	@Param			maxTotalTravel		query		number	false	"max total travel in mm"
+//	@Param			units				query		string	false	"metric (default) or imperial"
+//	@Param			forceUnit			query		string	false	"gf, cN, g or oz, overrides force unit of the units system"
+//	@Param			raw					query		bool	false	"adds plain numbers with their units next to formatted values"
+//	@Success		200					{array}		SearchResultDTO
+//	@Failure		500					{object}	common.APIError
+//	@Failure		400					{object}	common.APIError
+//	@Router			/api/search [get]
+func (c controller) HandleSearch(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	unitOpts, ok := unitOptions(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+
+	q := query.Get("q")
+	if q == "" {
+		writeErr("query parameter 'q' is missing", http.StatusBadRequest, w)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if l := query.Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 || parsed > maxSearchLimit {
+			writeErr(fmt.Sprintf("query parameter 'limit' must be a number between 1 and %d", maxSearchLimit), http.StatusBadRequest, w)
+			return
+		}
+		limit = parsed
+	}
+
+	filter, err := parseFilter(query)
+	if err != nil {
+		writeErr(err.Error(), http.StatusBadRequest, w)
+		return
+	}
+
+	resp, e := c.service.Search(ctx, q, filter, limit)
+	if e != nil {
+		e := common.ToAPIErr(*e)
+		w.WriteHeader(e.Status)
+		fmt.Fprint(w, e.Error())
+		return
+	}
+
+	json, _ := json.Marshal(presenterFor(ctx).search(resp, unitOpts))
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleAliases godoc
+//
+//	@Summary		Get aliases of a switch
+//	@Description	Gives every alternative brand/name registered for the switch
+//	@Tags			switches
+//	@Produce		json
+//	@Param			brand	path		string	true	"brand of the switch"
+//	@Param			name	path		string	true	"name of the switch"
+//	@Success		200		{array}		AliasDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/aliases [get]
+func (c controller) HandleAliases(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	brand := r.PathValue("brand")
+	name := r.PathValue("name")
+
+	resp, err := c.service.GetAliases(ctx, brand, name)
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		w.WriteHeader(e.Status)
+		fmt.Fprint(w, e.Error())
+		return
+	}
+
+	dtos := make([]AliasDTO, len(resp))
+	for i, a := range resp {
+		dtos[i] = AliasDTO{Brand: a.Brand, Name: a.Name}
+	}
+
+	json, _ := json.Marshal(dtos)
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleAliasAdd godoc
+//
+//	@Summary		Register an alias for a switch
+//	@Description	After registration switch can be fetched and searched by the alias
+//	@Tags			switches
+//	@Accept			json
+//	@Param			brand			path	string					true	"brand of the switch"
+//	@Param			name			path	string					true	"name of the switch"
+//	@Param			alias			body	models.AliasRequestBody	true	"alias to add"
+//	@Param			Idempotency-Key	header	string					false	"retries with the same key replay the first response"
+//	@Param			X-API-Key		header	string					false	"api key with write scope, not needed within a session of a moderator"
+//	@Success		201
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Failure		409	{object}	common.APIError
+//	@Failure		422	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		403	{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/aliases [post]
+func (c controller) HandleAliasAdd(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	brand := r.PathValue("brand")
+	name := r.PathValue("name")
+
+	var req models.AliasRequestBody
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&req)
+	if err != nil {
+		writeErr("invalid request model", http.StatusBadRequest, w)
+		return
+	}
+
+	e := c.service.AddAlias(ctx, brand, name, req)
+	if e != nil {
+		e := common.ToAPIErr(*e)
+		w.WriteHeader(e.Status)
+		fmt.Fprint(w, e.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "")
+}
+
+// HandleAliasRemove godoc
+//
+//	@Summary		Remove an alias of a switch
+//	@Tags			switches
+//	@Param			brand		path	string	true	"brand of the switch"
+//	@Param			name		path	string	true	"name of the switch"
+//	@Param			aliasBrand	path	string	true	"brand of the alias"
+//	@Param			aliasName	path	string	true	"name of the alias"
+//	@Param			X-API-Key	header	string	false	"api key with write scope, not needed within a session of a moderator"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		404	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		403	{object}	common.APIError
+//	@Router			/api/switches/{brand}/{name}/aliases/{aliasBrand}/{aliasName} [delete]
+func (c controller) HandleAliasRemove(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	brand := r.PathValue("brand")
+	name := r.PathValue("name")
+	alias := models.Alias{
+		Brand: r.PathValue("aliasBrand"),
+		Name:  r.PathValue("aliasName"),
+	}
+
+	err := c.service.RemoveAlias(ctx, brand, name, alias)
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		w.WriteHeader(e.Status)
+		fmt.Fprint(w, e.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	fmt.Fprintf(w, "")
+}
+
+// HandleFacets godoc
+//
+//	@Summary		Get facet counts for the catalog filter sidebar
+//	@Description	Counts switches per profile, triggerMethod, soundProfile, actuationType and brand,
+//	@Description	and gives histogram buckets of operatingForce, totalTravel and activationTravel.
+//	@Description	Counts respect applied filters, except the filter on the counted attribute itself
+//	@Tags			switches
+//	@Produce		json
+//	@Param			brand				query		string	false	"brand filter"
+//	@Param			profile				query		string	false	"profile filter"
+//	@Param			triggerMethod		query		string	false	"trigger method filter"
+//	@Param			soundProfile		query		string	false	"sound profile filter"
+//	@Param			actuationType		query		string	false	"actuation type filter"
+//	@Param			minOperatingForce	query		int		false	"min operating force in gf"
+//	@Param			maxOperatingForce	query		int		false	"max operating force in gf"
+//	@Param			minTotalTravel		query		number	false	"min total travel in mm"
+//	@Param			maxTotalTravel		query		number	false	"max total travel in mm"
+//	@Param			units				query		string	false	"metric (default) or imperial"
+//	@Param			forceUnit			query		string	false	"gf, cN, g or oz, overrides force unit of the units system"
+//	@Success		200					{object}	FacetsDTO
+//	@Failure		500					{object}	common.APIError
+//	@Failure		400					{object}	common.APIError
+//	@Router			/api/switches/facets [get]
+func (c controller) HandleFacets(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	unitOpts, ok := unitOptions(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := parseFilter(r.URL.Query())
+	if err != nil {
+		writeErr(err.Error(), http.StatusBadRequest, w)
+		return
+	}
+
+	resp, e := c.service.GetFacets(ctx, filter)
+	if e != nil {
+		e := common.ToAPIErr(*e)
+		w.WriteHeader(e.Status)
+		fmt.Fprint(w, e.Error())
+		return
+	}
+
+	json, _ := json.Marshal(AsFacetsDTO(*resp, unitOpts))
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleStats godoc
+//
+//	@Summary		Get catalog statistics
+//	@Description	Aggregates over the whole catalog: counts per brand and profile, operating force and travel
+//	@Description	distributions per actuation type, most common spring weights and entries added per month
+//	@Tags			switches
+//	@Produce		json
+//	@Param			units		query		string	false	"metric (default) or imperial"
+//	@Param			forceUnit	query		string	false	"gf, cN, g or oz, overrides force unit of the units system"
+//	@Success		200			{object}	StatsDTO
+//	@Failure		500			{object}	common.APIError
+//	@Router			/api/stats [get]
+func (c controller) HandleStats(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	unitOpts, ok := unitOptions(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := c.service.GetStats(ctx)
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		w.WriteHeader(e.Status)
+		fmt.Fprint(w, e.Error())
+		return
+	}
+
+	json, _ := json.Marshal(AsStatsDTO(*resp, unitOpts))
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+const maxImportBodySize = 10 << 20
+
+// HandleImport godoc
+//
+//	@Summary		Bulk import switches
+//	@Description	Accepts csv with a header of switch field names or a json array of switches.
+//	@Description	Every row is validated the same way single switch is and reported as created, updated, skipped, invalid or failed.
+//	@Description	In atomic mode nothing is stored unless every row succeeds
+//	@Tags			switches
+//	@Accept			json
+//	@Accept			text/csv
+//	@Produce		json
+//	@Param			switches		body		[]models.SwitchRequestBody	true	"switches to import"
+//	@Param			dryRun			query		bool						false	"validate and report without storing anything"
+//	@Param			mode			query		string						false	"bestEffort (default) or atomic"
+//	@Param			onDuplicate		query		string						false	"skip (default) or update existing switches"
+//	@Param			Idempotency-Key	header		string						false	"retries with the same key replay the first response"
+//	@Param			X-API-Key		header		string						false	"api key with write scope, not needed within a session of a moderator"
+//	@Success		200				{object}	ImportReportDTO
+//	@Failure		422				{object}	ImportReportDTO
+//	@Failure		500				{object}	common.APIError
+//	@Failure		400				{object}	common.APIError
+//	@Failure		409				{object}	common.APIError
+//	@Failure		401				{object}	common.APIError
+//	@Failure		403				{object}	common.APIError
+//	@Router			/api/switches/import [post]
+func (c controller) HandleImport(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var opts models.ImportOptions
+	if v := query.Get("dryRun"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			writeErr("query parameter 'dryRun' must be a boolean", http.StatusBadRequest, w)
+			return
+		}
+		opts.DryRun = dryRun
+	}
+
+	switch query.Get("mode") {
+	case "", "bestEffort":
+	case "atomic":
+		opts.Atomic = true
+	default:
+		writeErr("query parameter 'mode' must be either 'bestEffort' or 'atomic'", http.StatusBadRequest, w)
+		return
+	}
+
+	switch query.Get("onDuplicate") {
+	case "", "skip":
+	case "update":
+		opts.UpdateExisting = true
+	default:
+		writeErr("query parameter 'onDuplicate' must be either 'skip' or 'update'", http.StatusBadRequest, w)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBodySize)
+	defer body.Close()
+
+	rows, err := parseImport(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		writeErr(err.Error(), http.StatusBadRequest, w)
+		return
+	}
+
+	resp, e := c.service.Import(ctx, rows, opts)
+	if e != nil {
+		e := common.ToAPIErr(*e)
+		w.WriteHeader(e.Status)
+		fmt.Fprint(w, e.Error())
+		return
+	}
+
+	json, _ := json.Marshal(AsImportReportDTO(*resp))
+
+	status := http.StatusOK
+	if resp.RolledBack {
+		status = http.StatusUnprocessableEntity
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleBatch godoc
+//
+//	@Summary		Run several switch mutations at once
+//	@Description	Runs ordered create, update and delete operations, update and delete target existing switch as "brand/name".
+//	@Description	In atomic mode operations share a single transaction which is rolled back at the first failure,
+//	@Description	in best effort mode every operation stands on its own. Outcome of every operation is reported
+//	@Tags			switches
+//	@Accept			json
+//	@Produce		json
+//	@Param			operations		body		[]BatchOperationDTO	true	"operations to run in order"
+//	@Param			mode			query		string				false	"bestEffort (default) or atomic"
+//	@Param			Idempotency-Key	header		string				false	"retries with the same key replay the first response"
+//	@Param			units			query		string				false	"metric (default) or imperial"
+//	@Param			forceUnit		query		string				false	"gf, cN, g or oz, overrides force unit of the units system"
+//	@Param			raw				query		bool				false	"adds plain numbers with their units next to formatted values"
+//	@Param			X-API-Key		header		string				false	"api key with write scope, not needed within a session of a moderator"
+//	@Success		200				{object}	BatchReportDTO
+//	@Failure		422				{object}	BatchReportDTO
+//	@Failure		500				{object}	common.APIError
+//	@Failure		400				{object}	common.APIError
+//	@Failure		409				{object}	common.APIError
+//	@Failure		401				{object}	common.APIError
+//	@Failure		403				{object}	common.APIError
+//	@Router			/api/switches/batch [post]
+func (c controller) HandleBatch(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	unitOpts, ok := unitOptions(w, r)
+	if !ok {
+		return
+	}
+
+	var opts models.BatchOptions
+	switch r.URL.Query().Get("mode") {
+	case "", "bestEffort":
+	case "atomic":
+		opts.Atomic = true
+	default:
+		writeErr("query parameter 'mode' must be either 'bestEffort' or 'atomic'", http.StatusBadRequest, w)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxBatchBodySize)
+	defer body.Close()
+
+	ops, err := parseBatch(body)
+	if err != nil {
+		writeErr(err.Error(), http.StatusBadRequest, w)
+		return
+	}
+
+	resp, e := c.service.Batch(ctx, ops, opts)
+	if e != nil {
+		e := common.ToAPIErr(*e)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	json, _ := json.Marshal(presenterFor(ctx).batch(*resp, unitOpts))
+
+	status := http.StatusOK
+	if resp.RolledBack {
+		status = http.StatusUnprocessableEntity
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// flush response every exportFlushEvery records so client receives data while export is running
+const exportFlushEvery = 100
+
+// HandleExport godoc
+//
+//	@Summary		Export switches catalog
+//	@Description	Streams switches matching the list filters as csv, ndjson or json array straight from the database.
+//	@Description	Numeric values are exported as plain numbers
+//	@Tags			switches
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Produce		json
+//	@Param			format				query		string	false	"csv (default), ndjson or json"
+//	@Param			brand				query		string	false	"brand filter"
+//	@Param			profile				query		string	false	"profile filter"
+//	@Param			triggerMethod		query		string	false	"trigger method filter"
+//	@Param			soundProfile		query		string	false	"sound profile filter"
+//	@Param			actuationType		query		string	false	"actuation type filter"
+//	@Param			minOperatingForce	query		int		false	"min operating force in gf"
+//	@Param			maxOperatingForce	query		int		false	"max operating force in gf"
+//	@Param			minTotalTravel		query		number	false	"min total travel in mm"
+//	@Param			maxTotalTravel		query		number	false	"max total travel in mm"
+//	@Param			units				query		string	false	"metric (default) or imperial"
+//	@Param			forceUnit			query		string	false	"gf, cN, g or oz, overrides force unit of the units system"
+//	@Success		200					{array}		ExportRecordDTO
+//	@Failure		500					{object}	common.APIError
+//	@Failure		400					{object}	common.APIError
+//	@Router			/api/switches/export [get]
+func (c controller) HandleExport(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	unitOpts, ok := unitOptions(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+
+	name := query.Get("format")
+	if name == "" {
+		name = "csv"
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		writeErr("query parameter 'format' must be one of csv, ndjson or json", http.StatusBadRequest, w)
+		return
+	}
+
+	filter, err := parseFilter(query)
+	if err != nil {
+		writeErr(err.Error(), http.StatusBadRequest, w)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	enc := format.new(w)
+	started := false
+	count := 0
+
+	// headers are sent with the first record, so a failing query can still be answered with an error status
+	start := func() error {
+		started = true
+		filename := fmt.Sprintf("switches-%s.%s", time.Now().Format(time.DateOnly), format.extension)
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		w.WriteHeader(http.StatusOK)
+		return enc.begin()
+	}
+
+	e := c.service.Export(ctx, filter, func(sw models.Switch) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := enc.encode(AsExportRecordDTO(sw, unitOpts)); err != nil {
+			return err
+		}
+
+		count++
+		if count%exportFlushEvery == 0 {
+			rc.Flush()
+		}
+		return nil
+	})
+	if e != nil {
+		if !started {
+			e := common.ToAPIErr(*e)
+			writeErr(e.Message, e.Status, w)
+		}
+		// otherwise response is already on its way, truncated body is all we can do
+		return
+	}
+
+	if !started {
+		if err := start(); err != nil {
+			return
+		}
+	}
+	enc.end()
+	rc.Flush()
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"kbswitch/internal/app/api/controllers/switches"
+	"kbswitch/internal/app/api/middlewares"
+	"kbswitch/internal/app/api/versioning"
 	"kbswitch/internal/core/common"
 	"kbswitch/internal/core/switches/models"
+	"kbswitch/internal/pkg/units"
 	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func intptr(x int) *int {
@@ -18,11 +24,15 @@ func intptr(x int) *int {
 type fakeWriter struct {
 	input        string
 	headerStatus int
+	header       http.Header
 }
 
 // Header implements http.ResponseWriter.
-func (w fakeWriter) Header() http.Header {
-	panic("unimplemented")
+func (w *fakeWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
 }
 
 func (w *fakeWriter) Write(p []byte) (int, error) {
@@ -40,6 +50,61 @@ type fakeService struct {
 	addSwitchAction    func(reqbody models.SwitchRequestBody) (*int, *common.AppError)
 	deleteSwitchAction func(string, string) *common.AppError
 	updateSwitchAction func(string, string, models.SwitchRequestBody) (*models.Switch, *common.AppError)
+	similarReturner    func(string, string, int, models.SimilarityWeights) ([]models.SimilarSwitch, *common.AppError)
+	searchReturner     func(string, models.SwitchFilter, int) ([]models.SearchResult, *common.AppError)
+	aliasesReturner    func(string, string) ([]models.Alias, *common.AppError)
+	addAliasAction     func(string, string, models.AliasRequestBody) *common.AppError
+	removeAliasAction  func(string, string, models.Alias) *common.AppError
+	facetsReturner     func(models.SwitchFilter) (*models.Facets, *common.AppError)
+	statsReturner      func() (*models.CatalogStats, *common.AppError)
+	importAction       func([]models.ImportRow, models.ImportOptions) (*models.ImportReport, *common.AppError)
+	exportAction       func(context.Context, models.SwitchFilter, func(models.Switch) error) *common.AppError
+	batchAction        func([]models.BatchOperation, models.BatchOptions) (*models.BatchReport, *common.AppError)
+	lookupReturner     func([]models.SwitchKey) (*models.LookupResult, *common.AppError)
+}
+
+func (f fakeService) Lookup(ctx context.Context, keys []models.SwitchKey) (*models.LookupResult, *common.AppError) {
+	return f.lookupReturner(keys)
+}
+
+func (f fakeService) Batch(ctx context.Context, ops []models.BatchOperation, opts models.BatchOptions) (*models.BatchReport, *common.AppError) {
+	return f.batchAction(ops, opts)
+}
+
+func (f fakeService) Export(ctx context.Context, filter models.SwitchFilter, fn func(models.Switch) error) *common.AppError {
+	return f.exportAction(ctx, filter, fn)
+}
+
+func (f fakeService) Import(ctx context.Context, rows []models.ImportRow, opts models.ImportOptions) (*models.ImportReport, *common.AppError) {
+	return f.importAction(rows, opts)
+}
+
+func (f fakeService) GetStats(ctx context.Context) (*models.CatalogStats, *common.AppError) {
+	return f.statsReturner()
+}
+
+func (f fakeService) GetFacets(ctx context.Context, filter models.SwitchFilter) (*models.Facets, *common.AppError) {
+	return f.facetsReturner(filter)
+}
+
+func (f fakeService) GetAliases(ctx context.Context, brand, name string) ([]models.Alias, *common.AppError) {
+	return f.aliasesReturner(brand, name)
+}
+
+func (f fakeService) AddAlias(ctx context.Context, brand, name string, alias models.AliasRequestBody) *common.AppError {
+	return f.addAliasAction(brand, name, alias)
+}
+
+func (f fakeService) RemoveAlias(ctx context.Context, brand, name string, alias models.Alias) *common.AppError {
+	return f.removeAliasAction(brand, name, alias)
+}
+
+func (f fakeService) Search(ctx context.Context, query string, filter models.SwitchFilter, limit int) ([]models.SearchResult, *common.AppError) {
+	return f.searchReturner(query, filter, limit)
+}
+
+func (f fakeService) GetSimilar(ctx context.Context, brand, name string, limit int, weights models.SimilarityWeights) ([]models.SimilarSwitch, *common.AppError) {
+	return f.similarReturner(brand, name, limit, weights)
 }
 
 func (f fakeService) Update(ctx context.Context, brand, name string, m models.SwitchRequestBody) (*models.Switch, *common.AppError) {
@@ -251,7 +316,7 @@ func TestHandleSwitchUpdate(t *testing.T) {
 					resp := models.Switch{
 						Name: "test",
 					}
-					dto := switches.AsDTO(resp)
+					dto := switches.AsDTO(resp, units.Options{})
 					j, _ := json.Marshal(dto)
 
 					return string(j[:])
@@ -375,7 +440,7 @@ func TestHandleSwitches(t *testing.T) {
 
 	for _, tc := range tcases {
 		handler := switches.New(tc.service)
-		handler.HandleSwitches(context.Background(), tc.w, nil)
+		handler.HandleSwitches(context.Background(), tc.w, httptest.NewRequest(http.MethodGet, "/api/switches", nil))
 		if tc.expected.data != tc.w.input {
 			t.Errorf("HandleSwitches failed\nexpected %v\ngot %s", tc.expected.data, tc.w.input)
 		}
@@ -386,6 +451,106 @@ func TestHandleSwitches(t *testing.T) {
 	}
 }
 
+func TestHandleSwitchesNegotiation(t *testing.T) {
+	service := fakeService{
+		pluralReturner: func() ([]models.Switch, *common.AppError) {
+			return []models.Switch{
+				{Brand: "Cherry", Name: "MX Red", Lifespan: 100, OperatingForce: 45, TotalTravel: 4},
+			}, nil
+		},
+	}
+
+	tcases := []struct {
+		accept   string
+		expected struct {
+			headerStatus int
+			contentType  string
+			data         string
+		}
+	}{
+		{
+			accept: "",
+			expected: struct {
+				headerStatus int
+				contentType  string
+				data         string
+			}{
+				headerStatus: http.StatusOK,
+				contentType:  "application/json;charset=utf8",
+				data:         `[{"brand":"Cherry"`,
+			},
+		},
+		{
+			accept: "application/yaml",
+			expected: struct {
+				headerStatus int
+				contentType  string
+				data         string
+			}{
+				headerStatus: http.StatusOK,
+				contentType:  "application/yaml; charset=utf-8",
+				data:         "- brand: Cherry\n",
+			},
+		},
+		{
+			accept: "text/html;q=0.9, text/csv",
+			expected: struct {
+				headerStatus int
+				contentType  string
+				data         string
+			}{
+				headerStatus: http.StatusOK,
+				contentType:  "text/csv; charset=utf-8",
+				data:         "brand,actuationType,lifespan,name,image,operatingForce,activationTravel,totalTravel,SoundProfile,triggermethod,profile,description\nCherry,,100M,MX Red,,45gf,0mm,4mm,,,,\n",
+			},
+		},
+		{
+			accept: "application/xml",
+			expected: struct {
+				headerStatus int
+				contentType  string
+				data         string
+			}{
+				headerStatus: http.StatusOK,
+				contentType:  "application/xml; charset=utf-8",
+				data:         "<items><switch><brand>Cherry</brand>",
+			},
+		},
+		{
+			accept: "text/html",
+			expected: struct {
+				headerStatus int
+				contentType  string
+				data         string
+			}{
+				headerStatus: http.StatusNotAcceptable,
+				contentType:  "application/json;charset=utf8",
+				data:         `{"status":406,`,
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/switches", nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+
+		switches.New(service).HandleSwitches(context.Background(), w, r)
+
+		if tc.expected.headerStatus != w.Code {
+			t.Errorf("HandleSwitches with Accept %q status failed\nexpected %v\ngot  %v", tc.accept, tc.expected.headerStatus, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); tc.expected.contentType != got {
+			t.Errorf("HandleSwitches with Accept %q content type failed\nexpected %v\ngot  %v", tc.accept, tc.expected.contentType, got)
+		}
+		if !strings.Contains(w.Body.String(), tc.expected.data) {
+			t.Errorf("HandleSwitches with Accept %q failed\nexpected to contain %v\ngot %s", tc.accept, tc.expected.data, w.Body.String())
+		}
+	}
+}
+
 func TestHandleSingleSwitch(t *testing.T) {
 	tcases := []struct {
 		service  fakeService
@@ -501,6 +666,8 @@ func TestHandleSingleSwitch(t *testing.T) {
 		{
 			service: fakeService{singleReturner: func(brand, name string) (*models.Switch, *common.AppError) {
 				return &models.Switch{
+					Brand:            brand,
+					Name:             name,
 					Lifespan:         100,
 					OperatingForce:   50,
 					ActivationTravel: 1.9,
@@ -521,6 +688,8 @@ func TestHandleSingleSwitch(t *testing.T) {
 			}{
 				data: func() string {
 					dto := switches.SwitchDTO{
+						Brand:            "tst",
+						Name:             "tst",
 						Lifespan:         "100M",
 						OperatingForce:   "50gf",
 						ActivationTravel: "1.9mm",
@@ -791,3 +960,907 @@ func TestHandleSwitchAdd(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleSimilarSwitches(t *testing.T) {
+	tcases := []struct {
+		service  fakeService
+		w        *fakeWriter
+		req      *http.Request
+		expected struct {
+			data         string
+			headerStatus int
+		}
+	}{
+		{
+			service: fakeService{},
+			w:       &fakeWriter{},
+			req: func() *http.Request {
+				rq, _ := http.NewRequest("GET", "/tst/tstname/similar?limit=0", nil)
+				rq.SetPathValue("brand", "tst")
+				rq.SetPathValue("name", "tstname")
+
+				return rq
+			}(),
+			expected: struct {
+				data         string
+				headerStatus int
+			}{
+				data: common.APIError{
+					Status:  http.StatusBadRequest,
+					Message: "query parameter 'limit' must be a number between 1 and 50",
+				}.Error(),
+				headerStatus: http.StatusBadRequest,
+			},
+		},
+		{
+			service: fakeService{},
+			w:       &fakeWriter{},
+			req: func() *http.Request {
+				rq, _ := http.NewRequest("GET", "/tst/tstname/similar?profile=abc", nil)
+				rq.SetPathValue("brand", "tst")
+				rq.SetPathValue("name", "tstname")
+
+				return rq
+			}(),
+			expected: struct {
+				data         string
+				headerStatus int
+			}{
+				data: common.APIError{
+					Status:  http.StatusBadRequest,
+					Message: "weight 'profile' must be a number",
+				}.Error(),
+				headerStatus: http.StatusBadRequest,
+			},
+		},
+		{
+			service: fakeService{
+				similarReturner: func(brand, name string, limit int, weights models.SimilarityWeights) ([]models.SimilarSwitch, *common.AppError) {
+					e := common.NewError(common.ErrNotFound, "tst")
+					return nil, &e
+				},
+			},
+			w: &fakeWriter{},
+			req: func() *http.Request {
+				rq, _ := http.NewRequest("GET", "/tst/tstname/similar", nil)
+				rq.SetPathValue("brand", "tst")
+				rq.SetPathValue("name", "tstname")
+
+				return rq
+			}(),
+			expected: struct {
+				data         string
+				headerStatus int
+			}{
+				data: common.APIError{
+					Status:  http.StatusNotFound,
+					Message: "tst",
+				}.Error(),
+				headerStatus: http.StatusNotFound,
+			},
+		},
+		{
+			service: fakeService{
+				similarReturner: func(brand, name string, limit int, weights models.SimilarityWeights) ([]models.SimilarSwitch, *common.AppError) {
+					if limit != 3 || weights[models.AttrProfile] != 5 {
+						e := common.NewError(common.ErrBadRequest, "query was not passed to service")
+						return nil, &e
+					}
+					return []models.SimilarSwitch{
+						{
+							Switch: models.Switch{Brand: "b", Name: "n", OperatingForce: 45},
+							Score:  0.123456,
+							Matches: []models.AttributeMatch{
+								{Attribute: models.AttrProfile, Similarity: 1, Weight: 5, Contribution: 0.123456},
+							},
+						},
+					}, nil
+				},
+			},
+			w: &fakeWriter{},
+			req: func() *http.Request {
+				rq, _ := http.NewRequest("GET", "/tst/tstname/similar?limit=3&profile=5", nil)
+				rq.SetPathValue("brand", "tst")
+				rq.SetPathValue("name", "tstname")
+
+				return rq
+			}(),
+			expected: struct {
+				data         string
+				headerStatus int
+			}{
+				data: func() string {
+					dtos := []switches.SimilarSwitchDTO{
+						{
+							Switch: switches.SwitchDTO{
+								Brand:            "b",
+								Name:             "n",
+								OperatingForce:   "45gf",
+								Lifespan:         "0M",
+								ActivationTravel: "0mm",
+								TotalTravel:      "0mm",
+							},
+							Score: 0.1235,
+							Matches: []switches.AttributeMatchDTO{
+								{Attribute: models.AttrProfile, Similarity: 1, Weight: 5, Contribution: 0.1235},
+							},
+						},
+					}
+
+					json, _ := json.Marshal(dtos)
+					return string(json[:])
+				}(),
+				headerStatus: http.StatusOK,
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		handler := switches.New(tc.service)
+		handler.HandleSimilarSwitches(context.Background(), tc.w, tc.req)
+		if tc.expected.data != tc.w.input {
+			t.Errorf("HandleSimilarSwitches failed\nexpected %v\ngot %s", tc.expected.data, tc.w.input)
+		}
+		if tc.expected.headerStatus != tc.w.headerStatus {
+			t.Errorf("HandleSimilarSwitches response header failed\nexpected %v\ngot  %v",
+				tc.expected.headerStatus, tc.w.headerStatus)
+		}
+	}
+}
+
+func TestHandleSearch(t *testing.T) {
+	tcases := []struct {
+		service  fakeService
+		w        *fakeWriter
+		req      *http.Request
+		expected struct {
+			data         string
+			headerStatus int
+		}
+	}{
+		{
+			service: fakeService{},
+			w:       &fakeWriter{},
+			req: func() *http.Request {
+				rq, _ := http.NewRequest("GET", "/api/search", nil)
+				return rq
+			}(),
+			expected: struct {
+				data         string
+				headerStatus int
+			}{
+				data: common.APIError{
+					Status:  http.StatusBadRequest,
+					Message: "query parameter 'q' is missing",
+				}.Error(),
+				headerStatus: http.StatusBadRequest,
+			},
+		},
+		{
+			service: fakeService{},
+			w:       &fakeWriter{},
+			req: func() *http.Request {
+				rq, _ := http.NewRequest("GET", "/api/search?q=red&minOperatingForce=heavy", nil)
+				return rq
+			}(),
+			expected: struct {
+				data         string
+				headerStatus int
+			}{
+				data: common.APIError{
+					Status:  http.StatusBadRequest,
+					Message: "query parameter 'minOperatingForce' must be an integer",
+				}.Error(),
+				headerStatus: http.StatusBadRequest,
+			},
+		},
+		{
+			service: fakeService{
+				searchReturner: func(q string, filter models.SwitchFilter, limit int) ([]models.SearchResult, *common.AppError) {
+					if q != "red" || filter.Profile != "MX" || *filter.MaxOperatingForce != 50 || limit != 20 {
+						e := common.NewError(common.ErrBadRequest, "query was not passed to service")
+						return nil, &e
+					}
+					return []models.SearchResult{
+						{
+							Switch:     models.Switch{Brand: "Cherry", Name: "Red"},
+							Rank:       0.5,
+							Highlights: map[string]string{models.AttrName: "<mark>Red</mark>"},
+						},
+					}, nil
+				},
+			},
+			w: &fakeWriter{},
+			req: func() *http.Request {
+				rq, _ := http.NewRequest("GET", "/api/search?q=red&profile=MX&maxOperatingForce=50", nil)
+				return rq
+			}(),
+			expected: struct {
+				data         string
+				headerStatus int
+			}{
+				data: func() string {
+					dtos := []switches.SearchResultDTO{
+						{
+							Switch: switches.SwitchDTO{
+								Brand:            "Cherry",
+								Name:             "Red",
+								OperatingForce:   "0gf",
+								Lifespan:         "0M",
+								ActivationTravel: "0mm",
+								TotalTravel:      "0mm",
+							},
+							Rank:       0.5,
+							Highlights: map[string]string{models.AttrName: "<mark>Red</mark>"},
+						},
+					}
+
+					json, _ := json.Marshal(dtos)
+					return string(json[:])
+				}(),
+				headerStatus: http.StatusOK,
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		handler := switches.New(tc.service)
+		handler.HandleSearch(context.Background(), tc.w, tc.req)
+		if tc.expected.data != tc.w.input {
+			t.Errorf("HandleSearch failed\nexpected %v\ngot %s", tc.expected.data, tc.w.input)
+		}
+		if tc.expected.headerStatus != tc.w.headerStatus {
+			t.Errorf("HandleSearch response header failed\nexpected %v\ngot  %v",
+				tc.expected.headerStatus, tc.w.headerStatus)
+		}
+	}
+}
+
+func TestHandleSingleSwitchAlias(t *testing.T) {
+	service := fakeService{
+		singleReturner: func(brand, name string) (*models.Switch, *common.AppError) {
+			return &models.Switch{Brand: "Cherry", Name: "MX Red"}, nil
+		},
+	}
+
+	tcases := []struct {
+		url      string
+		name     string
+		expected struct {
+			canonical    string
+			location     string
+			headerStatus int
+		}
+	}{
+		{
+			url:  "/Cherry/mx%20red",
+			name: "mx red",
+			expected: struct {
+				canonical    string
+				location     string
+				headerStatus int
+			}{
+				headerStatus: http.StatusOK,
+			},
+		},
+		{
+			url:  "/Cherry/Red",
+			name: "Red",
+			expected: struct {
+				canonical    string
+				location     string
+				headerStatus int
+			}{
+				canonical:    "/api/switches/Cherry/MX%20Red",
+				headerStatus: http.StatusOK,
+			},
+		},
+		{
+			url:  "/Cherry/Red?redirect=true",
+			name: "Red",
+			expected: struct {
+				canonical    string
+				location     string
+				headerStatus int
+			}{
+				canonical:    "/api/switches/Cherry/MX%20Red",
+				location:     "/api/switches/Cherry/MX%20Red",
+				headerStatus: http.StatusMovedPermanently,
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		w := &fakeWriter{}
+		rq, _ := http.NewRequest("GET", tc.url, nil)
+		rq.SetPathValue("brand", "Cherry")
+		rq.SetPathValue("name", tc.name)
+
+		handler := switches.New(service)
+		handler.HandleSingleSwitch(context.Background(), w, rq)
+
+		if tc.expected.headerStatus != w.headerStatus {
+			t.Errorf("HandleSingleSwitch response header failed\nexpected %v\ngot  %v",
+				tc.expected.headerStatus, w.headerStatus)
+		}
+		if loc := w.Header().Get("Location"); tc.expected.location != loc {
+			t.Errorf("HandleSingleSwitch redirect failed\nexpected %q\ngot %q", tc.expected.location, loc)
+		}
+		if w.headerStatus != http.StatusOK {
+			continue
+		}
+
+		var dto switches.SwitchDTO
+		json.Unmarshal([]byte(w.input), &dto)
+		if tc.expected.canonical != dto.Canonical {
+			t.Errorf("HandleSingleSwitch canonical link failed\nexpected %q\ngot %q", tc.expected.canonical, dto.Canonical)
+		}
+	}
+}
+
+func TestHandleExport(t *testing.T) {
+	catalog := []models.Switch{
+		{Brand: "Gateron", Name: "Yellow", OperatingForce: 50, TotalTravel: 4, ActivationTravel: 2, Profile: "MX"},
+		{Brand: "Cherry", Name: "Red, Silent", OperatingForce: 45, TotalTravel: 3.7, ActivationTravel: 1.9, Profile: "MX"},
+	}
+	service := fakeService{
+		exportAction: func(ctx context.Context, filter models.SwitchFilter, fn func(models.Switch) error) *common.AppError {
+			if filter.Profile == "fail" {
+				e := common.NewError(common.ErrInternalServer, "tst")
+				return &e
+			}
+			for _, sw := range catalog {
+				if err := fn(sw); err != nil {
+					return common.Wrap(err)
+				}
+			}
+			return nil
+		},
+	}
+	date := time.Now().Format(time.DateOnly)
+
+	tcases := []struct {
+		url      string
+		expected struct {
+			status      int
+			contentType string
+			disposition string
+			body        string
+		}
+	}{
+		{
+			url: "/export",
+			expected: struct {
+				status      int
+				contentType string
+				disposition string
+				body        string
+			}{
+				status:      http.StatusOK,
+				contentType: "text/csv; charset=utf-8",
+				disposition: "attachment; filename=switches-" + date + ".csv",
+				body: "brand,name,actuationType,lifespan,operatingForce,activationTravel,totalTravel,soundProfile,triggerMethod,profile,description\n" +
+					"Gateron,Yellow,,0,50,2,4,,,MX,\n" +
+					"Cherry,\"Red, Silent\",,0,45,1.9,3.7,,,MX,\n",
+			},
+		},
+		{
+			url: "/export?format=ndjson",
+			expected: struct {
+				status      int
+				contentType string
+				disposition string
+				body        string
+			}{
+				status:      http.StatusOK,
+				contentType: "application/x-ndjson",
+				disposition: "attachment; filename=switches-" + date + ".ndjson",
+				body: func() string {
+					a, _ := json.Marshal(switches.AsExportRecordDTO(catalog[0], units.Options{}))
+					b, _ := json.Marshal(switches.AsExportRecordDTO(catalog[1], units.Options{}))
+					return string(a) + "\n" + string(b) + "\n"
+				}(),
+			},
+		},
+		{
+			url: "/export?format=json",
+			expected: struct {
+				status      int
+				contentType string
+				disposition string
+				body        string
+			}{
+				status:      http.StatusOK,
+				contentType: "application/json; charset=utf-8",
+				disposition: "attachment; filename=switches-" + date + ".json",
+				body: func() string {
+					j, _ := json.Marshal([]switches.ExportRecordDTO{
+						switches.AsExportRecordDTO(catalog[0], units.Options{}),
+						switches.AsExportRecordDTO(catalog[1], units.Options{}),
+					})
+					return string(j)
+				}(),
+			},
+		},
+		{
+			url: "/export?format=xlsx",
+			expected: struct {
+				status      int
+				contentType string
+				disposition string
+				body        string
+			}{
+				status: http.StatusBadRequest,
+				body: common.APIError{
+					Status:  http.StatusBadRequest,
+					Message: "query parameter 'format' must be one of csv, ndjson or json",
+				}.Error(),
+			},
+		},
+		{
+			url: "/export?profile=fail",
+			expected: struct {
+				status      int
+				contentType string
+				disposition string
+				body        string
+			}{
+				status: http.StatusInternalServerError,
+				body: common.APIError{
+					Status:  http.StatusInternalServerError,
+					Message: "tst",
+				}.Error(),
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		w := httptest.NewRecorder()
+		rq, _ := http.NewRequest("GET", tc.url, nil)
+
+		handler := switches.New(service)
+		handler.HandleExport(context.Background(), w, rq)
+
+		if tc.expected.status != w.Code {
+			t.Errorf("HandleExport response header failed\nexpected %v\ngot  %v", tc.expected.status, w.Code)
+		}
+		if tc.expected.body != w.Body.String() {
+			t.Errorf("HandleExport failed\nexpected %v\ngot %s", tc.expected.body, w.Body.String())
+		}
+		if tc.expected.contentType != "" && tc.expected.contentType != w.Header().Get("Content-Type") {
+			t.Errorf("HandleExport content type failed\nexpected %v\ngot %v", tc.expected.contentType, w.Header().Get("Content-Type"))
+		}
+		if tc.expected.disposition != w.Header().Get("Content-Disposition") {
+			t.Errorf("HandleExport content disposition failed\nexpected %v\ngot %v", tc.expected.disposition, w.Header().Get("Content-Disposition"))
+		}
+	}
+}
+
+func TestHandleExportOutlastsTimeout(t *testing.T) {
+	catalog := []models.Switch{
+		{Brand: "Gateron", Name: "Yellow", OperatingForce: 50, Profile: "MX"},
+		{Brand: "Cherry", Name: "Red", OperatingForce: 45, Profile: "MX"},
+	}
+	// every record takes a while, together they take longer than the request timeout
+	service := fakeService{
+		exportAction: func(ctx context.Context, filter models.SwitchFilter, fn func(models.Switch) error) *common.AppError {
+			for _, sw := range catalog {
+				select {
+				case <-time.After(700 * time.Millisecond):
+				case <-ctx.Done():
+					return common.Wrap(ctx.Err())
+				}
+				if err := fn(sw); err != nil {
+					return common.Wrap(err)
+				}
+			}
+			return nil
+		},
+	}
+
+	handler := switches.New(service)
+	export := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.HandleExport(r.Context(), w, r)
+	})
+	sut := middlewares.Timeout(1)(middlewares.Stream(time.Minute)(export))
+
+	w := httptest.NewRecorder()
+	rq, _ := http.NewRequest("GET", "/export", nil)
+	sut.ServeHTTP(w, rq)
+
+	want := "brand,name,actuationType,lifespan,operatingForce,activationTravel,totalTravel,soundProfile,triggerMethod,profile,description\n" +
+		"Gateron,Yellow,,0,50,0,0,,,MX,\n" +
+		"Cherry,Red,,0,45,0,0,,,MX,\n"
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("HandleExport under timeout failed\nexpected %v %q\ngot %v %q", http.StatusOK, want, w.Code, w.Body.String())
+	}
+}
+
+func TestHandleBatch(t *testing.T) {
+	var received []models.BatchOperation
+	service := fakeService{
+		batchAction: func(ops []models.BatchOperation, opts models.BatchOptions) (*models.BatchReport, *common.AppError) {
+			received = ops
+			e := common.NewError(common.ErrNotFound, "tst")
+			return &models.BatchReport{
+				Atomic:     opts.Atomic,
+				RolledBack: opts.Atomic,
+				Results: []models.BatchOperationResult{
+					{Index: 0, Action: models.BatchCreate, Brand: "Gateron", Name: "Yellow", Status: models.BatchCreated, ID: intptr(2)},
+					{Index: 1, Action: models.BatchDelete, Brand: "Cherry", Name: "MX Red", Status: models.BatchFailed, Err: &e},
+				},
+			}, nil
+		},
+	}
+	body := `[{"op":"create","switch":{"brand":"Gateron","name":"Yellow"}},{"op":"delete","target":"Cherry/MX Red"}]`
+
+	tcases := []struct {
+		url      string
+		body     string
+		expected struct {
+			status int
+			ops    []models.BatchOperation
+			data   string
+		}
+	}{
+		{
+			url:  "/batch",
+			body: body,
+			expected: struct {
+				status int
+				ops    []models.BatchOperation
+				data   string
+			}{
+				status: http.StatusOK,
+				ops: []models.BatchOperation{
+					{Action: models.BatchCreate, Body: &models.SwitchRequestBody{Brand: "Gateron", Name: "Yellow"}},
+					{Action: models.BatchDelete, Brand: "Cherry", Name: "MX Red"},
+				},
+				data: `{"atomic":false,"rolledBack":false,"summary":{"created":1,"failed":1},"operations":[` +
+					`{"index":0,"op":"create","target":"Gateron/Yellow","status":"created","id":2},` +
+					`{"index":1,"op":"delete","target":"Cherry/MX Red","status":"failed","error":{"status":404,"message":"tst"}}]}`,
+			},
+		},
+		{
+			url:  "/batch?mode=atomic",
+			body: body,
+			expected: struct {
+				status int
+				ops    []models.BatchOperation
+				data   string
+			}{
+				status: http.StatusUnprocessableEntity,
+				ops: []models.BatchOperation{
+					{Action: models.BatchCreate, Body: &models.SwitchRequestBody{Brand: "Gateron", Name: "Yellow"}},
+					{Action: models.BatchDelete, Brand: "Cherry", Name: "MX Red"},
+				},
+				data: `"rolledBack":true`,
+			},
+		},
+		{
+			url:  "/batch",
+			body: `[{"op":"delete","target":"Cherry"}]`,
+			expected: struct {
+				status int
+				ops    []models.BatchOperation
+				data   string
+			}{
+				status: http.StatusBadRequest,
+				data: common.APIError{
+					Status:  http.StatusBadRequest,
+					Message: "operation 0: target must be in form 'brand/name'",
+				}.Error(),
+			},
+		},
+		{
+			url:  "/batch?mode=all",
+			body: body,
+			expected: struct {
+				status int
+				ops    []models.BatchOperation
+				data   string
+			}{
+				status: http.StatusBadRequest,
+				data: common.APIError{
+					Status:  http.StatusBadRequest,
+					Message: "query parameter 'mode' must be either 'bestEffort' or 'atomic'",
+				}.Error(),
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		received = nil
+		w := httptest.NewRecorder()
+		rq := httptest.NewRequest(http.MethodPost, tc.url, strings.NewReader(tc.body))
+
+		switches.New(service).HandleBatch(context.Background(), w, rq)
+
+		if tc.expected.status != w.Code {
+			t.Errorf("HandleBatch response header failed\nexpected %v\ngot  %v", tc.expected.status, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), tc.expected.data) {
+			t.Errorf("HandleBatch failed\nexpected %v\ngot %s", tc.expected.data, w.Body.String())
+		}
+		if !reflect.DeepEqual(tc.expected.ops, received) {
+			t.Errorf("HandleBatch operations failed\nexpected %+v\ngot %+v", tc.expected.ops, received)
+		}
+	}
+}
+
+func TestHandleLookup(t *testing.T) {
+	var received []models.SwitchKey
+	service := fakeService{
+		lookupReturner: func(keys []models.SwitchKey) (*models.LookupResult, *common.AppError) {
+			received = keys
+			return &models.LookupResult{
+				Found:   []models.Switch{{Brand: "Cherry", Name: "MX Red", Lifespan: 100}},
+				Missing: []models.SwitchKey{{Brand: "Gateron", Name: "Ink"}},
+			}, nil
+		},
+	}
+	found := `{"found":[{"brand":"Cherry","actuationType":"","lifespan":"100M","name":"MX Red","image":"","operatingForce":"0gf",` +
+		`"activationTravel":"0mm","totalTravel":"0mm","SoundProfile":"","triggermethod":"","profile":"","description":""}],` +
+		`"missing":[{"brand":"Gateron","name":"Ink"}]}`
+
+	tcases := []struct {
+		rq       *http.Request
+		expected struct {
+			status int
+			keys   []models.SwitchKey
+			data   string
+		}
+	}{
+		{
+			rq: httptest.NewRequest(http.MethodGet, "/?ids=Cherry/MX%20Red,Gateron/Ink", nil),
+			expected: struct {
+				status int
+				keys   []models.SwitchKey
+				data   string
+			}{
+				status: http.StatusOK,
+				keys:   []models.SwitchKey{{Brand: "Cherry", Name: "MX Red"}, {Brand: "Gateron", Name: "Ink"}},
+				data:   found,
+			},
+		},
+		{
+			rq: httptest.NewRequest(http.MethodPost, "/lookup",
+				strings.NewReader(`[{"brand":"Cherry","name":"MX Red"},{"brand":"Gateron","name":"Ink"}]`)),
+			expected: struct {
+				status int
+				keys   []models.SwitchKey
+				data   string
+			}{
+				status: http.StatusOK,
+				keys:   []models.SwitchKey{{Brand: "Cherry", Name: "MX Red"}, {Brand: "Gateron", Name: "Ink"}},
+				data:   found,
+			},
+		},
+		{
+			rq: httptest.NewRequest(http.MethodGet, "/?ids=Cherry", nil),
+			expected: struct {
+				status int
+				keys   []models.SwitchKey
+				data   string
+			}{
+				status: http.StatusBadRequest,
+				data: common.APIError{
+					Status:  http.StatusBadRequest,
+					Message: "id 'Cherry' must be in form 'brand/name'",
+				}.Error(),
+			},
+		},
+		{
+			rq: func() *http.Request {
+				rq := httptest.NewRequest(http.MethodGet, "/?ids=Cherry/MX%20Red", nil)
+				rq.Header.Set("Accept", "text/csv")
+				return rq
+			}(),
+			expected: struct {
+				status int
+				keys   []models.SwitchKey
+				data   string
+			}{
+				status: http.StatusNotAcceptable,
+				data:   `{"status":406,`,
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		received = nil
+		w := httptest.NewRecorder()
+
+		handler := switches.New(service)
+		if tc.rq.Method == http.MethodPost {
+			handler.HandleLookup(context.Background(), w, tc.rq)
+		} else {
+			handler.HandleSwitches(context.Background(), w, tc.rq)
+		}
+
+		if tc.expected.status != w.Code {
+			t.Errorf("HandleLookup response header failed\nexpected %v\ngot  %v", tc.expected.status, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), tc.expected.data) {
+			t.Errorf("HandleLookup failed\nexpected %v\ngot %s", tc.expected.data, w.Body.String())
+		}
+		if !reflect.DeepEqual(tc.expected.keys, received) {
+			t.Errorf("HandleLookup keys failed\nexpected %+v\ngot %+v", tc.expected.keys, received)
+		}
+	}
+}
+
+func TestHandleSingleSwitchUnits(t *testing.T) {
+	service := fakeService{singleReturner: func(brand, name string) (*models.Switch, *common.AppError) {
+		return &models.Switch{
+			Brand: brand, Name: name, Lifespan: 50, OperatingForce: 45, ActivationTravel: 2, TotalTravel: 4,
+		}, nil
+	}}
+
+	tcases := []struct {
+		query    string
+		expected struct {
+			status int
+			data   string
+		}
+	}{
+		{
+			query: "",
+			expected: struct {
+				status int
+				data   string
+			}{
+				status: http.StatusOK,
+				data:   `"lifespan":"50M","name":"tst","image":"","operatingForce":"45gf","activationTravel":"2mm","totalTravel":"4mm"`,
+			},
+		},
+		{
+			query: "?units=imperial",
+			expected: struct {
+				status int
+				data   string
+			}{
+				status: http.StatusOK,
+				data:   `"operatingForce":"1.59oz","activationTravel":"0.079in","totalTravel":"0.157in"`,
+			},
+		},
+		{
+			query: "?forceUnit=cN&raw=true",
+			expected: struct {
+				status int
+				data   string
+			}{
+				status: http.StatusOK,
+				data: `"operatingForce":"44.13cN","activationTravel":"2mm","totalTravel":"4mm","SoundProfile":"","triggermethod":"","profile":"","description":"",` +
+					`"values":{"lifespan":50,"operatingForce":44.13,"activationTravel":2,"totalTravel":4},` +
+					`"units":{"force":"cN","travel":"mm","lifespan":"M"}}`,
+			},
+		},
+		{
+			query: "?forceUnit=N",
+			expected: struct {
+				status int
+				data   string
+			}{
+				status: http.StatusBadRequest,
+				data: common.APIError{
+					Status:  http.StatusBadRequest,
+					Message: "query parameter 'forceUnit' must be one of gf, cN, g or oz",
+				}.Error(),
+			},
+		},
+		{
+			query: "?units=nautical",
+			expected: struct {
+				status int
+				data   string
+			}{
+				status: http.StatusBadRequest,
+				data: common.APIError{
+					Status:  http.StatusBadRequest,
+					Message: "query parameter 'units' must be either 'metric' or 'imperial'",
+				}.Error(),
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		w := httptest.NewRecorder()
+		rq := httptest.NewRequest(http.MethodGet, "/tst/tst"+tc.query, nil)
+		rq.SetPathValue("brand", "tst")
+		rq.SetPathValue("name", "tst")
+
+		switches.New(service).HandleSingleSwitch(context.Background(), w, rq)
+
+		if tc.expected.status != w.Code {
+			t.Errorf("HandleSingleSwitch with %q status failed\nexpected %v\ngot  %v", tc.query, tc.expected.status, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), tc.expected.data) {
+			t.Errorf("HandleSingleSwitch with %q failed\nexpected to contain %v\ngot %s", tc.query, tc.expected.data, w.Body.String())
+		}
+	}
+}
+
+func TestHandleSingleSwitchVersions(t *testing.T) {
+	service := fakeService{singleReturner: func(brand, name string) (*models.Switch, *common.AppError) {
+		return &models.Switch{
+			Brand: "Gateron", Name: "Yellow", Lifespan: 50, OperatingForce: 45, ActivationTravel: 2, TotalTravel: 4,
+		}, nil
+	}}
+
+	tcases := []struct {
+		version  versioning.Version
+		path     string
+		query    string
+		expected struct {
+			data string
+			link string
+		}
+	}{
+		{
+			version: versioning.V1,
+			path:    "Gateron/Yellow",
+			expected: struct {
+				data string
+				link string
+			}{
+				data: `"lifespan":"50M","name":"Yellow","image":"","operatingForce":"45gf"`,
+			},
+		},
+		{
+			version: versioning.V2,
+			path:    "Gateron/Yellow",
+			query:   "?units=imperial",
+			expected: struct {
+				data string
+				link string
+			}{
+				data: `"lifespan":50,"operatingForce":1.59,"activationTravel":0.079,"totalTravel":0.157,"soundProfile":"","triggerMethod":"",` +
+					`"profile":"","image":"","description":"","units":{"force":"oz","travel":"in","lifespan":"M"}}`,
+			},
+		},
+		{
+			version: versioning.V1,
+			path:    "KTT/Yellow",
+			expected: struct {
+				data string
+				link string
+			}{
+				data: `"canonical":"/api/switches/Gateron/Yellow"`,
+				link: `</api/switches/Gateron/Yellow>; rel="canonical"`,
+			},
+		},
+		{
+			version: versioning.V2,
+			path:    "KTT/Yellow",
+			expected: struct {
+				data string
+				link string
+			}{
+				data: `"canonical":"/api/v2/switches/Gateron/Yellow"`,
+				link: `</api/v2/switches/Gateron/Yellow>; rel="canonical"`,
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		w := httptest.NewRecorder()
+		rq := httptest.NewRequest(http.MethodGet, "/"+tc.path+tc.query, nil)
+		brand, name, _ := strings.Cut(tc.path, "/")
+		rq.SetPathValue("brand", brand)
+		rq.SetPathValue("name", name)
+
+		ctx := versioning.WithVersion(context.Background(), tc.version)
+		switches.New(service).HandleSingleSwitch(ctx, w, rq)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("HandleSingleSwitch v%d %s status failed\nexpected %v\ngot  %v", tc.version, tc.path, http.StatusOK, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), tc.expected.data) {
+			t.Errorf("HandleSingleSwitch v%d %s failed\nexpected to contain %v\ngot %s", tc.version, tc.path, tc.expected.data, w.Body.String())
+		}
+		if link := w.Header().Get("Link"); link != tc.expected.link {
+			t.Errorf("HandleSingleSwitch v%d %s link failed\nexpected %v\ngot  %v", tc.version, tc.path, tc.expected.link, link)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package switches
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kbswitch/internal/core/switches/models"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const maxImportRows = 5000
+
+var errTooManyRows = fmt.Errorf("import is limited to %d rows", maxImportRows)
+
+// importColumns maps json field names of models.SwitchRequestBody to their struct field index,
+// so csv header uses the same names as json body does
+var importColumns = func() map[string]int {
+	res := map[string]int{}
+	t := reflect.TypeOf(models.SwitchRequestBody{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		res[strings.ToLower(tag)] = i
+	}
+	return res
+}()
+
+func parseImport(contentType string, body io.Reader) ([]models.ImportRow, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case "text/csv":
+		return parseCSVImport(body)
+	case "application/json", "":
+		return parseJSONImport(body)
+	default:
+		return nil, fmt.Errorf("unsupported content type '%s', use text/csv or application/json", mediaType)
+	}
+}
+
+func parseJSONImport(body io.Reader) ([]models.ImportRow, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return nil, errors.New("request body must be a json array of switches")
+	}
+	if len(raw) > maxImportRows {
+		return nil, errTooManyRows
+	}
+
+	rows := make([]models.ImportRow, len(raw))
+	for i, item := range raw {
+		rows[i].Row = i + 1
+
+		decoder := json.NewDecoder(bytes.NewReader(item))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rows[i].Body); err != nil {
+			rows[i].ParseErrors = []string{"invalid switch model: " + err.Error()}
+		}
+	}
+
+	return rows, nil
+}
+
+func parseCSVImport(body io.Reader) ([]models.ImportRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("csv header is missing")
+	}
+
+	fields := make([]int, len(header))
+	for i, column := range header {
+		idx, ok := importColumns[strings.ToLower(strings.TrimSpace(column))]
+		if !ok {
+			return nil, fmt.Errorf("unknown csv column '%s'", column)
+		}
+		fields[i] = idx
+	}
+
+	rows := make([]models.ImportRow, 0)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) == maxImportRows {
+			return nil, errTooManyRows
+		}
+
+		row := models.ImportRow{Row: line}
+		if err != nil {
+			row.ParseErrors = []string{err.Error()}
+			rows = append(rows, row)
+			continue
+		}
+
+		v := reflect.ValueOf(&row.Body).Elem()
+		for i, value := range record {
+			if err := setField(v.Field(fields[i]), strings.TrimSpace(value)); err != nil {
+				row.ParseErrors = append(row.ParseErrors, fmt.Sprintf("column '%s': %s", header[i], err.Error()))
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		if value == "" {
+			return nil
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("'%s' is not an integer", value)
+		}
+		field.SetInt(int64(parsed))
+	case reflect.Float64:
+		if value == "" {
+			return nil
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a number", value)
+		}
+		field.SetFloat(parsed)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package switches
+
+import (
+	"kbswitch/internal/core/switches/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseImport(t *testing.T) {
+	tcases := []struct {
+		contentType string
+		body        string
+		expected    struct {
+			rows []models.ImportRow
+			err  string
+		}
+	}{
+		{
+			contentType: "text/csv; charset=utf-8",
+			body: "brand,name,operatingForce,totalTravel\n" +
+				"Gateron,Yellow,50,4\n" +
+				"Cherry,Red,heavy,4\n",
+			expected: struct {
+				rows []models.ImportRow
+				err  string
+			}{
+				rows: []models.ImportRow{
+					{Row: 1, Body: models.SwitchRequestBody{Brand: "Gateron", Name: "Yellow", OperatingForce: 50, TotalTravel: 4}},
+					{
+						Row:         2,
+						Body:        models.SwitchRequestBody{Brand: "Cherry", Name: "Red", TotalTravel: 4},
+						ParseErrors: []string{"column 'operatingForce': 'heavy' is not an integer"},
+					},
+				},
+			},
+		},
+		{
+			contentType: "text/csv",
+			body:        "brand,name,color\nGateron,Yellow,yellow\n",
+			expected: struct {
+				rows []models.ImportRow
+				err  string
+			}{
+				err: "unknown csv column 'color'",
+			},
+		},
+		{
+			contentType: "application/json",
+			body:        `[{"brand":"Gateron","name":"Yellow"},{"brand":"Cherry","color":"red"}]`,
+			expected: struct {
+				rows []models.ImportRow
+				err  string
+			}{
+				rows: []models.ImportRow{
+					{Row: 1, Body: models.SwitchRequestBody{Brand: "Gateron", Name: "Yellow"}},
+					{
+						Row:         2,
+						Body:        models.SwitchRequestBody{Brand: "Cherry"},
+						ParseErrors: []string{`invalid switch model: json: unknown field "color"`},
+					},
+				},
+			},
+		},
+		{
+			contentType: "application/xml",
+			body:        "<switches/>",
+			expected: struct {
+				rows []models.ImportRow
+				err  string
+			}{
+				err: "unsupported content type 'application/xml', use text/csv or application/json",
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		rows, err := parseImport(tc.contentType, strings.NewReader(tc.body))
+
+		if tc.expected.err != "" {
+			if err == nil || err.Error() != tc.expected.err {
+				t.Errorf("parseImport error check failed\nexpected %v\ngot %v", tc.expected.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseImport returned unexpected error %v", err)
+			continue
+		}
+		if !reflect.DeepEqual(tc.expected.rows, rows) {
+			t.Errorf("parseImport failed\nexpected %+v\ngot %+v", tc.expected.rows, rows)
+		}
+	}
+}
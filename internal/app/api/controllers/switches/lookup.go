@@ -0,0 +1,63 @@
+package switches
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kbswitch/internal/app/api/render"
+	"kbswitch/internal/core/switches/models"
+	"strings"
+)
+
+const maxLookupBodySize = 1 << 20
+
+// lookupEncoders leaves csv out, lookup result is not a flat table
+var lookupEncoders = func() *render.Registry {
+	r := render.NewRegistry()
+	r.Register("application/json", render.JSON{})
+	r.Register("application/yaml", render.YAML{})
+	r.Register("application/x-yaml", render.YAML{})
+	r.Register("text/yaml", render.YAML{})
+	r.Register("application/xml", render.XML{})
+	r.Register("text/xml", render.XML{})
+	return r
+}()
+
+// parseIDs reads ids query values, every value is a comma separated list of brand/name pairs
+func parseIDs(values []string) ([]models.SwitchKey, error) {
+	keys := []models.SwitchKey{}
+	for _, v := range values {
+		for _, id := range strings.Split(v, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" {
+				continue
+			}
+
+			brand, name, ok := strings.Cut(id, "/")
+			if !ok || brand == "" || name == "" {
+				return nil, fmt.Errorf("id '%s' must be in form 'brand/name'", id)
+			}
+			keys = append(keys, models.SwitchKey{Brand: brand, Name: name})
+		}
+	}
+
+	return keys, nil
+}
+
+func parseLookup(body io.Reader) ([]models.SwitchKey, error) {
+	var raw []SwitchKeyDTO
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, errors.New("request body must be a json array of brand and name pairs")
+	}
+
+	keys := make([]models.SwitchKey, len(raw))
+	for i, k := range raw {
+		keys[i] = models.SwitchKey{Brand: k.Brand, Name: k.Name}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,40 @@
+package switches
+
+import (
+	"errors"
+	"kbswitch/internal/pkg/units"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// unitOptions reads units, forceUnit and raw query parameters,
+// on invalid ones it responds with 400 and returns false
+func unitOptions(w http.ResponseWriter, r *http.Request) (units.Options, bool) {
+	var query url.Values
+	if r.URL != nil {
+		query = r.URL.Query()
+	}
+
+	var raw bool
+	if v := query.Get("raw"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			writeErr("query parameter 'raw' must be a boolean", http.StatusBadRequest, w)
+			return units.Options{}, false
+		}
+		raw = parsed
+	}
+
+	opts, err := units.Parse(query.Get("units"), query.Get("forceUnit"), raw)
+	if errors.Is(err, units.ErrUnknownSystem) {
+		writeErr("query parameter 'units' must be either 'metric' or 'imperial'", http.StatusBadRequest, w)
+		return units.Options{}, false
+	}
+	if errors.Is(err, units.ErrUnknownForce) {
+		writeErr("query parameter 'forceUnit' must be one of gf, cN, g or oz", http.StatusBadRequest, w)
+		return units.Options{}, false
+	}
+
+	return opts, true
+}
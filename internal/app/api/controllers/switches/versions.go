@@ -0,0 +1,118 @@
+package switches
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/app/api/versioning"
+	"kbswitch/internal/core/switches/models"
+	"kbswitch/internal/pkg/units"
+	"net/url"
+)
+
+// presenter builds response bodies in representation of the api version request was routed to
+type presenter interface {
+	switches(entities []models.Switch, opts units.Options) any
+	// single sets canonical link when it is not empty
+	single(entity models.Switch, canonical string, opts units.Options) any
+	lookup(entity models.LookupResult, opts units.Options) any
+	similar(entities []models.SimilarSwitch, opts units.Options) any
+	search(entities []models.SearchResult, opts units.Options) any
+	batch(entity models.BatchReport, opts units.Options) any
+	canonicalPath(brand, name string) string
+}
+
+func presenterFor(ctx context.Context) presenter {
+	if versioning.FromContext(ctx) == versioning.V2 {
+		return v2Presenter{}
+	}
+	return v1Presenter{}
+}
+
+type v1Presenter struct{}
+
+func (v1Presenter) switches(entities []models.Switch, opts units.Options) any {
+	dtos := make([]SwitchDTO, len(entities))
+	for i, item := range entities {
+		dtos[i] = AsDTO(item, opts)
+	}
+	return dtos
+}
+
+func (v1Presenter) single(entity models.Switch, canonical string, opts units.Options) any {
+	dto := AsDTO(entity, opts)
+	dto.Canonical = canonical
+	return dto
+}
+
+func (v1Presenter) lookup(entity models.LookupResult, opts units.Options) any {
+	return AsLookupDTO(entity, opts)
+}
+
+func (v1Presenter) similar(entities []models.SimilarSwitch, opts units.Options) any {
+	dtos := make([]SimilarSwitchDTO, len(entities))
+	for i, item := range entities {
+		dtos[i] = AsSimilarDTO(item, opts)
+	}
+	return dtos
+}
+
+func (v1Presenter) search(entities []models.SearchResult, opts units.Options) any {
+	dtos := make([]SearchResultDTO, len(entities))
+	for i, item := range entities {
+		dtos[i] = AsSearchResultDTO(item, opts)
+	}
+	return dtos
+}
+
+func (v1Presenter) batch(entity models.BatchReport, opts units.Options) any {
+	return AsBatchReportDTO(entity, opts)
+}
+
+// v1 links stay unversioned, so they keep working for clients which never heard of versions
+func (v1Presenter) canonicalPath(brand, name string) string {
+	return fmt.Sprintf("/api/switches/%s/%s", url.PathEscape(brand), url.PathEscape(name))
+}
+
+type v2Presenter struct{}
+
+func (v2Presenter) switches(entities []models.Switch, opts units.Options) any {
+	dtos := make([]SwitchV2DTO, len(entities))
+	for i, item := range entities {
+		dtos[i] = AsV2DTO(item, opts)
+	}
+	return dtos
+}
+
+func (v2Presenter) single(entity models.Switch, canonical string, opts units.Options) any {
+	dto := AsV2DTO(entity, opts)
+	dto.Canonical = canonical
+	return dto
+}
+
+func (v2Presenter) lookup(entity models.LookupResult, opts units.Options) any {
+	return AsLookupV2DTO(entity, opts)
+}
+
+func (v2Presenter) similar(entities []models.SimilarSwitch, opts units.Options) any {
+	dtos := make([]SimilarSwitchV2DTO, len(entities))
+	for i, item := range entities {
+		dtos[i] = AsSimilarV2DTO(item, opts)
+	}
+	return dtos
+}
+
+func (v2Presenter) search(entities []models.SearchResult, opts units.Options) any {
+	dtos := make([]SearchResultV2DTO, len(entities))
+	for i, item := range entities {
+		dtos[i] = AsSearchResultV2DTO(item, opts)
+	}
+	return dtos
+}
+
+func (v2Presenter) batch(entity models.BatchReport, opts units.Options) any {
+	return AsBatchReportV2DTO(entity, opts)
+}
+
+func (v2Presenter) canonicalPath(brand, name string) string {
+	return fmt.Sprintf("/api/v%d/switches/%s/%s", versioning.V2, url.PathEscape(brand), url.PathEscape(name))
+}
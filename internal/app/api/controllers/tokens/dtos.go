@@ -0,0 +1,69 @@
+package tokens
+
+import "kbswitch/internal/core/tokens/models"
+
+type TokenRequestDTO struct {
+	GrantType    string `json:"grantType" enums:"password,refresh_token"`
+	Email        string `json:"email,omitempty"`
+	Password     string `json:"password,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+type RevokeDTO struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type TokenPairDTO struct {
+	AccessToken string `json:"accessToken"`
+	TokenType   string `json:"tokenType"`
+	// ExpiresIn is lifetime of the access token in seconds
+	ExpiresIn    int    `json:"expiresIn"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type JWKDTO struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+type JWKSDTO struct {
+	Keys []JWKDTO `json:"keys"`
+}
+
+func toTokenRequest(dto TokenRequestDTO) models.TokenRequest {
+	return models.TokenRequest{
+		GrantType:    models.GrantType(dto.GrantType),
+		Email:        dto.Email,
+		Password:     dto.Password,
+		RefreshToken: dto.RefreshToken,
+	}
+}
+
+func AsDTO(pair models.TokenPair) TokenPairDTO {
+	return TokenPairDTO{
+		AccessToken:  pair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(pair.ExpiresIn.Seconds()),
+		RefreshToken: pair.RefreshToken,
+	}
+}
+
+func AsJWKSDTO(keys []models.JWK) JWKSDTO {
+	dtos := make([]JWKDTO, len(keys))
+	for i, k := range keys {
+		dtos[i] = JWKDTO{
+			Kty: k.Kty,
+			Crv: k.Crv,
+			X:   k.X,
+			Kid: k.Kid,
+			Alg: k.Alg,
+			Use: k.Use,
+		}
+	}
+
+	return JWKSDTO{Keys: dtos}
+}
@@ -0,0 +1,126 @@
+package tokens
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/tokens"
+	"net/http"
+)
+
+const (
+	maxBodySize = 1 << 16
+	// jwksMaxAge lets clients cache public keys, a new key shows up for them within it
+	jwksMaxAge = 60 * 60
+)
+
+type controller struct {
+	service tokens.Service
+}
+
+func New(service tokens.Service) controller {
+	return controller{
+		service: service,
+	}
+}
+
+func writeErr(err string, status int, w http.ResponseWriter) {
+	e := common.APIError{
+		Status:  status,
+		Message: err,
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprint(w, e)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, dst any) bool {
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil && err != io.EOF {
+		writeErr("invalid request model", http.StatusBadRequest, w)
+		return false
+	}
+	return true
+}
+
+// HandleToken godoc
+//
+//	@Summary		Issue an access token
+//	@Description	Grants a short-lived access token with a refresh token, either for credentials or in exchange of a refresh token.
+//	@Description	A refresh token is good for one exchange only, using it again revokes every token of the session
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		TokenRequestDTO	true	"email and password for 'password' grant, refreshToken for 'refresh_token' grant"
+//	@Success		200		{object}	TokenPairDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Router			/api/auth/token [post]
+func (c controller) HandleToken(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req TokenRequestDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Issue(ctx, toTokenRequest(req))
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	json, _ := json.Marshal(AsDTO(*resp))
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleRevoke godoc
+//
+//	@Summary		Revoke a refresh token
+//	@Description	Revokes the refresh token with every token of its session, issued access tokens stay valid until they expire
+//	@Tags			auth
+//	@Accept			json
+//	@Param			request	body	RevokeDTO	true	"refresh token to revoke"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Router			/api/auth/revoke [post]
+func (c controller) HandleRevoke(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req RevokeDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if err := c.service.Revoke(ctx, req.RefreshToken); err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleJWKS godoc
+//
+//	@Summary		Get public keys of access tokens
+//	@Description	JSON Web Key Set to verify access tokens with, tokens name their key in 'kid' header
+//	@Tags			auth
+//	@Produce		json
+//	@Success		200	{object}	JWKSDTO
+//	@Router			/api/auth/jwks [get]
+func (c controller) HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	json, _ := json.Marshal(AsJWKSDTO(c.service.JWKS()))
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", jwksMaxAge))
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
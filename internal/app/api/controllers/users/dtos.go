@@ -0,0 +1,53 @@
+package users
+
+import (
+	"kbswitch/internal/core/users/models"
+	"time"
+)
+
+type CredentialsDTO struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type TokenDTO struct {
+	Token string `json:"token"`
+}
+
+type ForgotPasswordDTO struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordDTO struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type UserDTO struct {
+	ID            int       `json:"id"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"emailVerified"`
+	Role          string    `json:"role"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func AsDTO(entity models.User) UserDTO {
+	return UserDTO{
+		ID:            entity.ID,
+		Email:         entity.Email,
+		EmailVerified: entity.EmailVerified,
+		Role:          string(entity.Role),
+		CreatedAt:     entity.CreatedAt,
+	}
+}
+
+func toCredentials(d CredentialsDTO) models.Credentials {
+	return models.Credentials{
+		Email:    d.Email,
+		Password: d.Password,
+	}
+}
+
+type RoleDTO struct {
+	Role string `json:"role" enums:"viewer,contributor,moderator,admin"`
+}
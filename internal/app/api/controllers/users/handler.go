@@ -0,0 +1,332 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kbswitch/internal/app/api/middlewares"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/users"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxBodySize = 1 << 16
+
+type controller struct {
+	service users.Service
+	// secureCookies is off only for plain http development setups
+	secureCookies bool
+}
+
+func New(service users.Service, secureCookies bool) controller {
+	return controller{
+		service:       service,
+		secureCookies: secureCookies,
+	}
+}
+
+func writeErr(err string, status int, w http.ResponseWriter) {
+	e := common.APIError{
+		Status:  status,
+		Message: err,
+	}
+
+	w.WriteHeader(status)
+	fmt.Fprint(w, e)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, dst any) bool {
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil && err != io.EOF {
+		writeErr("invalid request model", http.StatusBadRequest, w)
+		return false
+	}
+	return true
+}
+
+func (c controller) setSession(w http.ResponseWriter, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     middlewares.SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(time.Until(expires).Seconds()),
+		HttpOnly: true,
+		Secure:   c.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (c controller) clearSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     middlewares.SessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// HandleRegister godoc
+//
+//	@Summary		Create an account
+//	@Description	Creates an account and mails a link to verify its email address
+//	@Tags			account
+//	@Accept			json
+//	@Produce		json
+//	@Param			credentials	body		CredentialsDTO	true	"email and password, at least 8 characters"
+//	@Success		201			{object}	UserDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		400			{object}	common.APIError
+//	@Router			/api/account/register [post]
+func (c controller) HandleRegister(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req CredentialsDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Register(ctx, toCredentials(req))
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	json, _ := json.Marshal(AsDTO(*resp))
+
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleLogin godoc
+//
+//	@Summary		Log in
+//	@Description	Starts a session kept in an HttpOnly cookie
+//	@Tags			account
+//	@Accept			json
+//	@Produce		json
+//	@Param			credentials	body		CredentialsDTO	true	"email and password"
+//	@Success		200			{object}	UserDTO
+//	@Failure		500			{object}	common.APIError
+//	@Failure		400			{object}	common.APIError
+//	@Failure		401			{object}	common.APIError
+//	@Router			/api/account/login [post]
+func (c controller) HandleLogin(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req CredentialsDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, err := c.service.Login(ctx, toCredentials(req))
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	c.setSession(w, resp.Token, resp.ExpiresAt)
+
+	json, _ := json.Marshal(AsDTO(resp.User))
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleLogout godoc
+//
+//	@Summary		Log out
+//	@Description	Ends the session of the cookie and clears it
+//	@Tags			account
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Router			/api/account/logout [post]
+func (c controller) HandleLogout(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(middlewares.SessionCookie); err == nil && cookie.Value != "" {
+		if err := c.service.Logout(ctx, cookie.Value); err != nil {
+			e := common.ToAPIErr(*err)
+			writeErr(e.Message, e.Status, w)
+			return
+		}
+	}
+
+	c.clearSession(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleMe godoc
+//
+//	@Summary		Get the logged in user
+//	@Tags			account
+//	@Produce		json
+//	@Success		200	{object}	UserDTO
+//	@Failure		401	{object}	common.APIError
+//	@Router			/api/account/me [get]
+func (c controller) HandleMe(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	user, ok := users.FromContext(ctx)
+	if !ok {
+		writeErr("not logged in", http.StatusUnauthorized, w)
+		return
+	}
+
+	json, _ := json.Marshal(AsDTO(user))
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleVerifyEmail godoc
+//
+//	@Summary		Verify email address
+//	@Tags			account
+//	@Accept			json
+//	@Param			token	body	TokenDTO	true	"token from the verification mail"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Router			/api/account/verify [post]
+func (c controller) HandleVerifyEmail(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req TokenDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if err := c.service.VerifyEmail(ctx, req.Token); err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleForgotPassword godoc
+//
+//	@Summary		Ask for a password reset
+//	@Description	Mails a reset link when there is an account for the email, responds the same either way
+//	@Tags			account
+//	@Accept			json
+//	@Param			email	body	ForgotPasswordDTO	true	"email of the account"
+//	@Success		202
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Router			/api/account/password/forgot [post]
+func (c controller) HandleForgotPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if err := c.service.RequestPasswordReset(ctx, req.Email); err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// HandleResetPassword godoc
+//
+//	@Summary		Reset password
+//	@Description	Sets a new password using token from the reset mail, every session of the account is ended
+//	@Tags			account
+//	@Accept			json
+//	@Param			reset	body	ResetPasswordDTO	true	"token and the new password"
+//	@Success		204
+//	@Failure		500	{object}	common.APIError
+//	@Failure		400	{object}	common.APIError
+//	@Router			/api/account/password/reset [post]
+func (c controller) HandleResetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if err := c.service.ResetPassword(ctx, req.Token, req.Password); err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	c.clearSession(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleUsers godoc
+//
+//	@Summary		List user accounts
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{array}		UserDTO
+//	@Failure		500	{object}	common.APIError
+//	@Failure		401	{object}	common.APIError
+//	@Failure		403	{object}	common.APIError
+//	@Router			/api/admin/users [get]
+func (c controller) HandleUsers(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	resp, err := c.service.List(ctx)
+	if err != nil {
+		e := common.ToAPIErr(*err)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	dtos := make([]UserDTO, len(resp))
+	for i, u := range resp {
+		dtos[i] = AsDTO(u)
+	}
+
+	json, _ := json.Marshal(dtos)
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
+
+// HandleUserRole godoc
+//
+//	@Summary		Change role of a user
+//	@Description	Role takes effect with the next request of the user, sessions are kept
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"id of the user"
+//	@Param			role	body		RoleDTO	true	"new role"
+//	@Success		200		{object}	UserDTO
+//	@Failure		500		{object}	common.APIError
+//	@Failure		400		{object}	common.APIError
+//	@Failure		401		{object}	common.APIError
+//	@Failure		403		{object}	common.APIError
+//	@Failure		404		{object}	common.APIError
+//	@Router			/api/admin/users/{id}/role [put]
+func (c controller) HandleUserRole(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErr("request parameter 'id' must be a number", http.StatusBadRequest, w)
+		return
+	}
+
+	var req RoleDTO
+	if !decode(w, r, &req) {
+		return
+	}
+
+	resp, e := c.service.SetRole(ctx, id, authz.Role(req.Role))
+	if e != nil {
+		e := common.ToAPIErr(*e)
+		writeErr(e.Message, e.Status, w)
+		return
+	}
+
+	json, _ := json.Marshal(AsDTO(*resp))
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s", string(json[:]))
+}
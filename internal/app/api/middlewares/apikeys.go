@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"fmt"
+	"kbswitch/internal/core/apikeys"
+	"kbswitch/internal/core/apikeys/models"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"net/http"
+	"strings"
+)
+
+const (
+	HeaderKeyAPIKey = "X-API-Key"
+	// apiKeyScheme is the Authorization header alternative to X-API-Key
+	apiKeyScheme = "ApiKey"
+)
+
+// scopeRoles tells which role a key acts with, the widest scope of the key wins
+var scopeRoles = map[models.Scope]authz.Role{
+	models.ScopeRead:  authz.RoleViewer,
+	models.ScopeWrite: authz.RoleModerator,
+	models.ScopeAdmin: authz.RoleAdmin,
+}
+
+// APIKeyAuthenticator identifies callers by api key given in X-API-Key or Authorization header
+func APIKeyAuthenticator(keys apikeys.Service) Authenticator {
+	return apiKeyAuthenticator{keys: keys}
+}
+
+type apiKeyAuthenticator struct {
+	keys apikeys.Service
+}
+
+// Authenticate implements Authenticator.
+func (a apiKeyAuthenticator) Authenticate(r *http.Request) (*authz.Principal, *common.AppError) {
+	secret := apiKeySecret(r)
+	if secret == "" {
+		return nil, nil
+	}
+
+	key, err := a.keys.Authenticate(r.Context(), secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var role authz.Role
+	for i := len(models.Scopes) - 1; i >= 0; i-- {
+		if key.Allows(models.Scopes[i]) {
+			role = scopeRoles[models.Scopes[i]]
+			break
+		}
+	}
+
+	return &authz.Principal{
+		Subject: fmt.Sprintf("key:%d", key.ID),
+		Name:    key.Name,
+		Role:    role,
+	}, nil
+}
+
+func apiKeySecret(r *http.Request) string {
+	if secret := r.Header.Get(HeaderKeyAPIKey); secret != "" {
+		return secret
+	}
+
+	scheme, secret, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, apiKeyScheme) {
+		return strings.TrimSpace(secret)
+	}
+	return ""
+}
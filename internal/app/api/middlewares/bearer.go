@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/tokens"
+	"net/http"
+	"strings"
+)
+
+const bearerScheme = "Bearer"
+
+// Bearer verifies access token of Authorization header and puts its claims into request context.
+// Requests without a token pass through, invalid tokens get 401
+func Bearer(service tokens.Service) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !ok || !strings.EqualFold(scheme, bearerScheme) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			claims, err := service.Verify(r.Context(), strings.TrimSpace(token))
+			if err != nil {
+				e := common.ToAPIErr(*err)
+				w.Header().Set("WWW-Authenticate", bearerScheme+` error="invalid_token"`)
+				writeAPIErr(w, e.Status, e.Message)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(tokens.WithClaims(r.Context(), *claims)))
+		})
+	}
+}
+
+// BearerAuthenticator identifies callers by claims Bearer middleware verified, so it must come after it
+func BearerAuthenticator() Authenticator {
+	return bearerAuthenticator{}
+}
+
+type bearerAuthenticator struct{}
+
+// Authenticate implements Authenticator.
+func (bearerAuthenticator) Authenticate(r *http.Request) (*authz.Principal, *common.AppError) {
+	claims, ok := tokens.FromContext(r.Context())
+	if !ok {
+		return nil, nil
+	}
+
+	return &authz.Principal{
+		Subject: claims.Subject,
+		Name:    claims.Name,
+		Role:    authz.Role(claims.Role),
+	}, nil
+}
@@ -0,0 +1,197 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/common/logger"
+	"kbswitch/internal/core/idempotency"
+	"kbswitch/internal/core/idempotency/models"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	HeaderKeyIdempotencyKey = "Idempotency-Key"
+	HeaderKeyReplayed       = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// bodies above this size are not buffered for hashing and are rejected instead
+	maxIdempotentBodySize = 10 << 20
+	// storeTimeout bounds releasing a key and storing a response, which outlive the request context
+	storeTimeout = 5 * time.Second
+)
+
+// Idempotency replays the first response given to a request carrying Idempotency-Key header
+// for every retry of it within ttl. Keys are scoped per client, reusing a key with a different
+// request is rejected with 422. Server errors are not stored so such requests can be retried
+func Idempotency(store idempotency.Store, ttl time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderKeyIdempotencyKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				writeAPIErr(w, http.StatusBadRequest,
+					fmt.Sprintf("header '%s' must not be longer than %d characters", HeaderKeyIdempotencyKey, maxIdempotencyKeyLength))
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+			r.Body.Close()
+			if err != nil {
+				writeAPIErr(w, http.StatusRequestEntityTooLarge, "request body is too large to be used with "+HeaderKeyIdempotencyKey)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := r.Context()
+			client := ClientID(r)
+			fingerprint := requestFingerprint(r, body)
+
+			record, err := store.Begin(ctx, client, key, fingerprint, ttl)
+			if err != nil {
+				logger.Error("could not claim idempotency key: " + err.Error())
+				writeAPIErr(w, http.StatusInternalServerError, "could not process "+HeaderKeyIdempotencyKey)
+				return
+			}
+
+			if record != nil {
+				replay(w, *record, fingerprint)
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				// the request may have timed out or its client gone away meanwhile, the key must not stay
+				// claimed without a response until it expires anyway, retries would get 409 until then
+				ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+				defer cancel()
+
+				// a panicking or failing handler must not leave the key claimed until it expires
+				if p := recover(); p != nil {
+					store.Release(ctx, client, key)
+					panic(p)
+				}
+
+				if rec.status >= http.StatusInternalServerError {
+					if err := store.Release(ctx, client, key); err != nil {
+						logger.Error("could not release idempotency key: " + err.Error())
+					}
+					return
+				}
+
+				if !rec.wroteHeader {
+					rec.header = w.Header().Clone()
+				}
+
+				err := store.Complete(ctx, client, key, models.Response{
+					Status: rec.status,
+					Header: rec.header,
+					Body:   rec.body.Bytes(),
+				})
+				if err != nil {
+					logger.Error("could not store idempotent response: " + err.Error())
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, record models.Record, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		writeAPIErr(w, http.StatusUnprocessableEntity,
+			fmt.Sprintf("%s was already used with a different request", HeaderKeyIdempotencyKey))
+		return
+	}
+	if record.Response == nil {
+		writeAPIErr(w, http.StatusConflict,
+			fmt.Sprintf("request with this %s is still being processed", HeaderKeyIdempotencyKey))
+		return
+	}
+
+	// headers of this very request, like its request id, are kept
+	for k, v := range record.Response.Header {
+		if _, ok := w.Header()[k]; !ok {
+			w.Header()[k] = v
+		}
+	}
+	w.Header().Set(HeaderKeyReplayed, "true")
+	w.WriteHeader(record.Response.Status)
+	w.Write(record.Response.Body)
+}
+
+// ClientID identifies the caller idempotency keys are scoped to,
+// identified callers own their keys wherever requests come from.
+// Anonymous ones are told apart by address, RealIP has to resolve it behind proxies
+func ClientID(r *http.Request) string {
+	if p := authz.FromContext(r.Context()); !p.Anonymous() {
+		return p.Subject
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", r.Method, r.URL.RequestURI())
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeAPIErr(w http.ResponseWriter, status int, msg string) {
+	e := common.APIError{
+		Status:  status,
+		Message: msg,
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=utf8")
+	w.WriteHeader(status)
+	fmt.Fprint(w, e)
+}
+
+// responseRecorder passes response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = statusCode
+	rec.header = rec.ResponseWriter.Header().Clone()
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach Flush of the underlying writer
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
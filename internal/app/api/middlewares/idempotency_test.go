@@ -0,0 +1,238 @@
+package middlewares_test
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/app/api/middlewares"
+	"kbswitch/internal/core/idempotency/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	records map[string]*models.Record
+}
+
+func (f *fakeStore) Begin(ctx context.Context, client, key, fingerprint string, ttl time.Duration) (*models.Record, error) {
+	if r, ok := f.records[client+"/"+key]; ok {
+		return r, nil
+	}
+	f.records[client+"/"+key] = &models.Record{Fingerprint: fingerprint, ExpiresAt: time.Now().Add(ttl)}
+	return nil, nil
+}
+
+func (f *fakeStore) Complete(ctx context.Context, client, key string, resp models.Response) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.records[client+"/"+key].Response = &resp
+	return nil
+}
+
+func (f *fakeStore) Release(ctx context.Context, client, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	delete(f.records, client+"/"+key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	type request struct {
+		key    string
+		body   string
+		remote string
+	}
+
+	tcases := []struct {
+		name     string
+		requests []request
+		// status handler responds with, handler is called once per request which is not replayed
+		status   int
+		expected struct {
+			calls    int
+			statuses []int
+			replayed []bool
+		}
+	}{
+		{
+			name:     "retry is replayed",
+			requests: []request{{key: "k", body: "a"}, {key: "k", body: "a"}},
+			status:   http.StatusCreated,
+			expected: struct {
+				calls    int
+				statuses []int
+				replayed []bool
+			}{
+				calls:    1,
+				statuses: []int{http.StatusCreated, http.StatusCreated},
+				replayed: []bool{false, true},
+			},
+		},
+		{
+			name:     "no key passes through",
+			requests: []request{{body: "a"}, {body: "a"}},
+			status:   http.StatusCreated,
+			expected: struct {
+				calls    int
+				statuses []int
+				replayed []bool
+			}{
+				calls:    2,
+				statuses: []int{http.StatusCreated, http.StatusCreated},
+				replayed: []bool{false, false},
+			},
+		},
+		{
+			name:     "reused key with different body",
+			requests: []request{{key: "k", body: "a"}, {key: "k", body: "b"}},
+			status:   http.StatusCreated,
+			expected: struct {
+				calls    int
+				statuses []int
+				replayed []bool
+			}{
+				calls:    1,
+				statuses: []int{http.StatusCreated, http.StatusUnprocessableEntity},
+				replayed: []bool{false, false},
+			},
+		},
+		{
+			name:     "keys are scoped per client",
+			requests: []request{{key: "k", body: "a"}, {key: "k", body: "b", remote: "10.0.0.2:1234"}},
+			status:   http.StatusCreated,
+			expected: struct {
+				calls    int
+				statuses []int
+				replayed []bool
+			}{
+				calls:    2,
+				statuses: []int{http.StatusCreated, http.StatusCreated},
+				replayed: []bool{false, false},
+			},
+		},
+		{
+			name:     "server errors are not stored",
+			requests: []request{{key: "k", body: "a"}, {key: "k", body: "a"}},
+			status:   http.StatusInternalServerError,
+			expected: struct {
+				calls    int
+				statuses []int
+				replayed []bool
+			}{
+				calls:    2,
+				statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError},
+				replayed: []bool{false, false},
+			},
+		},
+		{
+			name:     "too long key",
+			requests: []request{{key: strings.Repeat("k", 256), body: "a"}},
+			status:   http.StatusCreated,
+			expected: struct {
+				calls    int
+				statuses []int
+				replayed []bool
+			}{
+				calls:    0,
+				statuses: []int{http.StatusBadRequest},
+				replayed: []bool{false},
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		calls := 0
+		handler := middlewares.Idempotency(&fakeStore{records: map[string]*models.Record{}}, time.Hour)(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Location", fmt.Sprintf("/created/%d", calls))
+				w.WriteHeader(tc.status)
+				fmt.Fprintf(w, "call %d", calls)
+			}))
+
+		var first string
+		for i, req := range tc.requests {
+			r := httptest.NewRequest(http.MethodPost, "/api/switches", strings.NewReader(req.body))
+			if req.key != "" {
+				r.Header.Set(middlewares.HeaderKeyIdempotencyKey, req.key)
+			}
+			if req.remote != "" {
+				r.RemoteAddr = req.remote
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if tc.expected.statuses[i] != w.Code {
+				t.Errorf("%s: request %d status failed\nexpected %v\ngot %v", tc.name, i, tc.expected.statuses[i], w.Code)
+			}
+			replayed := w.Header().Get(middlewares.HeaderKeyReplayed) == "true"
+			if tc.expected.replayed[i] != replayed {
+				t.Errorf("%s: request %d replay failed\nexpected %v\ngot %v", tc.name, i, tc.expected.replayed[i], replayed)
+			}
+			if i == 0 {
+				first = w.Body.String() + w.Header().Get("Location")
+			} else if replayed && first != w.Body.String()+w.Header().Get("Location") {
+				t.Errorf("%s: replayed response differs\nexpected %v\ngot %v", tc.name, first, w.Body.String()+w.Header().Get("Location"))
+			}
+		}
+
+		if tc.expected.calls != calls {
+			t.Errorf("%s: handler calls failed\nexpected %v\ngot %v", tc.name, tc.expected.calls, calls)
+		}
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	store := &fakeStore{records: map[string]*models.Record{}}
+	var inner *httptest.ResponseRecorder
+
+	var handler http.Handler
+	handler = middlewares.Idempotency(store, time.Hour)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// retry arrives while the first request is still being handled
+			inner = httptest.NewRecorder()
+			retry := httptest.NewRequest(http.MethodPost, "/api/switches", strings.NewReader("a"))
+			retry.Header.Set(middlewares.HeaderKeyIdempotencyKey, "k")
+			handler.ServeHTTP(inner, retry)
+
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/switches", strings.NewReader("a"))
+	r.Header.Set(middlewares.HeaderKeyIdempotencyKey, "k")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if inner.Code != http.StatusConflict {
+		t.Errorf("in progress retry status failed\nexpected %v\ngot %v", http.StatusConflict, inner.Code)
+	}
+}
+
+func TestIdempotencyCanceled(t *testing.T) {
+	store := &fakeStore{records: map[string]*models.Record{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	h := middlewares.Idempotency(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// the client gives up while the request is handled
+		cancel()
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	rq := httptest.NewRequest(http.MethodPost, "/api/switches/", strings.NewReader("a")).WithContext(ctx)
+	rq.Header.Set(middlewares.HeaderKeyIdempotencyKey, "k")
+	h.ServeHTTP(httptest.NewRecorder(), rq)
+
+	rq = httptest.NewRequest(http.MethodPost, "/api/switches/", strings.NewReader("a"))
+	rq.Header.Set(middlewares.HeaderKeyIdempotencyKey, "k")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, rq)
+
+	if calls != 1 || w.Code != http.StatusCreated || w.Header().Get(middlewares.HeaderKeyReplayed) != "true" {
+		t.Errorf("expected the retry to be replayed, got %d calls and %v", calls, w.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package middlewares
+
+import (
+	"fmt"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/users"
+	"net/http"
+)
+
+// Authenticator identifies caller of a request. Nil principal and error mean request carries
+// no credentials the authenticator knows of, an error means it carries them but they are not valid
+type Authenticator interface {
+	Authenticate(r *http.Request) (*authz.Principal, *common.AppError)
+}
+
+// Identify puts principal of the first authenticator recognizing the request into its context,
+// requests nobody recognizes act as authz.Anonymous. Invalid credentials are refused with 401
+// rather than treated as anonymous, so a client notices its key was revoked
+func Identify(authenticators ...Authenticator) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, a := range authenticators {
+				p, err := a.Authenticate(r)
+				if err != nil {
+					e := common.ToAPIErr(*err)
+					if e.Status == http.StatusUnauthorized {
+						w.Header().Set("WWW-Authenticate", apiKeyScheme)
+					}
+					writeAPIErr(w, e.Status, e.Message)
+					return
+				}
+				if p != nil {
+					r = r.WithContext(authz.WithPrincipal(r.Context(), *p))
+					break
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission refuses requests whose principal policy does not grant perm,
+// anonymous callers get 401 so they know credentials are needed, everybody else gets 403
+func RequirePermission(policy authz.Policy, perm authz.Permission) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := authz.FromContext(r.Context())
+			if policy.Allows(p, perm) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if p.Anonymous() {
+				w.Header().Set("WWW-Authenticate", apiKeyScheme)
+				writeAPIErr(w, http.StatusUnauthorized, fmt.Sprintf("authentication required for permission '%s'", perm))
+				return
+			}
+			e := common.ToAPIErr(*authz.Forbidden(perm))
+			writeAPIErr(w, e.Status, e.Message)
+		})
+	}
+}
+
+// SessionAuthenticator identifies callers by the user Session middleware found, so it must come after it
+func SessionAuthenticator() Authenticator {
+	return sessionAuthenticator{}
+}
+
+type sessionAuthenticator struct{}
+
+// Authenticate implements Authenticator.
+func (sessionAuthenticator) Authenticate(r *http.Request) (*authz.Principal, *common.AppError) {
+	user, ok := users.FromContext(r.Context())
+	if !ok {
+		return nil, nil
+	}
+
+	return &authz.Principal{
+		Subject: fmt.Sprintf("user:%d", user.ID),
+		Name:    user.Email,
+		Role:    user.Role,
+	}, nil
+}
@@ -0,0 +1,160 @@
+package middlewares_test
+
+import (
+	"context"
+	"kbswitch/internal/app/api/middlewares"
+	"kbswitch/internal/core/apikeys"
+	"kbswitch/internal/core/apikeys/models"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/users"
+	usermodels "kbswitch/internal/core/users/models"
+	authzpolicy "kbswitch/internal/pkg/authz"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeKeys knows secrets "reader" and "writer", every other one is refused
+type fakeKeys struct {
+	apikeys.Service
+}
+
+func (fakeKeys) Authenticate(ctx context.Context, secret string) (*models.Key, *common.AppError) {
+	switch secret {
+	case "reader":
+		return &models.Key{ID: 1, Scopes: []models.Scope{models.ScopeRead}}, nil
+	case "writer":
+		return &models.Key{ID: 2, Scopes: []models.Scope{models.ScopeRead, models.ScopeWrite}}, nil
+	}
+	e := common.NewError(common.ErrUnauthorized, "api key is invalid or revoked")
+	return nil, &e
+}
+
+func TestIdentify(t *testing.T) {
+	tcases := []struct {
+		name     string
+		header   http.Header
+		user     *usermodels.User
+		perm     authz.Permission
+		expected struct {
+			status  int
+			client  string
+			message string
+		}
+	}{
+		{
+			name:   "write key through header",
+			header: http.Header{"X-Api-Key": {"writer"}},
+			perm:   authz.PermSwitchesDelete,
+			expected: struct {
+				status  int
+				client  string
+				message string
+			}{status: http.StatusOK, client: "key:2"},
+		},
+		{
+			name:   "write key through authorization",
+			header: http.Header{"Authorization": {"ApiKey writer"}},
+			perm:   authz.PermSwitchesEdit,
+			expected: struct {
+				status  int
+				client  string
+				message string
+			}{status: http.StatusOK, client: "key:2"},
+		},
+		{
+			name:   "read key",
+			header: http.Header{"X-Api-Key": {"reader"}},
+			perm:   authz.PermSwitchesEdit,
+			expected: struct {
+				status  int
+				client  string
+				message string
+			}{status: http.StatusForbidden, message: `{"status":403,"message":"missing permission 'switches:edit'"}`},
+		},
+		{
+			name:   "unknown key",
+			header: http.Header{"X-Api-Key": {"nobody"}},
+			perm:   authz.PermSwitchesRead,
+			expected: struct {
+				status  int
+				client  string
+				message string
+			}{status: http.StatusUnauthorized},
+		},
+		{
+			name: "anonymous can read",
+			perm: authz.PermSwitchesRead,
+			expected: struct {
+				status  int
+				client  string
+				message string
+			}{status: http.StatusOK, client: "192.0.2.1"},
+		},
+		{
+			name:   "anonymous can't write",
+			header: http.Header{"Authorization": {"Bearer writer"}},
+			perm:   authz.PermSwitchesCreate,
+			expected: struct {
+				status  int
+				client  string
+				message string
+			}{status: http.StatusUnauthorized},
+		},
+		{
+			name: "contributor can propose",
+			user: &usermodels.User{ID: 5, Role: authz.RoleContributor},
+			perm: authz.PermSwitchesPropose,
+			expected: struct {
+				status  int
+				client  string
+				message string
+			}{status: http.StatusOK, client: "user:5"},
+		},
+		{
+			name: "moderator can't manage keys",
+			user: &usermodels.User{ID: 6, Role: authz.RoleModerator},
+			perm: authz.PermKeysManage,
+			expected: struct {
+				status  int
+				client  string
+				message string
+			}{status: http.StatusForbidden, message: `{"status":403,"message":"missing permission 'keys:manage'"}`},
+		},
+	}
+
+	policy := authzpolicy.NewRolePolicy()
+
+	for _, tc := range tcases {
+		var client string
+		h := middlewares.Identify(middlewares.APIKeyAuthenticator(fakeKeys{}), middlewares.SessionAuthenticator())(
+			middlewares.RequirePermission(policy, tc.perm)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				client = middlewares.ClientID(r)
+			})),
+		)
+
+		w := httptest.NewRecorder()
+		rq := httptest.NewRequest(http.MethodPost, "/api/switches/", nil)
+		for k, v := range tc.header {
+			rq.Header[k] = v
+		}
+		if tc.user != nil {
+			rq = rq.WithContext(users.WithUser(rq.Context(), *tc.user))
+		}
+		h.ServeHTTP(w, rq)
+
+		if w.Code != tc.expected.status {
+			t.Errorf("%s: status failed\nexpected %v\ngot  %v", tc.name, tc.expected.status, w.Code)
+		}
+		if client != tc.expected.client {
+			t.Errorf("%s: client failed\nexpected %v\ngot  %v", tc.name, tc.expected.client, client)
+		}
+		if tc.expected.message != "" && w.Body.String() != tc.expected.message {
+			t.Errorf("%s: body failed\nexpected %v\ngot  %v", tc.name, tc.expected.message, w.Body.String())
+		}
+		if tc.expected.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: WWW-Authenticate header is missing", tc.name)
+		}
+	}
+}
@@ -3,16 +3,28 @@ package middlewares
 import (
 	"kbswitch/internal/core/common/logger"
 	"net/http"
+	"strings"
 )
 
-func LogHttpCycle(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		rww := logger.NewResponseWriterWrapper(w)
-		defer func() {
-			msg := logger.GetRequestResponseLog(rww, r)
-			logger.Trace(msg)
-		}()
+// LogHttpCycle logs every request together with its response. Bodies of requests under
+// redacted path prefixes are left out, they carry passwords, tokens or keys
+func LogHttpCycle(redacted ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			withBodies := true
+			for _, prefix := range redacted {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					withBodies = false
+				}
+			}
 
-		next.ServeHTTP(rww, r)
-	})
+			rww := logger.NewResponseWriterWrapper(w)
+			defer func() {
+				msg := logger.GetRequestResponseLog(rww, r, withBodies)
+				logger.Trace(msg)
+			}()
+
+			next.ServeHTTP(rww, r)
+		})
+	}
 }
@@ -0,0 +1,53 @@
+package middlewares_test
+
+import (
+	"fmt"
+	"kbswitch/internal/app"
+	"kbswitch/internal/app/api/middlewares"
+	"kbswitch/internal/core/common/logger"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogHttpCycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.json")
+	logger.Init(app.Application{Logging: app.Logging{LogFilePath: path}})
+
+	const secret = "kbs_0123456789abcdef"
+	const session = "c2Vzc2lvbiB0b2tlbg"
+
+	tcases := []struct {
+		name   string
+		url    string
+		logged bool
+	}{
+		{name: "created api key", url: "/api/admin/keys/"},
+		{name: "login", url: "/api/account/login"},
+		{name: "issued token", url: "/api/auth/token"},
+		{name: "switch", url: "/api/switches/", logged: true},
+	}
+
+	for _, tc := range tcases {
+		os.Truncate(path, 0)
+
+		h := middlewares.LogHttpCycle("/api/admin/keys/", "/api/account/", "/api/auth/")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: session})
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"secret":"%s"}`, secret)
+		}))
+		rq := httptest.NewRequest(http.MethodPost, tc.url, strings.NewReader(`{"name":"ci"}`))
+		h.ServeHTTP(httptest.NewRecorder(), rq)
+
+		line, _ := os.ReadFile(path)
+		if strings.Contains(string(line), secret) != tc.logged {
+			t.Errorf("%s: expected body to be logged %v, got %s", tc.name, tc.logged, line)
+		}
+		if strings.Contains(string(line), session) {
+			t.Errorf("%s: expected session cookie to be redacted, got %s", tc.name, line)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const HeaderKeyForwardedFor = "X-Forwarded-For"
+
+// RealIP sets RemoteAddr of requests coming through one of trusted proxies to the client address
+// they forwarded, behind a proxy every client would share its address otherwise. X-Forwarded-For is
+// read from the right, addresses appended by trusted proxies are skipped, the first other one is the client
+func RealIP(trusted []netip.Prefix) func(next http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, p := range trusted {
+			if p.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer, err := netip.ParseAddrPort(r.RemoteAddr)
+			if err != nil || !isTrusted(peer.Addr()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			hops := strings.Split(strings.Join(r.Header.Values(HeaderKeyForwardedFor), ","), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+				if err != nil {
+					break
+				}
+				if !isTrusted(addr) {
+					r.RemoteAddr = net.JoinHostPort(addr.String(), "0")
+					break
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package middlewares_test
+
+import (
+	"kbswitch/internal/app/api/middlewares"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tcases := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		expected  string
+	}{
+		{name: "direct", remote: "203.0.113.7:5123", expected: "203.0.113.7"},
+		{name: "untrusted peer can't forward", remote: "203.0.113.7:5123", forwarded: []string{"198.51.100.1"}, expected: "203.0.113.7"},
+		{name: "through proxy", remote: "10.0.0.2:5123", forwarded: []string{"198.51.100.1"}, expected: "198.51.100.1"},
+		{name: "through proxies", remote: "10.0.0.2:5123", forwarded: []string{"192.0.2.9, 198.51.100.1", "10.1.1.1"}, expected: "198.51.100.1"},
+		{name: "proxy without header", remote: "10.0.0.2:5123", expected: "10.0.0.2"},
+		{name: "garbage", remote: "10.0.0.2:5123", forwarded: []string{"unknown"}, expected: "10.0.0.2"},
+	}
+
+	for _, tc := range tcases {
+		var client string
+		h := middlewares.RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client = middlewares.ClientID(r)
+		}))
+
+		rq := httptest.NewRequest(http.MethodPost, "/api/switches/", nil)
+		rq.RemoteAddr = tc.remote
+		for _, v := range tc.forwarded {
+			rq.Header.Add(middlewares.HeaderKeyForwardedFor, v)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), rq)
+
+		if client != tc.expected {
+			t.Errorf("%s: client failed\nexpected %v\ngot  %v", tc.name, tc.expected, client)
+		}
+	}
+}
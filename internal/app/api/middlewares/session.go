@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"kbswitch/internal/core/users"
+	"net/http"
+)
+
+const SessionCookie = "kbswitch_session"
+
+// Session puts user of the session cookie into request context. Requests without a valid session
+// pass through anonymously and a stale cookie is cleared, routes decide themselves whether they need a user
+func Session(accounts users.Service) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cookie, err := r.Cookie(SessionCookie)
+			if err != nil || cookie.Value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, e := accounts.Authenticate(r.Context(), cookie.Value)
+			if e != nil {
+				http.SetCookie(w, &http.Cookie{Name: SessionCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(users.WithUser(r.Context(), *user)))
+		})
+	}
+}
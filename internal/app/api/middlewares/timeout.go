@@ -7,13 +7,24 @@ import (
 	"kbswitch/internal/core/common"
 	"kbswitch/internal/core/common/middleware/models"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
+type timeoutKey struct{}
+
+// timeoutState lets a streamed route take its request out of Timeout
+type timeoutState struct {
+	// parent is the request context before Timeout set its deadline
+	parent    context.Context
+	streaming atomic.Bool
+}
+
 func Timeout(t int) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			ctx, cancel := context.WithTimeout(r.Context(), time.Duration(t)*time.Second)
+			state := &timeoutState{parent: r.Context()}
+			ctx, cancel := context.WithTimeout(context.WithValue(r.Context(), timeoutKey{}, state), time.Duration(t)*time.Second)
 
 			r = r.WithContext(ctx)
 			rw := &models.ResponseWriterWithTimeout{ResponseWriter: w}
@@ -29,6 +40,12 @@ func Timeout(t int) func(next http.Handler) http.Handler {
 			case <-ctx.Done():
 				cancel()
 
+				if state.streaming.Load() {
+					// the stream has a deadline of its own and its response is under way already
+					<-done
+					return
+				}
+
 				// timeout occured
 				if ctx.Err() == context.DeadlineExceeded {
 					rw.WriteHeader(http.StatusGatewayTimeout)
@@ -51,3 +68,25 @@ func Timeout(t int) func(next http.Handler) http.Handler {
 		return http.HandlerFunc(fn)
 	}
 }
+
+// Stream gives a streamed response deadline d instead of the one set by Timeout. Once the body is flowing
+// a timeout can't be reported anymore without corrupting it, so the stream just ends when d is up.
+func Stream(d time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parent := r.Context()
+			if state, ok := r.Context().Value(timeoutKey{}).(*timeoutState); ok {
+				state.streaming.Store(true)
+				parent = state.parent
+			}
+
+			// the stream is still canceled when the client goes away
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), d)
+			defer cancel()
+			stop := context.AfterFunc(parent, cancel)
+			defer stop()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
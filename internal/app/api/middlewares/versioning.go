@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"fmt"
+	"kbswitch/internal/app/api/versioning"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	HeaderKeyAcceptVersion = "Accept-Version"
+	HeaderKeyAPIVersion    = "API-Version"
+)
+
+// APIVersion puts api version into request context. Routes mounted under a version prefix pass it as fixed,
+// unversioned routes pass 0 and let clients choose with Accept-Version header.
+// Responses of deprecated v1 carry Deprecation, Sunset and successor Link headers
+func APIVersion(fixed versioning.Version) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			version := fixed
+			if version == 0 {
+				w.Header().Add("Vary", HeaderKeyAcceptVersion)
+
+				v, err := parseVersion(r.Header.Get(HeaderKeyAcceptVersion))
+				if err != nil {
+					writeAPIErr(w, http.StatusBadRequest, err.Error())
+					return
+				}
+				version = v
+			}
+
+			w.Header().Set(HeaderKeyAPIVersion, strconv.Itoa(int(version)))
+			if version == versioning.V1 {
+				w.Header().Set("Deprecation", fmt.Sprintf("@%d", versioning.V1DeprecatedAt.Unix()))
+				w.Header().Set("Sunset", versioning.V1Sunset.Format(http.TimeFormat))
+				w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successorPath(r.URL.Path)))
+			}
+
+			r = r.WithContext(versioning.WithVersion(r.Context(), version))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func parseVersion(header string) (versioning.Version, error) {
+	if header == "" {
+		return versioning.Default, nil
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(header)), "v"))
+	if err != nil || n < int(versioning.V1) || n > int(versioning.Latest) {
+		return 0, fmt.Errorf("header '%s' must be one of 1 or 2", HeaderKeyAcceptVersion)
+	}
+	return versioning.Version(n), nil
+}
+
+// successorPath maps /api/x and /api/v1/x to /api/v2/x
+func successorPath(path string) string {
+	rest := strings.TrimPrefix(path, "/api/")
+	rest = strings.TrimPrefix(rest, "v1/")
+	return fmt.Sprintf("/api/v%d/%s", versioning.Latest, rest)
+}
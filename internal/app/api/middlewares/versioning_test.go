@@ -0,0 +1,111 @@
+package middlewares_test
+
+import (
+	"kbswitch/internal/app/api/middlewares"
+	"kbswitch/internal/app/api/versioning"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIVersion(t *testing.T) {
+	tcases := []struct {
+		name     string
+		fixed    versioning.Version
+		path     string
+		header   string
+		expected struct {
+			status     int
+			version    versioning.Version
+			deprecated bool
+			link       string
+		}
+	}{
+		{
+			name: "unversioned defaults to v1",
+			path: "/api/switches/",
+			expected: struct {
+				status     int
+				version    versioning.Version
+				deprecated bool
+				link       string
+			}{
+				status:     http.StatusOK,
+				version:    versioning.V1,
+				deprecated: true,
+				link:       `</api/v2/switches/>; rel="successor-version"`,
+			},
+		},
+		{
+			name:   "header picks v2",
+			path:   "/api/switches/Gateron/Yellow",
+			header: "v2",
+			expected: struct {
+				status     int
+				version    versioning.Version
+				deprecated bool
+				link       string
+			}{
+				status:  http.StatusOK,
+				version: versioning.V2,
+			},
+		},
+		{
+			name:   "prefix wins over header",
+			fixed:  versioning.V1,
+			path:   "/api/v1/switches/Gateron/Yellow",
+			header: "2",
+			expected: struct {
+				status     int
+				version    versioning.Version
+				deprecated bool
+				link       string
+			}{
+				status:     http.StatusOK,
+				version:    versioning.V1,
+				deprecated: true,
+				link:       `</api/v2/switches/Gateron/Yellow>; rel="successor-version"`,
+			},
+		},
+		{
+			name:   "unknown version",
+			path:   "/api/switches/",
+			header: "3",
+			expected: struct {
+				status     int
+				version    versioning.Version
+				deprecated bool
+				link       string
+			}{
+				status: http.StatusBadRequest,
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		var got versioning.Version
+		h := middlewares.APIVersion(tc.fixed)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = versioning.FromContext(r.Context())
+		}))
+
+		w := httptest.NewRecorder()
+		rq := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.header != "" {
+			rq.Header.Set(middlewares.HeaderKeyAcceptVersion, tc.header)
+		}
+		h.ServeHTTP(w, rq)
+
+		if w.Code != tc.expected.status {
+			t.Errorf("%s: status failed\nexpected %v\ngot  %v", tc.name, tc.expected.status, w.Code)
+		}
+		if got != tc.expected.version {
+			t.Errorf("%s: version failed\nexpected %v\ngot  %v", tc.name, tc.expected.version, got)
+		}
+		if deprecated := w.Header().Get("Deprecation") != "" && w.Header().Get("Sunset") != ""; deprecated != tc.expected.deprecated {
+			t.Errorf("%s: deprecation headers failed\nexpected %v\ngot  %v", tc.name, tc.expected.deprecated, w.Header())
+		}
+		if link := w.Header().Get("Link"); link != tc.expected.link {
+			t.Errorf("%s: link failed\nexpected %v\ngot  %v", tc.name, tc.expected.link, link)
+		}
+	}
+}
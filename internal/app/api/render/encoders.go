@@ -0,0 +1,214 @@
+package render
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"kbswitch/internal/core/common"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type JSON struct{}
+
+func (JSON) ContentType() string {
+	return "application/json;charset=utf8"
+}
+
+func (JSON) Encode(w io.Writer, v any) error {
+	json, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(json)
+	return err
+}
+
+type YAML struct{}
+
+func (YAML) ContentType() string {
+	return "application/yaml; charset=utf-8"
+}
+
+func (YAML) Encode(w io.Writer, v any) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// XML wraps slices into <items> root element, as xml document can not have several roots
+type XML struct{}
+
+func (XML) ContentType() string {
+	return "application/xml; charset=utf-8"
+}
+
+func (XML) Encode(w io.Writer, v any) error {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+		v = struct {
+			XMLName xml.Name `xml:"items"`
+			Items   any
+		}{Items: v}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// CSV renders a flat struct or a slice of them, columns are named after json tags
+type CSV struct{}
+
+func (CSV) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (CSV) Encode(w io.Writer, v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+
+	var rows []reflect.Value
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := range rv.Len() {
+			rows = append(rows, reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		rows = append(rows, rv)
+	default:
+		return fmt.Errorf("csv can not encode %s", rv.Kind())
+	}
+
+	t := rv.Type()
+	if rv.Kind() != reflect.Struct {
+		t = t.Elem()
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+	}
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("csv can not encode rows of %s", t.Kind())
+	}
+
+	columns := csvColumns(t, "", false)
+
+	// like json, omitempty fields are left out, here only when they are empty in every row
+	kept := columns[:0]
+	for _, c := range columns {
+		if !c.omitempty || slices.ContainsFunc(rows, func(row reflect.Value) bool { return c.value(row) != "" }) {
+			kept = append(kept, c)
+		}
+	}
+	columns = kept
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.name
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, c := range columns {
+			record[i] = c.value(row)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+type csvColumn struct {
+	name      string
+	path      []int
+	omitempty bool
+}
+
+// value walks nested structs down to the column, missing nested struct gives an empty cell
+func (c csvColumn) value(row reflect.Value) string {
+	v := row
+	for _, i := range c.path {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return ""
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+var textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// csvColumns flattens nested structs into columns named "parent.child"
+func csvColumns(t reflect.Type, prefix string, omitempty bool) []csvColumn {
+	var columns []csvColumn
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() || f.Type == reflect.TypeOf(xml.Name{}) {
+			continue
+		}
+
+		name := f.Name
+		omit := omitempty
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, opts, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+			omit = omit || slices.Contains(strings.Split(opts, ","), "omitempty")
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && !reflect.PointerTo(ft).Implements(textMarshaler) {
+			for _, c := range csvColumns(ft, prefix+name+".", omit) {
+				columns = append(columns, csvColumn{name: c.name, path: append([]int{i}, c.path...), omitempty: c.omitempty})
+			}
+			continue
+		}
+
+		columns = append(columns, csvColumn{name: prefix + name, path: []int{i}, omitempty: omit})
+	}
+	return columns
+}
+
+// NotAcceptable answers with 406 listing media types client can ask for
+func NotAcceptable(w http.ResponseWriter, supported []string) {
+	e := common.APIError{
+		Status:  http.StatusNotAcceptable,
+		Message: "none of the accepted media types is supported, use one of: " + strings.Join(supported, ", "),
+	}
+
+	w.Header().Set("Content-Type", JSON{}.ContentType())
+	w.WriteHeader(e.Status)
+	fmt.Fprint(w, e)
+}
@@ -0,0 +1,173 @@
+package render
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encoder writes a value in a single media type
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, v any) error
+}
+
+type entry struct {
+	mediaType string
+	encoder   Encoder
+}
+
+// Registry keeps encoders in registration order, the first one is used when client accepts anything
+type Registry struct {
+	entries []entry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default returns registry with json, yaml, csv and xml encoders, json being the default one
+func Default() *Registry {
+	r := NewRegistry()
+	r.Register("application/json", JSON{})
+	r.Register("application/yaml", YAML{})
+	r.Register("application/x-yaml", YAML{})
+	r.Register("text/yaml", YAML{})
+	r.Register("text/csv", CSV{})
+	r.Register("application/xml", XML{})
+	r.Register("text/xml", XML{})
+	return r
+}
+
+// Register adds encoder for a media type, registering same media type again replaces the encoder
+func (r *Registry) Register(mediaType string, enc Encoder) {
+	mediaType = strings.ToLower(mediaType)
+	for i, e := range r.entries {
+		if e.mediaType == mediaType {
+			r.entries[i].encoder = enc
+			return
+		}
+	}
+	r.entries = append(r.entries, entry{mediaType: mediaType, encoder: enc})
+}
+
+// MediaTypes lists registered media types in registration order
+func (r *Registry) MediaTypes() []string {
+	res := make([]string, len(r.entries))
+	for i, e := range r.entries {
+		res[i] = e.mediaType
+	}
+	return res
+}
+
+type accepted struct {
+	mediaType string
+	q         float64
+	order     int
+}
+
+func (a accepted) specificity() int {
+	switch {
+	case a.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(a.mediaType, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func parseAccept(header string) []accepted {
+	var res []accepted
+	for i, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		mt, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		res = append(res, accepted{mediaType: mt, q: q, order: i})
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].q != res[j].q {
+			return res[i].q > res[j].q
+		}
+		return res[i].specificity() > res[j].specificity()
+	})
+	return res
+}
+
+func matches(pattern, mediaType string) bool {
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mediaType, prefix+"/")
+	}
+	return false
+}
+
+// Negotiate picks encoder for a value of Accept header, missing header means client accepts anything
+func (r *Registry) Negotiate(accept string) (Encoder, bool) {
+	if len(r.entries) == 0 {
+		return nil, false
+	}
+	if strings.TrimSpace(accept) == "" {
+		return r.entries[0].encoder, true
+	}
+
+	ranges := parseAccept(accept)
+	for _, a := range ranges {
+		if a.q == 0 {
+			continue
+		}
+		for _, e := range r.entries {
+			if matches(a.mediaType, e.mediaType) && !r.excluded(ranges, e.mediaType) {
+				return e.encoder, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// excluded reports whether the most specific range matching media type has q=0
+func (r *Registry) excluded(ranges []accepted, mediaType string) bool {
+	best := -1
+	q := 1.0
+	for _, a := range ranges {
+		if matches(a.mediaType, mediaType) && a.specificity() > best {
+			best = a.specificity()
+			q = a.q
+		}
+	}
+	return best >= 0 && q == 0
+}
+
+// Render negotiates encoder from request and writes v with given status.
+// When nothing acceptable is registered it responds with 406 and returns false
+func (r *Registry) Render(w http.ResponseWriter, req *http.Request, status int, v any) bool {
+	enc, ok := r.Negotiate(req.Header.Get("Accept"))
+	w.Header().Add("Vary", "Accept")
+	if !ok {
+		NotAcceptable(w, r.MediaTypes())
+		return false
+	}
+
+	w.Header().Set("Content-Type", enc.ContentType())
+	w.WriteHeader(status)
+	enc.Encode(w, v)
+	return true
+}
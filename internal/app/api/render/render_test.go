@@ -0,0 +1,85 @@
+package render_test
+
+import (
+	"kbswitch/internal/app/api/render"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tcases := []struct {
+		accept   string
+		expected struct {
+			contentType string
+			ok          bool
+		}
+	}{
+		{
+			accept: "",
+			expected: struct {
+				contentType string
+				ok          bool
+			}{contentType: render.JSON{}.ContentType(), ok: true},
+		},
+		{
+			accept: "*/*",
+			expected: struct {
+				contentType string
+				ok          bool
+			}{contentType: render.JSON{}.ContentType(), ok: true},
+		},
+		{
+			accept: "text/*",
+			expected: struct {
+				contentType string
+				ok          bool
+			}{contentType: render.YAML{}.ContentType(), ok: true},
+		},
+		{
+			accept: "application/json;q=0.5, application/xml",
+			expected: struct {
+				contentType string
+				ok          bool
+			}{contentType: render.XML{}.ContentType(), ok: true},
+		},
+		{
+			accept: "*/*;q=0.1, text/csv;q=0.8",
+			expected: struct {
+				contentType string
+				ok          bool
+			}{contentType: render.CSV{}.ContentType(), ok: true},
+		},
+		{
+			accept: "application/json;q=0, */*",
+			expected: struct {
+				contentType string
+				ok          bool
+			}{contentType: render.YAML{}.ContentType(), ok: true},
+		},
+		{
+			accept: "image/png, text/html",
+			expected: struct {
+				contentType string
+				ok          bool
+			}{ok: false},
+		},
+		{
+			accept: "application/json;q=0",
+			expected: struct {
+				contentType string
+				ok          bool
+			}{ok: false},
+		},
+	}
+
+	registry := render.Default()
+	for _, tc := range tcases {
+		enc, ok := registry.Negotiate(tc.accept)
+		if tc.expected.ok != ok {
+			t.Errorf("Negotiate(%q) failed\nexpected ok %v\ngot %v", tc.accept, tc.expected.ok, ok)
+			continue
+		}
+		if ok && tc.expected.contentType != enc.ContentType() {
+			t.Errorf("Negotiate(%q) failed\nexpected %v\ngot %v", tc.accept, tc.expected.contentType, enc.ContentType())
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package versioning
+
+import (
+	"context"
+	"time"
+)
+
+type Version int
+
+const (
+	V1 Version = 1
+	V2 Version = 2
+
+	Latest = V2
+	// Default is served on unversioned routes without Accept-Version header, kept at v1 not to break existing clients
+	Default = V1
+)
+
+var (
+	// V1DeprecatedAt and V1Sunset are announced in Deprecation and Sunset headers of every v1 response
+	V1DeprecatedAt = time.Date(2024, time.October, 7, 0, 0, 0, 0, time.UTC)
+	V1Sunset       = time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC)
+)
+
+type ctxKey struct{}
+
+func WithVersion(ctx context.Context, v Version) context.Context {
+	return context.WithValue(ctx, ctxKey{}, v)
+}
+
+// FromContext gives version request was routed to, Default when routing did not set any
+func FromContext(ctx context.Context) Version {
+	if v, ok := ctx.Value(ctxKey{}).(Version); ok {
+		return v
+	}
+	return Default
+}
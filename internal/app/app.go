@@ -1,21 +1,43 @@
 package app
 
 import (
+	"fmt"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	APP_TIMEOUT        = "APP_TIMEOUT"
-	APP_PORT           = "APP_PORT"
-	APP_DB_USER        = "APP_DB_USER"
-	APP_DB_PASS        = "APP_DB_PASS"
-	APP_DB_HOST        = "APP_DB_HOST"
-	APP_DB_PORT        = "APP_DB_PORT"
-	APP_DB             = "APP_DB"
-	LOG_PATH           = "LOG_PATH"
-	LOG_ENABLE_CONSOLE = "LOG_ENABLE_CONSOLE"
+	APP_TIMEOUT         = "APP_TIMEOUT"
+	APP_EXPORT_TIMEOUT  = "APP_EXPORT_TIMEOUT"
+	APP_IDEMPOTENCY_TTL = "APP_IDEMPOTENCY_TTL"
+	APP_TRUSTED_PROXIES = "APP_TRUSTED_PROXIES"
+	APP_ADMIN_KEY       = "APP_ADMIN_KEY"
+	APP_PUBLIC_URL      = "APP_PUBLIC_URL"
+	APP_MAIL_DIR        = "APP_MAIL_DIR"
+	APP_SMTP_ADDR       = "APP_SMTP_ADDR"
+	APP_SMTP_FROM       = "APP_SMTP_FROM"
+	APP_SMTP_USER       = "APP_SMTP_USER"
+	APP_SMTP_PASS       = "APP_SMTP_PASS"
+	APP_WEBHOOK_PRIVATE = "APP_WEBHOOK_PRIVATE"
+	APP_COOKIE_SECURE   = "APP_COOKIE_SECURE"
+	APP_JWT_ALG         = "APP_JWT_ALG"
+	APP_JWT_SECRET      = "APP_JWT_SECRET"
+	APP_JWT_SEED        = "APP_JWT_SEED"
+	APP_CONTENT_BLOCKED = "APP_CONTENT_BLOCKED"
+	APP_CONTENT_FLAGGED = "APP_CONTENT_FLAGGED"
+	APP_CONTENT_LINKS   = "APP_CONTENT_LINKS"
+	APP_CONTENT_RATE    = "APP_CONTENT_RATE"
+	APP_PORT            = "APP_PORT"
+	APP_DB_USER         = "APP_DB_USER"
+	APP_DB_PASS         = "APP_DB_PASS"
+	APP_DB_HOST         = "APP_DB_HOST"
+	APP_DB_PORT         = "APP_DB_PORT"
+	APP_DB              = "APP_DB"
+	LOG_PATH            = "LOG_PATH"
+	LOG_ENABLE_CONSOLE  = "LOG_ENABLE_CONSOLE"
 )
 
 type Application struct {
@@ -27,7 +49,42 @@ type Application struct {
 
 type Config struct {
 	Timeout int
-	Port    int
+	// ExportTimeout is how long, in seconds, a catalog export may stream, Timeout does not apply to it
+	ExportTimeout int
+	Port          int
+	// IdempotencyTTL is how long, in seconds, responses are replayed for a reused Idempotency-Key
+	IdempotencyTTL int
+	// TrustedProxies are networks of reverse proxies, addresses of clients behind them are taken from X-Forwarded-For
+	TrustedProxies []netip.Prefix
+	// AdminKey is registered as an admin api key on start, when set
+	AdminKey string
+	// PublicURL is where links in mails point to
+	PublicURL string
+	// MailDir makes mails be written there as files instead of only being logged
+	MailDir string
+	// SMTPAddr, host:port of an SMTP server, makes mails be delivered through it, it takes precedence over MailDir
+	SMTPAddr string
+	SMTPFrom string
+	// SMTPUser and SMTPPass authenticate to the server when SMTPUser is set
+	SMTPUser string
+	SMTPPass string
+	// WebhookPrivate lets alert webhooks reach private and loopback addresses, meant for development
+	WebhookPrivate bool
+	// CookieSecure is on unless turned off for plain http development setups
+	CookieSecure bool
+	// JWTAlg signs access tokens, EdDSA unless HS256 is asked for
+	JWTAlg string
+	// JWTSecret is the HS256 key, tokens signed with it are accepted only when set
+	JWTSecret string
+	// JWTSeed is base64 of the Ed25519 private key seed, a key generated on start is used without it
+	JWTSeed string
+	// ContentBlocked words make user written text rejected, ContentFlagged ones make it held for review
+	ContentBlocked []string
+	ContentFlagged []string
+	// ContentLinks is how many links user written text may have, negative allows any number
+	ContentLinks int
+	// ContentRate is how many texts a user may write in an hour, zero turns the limit off
+	ContentRate int
 }
 
 type Logging struct {
@@ -45,7 +102,60 @@ type DbConfig struct {
 
 func New(buildDate string) Application {
 	timeout, _ := strconv.Atoi(os.Getenv(APP_TIMEOUT))
+	exportTimeout, err := strconv.Atoi(os.Getenv(APP_EXPORT_TIMEOUT))
+	if err != nil || exportTimeout <= 0 {
+		exportTimeout = 30 * 60
+	}
 	port, _ := strconv.Atoi(os.Getenv(APP_PORT))
+	idempotencyTTL, err := strconv.Atoi(os.Getenv(APP_IDEMPOTENCY_TTL))
+	if err != nil || idempotencyTTL <= 0 {
+		idempotencyTTL = 24 * 60 * 60
+	}
+	trustedProxies := []netip.Prefix{}
+	for _, p := range strings.Split(os.Getenv(APP_TRUSTED_PROXIES), ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			panic("could not parse trusted proxy network\n" + err.Error())
+		}
+		trustedProxies = append(trustedProxies, prefix)
+	}
+	adminKey := os.Getenv(APP_ADMIN_KEY)
+	publicURL := os.Getenv(APP_PUBLIC_URL)
+	if publicURL == "" {
+		publicURL = fmt.Sprintf("http://localhost:%d", port)
+	}
+	mailDir := os.Getenv(APP_MAIL_DIR)
+	smtpAddr := os.Getenv(APP_SMTP_ADDR)
+	smtpFrom := os.Getenv(APP_SMTP_FROM)
+	if smtpFrom == "" {
+		smtpFrom = "noreply@localhost"
+	}
+	smtpUser := os.Getenv(APP_SMTP_USER)
+	smtpPass := os.Getenv(APP_SMTP_PASS)
+	webhookPrivate, _ := strconv.ParseBool(os.Getenv(APP_WEBHOOK_PRIVATE))
+	cookieSecure, err := strconv.ParseBool(os.Getenv(APP_COOKIE_SECURE))
+	if err != nil {
+		cookieSecure = true
+	}
+	jwtAlg := os.Getenv(APP_JWT_ALG)
+	if jwtAlg == "" {
+		jwtAlg = "EdDSA"
+	}
+	jwtSecret := os.Getenv(APP_JWT_SECRET)
+	jwtSeed := os.Getenv(APP_JWT_SEED)
+	contentBlocked := words(os.Getenv(APP_CONTENT_BLOCKED))
+	contentFlagged := words(os.Getenv(APP_CONTENT_FLAGGED))
+	contentLinks, err := strconv.Atoi(os.Getenv(APP_CONTENT_LINKS))
+	if err != nil {
+		contentLinks = 2
+	}
+	contentRate, err := strconv.Atoi(os.Getenv(APP_CONTENT_RATE))
+	if err != nil || contentRate < 0 {
+		contentRate = 10
+	}
 	user := os.Getenv(APP_DB_USER)
 	pass := os.Getenv(APP_DB_PASS)
 	host := os.Getenv(APP_DB_HOST)
@@ -62,8 +172,27 @@ func New(buildDate string) Application {
 
 	return Application{
 		Config: Config{
-			Timeout: timeout,
-			Port:    port,
+			Timeout:        timeout,
+			ExportTimeout:  exportTimeout,
+			Port:           port,
+			IdempotencyTTL: idempotencyTTL,
+			TrustedProxies: trustedProxies,
+			AdminKey:       adminKey,
+			PublicURL:      publicURL,
+			MailDir:        mailDir,
+			SMTPAddr:       smtpAddr,
+			SMTPFrom:       smtpFrom,
+			SMTPUser:       smtpUser,
+			SMTPPass:       smtpPass,
+			WebhookPrivate: webhookPrivate,
+			CookieSecure:   cookieSecure,
+			JWTAlg:         jwtAlg,
+			JWTSecret:      jwtSecret,
+			JWTSeed:        jwtSeed,
+			ContentBlocked: contentBlocked,
+			ContentFlagged: contentFlagged,
+			ContentLinks:   contentLinks,
+			ContentRate:    contentRate,
 		},
 		Logging: Logging{
 			LogFilePath:   logpath,
@@ -79,3 +208,14 @@ func New(buildDate string) Application {
 		BuildDate: bd,
 	}
 }
+
+// words splits comma separated list, blank entries are left out
+func words(list string) []string {
+	res := []string{}
+	for _, w := range strings.Split(list, ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			res = append(res, w)
+		}
+	}
+	return res
+}
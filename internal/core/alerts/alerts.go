@@ -0,0 +1,78 @@
+package alerts
+
+import (
+	"context"
+	"kbswitch/internal/core/alerts/models"
+	"kbswitch/internal/core/common"
+	switchmodels "kbswitch/internal/core/switches/models"
+	usermodels "kbswitch/internal/core/users/models"
+	"time"
+)
+
+// Service keeps price and stock of switches, subscriptions to their changes and inbox of the principal
+type Service interface {
+	Stock(ctx context.Context, brand, name string) (*models.Stock, *common.AppError)
+	// SetStock replaces price and stock of a switch, alerts of the switch are evaluated against the new one
+	SetStock(ctx context.Context, brand, name string, stock models.NewStock) (*models.Stock, *common.AppError)
+	// Subscriptions gives subscriptions made by the principal
+	Subscriptions(ctx context.Context) ([]models.Subscription, *common.AppError)
+	// Subscribe awaits changes to come, Triggered of the subscription tells whether the awaited one holds already.
+	// Email subscriptions notify once the address confirms them, unless it is the verified address of the principal
+	Subscribe(ctx context.Context, subscription models.NewSubscription) (*models.Subscription, *common.AppError)
+	Confirm(ctx context.Context, token string) (*models.Subscription, *common.AppError)
+	// Unsubscribe removes a subscription made by the principal
+	Unsubscribe(ctx context.Context, id int) *common.AppError
+	// UnsubscribeToken removes a subscription whoever holds its token, no account is needed
+	UnsubscribeToken(ctx context.Context, token string) *common.AppError
+	Inbox(ctx context.Context, unreadOnly bool) ([]models.Notification, *common.AppError)
+	MarkRead(ctx context.Context, id int) *common.AppError
+}
+
+// Evaluator checks subscriptions to a switch against its price and stock and notifies about changes
+type Evaluator interface {
+	Evaluate(ctx context.Context, brand, name string) error
+}
+
+// Notifier delivers a notification through a channel to target of the subscription
+type Notifier interface {
+	Notify(ctx context.Context, target string, notification models.Notification) error
+}
+
+// Catalog resolves brand and name, or an alias of them, to the switch subscriptions are about
+type Catalog interface {
+	GetID(ctx context.Context, brand, name string) (*int, error)
+	GetSingle(ctx context.Context, id int) (*switchmodels.SwitchEntity, error)
+}
+
+// Accounts tells whether email of a user is verified, users.Service satisfies it
+type Accounts interface {
+	Get(ctx context.Context, id int) (*usermodels.User, *common.AppError)
+}
+
+type Repo interface {
+	GetStock(ctx context.Context, switchID int) (*models.StockEntity, error)
+	// SaveStock inserts price and stock of the switch or replaces the one there is
+	SaveStock(ctx context.Context, e models.StockEntity) error
+	Subscriptions(ctx context.Context, owner string) ([]models.SubscriptionEntity, error)
+	// CountSubscriptions counts subscriptions of the subscriber, pending ones are those not confirmed yet
+	CountSubscriptions(ctx context.Context, subscriber string) (total, pending int, err error)
+	// PurgePending deletes subscriptions of the subscriber left unconfirmed since before
+	PurgePending(ctx context.Context, subscriber string, before time.Time) error
+	// SwitchSubscriptions gives confirmed subscriptions to the switch
+	SwitchSubscriptions(ctx context.Context, switchID int) ([]models.SubscriptionEntity, error)
+	GetSubscription(ctx context.Context, id int) (*models.SubscriptionEntity, error)
+	SubscriptionByToken(ctx context.Context, token string) (*models.SubscriptionEntity, error)
+	// InsertSubscription gives nil id when the subscriber awaits the same change through the same channel already
+	InsertSubscription(ctx context.Context, e models.SubscriptionEntity) (*int, error)
+	// ConfirmSubscription turns Confirmed on, triggered tells whether the awaited change holds at that moment
+	ConfirmSubscription(ctx context.Context, id int, triggered bool) error
+	DeleteSubscription(ctx context.Context, id int) (bool, error)
+	// TriggerAndNotify sets Triggered of the subscription and inserts e in one transaction. It gives nil id
+	// when the subscription was triggered already, so only one of concurrent evaluations notifies
+	TriggerAndNotify(ctx context.Context, id int, e models.NotificationEntity) (*int, error)
+	// Rearm clears Triggered of the subscription, so it notifies on the next change again
+	Rearm(ctx context.Context, id int) error
+	Notifications(ctx context.Context, owner string, unreadOnly bool) ([]models.NotificationEntity, error)
+	GetNotification(ctx context.Context, id int) (*models.NotificationEntity, error)
+	MarkRead(ctx context.Context, id int) error
+}
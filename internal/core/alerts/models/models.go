@@ -0,0 +1,137 @@
+package models
+
+import "time"
+
+// Kind tells what change of a switch a subscription waits for
+type Kind string
+
+const (
+	// KindPriceDrop fires when a switch is in stock at or below the target price of a single switch
+	KindPriceDrop Kind = "price_drop"
+	// KindBackInStock fires when a switch is in stock again
+	KindBackInStock Kind = "back_in_stock"
+)
+
+var Kinds = []Kind{KindPriceDrop, KindBackInStock}
+
+// Channel tells how notifications reach the subscriber
+type Channel string
+
+const (
+	// ChannelInbox keeps notifications for an account to read, it is the only channel needing one
+	ChannelInbox   Channel = "inbox"
+	ChannelEmail   Channel = "email"
+	ChannelWebhook Channel = "webhook"
+)
+
+var Channels = []Channel{ChannelInbox, ChannelEmail, ChannelWebhook}
+
+// Money is an amount in hundredths of Currency, which is an ISO 4217 code
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// NewStock is what admins or scripts tell about a switch, alerts of the switch are evaluated against it
+type NewStock struct {
+	// Price of a single switch, nil when it is not known
+	Price   *Money
+	InStock bool
+}
+
+type StockEntity struct {
+	SwitchID  int
+	Price     *Money
+	InStock   bool
+	UpdatedAt time.Time
+	// Brand and Name of the switch as the catalog knows it now, they are only read
+	Brand string
+	Name  string
+}
+
+type Stock struct {
+	Brand     string
+	Name      string
+	Price     *Money
+	InStock   bool
+	UpdatedAt time.Time
+}
+
+type NewSubscription struct {
+	Brand   string
+	Name    string
+	Kind    Kind
+	Channel Channel
+	// Target is the email address or webhook url notifications go to, inbox alerts need none
+	Target string
+	// Price of a single switch, it is needed by price drops only
+	Price *Money
+}
+
+type SubscriptionEntity struct {
+	ID int
+	// Subscriber keys the subscription, it is the email address or webhook url notifications go to,
+	// or subject of the account for the inbox
+	Subscriber string
+	// Owner is subject of the principal subscribed, e.g. user:5, empty for anonymous subscribers
+	Owner    string
+	SwitchID int
+	Kind     Kind
+	Channel  Channel
+	Price    *Money
+	// Token confirms the subscription and removes it without an account
+	Token string
+	// Confirmed is off until the subscriber follows the link mailed to the address, only confirmed subscriptions notify
+	Confirmed bool
+	// Triggered is on while the awaited change holds, a notification is sent only when it turns on
+	Triggered bool
+	CreatedAt time.Time
+	// Brand and Name of the switch as the catalog knows it now, they are only read
+	Brand string
+	Name  string
+}
+
+type Subscription struct {
+	ID         int
+	Brand      string
+	Name       string
+	Kind       Kind
+	Channel    Channel
+	Subscriber string
+	Price      *Money
+	// Token is given only to whoever owns the target already, email addresses get theirs in the confirmation mail
+	Token     string
+	Confirmed bool
+	Triggered bool
+	CreatedAt time.Time
+}
+
+type NotificationEntity struct {
+	ID    int
+	Owner string
+	// SubscriptionID is nil once the subscription is gone, its notifications stay in the inbox
+	SubscriptionID *int
+	Kind           Kind
+	Brand          string
+	Name           string
+	Subject        string
+	Body           string
+	// Price of a single switch when the notification was made, nil when it was not known
+	Price     *Money
+	CreatedAt time.Time
+	ReadAt    *time.Time
+}
+
+type Notification struct {
+	ID        int
+	Kind      Kind
+	Brand     string
+	Name      string
+	Subject   string
+	Body      string
+	Price     *Money
+	CreatedAt time.Time
+	ReadAt    *time.Time
+	// Unsubscribe is the link removing the subscription, it is only delivered and never kept
+	Unsubscribe string
+}
@@ -0,0 +1,27 @@
+package apikeys
+
+import (
+	"context"
+	"kbswitch/internal/core/apikeys/models"
+	"kbswitch/internal/core/common"
+)
+
+type Service interface {
+	Create(ctx context.Context, req models.NewKey) (*models.CreatedKey, *common.AppError)
+	List(ctx context.Context) ([]models.Key, *common.AppError)
+	Revoke(ctx context.Context, id int) *common.AppError
+	// Authenticate gives the key of a secret, revoked and expired keys are refused
+	Authenticate(ctx context.Context, secret string) (*models.Key, *common.AppError)
+	// Bootstrap registers secret as a non expiring admin key, so the very first keys can be created
+	Bootstrap(ctx context.Context, secret string) *common.AppError
+}
+
+type Repo interface {
+	// Insert stores a key, nil id is returned when key with the same hash already exists
+	Insert(ctx context.Context, e models.KeyEntity) (*int, error)
+	List(ctx context.Context) ([]models.KeyEntity, error)
+	// Revoke reports false when there is no such key or it is already revoked
+	Revoke(ctx context.Context, id int) (bool, error)
+	// Use marks key with given hash as used now and gives it, revoked keys are not found
+	Use(ctx context.Context, hash string) (*models.KeyEntity, error)
+}
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+// Scopes are ordered from the weakest, each scope grants everything the previous ones do
+var Scopes = []Scope{ScopeRead, ScopeWrite, ScopeAdmin}
+
+func (s Scope) rank() int {
+	for i, scope := range Scopes {
+		if scope == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s Scope) Valid() bool {
+	return s.rank() >= 0
+}
+
+type KeyEntity struct {
+	ID         int
+	Name       string
+	Prefix     string
+	Hash       string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+type Key struct {
+	ID         int
+	Name       string
+	Prefix     string
+	Scopes     []Scope
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+// Allows tells if any scope of the key grants the given one
+func (k Key) Allows(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s.rank() >= scope.rank() {
+			return true
+		}
+	}
+	return false
+}
+
+func (k Key) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+type NewKey struct {
+	Name      string
+	Scopes    []Scope
+	ExpiresAt *time.Time
+}
+
+// CreatedKey carries the secret, it is never stored and can't be shown again
+type CreatedKey struct {
+	Key    Key
+	Secret string
+}
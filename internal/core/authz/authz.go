@@ -0,0 +1,90 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/common"
+)
+
+type Role string
+
+const (
+	RoleViewer      Role = "viewer"
+	RoleContributor Role = "contributor"
+	RoleModerator   Role = "moderator"
+	RoleAdmin       Role = "admin"
+)
+
+var Roles = []Role{RoleViewer, RoleContributor, RoleModerator, RoleAdmin}
+
+func (r Role) Valid() bool {
+	for _, role := range Roles {
+		if role == r {
+			return true
+		}
+	}
+	return false
+}
+
+type Permission string
+
+const (
+	PermSwitchesRead    Permission = "switches:read"
+	PermSwitchesPropose Permission = "switches:propose"
+	PermSwitchesCreate  Permission = "switches:create"
+	PermSwitchesApprove Permission = "switches:approve"
+	PermSwitchesEdit    Permission = "switches:edit"
+	PermSwitchesDelete  Permission = "switches:delete"
+	PermReviewsWrite    Permission = "reviews:write"
+	PermReviewsModerate Permission = "reviews:moderate"
+	PermListingsManage  Permission = "listings:manage"
+	PermUsersManage     Permission = "users:manage"
+	PermKeysManage      Permission = "keys:manage"
+)
+
+// Principal is whoever a request acts on behalf of
+type Principal struct {
+	// Subject identifies the principal across requests, e.g. user:5 or key:2, empty for anonymous callers
+	Subject string
+	Name    string
+	Role    Role
+}
+
+// Anonymous is the principal of requests carrying no credentials
+var Anonymous = Principal{Name: "anonymous", Role: RoleViewer}
+
+func (p Principal) Anonymous() bool {
+	return p.Subject == ""
+}
+
+type Policy interface {
+	Allows(p Principal, perm Permission) bool
+}
+
+type ctxKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext gives principal of the request, Anonymous when nobody was identified
+func FromContext(ctx context.Context) Principal {
+	if p, ok := ctx.Value(ctxKey{}).(Principal); ok {
+		return p
+	}
+	return Anonymous
+}
+
+// Forbidden is the error of an action principal lacks permission for, the permission is named in it
+func Forbidden(perm Permission) *common.AppError {
+	e := common.NewError(common.ErrForbidden, fmt.Sprintf("missing permission '%s'", perm))
+	return &e
+}
+
+// Require checks principal of ctx against policy
+func Require(ctx context.Context, policy Policy, perm Permission) *common.AppError {
+	if policy.Allows(FromContext(ctx), perm) {
+		return nil
+	}
+	return Forbidden(perm)
+}
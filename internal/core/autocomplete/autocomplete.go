@@ -0,0 +1,7 @@
+package autocomplete
+
+import "kbswitch/internal/core/autocomplete/models"
+
+type Suggester interface {
+	Suggest(query string, limit int) []models.Suggestion
+}
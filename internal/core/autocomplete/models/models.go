@@ -0,0 +1,7 @@
+package models
+
+type Suggestion struct {
+	Brand string
+	Name  string
+	Score float64
+}
@@ -0,0 +1,63 @@
+package collections
+
+import (
+	"context"
+	"kbswitch/internal/core/collections/models"
+	"kbswitch/internal/core/common"
+	switchmodels "kbswitch/internal/core/switches/models"
+)
+
+// Service keeps lists of the principal, anonymous principals have none
+type Service interface {
+	Collection(ctx context.Context) (*Collection, *common.AppError)
+	AddItem(ctx context.Context, item models.NewItem) (*models.Item, *common.AppError)
+	// UpdateItem replaces item, the switch it is about can't change
+	UpdateItem(ctx context.Context, id int, item models.NewItem) (*models.Item, *common.AppError)
+	RemoveItem(ctx context.Context, id int) *common.AppError
+	Wishlist(ctx context.Context) ([]models.Wish, *common.AppError)
+	// AddWish adds switch to wishlist, every switch can be there only once
+	AddWish(ctx context.Context, wish models.NewWish) (*models.Wish, *common.AppError)
+	UpdateWish(ctx context.Context, id int, wish models.NewWish) (*models.Wish, *common.AppError)
+	RemoveWish(ctx context.Context, id int) *common.AppError
+	// Share gives link of list, lists have none until SetShare is called
+	Share(ctx context.Context, list models.List) (*models.Share, *common.AppError)
+	// SetShare creates link of list or changes who it works for
+	SetShare(ctx context.Context, list models.List, public bool) (*models.Share, *common.AppError)
+	// Unshare drops link of list, the next one has another token
+	Unshare(ctx context.Context, list models.List) *common.AppError
+	// Shared gives list behind a link to whoever holds its token, private links are unlisted rather than closed
+	Shared(ctx context.Context, token string) (*models.Shared, *common.AppError)
+}
+
+type Collection struct {
+	Items []models.Item
+	Value models.Value
+}
+
+// Catalog resolves references to switches, switches.Repo is one
+type Catalog interface {
+	GetID(ctx context.Context, brand, name string) (*int, error)
+	GetSingle(ctx context.Context, id int) (*switchmodels.SwitchEntity, error)
+}
+
+type Repo interface {
+	// Items gives collection of owner, oldest first
+	Items(ctx context.Context, owner string) ([]models.ItemEntity, error)
+	GetItem(ctx context.Context, id int) (*models.ItemEntity, error)
+	InsertItem(ctx context.Context, e models.ItemEntity) (*int, error)
+	UpdateItem(ctx context.Context, e models.ItemEntity) error
+	DeleteItem(ctx context.Context, id int) (bool, error)
+	// Value sums prices of collection of owner
+	Value(ctx context.Context, owner string) (*models.Value, error)
+	Wishes(ctx context.Context, owner string) ([]models.WishEntity, error)
+	GetWish(ctx context.Context, id int) (*models.WishEntity, error)
+	// InsertWish gives nil id when owner wishes for the switch already
+	InsertWish(ctx context.Context, e models.WishEntity) (*int, error)
+	UpdateWish(ctx context.Context, e models.WishEntity) error
+	DeleteWish(ctx context.Context, id int) (bool, error)
+	GetShare(ctx context.Context, owner string, list models.List) (*models.ShareEntity, error)
+	// SaveShare inserts share of the owner and list or changes visibility of the one there is, token of that one is kept
+	SaveShare(ctx context.Context, e models.ShareEntity) (*models.ShareEntity, error)
+	DeleteShare(ctx context.Context, owner string, list models.List) (bool, error)
+	ShareByToken(ctx context.Context, token string) (*models.ShareEntity, error)
+}
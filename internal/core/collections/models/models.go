@@ -0,0 +1,139 @@
+package models
+
+import "time"
+
+type Condition string
+
+const (
+	ConditionNew     Condition = "new"
+	ConditionLikeNew Condition = "like_new"
+	ConditionUsed    Condition = "used"
+	ConditionWorn    Condition = "worn"
+)
+
+var Conditions = []Condition{ConditionNew, ConditionLikeNew, ConditionUsed, ConditionWorn}
+
+// List names the lists a user keeps, each of them is shared on its own
+type List string
+
+const (
+	ListCollection List = "collection"
+	ListWishlist   List = "wishlist"
+)
+
+// Money is an amount in hundredths of Currency, which is an ISO 4217 code
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// SwitchRef points at a switch either by its ID or by brand and name, or an alias of them
+type SwitchRef struct {
+	ID    *int
+	Brand string
+	Name  string
+}
+
+type NewItem struct {
+	Switch    SwitchRef
+	Quantity  int
+	Condition Condition
+	Lubed     bool
+	// Price is what a single switch was bought for, when known
+	Price *Money
+	Note  string
+}
+
+type ItemEntity struct {
+	ID int
+	// Owner is subject of the principal keeping the collection, e.g. user:5
+	Owner     string
+	SwitchID  int
+	Quantity  int
+	Condition Condition
+	Lubed     bool
+	Price     *Money
+	Note      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// Brand and Name of the switch as the catalog knows it now, they are only read
+	Brand string
+	Name  string
+}
+
+type Item struct {
+	ID        int
+	SwitchID  int
+	Brand     string
+	Name      string
+	Quantity  int
+	Condition Condition
+	Lubed     bool
+	Price     *Money
+	Note      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type NewWish struct {
+	Switch   SwitchRef
+	Quantity int
+	Note     string
+}
+
+type WishEntity struct {
+	ID        int
+	Owner     string
+	SwitchID  int
+	Quantity  int
+	Note      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Brand     string
+	Name      string
+}
+
+type Wish struct {
+	ID        int
+	SwitchID  int
+	Brand     string
+	Name      string
+	Quantity  int
+	Note      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Value of a collection, totals are kept per currency since prices are never converted
+type Value struct {
+	Totals []Money
+	// Switches counts distinct switches, Quantity all of them
+	Switches int
+	Quantity int
+	// Unpriced counts switches bought for an unknown price, they are left out of Totals
+	Unpriced int
+}
+
+type ShareEntity struct {
+	Owner string
+	List  List
+	// Token is the secret part of the link
+	Token     string
+	Public    bool
+	CreatedAt time.Time
+}
+
+// Share tells whether the link to a list is public, private links are unlisted and work for those they are passed to
+type Share struct {
+	List   List
+	Token  string
+	Public bool
+}
+
+// Shared is a list as others see it through its link, prices and notes are left out
+type Shared struct {
+	List   List
+	Public bool
+	Items  []Item
+	Wishes []Wish
+}
@@ -14,6 +14,8 @@ type DBPool interface {
 	Query(context.Context, string, ...any) (pgx.Rows, error)
 	QueryRow(context.Context, string, ...any) pgx.Row
 	Exec(context.Context, string, ...any) (pgconn.CommandTag, error)
+	// Begin starts a transaction, on pgx.Tx itself it starts a savepoint
+	Begin(context.Context) (pgx.Tx, error)
 }
 
 func NewPool(ctx context.Context, cfg app.DbConfig) (*pgxpool.Pool, error) {
@@ -17,9 +17,13 @@ func (e APIError) Error() string {
 }
 
 var (
-	ErrBadRequest     = errors.New("bad request!")
-	ErrNotFound       = errors.New("not found!")
-	ErrInternalServer = errors.New("internal server error!")
+	ErrBadRequest      = errors.New("bad request!")
+	ErrNotFound        = errors.New("not found!")
+	ErrInternalServer  = errors.New("internal server error!")
+	ErrUnauthorized    = errors.New("unauthorized!")
+	ErrForbidden       = errors.New("forbidden!")
+	ErrConflict        = errors.New("conflict!")
+	ErrTooManyRequests = errors.New("too many requests!")
 )
 
 type AppError struct {
@@ -52,6 +56,14 @@ func ToAPIErr(err AppError) APIError {
 		status = http.StatusNotFound
 	case ErrInternalServer:
 		status = http.StatusInternalServerError
+	case ErrUnauthorized:
+		status = http.StatusUnauthorized
+	case ErrForbidden:
+		status = http.StatusForbidden
+	case ErrConflict:
+		status = http.StatusConflict
+	case ErrTooManyRequests:
+		status = http.StatusTooManyRequests
 	}
 
 	return APIError{Status: status, Message: err.Reason.Error()}
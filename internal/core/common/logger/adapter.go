@@ -0,0 +1,21 @@
+package logger
+
+import "kbswitch/internal/core/common/logging"
+
+// Adapter exposes package level logger through logging.Logger,
+// so it can be injected into services and repositories
+type Adapter struct{}
+
+var _ logging.Logger = Adapter{}
+
+func (Adapter) LogInfo(msg string) {
+	Info(msg)
+}
+
+func (Adapter) LogTrace(msg string) {
+	Trace(msg)
+}
+
+func (Adapter) LogError(msg string) {
+	Error(msg)
+}
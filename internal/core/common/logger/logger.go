@@ -114,19 +114,39 @@ func getResponseLog(rww models.ResponseWriterLogWrapper) models.ResponseLog {
 	var buf bytes.Buffer
 	buf.WriteString(rww.Body.String())
 
-	result.Header = (*rww.W).Header()
+	result.Header = redactHeader((*rww.W).Header())
 	result.Body = buf.String()
 
 	return result
 }
 
-// returns json
-func GetRequestResponseLog(rww models.ResponseWriterLogWrapper, r *http.Request) string {
+// Redacted stands in for logged values which carry credentials
+const Redacted = "[redacted]"
+
+// credentialHeaders carry session tokens and keys, their values are never logged
+var credentialHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+func redactHeader(header http.Header) http.Header {
+	res := header.Clone()
+	for _, name := range credentialHeaders {
+		if _, ok := res[name]; ok {
+			res[name] = []string{Redacted}
+		}
+	}
+	return res
+}
+
+// returns json, bodies are replaced by Redacted unless withBodies is set
+func GetRequestResponseLog(rww models.ResponseWriterLogWrapper, r *http.Request, withBodies bool) string {
 	rrl := models.RequestResponseLog{
 		Req:        getRequestLog(r),
 		Resp:       getResponseLog(rww),
 		StatusCode: *(rww.StatusCode),
 	}
+	if !withBodies {
+		rrl.Req.Body = Redacted
+		rrl.Resp.Body = Redacted
+	}
 
 	bytes, err := json.Marshal(rrl)
 	if err != nil {
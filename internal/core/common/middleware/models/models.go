@@ -29,11 +29,21 @@ type ResponseLog struct {
 	Body   string              `json:"Body"`
 }
 
+// only the beginning of the body is kept for logging, streamed responses can be arbitrarily large
+const maxLoggedBody = 64 << 10
+
 func (rww ResponseWriterLogWrapper) Write(buf []byte) (int, error) {
-	rww.Body.Write(buf)
+	if left := maxLoggedBody - rww.Body.Len(); left > 0 {
+		rww.Body.Write(buf[:min(left, len(buf))])
+	}
 	return (*rww.W).Write(buf)
 }
 
+// Unwrap lets http.ResponseController reach Flush of the underlying writer
+func (rww ResponseWriterLogWrapper) Unwrap() http.ResponseWriter {
+	return *rww.W
+}
+
 func (rww ResponseWriterLogWrapper) Header() http.Header {
 	return (*rww.W).Header()
 }
@@ -55,6 +65,11 @@ func (rw *ResponseWriterWithTimeout) WriteHeader(statusCode int) {
 	}
 }
 
+// Unwrap lets http.ResponseController reach Flush of the underlying writer
+func (rw *ResponseWriterWithTimeout) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func (rw *ResponseWriterWithTimeout) Write(b []byte) (int, error) {
 	if !rw.headerWritten {
 		rw.WriteHeader(http.StatusOK)
@@ -0,0 +1,9 @@
+package contentfilter
+
+import "kbswitch/internal/core/contentfilter/models"
+
+type Filter interface {
+	// Check judges content, content which isn't rejected counts towards
+	// posting rate of its author and is remembered for duplicate detection
+	Check(content models.Content) models.Verdict
+}
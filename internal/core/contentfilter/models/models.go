@@ -0,0 +1,50 @@
+package models
+
+// Action tells what to do with content, later actions are stricter than earlier ones
+type Action string
+
+const (
+	ActionAllow  Action = "allow"
+	ActionHold   Action = "hold"
+	ActionReject Action = "reject"
+)
+
+var severity = map[Action]int{
+	ActionAllow:  0,
+	ActionHold:   1,
+	ActionReject: 2,
+}
+
+// Stricter tells whether a is stricter than b
+func (a Action) Stricter(b Action) bool {
+	return severity[a] > severity[b]
+}
+
+type ReasonCode string
+
+const (
+	ReasonBlockedWord  ReasonCode = "blocked_word"
+	ReasonFlaggedWord  ReasonCode = "flagged_word"
+	ReasonTooManyLinks ReasonCode = "too_many_links"
+	ReasonDuplicate    ReasonCode = "duplicate"
+	ReasonRateLimited  ReasonCode = "rate_limited"
+)
+
+type Reason struct {
+	Code ReasonCode
+	// Action is what this reason alone asks for
+	Action Action
+	Detail string
+}
+
+// Content is text written by a user, Author is the subject of the principal
+type Content struct {
+	Author string
+	Text   string
+}
+
+// Verdict is the strictest action any of its reasons asks for, content without reasons is allowed
+type Verdict struct {
+	Action  Action
+	Reasons []Reason
+}
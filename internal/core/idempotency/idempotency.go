@@ -0,0 +1,17 @@
+package idempotency
+
+import (
+	"context"
+	"kbswitch/internal/core/idempotency/models"
+	"time"
+)
+
+type Store interface {
+	// Begin claims key of a client for ttl. When key is already claimed and not expired
+	// the existing record is returned and nothing is claimed, nil record means the caller owns the key
+	Begin(ctx context.Context, client, key, fingerprint string, ttl time.Duration) (*models.Record, error)
+	// Complete stores response of the request which claimed the key
+	Complete(ctx context.Context, client, key string, resp models.Response) error
+	// Release frees the key so the request can be retried from scratch
+	Release(ctx context.Context, client, key string) error
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"net/http"
+	"time"
+)
+
+// Response is what gets replayed to a client retrying a request with the same key
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type Record struct {
+	// Fingerprint is a hash of the request the key was first used with
+	Fingerprint string
+	// Response is nil while the first request is still being handled
+	Response  *Response
+	ExpiresAt time.Time
+}
@@ -0,0 +1,44 @@
+package listings
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/listings/models"
+	"time"
+)
+
+type Service interface {
+	Vendors(ctx context.Context) ([]models.Vendor, *common.AppError)
+	AddVendor(ctx context.Context, vendor models.NewVendor) (*models.Vendor, *common.AppError)
+	// RemoveVendor removes listings of the vendor as well
+	RemoveVendor(ctx context.Context, id int) *common.AppError
+	Listings(ctx context.Context, brand, name string) ([]models.Listing, *common.AppError)
+	AddListing(ctx context.Context, brand, name string, listing models.NewListing) (*models.Listing, *common.AppError)
+	// UpdateListing records what the listing offers now, vendor of a listing can't change
+	UpdateListing(ctx context.Context, brand, name string, id int, listing models.NewListing) (*models.Listing, *common.AppError)
+	RemoveListing(ctx context.Context, brand, name string, id int) *common.AppError
+	// Prices gives the best in stock offers of a switch and price history of each of its listings
+	Prices(ctx context.Context, brand, name string, query models.PriceQuery) (*models.PriceReport, *common.AppError)
+}
+
+// Catalog resolves brand and name, or an alias of them, to ID of the switch listings are about
+type Catalog interface {
+	GetID(ctx context.Context, brand, name string) (*int, error)
+}
+
+type Repo interface {
+	Vendors(ctx context.Context) ([]models.VendorEntity, error)
+	GetVendor(ctx context.Context, id int) (*models.VendorEntity, error)
+	// InsertVendor gives nil id when a vendor of the same name is there already
+	InsertVendor(ctx context.Context, e models.VendorEntity) (*int, error)
+	DeleteVendor(ctx context.Context, id int) (bool, error)
+	Listings(ctx context.Context, switchID int) ([]models.ListingEntity, error)
+	GetListing(ctx context.Context, id int) (*models.ListingEntity, error)
+	// InsertListing adds listing with its first snapshot, nil id tells the vendor lists the switch at the url already
+	InsertListing(ctx context.Context, e models.ListingEntity) (*int, error)
+	// UpdateListing replaces listing and records a snapshot in the same transaction when price or stock changed
+	UpdateListing(ctx context.Context, e models.ListingEntity) error
+	DeleteListing(ctx context.Context, id int) (bool, error)
+	// History gives snapshots of listings of a switch since given time together with the last one before it, oldest first
+	History(ctx context.Context, switchID int, since time.Time) ([]models.SnapshotEntity, error)
+}
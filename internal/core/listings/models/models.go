@@ -0,0 +1,100 @@
+package models
+
+import "time"
+
+type NewVendor struct {
+	Name string
+	// URL is the home page of the vendor
+	URL string
+}
+
+type VendorEntity struct {
+	ID        int
+	Name      string
+	URL       string
+	CreatedAt time.Time
+}
+
+type Vendor struct {
+	ID   int
+	Name string
+	URL  string
+}
+
+// NewListing offers a switch at a vendor, Price is for the whole pack in hundredths of Currency
+type NewListing struct {
+	VendorID int
+	URL      string
+	Price    int64
+	Currency string
+	PackSize int
+	InStock  bool
+}
+
+type ListingEntity struct {
+	ID        int
+	SwitchID  int
+	VendorID  int
+	URL       string
+	Price     int64
+	Currency  string
+	PackSize  int
+	InStock   bool
+	CreatedAt time.Time
+	// CheckedAt is when the listing was seen last, with changes or without them
+	CheckedAt time.Time
+	// VendorName is only read
+	VendorName string
+}
+
+type Listing struct {
+	ID         int
+	VendorID   int
+	VendorName string
+	URL        string
+	Price      int64
+	Currency   string
+	PackSize   int
+	InStock    bool
+	// UnitPrice is price of a single switch in Currency, rather than hundredths of it
+	UnitPrice float64
+	CreatedAt time.Time
+	CheckedAt time.Time
+}
+
+// SnapshotEntity is the state of a listing since At, a new one is recorded only when price or stock changes
+type SnapshotEntity struct {
+	ListingID int
+	Price     int64
+	Currency  string
+	PackSize  int
+	InStock   bool
+	At        time.Time
+}
+
+type PricePoint struct {
+	At        time.Time
+	Price     int64
+	Currency  string
+	PackSize  int
+	UnitPrice float64
+	InStock   bool
+}
+
+type ListingHistory struct {
+	Listing Listing
+	// History is oldest first, its first point may be older than asked for as it tells the state at that time
+	History []PricePoint
+}
+
+// PriceQuery narrows price report of a switch, zero values leave nothing out
+type PriceQuery struct {
+	Currency string
+	Since    time.Time
+}
+
+// PriceReport gives the best offer per currency, prices are never converted
+type PriceReport struct {
+	Best     []Listing
+	Listings []ListingHistory
+}
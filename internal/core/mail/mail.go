@@ -0,0 +1,14 @@
+package mail
+
+import "context"
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers mail, implementations range from logging it in development to real delivery
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+const (
+	MinScore = 1
+	MaxScore = 5
+)
+
+// Scores of a review, each of them from MinScore to MaxScore
+type Scores struct {
+	Overall    int
+	Smoothness int
+	Sound      int
+	Feel       int
+}
+
+type NewReview struct {
+	Scores Scores
+	Body   string
+}
+
+type ReviewEntity struct {
+	ID       int
+	SwitchID int
+	// Author is subject of the principal which wrote the review, e.g. user:5
+	Author     string
+	AuthorName string
+	Scores     Scores
+	Body       string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type Review struct {
+	ID        int
+	Author    string
+	Scores    Scores
+	Body      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// RatingEntity is the aggregate kept next to reviews of a switch, sums are divided on read
+type RatingEntity struct {
+	SwitchID      int
+	Count         int
+	SumOverall    int
+	SumSmoothness int
+	SumSound      int
+	SumFeel       int
+	Histogram     [MaxScore]int
+}
+
+type Sort string
+
+const (
+	SortNewest  Sort = "newest"
+	SortOldest  Sort = "oldest"
+	SortHighest Sort = "highest"
+	SortLowest  Sort = "lowest"
+)
+
+var Sorts = []Sort{SortNewest, SortOldest, SortHighest, SortLowest}
+
+// PageRequest asks for a page of reviews, pages are numbered from 1
+type PageRequest struct {
+	Page     int
+	PageSize int
+	Sort     Sort
+}
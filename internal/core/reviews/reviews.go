@@ -0,0 +1,50 @@
+package reviews
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/reviews/models"
+	switchmodels "kbswitch/internal/core/switches/models"
+)
+
+type Service interface {
+	// Create adds review of the principal, everybody can review a switch only once
+	Create(ctx context.Context, brand, name string, review models.NewReview) (*models.Review, *common.AppError)
+	// Update replaces review of the principal
+	Update(ctx context.Context, brand, name string, id int, review models.NewReview) (*models.Review, *common.AppError)
+	// Delete removes review of the principal, moderators can remove any review
+	Delete(ctx context.Context, brand, name string, id int) *common.AppError
+	List(ctx context.Context, brand, name string, page models.PageRequest) (*ReviewPage, *common.AppError)
+	// Summaries gives rating of every switch of keys which has reviews, keys must be canonical
+	Summaries(ctx context.Context, keys []switchmodels.SwitchKey) (map[switchmodels.SwitchKey]switchmodels.RatingSummary, *common.AppError)
+}
+
+type ReviewPage struct {
+	Reviews  []models.Review
+	Total    int
+	Page     int
+	PageSize int
+	Summary  switchmodels.RatingSummary
+}
+
+// Catalog resolves brand and name, or an alias of them, to ID of the switch reviews are about
+type Catalog interface {
+	GetID(ctx context.Context, brand, name string) (*int, error)
+}
+
+type Repo interface {
+	// Insert adds review and counts it into rating of its switch in one transaction,
+	// nil id tells the author reviewed the switch already
+	Insert(ctx context.Context, e models.ReviewEntity) (*int, error)
+	Get(ctx context.Context, id int) (*models.ReviewEntity, error)
+	// Update replaces scores and body of a review, rating of its switch is corrected in the same transaction
+	Update(ctx context.Context, e models.ReviewEntity) error
+	// Delete removes review and takes it out of rating of its switch in one transaction
+	Delete(ctx context.Context, id int) (bool, error)
+	// List gives a page of reviews of a switch with count of all of them
+	List(ctx context.Context, switchID int, page models.PageRequest) ([]models.ReviewEntity, int, error)
+	// Rating gives nil for switches without reviews
+	Rating(ctx context.Context, switchID int) (*models.RatingEntity, error)
+	// Ratings gives ratings of switches with given canonical brand and name, switches without reviews are absent
+	Ratings(ctx context.Context, keys []switchmodels.SwitchKey) (map[switchmodels.SwitchKey]models.RatingEntity, error)
+}
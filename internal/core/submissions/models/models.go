@@ -0,0 +1,79 @@
+package models
+
+import (
+	switchmodels "kbswitch/internal/core/switches/models"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending          Status = "pending"
+	StatusChangesRequested Status = "changes_requested"
+	StatusApproved         Status = "approved"
+	StatusRejected         Status = "rejected"
+)
+
+type Action string
+
+const (
+	ActionApprove        Action = "approve"
+	ActionReject         Action = "reject"
+	ActionRequestChanges Action = "request_changes"
+	// ActionRevise is taken by the submitter answering requested changes
+	ActionRevise Action = "revise"
+)
+
+type SubmissionEntity struct {
+	ID int
+	// Submitter is subject of the principal which submitted, e.g. user:5
+	Submitter     string
+	SubmitterName string
+	Body          switchmodels.SwitchRequestBody
+	Status        Status
+	// SwitchID is set once submission is approved and the switch exists
+	SwitchID  *int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CommentEntity records every action taken on a submission
+type CommentEntity struct {
+	SubmissionID int
+	Author       string
+	AuthorName   string
+	Action       Action
+	Comment      string
+	CreatedAt    time.Time
+}
+
+type Comment struct {
+	Author    string
+	Action    Action
+	Comment   string
+	CreatedAt time.Time
+}
+
+type Submission struct {
+	ID            int
+	Submitter     string
+	SubmitterName string
+	Body          switchmodels.SwitchRequestBody
+	Status        Status
+	SwitchID      *int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Comments      []Comment
+}
+
+// Decision of a moderator on a pending submission
+type Decision struct {
+	Action  Action
+	Comment string
+}
+
+// ListFilter narrows listed submissions, zero fields match everything
+type ListFilter struct {
+	Submitter string
+	Status    Status
+}
@@ -0,0 +1,38 @@
+package submissions
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/submissions/models"
+	switchmodels "kbswitch/internal/core/switches/models"
+)
+
+// Service keeps switches proposed by the community until a moderator decides on them.
+// Submitter is always the principal of ctx
+type Service interface {
+	Submit(ctx context.Context, body switchmodels.SwitchRequestBody) (*models.Submission, *common.AppError)
+	// Revise replaces body of a submission changes were requested for and puts it back into the queue
+	Revise(ctx context.Context, id int, body switchmodels.SwitchRequestBody, comment string) (*models.Submission, *common.AppError)
+	// Mine lists submissions of the principal, newest first
+	Mine(ctx context.Context) ([]models.Submission, *common.AppError)
+	// Get gives a submission to its submitter and to moderators
+	Get(ctx context.Context, id int) (*models.Submission, *common.AppError)
+	// Queue lists pending submissions, oldest first
+	Queue(ctx context.Context) ([]models.Submission, *common.AppError)
+	// Review approves, rejects or requests changes of a pending submission,
+	// approved ones are added to the catalog
+	Review(ctx context.Context, id int, decision models.Decision) (*models.Submission, *common.AppError)
+}
+
+type Repo interface {
+	Insert(ctx context.Context, e models.SubmissionEntity) (*int, error)
+	Get(ctx context.Context, id int) (*models.SubmissionEntity, error)
+	List(ctx context.Context, filter models.ListFilter) ([]models.SubmissionEntity, error)
+	// Transition stores e together with comment, in one transaction, only while the submission
+	// is still in from status, false tells it was not anymore
+	Transition(ctx context.Context, e models.SubmissionEntity, from models.Status, comment models.CommentEntity) (bool, error)
+	// Claim moves the submission to status to only while it is still in from status, without a comment,
+	// false tells it was not anymore
+	Claim(ctx context.Context, id int, from, to models.Status) (bool, error)
+	Comments(ctx context.Context, submissionID int) ([]models.CommentEntity, error)
+}
@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	// StatusAccepted is given when every proposed field was applied, StatusPartiallyAccepted when some were
+	StatusAccepted          Status = "accepted"
+	StatusPartiallyAccepted Status = "partially_accepted"
+	StatusRejected          Status = "rejected"
+)
+
+// Patch is a partial change of a switch, nil fields are left as they are.
+// Brand, name and image can't be suggested
+type Patch struct {
+	ActuationType    *string  `json:"actuationType,omitempty"`
+	Lifespan         *int     `json:"lifespan,omitempty"`
+	OperatingForce   *int     `json:"operatingForce,omitempty"`
+	ActivationTravel *float64 `json:"activationTravel,omitempty"`
+	TotalTravel      *float64 `json:"totalTravel,omitempty"`
+	SoundProfile     *string  `json:"soundProfile,omitempty"`
+	TriggerMethod    *string  `json:"triggerMethod,omitempty"`
+	Profile          *string  `json:"profile,omitempty"`
+	Description      *string  `json:"description,omitempty"`
+}
+
+type NewSuggestion struct {
+	Patch     Patch
+	SourceURL string
+}
+
+type SuggestionEntity struct {
+	ID int
+	// Brand and Name are the canonical ones of the switch at the time of suggesting
+	Brand string
+	Name  string
+	Patch Patch
+	// Base holds values of the patched fields the author saw, a field changed since is a conflict
+	Base           Patch
+	SourceURL      string
+	Author         string
+	AuthorName     string
+	Status         Status
+	AcceptedFields []string
+	Reviewer       string
+	ReviewerName   string
+	Comment        string
+	CreatedAt      time.Time
+	ReviewedAt     *time.Time
+}
+
+// FieldDiff compares a single proposed field against the switch as it is now
+type FieldDiff struct {
+	Field    string
+	Base     any
+	Current  any
+	Proposed any
+	// Conflict tells the field changed since the suggestion was made
+	Conflict bool
+}
+
+type Suggestion struct {
+	ID             int
+	Brand          string
+	Name           string
+	Patch          Patch
+	SourceURL      string
+	Author         string
+	Status         Status
+	AcceptedFields []string
+	Reviewer       string
+	Comment        string
+	CreatedAt      time.Time
+	ReviewedAt     *time.Time
+	// Diff is there for pending suggestions only
+	Diff []FieldDiff
+	// Conflicting tells at least one field of Diff is a conflict
+	Conflicting bool
+}
+
+// Acceptance picks fields of a suggestion to apply, all of them when Fields is empty.
+// Force applies conflicting fields as well
+type Acceptance struct {
+	Fields  []string
+	Force   bool
+	Comment string
+}
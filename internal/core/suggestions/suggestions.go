@@ -0,0 +1,40 @@
+package suggestions
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/suggestions/models"
+)
+
+// Service keeps edits of existing switches proposed by the community until a moderator reviews them
+type Service interface {
+	Suggest(ctx context.Context, brand, name string, s models.NewSuggestion) (*models.Suggestion, *common.AppError)
+	// Get gives a suggestion with its diff against the current switch
+	Get(ctx context.Context, id int) (*models.Suggestion, *common.AppError)
+	// Queue lists pending suggestions with their diffs, oldest first
+	Queue(ctx context.Context) ([]models.Suggestion, *common.AppError)
+	// ForSwitch lists every suggestion of a switch, newest first, so accepted ones attribute its values
+	ForSwitch(ctx context.Context, brand, name string) ([]models.Suggestion, *common.AppError)
+	// Accept applies chosen fields of a pending suggestion to the switch
+	Accept(ctx context.Context, id int, a models.Acceptance) (*models.Suggestion, *common.AppError)
+	Reject(ctx context.Context, id int, comment string) (*models.Suggestion, *common.AppError)
+}
+
+// ListFilter narrows listed suggestions, zero fields match everything
+type ListFilter struct {
+	Brand  string
+	Name   string
+	Status models.Status
+}
+
+type Repo interface {
+	Insert(ctx context.Context, e models.SuggestionEntity) (*int, error)
+	Get(ctx context.Context, id int) (*models.SuggestionEntity, error)
+	// List orders suggestions oldest first
+	List(ctx context.Context, filter ListFilter) ([]models.SuggestionEntity, error)
+	// Review stores outcome of a review while the suggestion is still pending, false tells it was not anymore
+	Review(ctx context.Context, e models.SuggestionEntity) (bool, error)
+	// Reopen makes a suggestion reviewed with status from pending again and forgets the review,
+	// false tells it was not in from status anymore
+	Reopen(ctx context.Context, id int, from models.Status) (bool, error)
+}
@@ -12,4 +12,10 @@ type SwitchRequestBody struct {
 	SoundProfile     string  `json:"soundProfile"`
 	TriggerMethod    string  `json:"triggerMethod"`
 	Profile          string  `json:"profile"`
+	Description      string  `json:"description"`
+}
+
+type AliasRequestBody struct {
+	Brand string `json:"brand"`
+	Name  string `json:"name"`
 }
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"kbswitch/internal/core/common"
+	"time"
+)
+
 type SwitchEntity struct {
 	ID               int
 	Manufacturer     string
@@ -13,6 +18,7 @@ type SwitchEntity struct {
 	SoundProfile     string  // Quiet, Loud, Normal
 	TriggerMethod    string  // mechanical, optical
 	Profile          string  // MX, chocov1, chocov2, MX low
+	Description      string
 }
 
 type Switch struct {
@@ -27,4 +33,266 @@ type Switch struct {
 	SoundProfile     string
 	TriggerMethod    string
 	Profile          string
+	Description      string
+	// Rating is there when reviews were asked for and the switch has some
+	Rating *RatingSummary
+}
+
+// RatingSummary aggregates review scores of a switch, scores go from 1 to 5
+type RatingSummary struct {
+	Count int
+	Mean  float64
+	// Histogram counts overall scores, index 0 holds number of 1 star reviews
+	Histogram  [5]int
+	Smoothness float64
+	Sound      float64
+	Feel       float64
+}
+
+// switch attribute names, used by similarity weights, filters and match explanations
+const (
+	AttrOperatingForce   = "operatingForce"
+	AttrActivationTravel = "activationTravel"
+	AttrTotalTravel      = "totalTravel"
+	AttrLifespan         = "lifespan"
+	AttrProfile          = "profile"
+	AttrTriggerMethod    = "triggerMethod"
+	AttrSoundProfile     = "soundProfile"
+	AttrActuationType    = "actuationType"
+	AttrBrand            = "brand"
+	AttrName             = "name"
+	AttrDescription      = "description"
+)
+
+// SimilarityWeights maps attribute name to its weight in similarity distance
+type SimilarityWeights map[string]float64
+
+func DefaultSimilarityWeights() SimilarityWeights {
+	return SimilarityWeights{
+		AttrOperatingForce:   3,
+		AttrActivationTravel: 2,
+		AttrTotalTravel:      1,
+		AttrLifespan:         0.5,
+		AttrProfile:          2,
+		AttrTriggerMethod:    1,
+		AttrSoundProfile:     1.5,
+		AttrActuationType:    2,
+	}
+}
+
+type AttributeMatch struct {
+	Attribute    string
+	Similarity   float64 // 0..1, how close this single attribute is
+	Weight       float64
+	Contribution float64 // part of the overall score this attribute is responsible for
+}
+
+type SimilarSwitch struct {
+	Switch  Switch
+	Score   float64 // 0..1, 1 means identical on every weighted attribute
+	Matches []AttributeMatch
+}
+
+// SwitchFilter narrows down switch listings, empty fields are not applied
+type SwitchFilter struct {
+	Brand             string
+	Profile           string
+	TriggerMethod     string
+	SoundProfile      string
+	ActuationType     string
+	MinOperatingForce *int
+	MaxOperatingForce *int
+	MinTotalTravel    *float64
+	MaxTotalTravel    *float64
+}
+
+type SearchHitEntity struct {
+	SwitchEntity
+	Rank                 float64
+	BrandHighlight       string
+	NameHighlight        string
+	DescriptionHighlight string
+}
+
+type SearchResult struct {
+	Switch     Switch
+	Rank       float64
+	Highlights map[string]string // field name -> html escaped text with matched terms wrapped in <mark></mark>
+}
+
+type AliasEntity struct {
+	ID       int
+	SwitchID int
+	Brand    string
+	Name     string
+}
+
+type Alias struct {
+	Brand string
+	Name  string
+}
+
+type FacetCount struct {
+	Value string
+	Count int
+}
+
+// HistogramBucket counts values in [From, To) range
+type HistogramBucket struct {
+	From  float64
+	To    float64
+	Count int
+}
+
+type Facets struct {
+	Terms      map[string][]FacetCount      // attribute -> count per distinct value
+	Histograms map[string][]HistogramBucket // attribute -> buckets ordered by range
+}
+
+type Distribution struct {
+	Min    float64
+	Max    float64
+	Avg    float64
+	Median float64
+	P25    float64
+	P75    float64
+	P90    float64
+}
+
+type ActuationStats struct {
+	ActuationType    string
+	Count            int
+	OperatingForce   Distribution
+	TotalTravel      Distribution
+	ActivationTravel Distribution
+}
+
+type SpringWeightCount struct {
+	OperatingForce int
+	Count          int
+}
+
+type MonthCount struct {
+	Month time.Time
+	Count int
+}
+
+type CatalogStats struct {
+	Total            int
+	PerBrand         []FacetCount
+	PerProfile       []FacetCount
+	PerActuationType []ActuationStats
+	SpringWeights    []SpringWeightCount // most common operating forces first
+	AddedPerMonth    []MonthCount
+}
+
+type ImportStatus string
+
+const (
+	ImportCreated ImportStatus = "created"
+	ImportUpdated ImportStatus = "updated"
+	ImportSkipped ImportStatus = "skipped"
+	ImportInvalid ImportStatus = "invalid"
+	ImportFailed  ImportStatus = "failed"
+)
+
+// ImportRow is a single parsed row of an import file, ParseErrors are filled when row could not be read
+type ImportRow struct {
+	Row         int
+	Body        SwitchRequestBody
+	ParseErrors []string
+}
+
+type ImportOptions struct {
+	DryRun bool
+	// Atomic stores either every row or none of them
+	Atomic bool
+	// UpdateExisting overwrites switches which already exist instead of skipping them
+	UpdateExisting bool
+}
+
+type ImportRowResult struct {
+	Row     int
+	Brand   string
+	Name    string
+	Status  ImportStatus
+	Reasons []string
+}
+
+type ImportReport struct {
+	Results    []ImportRowResult
+	DryRun     bool
+	Atomic     bool
+	RolledBack bool
+}
+
+type BatchAction string
+
+const (
+	BatchCreate BatchAction = "create"
+	BatchUpdate BatchAction = "update"
+	BatchDelete BatchAction = "delete"
+)
+
+type BatchStatus string
+
+const (
+	BatchCreated BatchStatus = "created"
+	BatchUpdated BatchStatus = "updated"
+	BatchDeleted BatchStatus = "deleted"
+	BatchFailed  BatchStatus = "failed"
+	// BatchSkipped operations were not run because an earlier one failed in atomic mode
+	BatchSkipped BatchStatus = "skipped"
+	// BatchRolledBack operations succeeded but their transaction was rolled back
+	BatchRolledBack BatchStatus = "rolledBack"
+)
+
+// BatchOperation targets existing switch by Brand and Name on update and delete,
+// Body is required for create and update
+type BatchOperation struct {
+	Action BatchAction
+	Brand  string
+	Name   string
+	Body   *SwitchRequestBody
+}
+
+type BatchOptions struct {
+	// Atomic runs operations in a single transaction which is rolled back at the first failure
+	Atomic bool
+}
+
+type BatchOperationResult struct {
+	Index  int
+	Action BatchAction
+	Brand  string
+	Name   string
+	Status BatchStatus
+	// ID is set for created switches
+	ID *int
+	// Switch is set for updated switches
+	Switch *Switch
+	Err    *common.AppError
+}
+
+type BatchReport struct {
+	Results    []BatchOperationResult
+	Atomic     bool
+	RolledBack bool
+}
+
+type SwitchKey struct {
+	Brand string
+	Name  string
+}
+
+// LookupHitEntity is a switch resolved for the key at Index of the requested keys
+type LookupHitEntity struct {
+	Index  int
+	Switch SwitchEntity
+}
+
+type LookupResult struct {
+	// Found keeps order of the requested keys, a switch requested several times is listed once
+	Found   []Switch
+	Missing []SwitchKey
 }
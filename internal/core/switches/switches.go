@@ -12,13 +12,45 @@ type Service interface {
 	AddNew(context.Context, models.SwitchRequestBody) (*int, *common.AppError)
 	Remove(context.Context, string, string) *common.AppError
 	Update(ctx context.Context, brand, name string, body models.SwitchRequestBody) (*models.Switch, *common.AppError)
+	GetSimilar(ctx context.Context, brand, name string, limit int, weights models.SimilarityWeights) ([]models.SimilarSwitch, *common.AppError)
+	Search(ctx context.Context, query string, filter models.SwitchFilter, limit int) ([]models.SearchResult, *common.AppError)
+	GetAliases(ctx context.Context, brand, name string) ([]models.Alias, *common.AppError)
+	AddAlias(ctx context.Context, brand, name string, alias models.AliasRequestBody) *common.AppError
+	RemoveAlias(ctx context.Context, brand, name string, alias models.Alias) *common.AppError
+	GetFacets(ctx context.Context, filter models.SwitchFilter) (*models.Facets, *common.AppError)
+	GetStats(ctx context.Context) (*models.CatalogStats, *common.AppError)
+	Import(ctx context.Context, rows []models.ImportRow, opts models.ImportOptions) (*models.ImportReport, *common.AppError)
+	// Export calls fn for every switch matching filter as it is read from the database,
+	// iteration stops at the first error returned by fn
+	Export(ctx context.Context, filter models.SwitchFilter, fn func(models.Switch) error) *common.AppError
+	// Batch runs create, update and delete operations in the given order, reporting outcome of each of them
+	Batch(ctx context.Context, ops []models.BatchOperation, opts models.BatchOptions) (*models.BatchReport, *common.AppError)
+	// Lookup resolves many brand/name pairs, or aliases of them, at once
+	Lookup(ctx context.Context, keys []models.SwitchKey) (*models.LookupResult, *common.AppError)
 }
 
 type Repo interface {
+	// GetID resolves brand and name, or any registered alias of them, to a switch ID
 	GetID(ctx context.Context, brand, name string) (*int, error)
 	GetAll(context.Context) ([]models.SwitchEntity, error)
 	GetSingle(context.Context, int) (*models.SwitchEntity, error)
 	AddNew(context.Context, models.SwitchEntity) (*int, error)
 	Remove(context.Context, int) error
 	Update(context.Context, int, models.SwitchEntity) (*models.SwitchEntity, error)
+	Search(ctx context.Context, query string, filter models.SwitchFilter, limit int) ([]models.SearchHitEntity, error)
+	GetAliases(ctx context.Context, switchID int) ([]models.AliasEntity, error)
+	AddAlias(context.Context, models.AliasEntity) (*int, error)
+	RemoveAlias(ctx context.Context, switchID int, brand, name string) (bool, error)
+	// Facets counts switches matching filter per attribute value,
+	// filter on the counted attribute itself is not applied
+	Facets(ctx context.Context, filter models.SwitchFilter) (*models.Facets, error)
+	Stats(context.Context) (*models.CatalogStats, error)
+	// InTx runs fn against a repo bound to a single transaction,
+	// transaction is committed when fn returns nil and rolled back otherwise
+	InTx(ctx context.Context, fn func(Repo) error) error
+	// Stream calls fn for every switch matching filter without collecting them first
+	Stream(ctx context.Context, filter models.SwitchFilter, fn func(models.SwitchEntity) error) error
+	// Lookup resolves keys the same way GetID does but in a single query,
+	// keys which resolve to nothing are absent from the result
+	Lookup(ctx context.Context, keys []models.SwitchKey) ([]models.LookupHitEntity, error)
 }
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+type GrantType string
+
+const (
+	GrantPassword     GrantType = "password"
+	GrantRefreshToken GrantType = "refresh_token"
+)
+
+type TokenRequest struct {
+	GrantType    GrantType
+	Email        string
+	Password     string
+	RefreshToken string
+}
+
+type TokenPair struct {
+	AccessToken  string
+	ExpiresIn    time.Duration
+	RefreshToken string
+}
+
+// Claims of a verified access token
+type Claims struct {
+	Subject   string
+	UserID    int
+	Name      string
+	Role      string
+	ExpiresAt time.Time
+}
+
+// RefreshEntity is a stored refresh token, every rotation adds a token to the family of the first one
+type RefreshEntity struct {
+	Hash      string
+	Family    string
+	UserID    int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+type JWK struct {
+	Kty string
+	Crv string
+	X   string
+	Kid string
+	Alg string
+	Use string
+}
@@ -0,0 +1,47 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/tokens/models"
+)
+
+type Service interface {
+	// Issue grants a token pair for user credentials or in exchange of a refresh token,
+	// which is used up by it
+	Issue(ctx context.Context, req models.TokenRequest) (*models.TokenPair, *common.AppError)
+	// Revoke ends the whole family of a refresh token
+	Revoke(ctx context.Context, refreshToken string) *common.AppError
+	// Verify gives claims of an access token
+	Verify(ctx context.Context, accessToken string) (*models.Claims, *common.AppError)
+	// JWKS gives public keys access tokens can be verified with
+	JWKS() []models.JWK
+}
+
+// ErrReused is given by Repo.Rotate for a refresh token which was rotated already
+var ErrReused = errors.New("refresh token was used before")
+
+type Repo interface {
+	Insert(ctx context.Context, e models.RefreshEntity) error
+	// Rotate marks token of hash used and stores successor under the same user and family, in one transaction.
+	// Reuse of a token means it leaked, then its whole family is revoked and ErrReused given.
+	// Unknown, revoked and expired tokens give nil
+	Rotate(ctx context.Context, hash string, successor models.RefreshEntity) (*models.RefreshEntity, error)
+	// RevokeFamily revokes every token in family of the token with hash
+	RevokeFamily(ctx context.Context, hash string) error
+	// RevokeAll revokes every token of the user, whichever family it is in
+	RevokeAll(ctx context.Context, userID int) error
+}
+
+type ctxKey struct{}
+
+func WithClaims(ctx context.Context, claims models.Claims) context.Context {
+	return context.WithValue(ctx, ctxKey{}, claims)
+}
+
+// FromContext gives claims of the bearer token request came with, if any
+func FromContext(ctx context.Context) (models.Claims, bool) {
+	claims, ok := ctx.Value(ctxKey{}).(models.Claims)
+	return claims, ok
+}
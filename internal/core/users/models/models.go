@@ -0,0 +1,58 @@
+package models
+
+import (
+	"kbswitch/internal/core/authz"
+	"time"
+)
+
+type UserEntity struct {
+	ID              int
+	Email           string
+	PasswordHash    string
+	Role            string
+	CreatedAt       time.Time
+	EmailVerifiedAt *time.Time
+}
+
+type User struct {
+	ID            int
+	Email         string
+	EmailVerified bool
+	Role          authz.Role
+	CreatedAt     time.Time
+}
+
+type SessionEntity struct {
+	// Hash of the session token, the token itself lives only in the cookie
+	Hash      string
+	UserID    int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Session is handed out on login, Token is what the client presents afterwards
+type Session struct {
+	Token     string
+	User      User
+	ExpiresAt time.Time
+}
+
+type TokenPurpose string
+
+const (
+	PurposeVerifyEmail   TokenPurpose = "verifyEmail"
+	PurposeResetPassword TokenPurpose = "resetPassword"
+)
+
+// TokenEntity is a single use token mailed to a user
+type TokenEntity struct {
+	Hash      string
+	UserID    int
+	Purpose   TokenPurpose
+	ExpiresAt time.Time
+}
+
+type Credentials struct {
+	Email    string
+	Password string
+}
@@ -0,0 +1,66 @@
+package users
+
+import (
+	"context"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/users/models"
+)
+
+type Service interface {
+	// Register creates an account and mails a verification token to it
+	Register(ctx context.Context, creds models.Credentials) (*models.User, *common.AppError)
+	Login(ctx context.Context, creds models.Credentials) (*models.Session, *common.AppError)
+	// CheckCredentials gives user of email and password without starting a session
+	CheckCredentials(ctx context.Context, creds models.Credentials) (*models.User, *common.AppError)
+	Get(ctx context.Context, id int) (*models.User, *common.AppError)
+	Logout(ctx context.Context, token string) *common.AppError
+	// Authenticate gives the user of a session token, expired sessions are refused
+	Authenticate(ctx context.Context, token string) (*models.User, *common.AppError)
+	VerifyEmail(ctx context.Context, token string) *common.AppError
+	// RequestPasswordReset mails a reset token, it succeeds for unknown emails as well
+	// so accounts can't be discovered through it
+	RequestPasswordReset(ctx context.Context, email string) *common.AppError
+	// ResetPassword sets a new password, ends every session of the user and revokes the rest of its credentials
+	ResetPassword(ctx context.Context, token, password string) *common.AppError
+	List(ctx context.Context) ([]models.User, *common.AppError)
+	SetRole(ctx context.Context, userID int, role authz.Role) (*models.User, *common.AppError)
+}
+
+// Revoker ends credentials of a user which are kept apart from sessions, tokens.Repo is one for refresh tokens
+type Revoker interface {
+	RevokeAll(ctx context.Context, userID int) error
+}
+
+type Repo interface {
+	// CreateUser gives nil id when email is already taken
+	CreateUser(ctx context.Context, e models.UserEntity) (*int, error)
+	GetUser(ctx context.Context, id int) (*models.UserEntity, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.UserEntity, error)
+	SetPassword(ctx context.Context, userID int, hash string) error
+	MarkVerified(ctx context.Context, userID int) error
+	CreateSession(ctx context.Context, e models.SessionEntity) error
+	// GetSession gives nil for unknown and expired sessions
+	GetSession(ctx context.Context, hash string) (*models.SessionEntity, error)
+	DeleteSession(ctx context.Context, hash string) error
+	DeleteSessions(ctx context.Context, userID int) error
+	// CreateToken replaces earlier tokens of the same user and purpose
+	CreateToken(ctx context.Context, e models.TokenEntity) error
+	// ConsumeToken deletes the token and gives it, nil when it is unknown or expired
+	ConsumeToken(ctx context.Context, hash string, purpose models.TokenPurpose) (*models.TokenEntity, error)
+	ListUsers(ctx context.Context) ([]models.UserEntity, error)
+	// SetRole gives the updated user, nil when there is no such user
+	SetRole(ctx context.Context, userID int, role string) (*models.UserEntity, error)
+}
+
+type ctxKey struct{}
+
+func WithUser(ctx context.Context, user models.User) context.Context {
+	return context.WithValue(ctx, ctxKey{}, user)
+}
+
+// FromContext gives user of the session request came with, if any
+func FromContext(ctx context.Context) (models.User, bool) {
+	user, ok := ctx.Value(ctxKey{}).(models.User)
+	return user, ok
+}
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE switches ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE switches ADD COLUMN IF NOT EXISTS search tsvector;
+
+CREATE OR REPLACE FUNCTION switches_search_update() RETURNS trigger AS $$
+BEGIN
+    NEW.search :=
+        setweight(to_tsvector('english', coalesce(NEW.manufacturer, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(NEW.model, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(NEW.description, '')), 'B');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER switches_search_update
+    BEFORE INSERT OR UPDATE OF manufacturer, model, description ON switches
+    FOR EACH ROW EXECUTE FUNCTION switches_search_update();
+
+-- fires the trigger for already existing rows
+UPDATE switches SET description = description;
+
+CREATE INDEX IF NOT EXISTS switches_search_idx ON switches USING GIN (search);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS switches_search_idx;
+DROP TRIGGER IF EXISTS switches_search_update ON switches;
+DROP FUNCTION IF EXISTS switches_search_update();
+ALTER TABLE switches DROP COLUMN IF EXISTS search;
+ALTER TABLE switches DROP COLUMN IF EXISTS description;
+-- +goose StatementEnd
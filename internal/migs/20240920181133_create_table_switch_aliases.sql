@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS switch_aliases (
+    id        SERIAL PRIMARY KEY,
+    switch_id INT NOT NULL REFERENCES switches(id) ON DELETE CASCADE,
+    brand     VARCHAR(255) NOT NULL,
+    name      VARCHAR(255) NOT NULL,
+    search    tsvector GENERATED ALWAYS AS (to_tsvector('english', brand || ' ' || name)) STORED
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS switch_aliases_brand_name_idx ON switch_aliases (lower(brand), lower(name));
+CREATE INDEX IF NOT EXISTS switch_aliases_switch_id_idx ON switch_aliases (switch_id);
+CREATE INDEX IF NOT EXISTS switch_aliases_search_idx ON switch_aliases USING GIN (search);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS switch_aliases;
+-- +goose StatementEnd
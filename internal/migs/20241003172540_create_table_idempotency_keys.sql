@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    client      VARCHAR(255) NOT NULL,
+    key         VARCHAR(255) NOT NULL,
+    fingerprint CHAR(64) NOT NULL,
+    status      INT,
+    header      JSONB,
+    body        BYTEA,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (client, key)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_keys;
+-- +goose StatementEnd
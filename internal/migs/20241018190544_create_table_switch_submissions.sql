@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS switch_submissions (
+    id             SERIAL PRIMARY KEY,
+    submitter      VARCHAR(64) NOT NULL,
+    submitter_name VARCHAR(254) NOT NULL,
+    body           JSONB NOT NULL,
+    status         VARCHAR(32) NOT NULL DEFAULT 'pending',
+    switch_id      INT REFERENCES switches (id) ON DELETE SET NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS switch_submissions_submitter_idx ON switch_submissions (submitter);
+CREATE INDEX IF NOT EXISTS switch_submissions_status_idx ON switch_submissions (status, created_at);
+
+CREATE TABLE IF NOT EXISTS switch_submission_comments (
+    id            SERIAL PRIMARY KEY,
+    submission_id INT NOT NULL REFERENCES switch_submissions (id) ON DELETE CASCADE,
+    author        VARCHAR(64) NOT NULL,
+    author_name   VARCHAR(254) NOT NULL,
+    action        VARCHAR(32) NOT NULL,
+    comment       TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS switch_submission_comments_submission_id_idx ON switch_submission_comments (submission_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS switch_submission_comments;
+DROP TABLE IF EXISTS switch_submissions;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS switch_suggestions (
+    id              SERIAL PRIMARY KEY,
+    brand           VARCHAR(255) NOT NULL,
+    name            VARCHAR(255) NOT NULL,
+    patch           JSONB NOT NULL,
+    base            JSONB NOT NULL,
+    source_url      VARCHAR(2048) NOT NULL,
+    author          VARCHAR(64) NOT NULL,
+    author_name     VARCHAR(254) NOT NULL,
+    status          VARCHAR(32) NOT NULL DEFAULT 'pending',
+    accepted_fields TEXT[] NOT NULL DEFAULT '{}',
+    reviewer        VARCHAR(64) NOT NULL DEFAULT '',
+    reviewer_name   VARCHAR(254) NOT NULL DEFAULT '',
+    comment         TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    reviewed_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS switch_suggestions_switch_idx ON switch_suggestions (lower(brand), lower(name));
+CREATE INDEX IF NOT EXISTS switch_suggestions_status_idx ON switch_suggestions (status, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS switch_suggestions;
+-- +goose StatementEnd
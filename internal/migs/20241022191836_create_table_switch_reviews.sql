@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS switch_reviews (
+    id          SERIAL PRIMARY KEY,
+    switch_id   INT NOT NULL REFERENCES switches (id) ON DELETE CASCADE,
+    author      VARCHAR(64) NOT NULL,
+    author_name VARCHAR(254) NOT NULL,
+    overall     SMALLINT NOT NULL CHECK (overall BETWEEN 1 AND 5),
+    smoothness  SMALLINT NOT NULL CHECK (smoothness BETWEEN 1 AND 5),
+    sound       SMALLINT NOT NULL CHECK (sound BETWEEN 1 AND 5),
+    feel        SMALLINT NOT NULL CHECK (feel BETWEEN 1 AND 5),
+    body        TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (switch_id, author)
+);
+
+CREATE INDEX IF NOT EXISTS switch_reviews_created_at_idx ON switch_reviews (switch_id, created_at);
+CREATE INDEX IF NOT EXISTS switch_reviews_overall_idx ON switch_reviews (switch_id, overall);
+
+-- kept by the same transactions which change reviews, so reading ratings never scans reviews
+CREATE TABLE IF NOT EXISTS switch_ratings (
+    switch_id      INT PRIMARY KEY REFERENCES switches (id) ON DELETE CASCADE,
+    count          INT NOT NULL DEFAULT 0,
+    sum_overall    INT NOT NULL DEFAULT 0,
+    sum_smoothness INT NOT NULL DEFAULT 0,
+    sum_sound      INT NOT NULL DEFAULT 0,
+    sum_feel       INT NOT NULL DEFAULT 0,
+    stars_1        INT NOT NULL DEFAULT 0,
+    stars_2        INT NOT NULL DEFAULT 0,
+    stars_3        INT NOT NULL DEFAULT 0,
+    stars_4        INT NOT NULL DEFAULT 0,
+    stars_5        INT NOT NULL DEFAULT 0
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS switch_ratings;
+DROP TABLE IF EXISTS switch_reviews;
+-- +goose StatementEnd
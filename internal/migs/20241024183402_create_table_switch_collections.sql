@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS collection_items (
+    id          SERIAL PRIMARY KEY,
+    owner       VARCHAR(64) NOT NULL,
+    switch_id   INT NOT NULL REFERENCES switches (id) ON DELETE CASCADE,
+    quantity    INT NOT NULL CHECK (quantity > 0),
+    condition   VARCHAR(16) NOT NULL,
+    lubed       BOOLEAN NOT NULL DEFAULT false,
+    -- price of a single switch in hundredths of currency, both are null when unknown
+    price       BIGINT CHECK (price >= 0),
+    currency    CHAR(3),
+    note        TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((price IS NULL) = (currency IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS collection_items_owner_idx ON collection_items (owner);
+
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    id          SERIAL PRIMARY KEY,
+    owner       VARCHAR(64) NOT NULL,
+    switch_id   INT NOT NULL REFERENCES switches (id) ON DELETE CASCADE,
+    quantity    INT NOT NULL CHECK (quantity > 0),
+    note        TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (owner, switch_id)
+);
+
+CREATE TABLE IF NOT EXISTS list_shares (
+    owner       VARCHAR(64) NOT NULL,
+    list        VARCHAR(16) NOT NULL,
+    token       VARCHAR(64) NOT NULL UNIQUE,
+    public      BOOLEAN NOT NULL DEFAULT false,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (owner, list)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS list_shares;
+DROP TABLE IF EXISTS wishlist_items;
+DROP TABLE IF EXISTS collection_items;
+-- +goose StatementEnd
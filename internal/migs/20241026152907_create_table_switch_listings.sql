@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS vendors (
+    id          SERIAL PRIMARY KEY,
+    name        VARCHAR(100) NOT NULL UNIQUE,
+    url         TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS switch_listings (
+    id          SERIAL PRIMARY KEY,
+    switch_id   INT NOT NULL REFERENCES switches (id) ON DELETE CASCADE,
+    vendor_id   INT NOT NULL REFERENCES vendors (id) ON DELETE CASCADE,
+    url         TEXT NOT NULL,
+    -- price of the whole pack in hundredths of currency
+    price       BIGINT NOT NULL CHECK (price >= 0),
+    currency    CHAR(3) NOT NULL,
+    pack_size   INT NOT NULL CHECK (pack_size > 0),
+    in_stock    BOOLEAN NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    checked_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (switch_id, vendor_id, url)
+);
+
+CREATE TABLE IF NOT EXISTS listing_snapshots (
+    listing_id  INT NOT NULL REFERENCES switch_listings (id) ON DELETE CASCADE,
+    price       BIGINT NOT NULL,
+    currency    CHAR(3) NOT NULL,
+    pack_size   INT NOT NULL,
+    in_stock    BOOLEAN NOT NULL,
+    at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS listing_snapshots_listing_at_idx ON listing_snapshots (listing_id, at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS listing_snapshots;
+DROP TABLE IF EXISTS switch_listings;
+DROP TABLE IF EXISTS vendors;
+-- +goose StatementEnd
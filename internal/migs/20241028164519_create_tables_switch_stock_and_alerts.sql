@@ -0,0 +1,58 @@
+-- +goose Up
+-- +goose StatementBegin
+-- price and stock of a switch as admins or scripts tell it, alerts are evaluated against it
+CREATE TABLE IF NOT EXISTS switch_stock (
+    switch_id   INT PRIMARY KEY REFERENCES switches (id) ON DELETE CASCADE,
+    -- price of a single switch in hundredths of currency, null when not known
+    price       BIGINT CHECK (price > 0),
+    currency    CHAR(3) NOT NULL DEFAULT '',
+    in_stock    BOOLEAN NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS alert_subscriptions (
+    id          SERIAL PRIMARY KEY,
+    -- email address or webhook url, subject of the account for the inbox
+    subscriber  TEXT NOT NULL,
+    -- subject of who subscribed, empty for anonymous subscribers
+    owner       VARCHAR(64) NOT NULL DEFAULT '',
+    switch_id   INT NOT NULL REFERENCES switches (id) ON DELETE CASCADE,
+    kind        VARCHAR(16) NOT NULL,
+    channel     VARCHAR(16) NOT NULL,
+    -- target price of a single switch in hundredths of currency, price drops only
+    price       BIGINT CHECK (price > 0),
+    currency    CHAR(3) NOT NULL DEFAULT '',
+    token       TEXT NOT NULL UNIQUE,
+    confirmed   BOOLEAN NOT NULL DEFAULT false,
+    triggered   BOOLEAN NOT NULL DEFAULT false,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (subscriber, channel, switch_id, kind, currency)
+);
+
+CREATE INDEX IF NOT EXISTS alert_subscriptions_switch_idx ON alert_subscriptions (switch_id);
+CREATE INDEX IF NOT EXISTS alert_subscriptions_owner_idx ON alert_subscriptions (owner);
+
+CREATE TABLE IF NOT EXISTS alert_notifications (
+    id              SERIAL PRIMARY KEY,
+    owner           VARCHAR(64) NOT NULL,
+    subscription_id INT REFERENCES alert_subscriptions (id) ON DELETE SET NULL,
+    kind            VARCHAR(16) NOT NULL,
+    brand           TEXT NOT NULL,
+    name            TEXT NOT NULL,
+    subject         TEXT NOT NULL,
+    body            TEXT NOT NULL,
+    price           BIGINT,
+    currency        CHAR(3) NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    read_at         TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS alert_notifications_owner_idx ON alert_notifications (owner, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS alert_notifications;
+DROP TABLE IF EXISTS alert_subscriptions;
+DROP TABLE IF EXISTS switch_stock;
+-- +goose StatementEnd
@@ -0,0 +1,573 @@
+package alerts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"kbswitch/internal/core/alerts"
+	"kbswitch/internal/core/alerts/models"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/mail"
+	switchmodels "kbswitch/internal/core/switches/models"
+	switchservice "kbswitch/internal/pkg/switches"
+	netmail "net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MaxSubscriptions = 100
+	// MaxPending bounds confirmation mails sent to an address which confirmed none of them yet
+	MaxPending = 3
+	// ConfirmTTL is how long a confirmation link is valid, subscriptions left unconfirmed are purged after it
+	ConfirmTTL = 48 * time.Hour
+
+	maxURLLen   = 2048
+	maxEmailLen = 254
+	userPrefix  = "user:"
+)
+
+var currency = regexp.MustCompile(`^[A-Z]{3}$`)
+
+var (
+	ErrAnonymous        = common.NewError(common.ErrUnauthorized, "the inbox and alerts of an account require being logged in")
+	ErrNoStock          = common.NewError(common.ErrNotFound, "switch has no price and stock yet")
+	ErrNoSubscription   = common.NewError(common.ErrNotFound, "alert subscription with given id not found")
+	ErrInvalidToken     = common.NewError(common.ErrNotFound, "alert subscription with given token not found or its confirmation expired")
+	ErrNoNotification   = common.NewError(common.ErrNotFound, "notification with given id not found")
+	ErrSubscribed       = common.NewError(common.ErrConflict, "the same change is awaited through the same channel already, remove that subscription first")
+	ErrTooMany          = common.NewError(common.ErrBadRequest, fmt.Sprintf("at most %d alert subscriptions can be kept", MaxSubscriptions))
+	ErrTooManyPending   = common.NewError(common.ErrBadRequest, fmt.Sprintf("at most %d alert subscriptions of an address can await confirmation", MaxPending))
+	ErrUnknownKind      = common.NewError(common.ErrBadRequest, "kind must be one of price_drop or back_in_stock")
+	ErrUnknownChannel   = common.NewError(common.ErrBadRequest, "channel must be one of inbox, email or webhook")
+	ErrMissingPrice     = common.NewError(common.ErrBadRequest, "price drops need a target price of a single switch")
+	ErrUnexpectedPrice  = common.NewError(common.ErrBadRequest, "only price drops have a target price")
+	ErrInvalidPrice     = common.NewError(common.ErrBadRequest, "price must be above zero")
+	ErrInvalidCurrency  = common.NewError(common.ErrBadRequest, "currency must be a three letter ISO 4217 code")
+	ErrInvalidEmail     = common.NewError(common.ErrBadRequest, "email alerts need a valid email address as target")
+	ErrInvalidWebhook   = common.NewError(common.ErrBadRequest, "webhook must be an absolute http or https url")
+	ErrUnexpectedTarget = common.NewError(common.ErrBadRequest, "inbox alerts have no target, they reach the account subscribing")
+)
+
+// New gives the service, email alerts notify once the address confirms them through a link mailed by sender,
+// links point to baseURL
+func New(logger logging.Logger, repo alerts.Repo, catalog alerts.Catalog, accounts alerts.Accounts, policy authz.Policy,
+	sender mail.Sender, baseURL string) alerts.Service {
+	return service{
+		repo:     repo,
+		catalog:  catalog,
+		accounts: accounts,
+		policy:   policy,
+		sender:   sender,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		logger:   logger,
+	}
+}
+
+type service struct {
+	repo     alerts.Repo
+	catalog  alerts.Catalog
+	accounts alerts.Accounts
+	policy   authz.Policy
+	sender   mail.Sender
+	baseURL  string
+	logger   logging.Logger
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func link(baseURL, path, token string) string {
+	return baseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// owner gives subject of the principal, the inbox belongs to it
+func (s service) owner(ctx context.Context) (authz.Principal, *common.AppError) {
+	principal := authz.FromContext(ctx)
+	if principal.Anonymous() {
+		s.logger.LogError("anonymous principal has no inbox")
+		return principal, &ErrAnonymous
+	}
+	return principal, nil
+}
+
+// subscriber validates where notifications of the subscription go, which keys the subscription as well
+func subscriber(principal authz.Principal, subscription models.NewSubscription) (string, *common.AppError) {
+	switch subscription.Channel {
+	case models.ChannelInbox:
+		if principal.Anonymous() {
+			return "", &ErrAnonymous
+		}
+		if subscription.Target != "" {
+			return "", &ErrUnexpectedTarget
+		}
+		return principal.Subject, nil
+	case models.ChannelEmail:
+		email := strings.ToLower(strings.TrimSpace(subscription.Target))
+		// display names like "Name <a@b.c>" are not an email address on their own
+		addr, err := netmail.ParseAddress(email)
+		if err != nil || len(email) > maxEmailLen || addr.Address != email {
+			return "", &ErrInvalidEmail
+		}
+		return email, nil
+	case models.ChannelWebhook:
+		u, err := url.Parse(subscription.Target)
+		if err != nil || len(subscription.Target) > maxURLLen || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", &ErrInvalidWebhook
+		}
+		return subscription.Target, nil
+	}
+	return "", &ErrUnknownChannel
+}
+
+// verified tells whether email is the verified address of the account behind the principal, mails to any other
+// address wait for it to confirm them, as anybody could give it otherwise
+func (s service) verified(ctx context.Context, principal authz.Principal, email string) (bool, *common.AppError) {
+	id, err := strconv.Atoi(strings.TrimPrefix(principal.Subject, userPrefix))
+	if err != nil || !strings.HasPrefix(principal.Subject, userPrefix) {
+		return false, nil
+	}
+
+	user, e := s.accounts.Get(ctx, id)
+	if e != nil {
+		if e.Errtype == common.ErrNotFound {
+			return false, nil
+		}
+		return false, e
+	}
+	return user.EmailVerified && strings.EqualFold(user.Email, email), nil
+}
+
+func validateMoney(m models.Money) *common.AppError {
+	if m.Amount <= 0 {
+		return &ErrInvalidPrice
+	}
+	if !currency.MatchString(m.Currency) {
+		return &ErrInvalidCurrency
+	}
+	return nil
+}
+
+func validatePrice(subscription models.NewSubscription) *common.AppError {
+	switch subscription.Kind {
+	case models.KindPriceDrop:
+		if subscription.Price == nil {
+			return &ErrMissingPrice
+		}
+		return validateMoney(*subscription.Price)
+	case models.KindBackInStock:
+		if subscription.Price != nil {
+			return &ErrUnexpectedPrice
+		}
+	default:
+		return &ErrUnknownKind
+	}
+	return nil
+}
+
+// resolve gives the switch behind brand and name, or an alias of them
+func (s service) resolve(ctx context.Context, brand, name string) (*switchmodels.SwitchEntity, *common.AppError) {
+	id, err := s.catalog.GetID(ctx, brand, name)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	var sw *switchmodels.SwitchEntity
+	if id != nil {
+		if sw, err = s.catalog.GetSingle(ctx, *id); err != nil {
+			s.logger.LogError(err.Error())
+			return nil, common.Wrap(err)
+		}
+	}
+	if sw == nil {
+		s.logger.LogError(fmt.Sprintf("switch %s %s not found", brand, name))
+		return nil, &switchservice.ErrNoSwitch
+	}
+	return sw, nil
+}
+
+func (s service) Stock(ctx context.Context, brand, name string) (*models.Stock, *common.AppError) {
+	sw, e := s.resolve(ctx, brand, name)
+	if e != nil {
+		return nil, e
+	}
+
+	entity, err := s.repo.GetStock(ctx, sw.ID)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil {
+		s.logger.LogError(fmt.Sprintf("switch %d has no stock", sw.ID))
+		return nil, &ErrNoStock
+	}
+
+	res := toStock(*entity)
+	return &res, nil
+}
+
+func (s service) SetStock(ctx context.Context, brand, name string, stock models.NewStock) (*models.Stock, *common.AppError) {
+	if e := authz.Require(ctx, s.policy, authz.PermListingsManage); e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+	if stock.Price != nil {
+		if e := validateMoney(*stock.Price); e != nil {
+			s.logger.LogError(e.Error())
+			return nil, e
+		}
+	}
+
+	sw, e := s.resolve(ctx, brand, name)
+	if e != nil {
+		return nil, e
+	}
+
+	entity := models.StockEntity{
+		SwitchID:  sw.ID,
+		Price:     stock.Price,
+		InStock:   stock.InStock,
+		UpdatedAt: time.Now(),
+		Brand:     sw.Manufacturer,
+		Name:      sw.Model,
+	}
+	if err := s.repo.SaveStock(ctx, entity); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("stock of switch %d set, in stock: %t", sw.ID, stock.InStock))
+	res := toStock(entity)
+	return &res, nil
+}
+
+func (s service) Subscriptions(ctx context.Context) ([]models.Subscription, *common.AppError) {
+	principal, e := s.owner(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	entities, err := s.repo.Subscriptions(ctx, principal.Subject)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := make([]models.Subscription, len(entities))
+	for i, e := range entities {
+		res[i] = toSubscription(e)
+		// tokens of unconfirmed subscriptions reach their address only
+		if e.Confirmed {
+			res[i].Token = e.Token
+		}
+	}
+	return res, nil
+}
+
+func (s service) Subscribe(ctx context.Context, subscription models.NewSubscription) (*models.Subscription, *common.AppError) {
+	principal := authz.FromContext(ctx)
+
+	if !slices.Contains(models.Kinds, subscription.Kind) {
+		return nil, &ErrUnknownKind
+	}
+	if !slices.Contains(models.Channels, subscription.Channel) {
+		return nil, &ErrUnknownChannel
+	}
+	if e := validatePrice(subscription); e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+	key, e := subscriber(principal, subscription)
+	if e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+
+	confirmed := true
+	if subscription.Channel == models.ChannelEmail {
+		if confirmed, e = s.verified(ctx, principal, key); e != nil {
+			s.logger.LogError(e.Error())
+			return nil, e
+		}
+	}
+
+	sw, e := s.resolve(ctx, subscription.Brand, subscription.Name)
+	if e != nil {
+		return nil, e
+	}
+
+	// expired confirmations neither count against the address nor keep it from subscribing again
+	if err := s.repo.PurgePending(ctx, key, time.Now().Add(-ConfirmTTL)); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	total, pending, err := s.repo.CountSubscriptions(ctx, key)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if total >= MaxSubscriptions {
+		s.logger.LogError(fmt.Sprintf("subscriber keeps %d subscriptions already", total))
+		return nil, &ErrTooMany
+	}
+	if !confirmed && pending >= MaxPending {
+		s.logger.LogError(fmt.Sprintf("subscriber has %d subscriptions awaiting confirmation already", pending))
+		return nil, &ErrTooManyPending
+	}
+
+	token, err := newToken()
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	entity := models.SubscriptionEntity{
+		Subscriber: key,
+		Owner:      principal.Subject,
+		SwitchID:   sw.ID,
+		Kind:       subscription.Kind,
+		Channel:    subscription.Channel,
+		Price:      subscription.Price,
+		Token:      token,
+		Confirmed:  confirmed,
+		CreatedAt:  time.Now(),
+		Brand:      sw.Manufacturer,
+		Name:       sw.Model,
+	}
+
+	// only changes after subscribing are notified about, a change which happened already just triggers the subscription
+	if entity.Triggered, e = s.holds(ctx, entity); e != nil {
+		return nil, e
+	}
+
+	sid, err := s.repo.InsertSubscription(ctx, entity)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if sid == nil {
+		s.logger.LogError(fmt.Sprintf("subscriber awaits %s of switch %d through %s already", entity.Kind, entity.SwitchID, entity.Channel))
+		return nil, &ErrSubscribed
+	}
+	entity.ID = *sid
+
+	if !confirmed {
+		if err := s.sender.Send(ctx, s.confirmation(entity)); err != nil {
+			s.logger.LogError(err.Error())
+			// a subscription nobody can confirm would only count against the address
+			if _, err := s.repo.DeleteSubscription(ctx, entity.ID); err != nil {
+				s.logger.LogError(err.Error())
+			}
+			return nil, common.Wrap(err)
+		}
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("subscription %d added, confirmed: %t", entity.ID, confirmed))
+	res := toSubscription(entity)
+	if confirmed {
+		res.Token = token
+	}
+	return &res, nil
+}
+
+// holds tells whether the change subscription awaits is there according to the current stock of its switch
+func (s service) holds(ctx context.Context, subscription models.SubscriptionEntity) (bool, *common.AppError) {
+	stock, err := s.repo.GetStock(ctx, subscription.SwitchID)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return false, common.Wrap(err)
+	}
+	return holds(subscription, stock), nil
+}
+
+// confirmation is the mail asking the address to confirm subscription, the link in it carries the token
+func (s service) confirmation(subscription models.SubscriptionEntity) mail.Message {
+	awaited := "is back in stock"
+	if subscription.Kind == models.KindPriceDrop {
+		awaited = "drops to " + price(*subscription.Price) + " per switch or below"
+	}
+
+	return mail.Message{
+		To:      subscription.Subscriber,
+		Subject: fmt.Sprintf("Confirm your alert for %s %s", subscription.Brand, subscription.Name),
+		Body: fmt.Sprintf("Somebody asked to have this address told when %s %s %s.\n\n"+
+			"Confirm by following the link below, it is valid for %s. If it wasn't you, just ignore this mail.\n\n%s\n",
+			subscription.Brand, subscription.Name, awaited, ConfirmTTL, link(s.baseURL, "/alerts/confirm", subscription.Token)),
+	}
+}
+
+func (s service) Confirm(ctx context.Context, token string) (*models.Subscription, *common.AppError) {
+	entity, err := s.repo.SubscriptionByToken(ctx, token)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil || (!entity.Confirmed && time.Since(entity.CreatedAt) > ConfirmTTL) {
+		s.logger.LogError("unknown or expired subscription token")
+		return nil, &ErrInvalidToken
+	}
+
+	if !entity.Confirmed {
+		// changes before confirming are not notified about, just as changes before subscribing are not
+		triggered, e := s.holds(ctx, *entity)
+		if e != nil {
+			return nil, e
+		}
+		if err := s.repo.ConfirmSubscription(ctx, entity.ID, triggered); err != nil {
+			s.logger.LogError(err.Error())
+			return nil, common.Wrap(err)
+		}
+		entity.Confirmed, entity.Triggered = true, triggered
+		s.logger.LogTrace(fmt.Sprintf("subscription %d confirmed", entity.ID))
+	}
+
+	res := toSubscription(*entity)
+	res.Token = entity.Token
+	return &res, nil
+}
+
+func (s service) Unsubscribe(ctx context.Context, id int) *common.AppError {
+	principal, e := s.owner(ctx)
+	if e != nil {
+		return e
+	}
+
+	entity, err := s.repo.GetSubscription(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if entity == nil || entity.Owner != principal.Subject {
+		s.logger.LogError(fmt.Sprintf("subscription %d of %s not found", id, principal.Subject))
+		return &ErrNoSubscription
+	}
+
+	return s.delete(ctx, entity.ID, &ErrNoSubscription)
+}
+
+func (s service) UnsubscribeToken(ctx context.Context, token string) *common.AppError {
+	entity, err := s.repo.SubscriptionByToken(ctx, token)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if entity == nil {
+		s.logger.LogError("unknown subscription token")
+		return &ErrInvalidToken
+	}
+
+	return s.delete(ctx, entity.ID, &ErrInvalidToken)
+}
+
+func (s service) delete(ctx context.Context, id int, gone *common.AppError) *common.AppError {
+	ok, err := s.repo.DeleteSubscription(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if !ok {
+		s.logger.LogError(fmt.Sprintf("subscription %d deleted meanwhile", id))
+		return gone
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("subscription %d removed", id))
+	return nil
+}
+
+func (s service) Inbox(ctx context.Context, unreadOnly bool) ([]models.Notification, *common.AppError) {
+	principal, e := s.owner(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	entities, err := s.repo.Notifications(ctx, principal.Subject, unreadOnly)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := make([]models.Notification, len(entities))
+	for i, e := range entities {
+		res[i] = toNotification(e)
+	}
+	return res, nil
+}
+
+func (s service) MarkRead(ctx context.Context, id int) *common.AppError {
+	principal, e := s.owner(ctx)
+	if e != nil {
+		return e
+	}
+
+	entity, err := s.repo.GetNotification(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if entity == nil || entity.Owner != principal.Subject {
+		s.logger.LogError(fmt.Sprintf("notification %d of %s not found", id, principal.Subject))
+		return &ErrNoNotification
+	}
+	if entity.ReadAt != nil {
+		return nil
+	}
+
+	if err := s.repo.MarkRead(ctx, id); err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("notification %d read", id))
+	return nil
+}
+
+func toStock(e models.StockEntity) models.Stock {
+	return models.Stock{
+		Brand:     e.Brand,
+		Name:      e.Name,
+		Price:     e.Price,
+		InStock:   e.InStock,
+		UpdatedAt: e.UpdatedAt,
+	}
+}
+
+func toSubscription(e models.SubscriptionEntity) models.Subscription {
+	return models.Subscription{
+		ID:         e.ID,
+		Brand:      e.Brand,
+		Name:       e.Name,
+		Kind:       e.Kind,
+		Channel:    e.Channel,
+		Subscriber: e.Subscriber,
+		Price:      e.Price,
+		Confirmed:  e.Confirmed,
+		Triggered:  e.Triggered,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+func toNotification(e models.NotificationEntity) models.Notification {
+	return models.Notification{
+		ID:        e.ID,
+		Kind:      e.Kind,
+		Brand:     e.Brand,
+		Name:      e.Name,
+		Subject:   e.Subject,
+		Body:      e.Body,
+		Price:     e.Price,
+		CreatedAt: e.CreatedAt,
+		ReadAt:    e.ReadAt,
+	}
+}
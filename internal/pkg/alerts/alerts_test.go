@@ -0,0 +1,1004 @@
+package alerts_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	corealerts "kbswitch/internal/core/alerts"
+	"kbswitch/internal/core/alerts/models"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/mail"
+	switchmodels "kbswitch/internal/core/switches/models"
+	usermodels "kbswitch/internal/core/users/models"
+	"kbswitch/internal/pkg/alerts"
+	authzpolicy "kbswitch/internal/pkg/authz"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeLogger struct{}
+
+func (fakeLogger) LogError(msg string) {}
+func (fakeLogger) LogInfo(msg string)  {}
+func (fakeLogger) LogTrace(msg string) {}
+
+type memRepo struct {
+	stock         map[int]models.StockEntity
+	subscriptions []*models.SubscriptionEntity
+	notifications []*models.NotificationEntity
+	// insertErr fails notification inserts
+	insertErr error
+}
+
+func newRepo() *memRepo {
+	return &memRepo{stock: map[int]models.StockEntity{}}
+}
+
+func (m *memRepo) GetStock(ctx context.Context, switchID int) (*models.StockEntity, error) {
+	e, ok := m.stock[switchID]
+	if !ok {
+		return nil, nil
+	}
+	e.Brand, e.Name = catalog[switchID].Manufacturer, catalog[switchID].Model
+	return &e, nil
+}
+
+func (m *memRepo) SaveStock(ctx context.Context, e models.StockEntity) error {
+	m.stock[e.SwitchID] = e
+	return nil
+}
+
+func (m *memRepo) filter(keep func(e models.SubscriptionEntity) bool) []models.SubscriptionEntity {
+	res := []models.SubscriptionEntity{}
+	for _, e := range m.subscriptions {
+		if e != nil && keep(*e) {
+			res = append(res, *e)
+		}
+	}
+	return res
+}
+
+func (m *memRepo) Subscriptions(ctx context.Context, owner string) ([]models.SubscriptionEntity, error) {
+	return m.filter(func(e models.SubscriptionEntity) bool { return e.Owner == owner }), nil
+}
+
+func (m *memRepo) CountSubscriptions(ctx context.Context, subscriber string) (int, int, error) {
+	total := m.filter(func(e models.SubscriptionEntity) bool { return e.Subscriber == subscriber })
+	pending := m.filter(func(e models.SubscriptionEntity) bool { return e.Subscriber == subscriber && !e.Confirmed })
+	return len(total), len(pending), nil
+}
+
+func (m *memRepo) PurgePending(ctx context.Context, subscriber string, before time.Time) error {
+	for i, e := range m.subscriptions {
+		if e != nil && e.Subscriber == subscriber && !e.Confirmed && e.CreatedAt.Before(before) {
+			m.subscriptions[i] = nil
+		}
+	}
+	return nil
+}
+
+func (m *memRepo) SwitchSubscriptions(ctx context.Context, switchID int) ([]models.SubscriptionEntity, error) {
+	return m.filter(func(e models.SubscriptionEntity) bool { return e.SwitchID == switchID && e.Confirmed }), nil
+}
+
+func (m *memRepo) GetSubscription(ctx context.Context, id int) (*models.SubscriptionEntity, error) {
+	if id < 1 || id > len(m.subscriptions) || m.subscriptions[id-1] == nil {
+		return nil, nil
+	}
+	res := *m.subscriptions[id-1]
+	return &res, nil
+}
+
+func (m *memRepo) SubscriptionByToken(ctx context.Context, token string) (*models.SubscriptionEntity, error) {
+	res := m.filter(func(e models.SubscriptionEntity) bool { return e.Token == token })
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return &res[0], nil
+}
+
+func (m *memRepo) InsertSubscription(ctx context.Context, e models.SubscriptionEntity) (*int, error) {
+	for _, s := range m.subscriptions {
+		if s != nil && s.Subscriber == e.Subscriber && s.SwitchID == e.SwitchID && s.Kind == e.Kind && s.Channel == e.Channel &&
+			(s.Price == nil) == (e.Price == nil) && (s.Price == nil || s.Price.Currency == e.Price.Currency) {
+			return nil, nil
+		}
+	}
+	e.ID = len(m.subscriptions) + 1
+	m.subscriptions = append(m.subscriptions, &e)
+	return &e.ID, nil
+}
+
+func (m *memRepo) ConfirmSubscription(ctx context.Context, id int, triggered bool) error {
+	s := m.subscriptions[id-1]
+	if !s.Confirmed {
+		s.Confirmed, s.Triggered = true, triggered
+	}
+	return nil
+}
+
+func (m *memRepo) DeleteSubscription(ctx context.Context, id int) (bool, error) {
+	ok := m.subscriptions[id-1] != nil
+	m.subscriptions[id-1] = nil
+	return ok, nil
+}
+
+func (m *memRepo) TriggerAndNotify(ctx context.Context, id int, e models.NotificationEntity) (*int, error) {
+	s := m.subscriptions[id-1]
+	if s.Triggered {
+		return nil, nil
+	}
+	if m.insertErr != nil {
+		return nil, m.insertErr
+	}
+	s.Triggered = true
+	e.ID = len(m.notifications) + 1
+	m.notifications = append(m.notifications, &e)
+	return &e.ID, nil
+}
+
+func (m *memRepo) Rearm(ctx context.Context, id int) error {
+	m.subscriptions[id-1].Triggered = false
+	return nil
+}
+
+func (m *memRepo) Notifications(ctx context.Context, owner string, unreadOnly bool) ([]models.NotificationEntity, error) {
+	res := []models.NotificationEntity{}
+	for _, e := range m.notifications {
+		if e.Owner == owner && (!unreadOnly || e.ReadAt == nil) {
+			res = append(res, *e)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].ID > res[j].ID })
+	return res, nil
+}
+
+func (m *memRepo) GetNotification(ctx context.Context, id int) (*models.NotificationEntity, error) {
+	if id < 1 || id > len(m.notifications) {
+		return nil, nil
+	}
+	res := *m.notifications[id-1]
+	return &res, nil
+}
+
+func (m *memRepo) MarkRead(ctx context.Context, id int) error {
+	now := time.Now()
+	m.notifications[id-1].ReadAt = &now
+	return nil
+}
+
+// fakeCatalog knows switches by id, Cherry Red is an alias of Cherry MX Red
+type fakeCatalog map[int]switchmodels.SwitchEntity
+
+func (f fakeCatalog) GetID(ctx context.Context, brand, name string) (*int, error) {
+	if brand == "Cherry" && name == "Red" {
+		brand, name = "Cherry", "MX Red"
+	}
+	for id, sw := range f {
+		if sw.Manufacturer == brand && sw.Model == name {
+			return &id, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f fakeCatalog) GetSingle(ctx context.Context, id int) (*switchmodels.SwitchEntity, error) {
+	sw, ok := f[id]
+	if !ok {
+		return nil, nil
+	}
+	return &sw, nil
+}
+
+// fakeAccounts knows users by id
+type fakeAccounts map[int]usermodels.User
+
+func (f fakeAccounts) Get(ctx context.Context, id int) (*usermodels.User, *common.AppError) {
+	u, ok := f[id]
+	if !ok {
+		e := common.NewError(common.ErrNotFound, "user not found")
+		return nil, &e
+	}
+	return &u, nil
+}
+
+type fakeSender struct {
+	messages []mail.Message
+	err      error
+}
+
+func (f *fakeSender) Send(ctx context.Context, msg mail.Message) error {
+	f.messages = append(f.messages, msg)
+	return f.err
+}
+
+// token gives the token of the link in the last message
+func (f *fakeSender) token(t *testing.T) string {
+	t.Helper()
+	if len(f.messages) == 0 {
+		t.Fatal("expected a mail")
+	}
+	body := f.messages[len(f.messages)-1].Body
+	_, raw, ok := strings.Cut(body, "token=")
+	if !ok {
+		t.Fatalf("expected a link with token in %q", body)
+	}
+	raw, _, _ = strings.Cut(raw, "\n")
+	token, err := url.QueryUnescape(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+type sent struct {
+	target       string
+	notification models.Notification
+}
+
+type fakeNotifier struct {
+	sent []sent
+	err  error
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, target string, notification models.Notification) error {
+	f.sent = append(f.sent, sent{target: target, notification: notification})
+	return f.err
+}
+
+var (
+	catalog = fakeCatalog{
+		1: {ID: 1, Manufacturer: "Gateron", Model: "Ink Black"},
+		2: {ID: 2, Manufacturer: "Cherry", Model: "MX Red"},
+	}
+
+	jane = authz.Principal{Subject: "user:1", Name: "jane@example.com", Role: authz.RoleViewer}
+	john = authz.Principal{Subject: "user:2", Name: "john@example.com", Role: authz.RoleViewer}
+	eve  = authz.Principal{Subject: "user:3", Name: "eve@example.com", Role: authz.RoleViewer}
+	bot  = authz.Principal{Subject: "key:1", Name: "price bot", Role: authz.RoleModerator}
+
+	accounts = fakeAccounts{
+		1: {ID: 1, Email: "jane@example.com", EmailVerified: true},
+		2: {ID: 2, Email: "john@example.com", EmailVerified: true},
+		3: {ID: 3, Email: "eve@example.com"},
+	}
+)
+
+func as(p authz.Principal) context.Context {
+	return authz.WithPrincipal(context.Background(), p)
+}
+
+func usd(amount int64) *models.Money {
+	return &models.Money{Amount: amount, Currency: "USD"}
+}
+
+func newService(repo *memRepo, sender mail.Sender) corealerts.Service {
+	return alerts.New(fakeLogger{}, repo, catalog, accounts, authzpolicy.NewRolePolicy(), sender, "https://switches.test/")
+}
+
+func TestSetStock(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		brand    string
+		stock    models.NewStock
+		expected struct {
+			stock models.Stock
+			err   error
+		}
+	}{
+		{
+			name:  "by alias",
+			ctx:   as(bot),
+			brand: "Cherry",
+			stock: models.NewStock{Price: usd(45), InStock: true},
+			expected: struct {
+				stock models.Stock
+				err   error
+			}{stock: models.Stock{Brand: "Cherry", Name: "MX Red", Price: usd(45), InStock: true}},
+		},
+		{
+			name:  "price not known",
+			ctx:   as(bot),
+			brand: "Cherry",
+			stock: models.NewStock{InStock: false},
+			expected: struct {
+				stock models.Stock
+				err   error
+			}{stock: models.Stock{Brand: "Cherry", Name: "MX Red"}},
+		},
+		{
+			name:  "viewer",
+			ctx:   as(jane),
+			brand: "Cherry",
+			stock: models.NewStock{Price: usd(45), InStock: true},
+			expected: struct {
+				stock models.Stock
+				err   error
+			}{err: common.ErrForbidden},
+		},
+		{
+			name:  "invalid currency",
+			ctx:   as(bot),
+			brand: "Cherry",
+			stock: models.NewStock{Price: &models.Money{Amount: 45, Currency: "usd"}, InStock: true},
+			expected: struct {
+				stock models.Stock
+				err   error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:  "free",
+			ctx:   as(bot),
+			brand: "Cherry",
+			stock: models.NewStock{Price: usd(0), InStock: true},
+			expected: struct {
+				stock models.Stock
+				err   error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:  "unknown switch",
+			ctx:   as(bot),
+			brand: "Kailh",
+			stock: models.NewStock{InStock: true},
+			expected: struct {
+				stock models.Stock
+				err   error
+			}{err: common.ErrNotFound},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := newService(newRepo(), &fakeSender{})
+			res, err := service.SetStock(test.ctx, test.brand, "Red", test.stock)
+			if test.expected.err != nil {
+				if err == nil || err.Errtype != test.expected.err {
+					t.Fatalf("expected error of type %v, got %v", test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			res.UpdatedAt = test.expected.stock.UpdatedAt
+			if !reflect.DeepEqual(*res, test.expected.stock) {
+				t.Errorf("expected stock %+v, got %+v", test.expected.stock, *res)
+			}
+
+			stored, err := service.Stock(context.Background(), "Cherry", "MX Red")
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			stored.UpdatedAt = test.expected.stock.UpdatedAt
+			if !reflect.DeepEqual(*stored, test.expected.stock) {
+				t.Errorf("expected stored stock %+v, got %+v", test.expected.stock, *stored)
+			}
+		})
+	}
+
+	if _, err := newService(newRepo(), &fakeSender{}).Stock(context.Background(), "Gateron", "Ink Black"); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected switch without stock not to be found, got %v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		subscription models.NewSubscription
+		expected     struct {
+			subscription models.Subscription
+			token        bool
+			mailed       bool
+			err          error
+		}
+	}{
+		{
+			name:         "inbox by alias",
+			ctx:          as(jane),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "Red", Kind: models.KindPriceDrop, Channel: models.ChannelInbox, Price: usd(50)},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{subscription: models.Subscription{ID: 1, Brand: "Cherry", Name: "MX Red", Kind: models.KindPriceDrop, Channel: models.ChannelInbox, Subscriber: "user:1", Price: usd(50), Confirmed: true}, token: true},
+		},
+		{
+			name:         "email without account",
+			ctx:          context.Background(),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Target: " Ann@Example.com"},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{subscription: models.Subscription{ID: 1, Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Subscriber: "ann@example.com"}, mailed: true},
+		},
+		{
+			name:         "email to verified address of the account",
+			ctx:          as(jane),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Target: "jane@example.com"},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{subscription: models.Subscription{ID: 1, Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Subscriber: "jane@example.com", Confirmed: true}, token: true},
+		},
+		{
+			name:         "email to another address than of the account",
+			ctx:          as(jane),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Target: "john@example.com"},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{subscription: models.Subscription{ID: 1, Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Subscriber: "john@example.com"}, mailed: true},
+		},
+		{
+			name:         "email not verified",
+			ctx:          as(eve),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Target: "eve@example.com"},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{subscription: models.Subscription{ID: 1, Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Subscriber: "eve@example.com"}, mailed: true},
+		},
+		{
+			name:         "webhook with change there already",
+			ctx:          context.Background(),
+			subscription: models.NewSubscription{Brand: "Gateron", Name: "Ink Black", Kind: models.KindPriceDrop, Channel: models.ChannelWebhook, Target: "https://hooks.example/kb", Price: usd(70)},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{subscription: models.Subscription{ID: 1, Brand: "Gateron", Name: "Ink Black", Kind: models.KindPriceDrop, Channel: models.ChannelWebhook, Subscriber: "https://hooks.example/kb", Price: usd(70), Confirmed: true, Triggered: true}, token: true},
+		},
+		{
+			name:         "inbox without account",
+			ctx:          context.Background(),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelInbox},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{err: common.ErrUnauthorized},
+		},
+		{
+			name:         "inbox with target",
+			ctx:          as(jane),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelInbox, Target: "john@example.com"},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:         "unknown switch",
+			ctx:          as(jane),
+			subscription: models.NewSubscription{Brand: "Kailh", Name: "Box White", Kind: models.KindBackInStock, Channel: models.ChannelInbox},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{err: common.ErrNotFound},
+		},
+		{
+			name:         "email with display name",
+			ctx:          context.Background(),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Target: "Ann <ann@example.com>"},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:         "webhook not on the web",
+			ctx:          context.Background(),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelWebhook, Target: "file:///etc/passwd"},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:         "price drop without price",
+			ctx:          as(jane),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindPriceDrop, Channel: models.ChannelInbox},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:         "restock with price",
+			ctx:          as(jane),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelInbox, Price: usd(50)},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:         "invalid currency",
+			ctx:          as(jane),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindPriceDrop, Channel: models.ChannelInbox, Price: &models.Money{Amount: 50, Currency: "usd"}},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:         "unknown channel",
+			ctx:          as(jane),
+			subscription: models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: "sms"},
+			expected: struct {
+				subscription models.Subscription
+				token        bool
+				mailed       bool
+				err          error
+			}{err: common.ErrBadRequest},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo, sender := newRepo(), &fakeSender{}
+			repo.stock[1] = models.StockEntity{SwitchID: 1, Price: usd(65), InStock: true}
+			service := newService(repo, sender)
+
+			res, err := service.Subscribe(test.ctx, test.subscription)
+			if test.expected.err != nil {
+				if err == nil || err.Errtype != test.expected.err {
+					t.Fatalf("expected error of type %v, got %v", test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if (res.Token != "") != test.expected.token {
+				t.Errorf("expected token given %v, got %q", test.expected.token, res.Token)
+			}
+			if mailed := len(sender.messages) == 1 && sender.messages[0].To == test.expected.subscription.Subscriber; mailed != test.expected.mailed {
+				t.Errorf("expected confirmation mailed %v, got %+v", test.expected.mailed, sender.messages)
+			}
+
+			res.CreatedAt, res.Token = test.expected.subscription.CreatedAt, ""
+			if !reflect.DeepEqual(*res, test.expected.subscription) {
+				t.Errorf("expected subscription %+v, got %+v", test.expected.subscription, *res)
+			}
+		})
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	repo, sender := newRepo(), &fakeSender{}
+	service := newService(repo, sender)
+	restock := models.NewSubscription{Brand: "Gateron", Name: "Ink Black", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Target: "ann@example.com"}
+
+	if _, err := service.Subscribe(context.Background(), restock); err != nil {
+		t.Fatal(err)
+	}
+	if subject := sender.messages[0].Subject; subject != "Confirm your alert for Gateron Ink Black" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	token := sender.token(t)
+
+	if _, err := service.Confirm(context.Background(), "made-up"); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected unknown token not to confirm, got %v", err)
+	}
+
+	// a change before confirming just triggers the subscription
+	repo.stock[1] = models.StockEntity{SwitchID: 1, InStock: true}
+	res, err := service.Confirm(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Confirmed || !res.Triggered || res.Token != token {
+		t.Errorf("expected confirmed and triggered subscription with its token, got %+v", *res)
+	}
+	if again, err := service.Confirm(context.Background(), token); err != nil || !again.Confirmed {
+		t.Errorf("expected confirming twice to be fine, got %v", err)
+	}
+
+	// pending subscriptions are bounded until their confirmations expire
+	pending := []models.NewSubscription{
+		{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Target: "bob@example.com"},
+		{Brand: "Cherry", Name: "MX Red", Kind: models.KindPriceDrop, Channel: models.ChannelEmail, Target: "bob@example.com", Price: usd(40)},
+		{Brand: "Gateron", Name: "Ink Black", Kind: models.KindPriceDrop, Channel: models.ChannelEmail, Target: "bob@example.com", Price: usd(50)},
+	}
+	for _, p := range pending {
+		if _, err := service.Subscribe(context.Background(), p); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	flood := models.NewSubscription{Brand: "Gateron", Name: "Ink Black", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Target: "bob@example.com"}
+	if _, err := service.Subscribe(context.Background(), flood); err == nil || err.Reason != alerts.ErrTooManyPending.Reason {
+		t.Fatalf("expected too many pending subscriptions, got %v", err)
+	}
+	expired := sender.token(t)
+	for _, s := range repo.subscriptions {
+		if s.Subscriber == "bob@example.com" {
+			s.CreatedAt = s.CreatedAt.Add(-alerts.ConfirmTTL - time.Minute)
+		}
+	}
+	if _, err := service.Confirm(context.Background(), expired); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected expired confirmation to fail, got %v", err)
+	}
+	if _, err := service.Subscribe(context.Background(), flood); err != nil {
+		t.Errorf("expected expired confirmations to be purged, got %v", err)
+	}
+}
+
+func TestConfirmMailFailure(t *testing.T) {
+	repo := newRepo()
+	service := newService(repo, &fakeSender{err: errors.New("connection refused")})
+
+	restock := models.NewSubscription{Brand: "Gateron", Name: "Ink Black", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Target: "ann@example.com"}
+	if _, err := service.Subscribe(context.Background(), restock); err == nil {
+		t.Fatal("expected failed mail to be reported")
+	}
+	if total, _, _ := repo.CountSubscriptions(context.Background(), "ann@example.com"); total != 0 {
+		t.Errorf("expected subscription nobody can confirm to be dropped, got %d", total)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	repo := newRepo()
+	service := newService(repo, &fakeSender{})
+	sub, _ := service.Subscribe(as(jane), models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelInbox})
+
+	if _, err := service.Subscribe(as(jane), models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelInbox}); err == nil || err.Errtype != common.ErrConflict {
+		t.Errorf("expected the same subscription to conflict, got %v", err)
+	}
+	if err := service.Unsubscribe(as(john), sub.ID); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected subscription of jane not to be found for john, got %v", err)
+	}
+	if res, _ := service.Subscriptions(as(john)); len(res) != 0 {
+		t.Errorf("expected john to have no subscriptions, got %+v", res)
+	}
+	if res, _ := service.Subscriptions(as(jane)); len(res) != 1 || res[0].Token != sub.Token {
+		t.Errorf("expected subscription of jane with its token, got %+v", res)
+	}
+
+	repo.notifications = append(repo.notifications, &models.NotificationEntity{ID: 1, Owner: "user:1", Subject: "Cherry MX Red is back in stock"})
+	if err := service.MarkRead(as(john), 1); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected notification of jane not to be found for john, got %v", err)
+	}
+	if err := service.MarkRead(as(jane), 1); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if res, _ := service.Inbox(as(jane), true); len(res) != 0 {
+		t.Errorf("expected no unread notifications, got %+v", res)
+	}
+	if res, _ := service.Inbox(as(jane), false); len(res) != 1 || res[0].ReadAt == nil {
+		t.Errorf("expected read notification in the inbox, got %+v", res)
+	}
+
+	if err := service.Unsubscribe(as(jane), sub.ID); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if res, _ := service.Subscriptions(as(jane)); len(res) != 0 {
+		t.Errorf("expected no subscriptions left, got %+v", res)
+	}
+
+	hook, _ := service.Subscribe(context.Background(), models.NewSubscription{Brand: "Cherry", Name: "MX Red", Kind: models.KindBackInStock, Channel: models.ChannelWebhook, Target: "https://hooks.example/kb"})
+	if err := service.UnsubscribeToken(context.Background(), "made-up"); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected unknown token not to unsubscribe, got %v", err)
+	}
+	if err := service.UnsubscribeToken(context.Background(), hook.Token); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if total, _, _ := repo.CountSubscriptions(context.Background(), "https://hooks.example/kb"); total != 0 {
+		t.Errorf("expected webhook subscription to be removed, got %d", total)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	repo, sender := newRepo(), &fakeSender{}
+	email, webhook := &fakeNotifier{}, &fakeNotifier{}
+	service := newService(repo, sender)
+	evaluator := alerts.NewEvaluator(fakeLogger{}, repo, catalog, map[models.Channel]corealerts.Notifier{
+		models.ChannelEmail:   email,
+		models.ChannelWebhook: webhook,
+	}, "https://switches.test")
+
+	service.Subscribe(context.Background(), models.NewSubscription{Brand: "Gateron", Name: "Ink Black", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Target: "ann@example.com"})
+	if _, err := service.Confirm(context.Background(), sender.token(t)); err != nil {
+		t.Fatal(err)
+	}
+	// bob never confirms, so nothing is mailed to him
+	service.Subscribe(context.Background(), models.NewSubscription{Brand: "Gateron", Name: "Ink Black", Kind: models.KindBackInStock, Channel: models.ChannelEmail, Target: "bob@example.com"})
+	service.Subscribe(as(john), models.NewSubscription{Brand: "Gateron", Name: "Ink Black", Kind: models.KindPriceDrop, Channel: models.ChannelWebhook, Target: "https://hooks.example/kb", Price: usd(60)})
+	service.Subscribe(as(john), models.NewSubscription{Brand: "Gateron", Name: "Ink Black", Kind: models.KindPriceDrop, Channel: models.ChannelInbox, Price: &models.Money{Amount: 60, Currency: "EUR"}})
+
+	steps := []struct {
+		name     string
+		stock    models.NewStock
+		expected struct {
+			email   []string
+			webhook []string
+		}
+	}{
+		{
+			name:  "back in stock above target",
+			stock: models.NewStock{Price: usd(65), InStock: true},
+			expected: struct {
+				email   []string
+				webhook []string
+			}{email: []string{"Gateron Ink Black is back in stock"}},
+		},
+		{
+			name:  "price not known",
+			stock: models.NewStock{InStock: true},
+		},
+		{
+			name:  "dropped",
+			stock: models.NewStock{Price: usd(55), InStock: true},
+			expected: struct {
+				email   []string
+				webhook []string
+			}{webhook: []string{"Gateron Ink Black dropped to 0.55 USD per switch"}},
+		},
+		{
+			name:  "still cheap",
+			stock: models.NewStock{Price: usd(58), InStock: true},
+		},
+		{
+			name:  "price back up",
+			stock: models.NewStock{Price: usd(70), InStock: true},
+		},
+		{
+			name:  "exactly at target",
+			stock: models.NewStock{Price: usd(60), InStock: true},
+			expected: struct {
+				email   []string
+				webhook []string
+			}{webhook: []string{"Gateron Ink Black dropped to 0.60 USD per switch"}},
+		},
+		{
+			name:  "sold out",
+			stock: models.NewStock{Price: usd(60), InStock: false},
+		},
+		{
+			name:  "restocked at the same price",
+			stock: models.NewStock{Price: usd(60), InStock: true},
+			expected: struct {
+				email   []string
+				webhook []string
+			}{email: []string{"Gateron Ink Black is back in stock"}, webhook: []string{"Gateron Ink Black dropped to 0.60 USD per switch"}},
+		},
+	}
+
+	for _, step := range steps {
+		email.sent, webhook.sent = nil, nil
+		if _, err := service.SetStock(as(bot), "Gateron", "Ink Black", step.stock); err != nil {
+			t.Fatalf("%s: unexpected error %v", step.name, err)
+		}
+		if err := evaluator.Evaluate(context.Background(), "Gateron", "Ink Black"); err != nil {
+			t.Fatalf("%s: unexpected error %v", step.name, err)
+		}
+
+		var emails, webhooks []string
+		for _, s := range email.sent {
+			if s.target != "ann@example.com" {
+				t.Errorf("%s: expected mail to the confirmed address only, got %s", step.name, s.target)
+			}
+			if !strings.HasPrefix(s.notification.Unsubscribe, "https://switches.test/alerts/unsubscribe?token=") {
+				t.Errorf("%s: expected unsubscribe link, got %q", step.name, s.notification.Unsubscribe)
+			}
+			emails = append(emails, s.notification.Subject)
+		}
+		for _, s := range webhook.sent {
+			if s.target != "https://hooks.example/kb" {
+				t.Errorf("%s: expected webhook of john, got %s", step.name, s.target)
+			}
+			webhooks = append(webhooks, s.notification.Subject)
+		}
+		if !reflect.DeepEqual(emails, step.expected.email) || !reflect.DeepEqual(webhooks, step.expected.webhook) {
+			t.Errorf("%s: expected mails %v and webhooks %v, got %v and %v", step.name, step.expected.email, step.expected.webhook, emails, webhooks)
+		}
+	}
+
+	inbox, _ := service.Inbox(as(john), false)
+	if len(inbox) != 3 {
+		t.Fatalf("expected every webhook notification in the inbox of john, got %d", len(inbox))
+	}
+	if !reflect.DeepEqual(inbox[0].Price, usd(60)) || inbox[0].Unsubscribe != "" {
+		t.Errorf("expected the newest notification at 0.60 USD without a link, got %+v", inbox[0])
+	}
+}
+
+func TestEvaluateDeliveryFailure(t *testing.T) {
+	repo := newRepo()
+	failing := &fakeNotifier{err: errors.New("connection refused")}
+	service := newService(repo, &fakeSender{})
+	evaluator := alerts.NewEvaluator(fakeLogger{}, repo, catalog, map[models.Channel]corealerts.Notifier{models.ChannelWebhook: failing}, "https://switches.test")
+
+	service.Subscribe(as(jane), models.NewSubscription{Brand: "Gateron", Name: "Ink Black", Kind: models.KindBackInStock, Channel: models.ChannelWebhook, Target: "https://hooks.example/kb"})
+	service.SetStock(as(bot), "Gateron", "Ink Black", models.NewStock{Price: usd(65), InStock: true})
+
+	if err := evaluator.Evaluate(context.Background(), "Gateron", "Ink Black"); err == nil {
+		t.Error("expected failed delivery to be reported")
+	}
+	if err := evaluator.Evaluate(context.Background(), "Gateron", "Ink Black"); err != nil || len(failing.sent) != 1 {
+		t.Errorf("expected the change to be notified once, got %d deliveries and %v", len(failing.sent), err)
+	}
+	if inbox, _ := service.Inbox(as(jane), false); len(inbox) != 1 {
+		t.Errorf("expected notification in the inbox despite failed delivery, got %d", len(inbox))
+	}
+}
+
+func TestEvaluateStoreFailure(t *testing.T) {
+	repo := newRepo()
+	webhook := &fakeNotifier{}
+	service := newService(repo, &fakeSender{})
+	evaluator := alerts.NewEvaluator(fakeLogger{}, repo, catalog, map[models.Channel]corealerts.Notifier{models.ChannelWebhook: webhook}, "https://switches.test")
+
+	service.Subscribe(context.Background(), models.NewSubscription{Brand: "Gateron", Name: "Ink Black", Kind: models.KindBackInStock, Channel: models.ChannelWebhook, Target: "https://hooks.example/kb"})
+	service.SetStock(as(bot), "Gateron", "Ink Black", models.NewStock{Price: usd(65), InStock: true})
+
+	repo.insertErr = errors.New("connection reset")
+	if err := evaluator.Evaluate(context.Background(), "Gateron", "Ink Black"); err == nil {
+		t.Error("expected failed insert to be reported")
+	}
+
+	repo.insertErr = nil
+	if err := evaluator.Evaluate(context.Background(), "Gateron", "Ink Black"); err != nil || len(webhook.sent) != 1 {
+		t.Errorf("expected the change to be notified on the next evaluation, got %d deliveries and %v", len(webhook.sent), err)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received map[string]any
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&received)
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer server.Close()
+
+	notification := models.Notification{
+		ID:          7,
+		Kind:        models.KindBackInStock,
+		Brand:       "Gateron",
+		Name:        "Ink Black",
+		Subject:     "Gateron Ink Black is back in stock",
+		Price:       usd(65),
+		Unsubscribe: "https://switches.test/alerts/unsubscribe?token=abc",
+	}
+
+	notifier := alerts.NewWebhookNotifier(server.Client())
+	if err := notifier.Notify(context.Background(), server.URL+"/hook", notification); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("expected json, got %s", contentType)
+	}
+	if received["kind"] != "back_in_stock" || received["switch"].(map[string]any)["name"] != "Ink Black" ||
+		received["price"].(map[string]any)["amount"] != 0.65 || received["unsubscribe"] != notification.Unsubscribe {
+		t.Errorf("expected notification in the payload, got %v", received)
+	}
+
+	if err := notifier.Notify(context.Background(), server.URL+"/gone", notification); err == nil {
+		t.Error("expected unsuccessful status to be an error")
+	}
+
+	public := alerts.NewWebhookNotifier(alerts.PublicClient(time.Second))
+	if err := public.Notify(context.Background(), server.URL+"/hook", notification); !errors.Is(err, alerts.ErrPrivateAddress) {
+		t.Errorf("expected loopback webhook to be refused, got %v", err)
+	}
+}
+
+func TestMailNotifier(t *testing.T) {
+	sender := &fakeSender{}
+	notification := models.Notification{
+		Subject:     "Gateron Ink Black is back in stock",
+		Body:        "Gateron Ink Black is in stock again.",
+		Unsubscribe: "https://switches.test/alerts/unsubscribe?token=abc",
+	}
+
+	if err := alerts.NewMailNotifier(sender).Notify(context.Background(), "ann@example.com", notification); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := []mail.Message{{
+		To:      "ann@example.com",
+		Subject: notification.Subject,
+		Body:    notification.Body + "\n\nTo stop these alerts, follow https://switches.test/alerts/unsubscribe?token=abc",
+	}}
+	if !reflect.DeepEqual(sender.messages, expected) {
+		t.Errorf("expected messages %+v, got %+v", expected, sender.messages)
+	}
+}
+
+// fakeStock only tells whether writes succeed
+type fakeStock struct {
+	corealerts.Service
+	err *common.AppError
+}
+
+func (f fakeStock) SetStock(ctx context.Context, brand, name string, stock models.NewStock) (*models.Stock, *common.AppError) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	// the record is kept under the name the catalog knows
+	if name == "Red" {
+		name = "MX Red"
+	}
+	return &models.Stock{Brand: brand, Name: name, InStock: stock.InStock}, nil
+}
+
+type fakeEvaluator chan string
+
+func (f fakeEvaluator) Evaluate(ctx context.Context, brand, name string) error {
+	f <- brand + " " + name
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	evaluated := make(fakeEvaluator, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	service := alerts.Watch(fakeStock{}, evaluated, fakeLogger{})
+
+	service.SetStock(ctx, "Gateron", "Ink Black", models.NewStock{InStock: true})
+	service.SetStock(ctx, "Cherry", "Red", models.NewStock{})
+	// evaluation outlives the request which changed the stock
+	cancel()
+
+	var res []string
+	for range 2 {
+		select {
+		case sw := <-evaluated:
+			res = append(res, sw)
+		case <-time.After(time.Second):
+			t.Fatalf("expected 2 evaluations, got %v", res)
+		}
+	}
+	sort.Strings(res)
+	if !reflect.DeepEqual(res, []string{"Cherry MX Red", "Gateron Ink Black"}) {
+		t.Errorf("expected every changed switch to be evaluated, got %v", res)
+	}
+
+	failing := alerts.Watch(fakeStock{err: &common.AppError{Errtype: common.ErrForbidden}}, evaluated, fakeLogger{})
+	failing.SetStock(context.Background(), "Gateron", "Ink Black", models.NewStock{InStock: true})
+	select {
+	case sw := <-evaluated:
+		t.Errorf("expected failed change not to be evaluated, got %s", sw)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
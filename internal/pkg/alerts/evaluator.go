@@ -0,0 +1,136 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/alerts"
+	"kbswitch/internal/core/alerts/models"
+	"kbswitch/internal/core/common/logging"
+	"strings"
+	"time"
+)
+
+// NewEvaluator gives an evaluator delivering through notifiers of their channels, notifications of channels without one
+// only reach the inbox. Links to remove subscriptions point to baseURL
+func NewEvaluator(logger logging.Logger, repo alerts.Repo, catalog alerts.Catalog, notifiers map[models.Channel]alerts.Notifier,
+	baseURL string) alerts.Evaluator {
+	return evaluator{
+		repo:      repo,
+		catalog:   catalog,
+		notifiers: notifiers,
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		logger:    logger,
+	}
+}
+
+type evaluator struct {
+	repo      alerts.Repo
+	catalog   alerts.Catalog
+	notifiers map[models.Channel]alerts.Notifier
+	baseURL   string
+	logger    logging.Logger
+}
+
+// holds tells whether the change subscription awaits is there according to stock, which is nil for switches without one
+func holds(subscription models.SubscriptionEntity, stock *models.StockEntity) bool {
+	if stock == nil || !stock.InStock {
+		return false
+	}
+	if subscription.Kind == models.KindPriceDrop {
+		return stock.Price != nil && stock.Price.Currency == subscription.Price.Currency && stock.Price.Amount <= subscription.Price.Amount
+	}
+	return true
+}
+
+func price(m models.Money) string {
+	return fmt.Sprintf("%d.%02d %s", m.Amount/100, m.Amount%100, m.Currency)
+}
+
+func notification(subscription models.SubscriptionEntity, stock models.StockEntity) models.NotificationEntity {
+	sw := subscription.Brand + " " + subscription.Name
+	n := models.NotificationEntity{
+		Owner:          subscription.Owner,
+		SubscriptionID: &subscription.ID,
+		Kind:           subscription.Kind,
+		Brand:          subscription.Brand,
+		Name:           subscription.Name,
+		Price:          stock.Price,
+		CreatedAt:      time.Now(),
+	}
+
+	switch subscription.Kind {
+	case models.KindPriceDrop:
+		n.Subject = fmt.Sprintf("%s dropped to %s per switch", sw, price(*stock.Price))
+		n.Body = fmt.Sprintf("%s is in stock at %s per switch, which is at or below your target of %s.",
+			sw, price(*stock.Price), price(*subscription.Price))
+	case models.KindBackInStock:
+		n.Subject = fmt.Sprintf("%s is back in stock", sw)
+		n.Body = fmt.Sprintf("%s is in stock again.", sw)
+		if stock.Price != nil {
+			n.Body = fmt.Sprintf("%s is in stock again at %s per switch.", sw, price(*stock.Price))
+		}
+	}
+	return n
+}
+
+// Evaluate implements alerts.Evaluator.
+func (e evaluator) Evaluate(ctx context.Context, brand, name string) error {
+	id, err := e.catalog.GetID(ctx, brand, name)
+	if err != nil {
+		return err
+	}
+	if id == nil {
+		e.logger.LogTrace(fmt.Sprintf("switch %s %s is gone, nothing to evaluate", brand, name))
+		return nil
+	}
+
+	subscriptions, err := e.repo.SwitchSubscriptions(ctx, *id)
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	stock, err := e.repo.GetStock(ctx, *id)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, subscription := range subscriptions {
+		// a subscription notifies once when the change happens and is armed again when it is undone
+		if !holds(subscription, stock) {
+			if err := e.repo.Rearm(ctx, subscription.ID); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		n := notification(subscription, *stock)
+		id, err := e.repo.TriggerAndNotify(ctx, subscription.ID, n)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if id == nil {
+			continue
+		}
+		n.ID = *id
+		e.logger.LogTrace(fmt.Sprintf("subscription %d triggered notification %d", subscription.ID, n.ID))
+
+		notifier, ok := e.notifiers[subscription.Channel]
+		if !ok {
+			continue
+		}
+		delivered := toNotification(n)
+		delivered.Unsubscribe = link(e.baseURL, "/alerts/unsubscribe", subscription.Token)
+		if err := notifier.Notify(ctx, subscription.Subscriber, delivered); err != nil {
+			e.logger.LogError(fmt.Sprintf("notification %d through %s failed: %s", n.ID, subscription.Channel, err.Error()))
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,131 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/alerts"
+	"kbswitch/internal/core/alerts/models"
+	"kbswitch/internal/core/mail"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+var ErrPrivateAddress = errors.New("webhooks can't reach private addresses")
+
+// NewMailNotifier gives a notifier mailing notifications through sender
+func NewMailNotifier(sender mail.Sender) alerts.Notifier {
+	return mailNotifier{sender: sender}
+}
+
+type mailNotifier struct {
+	sender mail.Sender
+}
+
+// Notify implements alerts.Notifier.
+func (n mailNotifier) Notify(ctx context.Context, target string, notification models.Notification) error {
+	body := notification.Body
+	if notification.Unsubscribe != "" {
+		body += "\n\nTo stop these alerts, follow " + notification.Unsubscribe
+	}
+	return n.sender.Send(ctx, mail.Message{To: target, Subject: notification.Subject, Body: body})
+}
+
+// NewWebhookNotifier gives a notifier posting notifications as json, see PublicClient for a client fit for user given urls
+func NewWebhookNotifier(client *http.Client) alerts.Notifier {
+	return webhookNotifier{client: client}
+}
+
+type webhookNotifier struct {
+	client *http.Client
+}
+
+type webhookSwitch struct {
+	Brand string `json:"brand"`
+	Name  string `json:"name"`
+}
+
+type webhookMoney struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+type webhookPayload struct {
+	ID      int           `json:"id"`
+	Kind    models.Kind   `json:"kind"`
+	Switch  webhookSwitch `json:"switch"`
+	Subject string        `json:"subject"`
+	Body    string        `json:"body"`
+	// Price of a single switch, it is left out when not known
+	Price       *webhookMoney `json:"price,omitempty"`
+	Unsubscribe string        `json:"unsubscribe,omitempty"`
+	CreatedAt   time.Time     `json:"createdAt"`
+}
+
+// Notify implements alerts.Notifier.
+func (n webhookNotifier) Notify(ctx context.Context, target string, notification models.Notification) error {
+	p := webhookPayload{
+		ID:          notification.ID,
+		Kind:        notification.Kind,
+		Switch:      webhookSwitch{Brand: notification.Brand, Name: notification.Name},
+		Subject:     notification.Subject,
+		Body:        notification.Body,
+		Unsubscribe: notification.Unsubscribe,
+		CreatedAt:   notification.CreatedAt,
+	}
+	if notification.Price != nil {
+		p.Price = &webhookMoney{Amount: float64(notification.Price.Amount) / 100, Currency: notification.Price.Currency}
+	}
+
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// PublicClient gives a client which refuses to connect to loopback, private and link local addresses, so user given
+// webhooks can't reach into the network the service runs in, redirects included
+func PublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+				ip.IsUnspecified() || ip.IsMulticast() {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,355 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/alerts"
+	"kbswitch/internal/core/alerts/models"
+	"kbswitch/internal/core/common/database"
+	"kbswitch/internal/core/common/logging"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func New(logger logging.Logger, pool database.DBPool) alerts.Repo {
+	return repo{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+type repo struct {
+	logger logging.Logger
+	pool   database.DBPool
+}
+
+// GetStock implements alerts.Repo.
+func (r repo) GetStock(ctx context.Context, switchID int) (*models.StockEntity, error) {
+	query := `SELECT t.switch_id, t.price, t.currency, t.in_stock, t.updated_at, s.manufacturer, s.model
+	FROM public.switch_stock t JOIN public.switches s ON s.id = t.switch_id
+	WHERE t.switch_id = $1`
+
+	var e models.StockEntity
+	var price *int64
+	var currency string
+	err := r.pool.QueryRow(ctx, query, switchID).Scan(&e.SwitchID, &price, &currency, &e.InStock, &e.UpdatedAt, &e.Brand, &e.Name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if price != nil {
+		e.Price = &models.Money{Amount: *price, Currency: currency}
+	}
+	return &e, nil
+}
+
+// SaveStock implements alerts.Repo.
+func (r repo) SaveStock(ctx context.Context, e models.StockEntity) error {
+	query := `INSERT INTO public.switch_stock (switch_id, price, currency, in_stock, updated_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (switch_id) DO UPDATE SET price = $2, currency = $3, in_stock = $4, updated_at = $5`
+
+	price, currency := money(e.Price)
+	if _, err := r.pool.Exec(ctx, query, e.SwitchID, price, currency, e.InStock, e.UpdatedAt); err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("stock of switch %d saved", e.SwitchID))
+
+	return nil
+}
+
+// money splits m into the price and currency columns
+func money(m *models.Money) (*int64, string) {
+	if m == nil {
+		return nil, ""
+	}
+	return &m.Amount, m.Currency
+}
+
+// column order must match scanSubscription, brand and name come from the joined switch
+const subscriptionColumns = `a.id, a.subscriber, a.owner, a.switch_id, a.kind, a.channel, a.price, a.currency, a.token,
+	a.confirmed, a.triggered, a.created_at, s.manufacturer, s.model`
+
+const subscriptionsFrom = ` FROM public.alert_subscriptions a JOIN public.switches s ON s.id = a.switch_id`
+
+func scanSubscription(row pgx.Row, e *models.SubscriptionEntity) error {
+	var kind, channel, currency string
+	var price *int64
+	err := row.Scan(&e.ID, &e.Subscriber, &e.Owner, &e.SwitchID, &kind, &channel, &price, &currency, &e.Token,
+		&e.Confirmed, &e.Triggered, &e.CreatedAt, &e.Brand, &e.Name)
+	if err != nil {
+		return err
+	}
+
+	e.Kind = models.Kind(kind)
+	e.Channel = models.Channel(channel)
+	if price != nil {
+		e.Price = &models.Money{Amount: *price, Currency: currency}
+	}
+	return nil
+}
+
+// column order must match scanNotification
+const notificationColumns = `id, owner, subscription_id, kind, brand, name, subject, body, price, currency, created_at, read_at`
+
+func scanNotification(row pgx.Row, e *models.NotificationEntity) error {
+	var kind, currency string
+	var price *int64
+	err := row.Scan(&e.ID, &e.Owner, &e.SubscriptionID, &kind, &e.Brand, &e.Name, &e.Subject, &e.Body, &price, &currency,
+		&e.CreatedAt, &e.ReadAt)
+	if err != nil {
+		return err
+	}
+
+	e.Kind = models.Kind(kind)
+	if price != nil {
+		e.Price = &models.Money{Amount: *price, Currency: currency}
+	}
+	return nil
+}
+
+func (r repo) subscriptions(ctx context.Context, where string, arg any) ([]models.SubscriptionEntity, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+subscriptionColumns+subscriptionsFrom+` WHERE `+where+` ORDER BY a.created_at, a.id`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.SubscriptionEntity{}
+	for rows.Next() {
+		var e models.SubscriptionEntity
+		if err := scanSubscription(rows, &e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r repo) subscription(ctx context.Context, where string, arg any) (*models.SubscriptionEntity, error) {
+	var e models.SubscriptionEntity
+	err := scanSubscription(r.pool.QueryRow(ctx, `SELECT `+subscriptionColumns+subscriptionsFrom+` WHERE `+where, arg), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// Subscriptions implements alerts.Repo.
+func (r repo) Subscriptions(ctx context.Context, owner string) ([]models.SubscriptionEntity, error) {
+	res, err := r.subscriptions(ctx, `a.owner = $1`, owner)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d subscriptions of %s read", len(res), owner))
+
+	return res, nil
+}
+
+// CountSubscriptions implements alerts.Repo.
+func (r repo) CountSubscriptions(ctx context.Context, subscriber string) (int, int, error) {
+	var total, pending int
+	err := r.pool.QueryRow(ctx, `SELECT count(*), count(*) FILTER (WHERE NOT confirmed) FROM public.alert_subscriptions
+	WHERE subscriber = $1`, subscriber).Scan(&total, &pending)
+	return total, pending, err
+}
+
+// PurgePending implements alerts.Repo.
+func (r repo) PurgePending(ctx context.Context, subscriber string, before time.Time) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM public.alert_subscriptions WHERE subscriber = $1 AND NOT confirmed AND created_at < $2`,
+		subscriber, before)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		r.logger.LogTrace(fmt.Sprintf("%d unconfirmed subscriptions purged", tag.RowsAffected()))
+	}
+
+	return nil
+}
+
+// SwitchSubscriptions implements alerts.Repo.
+func (r repo) SwitchSubscriptions(ctx context.Context, switchID int) ([]models.SubscriptionEntity, error) {
+	res, err := r.subscriptions(ctx, `a.switch_id = $1 AND a.confirmed`, switchID)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d subscriptions to switch %d read", len(res), switchID))
+
+	return res, nil
+}
+
+// GetSubscription implements alerts.Repo.
+func (r repo) GetSubscription(ctx context.Context, id int) (*models.SubscriptionEntity, error) {
+	return r.subscription(ctx, `a.id = $1`, id)
+}
+
+// SubscriptionByToken implements alerts.Repo.
+func (r repo) SubscriptionByToken(ctx context.Context, token string) (*models.SubscriptionEntity, error) {
+	return r.subscription(ctx, `a.token = $1`, token)
+}
+
+// InsertSubscription implements alerts.Repo.
+func (r repo) InsertSubscription(ctx context.Context, e models.SubscriptionEntity) (*int, error) {
+	query := `INSERT INTO public.alert_subscriptions (subscriber, owner, switch_id, kind, channel, price, currency, token,
+		confirmed, triggered, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	ON CONFLICT (subscriber, channel, switch_id, kind, currency) DO NOTHING
+	RETURNING id`
+
+	price, currency := money(e.Price)
+
+	var id int
+	err := r.pool.QueryRow(ctx, query, e.Subscriber, e.Owner, e.SwitchID, string(e.Kind), string(e.Channel), price, currency, e.Token,
+		e.Confirmed, e.Triggered, e.CreatedAt).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		r.logger.LogTrace(fmt.Sprintf("subscriber is subscribed to switch %d already", e.SwitchID))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("subscription %d inserted", id))
+
+	return &id, nil
+}
+
+// ConfirmSubscription implements alerts.Repo.
+func (r repo) ConfirmSubscription(ctx context.Context, id int, triggered bool) error {
+	_, err := r.pool.Exec(ctx, `UPDATE public.alert_subscriptions SET confirmed = true, triggered = $2 WHERE id = $1 AND NOT confirmed`,
+		id, triggered)
+	if err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("subscription %d confirmed", id))
+
+	return nil
+}
+
+// DeleteSubscription implements alerts.Repo.
+func (r repo) DeleteSubscription(ctx context.Context, id int) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM public.alert_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d subscriptions deleted", tag.RowsAffected()))
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// TriggerAndNotify implements alerts.Repo.
+func (r repo) TriggerAndNotify(ctx context.Context, id int, e models.NotificationEntity) (*int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE public.alert_subscriptions SET triggered = true WHERE id = $1 AND NOT triggered`, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, nil
+	}
+
+	query := `INSERT INTO public.alert_notifications (owner, subscription_id, kind, brand, name, subject, body, price,
+		currency, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING id`
+
+	price, currency := money(e.Price)
+
+	var notificationID int
+	err = tx.QueryRow(ctx, query, e.Owner, e.SubscriptionID, string(e.Kind), e.Brand, e.Name, e.Subject, e.Body, price,
+		currency, e.CreatedAt).Scan(&notificationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("subscription %d triggered, notification %d inserted", id, notificationID))
+
+	return &notificationID, nil
+}
+
+// Rearm implements alerts.Repo.
+func (r repo) Rearm(ctx context.Context, id int) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE public.alert_subscriptions SET triggered = false WHERE id = $1 AND triggered`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		r.logger.LogTrace(fmt.Sprintf("subscription %d rearmed", id))
+	}
+
+	return nil
+}
+
+// Notifications implements alerts.Repo.
+func (r repo) Notifications(ctx context.Context, owner string, unreadOnly bool) ([]models.NotificationEntity, error) {
+	query := `SELECT ` + notificationColumns + ` FROM public.alert_notifications
+	WHERE owner = $1 AND (NOT $2 OR read_at IS NULL)
+	ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.pool.Query(ctx, query, owner, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.NotificationEntity{}
+	for rows.Next() {
+		var e models.NotificationEntity
+		if err := scanNotification(rows, &e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d notifications of %s read", len(res), owner))
+
+	return res, nil
+}
+
+// GetNotification implements alerts.Repo.
+func (r repo) GetNotification(ctx context.Context, id int) (*models.NotificationEntity, error) {
+	var e models.NotificationEntity
+	err := scanNotification(r.pool.QueryRow(ctx, `SELECT `+notificationColumns+` FROM public.alert_notifications WHERE id = $1`, id), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// MarkRead implements alerts.Repo.
+func (r repo) MarkRead(ctx context.Context, id int) error {
+	_, err := r.pool.Exec(ctx, `UPDATE public.alert_notifications SET read_at = $2 WHERE id = $1 AND read_at IS NULL`, id, time.Now())
+	if err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("notification %d marked read", id))
+
+	return nil
+}
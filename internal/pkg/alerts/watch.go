@@ -0,0 +1,49 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/alerts"
+	"kbswitch/internal/core/alerts/models"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/common/logging"
+	"time"
+)
+
+// evaluationTimeout bounds evaluation of a switch including deliveries, which outlive the request changing its stock
+const evaluationTimeout = 2 * time.Minute
+
+// Watch evaluates alerts of a switch in background whenever its price and stock change
+func Watch(service alerts.Service, evaluator alerts.Evaluator, logger logging.Logger) alerts.Service {
+	return watched{
+		Service:   service,
+		evaluator: evaluator,
+		logger:    logger,
+	}
+}
+
+type watched struct {
+	alerts.Service
+	evaluator alerts.Evaluator
+	logger    logging.Logger
+}
+
+func (w watched) evaluate(ctx context.Context, brand, name string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), evaluationTimeout)
+
+	go func() {
+		defer cancel()
+		if err := w.evaluator.Evaluate(ctx, brand, name); err != nil {
+			w.logger.LogError(fmt.Sprintf("alerts of switch %s %s: %s", brand, name, err.Error()))
+		}
+	}()
+}
+
+func (w watched) SetStock(ctx context.Context, brand, name string, stock models.NewStock) (*models.Stock, *common.AppError) {
+	res, err := w.Service.SetStock(ctx, brand, name, stock)
+	if err == nil {
+		w.evaluate(ctx, res.Brand, res.Name)
+	}
+
+	return res, err
+}
@@ -0,0 +1,221 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"kbswitch/internal/core/apikeys"
+	"kbswitch/internal/core/apikeys/models"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/common/logging"
+	"strings"
+	"time"
+)
+
+const (
+	// secrets look like kbs_<prefix>_<random>, prefix is stored in plain to tell keys apart
+	secretPrefix = "kbs_"
+	prefixLength = 8
+	maxNameLen   = 100
+
+	bootstrapName = "bootstrap"
+)
+
+var (
+	ErrMissingKey   = common.NewError(common.ErrUnauthorized, "api key is missing")
+	ErrInvalidKey   = common.NewError(common.ErrUnauthorized, "api key is invalid or revoked")
+	ErrExpiredKey   = common.NewError(common.ErrUnauthorized, "api key has expired")
+	ErrNoKey        = common.NewError(common.ErrNotFound, "api key with given id not found")
+	ErrMissingName  = common.NewError(common.ErrBadRequest, "api key name is missing")
+	ErrNameTooLong  = common.NewError(common.ErrBadRequest, fmt.Sprintf("api key name can't be longer than %d characters", maxNameLen))
+	ErrNoScopes     = common.NewError(common.ErrBadRequest, "api key needs at least one scope")
+	ErrPastExpiry   = common.NewError(common.ErrBadRequest, "api key expiry must be in the future")
+	ErrHashConflict = common.NewError(common.ErrInternalServer, "generated api key collided with an existing one")
+)
+
+func New(logger logging.Logger, repo apikeys.Repo) apikeys.Service {
+	return service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+type service struct {
+	repo   apikeys.Repo
+	logger logging.Logger
+}
+
+// Hash is what is stored instead of a secret, secrets are random enough for plain sha256
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func generate() (prefix, secret string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	random := base64.RawURLEncoding.EncodeToString(buf)
+
+	prefix = random[:prefixLength]
+	return prefix, secretPrefix + prefix + "_" + random[prefixLength:], nil
+}
+
+func (s service) Create(ctx context.Context, req models.NewKey) (*models.CreatedKey, *common.AppError) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		s.logger.LogError("api key name is missing")
+		return nil, &ErrMissingName
+	}
+	if len(name) > maxNameLen {
+		s.logger.LogError(fmt.Sprintf("api key name %s is too long", name))
+		return nil, &ErrNameTooLong
+	}
+	if len(req.Scopes) == 0 {
+		s.logger.LogError("api key without scopes")
+		return nil, &ErrNoScopes
+	}
+	scopes := make([]string, len(req.Scopes))
+	for i, scope := range req.Scopes {
+		if !scope.Valid() {
+			s.logger.LogError(fmt.Sprintf("unknown api key scope %s", scope))
+			e := common.NewError(common.ErrBadRequest, fmt.Sprintf("unknown scope '%s', must be one of read, write or admin", scope))
+			return nil, &e
+		}
+		scopes[i] = string(scope)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		s.logger.LogError(fmt.Sprintf("api key expiry %v is in the past", req.ExpiresAt))
+		return nil, &ErrPastExpiry
+	}
+
+	prefix, secret, err := generate()
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	entity := models.KeyEntity{
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      Hash(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+		ExpiresAt: req.ExpiresAt,
+	}
+	id, err := s.repo.Insert(ctx, entity)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if id == nil {
+		s.logger.LogError("api key hash collision")
+		return nil, &ErrHashConflict
+	}
+	entity.ID = *id
+
+	res := models.CreatedKey{Key: toKey(entity), Secret: secret}
+
+	s.logger.LogTrace(fmt.Sprintf("result is key %d", res.Key.ID))
+	return &res, nil
+}
+
+func (s service) List(ctx context.Context) ([]models.Key, *common.AppError) {
+	entities, err := s.repo.List(ctx)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := make([]models.Key, len(entities))
+	for i, e := range entities {
+		res[i] = toKey(e)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("result is %v", res))
+	return res, nil
+}
+
+func (s service) Revoke(ctx context.Context, id int) *common.AppError {
+	revoked, err := s.repo.Revoke(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if !revoked {
+		s.logger.LogError(fmt.Sprintf("no active api key %d to revoke", id))
+		return &ErrNoKey
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("api key %d revoked", id))
+	return nil
+}
+
+func (s service) Authenticate(ctx context.Context, secret string) (*models.Key, *common.AppError) {
+	if secret == "" {
+		return nil, &ErrMissingKey
+	}
+
+	entity, err := s.repo.Use(ctx, Hash(secret))
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil {
+		s.logger.LogError("unknown or revoked api key used")
+		return nil, &ErrInvalidKey
+	}
+
+	key := toKey(*entity)
+	if key.Expired(time.Now()) {
+		s.logger.LogError(fmt.Sprintf("expired api key %d used", key.ID))
+		return nil, &ErrExpiredKey
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("authenticated with api key %d", key.ID))
+	return &key, nil
+}
+
+func (s service) Bootstrap(ctx context.Context, secret string) *common.AppError {
+	if secret == "" {
+		return &ErrMissingKey
+	}
+
+	entity := models.KeyEntity{
+		Name:      bootstrapName,
+		Prefix:    bootstrapName,
+		Hash:      Hash(secret),
+		Scopes:    []string{string(models.ScopeAdmin)},
+		CreatedAt: time.Now(),
+	}
+	// nil id only means it was registered by an earlier start
+	if _, err := s.repo.Insert(ctx, entity); err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+
+	s.logger.LogTrace("bootstrap api key registered")
+	return nil
+}
+
+func toKey(e models.KeyEntity) models.Key {
+	scopes := make([]models.Scope, len(e.Scopes))
+	for i, s := range e.Scopes {
+		scopes[i] = models.Scope(s)
+	}
+
+	return models.Key{
+		ID:         e.ID,
+		Name:       e.Name,
+		Prefix:     e.Prefix,
+		Scopes:     scopes,
+		CreatedAt:  e.CreatedAt,
+		ExpiresAt:  e.ExpiresAt,
+		RevokedAt:  e.RevokedAt,
+		LastUsedAt: e.LastUsedAt,
+	}
+}
@@ -0,0 +1,221 @@
+package apikeys_test
+
+import (
+	"context"
+	"errors"
+	"kbswitch/internal/core/apikeys/models"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/pkg/apikeys"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeLogger struct{}
+
+func (fakeLogger) LogError(msg string) {}
+func (fakeLogger) LogInfo(msg string)  {}
+func (fakeLogger) LogTrace(msg string) {}
+
+type fakeRepo struct {
+	insert func(models.KeyEntity) (*int, error)
+	list   func() ([]models.KeyEntity, error)
+	revoke func(int) (bool, error)
+	use    func(string) (*models.KeyEntity, error)
+}
+
+func (f fakeRepo) Insert(ctx context.Context, e models.KeyEntity) (*int, error) {
+	return f.insert(e)
+}
+
+func (f fakeRepo) List(ctx context.Context) ([]models.KeyEntity, error) {
+	return f.list()
+}
+
+func (f fakeRepo) Revoke(ctx context.Context, id int) (bool, error) {
+	return f.revoke(id)
+}
+
+func (f fakeRepo) Use(ctx context.Context, hash string) (*models.KeyEntity, error) {
+	return f.use(hash)
+}
+
+func timeptr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestCreate(t *testing.T) {
+	var stored models.KeyEntity
+	repo := fakeRepo{insert: func(e models.KeyEntity) (*int, error) {
+		stored = e
+		id := 7
+		return &id, nil
+	}}
+
+	tcases := []struct {
+		name     string
+		req      models.NewKey
+		expected *common.AppError
+	}{
+		{
+			name: "valid",
+			req:  models.NewKey{Name: "ci", Scopes: []models.Scope{models.ScopeWrite}, ExpiresAt: timeptr(time.Now().Add(time.Hour))},
+		},
+		{
+			name:     "missing name",
+			req:      models.NewKey{Name: " ", Scopes: []models.Scope{models.ScopeWrite}},
+			expected: &apikeys.ErrMissingName,
+		},
+		{
+			name:     "no scopes",
+			req:      models.NewKey{Name: "ci"},
+			expected: &apikeys.ErrNoScopes,
+		},
+		{
+			name: "unknown scope",
+			req:  models.NewKey{Name: "ci", Scopes: []models.Scope{"root"}},
+			expected: func() *common.AppError {
+				e := common.NewError(common.ErrBadRequest, "unknown scope 'root', must be one of read, write or admin")
+				return &e
+			}(),
+		},
+		{
+			name:     "expiry in the past",
+			req:      models.NewKey{Name: "ci", Scopes: []models.Scope{models.ScopeRead}, ExpiresAt: timeptr(time.Now().Add(-time.Hour))},
+			expected: &apikeys.ErrPastExpiry,
+		},
+	}
+
+	for _, tc := range tcases {
+		res, err := apikeys.New(fakeLogger{}, repo).Create(context.Background(), tc.req)
+
+		if !reflect.DeepEqual(err, tc.expected) {
+			t.Errorf("Create %s failed\nexpected error %v\ngot %v", tc.name, tc.expected, err)
+		}
+		if tc.expected != nil {
+			continue
+		}
+
+		if res.Key.ID != 7 || res.Key.Name != "ci" {
+			t.Errorf("Create %s failed\ngot key %+v", tc.name, res.Key)
+		}
+		if !strings.HasPrefix(res.Secret, "kbs_"+res.Key.Prefix+"_") {
+			t.Errorf("Create %s failed\nsecret %s does not carry prefix %s", tc.name, res.Secret, res.Key.Prefix)
+		}
+		if stored.Hash != apikeys.Hash(res.Secret) || strings.Contains(stored.Hash, res.Secret) {
+			t.Errorf("Create %s failed\nstored hash %s does not match the secret", tc.name, stored.Hash)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	secret := "kbs_abcdefgh_secret"
+	keys := map[string]models.KeyEntity{
+		"kbs_abcdefgh_secret": {ID: 1, Scopes: []string{"write"}},
+		"kbs_expired0_secret": {ID: 2, Scopes: []string{"admin"}, ExpiresAt: timeptr(time.Now().Add(-time.Minute))},
+	}
+	repo := fakeRepo{use: func(hash string) (*models.KeyEntity, error) {
+		for s, e := range keys {
+			if apikeys.Hash(s) == hash {
+				return &e, nil
+			}
+		}
+		return nil, nil
+	}}
+
+	tcases := []struct {
+		secret   string
+		expected struct {
+			id  int
+			err *common.AppError
+		}
+	}{
+		{
+			secret: secret,
+			expected: struct {
+				id  int
+				err *common.AppError
+			}{id: 1},
+		},
+		{
+			secret: "",
+			expected: struct {
+				id  int
+				err *common.AppError
+			}{err: &apikeys.ErrMissingKey},
+		},
+		{
+			secret: "kbs_unknown0_secret",
+			expected: struct {
+				id  int
+				err *common.AppError
+			}{err: &apikeys.ErrInvalidKey},
+		},
+		{
+			secret: "kbs_expired0_secret",
+			expected: struct {
+				id  int
+				err *common.AppError
+			}{err: &apikeys.ErrExpiredKey},
+		},
+	}
+
+	for _, tc := range tcases {
+		key, err := apikeys.New(fakeLogger{}, repo).Authenticate(context.Background(), tc.secret)
+
+		if !reflect.DeepEqual(err, tc.expected.err) {
+			t.Errorf("Authenticate %q failed\nexpected error %v\ngot %v", tc.secret, tc.expected.err, err)
+		}
+		if err == nil && key.ID != tc.expected.id {
+			t.Errorf("Authenticate %q failed\nexpected key %d\ngot %d", tc.secret, tc.expected.id, key.ID)
+		}
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	tcases := []struct {
+		repo     fakeRepo
+		expected *common.AppError
+	}{
+		{
+			repo: fakeRepo{revoke: func(int) (bool, error) { return true, nil }},
+		},
+		{
+			repo:     fakeRepo{revoke: func(int) (bool, error) { return false, nil }},
+			expected: &apikeys.ErrNoKey,
+		},
+		{
+			repo:     fakeRepo{revoke: func(int) (bool, error) { return false, errors.New("db is down") }},
+			expected: common.Wrap(errors.New("db is down")),
+		},
+	}
+
+	for i, tc := range tcases {
+		err := apikeys.New(fakeLogger{}, tc.repo).Revoke(context.Background(), 1)
+
+		if !reflect.DeepEqual(err, tc.expected) {
+			t.Errorf("Revoke case %d failed\nexpected %v\ngot %v", i, tc.expected, err)
+		}
+	}
+}
+
+func TestKeyAllows(t *testing.T) {
+	tcases := []struct {
+		scopes   []models.Scope
+		scope    models.Scope
+		expected bool
+	}{
+		{scopes: []models.Scope{models.ScopeRead}, scope: models.ScopeRead, expected: true},
+		{scopes: []models.Scope{models.ScopeRead}, scope: models.ScopeWrite, expected: false},
+		{scopes: []models.Scope{models.ScopeAdmin}, scope: models.ScopeWrite, expected: true},
+		{scopes: []models.Scope{"unknown"}, scope: models.ScopeRead, expected: false},
+		{scopes: nil, scope: models.ScopeRead, expected: false},
+	}
+
+	for _, tc := range tcases {
+		if got := (models.Key{Scopes: tc.scopes}).Allows(tc.scope); got != tc.expected {
+			t.Errorf("Key%v.Allows(%s) failed\nexpected %v\ngot %v", tc.scopes, tc.scope, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/apikeys"
+	"kbswitch/internal/core/apikeys/models"
+	"kbswitch/internal/core/common/database"
+	"kbswitch/internal/core/common/logging"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func New(logger logging.Logger, pool database.DBPool) apikeys.Repo {
+	return repo{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+type repo struct {
+	logger logging.Logger
+	pool   database.DBPool
+}
+
+// column order must match scanKey
+const keyColumns = `id, name, prefix, hash, scopes, created_at, expires_at, revoked_at, last_used_at`
+
+func scanKey(row pgx.Row, e *models.KeyEntity) error {
+	return row.Scan(&e.ID, &e.Name, &e.Prefix, &e.Hash, &e.Scopes, &e.CreatedAt, &e.ExpiresAt, &e.RevokedAt, &e.LastUsedAt)
+}
+
+// Insert implements apikeys.Repo.
+func (r repo) Insert(ctx context.Context, e models.KeyEntity) (*int, error) {
+	query := `INSERT INTO public.api_keys (name, prefix, hash, scopes, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (hash) DO NOTHING
+	RETURNING id`
+
+	var id int
+	err := r.pool.QueryRow(ctx, query, e.Name, e.Prefix, e.Hash, e.Scopes, e.CreatedAt, e.ExpiresAt).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		r.logger.LogTrace(fmt.Sprintf("api key %s already exists", e.Prefix))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("api key %d inserted", id))
+
+	return &id, nil
+}
+
+// List implements apikeys.Repo.
+func (r repo) List(ctx context.Context) ([]models.KeyEntity, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+keyColumns+` FROM public.api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.KeyEntity{}
+	for rows.Next() {
+		var e models.KeyEntity
+		if err := scanKey(rows, &e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d api keys listed", len(res)))
+
+	return res, nil
+}
+
+// Revoke implements apikeys.Repo.
+func (r repo) Revoke(ctx context.Context, id int) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `UPDATE public.api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("api key %d revoke affected %d rows", id, tag.RowsAffected()))
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// Use implements apikeys.Repo.
+func (r repo) Use(ctx context.Context, hash string) (*models.KeyEntity, error) {
+	query := `UPDATE public.api_keys SET last_used_at = now()
+	WHERE hash = $1 AND revoked_at IS NULL
+	RETURNING ` + keyColumns
+
+	var e models.KeyEntity
+	err := scanKey(r.pool.QueryRow(ctx, query, hash), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
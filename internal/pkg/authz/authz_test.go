@@ -0,0 +1,148 @@
+package authz_test
+
+import (
+	"context"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches"
+	"kbswitch/internal/core/switches/models"
+	authzpolicy "kbswitch/internal/pkg/authz"
+	"reflect"
+	"testing"
+)
+
+func TestRolePolicy(t *testing.T) {
+	policy := authzpolicy.NewRolePolicy()
+
+	tcases := []struct {
+		role    authz.Role
+		allowed []authz.Permission
+		refused []authz.Permission
+	}{
+		{
+			role:    authz.RoleViewer,
+			allowed: []authz.Permission{authz.PermSwitchesRead},
+			refused: []authz.Permission{authz.PermSwitchesPropose, authz.PermSwitchesEdit, authz.PermReviewsWrite},
+		},
+		{
+			role:    authz.RoleContributor,
+			allowed: []authz.Permission{authz.PermSwitchesRead, authz.PermSwitchesPropose, authz.PermReviewsWrite},
+			refused: []authz.Permission{authz.PermSwitchesApprove, authz.PermSwitchesCreate, authz.PermSwitchesDelete, authz.PermReviewsModerate, authz.PermListingsManage},
+		},
+		{
+			role:    authz.RoleModerator,
+			allowed: []authz.Permission{authz.PermSwitchesPropose, authz.PermSwitchesApprove, authz.PermSwitchesEdit, authz.PermSwitchesDelete, authz.PermReviewsModerate, authz.PermListingsManage},
+			refused: []authz.Permission{authz.PermUsersManage, authz.PermKeysManage},
+		},
+		{
+			role:    authz.RoleAdmin,
+			allowed: []authz.Permission{authz.PermSwitchesDelete, authz.PermUsersManage, authz.PermKeysManage},
+		},
+		{
+			role:    "root",
+			refused: []authz.Permission{authz.PermSwitchesRead},
+		},
+	}
+
+	for _, tc := range tcases {
+		p := authz.Principal{Subject: "user:1", Role: tc.role}
+		for _, perm := range tc.allowed {
+			if !policy.Allows(p, perm) {
+				t.Errorf("%s should be allowed %s", tc.role, perm)
+			}
+		}
+		for _, perm := range tc.refused {
+			if policy.Allows(p, perm) {
+				t.Errorf("%s should not be allowed %s", tc.role, perm)
+			}
+		}
+	}
+}
+
+// fakeService records whether a call got through the guard
+type fakeService struct {
+	switches.Service
+	called *bool
+}
+
+func (f fakeService) Remove(ctx context.Context, brand, name string) *common.AppError {
+	*f.called = true
+	return nil
+}
+
+func (f fakeService) Batch(ctx context.Context, ops []models.BatchOperation, opts models.BatchOptions) (*models.BatchReport, *common.AppError) {
+	*f.called = true
+	return &models.BatchReport{}, nil
+}
+
+func TestGuardSwitches(t *testing.T) {
+	tcases := []struct {
+		name     string
+		role     authz.Role
+		call     func(s switches.Service, ctx context.Context) *common.AppError
+		expected struct {
+			called bool
+			err    *common.AppError
+		}
+	}{
+		{
+			name: "moderator removes",
+			role: authz.RoleModerator,
+			call: func(s switches.Service, ctx context.Context) *common.AppError {
+				return s.Remove(ctx, "Gateron", "Yellow")
+			},
+			expected: struct {
+				called bool
+				err    *common.AppError
+			}{called: true},
+		},
+		{
+			name: "contributor removes",
+			role: authz.RoleContributor,
+			call: func(s switches.Service, ctx context.Context) *common.AppError {
+				return s.Remove(ctx, "Gateron", "Yellow")
+			},
+			expected: struct {
+				called bool
+				err    *common.AppError
+			}{err: authz.Forbidden(authz.PermSwitchesDelete)},
+		},
+		{
+			name: "contributor batch with a delete",
+			role: authz.RoleContributor,
+			call: func(s switches.Service, ctx context.Context) *common.AppError {
+				_, err := s.Batch(ctx, []models.BatchOperation{{Action: models.BatchDelete}}, models.BatchOptions{})
+				return err
+			},
+			expected: struct {
+				called bool
+				err    *common.AppError
+			}{err: authz.Forbidden(authz.PermSwitchesDelete)},
+		},
+		{
+			name: "anonymous through background context",
+			call: func(s switches.Service, ctx context.Context) *common.AppError {
+				return s.Remove(context.Background(), "Gateron", "Yellow")
+			},
+			expected: struct {
+				called bool
+				err    *common.AppError
+			}{err: authz.Forbidden(authz.PermSwitchesDelete)},
+		},
+	}
+
+	for _, tc := range tcases {
+		called := false
+		s := authzpolicy.GuardSwitches(fakeService{called: &called}, authzpolicy.NewRolePolicy())
+		ctx := authz.WithPrincipal(context.Background(), authz.Principal{Subject: "user:1", Role: tc.role})
+
+		err := tc.call(s, ctx)
+
+		if called != tc.expected.called {
+			t.Errorf("%s: expected call to go through %v, got %v", tc.name, tc.expected.called, called)
+		}
+		if !reflect.DeepEqual(err, tc.expected.err) {
+			t.Errorf("%s: error failed\nexpected %v\ngot %v", tc.name, tc.expected.err, err)
+		}
+	}
+}
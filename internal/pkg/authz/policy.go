@@ -0,0 +1,44 @@
+package authz
+
+import "kbswitch/internal/core/authz"
+
+// each role is granted its own permissions and those of every role before it
+var grants = []struct {
+	role  authz.Role
+	perms []authz.Permission
+}{
+	{authz.RoleViewer, []authz.Permission{authz.PermSwitchesRead}},
+	{authz.RoleContributor, []authz.Permission{authz.PermSwitchesPropose, authz.PermReviewsWrite}},
+	{authz.RoleModerator, []authz.Permission{
+		authz.PermSwitchesApprove, authz.PermSwitchesCreate, authz.PermSwitchesEdit, authz.PermSwitchesDelete,
+		authz.PermReviewsModerate, authz.PermListingsManage,
+	}},
+	{authz.RoleAdmin, []authz.Permission{authz.PermUsersManage, authz.PermKeysManage}},
+}
+
+// NewRolePolicy gives the policy of built in roles
+func NewRolePolicy() authz.Policy {
+	p := rolePolicy{}
+
+	granted := map[authz.Permission]bool{}
+	for _, g := range grants {
+		for _, perm := range g.perms {
+			granted[perm] = true
+		}
+
+		perms := make(map[authz.Permission]bool, len(granted))
+		for perm := range granted {
+			perms[perm] = true
+		}
+		p[g.role] = perms
+	}
+
+	return p
+}
+
+type rolePolicy map[authz.Role]map[authz.Permission]bool
+
+// Allows implements authz.Policy.
+func (p rolePolicy) Allows(principal authz.Principal, perm authz.Permission) bool {
+	return p[principal.Role][perm]
+}
@@ -0,0 +1,92 @@
+package authz
+
+import (
+	"context"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches"
+	"kbswitch/internal/core/switches/models"
+)
+
+// GuardSwitches checks principal of the request context against policy before every
+// catalog change, so the rules hold whichever way the service is reached
+func GuardSwitches(service switches.Service, policy authz.Policy) switches.Service {
+	return guarded{
+		Service: service,
+		policy:  policy,
+	}
+}
+
+type guarded struct {
+	switches.Service
+	policy authz.Policy
+}
+
+func (g guarded) AddNew(ctx context.Context, reqbody models.SwitchRequestBody) (*int, *common.AppError) {
+	if err := authz.Require(ctx, g.policy, authz.PermSwitchesCreate); err != nil {
+		return nil, err
+	}
+	return g.Service.AddNew(ctx, reqbody)
+}
+
+func (g guarded) Update(ctx context.Context, brand, name string, body models.SwitchRequestBody) (*models.Switch, *common.AppError) {
+	if err := authz.Require(ctx, g.policy, authz.PermSwitchesEdit); err != nil {
+		return nil, err
+	}
+	return g.Service.Update(ctx, brand, name, body)
+}
+
+func (g guarded) Remove(ctx context.Context, brand, name string) *common.AppError {
+	if err := authz.Require(ctx, g.policy, authz.PermSwitchesDelete); err != nil {
+		return err
+	}
+	return g.Service.Remove(ctx, brand, name)
+}
+
+func (g guarded) AddAlias(ctx context.Context, brand, name string, alias models.AliasRequestBody) *common.AppError {
+	if err := authz.Require(ctx, g.policy, authz.PermSwitchesEdit); err != nil {
+		return err
+	}
+	return g.Service.AddAlias(ctx, brand, name, alias)
+}
+
+func (g guarded) RemoveAlias(ctx context.Context, brand, name string, alias models.Alias) *common.AppError {
+	if err := authz.Require(ctx, g.policy, authz.PermSwitchesEdit); err != nil {
+		return err
+	}
+	return g.Service.RemoveAlias(ctx, brand, name, alias)
+}
+
+func (g guarded) Import(ctx context.Context, rows []models.ImportRow, opts models.ImportOptions) (*models.ImportReport, *common.AppError) {
+	if err := authz.Require(ctx, g.policy, authz.PermSwitchesCreate); err != nil {
+		return nil, err
+	}
+	// overwriting existing switches is an edit of them
+	if opts.UpdateExisting {
+		if err := authz.Require(ctx, g.policy, authz.PermSwitchesEdit); err != nil {
+			return nil, err
+		}
+	}
+	return g.Service.Import(ctx, rows, opts)
+}
+
+// Batch is refused as a whole when any of its operations is not permitted
+func (g guarded) Batch(ctx context.Context, ops []models.BatchOperation, opts models.BatchOptions) (*models.BatchReport, *common.AppError) {
+	for _, op := range ops {
+		perm, ok := batchPermissions[op.Action]
+		if !ok {
+			// unknown actions are reported by the service itself
+			continue
+		}
+		if err := authz.Require(ctx, g.policy, perm); err != nil {
+			return nil, err
+		}
+	}
+	return g.Service.Batch(ctx, ops, opts)
+}
+
+var batchPermissions = map[models.BatchAction]authz.Permission{
+	models.BatchCreate: authz.PermSwitchesCreate,
+	models.BatchUpdate: authz.PermSwitchesEdit,
+	models.BatchDelete: authz.PermSwitchesDelete,
+}
@@ -0,0 +1,318 @@
+package autocomplete
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/autocomplete/models"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type entry struct {
+	brand  string
+	name   string
+	tokens []string
+}
+
+// Index keeps brand/name of every switch in memory and answers typo tolerant prefix queries.
+// it is safe for concurrent use
+type Index struct {
+	mu      sync.RWMutex
+	entries map[string]*entry
+	// sorted vocabulary of all tokens, used for prefix lookup by binary search
+	vocab []string
+	// token -> keys of entries containing it
+	postings map[string]map[string]struct{}
+}
+
+func New() *Index {
+	return &Index{
+		entries:  map[string]*entry{},
+		postings: map[string]map[string]struct{}{},
+	}
+}
+
+// Load replaces index content with the whole catalog served by service
+func (idx *Index) Load(ctx context.Context, service switches.Service) *common.AppError {
+	all, err := service.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.entries = map[string]*entry{}
+	idx.postings = map[string]map[string]struct{}{}
+	for _, item := range all {
+		idx.add(item.Brand, item.Name)
+	}
+	idx.rebuildVocab()
+
+	return nil
+}
+
+func (idx *Index) Add(brand, name string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.add(brand, name)
+	idx.rebuildVocab()
+}
+
+func (idx *Index) Remove(brand, name string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	key := entryKey(brand, name)
+	e, ok := idx.entries[key]
+	if !ok {
+		return
+	}
+
+	delete(idx.entries, key)
+	for _, t := range e.tokens {
+		delete(idx.postings[t], key)
+		if len(idx.postings[t]) == 0 {
+			delete(idx.postings, t)
+		}
+	}
+	idx.rebuildVocab()
+}
+
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.entries)
+}
+
+func (idx *Index) add(brand, name string) {
+	key := entryKey(brand, name)
+	if _, ok := idx.entries[key]; ok {
+		return
+	}
+
+	e := &entry{
+		brand:  brand,
+		name:   name,
+		tokens: tokenize(brand + " " + name),
+	}
+	idx.entries[key] = e
+
+	for _, t := range e.tokens {
+		if idx.postings[t] == nil {
+			idx.postings[t] = map[string]struct{}{}
+		}
+		idx.postings[t][key] = struct{}{}
+	}
+}
+
+func (idx *Index) rebuildVocab() {
+	vocab := make([]string, 0, len(idx.postings))
+	for t := range idx.postings {
+		vocab = append(vocab, t)
+	}
+	sort.Strings(vocab)
+
+	idx.vocab = vocab
+}
+
+// Suggest returns at most limit entries matching every token of query.
+// the last token is treated as a prefix since user is most likely still typing it,
+// each token tolerates a few typos depending on its length
+func (idx *Index) Suggest(query string, limit int) []models.Suggestion {
+	qtokens := tokenize(query)
+	if len(qtokens) == 0 || limit <= 0 {
+		return []models.Suggestion{}
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// entry key -> accumulated score, only entries matched by every query token survive
+	var scores map[string]float64
+	for i, qt := range qtokens {
+		matched := idx.matchToken(qt, i == len(qtokens)-1)
+
+		next := map[string]float64{}
+		for token, tokenScore := range matched {
+			for key := range idx.postings[token] {
+				if scores != nil {
+					if _, ok := scores[key]; !ok {
+						continue
+					}
+				}
+				if tokenScore > next[key] {
+					next[key] = tokenScore
+				}
+			}
+		}
+		for key, s := range next {
+			next[key] = s + scores[key]
+		}
+
+		scores = next
+		if len(scores) == 0 {
+			return []models.Suggestion{}
+		}
+	}
+
+	res := make([]models.Suggestion, 0, len(scores))
+	for key, score := range scores {
+		e := idx.entries[key]
+		res = append(res, models.Suggestion{
+			Brand: e.brand,
+			Name:  e.name,
+			Score: score / float64(len(qtokens)),
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		li, lj := len(res[i].Brand)+len(res[i].Name), len(res[j].Brand)+len(res[j].Name)
+		if li != lj {
+			return li < lj
+		}
+		return entryKey(res[i].Brand, res[i].Name) < entryKey(res[j].Brand, res[j].Name)
+	})
+
+	if len(res) > limit {
+		res = res[:limit]
+	}
+
+	return res
+}
+
+// matchToken finds vocabulary tokens close to qt and scores them from 0 to 1
+func (idx *Index) matchToken(qt string, prefix bool) map[string]float64 {
+	res := map[string]float64{}
+
+	if prefix {
+		start := sort.SearchStrings(idx.vocab, qt)
+		for i := start; i < len(idx.vocab) && strings.HasPrefix(idx.vocab[i], qt); i++ {
+			if idx.vocab[i] == qt {
+				res[idx.vocab[i]] = 1
+			} else {
+				res[idx.vocab[i]] = 0.9
+			}
+		}
+	} else if _, ok := idx.postings[qt]; ok {
+		res[qt] = 1
+	}
+
+	allowed := maxTypos(qt)
+	if allowed == 0 {
+		return res
+	}
+
+	qr := []rune(qt)
+	for _, token := range idx.vocab {
+		if _, ok := res[token]; ok {
+			continue
+		}
+
+		tr := []rune(token)
+		d := allowed + 1
+		if prefix {
+			// compare against token prefixes around query length, "yelow" should still reach "yellow"
+			for l := len(qr) - allowed; l <= len(qr)+allowed; l++ {
+				if l <= 0 || l > len(tr) {
+					continue
+				}
+				d = min(d, distance(qr, tr[:l], allowed))
+			}
+		} else {
+			d = distance(qr, tr, allowed)
+		}
+
+		if d <= allowed {
+			res[token] = 0.8 - 0.2*float64(d-1)
+		}
+	}
+
+	return res
+}
+
+func maxTypos(token string) int {
+	switch l := len([]rune(token)); {
+	case l <= 3:
+		return 0
+	case l <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// distance is a Damerau-Levenshtein (optimal string alignment) distance,
+// it gives up early and returns max+1 once distance is known to exceed max
+func distance(a, b []rune, max int) int {
+	if abs(len(a)-len(b)) > max {
+		return max + 1
+	}
+
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	return prev[len(b)]
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := map[string]struct{}{}
+	res := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if _, ok := seen[f]; ok {
+			continue
+		}
+		seen[f] = struct{}{}
+		res = append(res, f)
+	}
+
+	return res
+}
+
+func entryKey(brand, name string) string {
+	return fmt.Sprintf("%s/%s", strings.ToLower(brand), strings.ToLower(name))
+}
@@ -0,0 +1,128 @@
+package autocomplete_test
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches"
+	"kbswitch/internal/core/switches/models"
+	"kbswitch/internal/pkg/autocomplete"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeService struct {
+	switches.Service
+	all []models.Switch
+}
+
+func (f fakeService) GetAll(ctx context.Context) ([]models.Switch, *common.AppError) {
+	return f.all, nil
+}
+
+func (f fakeService) AddNew(ctx context.Context, body models.SwitchRequestBody) (*int, *common.AppError) {
+	id := 1
+	return &id, nil
+}
+
+func (f fakeService) Update(ctx context.Context, brand, name string, body models.SwitchRequestBody) (*models.Switch, *common.AppError) {
+	return &models.Switch{Brand: body.Brand, Name: body.Name}, nil
+}
+
+func (f fakeService) GetSingle(ctx context.Context, brand, name string) (*models.Switch, *common.AppError) {
+	return &models.Switch{Brand: brand, Name: name}, nil
+}
+
+func (f fakeService) Remove(ctx context.Context, brand, name string) *common.AppError {
+	return nil
+}
+
+func names(index *autocomplete.Index, q string, limit int) []string {
+	res := []string{}
+	for _, s := range index.Suggest(q, limit) {
+		res = append(res, s.Brand+" "+s.Name)
+	}
+	return res
+}
+
+func loaded(t *testing.T) (*autocomplete.Index, fakeService) {
+	service := fakeService{
+		all: []models.Switch{
+			{Brand: "Gateron", Name: "Yellow"},
+			{Brand: "Gateron", Name: "Yellow Pro"},
+			{Brand: "Gateron", Name: "Red"},
+			{Brand: "Cherry", Name: "MX Red"},
+			{Brand: "Cherry", Name: "MX Black"},
+			{Brand: "Kailh", Name: "Box Jade"},
+		},
+	}
+
+	index := autocomplete.New()
+	if err := index.Load(context.Background(), service); err != nil {
+		t.Fatalf("could not load index: %v", err)
+	}
+
+	return index, service
+}
+
+func TestSuggest(t *testing.T) {
+	index, _ := loaded(t)
+
+	tcases := []struct {
+		query    string
+		limit    int
+		expected []string
+	}{
+		{query: "gatron yelow", limit: 10, expected: []string{"Gateron Yellow", "Gateron Yellow Pro"}},
+		{query: "gate", limit: 10, expected: []string{"Gateron Red", "Gateron Yellow", "Gateron Yellow Pro"}},
+		{query: "mx r", limit: 10, expected: []string{"Cherry MX Red"}},
+		{query: "red", limit: 1, expected: []string{"Gateron Red"}},
+		{query: "jaed", limit: 10, expected: []string{"Kailh Box Jade"}},
+		{query: "holy panda", limit: 10, expected: []string{}},
+		{query: "  ", limit: 10, expected: []string{}},
+	}
+
+	for _, tc := range tcases {
+		got := names(index, tc.query, tc.limit)
+		if !reflect.DeepEqual(tc.expected, got) {
+			t.Errorf("Suggest(%q) failed\nexpected %v\ngot %v", tc.query, tc.expected, got)
+		}
+	}
+}
+
+func TestObserve(t *testing.T) {
+	index, service := loaded(t)
+	observed := autocomplete.Observe(service, index)
+	ctx := context.Background()
+
+	observed.AddNew(ctx, models.SwitchRequestBody{Brand: "Akko", Name: "Lavender"})
+	if got := names(index, "lavender", 10); !reflect.DeepEqual([]string{"Akko Lavender"}, got) {
+		t.Errorf("index was not refreshed after AddNew, got %v", got)
+	}
+
+	observed.Update(ctx, "Akko", "Lavender", models.SwitchRequestBody{Brand: "Akko", Name: "Lavender Purple"})
+	if got := names(index, "akko", 10); !reflect.DeepEqual([]string{"Akko Lavender Purple"}, got) {
+		t.Errorf("index was not refreshed after Update, got %v", got)
+	}
+
+	observed.Remove(ctx, "Akko", "Lavender Purple")
+	if got := names(index, "akko", 10); !reflect.DeepEqual([]string{}, got) {
+		t.Errorf("index was not refreshed after Remove, got %v", got)
+	}
+}
+
+func TestSuggestLatency(t *testing.T) {
+	all := make([]models.Switch, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		all = append(all, models.Switch{Brand: "Brand" + string(rune('a'+i%26)), Name: "Switch" + string(rune('a'+i/26%26)) + string(rune('a'+i%7))})
+	}
+
+	index := autocomplete.New()
+	index.Load(context.Background(), fakeService{all: all})
+
+	start := time.Now()
+	index.Suggest("swich brandk", 10)
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("Suggest took %v, expected under 20ms", elapsed)
+	}
+}
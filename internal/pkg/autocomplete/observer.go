@@ -0,0 +1,117 @@
+package autocomplete
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches"
+	"kbswitch/internal/core/switches/models"
+)
+
+// Observe wraps service so that every successful create, update or delete is reflected in idx,
+// this way index stays fresh without querying the database per keystroke
+func Observe(service switches.Service, idx *Index) switches.Service {
+	return observed{
+		Service: service,
+		idx:     idx,
+	}
+}
+
+type observed struct {
+	switches.Service
+	idx *Index
+}
+
+func (o observed) AddNew(ctx context.Context, reqbody models.SwitchRequestBody) (*int, *common.AppError) {
+	id, err := o.Service.AddNew(ctx, reqbody)
+	if err == nil {
+		o.idx.Add(reqbody.Brand, reqbody.Name)
+	}
+
+	return id, err
+}
+
+// canonical resolves brand and name, which might be an alias, to the ones index knows the switch by
+func (o observed) canonical(ctx context.Context, brand, name string) (string, string) {
+	sw, err := o.Service.GetSingle(ctx, brand, name)
+	if err != nil || sw == nil {
+		return brand, name
+	}
+
+	return sw.Brand, sw.Name
+}
+
+func (o observed) Update(ctx context.Context, brand, name string, body models.SwitchRequestBody) (*models.Switch, *common.AppError) {
+	brand, name = o.canonical(ctx, brand, name)
+
+	res, err := o.Service.Update(ctx, brand, name, body)
+	if err == nil {
+		o.idx.Remove(brand, name)
+		if res != nil {
+			o.idx.Add(res.Brand, res.Name)
+		} else {
+			o.idx.Add(body.Brand, body.Name)
+		}
+	}
+
+	return res, err
+}
+
+func (o observed) Remove(ctx context.Context, brand, name string) *common.AppError {
+	brand, name = o.canonical(ctx, brand, name)
+
+	err := o.Service.Remove(ctx, brand, name)
+	if err == nil {
+		o.idx.Remove(brand, name)
+	}
+
+	return err
+}
+
+func (o observed) Import(ctx context.Context, rows []models.ImportRow, opts models.ImportOptions) (*models.ImportReport, *common.AppError) {
+	report, err := o.Service.Import(ctx, rows, opts)
+	if err != nil || report.DryRun || report.RolledBack {
+		return report, err
+	}
+
+	for _, res := range report.Results {
+		if res.Status == models.ImportCreated {
+			o.idx.Add(res.Brand, res.Name)
+		}
+	}
+
+	return report, nil
+}
+
+func (o observed) Batch(ctx context.Context, ops []models.BatchOperation, opts models.BatchOptions) (*models.BatchReport, *common.AppError) {
+	// targets are resolved upfront, switch might be renamed or gone once batch has run
+	targets := make([][2]string, len(ops))
+	for i, op := range ops {
+		if op.Action == models.BatchUpdate || op.Action == models.BatchDelete {
+			targets[i][0], targets[i][1] = o.canonical(ctx, op.Brand, op.Name)
+		}
+	}
+
+	report, err := o.Service.Batch(ctx, ops, opts)
+	if err != nil || report.RolledBack {
+		return report, err
+	}
+
+	for _, res := range report.Results {
+		target := targets[res.Index]
+		switch res.Status {
+		case models.BatchCreated:
+			o.idx.Add(res.Brand, res.Name)
+		case models.BatchUpdated:
+			o.idx.Remove(target[0], target[1])
+			if res.Switch != nil {
+				o.idx.Add(res.Switch.Brand, res.Switch.Name)
+			} else if body := ops[res.Index].Body; body != nil {
+				o.idx.Add(body.Brand, body.Name)
+			}
+		case models.BatchDeleted:
+			o.idx.Remove(target[0], target[1])
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,578 @@
+package collections
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/collections"
+	"kbswitch/internal/core/collections/models"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/common/logging"
+	switchservice "kbswitch/internal/pkg/switches"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxQuantity = 100000
+	maxNoteLen  = 1000
+)
+
+var currency = regexp.MustCompile(`^[A-Z]{3}$`)
+
+var (
+	ErrAnonymous        = common.NewError(common.ErrUnauthorized, "keeping lists requires being logged in")
+	ErrNoItem           = common.NewError(common.ErrNotFound, "collection item with given id not found")
+	ErrNoWish           = common.NewError(common.ErrNotFound, "wishlist entry with given id not found")
+	ErrNoShare          = common.NewError(common.ErrNotFound, "shared list not found")
+	ErrAlreadyWished    = common.NewError(common.ErrConflict, "switch is on the wishlist already, update that entry instead")
+	ErrUnknownList      = common.NewError(common.ErrBadRequest, "list must be one of collection or wishlist")
+	ErrMissingSwitch    = common.NewError(common.ErrBadRequest, "switch must be given by its id or by brand and name")
+	ErrInvalidQuantity  = common.NewError(common.ErrBadRequest, fmt.Sprintf("quantity must be from 1 to %d", MaxQuantity))
+	ErrUnknownCondition = common.NewError(common.ErrBadRequest, "condition must be one of new, like_new, used or worn")
+	ErrInvalidPrice     = common.NewError(common.ErrBadRequest, "price can't be negative")
+	ErrInvalidCurrency  = common.NewError(common.ErrBadRequest, "currency must be a three letter ISO 4217 code")
+	ErrNoteTooLong      = common.NewError(common.ErrBadRequest, fmt.Sprintf("note can't be longer than %d characters", maxNoteLen))
+)
+
+func New(logger logging.Logger, repo collections.Repo, catalog collections.Catalog) collections.Service {
+	return service{
+		repo:    repo,
+		catalog: catalog,
+		logger:  logger,
+	}
+}
+
+type service struct {
+	repo    collections.Repo
+	catalog collections.Catalog
+	logger  logging.Logger
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// owner gives subject of the principal, lists belong to it
+func (s service) owner(ctx context.Context) (string, *common.AppError) {
+	principal := authz.FromContext(ctx)
+	if principal.Anonymous() {
+		s.logger.LogError("anonymous principal has no lists")
+		return "", &ErrAnonymous
+	}
+	return principal.Subject, nil
+}
+
+func validateQuantity(quantity *int) *common.AppError {
+	if *quantity == 0 {
+		*quantity = 1
+	}
+	if *quantity < 1 || *quantity > MaxQuantity {
+		return &ErrInvalidQuantity
+	}
+	return nil
+}
+
+func validateNote(note string) *common.AppError {
+	if utf8.RuneCountInString(note) > maxNoteLen {
+		return &ErrNoteTooLong
+	}
+	return nil
+}
+
+func validateItem(item *models.NewItem) *common.AppError {
+	if e := validateQuantity(&item.Quantity); e != nil {
+		return e
+	}
+	if item.Condition == "" {
+		item.Condition = models.ConditionNew
+	}
+	if !slices.Contains(models.Conditions, item.Condition) {
+		return &ErrUnknownCondition
+	}
+	if item.Price != nil {
+		if item.Price.Amount < 0 {
+			return &ErrInvalidPrice
+		}
+		if !currency.MatchString(item.Price.Currency) {
+			return &ErrInvalidCurrency
+		}
+	}
+	return validateNote(item.Note)
+}
+
+// resolve gives ID and the name the catalog knows the referenced switch by
+func (s service) resolve(ctx context.Context, ref models.SwitchRef) (int, string, string, *common.AppError) {
+	id := ref.ID
+	if id == nil {
+		if ref.Brand == "" || ref.Name == "" {
+			return 0, "", "", &ErrMissingSwitch
+		}
+
+		var err error
+		id, err = s.catalog.GetID(ctx, ref.Brand, ref.Name)
+		if err != nil {
+			s.logger.LogError(err.Error())
+			return 0, "", "", common.Wrap(err)
+		}
+		if id == nil {
+			s.logger.LogError(fmt.Sprintf("switch %s %s not found", ref.Brand, ref.Name))
+			return 0, "", "", &switchservice.ErrNoSwitch
+		}
+	}
+
+	sw, err := s.catalog.GetSingle(ctx, *id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return 0, "", "", common.Wrap(err)
+	}
+	if sw == nil {
+		s.logger.LogError(fmt.Sprintf("switch %d not found", *id))
+		return 0, "", "", &switchservice.ErrNoSwitch
+	}
+	return sw.ID, sw.Manufacturer, sw.Model, nil
+}
+
+func (s service) Collection(ctx context.Context) (*collections.Collection, *common.AppError) {
+	owner, e := s.owner(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	entities, err := s.repo.Items(ctx, owner)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	value, err := s.repo.Value(ctx, owner)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := collections.Collection{
+		Items: make([]models.Item, len(entities)),
+		Value: *value,
+	}
+	for i, e := range entities {
+		res.Items[i] = toItem(e)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("%d collection items of %s listed", len(res.Items), owner))
+	return &res, nil
+}
+
+func (s service) AddItem(ctx context.Context, item models.NewItem) (*models.Item, *common.AppError) {
+	owner, e := s.owner(ctx)
+	if e != nil {
+		return nil, e
+	}
+	if e := validateItem(&item); e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+
+	switchID, brand, name, e := s.resolve(ctx, item.Switch)
+	if e != nil {
+		return nil, e
+	}
+
+	now := time.Now()
+	entity := models.ItemEntity{
+		Owner:     owner,
+		SwitchID:  switchID,
+		Quantity:  item.Quantity,
+		Condition: item.Condition,
+		Lubed:     item.Lubed,
+		Price:     item.Price,
+		Note:      strings.TrimSpace(item.Note),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Brand:     brand,
+		Name:      name,
+	}
+
+	id, err := s.repo.InsertItem(ctx, entity)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	entity.ID = *id
+
+	s.logger.LogTrace(fmt.Sprintf("collection item %d of %s added", entity.ID, owner))
+	res := toItem(entity)
+	return &res, nil
+}
+
+func (s service) item(ctx context.Context, owner string, id int) (*models.ItemEntity, *common.AppError) {
+	entity, err := s.repo.GetItem(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	// items of others are not found, rather than forbidden, so their ids tell nothing
+	if entity == nil || entity.Owner != owner {
+		s.logger.LogError(fmt.Sprintf("collection item %d of %s not found", id, owner))
+		return nil, &ErrNoItem
+	}
+	return entity, nil
+}
+
+func (s service) UpdateItem(ctx context.Context, id int, item models.NewItem) (*models.Item, *common.AppError) {
+	owner, e := s.owner(ctx)
+	if e != nil {
+		return nil, e
+	}
+	if e := validateItem(&item); e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+
+	entity, e := s.item(ctx, owner, id)
+	if e != nil {
+		return nil, e
+	}
+	entity.Quantity = item.Quantity
+	entity.Condition = item.Condition
+	entity.Lubed = item.Lubed
+	entity.Price = item.Price
+	entity.Note = strings.TrimSpace(item.Note)
+	entity.UpdatedAt = time.Now()
+
+	if err := s.repo.UpdateItem(ctx, *entity); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("collection item %d updated", id))
+	res := toItem(*entity)
+	return &res, nil
+}
+
+func (s service) RemoveItem(ctx context.Context, id int) *common.AppError {
+	owner, e := s.owner(ctx)
+	if e != nil {
+		return e
+	}
+	if _, e := s.item(ctx, owner, id); e != nil {
+		return e
+	}
+
+	ok, err := s.repo.DeleteItem(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if !ok {
+		s.logger.LogError(fmt.Sprintf("collection item %d deleted meanwhile", id))
+		return &ErrNoItem
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("collection item %d removed", id))
+	return nil
+}
+
+func (s service) Wishlist(ctx context.Context) ([]models.Wish, *common.AppError) {
+	owner, e := s.owner(ctx)
+	if e != nil {
+		return nil, e
+	}
+	return s.wishes(ctx, owner)
+}
+
+func (s service) wishes(ctx context.Context, owner string) ([]models.Wish, *common.AppError) {
+	entities, err := s.repo.Wishes(ctx, owner)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := make([]models.Wish, len(entities))
+	for i, e := range entities {
+		res[i] = toWish(e)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("%d wishes of %s listed", len(res), owner))
+	return res, nil
+}
+
+func (s service) AddWish(ctx context.Context, wish models.NewWish) (*models.Wish, *common.AppError) {
+	owner, e := s.owner(ctx)
+	if e != nil {
+		return nil, e
+	}
+	if e := validateQuantity(&wish.Quantity); e != nil {
+		return nil, e
+	}
+	if e := validateNote(wish.Note); e != nil {
+		return nil, e
+	}
+
+	switchID, brand, name, e := s.resolve(ctx, wish.Switch)
+	if e != nil {
+		return nil, e
+	}
+
+	now := time.Now()
+	entity := models.WishEntity{
+		Owner:     owner,
+		SwitchID:  switchID,
+		Quantity:  wish.Quantity,
+		Note:      strings.TrimSpace(wish.Note),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Brand:     brand,
+		Name:      name,
+	}
+
+	id, err := s.repo.InsertWish(ctx, entity)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if id == nil {
+		s.logger.LogError(fmt.Sprintf("%s wishes for switch %d already", owner, switchID))
+		return nil, &ErrAlreadyWished
+	}
+	entity.ID = *id
+
+	s.logger.LogTrace(fmt.Sprintf("wish %d of %s added", entity.ID, owner))
+	res := toWish(entity)
+	return &res, nil
+}
+
+func (s service) wish(ctx context.Context, owner string, id int) (*models.WishEntity, *common.AppError) {
+	entity, err := s.repo.GetWish(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil || entity.Owner != owner {
+		s.logger.LogError(fmt.Sprintf("wish %d of %s not found", id, owner))
+		return nil, &ErrNoWish
+	}
+	return entity, nil
+}
+
+func (s service) UpdateWish(ctx context.Context, id int, wish models.NewWish) (*models.Wish, *common.AppError) {
+	owner, e := s.owner(ctx)
+	if e != nil {
+		return nil, e
+	}
+	if e := validateQuantity(&wish.Quantity); e != nil {
+		return nil, e
+	}
+	if e := validateNote(wish.Note); e != nil {
+		return nil, e
+	}
+
+	entity, e := s.wish(ctx, owner, id)
+	if e != nil {
+		return nil, e
+	}
+	entity.Quantity = wish.Quantity
+	entity.Note = strings.TrimSpace(wish.Note)
+	entity.UpdatedAt = time.Now()
+
+	if err := s.repo.UpdateWish(ctx, *entity); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("wish %d updated", id))
+	res := toWish(*entity)
+	return &res, nil
+}
+
+func (s service) RemoveWish(ctx context.Context, id int) *common.AppError {
+	owner, e := s.owner(ctx)
+	if e != nil {
+		return e
+	}
+	if _, e := s.wish(ctx, owner, id); e != nil {
+		return e
+	}
+
+	ok, err := s.repo.DeleteWish(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if !ok {
+		s.logger.LogError(fmt.Sprintf("wish %d deleted meanwhile", id))
+		return &ErrNoWish
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("wish %d removed", id))
+	return nil
+}
+
+func validateList(list models.List) *common.AppError {
+	if list != models.ListCollection && list != models.ListWishlist {
+		return &ErrUnknownList
+	}
+	return nil
+}
+
+func (s service) Share(ctx context.Context, list models.List) (*models.Share, *common.AppError) {
+	owner, e := s.owner(ctx)
+	if e != nil {
+		return nil, e
+	}
+	if e := validateList(list); e != nil {
+		return nil, e
+	}
+
+	entity, err := s.repo.GetShare(ctx, owner, list)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil {
+		s.logger.LogError(fmt.Sprintf("%s of %s has no link", list, owner))
+		return nil, &ErrNoShare
+	}
+
+	res := toShare(*entity)
+	return &res, nil
+}
+
+func (s service) SetShare(ctx context.Context, list models.List, public bool) (*models.Share, *common.AppError) {
+	owner, e := s.owner(ctx)
+	if e != nil {
+		return nil, e
+	}
+	if e := validateList(list); e != nil {
+		return nil, e
+	}
+
+	token, err := newToken()
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	entity, err := s.repo.SaveShare(ctx, models.ShareEntity{
+		Owner:     owner,
+		List:      list,
+		Token:     token,
+		Public:    public,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("%s of %s shared, public: %t", list, owner, public))
+	res := toShare(*entity)
+	return &res, nil
+}
+
+func (s service) Unshare(ctx context.Context, list models.List) *common.AppError {
+	owner, e := s.owner(ctx)
+	if e != nil {
+		return e
+	}
+	if e := validateList(list); e != nil {
+		return e
+	}
+
+	ok, err := s.repo.DeleteShare(ctx, owner, list)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if !ok {
+		s.logger.LogError(fmt.Sprintf("%s of %s has no link", list, owner))
+		return &ErrNoShare
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("link of %s of %s dropped", list, owner))
+	return nil
+}
+
+func (s service) Shared(ctx context.Context, token string) (*models.Shared, *common.AppError) {
+	entity, err := s.repo.ShareByToken(ctx, token)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil {
+		s.logger.LogError("shared list not found")
+		return nil, &ErrNoShare
+	}
+
+	res := models.Shared{List: entity.List, Public: entity.Public}
+	switch entity.List {
+	case models.ListCollection:
+		items, err := s.repo.Items(ctx, entity.Owner)
+		if err != nil {
+			s.logger.LogError(err.Error())
+			return nil, common.Wrap(err)
+		}
+		res.Items = make([]models.Item, len(items))
+		for i, e := range items {
+			res.Items[i] = toItem(e)
+			res.Items[i].Price = nil
+			res.Items[i].Note = ""
+		}
+	case models.ListWishlist:
+		wishes, e := s.wishes(ctx, entity.Owner)
+		if e != nil {
+			return nil, e
+		}
+		res.Wishes = wishes
+		for i := range res.Wishes {
+			res.Wishes[i].Note = ""
+		}
+	}
+
+	return &res, nil
+}
+
+func toItem(e models.ItemEntity) models.Item {
+	return models.Item{
+		ID:        e.ID,
+		SwitchID:  e.SwitchID,
+		Brand:     e.Brand,
+		Name:      e.Name,
+		Quantity:  e.Quantity,
+		Condition: e.Condition,
+		Lubed:     e.Lubed,
+		Price:     e.Price,
+		Note:      e.Note,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+	}
+}
+
+func toWish(e models.WishEntity) models.Wish {
+	return models.Wish{
+		ID:        e.ID,
+		SwitchID:  e.SwitchID,
+		Brand:     e.Brand,
+		Name:      e.Name,
+		Quantity:  e.Quantity,
+		Note:      e.Note,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+	}
+}
+
+func toShare(e models.ShareEntity) models.Share {
+	return models.Share{
+		List:   e.List,
+		Token:  e.Token,
+		Public: e.Public,
+	}
+}
@@ -0,0 +1,454 @@
+package collections_test
+
+import (
+	"context"
+	"kbswitch/internal/core/authz"
+	corecollections "kbswitch/internal/core/collections"
+	"kbswitch/internal/core/collections/models"
+	"kbswitch/internal/core/common"
+	switchmodels "kbswitch/internal/core/switches/models"
+	"kbswitch/internal/pkg/collections"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type fakeLogger struct{}
+
+func (fakeLogger) LogError(msg string) {}
+func (fakeLogger) LogInfo(msg string)  {}
+func (fakeLogger) LogTrace(msg string) {}
+
+type memRepo struct {
+	items  []*models.ItemEntity
+	wishes []*models.WishEntity
+	shares map[models.List]map[string]models.ShareEntity
+}
+
+func newRepo() *memRepo {
+	return &memRepo{shares: map[models.List]map[string]models.ShareEntity{
+		models.ListCollection: {},
+		models.ListWishlist:   {},
+	}}
+}
+
+func (m *memRepo) Items(ctx context.Context, owner string) ([]models.ItemEntity, error) {
+	res := []models.ItemEntity{}
+	for _, e := range m.items {
+		if e != nil && e.Owner == owner {
+			res = append(res, *e)
+		}
+	}
+	return res, nil
+}
+
+func (m *memRepo) GetItem(ctx context.Context, id int) (*models.ItemEntity, error) {
+	if id < 1 || id > len(m.items) || m.items[id-1] == nil {
+		return nil, nil
+	}
+	res := *m.items[id-1]
+	return &res, nil
+}
+
+func (m *memRepo) InsertItem(ctx context.Context, e models.ItemEntity) (*int, error) {
+	e.ID = len(m.items) + 1
+	m.items = append(m.items, &e)
+	return &e.ID, nil
+}
+
+func (m *memRepo) UpdateItem(ctx context.Context, e models.ItemEntity) error {
+	m.items[e.ID-1] = &e
+	return nil
+}
+
+func (m *memRepo) DeleteItem(ctx context.Context, id int) (bool, error) {
+	ok := m.items[id-1] != nil
+	m.items[id-1] = nil
+	return ok, nil
+}
+
+func (m *memRepo) Value(ctx context.Context, owner string) (*models.Value, error) {
+	items, _ := m.Items(ctx, owner)
+
+	res := models.Value{Totals: []models.Money{}}
+	totals := map[string]int64{}
+	switches := map[int]struct{}{}
+	for _, e := range items {
+		switches[e.SwitchID] = struct{}{}
+		res.Quantity += e.Quantity
+		if e.Price == nil {
+			res.Unpriced += e.Quantity
+		} else {
+			totals[e.Price.Currency] += e.Price.Amount * int64(e.Quantity)
+		}
+	}
+	res.Switches = len(switches)
+	for currency, amount := range totals {
+		res.Totals = append(res.Totals, models.Money{Amount: amount, Currency: currency})
+	}
+	sort.Slice(res.Totals, func(i, j int) bool { return res.Totals[i].Currency < res.Totals[j].Currency })
+	return &res, nil
+}
+
+func (m *memRepo) Wishes(ctx context.Context, owner string) ([]models.WishEntity, error) {
+	res := []models.WishEntity{}
+	for _, e := range m.wishes {
+		if e != nil && e.Owner == owner {
+			res = append(res, *e)
+		}
+	}
+	return res, nil
+}
+
+func (m *memRepo) GetWish(ctx context.Context, id int) (*models.WishEntity, error) {
+	if id < 1 || id > len(m.wishes) || m.wishes[id-1] == nil {
+		return nil, nil
+	}
+	res := *m.wishes[id-1]
+	return &res, nil
+}
+
+func (m *memRepo) InsertWish(ctx context.Context, e models.WishEntity) (*int, error) {
+	for _, w := range m.wishes {
+		if w != nil && w.Owner == e.Owner && w.SwitchID == e.SwitchID {
+			return nil, nil
+		}
+	}
+	e.ID = len(m.wishes) + 1
+	m.wishes = append(m.wishes, &e)
+	return &e.ID, nil
+}
+
+func (m *memRepo) UpdateWish(ctx context.Context, e models.WishEntity) error {
+	m.wishes[e.ID-1] = &e
+	return nil
+}
+
+func (m *memRepo) DeleteWish(ctx context.Context, id int) (bool, error) {
+	ok := m.wishes[id-1] != nil
+	m.wishes[id-1] = nil
+	return ok, nil
+}
+
+func (m *memRepo) GetShare(ctx context.Context, owner string, list models.List) (*models.ShareEntity, error) {
+	e, ok := m.shares[list][owner]
+	if !ok {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+func (m *memRepo) SaveShare(ctx context.Context, e models.ShareEntity) (*models.ShareEntity, error) {
+	if old, ok := m.shares[e.List][e.Owner]; ok {
+		e.Token = old.Token
+	}
+	m.shares[e.List][e.Owner] = e
+	return &e, nil
+}
+
+func (m *memRepo) DeleteShare(ctx context.Context, owner string, list models.List) (bool, error) {
+	_, ok := m.shares[list][owner]
+	delete(m.shares[list], owner)
+	return ok, nil
+}
+
+func (m *memRepo) ShareByToken(ctx context.Context, token string) (*models.ShareEntity, error) {
+	for _, shares := range m.shares {
+		for _, e := range shares {
+			if e.Token == token {
+				return &e, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// fakeCatalog knows switches by id, Cherry Red is an alias of Cherry MX Red
+type fakeCatalog map[int]switchmodels.SwitchEntity
+
+func (f fakeCatalog) GetID(ctx context.Context, brand, name string) (*int, error) {
+	if brand == "Cherry" && name == "Red" {
+		brand, name = "Cherry", "MX Red"
+	}
+	for id, sw := range f {
+		if sw.Manufacturer == brand && sw.Model == name {
+			return &id, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f fakeCatalog) GetSingle(ctx context.Context, id int) (*switchmodels.SwitchEntity, error) {
+	sw, ok := f[id]
+	if !ok {
+		return nil, nil
+	}
+	return &sw, nil
+}
+
+var (
+	catalog = fakeCatalog{
+		1: {ID: 1, Manufacturer: "Gateron", Model: "Ink Black"},
+		2: {ID: 2, Manufacturer: "Cherry", Model: "MX Red"},
+	}
+
+	jane = authz.Principal{Subject: "user:1", Name: "jane@example.com", Role: authz.RoleViewer}
+	john = authz.Principal{Subject: "user:2", Name: "john@example.com", Role: authz.RoleViewer}
+)
+
+func as(p authz.Principal) context.Context {
+	return authz.WithPrincipal(context.Background(), p)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func newService() corecollections.Service {
+	return collections.New(fakeLogger{}, newRepo(), catalog)
+}
+
+func TestAddItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		item     models.NewItem
+		expected struct {
+			item models.Item
+			err  error
+		}
+	}{
+		{
+			name: "by id",
+			ctx:  as(jane),
+			item: models.NewItem{Switch: models.SwitchRef{ID: ptr(1)}, Quantity: 70, Condition: models.ConditionUsed, Lubed: true, Price: &models.Money{Amount: 45, Currency: "USD"}},
+			expected: struct {
+				item models.Item
+				err  error
+			}{item: models.Item{ID: 1, SwitchID: 1, Brand: "Gateron", Name: "Ink Black", Quantity: 70, Condition: models.ConditionUsed, Lubed: true, Price: &models.Money{Amount: 45, Currency: "USD"}}},
+		},
+		{
+			name: "by alias with defaults",
+			ctx:  as(jane),
+			item: models.NewItem{Switch: models.SwitchRef{Brand: "Cherry", Name: "Red"}},
+			expected: struct {
+				item models.Item
+				err  error
+			}{item: models.Item{ID: 1, SwitchID: 2, Brand: "Cherry", Name: "MX Red", Quantity: 1, Condition: models.ConditionNew}},
+		},
+		{
+			name: "anonymous",
+			ctx:  context.Background(),
+			item: models.NewItem{Switch: models.SwitchRef{ID: ptr(1)}},
+			expected: struct {
+				item models.Item
+				err  error
+			}{err: common.ErrUnauthorized},
+		},
+		{
+			name: "unknown switch",
+			ctx:  as(jane),
+			item: models.NewItem{Switch: models.SwitchRef{ID: ptr(3)}},
+			expected: struct {
+				item models.Item
+				err  error
+			}{err: common.ErrNotFound},
+		},
+		{
+			name: "no switch",
+			ctx:  as(jane),
+			item: models.NewItem{Switch: models.SwitchRef{Brand: "Cherry"}},
+			expected: struct {
+				item models.Item
+				err  error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name: "negative quantity",
+			ctx:  as(jane),
+			item: models.NewItem{Switch: models.SwitchRef{ID: ptr(1)}, Quantity: -1},
+			expected: struct {
+				item models.Item
+				err  error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name: "unknown condition",
+			ctx:  as(jane),
+			item: models.NewItem{Switch: models.SwitchRef{ID: ptr(1)}, Condition: "mint"},
+			expected: struct {
+				item models.Item
+				err  error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name: "invalid currency",
+			ctx:  as(jane),
+			item: models.NewItem{Switch: models.SwitchRef{ID: ptr(1)}, Price: &models.Money{Amount: 45, Currency: "usd"}},
+			expected: struct {
+				item models.Item
+				err  error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name: "negative price",
+			ctx:  as(jane),
+			item: models.NewItem{Switch: models.SwitchRef{ID: ptr(1)}, Price: &models.Money{Amount: -45, Currency: "USD"}},
+			expected: struct {
+				item models.Item
+				err  error
+			}{err: common.ErrBadRequest},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := newService().AddItem(test.ctx, test.item)
+			if test.expected.err != nil {
+				if err == nil || err.Errtype != test.expected.err {
+					t.Fatalf("expected error of type %v, got %v", test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			res.CreatedAt, res.UpdatedAt = test.expected.item.CreatedAt, test.expected.item.UpdatedAt
+			if !reflect.DeepEqual(*res, test.expected.item) {
+				t.Errorf("expected item %+v, got %+v", test.expected.item, *res)
+			}
+		})
+	}
+}
+
+func TestCollectionValue(t *testing.T) {
+	service := newService()
+	service.AddItem(as(jane), models.NewItem{Switch: models.SwitchRef{ID: ptr(1)}, Quantity: 70, Price: &models.Money{Amount: 45, Currency: "USD"}})
+	service.AddItem(as(jane), models.NewItem{Switch: models.SwitchRef{ID: ptr(1)}, Quantity: 10, Condition: models.ConditionUsed, Price: &models.Money{Amount: 30, Currency: "USD"}})
+	service.AddItem(as(jane), models.NewItem{Switch: models.SwitchRef{ID: ptr(2)}, Quantity: 90, Price: &models.Money{Amount: 40, Currency: "EUR"}})
+	service.AddItem(as(jane), models.NewItem{Switch: models.SwitchRef{ID: ptr(2)}, Quantity: 5})
+	service.AddItem(as(john), models.NewItem{Switch: models.SwitchRef{ID: ptr(2)}, Quantity: 1000, Price: &models.Money{Amount: 40, Currency: "EUR"}})
+
+	res, err := service.Collection(as(jane))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := models.Value{
+		Totals:   []models.Money{{Amount: 3600, Currency: "EUR"}, {Amount: 3450, Currency: "USD"}},
+		Switches: 2,
+		Quantity: 175,
+		Unpriced: 5,
+	}
+	if len(res.Items) != 4 || !reflect.DeepEqual(res.Value, expected) {
+		t.Errorf("expected 4 items worth %+v, got %d worth %+v", expected, len(res.Items), res.Value)
+	}
+}
+
+func TestItemsOfOthers(t *testing.T) {
+	service := newService()
+	item, _ := service.AddItem(as(jane), models.NewItem{Switch: models.SwitchRef{ID: ptr(1)}})
+
+	if _, err := service.UpdateItem(as(john), item.ID, models.NewItem{Quantity: 2}); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected item of another user not to be found on update, got %v", err)
+	}
+	if err := service.RemoveItem(as(john), item.ID); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected item of another user not to be found on remove, got %v", err)
+	}
+
+	res, err := service.UpdateItem(as(jane), item.ID, models.NewItem{Switch: models.SwitchRef{ID: ptr(2)}, Quantity: 2, Lubed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.SwitchID != 1 || res.Quantity != 2 || !res.Lubed {
+		t.Errorf("expected quantity and lube to change but not the switch, got %+v", *res)
+	}
+	if err := service.RemoveItem(as(jane), item.ID); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestWishlist(t *testing.T) {
+	service := newService()
+
+	wish, err := service.AddWish(as(jane), models.NewWish{Switch: models.SwitchRef{Brand: "Gateron", Name: "Ink Black"}, Note: " for the 65% "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wish.SwitchID != 1 || wish.Quantity != 1 || wish.Note != "for the 65%" {
+		t.Errorf("unexpected wish %+v", *wish)
+	}
+
+	if _, err := service.AddWish(as(jane), models.NewWish{Switch: models.SwitchRef{ID: ptr(1)}}); err == nil || err.Errtype != common.ErrConflict {
+		t.Errorf("expected switch to be wished for once, got %v", err)
+	}
+	if _, err := service.AddWish(as(john), models.NewWish{Switch: models.SwitchRef{ID: ptr(1)}}); err != nil {
+		t.Errorf("expected others to wish for the same switch, got %v", err)
+	}
+
+	if _, err := service.UpdateWish(as(jane), wish.ID, models.NewWish{Quantity: 90}); err != nil {
+		t.Fatal(err)
+	}
+	wishes, _ := service.Wishlist(as(jane))
+	if len(wishes) != 1 || wishes[0].Quantity != 90 {
+		t.Errorf("expected a single wish of 90 switches, got %+v", wishes)
+	}
+}
+
+func TestShare(t *testing.T) {
+	service := newService()
+	service.AddItem(as(jane), models.NewItem{Switch: models.SwitchRef{ID: ptr(1)}, Price: &models.Money{Amount: 45, Currency: "USD"}, Note: "from the group buy"})
+
+	if _, err := service.Share(as(jane), models.ListCollection); err == nil || err.Errtype != common.ErrNotFound {
+		t.Fatalf("expected no link before sharing, got %v", err)
+	}
+
+	private, err := service.SetShare(as(jane), models.ListCollection, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ctx := range []context.Context{as(john), context.Background()} {
+		shared, err := service.Shared(ctx, private.Token)
+		if err != nil {
+			t.Fatalf("expected private link to work for whoever holds it, got %v", err)
+		}
+		if shared.Public || len(shared.Items) != 1 {
+			t.Errorf("expected unlisted collection, got %+v", *shared)
+		}
+	}
+	if _, err := service.Shared(as(john), "made-up"); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected unknown link not to work, got %v", err)
+	}
+
+	public, err := service.SetShare(as(jane), models.ListCollection, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if public.Token != private.Token {
+		t.Errorf("expected link to stay the same when made public")
+	}
+
+	shared, err := service.Shared(context.Background(), public.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared.List != models.ListCollection || len(shared.Items) != 1 || shared.Items[0].Price != nil || shared.Items[0].Note != "" {
+		t.Errorf("expected collection without prices and notes, got %+v", *shared)
+	}
+
+	if err := service.Unshare(as(jane), models.ListCollection); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Shared(context.Background(), public.Token); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected dropped link to stop working, got %v", err)
+	}
+
+	again, err := service.SetShare(as(jane), models.ListCollection, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Token == public.Token {
+		t.Errorf("expected sharing again to give another link")
+	}
+}
@@ -0,0 +1,337 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/collections"
+	"kbswitch/internal/core/collections/models"
+	"kbswitch/internal/core/common/database"
+	"kbswitch/internal/core/common/logging"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func New(logger logging.Logger, pool database.DBPool) collections.Repo {
+	return repo{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+type repo struct {
+	logger logging.Logger
+	pool   database.DBPool
+}
+
+// column order must match scanItem, brand and name come from the joined switch
+const itemColumns = `i.id, i.owner, i.switch_id, i.quantity, i.condition, i.lubed, i.price, i.currency, i.note,
+	i.created_at, i.updated_at, s.manufacturer, s.model`
+
+const itemsFrom = ` FROM public.collection_items i JOIN public.switches s ON s.id = i.switch_id`
+
+func scanItem(row pgx.Row, e *models.ItemEntity) error {
+	var condition string
+	var price *int64
+	var currency *string
+	err := row.Scan(&e.ID, &e.Owner, &e.SwitchID, &e.Quantity, &condition, &e.Lubed, &price, &currency, &e.Note,
+		&e.CreatedAt, &e.UpdatedAt, &e.Brand, &e.Name)
+	if err != nil {
+		return err
+	}
+
+	e.Condition = models.Condition(condition)
+	if price != nil && currency != nil {
+		e.Price = &models.Money{Amount: *price, Currency: *currency}
+	}
+	return nil
+}
+
+// column order must match scanWish
+const wishColumns = `w.id, w.owner, w.switch_id, w.quantity, w.note, w.created_at, w.updated_at, s.manufacturer, s.model`
+
+const wishesFrom = ` FROM public.wishlist_items w JOIN public.switches s ON s.id = w.switch_id`
+
+func scanWish(row pgx.Row, e *models.WishEntity) error {
+	return row.Scan(&e.ID, &e.Owner, &e.SwitchID, &e.Quantity, &e.Note, &e.CreatedAt, &e.UpdatedAt, &e.Brand, &e.Name)
+}
+
+const shareColumns = `owner, list, token, public, created_at`
+
+func scanShare(row pgx.Row, e *models.ShareEntity) error {
+	var list string
+	if err := row.Scan(&e.Owner, &list, &e.Token, &e.Public, &e.CreatedAt); err != nil {
+		return err
+	}
+	e.List = models.List(list)
+	return nil
+}
+
+func price(m *models.Money) (*int64, *string) {
+	if m == nil {
+		return nil, nil
+	}
+	return &m.Amount, &m.Currency
+}
+
+// Items implements collections.Repo.
+func (r repo) Items(ctx context.Context, owner string) ([]models.ItemEntity, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+itemColumns+itemsFrom+` WHERE i.owner = $1 ORDER BY i.created_at, i.id`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.ItemEntity{}
+	for rows.Next() {
+		var e models.ItemEntity
+		if err := scanItem(rows, &e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d collection items of %s read", len(res), owner))
+
+	return res, nil
+}
+
+// GetItem implements collections.Repo.
+func (r repo) GetItem(ctx context.Context, id int) (*models.ItemEntity, error) {
+	var e models.ItemEntity
+	err := scanItem(r.pool.QueryRow(ctx, `SELECT `+itemColumns+itemsFrom+` WHERE i.id = $1`, id), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// InsertItem implements collections.Repo.
+func (r repo) InsertItem(ctx context.Context, e models.ItemEntity) (*int, error) {
+	query := `INSERT INTO public.collection_items (owner, switch_id, quantity, condition, lubed, price, currency, note, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING id`
+
+	amount, currency := price(e.Price)
+	var id int
+	err := r.pool.QueryRow(ctx, query, e.Owner, e.SwitchID, e.Quantity, string(e.Condition), e.Lubed, amount, currency,
+		e.Note, e.CreatedAt, e.UpdatedAt).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("collection item %d inserted", id))
+
+	return &id, nil
+}
+
+// UpdateItem implements collections.Repo.
+func (r repo) UpdateItem(ctx context.Context, e models.ItemEntity) error {
+	query := `UPDATE public.collection_items
+	SET quantity = $2, condition = $3, lubed = $4, price = $5, currency = $6, note = $7, updated_at = $8
+	WHERE id = $1`
+
+	amount, currency := price(e.Price)
+	_, err := r.pool.Exec(ctx, query, e.ID, e.Quantity, string(e.Condition), e.Lubed, amount, currency, e.Note, e.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("collection item %d updated", e.ID))
+
+	return nil
+}
+
+// DeleteItem implements collections.Repo.
+func (r repo) DeleteItem(ctx context.Context, id int) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM public.collection_items WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("collection item %d deleted", id))
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// Value implements collections.Repo.
+func (r repo) Value(ctx context.Context, owner string) (*models.Value, error) {
+	res := models.Value{Totals: []models.Money{}}
+
+	query := `SELECT count(DISTINCT switch_id), coalesce(sum(quantity), 0), coalesce(sum(quantity) FILTER (WHERE price IS NULL), 0)
+	FROM public.collection_items
+	WHERE owner = $1`
+
+	err := r.pool.QueryRow(ctx, query, owner).Scan(&res.Switches, &res.Quantity, &res.Unpriced)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `SELECT currency, sum(price * quantity)::BIGINT
+	FROM public.collection_items
+	WHERE owner = $1 AND price IS NOT NULL
+	GROUP BY currency
+	ORDER BY currency`
+
+	rows, err := r.pool.Query(ctx, query, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.Money
+		if err := rows.Scan(&m.Currency, &m.Amount); err != nil {
+			return nil, err
+		}
+		res.Totals = append(res.Totals, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Wishes implements collections.Repo.
+func (r repo) Wishes(ctx context.Context, owner string) ([]models.WishEntity, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+wishColumns+wishesFrom+` WHERE w.owner = $1 ORDER BY w.created_at, w.id`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.WishEntity{}
+	for rows.Next() {
+		var e models.WishEntity
+		if err := scanWish(rows, &e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d wishes of %s read", len(res), owner))
+
+	return res, nil
+}
+
+// GetWish implements collections.Repo.
+func (r repo) GetWish(ctx context.Context, id int) (*models.WishEntity, error) {
+	var e models.WishEntity
+	err := scanWish(r.pool.QueryRow(ctx, `SELECT `+wishColumns+wishesFrom+` WHERE w.id = $1`, id), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// InsertWish implements collections.Repo.
+func (r repo) InsertWish(ctx context.Context, e models.WishEntity) (*int, error) {
+	query := `INSERT INTO public.wishlist_items (owner, switch_id, quantity, note, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (owner, switch_id) DO NOTHING
+	RETURNING id`
+
+	var id int
+	err := r.pool.QueryRow(ctx, query, e.Owner, e.SwitchID, e.Quantity, e.Note, e.CreatedAt, e.UpdatedAt).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		r.logger.LogTrace(fmt.Sprintf("%s wishes for switch %d already", e.Owner, e.SwitchID))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("wish %d inserted", id))
+
+	return &id, nil
+}
+
+// UpdateWish implements collections.Repo.
+func (r repo) UpdateWish(ctx context.Context, e models.WishEntity) error {
+	query := `UPDATE public.wishlist_items SET quantity = $2, note = $3, updated_at = $4 WHERE id = $1`
+
+	if _, err := r.pool.Exec(ctx, query, e.ID, e.Quantity, e.Note, e.UpdatedAt); err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("wish %d updated", e.ID))
+
+	return nil
+}
+
+// DeleteWish implements collections.Repo.
+func (r repo) DeleteWish(ctx context.Context, id int) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM public.wishlist_items WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("wish %d deleted", id))
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetShare implements collections.Repo.
+func (r repo) GetShare(ctx context.Context, owner string, list models.List) (*models.ShareEntity, error) {
+	query := `SELECT ` + shareColumns + ` FROM public.list_shares WHERE owner = $1 AND list = $2`
+
+	var e models.ShareEntity
+	err := scanShare(r.pool.QueryRow(ctx, query, owner, string(list)), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// SaveShare implements collections.Repo.
+func (r repo) SaveShare(ctx context.Context, e models.ShareEntity) (*models.ShareEntity, error) {
+	query := `INSERT INTO public.list_shares (` + shareColumns + `)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (owner, list) DO UPDATE SET public = EXCLUDED.public
+	RETURNING ` + shareColumns
+
+	var res models.ShareEntity
+	err := scanShare(r.pool.QueryRow(ctx, query, e.Owner, string(e.List), e.Token, e.Public, e.CreatedAt), &res)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("share of %s of %s saved", e.List, e.Owner))
+
+	return &res, nil
+}
+
+// DeleteShare implements collections.Repo.
+func (r repo) DeleteShare(ctx context.Context, owner string, list models.List) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM public.list_shares WHERE owner = $1 AND list = $2`, owner, string(list))
+	if err != nil {
+		return false, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("share of %s of %s deleted", list, owner))
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// ShareByToken implements collections.Repo.
+func (r repo) ShareByToken(ctx context.Context, token string) (*models.ShareEntity, error) {
+	var e models.ShareEntity
+	err := scanShare(r.pool.QueryRow(ctx, `SELECT `+shareColumns+` FROM public.list_shares WHERE token = $1`, token), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
@@ -0,0 +1,257 @@
+package contentfilter
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/contentfilter/models"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrRejected = common.NewError(common.ErrBadRequest, "content was rejected")
+	ErrHeld     = common.NewError(common.ErrBadRequest, "content needs a moderator to look at it first")
+	ErrTooMany  = common.NewError(common.ErrTooManyRequests, "too many posts, try again later")
+)
+
+var link = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+type Config struct {
+	// Blocked words and phrases make content rejected
+	Blocked []string
+	// Flagged words and phrases make content held for review
+	Flagged []string
+	// MaxLinks is how many links content may have before it is held, negative allows any number
+	MaxLinks int
+	// DuplicateWindow is how long text is remembered for, same text of another user is held meanwhile
+	DuplicateWindow time.Duration
+	// MinDuplicateLength leaves shorter texts, which are alike naturally, out of duplicate detection
+	MinDuplicateLength int
+	// RateLimit is how many posts one user may write in RateWindow, zero or less turns the limit off
+	RateLimit  int
+	RateWindow time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxLinks:           2,
+		DuplicateWindow:    24 * time.Hour,
+		MinDuplicateLength: 30,
+		RateLimit:          10,
+		RateWindow:         time.Hour,
+	}
+}
+
+// wordList finds words and phrases in analyzed text, it knows each of them by the way it is written in the list
+type wordList struct {
+	words    map[string]string
+	squeezed map[string]string
+	phrases  map[string]string
+	longest  int
+}
+
+func newWordList(entries []string) wordList {
+	l := wordList{
+		words:    map[string]string{},
+		squeezed: map[string]string{},
+		phrases:  map[string]string{},
+	}
+	for _, entry := range entries {
+		a := analyze(entry)
+		switch len(a.words) {
+		case 0:
+			continue
+		case 1:
+			l.words[a.words[0]] = entry
+			l.squeezed[squeeze(a.words[0])] = entry
+		default:
+			l.phrases[strings.Join(a.words, " ")] = entry
+			l.longest = max(l.longest, len(a.words))
+		}
+	}
+	return l
+}
+
+// find gives entries of the list which text has, in order of the list
+func (l wordList) find(text analysis) []string {
+	found := map[string]struct{}{}
+	for _, w := range append(slices.Clone(text.words), text.variants...) {
+		if entry, ok := l.words[w]; ok {
+			found[entry] = struct{}{}
+		} else if entry, ok := l.squeezed[squeeze(w)]; ok && stretched(w) {
+			found[entry] = struct{}{}
+		}
+	}
+	for i := range text.words {
+		for n := 2; n <= l.longest && i+n <= len(text.words); n++ {
+			if entry, ok := l.phrases[strings.Join(text.words[i:i+n], " ")]; ok {
+				found[entry] = struct{}{}
+			}
+		}
+	}
+
+	res := make([]string, 0, len(found))
+	for entry := range found {
+		res = append(res, entry)
+	}
+	slices.Sort(res)
+	return res
+}
+
+type post struct {
+	author string
+	at     time.Time
+}
+
+// Filter keeps what it needs to judge content in memory, it is safe for concurrent use
+type Filter struct {
+	config  Config
+	blocked wordList
+	flagged wordList
+	now     func() time.Time
+
+	mu sync.Mutex
+	// author -> times of posts within rate window
+	posts map[string][]time.Time
+	// fingerprint of text -> posts of it within duplicate window
+	seen      map[[sha256.Size]byte][]post
+	lastSweep time.Time
+}
+
+func New(config Config) *Filter {
+	return NewWithClock(config, time.Now)
+}
+
+// NewWithClock is New with time told by now instead of the wall clock
+func NewWithClock(config Config, now func() time.Time) *Filter {
+	return &Filter{
+		config:  config,
+		blocked: newWordList(config.Blocked),
+		flagged: newWordList(config.Flagged),
+		now:     now,
+		posts:   map[string][]time.Time{},
+		seen:    map[[sha256.Size]byte][]post{},
+	}
+}
+
+func raise(v *models.Verdict, code models.ReasonCode, action models.Action, detail string) {
+	v.Reasons = append(v.Reasons, models.Reason{Code: code, Action: action, Detail: detail})
+	if action.Stricter(v.Action) {
+		v.Action = action
+	}
+}
+
+func (f *Filter) Check(content models.Content) models.Verdict {
+	v := models.Verdict{Action: models.ActionAllow}
+	text := analyze(content.Text)
+
+	for _, w := range f.blocked.find(text) {
+		raise(&v, models.ReasonBlockedWord, models.ActionReject, fmt.Sprintf("'%s' is not allowed", w))
+	}
+	for _, w := range f.flagged.find(text) {
+		raise(&v, models.ReasonFlaggedWord, models.ActionHold, fmt.Sprintf("'%s' needs a review", w))
+	}
+	if links := len(link.FindAllString(content.Text, -1)); f.config.MaxLinks >= 0 && links > f.config.MaxLinks {
+		raise(&v, models.ReasonTooManyLinks, models.ActionHold, fmt.Sprintf("%d links, at most %d are allowed", links, f.config.MaxLinks))
+	}
+
+	normalized := strings.Join(text.words, " ")
+	duplicate := utf8.RuneCountInString(normalized) >= f.config.MinDuplicateLength
+	fingerprint := sha256.Sum256([]byte(normalized))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	f.sweep(now)
+
+	recent := slices.DeleteFunc(f.posts[content.Author], f.expired(now, f.config.RateWindow))
+	if f.config.RateLimit > 0 && len(recent) >= f.config.RateLimit {
+		raise(&v, models.ReasonRateLimited, models.ActionReject, fmt.Sprintf("at most %d posts in %s are allowed", f.config.RateLimit, f.config.RateWindow))
+	}
+	if duplicate {
+		for _, p := range f.seen[fingerprint] {
+			if f.expired(now, f.config.DuplicateWindow)(p.at) {
+				continue
+			}
+			if p.author != content.Author {
+				raise(&v, models.ReasonDuplicate, models.ActionHold, "same text was posted by another user")
+				break
+			}
+		}
+	}
+
+	if v.Action == models.ActionReject {
+		return v
+	}
+	if f.config.RateLimit > 0 {
+		f.posts[content.Author] = append(recent, now)
+	}
+	if duplicate {
+		f.seen[fingerprint] = append(f.seen[fingerprint], post{author: content.Author, at: now})
+	}
+	return v
+}
+
+func (f *Filter) expired(now time.Time, window time.Duration) func(time.Time) bool {
+	return func(t time.Time) bool {
+		return now.Sub(t) >= window
+	}
+}
+
+// sweep forgets posts of users who stopped posting, at most once a minute
+func (f *Filter) sweep(now time.Time) {
+	if now.Sub(f.lastSweep) < time.Minute {
+		return
+	}
+	f.lastSweep = now
+
+	for author, times := range f.posts {
+		times = slices.DeleteFunc(times, f.expired(now, f.config.RateWindow))
+		if len(times) == 0 {
+			delete(f.posts, author)
+		} else {
+			f.posts[author] = times
+		}
+	}
+	for fp, posts := range f.seen {
+		posts = slices.DeleteFunc(posts, func(p post) bool { return f.expired(now, f.config.DuplicateWindow)(p.at) })
+		if len(posts) == 0 {
+			delete(f.seen, fp)
+		} else {
+			f.seen[fp] = posts
+		}
+	}
+}
+
+// Enforce turns verdict into the error a caller answers with. Held content passes when it
+// is moderated anyway, otherwise it is refused the same as rejected content is
+func Enforce(v models.Verdict, moderated bool) *common.AppError {
+	switch {
+	case v.Action == models.ActionAllow:
+		return nil
+	case v.Action == models.ActionHold && moderated:
+		return nil
+	}
+
+	details := make([]string, len(v.Reasons))
+	limited := false
+	for i, r := range v.Reasons {
+		details[i] = r.Detail
+		limited = limited || r.Code == models.ReasonRateLimited
+	}
+
+	e := ErrRejected
+	if limited {
+		e = ErrTooMany
+	} else if v.Action == models.ActionHold {
+		e = ErrHeld
+	}
+	err := common.NewError(e.Errtype, fmt.Sprintf("%s: %s", e.Reason, strings.Join(details, "; ")))
+	return &err
+}
@@ -0,0 +1,240 @@
+package contentfilter_test
+
+import (
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/contentfilter/models"
+	"kbswitch/internal/pkg/contentfilter"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func newFilter() (*clock, *contentfilter.Filter) {
+	config := contentfilter.DefaultConfig()
+	config.Blocked = []string{"shit", "buy followers"}
+	config.Flagged = []string{"crap"}
+	config.RateLimit = 3
+
+	c := &clock{t: time.Date(2024, 10, 24, 12, 0, 0, 0, time.UTC)}
+	return c, contentfilter.NewWithClock(config, c.now)
+}
+
+func codes(v models.Verdict) []models.ReasonCode {
+	res := []models.ReasonCode{}
+	for _, r := range v.Reasons {
+		res = append(res, r.Code)
+	}
+	return res
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected struct {
+			action models.Action
+			codes  []models.ReasonCode
+		}
+	}{
+		{
+			text: "Smooth and deep, one of the best linears I tried.",
+			expected: struct {
+				action models.Action
+				codes  []models.ReasonCode
+			}{action: models.ActionAllow, codes: []models.ReasonCode{}},
+		},
+		{
+			text: "sounds like SHIT",
+			expected: struct {
+				action models.Action
+				codes  []models.ReasonCode
+			}{action: models.ActionReject, codes: []models.ReasonCode{models.ReasonBlockedWord}},
+		},
+		{
+			text: "sounds like sh1t",
+			expected: struct {
+				action models.Action
+				codes  []models.ReasonCode
+			}{action: models.ActionReject, codes: []models.ReasonCode{models.ReasonBlockedWord}},
+		},
+		{
+			text: "sounds like $h!t",
+			expected: struct {
+				action models.Action
+				codes  []models.ReasonCode
+			}{action: models.ActionReject, codes: []models.ReasonCode{models.ReasonBlockedWord}},
+		},
+		{
+			text: "sounds like s.h.i.t",
+			expected: struct {
+				action models.Action
+				codes  []models.ReasonCode
+			}{action: models.ActionReject, codes: []models.ReasonCode{models.ReasonBlockedWord}},
+		},
+		{
+			text: "sounds like shiiiiit",
+			expected: struct {
+				action models.Action
+				codes  []models.ReasonCode
+			}{action: models.ActionReject, codes: []models.ReasonCode{models.ReasonBlockedWord}},
+		},
+		{
+			text: "sounds like šhít",
+			expected: struct {
+				action models.Action
+				codes  []models.ReasonCode
+			}{action: models.ActionReject, codes: []models.ReasonCode{models.ReasonBlockedWord}},
+		},
+		{
+			// words are matched whole, the list word inside of another one is no match
+			text: "shitake mushrooms are tastier than this switch",
+			expected: struct {
+				action models.Action
+				codes  []models.ReasonCode
+			}{action: models.ActionAllow, codes: []models.ReasonCode{}},
+		},
+		{
+			text: "cheap! BUY   f0ll0wers now",
+			expected: struct {
+				action models.Action
+				codes  []models.ReasonCode
+			}{action: models.ActionReject, codes: []models.ReasonCode{models.ReasonBlockedWord}},
+		},
+		{
+			text: "feels like cr4p",
+			expected: struct {
+				action models.Action
+				codes  []models.ReasonCode
+			}{action: models.ActionHold, codes: []models.ReasonCode{models.ReasonFlaggedWord}},
+		},
+		{
+			text: "crap, total shit",
+			expected: struct {
+				action models.Action
+				codes  []models.ReasonCode
+			}{action: models.ActionReject, codes: []models.ReasonCode{models.ReasonBlockedWord, models.ReasonFlaggedWord}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			_, filter := newFilter()
+
+			v := filter.Check(models.Content{Author: "user:1", Text: test.text})
+			if v.Action != test.expected.action || !reflect.DeepEqual(codes(v), test.expected.codes) {
+				t.Errorf("expected %s for %v, got %s for %+v", test.expected.action, test.expected.codes, v.Action, v.Reasons)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	_, filter := newFilter()
+
+	if v := filter.Check(models.Content{Author: "user:1", Text: "datasheet at https://gateron.com/ink and www.gateron.com"}); v.Action != models.ActionAllow {
+		t.Errorf("expected two links to be allowed, got %+v", v)
+	}
+
+	v := filter.Check(models.Content{Author: "user:2", Text: "http://a.example http://b.example HTTPS://c.example"})
+	if v.Action != models.ActionHold || !reflect.DeepEqual(codes(v), []models.ReasonCode{models.ReasonTooManyLinks}) {
+		t.Errorf("expected three links to be held, got %+v", v)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	c, filter := newFilter()
+	text := "Best switch ever, get yours at a discount today!"
+
+	if v := filter.Check(models.Content{Author: "user:1", Text: text}); v.Action != models.ActionAllow {
+		t.Fatalf("expected first post to be allowed, got %+v", v)
+	}
+	if v := filter.Check(models.Content{Author: "user:1", Text: text}); v.Action != models.ActionAllow {
+		t.Errorf("expected same user to repeat text, got %+v", v)
+	}
+
+	v := filter.Check(models.Content{Author: "user:2", Text: "best SWITCH ever... get yours at a discount today"})
+	if v.Action != models.ActionHold || !reflect.DeepEqual(codes(v), []models.ReasonCode{models.ReasonDuplicate}) {
+		t.Errorf("expected text of another user to be held, got %+v", v)
+	}
+
+	if v := filter.Check(models.Content{Author: "user:3", Text: "great"}); v.Action != models.ActionAllow {
+		t.Fatalf("expected short post to be allowed, got %+v", v)
+	}
+	if v := filter.Check(models.Content{Author: "user:4", Text: "great"}); v.Action != models.ActionAllow {
+		t.Errorf("expected short text to be left out of duplicate detection, got %+v", v)
+	}
+
+	c.t = c.t.Add(25 * time.Hour)
+	if v := filter.Check(models.Content{Author: "user:5", Text: text}); v.Action != models.ActionAllow {
+		t.Errorf("expected text to be forgotten after a day, got %+v", v)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	c, filter := newFilter()
+
+	for i := range 3 {
+		if v := filter.Check(models.Content{Author: "user:1", Text: "shit"}); v.Action != models.ActionReject {
+			t.Fatalf("expected post %d to be rejected, got %+v", i, v)
+		}
+	}
+	for i := range 3 {
+		if v := filter.Check(models.Content{Author: "user:1", Text: "fine"}); v.Action != models.ActionAllow {
+			t.Fatalf("expected post %d to be allowed as rejected ones don't count, got %+v", i, v)
+		}
+		c.t = c.t.Add(10 * time.Minute)
+	}
+
+	v := filter.Check(models.Content{Author: "user:1", Text: "fine"})
+	if v.Action != models.ActionReject || !reflect.DeepEqual(codes(v), []models.ReasonCode{models.ReasonRateLimited}) {
+		t.Errorf("expected fourth post within an hour to be rejected, got %+v", v)
+	}
+	if v := filter.Check(models.Content{Author: "user:2", Text: "fine"}); v.Action != models.ActionAllow {
+		t.Errorf("expected other users not to be limited, got %+v", v)
+	}
+
+	c.t = c.t.Add(30 * time.Minute)
+	if v := filter.Check(models.Content{Author: "user:1", Text: "fine"}); v.Action != models.ActionAllow {
+		t.Errorf("expected post to be allowed once the first one is an hour old, got %+v", v)
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	held := models.Verdict{Action: models.ActionHold, Reasons: []models.Reason{{Code: models.ReasonTooManyLinks, Action: models.ActionHold, Detail: "3 links"}}}
+	limited := models.Verdict{Action: models.ActionReject, Reasons: []models.Reason{{Code: models.ReasonRateLimited, Action: models.ActionReject}}}
+
+	tests := []struct {
+		name      string
+		verdict   models.Verdict
+		moderated bool
+		expected  error
+	}{
+		{name: "allowed", verdict: models.Verdict{Action: models.ActionAllow}},
+		{name: "held for moderated", verdict: held, moderated: true},
+		{name: "held", verdict: held, expected: common.ErrBadRequest},
+		{name: "rejected", verdict: models.Verdict{Action: models.ActionReject}, moderated: true, expected: common.ErrBadRequest},
+		{name: "rate limited", verdict: limited, expected: common.ErrTooManyRequests},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := contentfilter.Enforce(test.verdict, test.moderated)
+			if test.expected == nil {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil || err.Errtype != test.expected {
+				t.Errorf("expected error of type %v, got %v", test.expected, err)
+			}
+		})
+	}
+}
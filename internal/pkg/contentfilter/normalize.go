@@ -0,0 +1,114 @@
+package contentfilter
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// leet folds characters standing in for letters into those letters. l and 1 both become i,
+// words of the lists are folded the same way as text is, so they still meet
+var leet = map[rune]rune{
+	'0': 'o', '1': 'i', '!': 'i', '|': 'i', 'l': 'i',
+	'3': 'e', '4': 'a', '@': 'a', '5': 's', '$': 's',
+	'7': 't', '+': 't', '8': 'b', '9': 'g',
+}
+
+// symbol is a leet character which is neither letter nor digit, it is part of a word only inside of it
+func symbol(r rune) bool {
+	_, ok := leet[r]
+	return ok && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func fold(s string) string {
+	return strings.Map(func(r rune) rune {
+		if f, ok := leet[r]; ok {
+			return f
+		}
+		return r
+	}, s)
+}
+
+// squeeze drops repeated letters, "fuuuun" becomes "fun"
+func squeeze(s string) string {
+	var b strings.Builder
+	var last rune
+	for i, r := range s {
+		if i == 0 || r != last {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+	return b.String()
+}
+
+// stretched tells whether s repeats a letter more than a word would, like "fuuun" does
+func stretched(s string) bool {
+	run, last := 0, rune(-1)
+	for _, r := range s {
+		if r == last {
+			run++
+		} else {
+			run, last = 1, r
+		}
+		if run >= 3 {
+			return true
+		}
+	}
+	return false
+}
+
+// analysis is text reduced to folded words
+type analysis struct {
+	// words of the text in order, symbols at their ends dropped
+	words []string
+	// other readings of the words, with symbols at their ends folded and with letters spelled apart joined
+	variants []string
+}
+
+// analyze lowercases text, drops accents and other marks, then folds its words.
+// "$h1t" reads as "hit" and as "shit", "s h i t" reads as "shit" as well
+func analyze(text string) analysis {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(text) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+
+	fields := strings.FieldsFunc(b.String(), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !symbol(r)
+	})
+
+	res := analysis{}
+	var spelled []string
+	flush := func() {
+		if len(spelled) >= 3 {
+			res.variants = append(res.variants, strings.Join(spelled, ""))
+		}
+		spelled = spelled[:0]
+	}
+
+	for _, f := range fields {
+		trimmed := strings.TrimFunc(f, symbol)
+		if trimmed == "" {
+			continue
+		}
+
+		word := fold(trimmed)
+		res.words = append(res.words, word)
+		if whole := fold(f); whole != word {
+			res.variants = append(res.variants, whole)
+		}
+
+		if len([]rune(word)) == 1 {
+			spelled = append(spelled, word)
+		} else {
+			flush()
+		}
+	}
+	flush()
+
+	return res
+}
@@ -0,0 +1,130 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/common/database"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/idempotency"
+	"kbswitch/internal/core/idempotency/models"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// purgeBatch bounds the number of expired keys a single Begin removes
+const purgeBatch = 100
+
+func New(logger logging.Logger, pool database.DBPool) idempotency.Store {
+	return repo{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+type repo struct {
+	logger logging.Logger
+	pool   database.DBPool
+}
+
+// Begin implements idempotency.Store.
+func (r repo) Begin(ctx context.Context, client, key, fingerprint string, ttl time.Duration) (*models.Record, error) {
+	r.purge(ctx)
+
+	// expired keys are taken over as if they never existed
+	query := `INSERT INTO public.idempotency_keys (client, key, fingerprint, expires_at)
+	VALUES ($1, $2, $3, now() + $4 * interval '1 millisecond')
+	ON CONFLICT (client, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, expires_at = EXCLUDED.expires_at,
+			status = NULL, header = NULL, body = NULL
+		WHERE idempotency_keys.expires_at <= now()
+	RETURNING true`
+
+	var claimed bool
+	err := r.pool.QueryRow(ctx, query, client, key, fingerprint, ttl.Milliseconds()).Scan(&claimed)
+	if err == nil {
+		r.logger.LogTrace(fmt.Sprintf("idempotency key %s claimed", key))
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	query = `SELECT fingerprint, status, header, body, expires_at FROM public.idempotency_keys
+	WHERE client = $1 AND key = $2`
+
+	var (
+		record models.Record
+		status *int
+		header []byte
+		body   []byte
+	)
+	err = r.pool.QueryRow(ctx, query, client, key).Scan(&record.Fingerprint, &status, &header, &body, &record.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != nil {
+		record.Response = &models.Response{
+			Status: *status,
+			Header: http.Header{},
+			Body:   body,
+		}
+		if err := json.Unmarshal(header, &record.Response.Header); err != nil {
+			return nil, err
+		}
+	}
+	r.logger.LogTrace(fmt.Sprintf("idempotency key %s already used", key))
+
+	return &record, nil
+}
+
+// Complete implements idempotency.Store.
+func (r repo) Complete(ctx context.Context, client, key string, resp models.Response) error {
+	header, err := json.Marshal(resp.Header)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE public.idempotency_keys SET status = $3, header = $4, body = $5
+	WHERE client = $1 AND key = $2`
+
+	_, err = r.pool.Exec(ctx, query, client, key, resp.Status, header, resp.Body)
+	if err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("response of idempotency key %s stored", key))
+
+	return nil
+}
+
+// Release implements idempotency.Store.
+func (r repo) Release(ctx context.Context, client, key string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM public.idempotency_keys WHERE client = $1 AND key = $2`, client, key)
+	if err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("idempotency key %s released", key))
+
+	return nil
+}
+
+// purge removes a batch of expired keys, keys are unique per request mostly and are never
+// taken over otherwise. Failure only delays the cleanup, so it does not fail the request.
+func (r repo) purge(ctx context.Context) {
+	query := `DELETE FROM public.idempotency_keys WHERE ctid IN (
+		SELECT ctid FROM public.idempotency_keys WHERE expires_at <= now() LIMIT $1
+	)`
+
+	tag, err := r.pool.Exec(ctx, query, purgeBatch)
+	if err != nil {
+		r.logger.LogError(fmt.Sprintf("purge of expired idempotency keys failed: %v", err))
+		return
+	}
+	if tag.RowsAffected() > 0 {
+		r.logger.LogTrace(fmt.Sprintf("%d expired idempotency keys purged", tag.RowsAffected()))
+	}
+}
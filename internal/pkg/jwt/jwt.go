@@ -0,0 +1,252 @@
+// Package jwt signs and verifies compact JSON web tokens with HS256 and EdDSA (Ed25519).
+// Only what the api needs is implemented, e.g. there is no support for encrypted tokens
+package jwt
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+
+	// Leeway tolerates clock skew between issuer and verifier
+	Leeway = 30 * time.Second
+)
+
+var (
+	ErrMalformed        = errors.New("token is malformed")
+	ErrUnknownKey       = errors.New("token is signed with an unknown key")
+	ErrAlgMismatch      = errors.New("token algorithm does not match its key")
+	ErrInvalidSignature = errors.New("token signature is invalid")
+	ErrExpired          = errors.New("token has expired")
+	ErrNotYetValid      = errors.New("token is not valid yet")
+	ErrWrongIssuer      = errors.New("token is issued by somebody else")
+)
+
+var enc = base64.RawURLEncoding
+
+type Claims struct {
+	Issuer    string `json:"iss,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ID        string `json:"jti,omitempty"`
+	// private claims of the api
+	Name string `json:"name,omitempty"`
+	Role string `json:"role,omitempty"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// Key signs and verifies tokens with a single algorithm
+type Key interface {
+	Alg() string
+	ID() string
+	Sign(data []byte) ([]byte, error)
+	Verify(data, sig []byte) bool
+}
+
+type hs256 struct {
+	id     string
+	secret []byte
+}
+
+// HS256 gives a key signing with HMAC SHA-256, secret should be at least 32 random bytes
+func HS256(id string, secret []byte) Key {
+	return hs256{id: id, secret: secret}
+}
+
+func (k hs256) Alg() string { return AlgHS256 }
+func (k hs256) ID() string  { return k.id }
+
+func (k hs256) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+func (k hs256) Verify(data, sig []byte) bool {
+	expected, _ := k.Sign(data)
+	return hmac.Equal(expected, sig)
+}
+
+type eddsa struct {
+	id   string
+	priv ed25519.PrivateKey
+	pub  ed25519.PublicKey
+}
+
+// EdDSA gives a key signing with Ed25519, a nil private key makes it verify only
+func EdDSA(id string, pub ed25519.PublicKey, priv ed25519.PrivateKey) Key {
+	return eddsa{id: id, priv: priv, pub: pub}
+}
+
+func (k eddsa) Alg() string { return AlgEdDSA }
+func (k eddsa) ID() string  { return k.id }
+
+func (k eddsa) Sign(data []byte) ([]byte, error) {
+	if k.priv == nil {
+		return nil, fmt.Errorf("key %s can only verify", k.id)
+	}
+	return ed25519.Sign(k.priv, data), nil
+}
+
+func (k eddsa) Verify(data, sig []byte) bool {
+	return ed25519.Verify(k.pub, data, sig)
+}
+
+// PublicKey gives the Ed25519 public key of k, nil for symmetric keys
+func PublicKey(k Key) ed25519.PublicKey {
+	if e, ok := k.(eddsa); ok {
+		return e.pub
+	}
+	return nil
+}
+
+// Sign encodes claims into a compact token signed with key
+func Sign(key Key, claims Claims) (string, error) {
+	h, err := json.Marshal(header{Alg: key.Alg(), Typ: "JWT", Kid: key.ID()})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signing := enc.EncodeToString(h) + "." + enc.EncodeToString(c)
+	sig, err := key.Sign([]byte(signing))
+	if err != nil {
+		return "", err
+	}
+
+	return signing + "." + enc.EncodeToString(sig), nil
+}
+
+// Verifier checks tokens against a set of keys, picked by kid of the token
+type Verifier struct {
+	keys   map[string]Key
+	issuer string
+}
+
+func NewVerifier(issuer string, keys ...Key) *Verifier {
+	v := &Verifier{keys: map[string]Key{}, issuer: issuer}
+	for _, k := range keys {
+		v.keys[k.ID()] = k
+	}
+	return v
+}
+
+// Verify gives claims of token when its signature holds and it is valid at now
+func (v *Verifier) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodePart(parts[0], &h); err != nil {
+		return nil, err
+	}
+
+	key, ok := v.keys[h.Kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	// alg of the header is attacker controlled, it has to agree with the key, never choose the key
+	if h.Alg != key.Alg() {
+		return nil, ErrAlgMismatch
+	}
+
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !key.Verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalidSignature
+	}
+
+	var claims Claims
+	if err := decodePart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if claims.ExpiresAt == 0 || !now.Before(time.Unix(claims.ExpiresAt, 0).Add(Leeway)) {
+		return nil, ErrExpired
+	}
+	if claims.NotBefore != 0 && now.Add(Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrNotYetValid
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, ErrWrongIssuer
+	}
+
+	return &claims, nil
+}
+
+func decodePart(part string, dst any) error {
+	raw, err := enc.DecodeString(part)
+	if err != nil {
+		return ErrMalformed
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if err := decoder.Decode(dst); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
+
+// JWK is the public part of a key as published in a JWKS document
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKS gives public keys among keys, symmetric ones are never published
+func JWKS(keys ...Key) []JWK {
+	res := []JWK{}
+	for _, k := range keys {
+		pub := PublicKey(k)
+		if pub == nil {
+			continue
+		}
+		res = append(res, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   enc.EncodeToString(pub),
+			Kid: k.ID(),
+			Alg: AlgEdDSA,
+			Use: "sig",
+		})
+	}
+	return res
+}
+
+// Thumbprint gives a key id derived from the public key, so it changes together with the key
+func Thumbprint(pub ed25519.PublicKey) string {
+	// RFC 7638 members in lexicographic order
+	canonical := fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, enc.EncodeToString(pub))
+	sum := sha256.Sum256([]byte(canonical))
+	return enc.EncodeToString(sum[:])
+}
@@ -0,0 +1,163 @@
+package jwt_test
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"kbswitch/internal/pkg/jwt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const issuer = "https://switches.test"
+
+func edKey(t *testing.T, id string) jwt.Key {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwt.EdDSA(id, pub, priv)
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2024, 10, 16, 12, 0, 0, 0, time.UTC)
+	ed := edKey(t, "ed")
+	hs := jwt.HS256("hs", []byte("0123456789abcdef0123456789abcdef"))
+	valid := jwt.Claims{
+		Issuer:    issuer,
+		Subject:   "user:1",
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+		Role:      "moderator",
+	}
+
+	sign := func(key jwt.Key, claims jwt.Claims) string {
+		token, err := jwt.Sign(key, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	withClaims := func(change func(c *jwt.Claims)) jwt.Claims {
+		c := valid
+		change(&c)
+		return c
+	}
+
+	// swaps signature of one token onto another, as forging a payload would
+	tamper := func(token string) string {
+		parts := strings.Split(token, ".")
+		other := strings.Split(sign(ed, withClaims(func(c *jwt.Claims) { c.Role = "admin" })), ".")
+		return parts[0] + "." + other[1] + "." + parts[2]
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		verifier *jwt.Verifier
+		expected error
+	}{
+		{
+			name:     "eddsa",
+			token:    sign(ed, valid),
+			verifier: jwt.NewVerifier(issuer, ed, hs),
+		},
+		{
+			name:     "hs256",
+			token:    sign(hs, valid),
+			verifier: jwt.NewVerifier(issuer, ed, hs),
+		},
+		{
+			name:     "within leeway",
+			token:    sign(ed, withClaims(func(c *jwt.Claims) { c.ExpiresAt = now.Add(-jwt.Leeway / 2).Unix() })),
+			verifier: jwt.NewVerifier(issuer, ed),
+		},
+		{
+			name:     "expired",
+			token:    sign(ed, withClaims(func(c *jwt.Claims) { c.ExpiresAt = now.Add(-time.Minute).Unix() })),
+			verifier: jwt.NewVerifier(issuer, ed),
+			expected: jwt.ErrExpired,
+		},
+		{
+			name:     "not yet valid",
+			token:    sign(ed, withClaims(func(c *jwt.Claims) { c.NotBefore = now.Add(time.Hour).Unix() })),
+			verifier: jwt.NewVerifier(issuer, ed),
+			expected: jwt.ErrNotYetValid,
+		},
+		{
+			name:     "other issuer",
+			token:    sign(ed, withClaims(func(c *jwt.Claims) { c.Issuer = "https://elsewhere.test" })),
+			verifier: jwt.NewVerifier(issuer, ed),
+			expected: jwt.ErrWrongIssuer,
+		},
+		{
+			name:     "unknown key",
+			token:    sign(edKey(t, "other"), valid),
+			verifier: jwt.NewVerifier(issuer, ed),
+			expected: jwt.ErrUnknownKey,
+		},
+		{
+			name:     "same id other key",
+			token:    sign(edKey(t, "ed"), valid),
+			verifier: jwt.NewVerifier(issuer, ed),
+			expected: jwt.ErrInvalidSignature,
+		},
+		{
+			name:     "alg of key swapped",
+			token:    sign(jwt.HS256("ed", []byte("0123456789abcdef0123456789abcdef")), valid),
+			verifier: jwt.NewVerifier(issuer, ed),
+			expected: jwt.ErrAlgMismatch,
+		},
+		{
+			name:     "tampered payload",
+			token:    tamper(sign(ed, valid)),
+			verifier: jwt.NewVerifier(issuer, ed),
+			expected: jwt.ErrInvalidSignature,
+		},
+		{
+			name:     "malformed",
+			token:    "not.a-token",
+			verifier: jwt.NewVerifier(issuer, ed),
+			expected: jwt.ErrMalformed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := test.verifier.Verify(test.token, now)
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("expected error %v, got %v", test.expected, err)
+			}
+			if test.expected == nil && (claims.Subject != valid.Subject || claims.Role != valid.Role) {
+				t.Errorf("expected claims %+v, got %+v", valid, *claims)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	ed := edKey(t, "ed")
+	keys := jwt.JWKS(ed, jwt.HS256("hs", []byte("secret")))
+
+	if len(keys) != 1 {
+		t.Fatalf("expected only public key to be published, got %+v", keys)
+	}
+	if keys[0].Kid != "ed" || keys[0].Kty != "OKP" || keys[0].Crv != "Ed25519" || keys[0].Alg != jwt.AlgEdDSA {
+		t.Errorf("unexpected key %+v", keys[0])
+	}
+}
+
+// example of RFC 8037, appendix A.3
+func TestThumbprint(t *testing.T) {
+	pub := ed25519.PublicKey{
+		0xd7, 0x5a, 0x98, 0x01, 0x82, 0xb1, 0x0a, 0xb7, 0xd5, 0x4b, 0xfe, 0xd3, 0xc9, 0x64, 0x07, 0x3a,
+		0x0e, 0xe1, 0x72, 0xf3, 0xda, 0xa6, 0x23, 0x25, 0xaf, 0x02, 0x1a, 0x68, 0xf7, 0x07, 0x51, 0x1a,
+	}
+
+	if got := jwt.Thumbprint(pub); got != "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k" {
+		t.Errorf("unexpected thumbprint %s", got)
+	}
+}
@@ -0,0 +1,404 @@
+package listings
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/listings"
+	"kbswitch/internal/core/listings/models"
+	switchservice "kbswitch/internal/pkg/switches"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxPackSize      = 10000
+	maxVendorNameLen = 100
+	maxURLLen        = 2048
+)
+
+var currency = regexp.MustCompile(`^[A-Z]{3}$`)
+
+var (
+	ErrNoVendor          = common.NewError(common.ErrNotFound, "vendor with given id not found")
+	ErrNoListing         = common.NewError(common.ErrNotFound, "listing with given id not found")
+	ErrVendorExists      = common.NewError(common.ErrConflict, "vendor with given name exists already")
+	ErrListingExists     = common.NewError(common.ErrConflict, "vendor lists the switch at given url already")
+	ErrInvalidVendorName = common.NewError(common.ErrBadRequest, fmt.Sprintf("vendor name must have from 1 to %d characters", maxVendorNameLen))
+	ErrInvalidURL        = common.NewError(common.ErrBadRequest, "url must be an absolute http or https url")
+	ErrInvalidPrice      = common.NewError(common.ErrBadRequest, "price can't be negative")
+	ErrInvalidCurrency   = common.NewError(common.ErrBadRequest, "currency must be a three letter ISO 4217 code")
+	ErrInvalidPackSize   = common.NewError(common.ErrBadRequest, fmt.Sprintf("pack size must be from 1 to %d", MaxPackSize))
+	ErrVendorChanged     = common.NewError(common.ErrBadRequest, "vendor of a listing can't change, add another listing instead")
+)
+
+func New(logger logging.Logger, repo listings.Repo, catalog listings.Catalog, policy authz.Policy) listings.Service {
+	return service{
+		repo:    repo,
+		catalog: catalog,
+		policy:  policy,
+		logger:  logger,
+	}
+}
+
+type service struct {
+	repo    listings.Repo
+	catalog listings.Catalog
+	policy  authz.Policy
+	logger  logging.Logger
+}
+
+func validateURL(raw string) *common.AppError {
+	u, err := url.Parse(raw)
+	if err != nil || len(raw) > maxURLLen || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &ErrInvalidURL
+	}
+	return nil
+}
+
+func validateListing(l models.NewListing) *common.AppError {
+	if e := validateURL(l.URL); e != nil {
+		return e
+	}
+	if l.Price < 0 {
+		return &ErrInvalidPrice
+	}
+	if !currency.MatchString(l.Currency) {
+		return &ErrInvalidCurrency
+	}
+	if l.PackSize < 1 || l.PackSize > MaxPackSize {
+		return &ErrInvalidPackSize
+	}
+	return nil
+}
+
+// unitPrice gives price of a single switch out of a pack, in currency units rather than hundredths of them
+func unitPrice(price int64, packSize int) float64 {
+	return float64(price) / 100 / float64(packSize)
+}
+
+func (s service) switchID(ctx context.Context, brand, name string) (int, *common.AppError) {
+	id, err := s.catalog.GetID(ctx, brand, name)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return 0, common.Wrap(err)
+	}
+	if id == nil {
+		s.logger.LogError(fmt.Sprintf("switch %s %s not found", brand, name))
+		return 0, &switchservice.ErrNoSwitch
+	}
+	return *id, nil
+}
+
+func (s service) Vendors(ctx context.Context) ([]models.Vendor, *common.AppError) {
+	entities, err := s.repo.Vendors(ctx)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := make([]models.Vendor, len(entities))
+	for i, e := range entities {
+		res[i] = toVendor(e)
+	}
+	return res, nil
+}
+
+func (s service) AddVendor(ctx context.Context, vendor models.NewVendor) (*models.Vendor, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermListingsManage); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+
+	vendor.Name = strings.TrimSpace(vendor.Name)
+	if n := utf8.RuneCountInString(vendor.Name); n < 1 || n > maxVendorNameLen {
+		return nil, &ErrInvalidVendorName
+	}
+	if e := validateURL(vendor.URL); e != nil {
+		return nil, e
+	}
+
+	entity := models.VendorEntity{Name: vendor.Name, URL: vendor.URL, CreatedAt: time.Now()}
+	id, err := s.repo.InsertVendor(ctx, entity)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if id == nil {
+		s.logger.LogError(fmt.Sprintf("vendor %s exists already", vendor.Name))
+		return nil, &ErrVendorExists
+	}
+	entity.ID = *id
+
+	s.logger.LogTrace(fmt.Sprintf("vendor %d added", entity.ID))
+	res := toVendor(entity)
+	return &res, nil
+}
+
+func (s service) RemoveVendor(ctx context.Context, id int) *common.AppError {
+	if err := authz.Require(ctx, s.policy, authz.PermListingsManage); err != nil {
+		s.logger.LogError(err.Error())
+		return err
+	}
+
+	ok, err := s.repo.DeleteVendor(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if !ok {
+		s.logger.LogError(fmt.Sprintf("vendor %d not found", id))
+		return &ErrNoVendor
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("vendor %d removed", id))
+	return nil
+}
+
+func (s service) Listings(ctx context.Context, brand, name string) ([]models.Listing, *common.AppError) {
+	switchID, e := s.switchID(ctx, brand, name)
+	if e != nil {
+		return nil, e
+	}
+
+	entities, err := s.repo.Listings(ctx, switchID)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := make([]models.Listing, len(entities))
+	for i, e := range entities {
+		res[i] = toListing(e)
+	}
+	return res, nil
+}
+
+func (s service) AddListing(ctx context.Context, brand, name string, listing models.NewListing) (*models.Listing, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermListingsManage); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+	if e := validateListing(listing); e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+
+	switchID, e := s.switchID(ctx, brand, name)
+	if e != nil {
+		return nil, e
+	}
+	vendor, err := s.repo.GetVendor(ctx, listing.VendorID)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if vendor == nil {
+		s.logger.LogError(fmt.Sprintf("vendor %d not found", listing.VendorID))
+		return nil, &ErrNoVendor
+	}
+
+	now := time.Now()
+	entity := models.ListingEntity{
+		SwitchID:   switchID,
+		VendorID:   vendor.ID,
+		URL:        listing.URL,
+		Price:      listing.Price,
+		Currency:   listing.Currency,
+		PackSize:   listing.PackSize,
+		InStock:    listing.InStock,
+		CreatedAt:  now,
+		CheckedAt:  now,
+		VendorName: vendor.Name,
+	}
+
+	id, err := s.repo.InsertListing(ctx, entity)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if id == nil {
+		s.logger.LogError(fmt.Sprintf("vendor %d lists switch %d at %s already", vendor.ID, switchID, listing.URL))
+		return nil, &ErrListingExists
+	}
+	entity.ID = *id
+
+	s.logger.LogTrace(fmt.Sprintf("listing %d of switch %d added", entity.ID, switchID))
+	res := toListing(entity)
+	return &res, nil
+}
+
+// listing gives listing of id when it is about switch of brand and name
+func (s service) listing(ctx context.Context, brand, name string, id int) (*models.ListingEntity, *common.AppError) {
+	switchID, e := s.switchID(ctx, brand, name)
+	if e != nil {
+		return nil, e
+	}
+
+	entity, err := s.repo.GetListing(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil || entity.SwitchID != switchID {
+		s.logger.LogError(fmt.Sprintf("listing %d of switch %d not found", id, switchID))
+		return nil, &ErrNoListing
+	}
+	return entity, nil
+}
+
+func (s service) UpdateListing(ctx context.Context, brand, name string, id int, listing models.NewListing) (*models.Listing, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermListingsManage); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+	if e := validateListing(listing); e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+
+	entity, e := s.listing(ctx, brand, name, id)
+	if e != nil {
+		return nil, e
+	}
+	if listing.VendorID != 0 && listing.VendorID != entity.VendorID {
+		s.logger.LogError(fmt.Sprintf("listing %d is of vendor %d", id, entity.VendorID))
+		return nil, &ErrVendorChanged
+	}
+
+	entity.URL = listing.URL
+	entity.Price = listing.Price
+	entity.Currency = listing.Currency
+	entity.PackSize = listing.PackSize
+	entity.InStock = listing.InStock
+	entity.CheckedAt = time.Now()
+
+	if err := s.repo.UpdateListing(ctx, *entity); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("listing %d updated", id))
+	res := toListing(*entity)
+	return &res, nil
+}
+
+func (s service) RemoveListing(ctx context.Context, brand, name string, id int) *common.AppError {
+	if err := authz.Require(ctx, s.policy, authz.PermListingsManage); err != nil {
+		s.logger.LogError(err.Error())
+		return err
+	}
+	if _, e := s.listing(ctx, brand, name, id); e != nil {
+		return e
+	}
+
+	ok, err := s.repo.DeleteListing(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if !ok {
+		s.logger.LogError(fmt.Sprintf("listing %d deleted meanwhile", id))
+		return &ErrNoListing
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("listing %d removed", id))
+	return nil
+}
+
+func (s service) Prices(ctx context.Context, brand, name string, query models.PriceQuery) (*models.PriceReport, *common.AppError) {
+	if query.Currency != "" && !currency.MatchString(query.Currency) {
+		return nil, &ErrInvalidCurrency
+	}
+
+	switchID, e := s.switchID(ctx, brand, name)
+	if e != nil {
+		return nil, e
+	}
+
+	entities, err := s.repo.Listings(ctx, switchID)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	snapshots, err := s.repo.History(ctx, switchID, query.Since)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	history := map[int][]models.PricePoint{}
+	for _, snap := range snapshots {
+		history[snap.ListingID] = append(history[snap.ListingID], models.PricePoint{
+			At:        snap.At,
+			Price:     snap.Price,
+			Currency:  snap.Currency,
+			PackSize:  snap.PackSize,
+			UnitPrice: unitPrice(snap.Price, snap.PackSize),
+			InStock:   snap.InStock,
+		})
+	}
+
+	res := models.PriceReport{
+		Best:     []models.Listing{},
+		Listings: []models.ListingHistory{},
+	}
+	best := map[string]models.Listing{}
+	for _, e := range entities {
+		if query.Currency != "" && e.Currency != query.Currency {
+			continue
+		}
+
+		listing := toListing(e)
+		points := history[e.ID]
+		if points == nil {
+			points = []models.PricePoint{}
+		}
+		res.Listings = append(res.Listings, models.ListingHistory{Listing: listing, History: points})
+
+		if !listing.InStock {
+			continue
+		}
+		if b, ok := best[listing.Currency]; !ok || listing.UnitPrice < b.UnitPrice {
+			best[listing.Currency] = listing
+		}
+	}
+
+	for _, listing := range best {
+		res.Best = append(res.Best, listing)
+	}
+	sort.Slice(res.Best, func(i, j int) bool { return res.Best[i].Currency < res.Best[j].Currency })
+
+	s.logger.LogTrace(fmt.Sprintf("prices of %d listings of switch %d read", len(res.Listings), switchID))
+	return &res, nil
+}
+
+func toVendor(e models.VendorEntity) models.Vendor {
+	return models.Vendor{
+		ID:   e.ID,
+		Name: e.Name,
+		URL:  e.URL,
+	}
+}
+
+func toListing(e models.ListingEntity) models.Listing {
+	return models.Listing{
+		ID:         e.ID,
+		VendorID:   e.VendorID,
+		VendorName: e.VendorName,
+		URL:        e.URL,
+		Price:      e.Price,
+		Currency:   e.Currency,
+		PackSize:   e.PackSize,
+		InStock:    e.InStock,
+		UnitPrice:  unitPrice(e.Price, e.PackSize),
+		CreatedAt:  e.CreatedAt,
+		CheckedAt:  e.CheckedAt,
+	}
+}
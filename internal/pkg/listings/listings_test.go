@@ -0,0 +1,533 @@
+package listings_test
+
+import (
+	"context"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	corelistings "kbswitch/internal/core/listings"
+	"kbswitch/internal/core/listings/models"
+	authzpolicy "kbswitch/internal/pkg/authz"
+	"kbswitch/internal/pkg/listings"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type fakeLogger struct{}
+
+func (fakeLogger) LogError(msg string) {}
+func (fakeLogger) LogInfo(msg string)  {}
+func (fakeLogger) LogTrace(msg string) {}
+
+type memRepo struct {
+	vendors   []*models.VendorEntity
+	listings  []*models.ListingEntity
+	snapshots []models.SnapshotEntity
+}
+
+func (m *memRepo) Vendors(ctx context.Context) ([]models.VendorEntity, error) {
+	res := []models.VendorEntity{}
+	for _, e := range m.vendors {
+		if e != nil {
+			res = append(res, *e)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+func (m *memRepo) GetVendor(ctx context.Context, id int) (*models.VendorEntity, error) {
+	if id < 1 || id > len(m.vendors) || m.vendors[id-1] == nil {
+		return nil, nil
+	}
+	res := *m.vendors[id-1]
+	return &res, nil
+}
+
+func (m *memRepo) InsertVendor(ctx context.Context, e models.VendorEntity) (*int, error) {
+	for _, v := range m.vendors {
+		if v != nil && v.Name == e.Name {
+			return nil, nil
+		}
+	}
+	e.ID = len(m.vendors) + 1
+	m.vendors = append(m.vendors, &e)
+	return &e.ID, nil
+}
+
+func (m *memRepo) DeleteVendor(ctx context.Context, id int) (bool, error) {
+	if id < 1 || id > len(m.vendors) || m.vendors[id-1] == nil {
+		return false, nil
+	}
+	m.vendors[id-1] = nil
+	for i, l := range m.listings {
+		if l != nil && l.VendorID == id {
+			m.listings[i] = nil
+		}
+	}
+	return true, nil
+}
+
+func (m *memRepo) Listings(ctx context.Context, switchID int) ([]models.ListingEntity, error) {
+	res := []models.ListingEntity{}
+	for _, e := range m.listings {
+		if e != nil && e.SwitchID == switchID {
+			res = append(res, *e)
+		}
+	}
+	return res, nil
+}
+
+func (m *memRepo) GetListing(ctx context.Context, id int) (*models.ListingEntity, error) {
+	if id < 1 || id > len(m.listings) || m.listings[id-1] == nil {
+		return nil, nil
+	}
+	res := *m.listings[id-1]
+	return &res, nil
+}
+
+func (m *memRepo) snapshot(e models.ListingEntity) {
+	m.snapshots = append(m.snapshots, models.SnapshotEntity{
+		ListingID: e.ID, Price: e.Price, Currency: e.Currency, PackSize: e.PackSize, InStock: e.InStock, At: e.CheckedAt,
+	})
+}
+
+func (m *memRepo) InsertListing(ctx context.Context, e models.ListingEntity) (*int, error) {
+	for _, l := range m.listings {
+		if l != nil && l.SwitchID == e.SwitchID && l.VendorID == e.VendorID && l.URL == e.URL {
+			return nil, nil
+		}
+	}
+	e.ID = len(m.listings) + 1
+	m.listings = append(m.listings, &e)
+	m.snapshot(e)
+	return &e.ID, nil
+}
+
+func (m *memRepo) UpdateListing(ctx context.Context, e models.ListingEntity) error {
+	old := m.listings[e.ID-1]
+	if old.Price != e.Price || old.Currency != e.Currency || old.PackSize != e.PackSize || old.InStock != e.InStock {
+		m.snapshot(e)
+	}
+	m.listings[e.ID-1] = &e
+	return nil
+}
+
+func (m *memRepo) DeleteListing(ctx context.Context, id int) (bool, error) {
+	ok := m.listings[id-1] != nil
+	m.listings[id-1] = nil
+	return ok, nil
+}
+
+func (m *memRepo) History(ctx context.Context, switchID int, since time.Time) ([]models.SnapshotEntity, error) {
+	last := map[int]models.SnapshotEntity{}
+	recent := []models.SnapshotEntity{}
+	for _, s := range m.snapshots {
+		if l := m.listings[s.ListingID-1]; l == nil || l.SwitchID != switchID {
+			continue
+		}
+		if s.At.Before(since) {
+			last[s.ListingID] = s
+		} else {
+			recent = append(recent, s)
+		}
+	}
+
+	res := []models.SnapshotEntity{}
+	for _, s := range last {
+		res = append(res, s)
+	}
+	res = append(res, recent...)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].At.Before(res[j].At) })
+	return res, nil
+}
+
+// fakeCatalog knows Gateron Ink Black as 1 and Cherry MX Red as 2
+type fakeCatalog struct{}
+
+func (fakeCatalog) GetID(ctx context.Context, brand, name string) (*int, error) {
+	var id int
+	switch {
+	case brand == "Gateron" && name == "Ink Black":
+		id = 1
+	case brand == "Cherry" && name == "MX Red":
+		id = 2
+	default:
+		return nil, nil
+	}
+	return &id, nil
+}
+
+var (
+	contributor = authz.Principal{Subject: "user:1", Name: "jane@example.com", Role: authz.RoleContributor}
+	moderator   = authz.Principal{Subject: "user:2", Name: "mod@example.com", Role: authz.RoleModerator}
+)
+
+func as(p authz.Principal) context.Context {
+	return authz.WithPrincipal(context.Background(), p)
+}
+
+// newService gives service with vendors Divinikey as 1 and KBDfans as 2
+func newService() (corelistings.Service, *memRepo) {
+	repo := &memRepo{}
+	service := listings.New(fakeLogger{}, repo, fakeCatalog{}, authzpolicy.NewRolePolicy())
+	service.AddVendor(as(moderator), models.NewVendor{Name: "Divinikey", URL: "https://divinikey.com"})
+	service.AddVendor(as(moderator), models.NewVendor{Name: "KBDfans", URL: "https://kbdfans.com"})
+	return service, repo
+}
+
+func TestAddVendor(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		vendor   models.NewVendor
+		expected struct {
+			vendor models.Vendor
+			err    error
+		}
+	}{
+		{
+			name:   "trimmed",
+			ctx:    as(moderator),
+			vendor: models.NewVendor{Name: " NovelKeys ", URL: "https://novelkeys.com"},
+			expected: struct {
+				vendor models.Vendor
+				err    error
+			}{vendor: models.Vendor{ID: 3, Name: "NovelKeys", URL: "https://novelkeys.com"}},
+		},
+		{
+			name:   "contributor",
+			ctx:    as(contributor),
+			vendor: models.NewVendor{Name: "NovelKeys", URL: "https://novelkeys.com"},
+			expected: struct {
+				vendor models.Vendor
+				err    error
+			}{err: common.ErrForbidden},
+		},
+		{
+			name:   "anonymous",
+			ctx:    context.Background(),
+			vendor: models.NewVendor{Name: "NovelKeys", URL: "https://novelkeys.com"},
+			expected: struct {
+				vendor models.Vendor
+				err    error
+			}{err: common.ErrForbidden},
+		},
+		{
+			name:   "existing name",
+			ctx:    as(moderator),
+			vendor: models.NewVendor{Name: "KBDfans", URL: "https://kbdfans.com"},
+			expected: struct {
+				vendor models.Vendor
+				err    error
+			}{err: common.ErrConflict},
+		},
+		{
+			name:   "no name",
+			ctx:    as(moderator),
+			vendor: models.NewVendor{Name: "  ", URL: "https://novelkeys.com"},
+			expected: struct {
+				vendor models.Vendor
+				err    error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:   "relative url",
+			ctx:    as(moderator),
+			vendor: models.NewVendor{Name: "NovelKeys", URL: "novelkeys.com"},
+			expected: struct {
+				vendor models.Vendor
+				err    error
+			}{err: common.ErrBadRequest},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service, _ := newService()
+			res, err := service.AddVendor(test.ctx, test.vendor)
+			if test.expected.err != nil {
+				if err == nil || err.Errtype != test.expected.err {
+					t.Fatalf("expected error of type %v, got %v", test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if !reflect.DeepEqual(*res, test.expected.vendor) {
+				t.Errorf("expected vendor %+v, got %+v", test.expected.vendor, *res)
+			}
+		})
+	}
+}
+
+func TestAddListing(t *testing.T) {
+	valid := models.NewListing{VendorID: 1, URL: "https://divinikey.com/ink-black", Price: 650, Currency: "USD", PackSize: 10, InStock: true}
+	with := func(change func(l *models.NewListing)) models.NewListing {
+		l := valid
+		change(&l)
+		return l
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		brand    string
+		listing  models.NewListing
+		expected struct {
+			unitPrice float64
+			err       error
+		}
+	}{
+		{
+			name:    "pack",
+			ctx:     as(moderator),
+			brand:   "Gateron",
+			listing: valid,
+			expected: struct {
+				unitPrice float64
+				err       error
+			}{unitPrice: 0.65},
+		},
+		{
+			name:    "contributor",
+			ctx:     as(contributor),
+			brand:   "Gateron",
+			listing: valid,
+			expected: struct {
+				unitPrice float64
+				err       error
+			}{err: common.ErrForbidden},
+		},
+		{
+			name:    "unknown switch",
+			ctx:     as(moderator),
+			brand:   "Kailh",
+			listing: valid,
+			expected: struct {
+				unitPrice float64
+				err       error
+			}{err: common.ErrNotFound},
+		},
+		{
+			name:    "unknown vendor",
+			ctx:     as(moderator),
+			brand:   "Gateron",
+			listing: with(func(l *models.NewListing) { l.VendorID = 3 }),
+			expected: struct {
+				unitPrice float64
+				err       error
+			}{err: common.ErrNotFound},
+		},
+		{
+			name:    "negative price",
+			ctx:     as(moderator),
+			brand:   "Gateron",
+			listing: with(func(l *models.NewListing) { l.Price = -1 }),
+			expected: struct {
+				unitPrice float64
+				err       error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:    "invalid currency",
+			ctx:     as(moderator),
+			brand:   "Gateron",
+			listing: with(func(l *models.NewListing) { l.Currency = "$" }),
+			expected: struct {
+				unitPrice float64
+				err       error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:    "empty pack",
+			ctx:     as(moderator),
+			brand:   "Gateron",
+			listing: with(func(l *models.NewListing) { l.PackSize = 0 }),
+			expected: struct {
+				unitPrice float64
+				err       error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:    "not a web page",
+			ctx:     as(moderator),
+			brand:   "Gateron",
+			listing: with(func(l *models.NewListing) { l.URL = "ftp://divinikey.com/ink-black" }),
+			expected: struct {
+				unitPrice float64
+				err       error
+			}{err: common.ErrBadRequest},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service, repo := newService()
+			res, err := service.AddListing(test.ctx, test.brand, "Ink Black", test.listing)
+			if test.expected.err != nil {
+				if err == nil || err.Errtype != test.expected.err {
+					t.Fatalf("expected error of type %v, got %v", test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if res.UnitPrice != test.expected.unitPrice {
+				t.Errorf("expected unit price %v, got %v", test.expected.unitPrice, res.UnitPrice)
+			}
+			if res.VendorName != "Divinikey" {
+				t.Errorf("expected vendor Divinikey, got %s", res.VendorName)
+			}
+			if len(repo.snapshots) != 1 {
+				t.Errorf("expected first snapshot to be recorded, got %d", len(repo.snapshots))
+			}
+		})
+	}
+}
+
+func TestListingConflicts(t *testing.T) {
+	service, _ := newService()
+	listing := models.NewListing{VendorID: 1, URL: "https://divinikey.com/ink-black", Price: 650, Currency: "USD", PackSize: 10}
+	added, _ := service.AddListing(as(moderator), "Gateron", "Ink Black", listing)
+
+	if _, err := service.AddListing(as(moderator), "Gateron", "Ink Black", listing); err == nil || err.Errtype != common.ErrConflict {
+		t.Errorf("expected the same listing to conflict, got %v", err)
+	}
+	if _, err := service.AddListing(as(moderator), "Cherry", "MX Red", listing); err != nil {
+		t.Errorf("expected the same page to list another switch, got %v", err)
+	}
+
+	listing.VendorID = 2
+	if _, err := service.UpdateListing(as(moderator), "Gateron", "Ink Black", added.ID, listing); err == nil || err.Errtype != common.ErrBadRequest {
+		t.Errorf("expected vendor change to be refused, got %v", err)
+	}
+	if _, err := service.UpdateListing(as(moderator), "Cherry", "MX Red", added.ID, listing); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected listing of another switch not to be found, got %v", err)
+	}
+	if err := service.RemoveListing(as(moderator), "Cherry", "MX Red", added.ID); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected listing of another switch not to be removed, got %v", err)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	service, repo := newService()
+	listing := models.NewListing{VendorID: 1, URL: "https://divinikey.com/ink-black", Price: 650, Currency: "USD", PackSize: 10, InStock: true}
+	added, _ := service.AddListing(as(moderator), "Gateron", "Ink Black", listing)
+
+	// checked again without changes
+	res, err := service.UpdateListing(as(moderator), "Gateron", "Ink Black", added.ID, listing)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !res.CheckedAt.After(added.CheckedAt) && !res.CheckedAt.Equal(added.CheckedAt) {
+		t.Errorf("expected listing to be checked again")
+	}
+	if len(repo.snapshots) != 1 {
+		t.Errorf("expected no snapshot for unchanged listing, got %d", len(repo.snapshots))
+	}
+
+	listing.VendorID = 0
+	listing.InStock = false
+	service.UpdateListing(as(moderator), "Gateron", "Ink Black", added.ID, listing)
+	listing.Price = 550
+	service.UpdateListing(as(moderator), "Gateron", "Ink Black", added.ID, listing)
+
+	var stock []bool
+	var prices []int64
+	for _, s := range repo.snapshots {
+		stock = append(stock, s.InStock)
+		prices = append(prices, s.Price)
+	}
+	if !reflect.DeepEqual(stock, []bool{true, false, false}) || !reflect.DeepEqual(prices, []int64{650, 650, 550}) {
+		t.Errorf("expected snapshot per change, got stock %v and prices %v", stock, prices)
+	}
+}
+
+func TestPrices(t *testing.T) {
+	service, repo := newService()
+	ctx := as(moderator)
+	// 0.65 and 0.60 per switch in USD, the cheaper one is out of stock
+	divinikey, _ := service.AddListing(ctx, "Gateron", "Ink Black", models.NewListing{VendorID: 1, URL: "https://divinikey.com/ink-black", Price: 650, Currency: "USD", PackSize: 10, InStock: true})
+	service.AddListing(ctx, "Gateron", "Ink Black", models.NewListing{VendorID: 2, URL: "https://kbdfans.com/ink-black-35", Price: 2100, Currency: "USD", PackSize: 35})
+	// 0.70 and 0.55 per switch in EUR
+	service.AddListing(ctx, "Gateron", "Ink Black", models.NewListing{VendorID: 2, URL: "https://kbdfans.com/eu/ink-black", Price: 700, Currency: "EUR", PackSize: 10, InStock: true})
+	service.AddListing(ctx, "Gateron", "Ink Black", models.NewListing{VendorID: 2, URL: "https://kbdfans.com/eu/ink-black-90", Price: 4950, Currency: "EUR", PackSize: 90, InStock: true})
+	service.AddListing(ctx, "Cherry", "MX Red", models.NewListing{VendorID: 1, URL: "https://divinikey.com/mx-red", Price: 100, Currency: "USD", PackSize: 10, InStock: true})
+
+	// the first price of divinikey is from a year ago and it changed twice since
+	now := time.Now()
+	repo.snapshots[0].At = now.AddDate(-1, 0, 0)
+	repo.snapshots = append(repo.snapshots,
+		models.SnapshotEntity{ListingID: divinikey.ID, Price: 700, Currency: "USD", PackSize: 10, InStock: true, At: now.AddDate(0, 0, -200)},
+		models.SnapshotEntity{ListingID: divinikey.ID, Price: 650, Currency: "USD", PackSize: 10, InStock: true, At: now.AddDate(0, 0, -30)},
+	)
+
+	res, err := service.Prices(context.Background(), "Gateron", "Ink Black", models.PriceQuery{Since: now.AddDate(0, 0, -90)})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var best []string
+	for _, l := range res.Best {
+		best = append(best, l.URL)
+	}
+	if !reflect.DeepEqual(best, []string{"https://kbdfans.com/eu/ink-black-90", "https://divinikey.com/ink-black"}) {
+		t.Errorf("expected cheapest in stock listing per currency, got %v", best)
+	}
+	if len(res.Listings) != 4 {
+		t.Fatalf("expected 4 listings of the switch, got %d", len(res.Listings))
+	}
+
+	var history []int64
+	for _, p := range res.Listings[0].History {
+		history = append(history, p.Price)
+	}
+	if !reflect.DeepEqual(history, []int64{700, 650}) {
+		t.Errorf("expected price at start of the period and changes within it, got %v", history)
+	}
+
+	res, err = service.Prices(context.Background(), "Gateron", "Ink Black", models.PriceQuery{Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(res.Best) != 1 || len(res.Listings) != 2 || res.Best[0].UnitPrice != 0.55 {
+		t.Errorf("expected only EUR listings, got best %+v of %d listings", res.Best, len(res.Listings))
+	}
+
+	if _, err := service.Prices(context.Background(), "Gateron", "Ink Black", models.PriceQuery{Currency: "eur"}); err == nil || err.Errtype != common.ErrBadRequest {
+		t.Errorf("expected invalid currency to be refused, got %v", err)
+	}
+	if _, err := service.Prices(context.Background(), "Kailh", "Box White", models.PriceQuery{}); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected unknown switch not to be found, got %v", err)
+	}
+}
+
+func TestRemoveVendor(t *testing.T) {
+	service, _ := newService()
+	service.AddListing(as(moderator), "Gateron", "Ink Black", models.NewListing{VendorID: 1, URL: "https://divinikey.com/ink-black", Price: 650, Currency: "USD", PackSize: 10})
+
+	if err := service.RemoveVendor(as(contributor), 1); err == nil || err.Errtype != common.ErrForbidden {
+		t.Errorf("expected contributor to be refused, got %v", err)
+	}
+	if err := service.RemoveVendor(as(moderator), 1); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := service.RemoveVendor(as(moderator), 1); err == nil || err.Errtype != common.ErrNotFound {
+		t.Errorf("expected removed vendor not to be found, got %v", err)
+	}
+
+	vendors, _ := service.Vendors(context.Background())
+	if len(vendors) != 1 || vendors[0].Name != "KBDfans" {
+		t.Errorf("expected only KBDfans to be left, got %+v", vendors)
+	}
+	res, _ := service.Listings(context.Background(), "Gateron", "Ink Black")
+	if len(res) != 0 {
+		t.Errorf("expected listings of removed vendor to be gone, got %d", len(res))
+	}
+}
@@ -0,0 +1,274 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/common/database"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/listings"
+	"kbswitch/internal/core/listings/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func New(logger logging.Logger, pool database.DBPool) listings.Repo {
+	return repo{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+type repo struct {
+	logger logging.Logger
+	pool   database.DBPool
+}
+
+const vendorColumns = `id, name, url, created_at`
+
+func scanVendor(row pgx.Row, e *models.VendorEntity) error {
+	return row.Scan(&e.ID, &e.Name, &e.URL, &e.CreatedAt)
+}
+
+// column order must match scanListing, vendor name comes from the joined vendor
+const listingColumns = `l.id, l.switch_id, l.vendor_id, l.url, l.price, l.currency, l.pack_size, l.in_stock,
+	l.created_at, l.checked_at, v.name`
+
+const listingsFrom = ` FROM public.switch_listings l JOIN public.vendors v ON v.id = l.vendor_id`
+
+func scanListing(row pgx.Row, e *models.ListingEntity) error {
+	return row.Scan(&e.ID, &e.SwitchID, &e.VendorID, &e.URL, &e.Price, &e.Currency, &e.PackSize, &e.InStock,
+		&e.CreatedAt, &e.CheckedAt, &e.VendorName)
+}
+
+func snapshot(ctx context.Context, tx pgx.Tx, e models.ListingEntity) error {
+	query := `INSERT INTO public.listing_snapshots (listing_id, price, currency, pack_size, in_stock, at)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := tx.Exec(ctx, query, e.ID, e.Price, e.Currency, e.PackSize, e.InStock, e.CheckedAt)
+	return err
+}
+
+// Vendors implements listings.Repo.
+func (r repo) Vendors(ctx context.Context) ([]models.VendorEntity, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+vendorColumns+` FROM public.vendors ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.VendorEntity{}
+	for rows.Next() {
+		var e models.VendorEntity
+		if err := scanVendor(rows, &e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d vendors read", len(res)))
+
+	return res, nil
+}
+
+// GetVendor implements listings.Repo.
+func (r repo) GetVendor(ctx context.Context, id int) (*models.VendorEntity, error) {
+	var e models.VendorEntity
+	err := scanVendor(r.pool.QueryRow(ctx, `SELECT `+vendorColumns+` FROM public.vendors WHERE id = $1`, id), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// InsertVendor implements listings.Repo.
+func (r repo) InsertVendor(ctx context.Context, e models.VendorEntity) (*int, error) {
+	query := `INSERT INTO public.vendors (name, url, created_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (name) DO NOTHING
+	RETURNING id`
+
+	var id int
+	err := r.pool.QueryRow(ctx, query, e.Name, e.URL, e.CreatedAt).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		r.logger.LogTrace(fmt.Sprintf("vendor %s exists already", e.Name))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("vendor %d inserted", id))
+
+	return &id, nil
+}
+
+// DeleteVendor implements listings.Repo.
+func (r repo) DeleteVendor(ctx context.Context, id int) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM public.vendors WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d vendors deleted", tag.RowsAffected()))
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// Listings implements listings.Repo.
+func (r repo) Listings(ctx context.Context, switchID int) ([]models.ListingEntity, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+listingColumns+listingsFrom+` WHERE l.switch_id = $1 ORDER BY v.name, l.id`, switchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.ListingEntity{}
+	for rows.Next() {
+		var e models.ListingEntity
+		if err := scanListing(rows, &e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d listings of switch %d read", len(res), switchID))
+
+	return res, nil
+}
+
+// GetListing implements listings.Repo.
+func (r repo) GetListing(ctx context.Context, id int) (*models.ListingEntity, error) {
+	var e models.ListingEntity
+	err := scanListing(r.pool.QueryRow(ctx, `SELECT `+listingColumns+listingsFrom+` WHERE l.id = $1`, id), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// InsertListing implements listings.Repo.
+func (r repo) InsertListing(ctx context.Context, e models.ListingEntity) (*int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO public.switch_listings (switch_id, vendor_id, url, price, currency, pack_size, in_stock, created_at, checked_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (switch_id, vendor_id, url) DO NOTHING
+	RETURNING id`
+
+	err = tx.QueryRow(ctx, query, e.SwitchID, e.VendorID, e.URL, e.Price, e.Currency, e.PackSize, e.InStock,
+		e.CreatedAt, e.CheckedAt).Scan(&e.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		r.logger.LogTrace(fmt.Sprintf("vendor %d lists switch %d at %s already", e.VendorID, e.SwitchID, e.URL))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := snapshot(ctx, tx, e); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("listing %d inserted", e.ID))
+
+	return &e.ID, nil
+}
+
+// UpdateListing implements listings.Repo.
+func (r repo) UpdateListing(ctx context.Context, e models.ListingEntity) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// previous offer is locked, so concurrent updates agree on whether it changed
+	var old models.ListingEntity
+	err = scanListing(tx.QueryRow(ctx, `SELECT `+listingColumns+listingsFrom+` WHERE l.id = $1 FOR UPDATE OF l`, e.ID), &old)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE public.switch_listings
+	SET url = $2, price = $3, currency = $4, pack_size = $5, in_stock = $6, checked_at = $7
+	WHERE id = $1`
+
+	_, err = tx.Exec(ctx, query, e.ID, e.URL, e.Price, e.Currency, e.PackSize, e.InStock, e.CheckedAt)
+	if err != nil {
+		return err
+	}
+
+	if old.Price != e.Price || old.Currency != e.Currency || old.PackSize != e.PackSize || old.InStock != e.InStock {
+		if err := snapshot(ctx, tx, e); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("listing %d updated", e.ID))
+
+	return nil
+}
+
+// DeleteListing implements listings.Repo.
+func (r repo) DeleteListing(ctx context.Context, id int) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM public.switch_listings WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d listings deleted", tag.RowsAffected()))
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// History implements listings.Repo.
+func (r repo) History(ctx context.Context, switchID int, since time.Time) ([]models.SnapshotEntity, error) {
+	// the last snapshot before since tells what was offered when the period started
+	query := `SELECT s.listing_id, s.price, s.currency, s.pack_size, s.in_stock, s.at
+	FROM public.listing_snapshots s
+	JOIN public.switch_listings l ON l.id = s.listing_id
+	WHERE l.switch_id = $1 AND (s.at >= $2 OR s.at = (
+		SELECT max(p.at) FROM public.listing_snapshots p WHERE p.listing_id = s.listing_id AND p.at < $2
+	))
+	ORDER BY s.at, s.listing_id`
+
+	rows, err := r.pool.Query(ctx, query, switchID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.SnapshotEntity{}
+	for rows.Next() {
+		var e models.SnapshotEntity
+		if err := rows.Scan(&e.ListingID, &e.Price, &e.Currency, &e.PackSize, &e.InStock, &e.At); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d snapshots of switch %d read", len(res), switchID))
+
+	return res, nil
+}
@@ -0,0 +1,62 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// NewLogSender gives a sender which only logs messages, meant for development
+func NewLogSender(logger logging.Logger) mail.Sender {
+	return logSender{logger: logger}
+}
+
+type logSender struct {
+	logger logging.Logger
+}
+
+// Send implements mail.Sender.
+func (s logSender) Send(ctx context.Context, msg mail.Message) error {
+	s.logger.LogInfo(fmt.Sprintf("mail to %s, subject %q\n%s", msg.To, msg.Subject, msg.Body))
+	return nil
+}
+
+// NewFileSender gives a sender which writes every message as an .eml file into dir
+func NewFileSender(dir string) (mail.Sender, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fileSender{dir: dir}, nil
+}
+
+type fileSender struct {
+	dir string
+	seq atomic.Int64
+}
+
+// Send implements mail.Sender.
+func (s *fileSender) Send(ctx context.Context, msg mail.Message) error {
+	now := time.Now()
+	name := fmt.Sprintf("%s-%d.eml", now.UTC().Format("20060102T150405.000000000"), s.seq.Add(1))
+
+	return os.WriteFile(filepath.Join(s.dir, name), []byte(Format(msg, now)), 0o644)
+}
+
+// Format renders msg as a plain text RFC 5322 message
+func Format(msg mail.Message, date time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return b.String()
+}
@@ -0,0 +1,185 @@
+package mail_test
+
+import (
+	"context"
+	"encoding/base64"
+	"kbswitch/internal/core/mail"
+	sender "kbswitch/internal/pkg/mail"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	s, err := sender.NewFileSender(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, to := range []string{"a@example.com", "b@example.com"} {
+		if err := s.Send(context.Background(), mail.Message{To: to, Subject: "Hi", Body: "line one\nline two"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 mail files, got %v", files)
+	}
+
+	content, _ := os.ReadFile(files[0])
+	for _, part := range []string{"To: a@example.com\r\n", "Subject: Hi\r\n", "\r\n\r\nline one\r\nline two"} {
+		if !strings.Contains(string(content), part) {
+			t.Errorf("mail file is missing %q\ngot %q", part, content)
+		}
+	}
+}
+
+// fakeSMTP accepts a single session on a local port and records what it was told
+type fakeSMTP struct {
+	addr     string
+	auth     bool
+	received chan smtpSession
+}
+
+type smtpSession struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+func newFakeSMTP(t *testing.T, auth bool) *fakeSMTP {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakeSMTP{addr: ln.Addr().String(), auth: auth, received: make(chan smtpSession, 1)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		f.serve(textproto.NewConn(conn))
+	}()
+
+	return f
+}
+
+func (f *fakeSMTP) serve(c *textproto.Conn) {
+	var s smtpSession
+	c.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			if f.auth {
+				c.PrintfLine("250-fake\r\n250 AUTH PLAIN")
+			} else {
+				c.PrintfLine("250 fake")
+			}
+		case "AUTH":
+			s.auth = arg
+			c.PrintfLine("235 authenticated")
+		case "MAIL":
+			s.from = arg
+			c.PrintfLine("250 ok")
+		case "RCPT":
+			s.to = append(s.to, arg)
+			c.PrintfLine("250 ok")
+		case "DATA":
+			c.PrintfLine("354 go on")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.data = string(data)
+			c.PrintfLine("250 queued")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			f.received <- s
+			return
+		default:
+			c.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPSender(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     bool
+		expected struct {
+			auth string
+		}
+	}{
+		{
+			name: "anonymous",
+		},
+		{
+			name: "authenticated",
+			auth: true,
+			expected: struct {
+				auth string
+			}{auth: "PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00kbswitch\x00secret"))},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newFakeSMTP(t, test.auth)
+			config := sender.SMTPConfig{Addr: server.addr, From: "alerts@kbswitch.example", Timeout: 5 * time.Second}
+			if test.auth {
+				config.Username, config.Password = "kbswitch", "secret"
+			}
+
+			err := sender.NewSMTPSender(config).Send(context.Background(), mail.Message{To: "jane@example.com", Subject: "Back in stock", Body: "line one\n.line two"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := <-server.received
+			if s.auth != test.expected.auth {
+				t.Errorf("expected auth %q, got %q", test.expected.auth, s.auth)
+			}
+			if s.from != "FROM:<alerts@kbswitch.example>" || !reflect.DeepEqual(s.to, []string{"TO:<jane@example.com>"}) {
+				t.Errorf("expected envelope from alerts to jane, got %s %v", s.from, s.to)
+			}
+			for _, part := range []string{"From: alerts@kbswitch.example\n", "To: jane@example.com\n", "Subject: Back in stock\n", "\n\nline one\n.line two"} {
+				if !strings.Contains(s.data, part) {
+					t.Errorf("message is missing %q\ngot %q", part, s.data)
+				}
+			}
+		})
+	}
+}
+
+func TestSMTPSenderRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	err = sender.NewSMTPSender(sender.SMTPConfig{Addr: addr, From: "alerts@kbswitch.example"}).Send(context.Background(), mail.Message{To: "jane@example.com"})
+	if err == nil {
+		t.Error("expected delivery to a closed port to fail")
+	}
+}
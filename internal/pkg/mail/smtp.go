@@ -0,0 +1,95 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"kbswitch/internal/core/mail"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPConfig tells where mail is handed over for delivery, Username and Password are only used when Username is set
+type SMTPConfig struct {
+	// Addr is host:port of the server
+	Addr     string
+	From     string
+	Username string
+	Password string
+	// Timeout bounds a whole delivery, contexts with an earlier deadline shorten it
+	Timeout time.Duration
+}
+
+// NewSMTPSender gives a sender handing messages over to an SMTP server, STARTTLS is used whenever the server offers it
+func NewSMTPSender(config SMTPConfig) mail.Sender {
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	return smtpSender{config: config}
+}
+
+type smtpSender struct {
+	config SMTPConfig
+}
+
+// Send implements mail.Sender.
+func (s smtpSender) Send(ctx context.Context, msg mail.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	host, _, err := net.SplitHostPort(s.config.Addr)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.config.Username != "" {
+		// plain auth refuses to send the password unencrypted to anything but localhost
+		if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(s.config.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "From: %s\r\n%s", s.config.From, Format(msg, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,107 @@
+package reviews
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/reviews"
+	"kbswitch/internal/core/switches"
+	"kbswitch/internal/core/switches/models"
+)
+
+// WithRatings wraps service so that switches it reads carry their rating. Ratings are an extra,
+// switches are given without them when ratings can't be read
+func WithRatings(service switches.Service, reviews reviews.Service) switches.Service {
+	return rated{
+		Service: service,
+		reviews: reviews,
+	}
+}
+
+type rated struct {
+	switches.Service
+	reviews reviews.Service
+}
+
+// rate sets rating of every switch of sws which has reviews, with a single query
+func (r rated) rate(ctx context.Context, sws ...*models.Switch) {
+	keys := make([]models.SwitchKey, len(sws))
+	for i, sw := range sws {
+		keys[i] = models.SwitchKey{Brand: sw.Brand, Name: sw.Name}
+	}
+
+	summaries, err := r.reviews.Summaries(ctx, keys)
+	if err != nil {
+		return
+	}
+	for i, sw := range sws {
+		if s, ok := summaries[keys[i]]; ok {
+			sw.Rating = &s
+		}
+	}
+}
+
+func (r rated) GetAll(ctx context.Context) ([]models.Switch, *common.AppError) {
+	res, err := r.Service.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sws := make([]*models.Switch, len(res))
+	for i := range res {
+		sws[i] = &res[i]
+	}
+	r.rate(ctx, sws...)
+	return res, nil
+}
+
+func (r rated) GetSingle(ctx context.Context, brand, name string) (*models.Switch, *common.AppError) {
+	res, err := r.Service.GetSingle(ctx, brand, name)
+	if err != nil || res == nil {
+		return res, err
+	}
+
+	r.rate(ctx, res)
+	return res, nil
+}
+
+func (r rated) GetSimilar(ctx context.Context, brand, name string, limit int, weights models.SimilarityWeights) ([]models.SimilarSwitch, *common.AppError) {
+	res, err := r.Service.GetSimilar(ctx, brand, name, limit, weights)
+	if err != nil {
+		return nil, err
+	}
+
+	sws := make([]*models.Switch, len(res))
+	for i := range res {
+		sws[i] = &res[i].Switch
+	}
+	r.rate(ctx, sws...)
+	return res, nil
+}
+
+func (r rated) Search(ctx context.Context, query string, filter models.SwitchFilter, limit int) ([]models.SearchResult, *common.AppError) {
+	res, err := r.Service.Search(ctx, query, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	sws := make([]*models.Switch, len(res))
+	for i := range res {
+		sws[i] = &res[i].Switch
+	}
+	r.rate(ctx, sws...)
+	return res, nil
+}
+
+func (r rated) Lookup(ctx context.Context, keys []models.SwitchKey) (*models.LookupResult, *common.AppError) {
+	res, err := r.Service.Lookup(ctx, keys)
+	if err != nil || res == nil {
+		return res, err
+	}
+
+	sws := make([]*models.Switch, len(res.Found))
+	for i := range res.Found {
+		sws[i] = &res.Found[i]
+	}
+	r.rate(ctx, sws...)
+	return res, nil
+}
@@ -0,0 +1,284 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/common/database"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/reviews"
+	"kbswitch/internal/core/reviews/models"
+	switchmodels "kbswitch/internal/core/switches/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func New(logger logging.Logger, pool database.DBPool) reviews.Repo {
+	return repo{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+type repo struct {
+	logger logging.Logger
+	pool   database.DBPool
+}
+
+// column order must match scanReview
+const reviewColumns = `id, switch_id, author, author_name, overall, smoothness, sound, feel, body, created_at, updated_at`
+
+func scanReview(row pgx.Row, e *models.ReviewEntity) error {
+	return row.Scan(&e.ID, &e.SwitchID, &e.Author, &e.AuthorName, &e.Scores.Overall, &e.Scores.Smoothness,
+		&e.Scores.Sound, &e.Scores.Feel, &e.Body, &e.CreatedAt, &e.UpdatedAt)
+}
+
+// column order must match scanRating
+const ratingColumns = `count, sum_overall, sum_smoothness, sum_sound, sum_feel, stars_1, stars_2, stars_3, stars_4, stars_5`
+
+func scanRating(row pgx.Row, e *models.RatingEntity, extra ...any) error {
+	dest := append(extra, &e.Count, &e.SumOverall, &e.SumSmoothness, &e.SumSound, &e.SumFeel,
+		&e.Histogram[0], &e.Histogram[1], &e.Histogram[2], &e.Histogram[3], &e.Histogram[4])
+	return row.Scan(dest...)
+}
+
+// sorts maps every models.Sort to its order clause, nothing else gets into the query
+var sorts = map[models.Sort]string{
+	models.SortNewest:  `created_at DESC, id DESC`,
+	models.SortOldest:  `created_at, id`,
+	models.SortHighest: `overall DESC, created_at DESC, id DESC`,
+	models.SortLowest:  `overall, created_at DESC, id DESC`,
+}
+
+// count adds scores to rating of switchID when sign is 1 and takes them out of it when sign is -1
+func count(ctx context.Context, tx pgx.Tx, switchID int, scores models.Scores, sign int) error {
+	var stars [models.MaxScore]int
+	stars[scores.Overall-1] = sign
+
+	query := `INSERT INTO public.switch_ratings AS r (switch_id, ` + ratingColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	ON CONFLICT (switch_id) DO UPDATE SET
+		count = r.count + EXCLUDED.count,
+		sum_overall = r.sum_overall + EXCLUDED.sum_overall,
+		sum_smoothness = r.sum_smoothness + EXCLUDED.sum_smoothness,
+		sum_sound = r.sum_sound + EXCLUDED.sum_sound,
+		sum_feel = r.sum_feel + EXCLUDED.sum_feel,
+		stars_1 = r.stars_1 + EXCLUDED.stars_1,
+		stars_2 = r.stars_2 + EXCLUDED.stars_2,
+		stars_3 = r.stars_3 + EXCLUDED.stars_3,
+		stars_4 = r.stars_4 + EXCLUDED.stars_4,
+		stars_5 = r.stars_5 + EXCLUDED.stars_5`
+
+	_, err := tx.Exec(ctx, query, switchID, sign, sign*scores.Overall, sign*scores.Smoothness, sign*scores.Sound,
+		sign*scores.Feel, stars[0], stars[1], stars[2], stars[3], stars[4])
+	return err
+}
+
+// Insert implements reviews.Repo.
+func (r repo) Insert(ctx context.Context, e models.ReviewEntity) (*int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO public.switch_reviews (switch_id, author, author_name, overall, smoothness, sound, feel, body, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (switch_id, author) DO NOTHING
+	RETURNING id`
+
+	var id int
+	err = tx.QueryRow(ctx, query, e.SwitchID, e.Author, e.AuthorName, e.Scores.Overall, e.Scores.Smoothness,
+		e.Scores.Sound, e.Scores.Feel, e.Body, e.CreatedAt, e.UpdatedAt).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		r.logger.LogTrace(fmt.Sprintf("%s reviewed switch %d already", e.Author, e.SwitchID))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := count(ctx, tx, e.SwitchID, e.Scores, 1); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("review %d inserted", id))
+
+	return &id, nil
+}
+
+// Get implements reviews.Repo.
+func (r repo) Get(ctx context.Context, id int) (*models.ReviewEntity, error) {
+	var e models.ReviewEntity
+	err := scanReview(r.pool.QueryRow(ctx, `SELECT `+reviewColumns+` FROM public.switch_reviews WHERE id = $1`, id), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// Update implements reviews.Repo.
+func (r repo) Update(ctx context.Context, e models.ReviewEntity) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// previous scores are locked, so concurrent updates can't take the same ones out of the rating twice
+	var old models.ReviewEntity
+	err = scanReview(tx.QueryRow(ctx, `SELECT `+reviewColumns+` FROM public.switch_reviews WHERE id = $1 FOR UPDATE`, e.ID), &old)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE public.switch_reviews
+	SET overall = $2, smoothness = $3, sound = $4, feel = $5, body = $6, updated_at = $7
+	WHERE id = $1`
+
+	_, err = tx.Exec(ctx, query, e.ID, e.Scores.Overall, e.Scores.Smoothness, e.Scores.Sound, e.Scores.Feel, e.Body, e.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := count(ctx, tx, old.SwitchID, old.Scores, -1); err != nil {
+		return err
+	}
+	if err := count(ctx, tx, old.SwitchID, e.Scores, 1); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("review %d updated", e.ID))
+
+	return nil
+}
+
+// Delete implements reviews.Repo.
+func (r repo) Delete(ctx context.Context, id int) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var old models.ReviewEntity
+	err = scanReview(tx.QueryRow(ctx, `DELETE FROM public.switch_reviews WHERE id = $1 RETURNING `+reviewColumns, id), &old)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := count(ctx, tx, old.SwitchID, old.Scores, -1); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("review %d deleted", id))
+
+	return true, nil
+}
+
+// List implements reviews.Repo.
+func (r repo) List(ctx context.Context, switchID int, page models.PageRequest) ([]models.ReviewEntity, int, error) {
+	order, ok := sorts[page.Sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown sort %s", page.Sort)
+	}
+
+	var total int
+	err := r.pool.QueryRow(ctx, `SELECT count(*) FROM public.switch_reviews WHERE switch_id = $1`, switchID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + reviewColumns + ` FROM public.switch_reviews
+	WHERE switch_id = $1
+	ORDER BY ` + order + `
+	LIMIT $2 OFFSET $3`
+
+	rows, err := r.pool.Query(ctx, query, switchID, page.PageSize, (page.Page-1)*page.PageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	res := []models.ReviewEntity{}
+	for rows.Next() {
+		var e models.ReviewEntity
+		if err := scanReview(rows, &e); err != nil {
+			return nil, 0, err
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d of %d reviews of switch %d listed", len(res), total, switchID))
+
+	return res, total, nil
+}
+
+// Rating implements reviews.Repo.
+func (r repo) Rating(ctx context.Context, switchID int) (*models.RatingEntity, error) {
+	query := `SELECT ` + ratingColumns + ` FROM public.switch_ratings WHERE switch_id = $1 AND count > 0`
+
+	e := models.RatingEntity{SwitchID: switchID}
+	err := scanRating(r.pool.QueryRow(ctx, query, switchID), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// Ratings implements reviews.Repo.
+func (r repo) Ratings(ctx context.Context, keys []switchmodels.SwitchKey) (map[switchmodels.SwitchKey]models.RatingEntity, error) {
+	brands := make([]string, len(keys))
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		brands[i] = k.Brand
+		names[i] = k.Name
+	}
+
+	query := `SELECT switches.manufacturer, switches.model, r.switch_id, ` + ratingColumns + `
+	FROM public.switch_ratings r
+	JOIN public.switches ON switches.id = r.switch_id
+	WHERE r.count > 0 AND (switches.manufacturer, switches.model) IN (
+		SELECT brand, name FROM unnest($1::text[], $2::text[]) AS w(brand, name)
+	)`
+
+	rows, err := r.pool.Query(ctx, query, brands, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := map[switchmodels.SwitchKey]models.RatingEntity{}
+	for rows.Next() {
+		var key switchmodels.SwitchKey
+		var e models.RatingEntity
+		if err := scanRating(rows, &e, &key.Brand, &key.Name, &e.SwitchID); err != nil {
+			return nil, err
+		}
+		res[key] = e
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,319 @@
+package reviews
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/contentfilter"
+	filtermodels "kbswitch/internal/core/contentfilter/models"
+	"kbswitch/internal/core/reviews"
+	"kbswitch/internal/core/reviews/models"
+	switchmodels "kbswitch/internal/core/switches/models"
+	filterservice "kbswitch/internal/pkg/contentfilter"
+	switchservice "kbswitch/internal/pkg/switches"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+	maxBodyLen      = 5000
+)
+
+var (
+	ErrNoReview        = common.NewError(common.ErrNotFound, "review with given id not found")
+	ErrAlreadyReviewed = common.NewError(common.ErrConflict, "switch was reviewed by you already, update that review instead")
+	ErrNotAuthor       = common.NewError(common.ErrForbidden, "review belongs to somebody else")
+	ErrInvalidScore    = common.NewError(common.ErrBadRequest, fmt.Sprintf("every score must be from %d to %d", models.MinScore, models.MaxScore))
+	ErrBodyTooLong     = common.NewError(common.ErrBadRequest, fmt.Sprintf("review can't be longer than %d characters", maxBodyLen))
+	ErrInvalidPage     = common.NewError(common.ErrBadRequest, "page must be a positive number")
+	ErrInvalidPageSize = common.NewError(common.ErrBadRequest, fmt.Sprintf("page size must be from 1 to %d", MaxPageSize))
+	ErrUnknownSort     = common.NewError(common.ErrBadRequest, "sort must be one of newest, oldest, highest or lowest")
+)
+
+// New gives the service, reviews are published right away so content filter holding one refuses it
+func New(logger logging.Logger, repo reviews.Repo, catalog reviews.Catalog, policy authz.Policy, filter contentfilter.Filter) reviews.Service {
+	return service{
+		repo:    repo,
+		catalog: catalog,
+		policy:  policy,
+		filter:  filter,
+		logger:  logger,
+	}
+}
+
+type service struct {
+	repo    reviews.Repo
+	catalog reviews.Catalog
+	policy  authz.Policy
+	filter  contentfilter.Filter
+	logger  logging.Logger
+}
+
+func validate(r models.NewReview) *common.AppError {
+	for _, score := range []int{r.Scores.Overall, r.Scores.Smoothness, r.Scores.Sound, r.Scores.Feel} {
+		if score < models.MinScore || score > models.MaxScore {
+			return &ErrInvalidScore
+		}
+	}
+	if utf8.RuneCountInString(r.Body) > maxBodyLen {
+		return &ErrBodyTooLong
+	}
+	return nil
+}
+
+func (s service) screen(ctx context.Context, body string) *common.AppError {
+	v := s.filter.Check(filtermodels.Content{Author: authz.FromContext(ctx).Subject, Text: body})
+	if e := filterservice.Enforce(v, false); e != nil {
+		s.logger.LogError(e.Error())
+		return e
+	}
+	return nil
+}
+
+func (s service) switchID(ctx context.Context, brand, name string) (int, *common.AppError) {
+	id, err := s.catalog.GetID(ctx, brand, name)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return 0, common.Wrap(err)
+	}
+	if id == nil {
+		s.logger.LogError(fmt.Sprintf("switch %s %s not found", brand, name))
+		return 0, &switchservice.ErrNoSwitch
+	}
+	return *id, nil
+}
+
+// review gives review of id when it is about switch of brand and name
+func (s service) review(ctx context.Context, brand, name string, id int) (*models.ReviewEntity, *common.AppError) {
+	switchID, e := s.switchID(ctx, brand, name)
+	if e != nil {
+		return nil, e
+	}
+
+	entity, err := s.repo.Get(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil || entity.SwitchID != switchID {
+		s.logger.LogError(fmt.Sprintf("review %d of switch %d not found", id, switchID))
+		return nil, &ErrNoReview
+	}
+	return entity, nil
+}
+
+func (s service) Create(ctx context.Context, brand, name string, review models.NewReview) (*models.Review, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermReviewsWrite); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+	if e := validate(review); e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+
+	switchID, e := s.switchID(ctx, brand, name)
+	if e != nil {
+		return nil, e
+	}
+	if e := s.screen(ctx, review.Body); e != nil {
+		return nil, e
+	}
+
+	principal := authz.FromContext(ctx)
+	now := time.Now()
+	entity := models.ReviewEntity{
+		SwitchID:   switchID,
+		Author:     principal.Subject,
+		AuthorName: principal.Name,
+		Scores:     review.Scores,
+		Body:       strings.TrimSpace(review.Body),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	id, err := s.repo.Insert(ctx, entity)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if id == nil {
+		s.logger.LogError(fmt.Sprintf("%s reviewed switch %d already", principal.Subject, switchID))
+		return nil, &ErrAlreadyReviewed
+	}
+	entity.ID = *id
+
+	s.logger.LogTrace(fmt.Sprintf("review %d of switch %d added", entity.ID, switchID))
+	res := toReview(entity)
+	return &res, nil
+}
+
+func (s service) Update(ctx context.Context, brand, name string, id int, review models.NewReview) (*models.Review, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermReviewsWrite); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+	if e := validate(review); e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+
+	entity, e := s.review(ctx, brand, name, id)
+	if e != nil {
+		return nil, e
+	}
+	if entity.Author != authz.FromContext(ctx).Subject {
+		s.logger.LogError(fmt.Sprintf("review %d belongs to %s", id, entity.Author))
+		return nil, &ErrNotAuthor
+	}
+	if e := s.screen(ctx, review.Body); e != nil {
+		return nil, e
+	}
+
+	entity.Scores = review.Scores
+	entity.Body = strings.TrimSpace(review.Body)
+	entity.UpdatedAt = time.Now()
+
+	if err := s.repo.Update(ctx, *entity); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("review %d updated", id))
+	res := toReview(*entity)
+	return &res, nil
+}
+
+func (s service) Delete(ctx context.Context, brand, name string, id int) *common.AppError {
+	if err := authz.Require(ctx, s.policy, authz.PermReviewsWrite); err != nil {
+		s.logger.LogError(err.Error())
+		return err
+	}
+
+	entity, e := s.review(ctx, brand, name, id)
+	if e != nil {
+		return e
+	}
+	principal := authz.FromContext(ctx)
+	if entity.Author != principal.Subject && !s.policy.Allows(principal, authz.PermReviewsModerate) {
+		s.logger.LogError(fmt.Sprintf("review %d belongs to %s", id, entity.Author))
+		return &ErrNotAuthor
+	}
+
+	ok, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if !ok {
+		s.logger.LogError(fmt.Sprintf("review %d deleted meanwhile", id))
+		return &ErrNoReview
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("review %d deleted", id))
+	return nil
+}
+
+func (s service) List(ctx context.Context, brand, name string, page models.PageRequest) (*reviews.ReviewPage, *common.AppError) {
+	if page.Page == 0 {
+		page.Page = 1
+	}
+	if page.PageSize == 0 {
+		page.PageSize = DefaultPageSize
+	}
+	if page.Sort == "" {
+		page.Sort = models.SortNewest
+	}
+	if page.Page < 0 {
+		return nil, &ErrInvalidPage
+	}
+	if page.PageSize < 0 || page.PageSize > MaxPageSize {
+		return nil, &ErrInvalidPageSize
+	}
+	if !slices.Contains(models.Sorts, page.Sort) {
+		return nil, &ErrUnknownSort
+	}
+
+	switchID, e := s.switchID(ctx, brand, name)
+	if e != nil {
+		return nil, e
+	}
+
+	entities, total, err := s.repo.List(ctx, switchID, page)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	rating, err := s.repo.Rating(ctx, switchID)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := reviews.ReviewPage{
+		Reviews:  make([]models.Review, len(entities)),
+		Total:    total,
+		Page:     page.Page,
+		PageSize: page.PageSize,
+	}
+	for i, e := range entities {
+		res.Reviews[i] = toReview(e)
+	}
+	if rating != nil {
+		res.Summary = toSummary(*rating)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("page %d of %d reviews of switch %d", page.Page, total, switchID))
+	return &res, nil
+}
+
+func (s service) Summaries(ctx context.Context, keys []switchmodels.SwitchKey) (map[switchmodels.SwitchKey]switchmodels.RatingSummary, *common.AppError) {
+	res := map[switchmodels.SwitchKey]switchmodels.RatingSummary{}
+	if len(keys) == 0 {
+		return res, nil
+	}
+
+	ratings, err := s.repo.Ratings(ctx, keys)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	for key, r := range ratings {
+		res[key] = toSummary(r)
+	}
+	return res, nil
+}
+
+func toReview(e models.ReviewEntity) models.Review {
+	return models.Review{
+		ID:        e.ID,
+		Author:    e.AuthorName,
+		Scores:    e.Scores,
+		Body:      e.Body,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+	}
+}
+
+func toSummary(r models.RatingEntity) switchmodels.RatingSummary {
+	if r.Count == 0 {
+		return switchmodels.RatingSummary{}
+	}
+
+	n := float64(r.Count)
+	return switchmodels.RatingSummary{
+		Count:      r.Count,
+		Mean:       float64(r.SumOverall) / n,
+		Histogram:  r.Histogram,
+		Smoothness: float64(r.SumSmoothness) / n,
+		Sound:      float64(r.SumSound) / n,
+		Feel:       float64(r.SumFeel) / n,
+	}
+}
@@ -0,0 +1,495 @@
+package reviews_test
+
+import (
+	"context"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	corereviews "kbswitch/internal/core/reviews"
+	"kbswitch/internal/core/reviews/models"
+	"kbswitch/internal/core/switches"
+	switchmodels "kbswitch/internal/core/switches/models"
+	authzpolicy "kbswitch/internal/pkg/authz"
+	"kbswitch/internal/pkg/contentfilter"
+	"kbswitch/internal/pkg/reviews"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type fakeLogger struct{}
+
+func (fakeLogger) LogError(msg string) {}
+func (fakeLogger) LogInfo(msg string)  {}
+func (fakeLogger) LogTrace(msg string) {}
+
+// memRepo keeps reviews of switches of fakeCatalog, ratings are counted from them when asked for
+type memRepo struct {
+	reviews map[int]models.ReviewEntity
+	nextID  int
+}
+
+func (m *memRepo) Insert(ctx context.Context, e models.ReviewEntity) (*int, error) {
+	for _, r := range m.reviews {
+		if r.SwitchID == e.SwitchID && r.Author == e.Author {
+			return nil, nil
+		}
+	}
+	m.nextID++
+	e.ID = m.nextID
+	m.reviews[e.ID] = e
+	return &e.ID, nil
+}
+
+func (m *memRepo) Get(ctx context.Context, id int) (*models.ReviewEntity, error) {
+	r, ok := m.reviews[id]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func (m *memRepo) Update(ctx context.Context, e models.ReviewEntity) error {
+	m.reviews[e.ID] = e
+	return nil
+}
+
+func (m *memRepo) Delete(ctx context.Context, id int) (bool, error) {
+	_, ok := m.reviews[id]
+	delete(m.reviews, id)
+	return ok, nil
+}
+
+func (m *memRepo) List(ctx context.Context, switchID int, page models.PageRequest) ([]models.ReviewEntity, int, error) {
+	res := []models.ReviewEntity{}
+	for _, r := range m.reviews {
+		if r.SwitchID == switchID {
+			res = append(res, r)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		switch page.Sort {
+		case models.SortOldest:
+			return res[i].ID < res[j].ID
+		case models.SortHighest:
+			return res[i].Scores.Overall > res[j].Scores.Overall
+		case models.SortLowest:
+			return res[i].Scores.Overall < res[j].Scores.Overall
+		}
+		return res[i].ID > res[j].ID
+	})
+
+	total := len(res)
+	from := min((page.Page-1)*page.PageSize, total)
+	to := min(from+page.PageSize, total)
+	return res[from:to], total, nil
+}
+
+func (m *memRepo) Rating(ctx context.Context, switchID int) (*models.RatingEntity, error) {
+	res := models.RatingEntity{SwitchID: switchID}
+	for _, r := range m.reviews {
+		if r.SwitchID != switchID {
+			continue
+		}
+		res.Count++
+		res.SumOverall += r.Scores.Overall
+		res.SumSmoothness += r.Scores.Smoothness
+		res.SumSound += r.Scores.Sound
+		res.SumFeel += r.Scores.Feel
+		res.Histogram[r.Scores.Overall-1]++
+	}
+	if res.Count == 0 {
+		return nil, nil
+	}
+	return &res, nil
+}
+
+func (m *memRepo) Ratings(ctx context.Context, keys []switchmodels.SwitchKey) (map[switchmodels.SwitchKey]models.RatingEntity, error) {
+	res := map[switchmodels.SwitchKey]models.RatingEntity{}
+	for _, key := range keys {
+		id, _ := catalog.GetID(ctx, key.Brand, key.Name)
+		if id == nil {
+			continue
+		}
+		if r, _ := m.Rating(ctx, *id); r != nil {
+			res[key] = *r
+		}
+	}
+	return res, nil
+}
+
+type fakeCatalog map[switchmodels.SwitchKey]int
+
+func (f fakeCatalog) GetID(ctx context.Context, brand, name string) (*int, error) {
+	id, ok := f[switchmodels.SwitchKey{Brand: brand, Name: name}]
+	if !ok {
+		return nil, nil
+	}
+	return &id, nil
+}
+
+var (
+	catalog = fakeCatalog{
+		{Brand: "Gateron", Name: "Ink Black"}: 1,
+		{Brand: "Cherry", Name: "MX Red"}:     2,
+	}
+
+	viewer      = authz.Principal{Subject: "user:4", Name: "guest@example.com", Role: authz.RoleViewer}
+	contributor = authz.Principal{Subject: "user:1", Name: "jane@example.com", Role: authz.RoleContributor}
+	other       = authz.Principal{Subject: "user:2", Name: "john@example.com", Role: authz.RoleContributor}
+	moderator   = authz.Principal{Subject: "user:3", Name: "mod@example.com", Role: authz.RoleModerator}
+)
+
+func as(p authz.Principal) context.Context {
+	return authz.WithPrincipal(context.Background(), p)
+}
+
+func review(overall, smoothness, sound, feel int) models.NewReview {
+	return models.NewReview{Scores: models.Scores{Overall: overall, Smoothness: smoothness, Sound: sound, Feel: feel}, Body: "  smooth and deep  "}
+}
+
+func newService() corereviews.Service {
+	config := contentfilter.DefaultConfig()
+	config.Blocked = []string{"trash"}
+	return reviews.New(fakeLogger{}, &memRepo{reviews: map[int]models.ReviewEntity{}}, catalog, authzpolicy.NewRolePolicy(), contentfilter.New(config))
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal authz.Principal
+		brand     string
+		review    models.NewReview
+		expected  error
+	}{
+		{name: "valid", principal: contributor, brand: "Gateron", review: review(5, 4, 3, 5)},
+		{name: "viewer", principal: viewer, brand: "Gateron", review: review(5, 4, 3, 5), expected: common.ErrForbidden},
+		{name: "score too low", principal: contributor, brand: "Gateron", review: review(0, 4, 3, 5), expected: common.ErrBadRequest},
+		{name: "sub score too high", principal: contributor, brand: "Gateron", review: review(5, 4, 6, 5), expected: common.ErrBadRequest},
+		{name: "unknown switch", principal: contributor, brand: "Kailh", review: review(5, 4, 3, 5), expected: common.ErrNotFound},
+		{name: "blocked word", principal: contributor, brand: "Gateron", review: models.NewReview{Scores: review(1, 1, 1, 1).Scores, Body: "tr4sh"}, expected: common.ErrBadRequest},
+		{name: "too many links", principal: contributor, brand: "Gateron", review: models.NewReview{Scores: review(5, 4, 3, 5).Scores, Body: "www.a.example www.b.example www.c.example"}, expected: common.ErrBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := newService()
+
+			res, err := service.Create(as(test.principal), test.brand, "Ink Black", test.review)
+			if test.expected != nil {
+				if err == nil || err.Errtype != test.expected {
+					t.Fatalf("expected error of type %v, got %v", test.expected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if res.Author != contributor.Name || res.Body != "smooth and deep" || res.Scores != test.review.Scores {
+				t.Errorf("unexpected review %+v", *res)
+			}
+		})
+	}
+}
+
+func TestOneReviewPerSwitch(t *testing.T) {
+	service := newService()
+
+	if _, err := service.Create(as(contributor), "Gateron", "Ink Black", review(5, 5, 5, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Create(as(contributor), "Gateron", "Ink Black", review(1, 1, 1, 1)); err == nil || err.Errtype != common.ErrConflict {
+		t.Errorf("expected second review to conflict, got %v", err)
+	}
+	if _, err := service.Create(as(contributor), "Cherry", "MX Red", review(1, 1, 1, 1)); err != nil {
+		t.Errorf("expected review of another switch to be added, got %v", err)
+	}
+}
+
+func TestChanges(t *testing.T) {
+	tests := []struct {
+		name string
+		// change is done to review of contributor
+		change   func(service corereviews.Service, id int) *common.AppError
+		expected struct {
+			err   error
+			count int
+		}
+	}{
+		{
+			name: "author updates",
+			change: func(service corereviews.Service, id int) *common.AppError {
+				_, err := service.Update(as(contributor), "Gateron", "Ink Black", id, review(1, 1, 1, 1))
+				return err
+			},
+			expected: struct {
+				err   error
+				count int
+			}{count: 1},
+		},
+		{
+			name: "other updates",
+			change: func(service corereviews.Service, id int) *common.AppError {
+				_, err := service.Update(as(other), "Gateron", "Ink Black", id, review(1, 1, 1, 1))
+				return err
+			},
+			expected: struct {
+				err   error
+				count int
+			}{err: common.ErrForbidden, count: 1},
+		},
+		{
+			name: "moderator updates",
+			change: func(service corereviews.Service, id int) *common.AppError {
+				_, err := service.Update(as(moderator), "Gateron", "Ink Black", id, review(1, 1, 1, 1))
+				return err
+			},
+			expected: struct {
+				err   error
+				count int
+			}{err: common.ErrForbidden, count: 1},
+		},
+		{
+			name: "update through another switch",
+			change: func(service corereviews.Service, id int) *common.AppError {
+				_, err := service.Update(as(contributor), "Cherry", "MX Red", id, review(1, 1, 1, 1))
+				return err
+			},
+			expected: struct {
+				err   error
+				count int
+			}{err: common.ErrNotFound, count: 1},
+		},
+		{
+			name: "author deletes",
+			change: func(service corereviews.Service, id int) *common.AppError {
+				return service.Delete(as(contributor), "Gateron", "Ink Black", id)
+			},
+			expected: struct {
+				err   error
+				count int
+			}{count: 0},
+		},
+		{
+			name: "other deletes",
+			change: func(service corereviews.Service, id int) *common.AppError {
+				return service.Delete(as(other), "Gateron", "Ink Black", id)
+			},
+			expected: struct {
+				err   error
+				count int
+			}{err: common.ErrForbidden, count: 1},
+		},
+		{
+			name: "moderator deletes",
+			change: func(service corereviews.Service, id int) *common.AppError {
+				return service.Delete(as(moderator), "Gateron", "Ink Black", id)
+			},
+			expected: struct {
+				err   error
+				count int
+			}{count: 0},
+		},
+		{
+			name: "unknown review",
+			change: func(service corereviews.Service, id int) *common.AppError {
+				return service.Delete(as(contributor), "Gateron", "Ink Black", id+1)
+			},
+			expected: struct {
+				err   error
+				count int
+			}{err: common.ErrNotFound, count: 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := newService()
+
+			r, err := service.Create(as(contributor), "Gateron", "Ink Black", review(5, 5, 5, 5))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = test.change(service, r.ID)
+			if test.expected.err != nil {
+				if err == nil || err.Errtype != test.expected.err {
+					t.Errorf("expected error of type %v, got %v", test.expected.err, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+
+			page, err := service.List(context.Background(), "Gateron", "Ink Black", models.PageRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != test.expected.count || page.Summary.Count != test.expected.count {
+				t.Errorf("expected %d reviews, got %d with rating of %d", test.expected.count, page.Total, page.Summary.Count)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	service := newService()
+	for i, p := range []authz.Principal{contributor, other, moderator} {
+		if _, err := service.Create(as(p), "Gateron", "Ink Black", review(i+3, 2, 4, i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		page     models.PageRequest
+		expected struct {
+			overall []int
+			page    int
+			size    int
+			err     error
+		}
+	}{
+		{
+			name: "defaults",
+			expected: struct {
+				overall []int
+				page    int
+				size    int
+				err     error
+			}{overall: []int{5, 4, 3}, page: 1, size: reviews.DefaultPageSize},
+		},
+		{
+			name: "second page",
+			page: models.PageRequest{Page: 2, PageSize: 2, Sort: models.SortOldest},
+			expected: struct {
+				overall []int
+				page    int
+				size    int
+				err     error
+			}{overall: []int{5}, page: 2, size: 2},
+		},
+		{
+			name: "past last page",
+			page: models.PageRequest{Page: 3, PageSize: 2},
+			expected: struct {
+				overall []int
+				page    int
+				size    int
+				err     error
+			}{overall: []int{}, page: 3, size: 2},
+		},
+		{
+			name: "lowest",
+			page: models.PageRequest{Sort: models.SortLowest},
+			expected: struct {
+				overall []int
+				page    int
+				size    int
+				err     error
+			}{overall: []int{3, 4, 5}, page: 1, size: reviews.DefaultPageSize},
+		},
+		{
+			name: "negative page",
+			page: models.PageRequest{Page: -1},
+			expected: struct {
+				overall []int
+				page    int
+				size    int
+				err     error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name: "page too large",
+			page: models.PageRequest{PageSize: reviews.MaxPageSize + 1},
+			expected: struct {
+				overall []int
+				page    int
+				size    int
+				err     error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name: "unknown sort",
+			page: models.PageRequest{Sort: "popular"},
+			expected: struct {
+				overall []int
+				page    int
+				size    int
+				err     error
+			}{err: common.ErrBadRequest},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := service.List(context.Background(), "Gateron", "Ink Black", test.page)
+			if test.expected.err != nil {
+				if err == nil || err.Errtype != test.expected.err {
+					t.Fatalf("expected error of type %v, got %v", test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			overall := make([]int, len(res.Reviews))
+			for i, r := range res.Reviews {
+				overall[i] = r.Scores.Overall
+			}
+			if !reflect.DeepEqual(overall, test.expected.overall) || res.Page != test.expected.page || res.PageSize != test.expected.size || res.Total != 3 {
+				t.Errorf("unexpected page %v of %d, %d per page, %d in total", overall, res.Page, res.PageSize, res.Total)
+			}
+		})
+	}
+}
+
+func TestRatingSummary(t *testing.T) {
+	service := newService()
+	service.Create(as(contributor), "Gateron", "Ink Black", review(5, 4, 3, 5))
+	service.Create(as(other), "Gateron", "Ink Black", review(5, 2, 4, 4))
+	r, _ := service.Create(as(moderator), "Gateron", "Ink Black", review(1, 1, 1, 1))
+	service.Update(as(moderator), "Gateron", "Ink Black", r.ID, review(2, 3, 2, 3))
+
+	page, err := service.List(context.Background(), "Gateron", "Ink Black", models.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := switchmodels.RatingSummary{Count: 3, Mean: 4, Histogram: [5]int{0, 1, 0, 0, 2}, Smoothness: 3, Sound: 3, Feel: 4}
+	if page.Summary != expected {
+		t.Errorf("expected rating %+v, got %+v", expected, page.Summary)
+	}
+
+	empty, err := service.List(context.Background(), "Cherry", "MX Red", models.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Summary != (switchmodels.RatingSummary{}) || len(empty.Reviews) != 0 {
+		t.Errorf("expected switch without reviews to have empty rating, got %+v", *empty)
+	}
+}
+
+type fakeSwitches struct {
+	switches.Service
+}
+
+func (fakeSwitches) GetAll(ctx context.Context) ([]switchmodels.Switch, *common.AppError) {
+	return []switchmodels.Switch{{Brand: "Gateron", Name: "Ink Black"}, {Brand: "Cherry", Name: "MX Red"}}, nil
+}
+
+func TestWithRatings(t *testing.T) {
+	service := newService()
+	service.Create(as(contributor), "Gateron", "Ink Black", review(4, 4, 4, 4))
+
+	res, err := reviews.WithRatings(fakeSwitches{}, service).GetAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res[0].Rating == nil || res[0].Rating.Count != 1 || res[0].Rating.Mean != 4 {
+		t.Errorf("expected reviewed switch to be rated, got %+v", res[0].Rating)
+	}
+	if res[1].Rating != nil {
+		t.Errorf("expected switch without reviews to have no rating, got %+v", res[1].Rating)
+	}
+}
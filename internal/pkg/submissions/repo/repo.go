@@ -0,0 +1,192 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/common/database"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/submissions"
+	"kbswitch/internal/core/submissions/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func New(logger logging.Logger, pool database.DBPool) submissions.Repo {
+	return repo{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+type repo struct {
+	logger logging.Logger
+	pool   database.DBPool
+}
+
+// column order must match scanSubmission
+const submissionColumns = `id, submitter, submitter_name, body, status, switch_id, created_at, updated_at`
+
+func scanSubmission(row pgx.Row, e *models.SubmissionEntity) error {
+	var body []byte
+	var status string
+	if err := row.Scan(&e.ID, &e.Submitter, &e.SubmitterName, &body, &status, &e.SwitchID, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		return err
+	}
+	e.Status = models.Status(status)
+
+	return json.Unmarshal(body, &e.Body)
+}
+
+// Insert implements submissions.Repo.
+func (r repo) Insert(ctx context.Context, e models.SubmissionEntity) (*int, error) {
+	body, err := json.Marshal(e.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO public.switch_submissions (submitter, submitter_name, body, status, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id`
+
+	var id int
+	err = r.pool.QueryRow(ctx, query, e.Submitter, e.SubmitterName, body, string(e.Status), e.CreatedAt, e.UpdatedAt).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("submission %d inserted", id))
+
+	return &id, nil
+}
+
+// Get implements submissions.Repo.
+func (r repo) Get(ctx context.Context, id int) (*models.SubmissionEntity, error) {
+	query := `SELECT ` + submissionColumns + ` FROM public.switch_submissions WHERE id = $1`
+
+	var e models.SubmissionEntity
+	err := scanSubmission(r.pool.QueryRow(ctx, query, id), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// List implements submissions.Repo.
+func (r repo) List(ctx context.Context, filter models.ListFilter) ([]models.SubmissionEntity, error) {
+	query := `SELECT ` + submissionColumns + ` FROM public.switch_submissions
+	WHERE ($1 = '' OR submitter = $1) AND ($2 = '' OR status = $2)
+	ORDER BY created_at, id`
+
+	rows, err := r.pool.Query(ctx, query, filter.Submitter, string(filter.Status))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.SubmissionEntity{}
+	for rows.Next() {
+		var e models.SubmissionEntity
+		if err := scanSubmission(rows, &e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d submissions listed", len(res)))
+
+	return res, nil
+}
+
+// Transition implements submissions.Repo.
+func (r repo) Transition(ctx context.Context, e models.SubmissionEntity, from models.Status, comment models.CommentEntity) (bool, error) {
+	body, err := json.Marshal(e.Body)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE public.switch_submissions
+	SET body = $2, status = $3, switch_id = $4, updated_at = $5
+	WHERE id = $1 AND status = $6`
+
+	tag, err := tx.Exec(ctx, query, e.ID, body, string(e.Status), e.SwitchID, e.UpdatedAt, string(from))
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	query = `INSERT INTO public.switch_submission_comments (submission_id, author, author_name, action, comment, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err = tx.Exec(ctx, query, comment.SubmissionID, comment.Author, comment.AuthorName, string(comment.Action), comment.Comment, comment.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("submission %d moved from %s to %s", e.ID, from, e.Status))
+
+	return true, nil
+}
+
+// Claim implements submissions.Repo.
+func (r repo) Claim(ctx context.Context, id int, from, to models.Status) (bool, error) {
+	query := `UPDATE public.switch_submissions SET status = $2 WHERE id = $1 AND status = $3`
+
+	tag, err := r.pool.Exec(ctx, query, id, string(to), string(from))
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	r.logger.LogTrace(fmt.Sprintf("submission %d claimed from %s to %s", id, from, to))
+
+	return true, nil
+}
+
+// Comments implements submissions.Repo.
+func (r repo) Comments(ctx context.Context, submissionID int) ([]models.CommentEntity, error) {
+	query := `SELECT submission_id, author, author_name, action, comment, created_at
+	FROM public.switch_submission_comments
+	WHERE submission_id = $1
+	ORDER BY created_at, id`
+
+	rows, err := r.pool.Query(ctx, query, submissionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.CommentEntity{}
+	for rows.Next() {
+		var e models.CommentEntity
+		var action string
+		if err := rows.Scan(&e.SubmissionID, &e.Author, &e.AuthorName, &action, &e.Comment, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Action = models.Action(action)
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
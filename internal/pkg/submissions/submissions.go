@@ -0,0 +1,367 @@
+package submissions
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/contentfilter"
+	filtermodels "kbswitch/internal/core/contentfilter/models"
+	"kbswitch/internal/core/submissions"
+	"kbswitch/internal/core/submissions/models"
+	"kbswitch/internal/core/switches"
+	switchmodels "kbswitch/internal/core/switches/models"
+	filterservice "kbswitch/internal/pkg/contentfilter"
+	switchservice "kbswitch/internal/pkg/switches"
+	"slices"
+	"strings"
+	"time"
+)
+
+const maxCommentLen = 2000
+
+var (
+	ErrNoSubmission     = common.NewError(common.ErrNotFound, "submission with given id not found")
+	ErrAnonymous        = common.NewError(common.ErrUnauthorized, "submitting switches requires being logged in")
+	ErrNotSubmitter     = common.NewError(common.ErrForbidden, "submission belongs to somebody else")
+	ErrNotPending       = common.NewError(common.ErrConflict, "submission is not pending anymore")
+	ErrNoChangesAsked   = common.NewError(common.ErrConflict, "submission can be revised only when changes were requested")
+	ErrUnknownAction    = common.NewError(common.ErrBadRequest, "action must be one of approve, reject or request_changes")
+	ErrMissingComment   = common.NewError(common.ErrBadRequest, "comment is required when rejecting or requesting changes")
+	ErrCommentTooLong   = common.NewError(common.ErrBadRequest, fmt.Sprintf("comment can't be longer than %d characters", maxCommentLen))
+	ErrSwitchExists     = common.NewError(common.ErrBadRequest, "switch with given brand and name is in the catalog already")
+	ErrConcurrentReview = common.NewError(common.ErrConflict, "submission was changed by somebody else meanwhile")
+)
+
+// New gives the service, catalog changes of approvals go through switches which should check the policy on its own.
+// every submission waits for a moderator, so only content filter rejecting text refuses it
+func New(logger logging.Logger, repo submissions.Repo, switches switches.Service, policy authz.Policy, filter contentfilter.Filter) submissions.Service {
+	return service{
+		repo:     repo,
+		switches: switches,
+		policy:   policy,
+		filter:   filter,
+		logger:   logger,
+	}
+}
+
+type service struct {
+	repo     submissions.Repo
+	switches switches.Service
+	policy   authz.Policy
+	filter   contentfilter.Filter
+	logger   logging.Logger
+}
+
+func validateComment(comment string) *common.AppError {
+	if len(comment) > maxCommentLen {
+		return &ErrCommentTooLong
+	}
+	return nil
+}
+
+func (s service) Submit(ctx context.Context, body switchmodels.SwitchRequestBody) (*models.Submission, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermSwitchesPropose); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+	principal := authz.FromContext(ctx)
+	if principal.Anonymous() {
+		s.logger.LogError("anonymous submission")
+		return nil, &ErrAnonymous
+	}
+
+	if e := s.checkBody(ctx, body); e != nil {
+		return nil, e
+	}
+	if e := s.screen(principal, body.Description); e != nil {
+		return nil, e
+	}
+
+	now := time.Now()
+	entity := models.SubmissionEntity{
+		Submitter:     principal.Subject,
+		SubmitterName: principal.Name,
+		Body:          body,
+		Status:        models.StatusPending,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	id, err := s.repo.Insert(ctx, entity)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	entity.ID = *id
+
+	s.logger.LogTrace(fmt.Sprintf("submission %d of %s added", entity.ID, entity.Submitter))
+	res := toSubmission(entity, nil)
+	return &res, nil
+}
+
+func (s service) screen(principal authz.Principal, text string) *common.AppError {
+	v := s.filter.Check(filtermodels.Content{Author: principal.Subject, Text: text})
+	if e := filterservice.Enforce(v, true); e != nil {
+		s.logger.LogError(e.Error())
+		return e
+	}
+	return nil
+}
+
+// checkBody validates body the same way switches are and refuses switches the catalog has already
+func (s service) checkBody(ctx context.Context, body switchmodels.SwitchRequestBody) *common.AppError {
+	if reasons := switchservice.Validate(body); reasons != nil {
+		s.logger.LogError(fmt.Sprintf("invalid submission %v", reasons))
+		e := common.NewError(common.ErrBadRequest, fmt.Sprintf("invalid switch: %s", strings.Join(reasons, "; ")))
+		return &e
+	}
+
+	_, e := s.switches.GetSingle(ctx, body.Brand, body.Name)
+	if e == nil {
+		s.logger.LogError(fmt.Sprintf("switch %s %s exists already", body.Brand, body.Name))
+		return &ErrSwitchExists
+	}
+	if e.Errtype != common.ErrNotFound {
+		s.logger.LogError(e.Error())
+		return e
+	}
+	return nil
+}
+
+func (s service) Revise(ctx context.Context, id int, body switchmodels.SwitchRequestBody, comment string) (*models.Submission, *common.AppError) {
+	if e := validateComment(comment); e != nil {
+		return nil, e
+	}
+
+	entity, e := s.get(ctx, id)
+	if e != nil {
+		return nil, e
+	}
+	principal := authz.FromContext(ctx)
+	if principal.Anonymous() || entity.Submitter != principal.Subject {
+		s.logger.LogError(fmt.Sprintf("%s can't revise submission %d", principal.Subject, id))
+		return nil, &ErrNotSubmitter
+	}
+	if entity.Status != models.StatusChangesRequested {
+		s.logger.LogError(fmt.Sprintf("submission %d is %s", id, entity.Status))
+		return nil, &ErrNoChangesAsked
+	}
+
+	if e := s.checkBody(ctx, body); e != nil {
+		return nil, e
+	}
+	if e := s.screen(principal, body.Description+"\n"+comment); e != nil {
+		return nil, e
+	}
+
+	entity.Body = body
+	entity.Status = models.StatusPending
+	return s.transition(ctx, *entity, models.StatusChangesRequested, principal, models.ActionRevise, comment)
+}
+
+func (s service) Mine(ctx context.Context) ([]models.Submission, *common.AppError) {
+	principal := authz.FromContext(ctx)
+	if principal.Anonymous() {
+		s.logger.LogError("anonymous listing of own submissions")
+		return nil, &ErrAnonymous
+	}
+
+	res, e := s.list(ctx, models.ListFilter{Submitter: principal.Subject})
+	if e != nil {
+		return nil, e
+	}
+	slices.Reverse(res)
+	return res, nil
+}
+
+func (s service) Get(ctx context.Context, id int) (*models.Submission, *common.AppError) {
+	entity, e := s.get(ctx, id)
+	if e != nil {
+		return nil, e
+	}
+
+	principal := authz.FromContext(ctx)
+	isSubmitter := !principal.Anonymous() && entity.Submitter == principal.Subject
+	if !isSubmitter && !s.policy.Allows(principal, authz.PermSwitchesApprove) {
+		// others' submissions are not disclosed, not even that they exist
+		s.logger.LogError(fmt.Sprintf("%s can't see submission %d", principal.Subject, id))
+		return nil, &ErrNoSubmission
+	}
+
+	return s.withComments(ctx, *entity)
+}
+
+func (s service) Queue(ctx context.Context) ([]models.Submission, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermSwitchesApprove); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+
+	return s.list(ctx, models.ListFilter{Status: models.StatusPending})
+}
+
+func (s service) Review(ctx context.Context, id int, decision models.Decision) (*models.Submission, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermSwitchesApprove); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+
+	var status models.Status
+	switch decision.Action {
+	case models.ActionApprove:
+		status = models.StatusApproved
+	case models.ActionReject:
+		status = models.StatusRejected
+	case models.ActionRequestChanges:
+		status = models.StatusChangesRequested
+	default:
+		s.logger.LogError(fmt.Sprintf("unknown review action %s", decision.Action))
+		return nil, &ErrUnknownAction
+	}
+	if status != models.StatusApproved && strings.TrimSpace(decision.Comment) == "" {
+		s.logger.LogError("review without comment")
+		return nil, &ErrMissingComment
+	}
+	if e := validateComment(decision.Comment); e != nil {
+		return nil, e
+	}
+
+	entity, e := s.get(ctx, id)
+	if e != nil {
+		return nil, e
+	}
+	if entity.Status != models.StatusPending {
+		s.logger.LogError(fmt.Sprintf("submission %d is %s", id, entity.Status))
+		return nil, &ErrNotPending
+	}
+
+	from := models.StatusPending
+	if status == models.StatusApproved {
+		// the submission is claimed before the switch is added, so no other decision can be
+		// recorded for a switch which is in the catalog already
+		if e := s.claim(ctx, id, models.StatusPending, models.StatusApproved); e != nil {
+			return nil, e
+		}
+		switchID, e := s.switches.AddNew(ctx, entity.Body)
+		if e != nil {
+			s.logger.LogError(e.Error())
+			s.unclaim(ctx, id)
+			return nil, e
+		}
+		entity.SwitchID = switchID
+		from = models.StatusApproved
+	}
+
+	entity.Status = status
+	return s.transition(ctx, *entity, from, authz.FromContext(ctx), decision.Action, decision.Comment)
+}
+
+func (s service) claim(ctx context.Context, id int, from, to models.Status) *common.AppError {
+	ok, err := s.repo.Claim(ctx, id, from, to)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if !ok {
+		s.logger.LogError(fmt.Sprintf("submission %d left %s meanwhile", id, from))
+		return &ErrConcurrentReview
+	}
+	return nil
+}
+
+// unclaim puts a submission back into the queue when its switch could not be added,
+// it runs even if the request was canceled meanwhile
+func (s service) unclaim(ctx context.Context, id int) {
+	if e := s.claim(context.WithoutCancel(ctx), id, models.StatusApproved, models.StatusPending); e != nil {
+		s.logger.LogError(fmt.Sprintf("submission %d is left approved without a switch", id))
+	}
+}
+
+func (s service) transition(ctx context.Context, entity models.SubmissionEntity, from models.Status, author authz.Principal, action models.Action, comment string) (*models.Submission, *common.AppError) {
+	now := time.Now()
+	entity.UpdatedAt = now
+
+	ok, err := s.repo.Transition(ctx, entity, from, models.CommentEntity{
+		SubmissionID: entity.ID,
+		Author:       author.Subject,
+		AuthorName:   author.Name,
+		Action:       action,
+		Comment:      strings.TrimSpace(comment),
+		CreatedAt:    now,
+	})
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if !ok {
+		s.logger.LogError(fmt.Sprintf("submission %d left %s meanwhile", entity.ID, from))
+		return nil, &ErrConcurrentReview
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("submission %d is %s", entity.ID, entity.Status))
+	return s.withComments(ctx, entity)
+}
+
+func (s service) get(ctx context.Context, id int) (*models.SubmissionEntity, *common.AppError) {
+	entity, err := s.repo.Get(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil {
+		s.logger.LogError(fmt.Sprintf("submission %d not found", id))
+		return nil, &ErrNoSubmission
+	}
+	return entity, nil
+}
+
+func (s service) list(ctx context.Context, filter models.ListFilter) ([]models.Submission, *common.AppError) {
+	entities, err := s.repo.List(ctx, filter)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := make([]models.Submission, len(entities))
+	for i, e := range entities {
+		res[i] = toSubmission(e, nil)
+	}
+	s.logger.LogTrace(fmt.Sprintf("%d submissions listed", len(res)))
+	return res, nil
+}
+
+func (s service) withComments(ctx context.Context, entity models.SubmissionEntity) (*models.Submission, *common.AppError) {
+	comments, err := s.repo.Comments(ctx, entity.ID)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := toSubmission(entity, comments)
+	return &res, nil
+}
+
+func toSubmission(e models.SubmissionEntity, comments []models.CommentEntity) models.Submission {
+	res := models.Submission{
+		ID:            e.ID,
+		Submitter:     e.Submitter,
+		SubmitterName: e.SubmitterName,
+		Body:          e.Body,
+		Status:        e.Status,
+		SwitchID:      e.SwitchID,
+		CreatedAt:     e.CreatedAt,
+		UpdatedAt:     e.UpdatedAt,
+		Comments:      make([]models.Comment, len(comments)),
+	}
+	for i, c := range comments {
+		res.Comments[i] = models.Comment{
+			Author:    c.AuthorName,
+			Action:    c.Action,
+			Comment:   c.Comment,
+			CreatedAt: c.CreatedAt,
+		}
+	}
+	return res
+}
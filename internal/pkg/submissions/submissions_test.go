@@ -0,0 +1,268 @@
+package submissions_test
+
+import (
+	"context"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	coresubmissions "kbswitch/internal/core/submissions"
+	"kbswitch/internal/core/submissions/models"
+	"kbswitch/internal/core/switches"
+	switchmodels "kbswitch/internal/core/switches/models"
+	authzpolicy "kbswitch/internal/pkg/authz"
+	"kbswitch/internal/pkg/contentfilter"
+	"kbswitch/internal/pkg/submissions"
+	switchservice "kbswitch/internal/pkg/switches"
+	"testing"
+)
+
+type fakeLogger struct{}
+
+func (fakeLogger) LogError(msg string) {}
+func (fakeLogger) LogInfo(msg string)  {}
+func (fakeLogger) LogTrace(msg string) {}
+
+type memRepo struct {
+	submissions map[int]*models.SubmissionEntity
+	comments    []models.CommentEntity
+}
+
+func (m *memRepo) Insert(ctx context.Context, e models.SubmissionEntity) (*int, error) {
+	e.ID = len(m.submissions) + 1
+	m.submissions[e.ID] = &e
+	return &e.ID, nil
+}
+
+func (m *memRepo) Get(ctx context.Context, id int) (*models.SubmissionEntity, error) {
+	e, ok := m.submissions[id]
+	if !ok {
+		return nil, nil
+	}
+	res := *e
+	return &res, nil
+}
+
+func (m *memRepo) List(ctx context.Context, filter models.ListFilter) ([]models.SubmissionEntity, error) {
+	res := []models.SubmissionEntity{}
+	for id := 1; id <= len(m.submissions); id++ {
+		e := m.submissions[id]
+		if (filter.Submitter == "" || e.Submitter == filter.Submitter) && (filter.Status == "" || e.Status == filter.Status) {
+			res = append(res, *e)
+		}
+	}
+	return res, nil
+}
+
+func (m *memRepo) Transition(ctx context.Context, e models.SubmissionEntity, from models.Status, comment models.CommentEntity) (bool, error) {
+	if m.submissions[e.ID].Status != from {
+		return false, nil
+	}
+	m.submissions[e.ID] = &e
+	m.comments = append(m.comments, comment)
+	return true, nil
+}
+
+func (m *memRepo) Claim(ctx context.Context, id int, from, to models.Status) (bool, error) {
+	if m.submissions[id].Status != from {
+		return false, nil
+	}
+	m.submissions[id].Status = to
+	return true, nil
+}
+
+func (m *memRepo) Comments(ctx context.Context, submissionID int) ([]models.CommentEntity, error) {
+	res := []models.CommentEntity{}
+	for _, c := range m.comments {
+		if c.SubmissionID == submissionID {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+// fakeCatalog knows switches by brand and name only
+type fakeCatalog struct {
+	switches.Service
+	added map[string]int
+	// onAdd runs before the switch is added, it stands for whatever happens meanwhile
+	onAdd func()
+}
+
+func (f *fakeCatalog) GetSingle(ctx context.Context, brand, name string) (*switchmodels.Switch, *common.AppError) {
+	if _, ok := f.added[brand+"/"+name]; ok {
+		return &switchmodels.Switch{}, nil
+	}
+	return nil, &switchservice.ErrNoSwitch
+}
+
+func (f *fakeCatalog) AddNew(ctx context.Context, body switchmodels.SwitchRequestBody) (*int, *common.AppError) {
+	if f.onAdd != nil {
+		f.onAdd()
+	}
+	if _, ok := f.added[body.Brand+"/"+body.Name]; ok {
+		return nil, &switchservice.ErrAlreadyExists
+	}
+	id := len(f.added) + 1
+	f.added[body.Brand+"/"+body.Name] = id
+	return &id, nil
+}
+
+var (
+	contributor = authz.Principal{Subject: "user:1", Name: "jane@example.com", Role: authz.RoleContributor}
+	other       = authz.Principal{Subject: "user:2", Name: "john@example.com", Role: authz.RoleContributor}
+	moderator   = authz.Principal{Subject: "user:3", Name: "mod@example.com", Role: authz.RoleModerator}
+	viewer      = authz.Principal{Subject: "key:1", Name: "reader", Role: authz.RoleViewer}
+
+	ink = switchmodels.SwitchRequestBody{Brand: "Gateron", Name: "Ink Black", OperatingForce: 60, ActivationTravel: 2, TotalTravel: 4}
+)
+
+func as(p authz.Principal) context.Context {
+	return authz.WithPrincipal(context.Background(), p)
+}
+
+func newService() (*fakeCatalog, coresubmissions.Service) {
+	catalog := &fakeCatalog{added: map[string]int{"Cherry/MX Red": 1}}
+	repo := &memRepo{submissions: map[int]*models.SubmissionEntity{}}
+	return catalog, submissions.New(fakeLogger{}, repo, catalog, authzpolicy.NewRolePolicy(), contentfilter.New(contentfilter.DefaultConfig()))
+}
+
+func TestSubmit(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal authz.Principal
+		body      switchmodels.SwitchRequestBody
+		expected  error
+	}{
+		{name: "contributor", principal: contributor, body: ink},
+		{name: "anonymous", principal: authz.Anonymous, body: ink, expected: common.ErrForbidden},
+		{name: "viewer", principal: viewer, body: ink, expected: common.ErrForbidden},
+		{name: "invalid", principal: contributor, body: switchmodels.SwitchRequestBody{Brand: "Gateron"}, expected: common.ErrBadRequest},
+		{name: "in catalog", principal: contributor, body: switchmodels.SwitchRequestBody{Brand: "Cherry", Name: "MX Red"}, expected: common.ErrBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, service := newService()
+
+			res, err := service.Submit(as(test.principal), test.body)
+			if test.expected != nil {
+				if err == nil || err.Errtype != test.expected {
+					t.Fatalf("expected error of type %v, got %v", test.expected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if res.Status != models.StatusPending || res.Submitter != test.principal.Subject {
+				t.Errorf("unexpected submission %+v", *res)
+			}
+		})
+	}
+}
+
+func TestReviewFlow(t *testing.T) {
+	catalog, service := newService()
+
+	sub, _ := service.Submit(as(contributor), ink)
+
+	if _, err := service.Review(as(contributor), sub.ID, models.Decision{Action: models.ActionApprove}); err == nil || err.Errtype != common.ErrForbidden {
+		t.Fatalf("expected contributor to be refused, got %v", err)
+	}
+	if _, err := service.Review(as(moderator), sub.ID, models.Decision{Action: models.ActionRequestChanges}); err == nil || err.Reason != submissions.ErrMissingComment.Reason {
+		t.Fatalf("expected comment to be required, got %v", err)
+	}
+
+	res, err := service.Review(as(moderator), sub.ID, models.Decision{Action: models.ActionRequestChanges, Comment: "total travel is 4.0mm"})
+	if err != nil || res.Status != models.StatusChangesRequested {
+		t.Fatalf("expected changes to be requested, got %v %v", res, err)
+	}
+	if queue, _ := service.Queue(as(moderator)); len(queue) != 0 {
+		t.Errorf("expected queue to be empty while changes are requested, got %d", len(queue))
+	}
+
+	if _, err := service.Revise(as(other), sub.ID, ink, ""); err == nil || err.Reason != submissions.ErrNotSubmitter.Reason {
+		t.Fatalf("expected somebody else's revision to be refused, got %v", err)
+	}
+	revised := ink
+	revised.TotalTravel = 4.0
+	if res, err = service.Revise(as(contributor), sub.ID, revised, "fixed"); err != nil || res.Status != models.StatusPending {
+		t.Fatalf("expected revision to be pending again, got %v %v", res, err)
+	}
+	if queue, _ := service.Queue(as(moderator)); len(queue) != 1 {
+		t.Errorf("expected revised submission in the queue, got %d", len(queue))
+	}
+
+	res, err = service.Review(as(moderator), sub.ID, models.Decision{Action: models.ActionApprove})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if res.Status != models.StatusApproved || res.SwitchID == nil || catalog.added["Gateron/Ink Black"] != *res.SwitchID {
+		t.Errorf("expected approved switch in the catalog, got %+v", *res)
+	}
+	if len(res.Comments) != 3 {
+		t.Errorf("expected every action to be recorded, got %+v", res.Comments)
+	}
+
+	if _, err := service.Review(as(moderator), sub.ID, models.Decision{Action: models.ActionReject, Comment: "late"}); err == nil || err.Errtype != common.ErrConflict {
+		t.Fatalf("expected decided submission to be final, got %v", err)
+	}
+}
+
+func TestConcurrentReview(t *testing.T) {
+	catalog, service := newService()
+	sub, _ := service.Submit(as(contributor), ink)
+
+	var rejectErr *common.AppError
+	catalog.onAdd = func() {
+		_, rejectErr = service.Review(as(moderator), sub.ID, models.Decision{Action: models.ActionReject, Comment: "duplicate"})
+	}
+	res, err := service.Review(as(moderator), sub.ID, models.Decision{Action: models.ActionApprove})
+	if err != nil || res.Status != models.StatusApproved {
+		t.Fatalf("expected approval to win, got %v %v", res, err)
+	}
+	if rejectErr == nil || rejectErr.Errtype != common.ErrConflict {
+		t.Errorf("expected rejection of a claimed submission to be refused, got %v", rejectErr)
+	}
+
+	// the switch shows up in the catalog before the approval adds it
+	sub, _ = service.Submit(as(contributor), switchmodels.SwitchRequestBody{Brand: "Gateron", Name: "Milky Yellow", OperatingForce: 50})
+	catalog.onAdd = func() { catalog.added["Gateron/Milky Yellow"] = 9 }
+	if _, err := service.Review(as(moderator), sub.ID, models.Decision{Action: models.ActionApprove}); err == nil {
+		t.Fatalf("expected approval of a duplicate to fail")
+	}
+	if res, _ := service.Get(as(moderator), sub.ID); res.Status != models.StatusPending {
+		t.Errorf("expected failed approval to put the submission back into the queue, got %s", res.Status)
+	}
+}
+
+func TestGet(t *testing.T) {
+	_, service := newService()
+	sub, _ := service.Submit(as(contributor), ink)
+
+	tests := []struct {
+		name      string
+		principal authz.Principal
+		expected  error
+	}{
+		{name: "submitter", principal: contributor},
+		{name: "moderator", principal: moderator},
+		{name: "somebody else", principal: other, expected: common.ErrNotFound},
+		{name: "anonymous", principal: authz.Anonymous, expected: common.ErrNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := service.Get(as(test.principal), sub.ID)
+			if test.expected == nil && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if test.expected != nil && (err == nil || err.Errtype != test.expected) {
+				t.Fatalf("expected error of type %v, got %v", test.expected, err)
+			}
+		})
+	}
+
+	if mine, _ := service.Mine(as(other)); len(mine) != 0 {
+		t.Errorf("expected no submissions of somebody else, got %d", len(mine))
+	}
+}
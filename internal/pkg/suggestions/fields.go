@@ -0,0 +1,148 @@
+package suggestions
+
+import (
+	"kbswitch/internal/core/suggestions/models"
+	switchmodels "kbswitch/internal/core/switches/models"
+)
+
+// field knows how to read, compare and apply a single suggestable attribute
+type field struct {
+	name string
+	// proposed gives value of the field in a patch, false when patch leaves it out
+	proposed func(models.Patch) (any, bool)
+	current  func(switchmodels.Switch) any
+	// snapshot copies current value of the field into a patch
+	snapshot func(*models.Patch, switchmodels.Switch)
+	apply    func(*switchmodels.SwitchRequestBody, models.Patch)
+}
+
+func value[T any](v *T) (any, bool) {
+	if v == nil {
+		return nil, false
+	}
+	return *v, true
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// fields are listed in the order diffs are presented
+var fields = []field{
+	{
+		name:     switchmodels.AttrOperatingForce,
+		proposed: func(p models.Patch) (any, bool) { return value(p.OperatingForce) },
+		current:  func(s switchmodels.Switch) any { return s.OperatingForce },
+		snapshot: func(p *models.Patch, s switchmodels.Switch) { p.OperatingForce = ptr(s.OperatingForce) },
+		apply:    func(b *switchmodels.SwitchRequestBody, p models.Patch) { b.OperatingForce = *p.OperatingForce },
+	},
+	{
+		name:     switchmodels.AttrActivationTravel,
+		proposed: func(p models.Patch) (any, bool) { return value(p.ActivationTravel) },
+		current:  func(s switchmodels.Switch) any { return s.ActivationTravel },
+		snapshot: func(p *models.Patch, s switchmodels.Switch) { p.ActivationTravel = ptr(s.ActivationTravel) },
+		apply:    func(b *switchmodels.SwitchRequestBody, p models.Patch) { b.ActivationTravel = *p.ActivationTravel },
+	},
+	{
+		name:     switchmodels.AttrTotalTravel,
+		proposed: func(p models.Patch) (any, bool) { return value(p.TotalTravel) },
+		current:  func(s switchmodels.Switch) any { return s.TotalTravel },
+		snapshot: func(p *models.Patch, s switchmodels.Switch) { p.TotalTravel = ptr(s.TotalTravel) },
+		apply:    func(b *switchmodels.SwitchRequestBody, p models.Patch) { b.TotalTravel = *p.TotalTravel },
+	},
+	{
+		name:     switchmodels.AttrLifespan,
+		proposed: func(p models.Patch) (any, bool) { return value(p.Lifespan) },
+		current:  func(s switchmodels.Switch) any { return s.Lifespan },
+		snapshot: func(p *models.Patch, s switchmodels.Switch) { p.Lifespan = ptr(s.Lifespan) },
+		apply:    func(b *switchmodels.SwitchRequestBody, p models.Patch) { b.Lifespan = *p.Lifespan },
+	},
+	{
+		name:     switchmodels.AttrActuationType,
+		proposed: func(p models.Patch) (any, bool) { return value(p.ActuationType) },
+		current:  func(s switchmodels.Switch) any { return s.ActuationType },
+		snapshot: func(p *models.Patch, s switchmodels.Switch) { p.ActuationType = ptr(s.ActuationType) },
+		apply:    func(b *switchmodels.SwitchRequestBody, p models.Patch) { b.ActuationType = *p.ActuationType },
+	},
+	{
+		name:     switchmodels.AttrSoundProfile,
+		proposed: func(p models.Patch) (any, bool) { return value(p.SoundProfile) },
+		current:  func(s switchmodels.Switch) any { return s.SoundProfile },
+		snapshot: func(p *models.Patch, s switchmodels.Switch) { p.SoundProfile = ptr(s.SoundProfile) },
+		apply:    func(b *switchmodels.SwitchRequestBody, p models.Patch) { b.SoundProfile = *p.SoundProfile },
+	},
+	{
+		name:     switchmodels.AttrTriggerMethod,
+		proposed: func(p models.Patch) (any, bool) { return value(p.TriggerMethod) },
+		current:  func(s switchmodels.Switch) any { return s.TriggerMethod },
+		snapshot: func(p *models.Patch, s switchmodels.Switch) { p.TriggerMethod = ptr(s.TriggerMethod) },
+		apply:    func(b *switchmodels.SwitchRequestBody, p models.Patch) { b.TriggerMethod = *p.TriggerMethod },
+	},
+	{
+		name:     switchmodels.AttrProfile,
+		proposed: func(p models.Patch) (any, bool) { return value(p.Profile) },
+		current:  func(s switchmodels.Switch) any { return s.Profile },
+		snapshot: func(p *models.Patch, s switchmodels.Switch) { p.Profile = ptr(s.Profile) },
+		apply:    func(b *switchmodels.SwitchRequestBody, p models.Patch) { b.Profile = *p.Profile },
+	},
+	{
+		name:     switchmodels.AttrDescription,
+		proposed: func(p models.Patch) (any, bool) { return value(p.Description) },
+		current:  func(s switchmodels.Switch) any { return s.Description },
+		snapshot: func(p *models.Patch, s switchmodels.Switch) { p.Description = ptr(s.Description) },
+		apply:    func(b *switchmodels.SwitchRequestBody, p models.Patch) { b.Description = *p.Description },
+	},
+}
+
+func fieldByName(name string) (field, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// diff compares patched fields of a suggestion against the switch as it is now
+func diff(e models.SuggestionEntity, current switchmodels.Switch) ([]models.FieldDiff, bool) {
+	res := []models.FieldDiff{}
+	conflicting := false
+	for _, f := range fields {
+		proposed, ok := f.proposed(e.Patch)
+		if !ok {
+			continue
+		}
+		base, _ := f.proposed(e.Base)
+		now := f.current(current)
+
+		d := models.FieldDiff{
+			Field:    f.name,
+			Base:     base,
+			Current:  now,
+			Proposed: proposed,
+			// a field changed to the proposed value meanwhile is no conflict, accepting it changes nothing
+			Conflict: base != now && proposed != now,
+		}
+		conflicting = conflicting || d.Conflict
+		res = append(res, d)
+	}
+	return res, conflicting
+}
+
+// asBody gives request body which stores s unchanged
+func asBody(s switchmodels.Switch) switchmodels.SwitchRequestBody {
+	return switchmodels.SwitchRequestBody{
+		Brand:            s.Brand,
+		ActuationType:    s.ActuationType,
+		Lifespan:         s.Lifespan,
+		Name:             s.Name,
+		Image:            s.Image,
+		OperatingForce:   s.OperatingForce,
+		ActivationTravel: s.ActivationTravel,
+		TotalTravel:      s.TotalTravel,
+		SoundProfile:     s.SoundProfile,
+		TriggerMethod:    s.TriggerMethod,
+		Profile:          s.Profile,
+		Description:      s.Description,
+	}
+}
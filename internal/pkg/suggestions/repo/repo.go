@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/common/database"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/suggestions"
+	"kbswitch/internal/core/suggestions/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func New(logger logging.Logger, pool database.DBPool) suggestions.Repo {
+	return repo{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+type repo struct {
+	logger logging.Logger
+	pool   database.DBPool
+}
+
+// column order must match scanSuggestion
+const suggestionColumns = `id, brand, name, patch, base, source_url, author, author_name, status,
+	accepted_fields, reviewer, reviewer_name, comment, created_at, reviewed_at`
+
+func scanSuggestion(row pgx.Row, e *models.SuggestionEntity) error {
+	var patch, base []byte
+	var status string
+	err := row.Scan(&e.ID, &e.Brand, &e.Name, &patch, &base, &e.SourceURL, &e.Author, &e.AuthorName, &status,
+		&e.AcceptedFields, &e.Reviewer, &e.ReviewerName, &e.Comment, &e.CreatedAt, &e.ReviewedAt)
+	if err != nil {
+		return err
+	}
+	e.Status = models.Status(status)
+
+	if err := json.Unmarshal(patch, &e.Patch); err != nil {
+		return err
+	}
+	return json.Unmarshal(base, &e.Base)
+}
+
+// Insert implements suggestions.Repo.
+func (r repo) Insert(ctx context.Context, e models.SuggestionEntity) (*int, error) {
+	patch, err := json.Marshal(e.Patch)
+	if err != nil {
+		return nil, err
+	}
+	base, err := json.Marshal(e.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO public.switch_suggestions (brand, name, patch, base, source_url, author, author_name, status, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	RETURNING id`
+
+	var id int
+	err = r.pool.QueryRow(ctx, query, e.Brand, e.Name, patch, base, e.SourceURL, e.Author, e.AuthorName, string(e.Status), e.CreatedAt).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("suggestion %d inserted", id))
+
+	return &id, nil
+}
+
+// Get implements suggestions.Repo.
+func (r repo) Get(ctx context.Context, id int) (*models.SuggestionEntity, error) {
+	query := `SELECT ` + suggestionColumns + ` FROM public.switch_suggestions WHERE id = $1`
+
+	var e models.SuggestionEntity
+	err := scanSuggestion(r.pool.QueryRow(ctx, query, id), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// List implements suggestions.Repo.
+func (r repo) List(ctx context.Context, filter suggestions.ListFilter) ([]models.SuggestionEntity, error) {
+	query := `SELECT ` + suggestionColumns + ` FROM public.switch_suggestions
+	WHERE ($1 = '' OR lower(brand) = lower($1)) AND ($2 = '' OR lower(name) = lower($2)) AND ($3 = '' OR status = $3)
+	ORDER BY created_at, id`
+
+	rows, err := r.pool.Query(ctx, query, filter.Brand, filter.Name, string(filter.Status))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.SuggestionEntity{}
+	for rows.Next() {
+		var e models.SuggestionEntity
+		if err := scanSuggestion(rows, &e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d suggestions listed", len(res)))
+
+	return res, nil
+}
+
+// Review implements suggestions.Repo.
+func (r repo) Review(ctx context.Context, e models.SuggestionEntity) (bool, error) {
+	if e.AcceptedFields == nil {
+		e.AcceptedFields = []string{}
+	}
+
+	query := `UPDATE public.switch_suggestions
+	SET status = $2, accepted_fields = $3, reviewer = $4, reviewer_name = $5, comment = $6, reviewed_at = $7
+	WHERE id = $1 AND status = 'pending'`
+
+	tag, err := r.pool.Exec(ctx, query, e.ID, string(e.Status), e.AcceptedFields, e.Reviewer, e.ReviewerName, e.Comment, e.ReviewedAt)
+	if err != nil {
+		return false, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("suggestion %d review affected %d rows", e.ID, tag.RowsAffected()))
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// Reopen implements suggestions.Repo.
+func (r repo) Reopen(ctx context.Context, id int, from models.Status) (bool, error) {
+	query := `UPDATE public.switch_suggestions
+	SET status = 'pending', accepted_fields = '{}', reviewer = '', reviewer_name = '', comment = '', reviewed_at = NULL
+	WHERE id = $1 AND status = $2`
+
+	tag, err := r.pool.Exec(ctx, query, id, string(from))
+	if err != nil {
+		return false, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("suggestion %d reopen affected %d rows", id, tag.RowsAffected()))
+
+	return tag.RowsAffected() > 0, nil
+}
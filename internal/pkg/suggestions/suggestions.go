@@ -0,0 +1,409 @@
+package suggestions
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/contentfilter"
+	filtermodels "kbswitch/internal/core/contentfilter/models"
+	"kbswitch/internal/core/suggestions"
+	"kbswitch/internal/core/suggestions/models"
+	"kbswitch/internal/core/switches"
+	switchmodels "kbswitch/internal/core/switches/models"
+	filterservice "kbswitch/internal/pkg/contentfilter"
+	switchservice "kbswitch/internal/pkg/switches"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	maxCommentLen   = 2000
+	maxSourceURLLen = 2048
+)
+
+var (
+	ErrNoSuggestion     = common.NewError(common.ErrNotFound, "suggestion with given id not found")
+	ErrEmptyPatch       = common.NewError(common.ErrBadRequest, "suggestion changes no field")
+	ErrNothingChanges   = common.NewError(common.ErrBadRequest, "switch has every suggested value already")
+	ErrMissingSource    = common.NewError(common.ErrBadRequest, "source url is required")
+	ErrInvalidSource    = common.NewError(common.ErrBadRequest, "source url must be an absolute http or https url")
+	ErrCommentTooLong   = common.NewError(common.ErrBadRequest, fmt.Sprintf("comment can't be longer than %d characters", maxCommentLen))
+	ErrNotPending       = common.NewError(common.ErrConflict, "suggestion is not pending anymore")
+	ErrConflicting      = common.NewError(common.ErrConflict, "switch changed since the suggestion was made, accept with force to apply conflicting fields anyway")
+	ErrSwitchGone       = common.NewError(common.ErrConflict, "switch of the suggestion was renamed or removed")
+	ErrConcurrentReview = common.NewError(common.ErrConflict, "suggestion was reviewed by somebody else meanwhile")
+)
+
+// New gives the service, accepted suggestions go through switches which should check the policy on its own.
+// every suggestion waits for a moderator, so only content filter rejecting text refuses it
+func New(logger logging.Logger, repo suggestions.Repo, switches switches.Service, policy authz.Policy, filter contentfilter.Filter) suggestions.Service {
+	return service{
+		repo:     repo,
+		switches: switches,
+		policy:   policy,
+		filter:   filter,
+		logger:   logger,
+	}
+}
+
+type service struct {
+	repo     suggestions.Repo
+	switches switches.Service
+	policy   authz.Policy
+	filter   contentfilter.Filter
+	logger   logging.Logger
+}
+
+func validateSource(source string) *common.AppError {
+	if strings.TrimSpace(source) == "" {
+		return &ErrMissingSource
+	}
+	u, err := url.Parse(source)
+	if err != nil || len(source) > maxSourceURLLen || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &ErrInvalidSource
+	}
+	return nil
+}
+
+func (s service) Suggest(ctx context.Context, brand, name string, req models.NewSuggestion) (*models.Suggestion, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermSwitchesPropose); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+	if e := validateSource(req.SourceURL); e != nil {
+		s.logger.LogError(fmt.Sprintf("invalid source url %s", req.SourceURL))
+		return nil, e
+	}
+
+	current, e := s.switches.GetSingle(ctx, brand, name)
+	if e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+
+	var base models.Patch
+	changes := false
+	body := asBody(*current)
+	for _, f := range fields {
+		proposed, ok := f.proposed(req.Patch)
+		if !ok {
+			continue
+		}
+		f.snapshot(&base, *current)
+		f.apply(&body, req.Patch)
+		changes = changes || proposed != f.current(*current)
+	}
+	if base == (models.Patch{}) {
+		s.logger.LogError("empty suggestion")
+		return nil, &ErrEmptyPatch
+	}
+	if !changes {
+		s.logger.LogError("suggestion changes nothing")
+		return nil, &ErrNothingChanges
+	}
+	// patched switch has to be valid on its own, so accepting it all can't fail validation
+	if reasons := switchservice.Validate(body); reasons != nil {
+		s.logger.LogError(fmt.Sprintf("invalid suggestion %v", reasons))
+		e := common.NewError(common.ErrBadRequest, fmt.Sprintf("invalid switch: %s", strings.Join(reasons, "; ")))
+		return nil, &e
+	}
+
+	principal := authz.FromContext(ctx)
+	if req.Patch.Description != nil {
+		v := s.filter.Check(filtermodels.Content{Author: principal.Subject, Text: *req.Patch.Description})
+		if e := filterservice.Enforce(v, true); e != nil {
+			s.logger.LogError(e.Error())
+			return nil, e
+		}
+	}
+
+	entity := models.SuggestionEntity{
+		Brand:      current.Brand,
+		Name:       current.Name,
+		Patch:      req.Patch,
+		Base:       base,
+		SourceURL:  req.SourceURL,
+		Author:     principal.Subject,
+		AuthorName: principal.Name,
+		Status:     models.StatusPending,
+		CreatedAt:  time.Now(),
+	}
+
+	id, err := s.repo.Insert(ctx, entity)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	entity.ID = *id
+
+	s.logger.LogTrace(fmt.Sprintf("suggestion %d for %s %s added", entity.ID, entity.Brand, entity.Name))
+	res := toSuggestion(entity)
+	res.Diff, res.Conflicting = diff(entity, *current)
+	return &res, nil
+}
+
+func (s service) Get(ctx context.Context, id int) (*models.Suggestion, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermSwitchesApprove); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+
+	entity, e := s.get(ctx, id)
+	if e != nil {
+		return nil, e
+	}
+
+	res := toSuggestion(*entity)
+	if entity.Status == models.StatusPending {
+		if e := s.withDiff(ctx, &res, *entity); e != nil {
+			return nil, e
+		}
+	}
+	return &res, nil
+}
+
+func (s service) Queue(ctx context.Context) ([]models.Suggestion, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermSwitchesApprove); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+
+	entities, err := s.repo.List(ctx, suggestions.ListFilter{Status: models.StatusPending})
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := make([]models.Suggestion, len(entities))
+	for i, e := range entities {
+		res[i] = toSuggestion(e)
+		if e := s.withDiff(ctx, &res[i], e); e != nil {
+			return nil, e
+		}
+	}
+	s.logger.LogTrace(fmt.Sprintf("%d pending suggestions", len(res)))
+	return res, nil
+}
+
+func (s service) ForSwitch(ctx context.Context, brand, name string) ([]models.Suggestion, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermSwitchesApprove); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+
+	current, e := s.switches.GetSingle(ctx, brand, name)
+	if e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+
+	entities, err := s.repo.List(ctx, suggestions.ListFilter{Brand: current.Brand, Name: current.Name})
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := make([]models.Suggestion, len(entities))
+	for i, e := range entities {
+		res[i] = toSuggestion(e)
+		if e.Status == models.StatusPending {
+			res[i].Diff, res[i].Conflicting = diff(e, *current)
+		}
+	}
+	slices.Reverse(res)
+	return res, nil
+}
+
+func (s service) Accept(ctx context.Context, id int, a models.Acceptance) (*models.Suggestion, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermSwitchesApprove); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+	if len(a.Comment) > maxCommentLen {
+		return nil, &ErrCommentTooLong
+	}
+
+	entity, e := s.pending(ctx, id)
+	if e != nil {
+		return nil, e
+	}
+
+	current, e := s.current(ctx, *entity)
+	if e != nil {
+		return nil, e
+	}
+	diffs, _ := diff(*entity, *current)
+
+	accepted := slices.Clone(a.Fields)
+	slices.Sort(accepted)
+	accepted = slices.Compact(accepted)
+	if len(accepted) == 0 {
+		for _, d := range diffs {
+			accepted = append(accepted, d.Field)
+		}
+	}
+
+	body := asBody(*current)
+	for _, name := range accepted {
+		i := slices.IndexFunc(diffs, func(d models.FieldDiff) bool { return d.Field == name })
+		if i < 0 {
+			s.logger.LogError(fmt.Sprintf("field %s is not part of suggestion %d", name, id))
+			e := common.NewError(common.ErrBadRequest, fmt.Sprintf("field '%s' is not part of the suggestion", name))
+			return nil, &e
+		}
+		if diffs[i].Conflict && !a.Force {
+			s.logger.LogError(fmt.Sprintf("field %s of suggestion %d conflicts", name, id))
+			return nil, &ErrConflicting
+		}
+		f, _ := fieldByName(name)
+		f.apply(&body, entity.Patch)
+	}
+
+	entity.Status = models.StatusAccepted
+	if len(accepted) < len(diffs) {
+		entity.Status = models.StatusPartiallyAccepted
+	}
+	entity.AcceptedFields = accepted
+
+	// the review is recorded before the switch changes, so a concurrent review can not win
+	// over an edit which is applied already
+	res, e := s.review(ctx, *entity, a.Comment)
+	if e != nil {
+		return nil, e
+	}
+	if _, e := s.switches.Update(ctx, current.Brand, current.Name, body); e != nil {
+		s.logger.LogError(e.Error())
+		s.reopen(ctx, id, entity.Status)
+		return nil, e
+	}
+
+	return res, nil
+}
+
+// reopen puts a suggestion back into the queue when its edit could not be applied,
+// it runs even if the request was canceled meanwhile
+func (s service) reopen(ctx context.Context, id int, from models.Status) {
+	ok, err := s.repo.Reopen(context.WithoutCancel(ctx), id, from)
+	if err != nil {
+		s.logger.LogError(err.Error())
+	}
+	if !ok {
+		s.logger.LogError(fmt.Sprintf("suggestion %d is left %s without its edit", id, from))
+	}
+}
+
+func (s service) Reject(ctx context.Context, id int, comment string) (*models.Suggestion, *common.AppError) {
+	if err := authz.Require(ctx, s.policy, authz.PermSwitchesApprove); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+	if len(comment) > maxCommentLen {
+		return nil, &ErrCommentTooLong
+	}
+
+	entity, e := s.pending(ctx, id)
+	if e != nil {
+		return nil, e
+	}
+
+	entity.Status = models.StatusRejected
+	return s.review(ctx, *entity, comment)
+}
+
+func (s service) review(ctx context.Context, entity models.SuggestionEntity, comment string) (*models.Suggestion, *common.AppError) {
+	principal := authz.FromContext(ctx)
+	now := time.Now()
+	entity.Reviewer = principal.Subject
+	entity.ReviewerName = principal.Name
+	entity.Comment = strings.TrimSpace(comment)
+	entity.ReviewedAt = &now
+
+	ok, err := s.repo.Review(ctx, entity)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if !ok {
+		s.logger.LogError(fmt.Sprintf("suggestion %d reviewed meanwhile", entity.ID))
+		return nil, &ErrConcurrentReview
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("suggestion %d is %s", entity.ID, entity.Status))
+	res := toSuggestion(entity)
+	return &res, nil
+}
+
+func (s service) get(ctx context.Context, id int) (*models.SuggestionEntity, *common.AppError) {
+	entity, err := s.repo.Get(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil {
+		s.logger.LogError(fmt.Sprintf("suggestion %d not found", id))
+		return nil, &ErrNoSuggestion
+	}
+	return entity, nil
+}
+
+func (s service) pending(ctx context.Context, id int) (*models.SuggestionEntity, *common.AppError) {
+	entity, e := s.get(ctx, id)
+	if e != nil {
+		return nil, e
+	}
+	if entity.Status != models.StatusPending {
+		s.logger.LogError(fmt.Sprintf("suggestion %d is %s", id, entity.Status))
+		return nil, &ErrNotPending
+	}
+	return entity, nil
+}
+
+// current gives the switch a suggestion was made for
+func (s service) current(ctx context.Context, e models.SuggestionEntity) (*switchmodels.Switch, *common.AppError) {
+	current, err := s.switches.GetSingle(ctx, e.Brand, e.Name)
+	if err != nil && err.Errtype == common.ErrNotFound {
+		s.logger.LogError(fmt.Sprintf("switch %s %s of suggestion %d is gone", e.Brand, e.Name, e.ID))
+		return nil, &ErrSwitchGone
+	}
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, err
+	}
+	return current, nil
+}
+
+func (s service) withDiff(ctx context.Context, res *models.Suggestion, e models.SuggestionEntity) *common.AppError {
+	current, err := s.current(ctx, e)
+	if err == &ErrSwitchGone {
+		// nothing to compare with, every field is a conflict then
+		res.Conflicting = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	res.Diff, res.Conflicting = diff(e, *current)
+	return nil
+}
+
+func toSuggestion(e models.SuggestionEntity) models.Suggestion {
+	return models.Suggestion{
+		ID:             e.ID,
+		Brand:          e.Brand,
+		Name:           e.Name,
+		Patch:          e.Patch,
+		SourceURL:      e.SourceURL,
+		Author:         e.AuthorName,
+		Status:         e.Status,
+		AcceptedFields: e.AcceptedFields,
+		Reviewer:       e.ReviewerName,
+		Comment:        e.Comment,
+		CreatedAt:      e.CreatedAt,
+		ReviewedAt:     e.ReviewedAt,
+	}
+}
@@ -0,0 +1,350 @@
+package suggestions_test
+
+import (
+	"context"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	coresuggestions "kbswitch/internal/core/suggestions"
+	"kbswitch/internal/core/suggestions/models"
+	"kbswitch/internal/core/switches"
+	switchmodels "kbswitch/internal/core/switches/models"
+	authzpolicy "kbswitch/internal/pkg/authz"
+	"kbswitch/internal/pkg/contentfilter"
+	"kbswitch/internal/pkg/suggestions"
+	switchservice "kbswitch/internal/pkg/switches"
+	"reflect"
+	"testing"
+)
+
+type fakeLogger struct{}
+
+func (fakeLogger) LogError(msg string) {}
+func (fakeLogger) LogInfo(msg string)  {}
+func (fakeLogger) LogTrace(msg string) {}
+
+type memRepo struct {
+	suggestions []models.SuggestionEntity
+}
+
+func (m *memRepo) Insert(ctx context.Context, e models.SuggestionEntity) (*int, error) {
+	e.ID = len(m.suggestions) + 1
+	m.suggestions = append(m.suggestions, e)
+	return &e.ID, nil
+}
+
+func (m *memRepo) Get(ctx context.Context, id int) (*models.SuggestionEntity, error) {
+	if id < 1 || id > len(m.suggestions) {
+		return nil, nil
+	}
+	res := m.suggestions[id-1]
+	return &res, nil
+}
+
+func (m *memRepo) List(ctx context.Context, filter coresuggestions.ListFilter) ([]models.SuggestionEntity, error) {
+	res := []models.SuggestionEntity{}
+	for _, e := range m.suggestions {
+		if (filter.Brand == "" || e.Brand == filter.Brand) && (filter.Name == "" || e.Name == filter.Name) && (filter.Status == "" || e.Status == filter.Status) {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func (m *memRepo) Review(ctx context.Context, e models.SuggestionEntity) (bool, error) {
+	if m.suggestions[e.ID-1].Status != models.StatusPending {
+		return false, nil
+	}
+	m.suggestions[e.ID-1] = e
+	return true, nil
+}
+
+func (m *memRepo) Reopen(ctx context.Context, id int, from models.Status) (bool, error) {
+	e := &m.suggestions[id-1]
+	if e.Status != from {
+		return false, nil
+	}
+	e.Status = models.StatusPending
+	e.AcceptedFields, e.Reviewer, e.ReviewerName, e.Comment, e.ReviewedAt = nil, "", "", "", nil
+	return true, nil
+}
+
+// fakeCatalog holds a single switch
+type fakeCatalog struct {
+	switches.Service
+	sw switchmodels.Switch
+	// onUpdate runs before the switch is updated, it stands for whatever happens meanwhile
+	onUpdate func() *common.AppError
+}
+
+func (f *fakeCatalog) GetSingle(ctx context.Context, brand, name string) (*switchmodels.Switch, *common.AppError) {
+	if brand != f.sw.Brand || name != f.sw.Name {
+		return nil, &switchservice.ErrNoSwitch
+	}
+	res := f.sw
+	return &res, nil
+}
+
+func (f *fakeCatalog) Update(ctx context.Context, brand, name string, body switchmodels.SwitchRequestBody) (*switchmodels.Switch, *common.AppError) {
+	if f.onUpdate != nil {
+		if e := f.onUpdate(); e != nil {
+			return nil, e
+		}
+	}
+	f.sw = switchmodels.Switch{
+		Brand:            body.Brand,
+		Name:             body.Name,
+		ActuationType:    body.ActuationType,
+		Lifespan:         body.Lifespan,
+		Image:            body.Image,
+		OperatingForce:   body.OperatingForce,
+		ActivationTravel: body.ActivationTravel,
+		TotalTravel:      body.TotalTravel,
+		SoundProfile:     body.SoundProfile,
+		TriggerMethod:    body.TriggerMethod,
+		Profile:          body.Profile,
+		Description:      body.Description,
+	}
+	res := f.sw
+	return &res, nil
+}
+
+var (
+	contributor = authz.Principal{Subject: "user:1", Name: "jane@example.com", Role: authz.RoleContributor}
+	moderator   = authz.Principal{Subject: "user:3", Name: "mod@example.com", Role: authz.RoleModerator}
+
+	source = "https://www.gateron.com/products/ink-black"
+)
+
+func as(p authz.Principal) context.Context {
+	return authz.WithPrincipal(context.Background(), p)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func newService() (*fakeCatalog, coresuggestions.Service) {
+	catalog := &fakeCatalog{sw: switchmodels.Switch{
+		Brand:            "Gateron",
+		Name:             "Ink Black",
+		OperatingForce:   60,
+		ActivationTravel: 2,
+		TotalTravel:      4,
+		Profile:          "MX",
+	}}
+	return catalog, suggestions.New(fakeLogger{}, &memRepo{}, catalog, authzpolicy.NewRolePolicy(), contentfilter.New(contentfilter.DefaultConfig()))
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name     string
+		patch    models.Patch
+		source   string
+		expected error
+	}{
+		{name: "valid", patch: models.Patch{OperatingForce: ptr(65)}, source: source},
+		{name: "empty", patch: models.Patch{}, source: source, expected: common.ErrBadRequest},
+		{name: "same values", patch: models.Patch{OperatingForce: ptr(60)}, source: source, expected: common.ErrBadRequest},
+		{name: "invalid switch", patch: models.Patch{ActivationTravel: ptr(5.0)}, source: source, expected: common.ErrBadRequest},
+		{name: "no source", patch: models.Patch{OperatingForce: ptr(65)}, expected: common.ErrBadRequest},
+		{name: "not a url", patch: models.Patch{OperatingForce: ptr(65)}, source: "gateron.com", expected: common.ErrBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, service := newService()
+
+			res, err := service.Suggest(as(contributor), "Gateron", "Ink Black", models.NewSuggestion{Patch: test.patch, SourceURL: test.source})
+			if test.expected != nil {
+				if err == nil || err.Errtype != test.expected {
+					t.Fatalf("expected error of type %v, got %v", test.expected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			expected := []models.FieldDiff{{Field: switchmodels.AttrOperatingForce, Base: 60, Current: 60, Proposed: 65}}
+			if !reflect.DeepEqual(res.Diff, expected) {
+				t.Errorf("expected diff %+v, got %+v", expected, res.Diff)
+			}
+		})
+	}
+}
+
+func TestAccept(t *testing.T) {
+	patch := models.Patch{OperatingForce: ptr(65), TotalTravel: ptr(3.8), Profile: ptr("MX")}
+
+	tests := []struct {
+		name string
+		// meanwhile changes the catalog between suggesting and accepting
+		meanwhile  func(*fakeCatalog)
+		acceptance models.Acceptance
+		expected   struct {
+			status models.Status
+			sw     switchmodels.Switch
+			err    error
+		}
+	}{
+		{
+			name: "all",
+			expected: struct {
+				status models.Status
+				sw     switchmodels.Switch
+				err    error
+			}{status: models.StatusAccepted, sw: switchmodels.Switch{Brand: "Gateron", Name: "Ink Black", OperatingForce: 65, ActivationTravel: 2, TotalTravel: 3.8, Profile: "MX"}},
+		},
+		{
+			name:       "part",
+			acceptance: models.Acceptance{Fields: []string{switchmodels.AttrTotalTravel}},
+			expected: struct {
+				status models.Status
+				sw     switchmodels.Switch
+				err    error
+			}{status: models.StatusPartiallyAccepted, sw: switchmodels.Switch{Brand: "Gateron", Name: "Ink Black", OperatingForce: 60, ActivationTravel: 2, TotalTravel: 3.8, Profile: "MX"}},
+		},
+		{
+			name:       "unknown field",
+			acceptance: models.Acceptance{Fields: []string{switchmodels.AttrLifespan}},
+			expected: struct {
+				status models.Status
+				sw     switchmodels.Switch
+				err    error
+			}{err: common.ErrBadRequest},
+		},
+		{
+			name:      "conflict",
+			meanwhile: func(c *fakeCatalog) { c.sw.OperatingForce = 62 },
+			expected: struct {
+				status models.Status
+				sw     switchmodels.Switch
+				err    error
+			}{err: common.ErrConflict},
+		},
+		{
+			name:       "conflict left out",
+			meanwhile:  func(c *fakeCatalog) { c.sw.OperatingForce = 62 },
+			acceptance: models.Acceptance{Fields: []string{switchmodels.AttrTotalTravel}},
+			expected: struct {
+				status models.Status
+				sw     switchmodels.Switch
+				err    error
+			}{status: models.StatusPartiallyAccepted, sw: switchmodels.Switch{Brand: "Gateron", Name: "Ink Black", OperatingForce: 62, ActivationTravel: 2, TotalTravel: 3.8, Profile: "MX"}},
+		},
+		{
+			name:       "conflict forced",
+			meanwhile:  func(c *fakeCatalog) { c.sw.OperatingForce = 62 },
+			acceptance: models.Acceptance{Force: true},
+			expected: struct {
+				status models.Status
+				sw     switchmodels.Switch
+				err    error
+			}{status: models.StatusAccepted, sw: switchmodels.Switch{Brand: "Gateron", Name: "Ink Black", OperatingForce: 65, ActivationTravel: 2, TotalTravel: 3.8, Profile: "MX"}},
+		},
+		{
+			name:      "changed to proposed value",
+			meanwhile: func(c *fakeCatalog) { c.sw.OperatingForce = 65 },
+			expected: struct {
+				status models.Status
+				sw     switchmodels.Switch
+				err    error
+			}{status: models.StatusAccepted, sw: switchmodels.Switch{Brand: "Gateron", Name: "Ink Black", OperatingForce: 65, ActivationTravel: 2, TotalTravel: 3.8, Profile: "MX"}},
+		},
+		{
+			name:      "switch renamed",
+			meanwhile: func(c *fakeCatalog) { c.sw.Name = "Ink Black V2" },
+			expected: struct {
+				status models.Status
+				sw     switchmodels.Switch
+				err    error
+			}{err: common.ErrConflict},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			catalog, service := newService()
+
+			sug, err := service.Suggest(as(contributor), "Gateron", "Ink Black", models.NewSuggestion{Patch: patch, SourceURL: source})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.meanwhile != nil {
+				test.meanwhile(catalog)
+			}
+
+			if _, err := service.Accept(as(contributor), sug.ID, test.acceptance); err == nil || err.Errtype != common.ErrForbidden {
+				t.Fatalf("expected contributor to be refused, got %v", err)
+			}
+
+			res, err := service.Accept(as(moderator), sug.ID, test.acceptance)
+			if test.expected.err != nil {
+				if err == nil || err.Errtype != test.expected.err {
+					t.Fatalf("expected error of type %v, got %v", test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if res.Status != test.expected.status || res.Author != contributor.Name || res.Reviewer != moderator.Name {
+				t.Errorf("unexpected suggestion %+v", *res)
+			}
+			if catalog.sw != test.expected.sw {
+				t.Errorf("expected switch %+v, got %+v", test.expected.sw, catalog.sw)
+			}
+			if _, err := service.Reject(as(moderator), sug.ID, ""); err == nil || err.Errtype != common.ErrConflict {
+				t.Errorf("expected reviewed suggestion to be final, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConcurrentAccept(t *testing.T) {
+	patch := models.Patch{OperatingForce: ptr(65)}
+	catalog, service := newService()
+
+	sug, _ := service.Suggest(as(contributor), "Gateron", "Ink Black", models.NewSuggestion{Patch: patch, SourceURL: source})
+	var rejectErr *common.AppError
+	catalog.onUpdate = func() *common.AppError {
+		_, rejectErr = service.Reject(as(moderator), sug.ID, "no")
+		return nil
+	}
+	if res, err := service.Accept(as(moderator), sug.ID, models.Acceptance{}); err != nil || res.Status != models.StatusAccepted {
+		t.Fatalf("expected acceptance to win, got %v %v", res, err)
+	}
+	if rejectErr == nil || rejectErr.Errtype != common.ErrConflict {
+		t.Errorf("expected rejection of an accepted suggestion to be refused, got %v", rejectErr)
+	}
+
+	// the switch is gone before the edit is applied
+	sug, _ = service.Suggest(as(contributor), "Gateron", "Ink Black", models.NewSuggestion{Patch: models.Patch{OperatingForce: ptr(70)}, SourceURL: source})
+	catalog.onUpdate = func() *common.AppError { return &switchservice.ErrNoSwitch }
+	if _, err := service.Accept(as(moderator), sug.ID, models.Acceptance{}); err == nil {
+		t.Fatalf("expected failed edit to fail the acceptance")
+	}
+	if res, _ := service.Get(as(moderator), sug.ID); res.Status != models.StatusPending || res.Reviewer != "" {
+		t.Errorf("expected failed acceptance to put the suggestion back into the queue, got %+v", *res)
+	}
+}
+
+func TestQueueFlagsConflicts(t *testing.T) {
+	catalog, service := newService()
+
+	service.Suggest(as(contributor), "Gateron", "Ink Black", models.NewSuggestion{Patch: models.Patch{OperatingForce: ptr(65)}, SourceURL: source})
+	service.Suggest(as(contributor), "Gateron", "Ink Black", models.NewSuggestion{Patch: models.Patch{TotalTravel: ptr(3.8)}, SourceURL: source})
+	catalog.sw.OperatingForce = 62
+
+	queue, err := service.Queue(as(moderator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 2 || !queue[0].Conflicting || queue[1].Conflicting {
+		t.Errorf("expected only the first suggestion to conflict, got %+v", queue)
+	}
+	if d := queue[0].Diff[0]; d.Base != 60 || d.Current != 62 || d.Proposed != 65 {
+		t.Errorf("unexpected diff %+v", d)
+	}
+}
@@ -0,0 +1,108 @@
+package switches
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+	"strings"
+)
+
+var (
+	ErrInvalidAlias = common.NewError(common.ErrBadRequest, "alias brand and name must not be empty")
+	ErrAliasTaken   = common.NewError(common.ErrBadRequest, "given brand and name already belong to a switch or an alias")
+	ErrNoAlias      = common.NewError(common.ErrNotFound, "alias with given brand and name not found")
+)
+
+func (s service) GetAliases(ctx context.Context, brand, name string) ([]models.Alias, *common.AppError) {
+	switchID, err := s.repo.GetID(ctx, brand, name)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if switchID == nil {
+		s.logger.LogError("switchID from repo was nil")
+		return nil, &ErrNoSwitch
+	}
+
+	resp, err := s.repo.GetAliases(ctx, *switchID)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := make([]models.Alias, 0, len(resp))
+	for _, a := range resp {
+		res = append(res, models.Alias{Brand: a.Brand, Name: a.Name})
+	}
+	s.logger.LogTrace(fmt.Sprintf("result is %v", res))
+
+	return res, nil
+}
+
+func (s service) AddAlias(ctx context.Context, brand, name string, alias models.AliasRequestBody) *common.AppError {
+	alias.Brand = strings.TrimSpace(alias.Brand)
+	alias.Name = strings.TrimSpace(alias.Name)
+	if alias.Brand == "" || alias.Name == "" {
+		s.logger.LogError(fmt.Sprintf("invalid alias %v", alias))
+		return &ErrInvalidAlias
+	}
+
+	switchID, err := s.repo.GetID(ctx, brand, name)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if switchID == nil {
+		s.logger.LogError("switchID from repo was nil")
+		return &ErrNoSwitch
+	}
+
+	takenID, err := s.repo.GetID(ctx, alias.Brand, alias.Name)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if takenID != nil {
+		s.logger.LogError(fmt.Sprintf("alias %s/%s already resolves to %d", alias.Brand, alias.Name, *takenID))
+		return &ErrAliasTaken
+	}
+
+	_, err = s.repo.AddAlias(ctx, models.AliasEntity{
+		SwitchID: *switchID,
+		Brand:    alias.Brand,
+		Name:     alias.Name,
+	})
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	s.logger.LogTrace(fmt.Sprintf("alias %s/%s added to %s/%s", alias.Brand, alias.Name, brand, name))
+
+	return nil
+}
+
+func (s service) RemoveAlias(ctx context.Context, brand, name string, alias models.Alias) *common.AppError {
+	switchID, err := s.repo.GetID(ctx, brand, name)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if switchID == nil {
+		s.logger.LogError("switchID from repo was nil")
+		return &ErrNoSwitch
+	}
+
+	removed, err := s.repo.RemoveAlias(ctx, *switchID, alias.Brand, alias.Name)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if !removed {
+		s.logger.LogError(fmt.Sprintf("alias %s/%s was not found", alias.Brand, alias.Name))
+		return &ErrNoAlias
+	}
+	s.logger.LogTrace(fmt.Sprintf("alias %s/%s removed from %s/%s", alias.Brand, alias.Name, brand, name))
+
+	return nil
+}
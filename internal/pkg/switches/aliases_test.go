@@ -0,0 +1,149 @@
+package switches_test
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+	"kbswitch/internal/pkg/switches"
+	"testing"
+)
+
+func TestAddAlias(t *testing.T) {
+	tcases := []struct {
+		repo     fakeRepo
+		logger   fakeLogger
+		alias    models.AliasRequestBody
+		expected struct {
+			err  *common.AppError
+			logs []string
+		}
+	}{
+		{
+			repo:  fakeRepo{},
+			alias: models.AliasRequestBody{Brand: " ", Name: "MX Red"},
+			expected: struct {
+				err  *common.AppError
+				logs []string
+			}{
+				err:  &switches.ErrInvalidAlias,
+				logs: []string{LogLvlError},
+			},
+		},
+		{
+			repo: fakeRepo{
+				getID: func(s1, s2 string) (*int, error) {
+					return nil, nil
+				},
+			},
+			alias: models.AliasRequestBody{Brand: "Cherry", Name: "MX Red"},
+			expected: struct {
+				err  *common.AppError
+				logs []string
+			}{
+				err:  &switches.ErrNoSwitch,
+				logs: []string{LogLvlError},
+			},
+		},
+		{
+			repo: fakeRepo{
+				getID: func(s1, s2 string) (*int, error) {
+					return intptr(1), nil
+				},
+			},
+			alias: models.AliasRequestBody{Brand: "Cherry", Name: "MX Red"},
+			expected: struct {
+				err  *common.AppError
+				logs []string
+			}{
+				err:  &switches.ErrAliasTaken,
+				logs: []string{LogLvlError},
+			},
+		},
+		{
+			repo: fakeRepo{
+				getID: func(brand, name string) (*int, error) {
+					if brand == "Cherry" && name == "Red" {
+						return intptr(1), nil
+					}
+					return nil, nil
+				},
+				addAliasAction: func(ae models.AliasEntity) (*int, error) {
+					if ae.SwitchID != 1 || ae.Brand != "Cherry" || ae.Name != "MX Red" {
+						return nil, errTest
+					}
+					return intptr(10), nil
+				},
+			},
+			alias: models.AliasRequestBody{Brand: " Cherry", Name: "MX Red "},
+			expected: struct {
+				err  *common.AppError
+				logs []string
+			}{
+				err:  nil,
+				logs: []string{LogLvlTrace},
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		unit := switches.New(&tc.logger, tc.repo)
+		err := unit.AddAlias(context.Background(), "Cherry", "Red", tc.alias)
+
+		assertErrorsEqual("AddAlias", t, tc.expected.err, err)
+		assertLogsEqual("AddAlias", t, tc.expected.logs, tc.logger.logs)
+	}
+}
+
+func TestRemoveAlias(t *testing.T) {
+	tcases := []struct {
+		repo     fakeRepo
+		logger   fakeLogger
+		expected struct {
+			err  *common.AppError
+			logs []string
+		}
+	}{
+		{
+			repo: fakeRepo{
+				getID: func(s1, s2 string) (*int, error) {
+					return intptr(1), nil
+				},
+				removeAliasAction: func(i int, s1, s2 string) (bool, error) {
+					return false, nil
+				},
+			},
+			expected: struct {
+				err  *common.AppError
+				logs []string
+			}{
+				err:  &switches.ErrNoAlias,
+				logs: []string{LogLvlError},
+			},
+		},
+		{
+			repo: fakeRepo{
+				getID: func(s1, s2 string) (*int, error) {
+					return intptr(1), nil
+				},
+				removeAliasAction: func(i int, s1, s2 string) (bool, error) {
+					return true, nil
+				},
+			},
+			expected: struct {
+				err  *common.AppError
+				logs []string
+			}{
+				err:  nil,
+				logs: []string{LogLvlTrace},
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		unit := switches.New(&tc.logger, tc.repo)
+		err := unit.RemoveAlias(context.Background(), "Cherry", "Red", models.Alias{Brand: "Cherry", Name: "MX Red"})
+
+		assertErrorsEqual("RemoveAlias", t, tc.expected.err, err)
+		assertLogsEqual("RemoveAlias", t, tc.expected.logs, tc.logger.logs)
+	}
+}
@@ -0,0 +1,143 @@
+package switches
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches"
+	"kbswitch/internal/core/switches/models"
+)
+
+const MaxBatchOperations = 100
+
+var (
+	ErrEmptyBatch        = common.NewError(common.ErrBadRequest, "batch has no operations")
+	ErrTooManyOperations = common.NewError(common.ErrBadRequest, fmt.Sprintf("batch is limited to %d operations", MaxBatchOperations))
+	ErrMissingBody       = common.NewError(common.ErrBadRequest, "operation requires a switch body")
+	ErrMissingTarget     = common.NewError(common.ErrBadRequest, "operation requires brand and name of the switch it targets")
+	ErrUnknownAction     = common.NewError(common.ErrBadRequest, "operation action must be one of create, update or delete")
+)
+
+// errBatchFailed aborts atomic batch transaction, it is never returned to the caller
+var errBatchFailed = errors.New("batch operation failed")
+
+func (s service) Batch(ctx context.Context, ops []models.BatchOperation, opts models.BatchOptions) (*models.BatchReport, *common.AppError) {
+	if len(ops) == 0 {
+		s.logger.LogError(ErrEmptyBatch.Error())
+		return nil, &ErrEmptyBatch
+	}
+	if len(ops) > MaxBatchOperations {
+		s.logger.LogError(ErrTooManyOperations.Error())
+		return nil, &ErrTooManyOperations
+	}
+
+	report := models.BatchReport{
+		Atomic:  opts.Atomic,
+		Results: make([]models.BatchOperationResult, len(ops)),
+	}
+
+	if !opts.Atomic {
+		for i, op := range ops {
+			report.Results[i] = s.runOperation(ctx, i, op)
+		}
+		s.logger.LogTrace(fmt.Sprintf("result is %v", report))
+		return &report, nil
+	}
+
+	err := s.repo.InTx(ctx, func(tx switches.Repo) error {
+		// operations go through the same service logic, only bound to the transaction
+		txs := service{repo: tx, logger: s.logger}
+
+		for i, op := range ops {
+			report.Results[i] = txs.runOperation(ctx, i, op)
+			if report.Results[i].Status != models.BatchFailed {
+				continue
+			}
+
+			for j := range i {
+				report.Results[j].Status = models.BatchRolledBack
+				report.Results[j].ID = nil
+				report.Results[j].Switch = nil
+			}
+			for j := i + 1; j < len(ops); j++ {
+				report.Results[j] = models.BatchOperationResult{
+					Index:  j,
+					Action: ops[j].Action,
+					Brand:  ops[j].Brand,
+					Name:   ops[j].Name,
+					Status: models.BatchSkipped,
+				}
+			}
+			return errBatchFailed
+		}
+		return nil
+	})
+	if errors.Is(err, errBatchFailed) {
+		report.RolledBack = true
+	} else if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	s.logger.LogTrace(fmt.Sprintf("result is %v", report))
+
+	return &report, nil
+}
+
+func (s service) runOperation(ctx context.Context, index int, op models.BatchOperation) models.BatchOperationResult {
+	res := models.BatchOperationResult{
+		Index:  index,
+		Action: op.Action,
+		Brand:  op.Brand,
+		Name:   op.Name,
+	}
+
+	fail := func(err *common.AppError) models.BatchOperationResult {
+		s.logger.LogError(fmt.Sprintf("batch operation %d failed: %v", index, err))
+		res.Status = models.BatchFailed
+		res.Err = err
+		return res
+	}
+
+	switch op.Action {
+	case models.BatchCreate:
+		if op.Body == nil {
+			return fail(&ErrMissingBody)
+		}
+		res.Brand, res.Name = op.Body.Brand, op.Body.Name
+
+		id, err := s.AddNew(ctx, *op.Body)
+		if err != nil {
+			return fail(err)
+		}
+		res.Status = models.BatchCreated
+		res.ID = id
+	case models.BatchUpdate:
+		if op.Brand == "" || op.Name == "" {
+			return fail(&ErrMissingTarget)
+		}
+		if op.Body == nil {
+			return fail(&ErrMissingBody)
+		}
+
+		sw, err := s.Update(ctx, op.Brand, op.Name, *op.Body)
+		if err != nil {
+			return fail(err)
+		}
+		res.Status = models.BatchUpdated
+		res.Switch = sw
+	case models.BatchDelete:
+		if op.Brand == "" || op.Name == "" {
+			return fail(&ErrMissingTarget)
+		}
+
+		if err := s.Remove(ctx, op.Brand, op.Name); err != nil {
+			return fail(err)
+		}
+		res.Status = models.BatchDeleted
+	default:
+		return fail(&ErrUnknownAction)
+	}
+
+	return res
+}
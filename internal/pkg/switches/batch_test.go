@@ -0,0 +1,136 @@
+package switches_test
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+	"kbswitch/internal/pkg/switches"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	ops := []models.BatchOperation{
+		{Action: models.BatchCreate, Body: &models.SwitchRequestBody{Brand: "Gateron", Name: "Yellow"}},
+		{Action: models.BatchUpdate, Brand: "Cherry", Name: "Red", Body: &models.SwitchRequestBody{Brand: "Cherry", Name: "MX Red"}},
+		{Action: models.BatchDelete, Brand: "Kailh", Name: "Box Jade"},
+		{Action: models.BatchDelete, Brand: "Cherry", Name: "Blue"},
+	}
+
+	tcases := []struct {
+		ops      []models.BatchOperation
+		opts     models.BatchOptions
+		expected struct {
+			statuses   []models.BatchStatus
+			rolledBack bool
+			err        *common.AppError
+		}
+	}{
+		{
+			ops:  ops,
+			opts: models.BatchOptions{},
+			expected: struct {
+				statuses   []models.BatchStatus
+				rolledBack bool
+				err        *common.AppError
+			}{
+				statuses: []models.BatchStatus{
+					models.BatchCreated, models.BatchUpdated, models.BatchFailed, models.BatchDeleted,
+				},
+			},
+		},
+		{
+			ops:  ops,
+			opts: models.BatchOptions{Atomic: true},
+			expected: struct {
+				statuses   []models.BatchStatus
+				rolledBack bool
+				err        *common.AppError
+			}{
+				statuses: []models.BatchStatus{
+					models.BatchRolledBack, models.BatchRolledBack, models.BatchFailed, models.BatchSkipped,
+				},
+				rolledBack: true,
+			},
+		},
+		{
+			ops:  ops[:2],
+			opts: models.BatchOptions{Atomic: true},
+			expected: struct {
+				statuses   []models.BatchStatus
+				rolledBack bool
+				err        *common.AppError
+			}{
+				statuses: []models.BatchStatus{models.BatchCreated, models.BatchUpdated},
+			},
+		},
+		{
+			ops: []models.BatchOperation{
+				{Action: "rename", Brand: "Cherry", Name: "Red"},
+				{Action: models.BatchCreate},
+				{Action: models.BatchUpdate, Body: &models.SwitchRequestBody{Brand: "Cherry", Name: "Red"}},
+			},
+			expected: struct {
+				statuses   []models.BatchStatus
+				rolledBack bool
+				err        *common.AppError
+			}{
+				statuses: []models.BatchStatus{models.BatchFailed, models.BatchFailed, models.BatchFailed},
+			},
+		},
+		{
+			ops: nil,
+			expected: struct {
+				statuses   []models.BatchStatus
+				rolledBack bool
+				err        *common.AppError
+			}{
+				err: &switches.ErrEmptyBatch,
+			},
+		},
+		{
+			ops: make([]models.BatchOperation, switches.MaxBatchOperations+1),
+			expected: struct {
+				statuses   []models.BatchStatus
+				rolledBack bool
+				err        *common.AppError
+			}{
+				err: &switches.ErrTooManyOperations,
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		repo := fakeRepo{
+			getID: func(brand, name string) (*int, error) {
+				if brand == "Cherry" {
+					return intptr(1), nil
+				}
+				return nil, nil
+			},
+			addNewAction: func(se models.SwitchEntity) (*int, error) {
+				return intptr(2), nil
+			},
+			updateAction: func(i int, se models.SwitchEntity) (*models.SwitchEntity, error) {
+				return &se, nil
+			},
+			removeAction: func(i int) error {
+				return nil
+			},
+		}
+
+		unit := switches.New(&fakeLogger{}, repo)
+		res, err := unit.Batch(context.Background(), tc.ops, tc.opts)
+
+		assertErrorsEqual("Batch", t, tc.expected.err, err)
+		if err != nil {
+			continue
+		}
+
+		statuses := make([]models.BatchStatus, len(res.Results))
+		for i, r := range res.Results {
+			statuses[i] = r.Status
+		}
+		assertResultsEqual("Batch", t, tc.expected.statuses, statuses)
+		assertResultsEqual("Batch", t, tc.expected.rolledBack, res.RolledBack)
+	}
+}
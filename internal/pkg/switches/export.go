@@ -0,0 +1,19 @@
+package switches
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+)
+
+func (s service) Export(ctx context.Context, filter models.SwitchFilter, fn func(models.Switch) error) *common.AppError {
+	err := s.repo.Stream(ctx, filter, func(e models.SwitchEntity) error {
+		return fn(toSwitch(e))
+	})
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package switches
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+)
+
+func (s service) GetFacets(ctx context.Context, filter models.SwitchFilter) (*models.Facets, *common.AppError) {
+	resp, err := s.repo.Facets(ctx, filter)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if resp == nil {
+		s.logger.LogError("response from repo was nil")
+		return nil, &ErrErrorMissing
+	}
+	s.logger.LogTrace(fmt.Sprintf("result is %v", *resp))
+
+	return resp, nil
+}
@@ -0,0 +1,141 @@
+package switches
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches"
+	"kbswitch/internal/core/switches/models"
+	"strings"
+)
+
+// errRollback aborts atomic import transaction, it is never returned to the caller
+var errRollback = errors.New("import has rows which were not stored")
+
+func (s service) Import(ctx context.Context, rows []models.ImportRow, opts models.ImportOptions) (*models.ImportReport, *common.AppError) {
+	report := models.ImportReport{
+		DryRun: opts.DryRun,
+		Atomic: opts.Atomic,
+	}
+
+	if !opts.Atomic || opts.DryRun {
+		report.Results = s.importRows(ctx, s.repo, rows, opts)
+		s.logger.LogTrace(fmt.Sprintf("result is %v", report))
+		return &report, nil
+	}
+
+	err := s.repo.InTx(ctx, func(tx switches.Repo) error {
+		report.Results = s.importRows(ctx, tx, rows, opts)
+		for _, res := range report.Results {
+			if res.Status == models.ImportInvalid || res.Status == models.ImportFailed {
+				return errRollback
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errRollback) {
+		report.RolledBack = true
+	} else if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	s.logger.LogTrace(fmt.Sprintf("result is %v", report))
+
+	return &report, nil
+}
+
+func (s service) importRows(ctx context.Context, repo switches.Repo, rows []models.ImportRow, opts models.ImportOptions) []models.ImportRowResult {
+	results := make([]models.ImportRowResult, 0, len(rows))
+	// importKey of a switch -> row which stored it
+	seen := map[string]int{}
+
+	for _, row := range rows {
+		res := models.ImportRowResult{
+			Row:   row.Row,
+			Brand: row.Body.Brand,
+			Name:  row.Body.Name,
+		}
+
+		if opts.Atomic && !opts.DryRun {
+			res.Status, res.Reasons = s.importSavepoint(ctx, repo, row, opts, seen)
+		} else {
+			res.Status, res.Reasons = s.importRow(ctx, repo, row, opts, seen)
+		}
+		switch res.Status {
+		case models.ImportFailed:
+			s.logger.LogError(fmt.Sprintf("import row %d failed: %v", row.Row, res.Reasons))
+		case models.ImportCreated, models.ImportUpdated:
+			// only a stored row makes later ones duplicates, a failed one leaves the switch to them
+			seen[importKey(row.Body)] = row.Row
+		}
+
+		results = append(results, res)
+	}
+
+	return results
+}
+
+// importSavepoint runs a row of an atomic import in a savepoint of its own. A failed statement
+// aborts the whole transaction otherwise and every later row would be reported with that error
+// instead of its real outcome.
+func (s service) importSavepoint(ctx context.Context, repo switches.Repo, row models.ImportRow, opts models.ImportOptions, seen map[string]int) (models.ImportStatus, []string) {
+	var status models.ImportStatus
+	var reasons []string
+
+	err := repo.InTx(ctx, func(tx switches.Repo) error {
+		status, reasons = s.importRow(ctx, tx, row, opts, seen)
+		if status == models.ImportFailed {
+			return errRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errRollback) {
+		return models.ImportFailed, []string{err.Error()}
+	}
+
+	return status, reasons
+}
+
+func (s service) importRow(ctx context.Context, repo switches.Repo, row models.ImportRow, opts models.ImportOptions, seen map[string]int) (models.ImportStatus, []string) {
+	if len(row.ParseErrors) > 0 {
+		return models.ImportInvalid, row.ParseErrors
+	}
+	if reasons := Validate(row.Body); reasons != nil {
+		return models.ImportInvalid, reasons
+	}
+
+	if first, ok := seen[importKey(row.Body)]; ok {
+		return models.ImportSkipped, []string{fmt.Sprintf("duplicate of row %d", first)}
+	}
+
+	switchID, err := repo.GetID(ctx, row.Body.Brand, row.Body.Name)
+	if err != nil {
+		return models.ImportFailed, []string{err.Error()}
+	}
+
+	if switchID != nil {
+		if !opts.UpdateExisting {
+			return models.ImportSkipped, []string{"switch already exists"}
+		}
+		if !opts.DryRun {
+			if _, err := repo.Update(ctx, *switchID, toEntity(row.Body)); err != nil {
+				return models.ImportFailed, []string{err.Error()}
+			}
+		}
+		return models.ImportUpdated, nil
+	}
+
+	if !opts.DryRun {
+		if _, err := repo.AddNew(ctx, toEntity(row.Body)); err != nil {
+			return models.ImportFailed, []string{err.Error()}
+		}
+	}
+
+	return models.ImportCreated, nil
+}
+
+// importKey identifies a switch among rows the same way brand and name are compared in the catalog
+func importKey(body models.SwitchRequestBody) string {
+	return strings.ToLower(body.Brand) + "/" + strings.ToLower(body.Name)
+}
@@ -0,0 +1,198 @@
+package switches_test
+
+import (
+	"context"
+	"errors"
+	coreswitches "kbswitch/internal/core/switches"
+	"kbswitch/internal/core/switches/models"
+	"kbswitch/internal/pkg/switches"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	rows := []models.ImportRow{
+		{Row: 1, Body: models.SwitchRequestBody{Brand: "Gateron", Name: "Yellow", OperatingForce: 50}},
+		{Row: 2, Body: models.SwitchRequestBody{Brand: "Cherry", Name: "Red", OperatingForce: 45}},
+		{Row: 3, Body: models.SwitchRequestBody{Brand: "gateron", Name: "yellow"}},
+		{Row: 4, Body: models.SwitchRequestBody{Brand: "Kailh", OperatingForce: -1}},
+		{Row: 5, ParseErrors: []string{"column 'lifespan': 'x' is not an integer"}},
+	}
+
+	tcases := []struct {
+		opts     models.ImportOptions
+		expected struct {
+			statuses   []models.ImportStatus
+			rolledBack bool
+			stored     int
+		}
+	}{
+		{
+			opts: models.ImportOptions{},
+			expected: struct {
+				statuses   []models.ImportStatus
+				rolledBack bool
+				stored     int
+			}{
+				statuses: []models.ImportStatus{
+					models.ImportCreated, models.ImportSkipped, models.ImportSkipped,
+					models.ImportInvalid, models.ImportInvalid,
+				},
+				stored: 1,
+			},
+		},
+		{
+			opts: models.ImportOptions{UpdateExisting: true},
+			expected: struct {
+				statuses   []models.ImportStatus
+				rolledBack bool
+				stored     int
+			}{
+				statuses: []models.ImportStatus{
+					models.ImportCreated, models.ImportUpdated, models.ImportSkipped,
+					models.ImportInvalid, models.ImportInvalid,
+				},
+				stored: 2,
+			},
+		},
+		{
+			opts: models.ImportOptions{DryRun: true, UpdateExisting: true},
+			expected: struct {
+				statuses   []models.ImportStatus
+				rolledBack bool
+				stored     int
+			}{
+				statuses: []models.ImportStatus{
+					models.ImportCreated, models.ImportUpdated, models.ImportSkipped,
+					models.ImportInvalid, models.ImportInvalid,
+				},
+				stored: 0,
+			},
+		},
+		{
+			opts: models.ImportOptions{Atomic: true},
+			expected: struct {
+				statuses   []models.ImportStatus
+				rolledBack bool
+				stored     int
+			}{
+				statuses: []models.ImportStatus{
+					models.ImportCreated, models.ImportSkipped, models.ImportSkipped,
+					models.ImportInvalid, models.ImportInvalid,
+				},
+				rolledBack: true,
+				stored:     1,
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		stored := 0
+		repo := fakeRepo{
+			getID: func(brand, name string) (*int, error) {
+				if brand == "Cherry" && name == "Red" {
+					return intptr(1), nil
+				}
+				return nil, nil
+			},
+			addNewAction: func(se models.SwitchEntity) (*int, error) {
+				stored++
+				return intptr(2), nil
+			},
+			updateAction: func(i int, se models.SwitchEntity) (*models.SwitchEntity, error) {
+				stored++
+				return &se, nil
+			},
+		}
+
+		unit := switches.New(&fakeLogger{}, repo)
+		res, err := unit.Import(context.Background(), rows, tc.opts)
+
+		assertErrorsEqual("Import", t, nil, err)
+
+		statuses := make([]models.ImportStatus, len(res.Results))
+		for i, r := range res.Results {
+			statuses[i] = r.Status
+		}
+		assertResultsEqual("Import", t, tc.expected.statuses, statuses)
+		assertResultsEqual("Import", t, tc.expected.rolledBack, res.RolledBack)
+		assertResultsEqual("Import", t, tc.expected.stored, stored)
+	}
+}
+
+func TestImportAtomicSavepoints(t *testing.T) {
+	rows := []models.ImportRow{
+		{Row: 1, Body: models.SwitchRequestBody{Brand: "Gateron", Name: "Yellow", OperatingForce: 50}},
+		{Row: 2, Body: models.SwitchRequestBody{Brand: "Cherry", Name: "Red", OperatingForce: 45}},
+	}
+
+	// a failed statement aborts the transaction until its savepoint is rolled back
+	depth, aborted := 0, false
+	repo := fakeRepo{
+		getID: func(brand, name string) (*int, error) {
+			if aborted {
+				return nil, errors.New("current transaction is aborted")
+			}
+			return nil, nil
+		},
+		addNewAction: func(se models.SwitchEntity) (*int, error) {
+			if aborted {
+				return nil, errors.New("current transaction is aborted")
+			}
+			if se.Manufacturer == "Gateron" {
+				aborted = true
+				return nil, errors.New("duplicate key value")
+			}
+			return intptr(2), nil
+		},
+	}
+	repo.inTxAction = func(f fakeRepo, fn func(coreswitches.Repo) error) error {
+		depth++
+		defer func() { depth-- }()
+
+		err := fn(f)
+		if err != nil && depth > 1 {
+			aborted = false
+		}
+		return err
+	}
+
+	unit := switches.New(&fakeLogger{}, repo)
+	res, err := unit.Import(context.Background(), rows, models.ImportOptions{Atomic: true})
+
+	assertErrorsEqual("Import", t, nil, err)
+	assertResultsEqual("Import", t, []models.ImportRowResult{
+		{Row: 1, Brand: "Gateron", Name: "Yellow", Status: models.ImportFailed, Reasons: []string{"duplicate key value"}},
+		{Row: 2, Brand: "Cherry", Name: "Red", Status: models.ImportCreated},
+	}, res.Results)
+	assertResultsEqual("Import", t, true, res.RolledBack)
+}
+
+func TestImportRetriesFailedDuplicate(t *testing.T) {
+	rows := []models.ImportRow{
+		{Row: 1, Body: models.SwitchRequestBody{Brand: "Gateron", Name: "Yellow", OperatingForce: 50}},
+		{Row: 2, Body: models.SwitchRequestBody{Brand: "gateron", Name: "yellow", OperatingForce: 50}},
+		{Row: 3, Body: models.SwitchRequestBody{Brand: "Gateron", Name: "Yellow", OperatingForce: 50}},
+	}
+
+	calls := 0
+	repo := fakeRepo{
+		getID: func(brand, name string) (*int, error) { return nil, nil },
+		addNewAction: func(se models.SwitchEntity) (*int, error) {
+			calls++
+			if calls == 1 {
+				return nil, errors.New("connection reset")
+			}
+			return intptr(calls), nil
+		},
+	}
+
+	unit := switches.New(&fakeLogger{}, repo)
+	res, err := unit.Import(context.Background(), rows, models.ImportOptions{})
+
+	assertErrorsEqual("Import", t, nil, err)
+	assertResultsEqual("Import", t, []models.ImportRowResult{
+		{Row: 1, Brand: "Gateron", Name: "Yellow", Status: models.ImportFailed, Reasons: []string{"connection reset"}},
+		{Row: 2, Brand: "gateron", Name: "yellow", Status: models.ImportCreated},
+		{Row: 3, Brand: "Gateron", Name: "Yellow", Status: models.ImportSkipped, Reasons: []string{"duplicate of row 2"}},
+	}, res.Results)
+}
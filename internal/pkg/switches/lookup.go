@@ -0,0 +1,67 @@
+package switches
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+)
+
+const MaxLookupKeys = 100
+
+var (
+	ErrEmptyLookup   = common.NewError(common.ErrBadRequest, "at least one brand and name pair is required")
+	ErrTooManyKeys   = common.NewError(common.ErrBadRequest, fmt.Sprintf("lookup is limited to %d brand and name pairs", MaxLookupKeys))
+	ErrIncompleteKey = common.NewError(common.ErrBadRequest, "every pair must have both brand and name")
+)
+
+func (s service) Lookup(ctx context.Context, keys []models.SwitchKey) (*models.LookupResult, *common.AppError) {
+	if len(keys) == 0 {
+		s.logger.LogError(ErrEmptyLookup.Error())
+		return nil, &ErrEmptyLookup
+	}
+	if len(keys) > MaxLookupKeys {
+		s.logger.LogError(ErrTooManyKeys.Error())
+		return nil, &ErrTooManyKeys
+	}
+	for _, k := range keys {
+		if k.Brand == "" || k.Name == "" {
+			s.logger.LogError(ErrIncompleteKey.Error())
+			return nil, &ErrIncompleteKey
+		}
+	}
+
+	hits, err := s.repo.Lookup(ctx, keys)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := models.LookupResult{
+		Found:   []models.Switch{},
+		Missing: []models.SwitchKey{},
+	}
+
+	resolved := make([]bool, len(keys))
+	seen := map[int]bool{}
+	for _, hit := range hits {
+		if hit.Index < 0 || hit.Index >= len(keys) {
+			continue
+		}
+		resolved[hit.Index] = true
+
+		if seen[hit.Switch.ID] {
+			continue
+		}
+		seen[hit.Switch.ID] = true
+		res.Found = append(res.Found, toSwitch(hit.Switch))
+	}
+	for i, k := range keys {
+		if !resolved[i] {
+			res.Missing = append(res.Missing, k)
+		}
+	}
+	s.logger.LogTrace(fmt.Sprintf("result is %v", res))
+
+	return &res, nil
+}
@@ -0,0 +1,119 @@
+package switches_test
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+	"kbswitch/internal/pkg/switches"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	red := models.SwitchEntity{ID: 1, Manufacturer: "Cherry", Model: "MX Red"}
+	jade := models.SwitchEntity{ID: 2, Manufacturer: "Kailh", Model: "Box Jade"}
+
+	tcases := []struct {
+		keys     []models.SwitchKey
+		hits     []models.LookupHitEntity
+		expected struct {
+			res  *models.LookupResult
+			err  *common.AppError
+			logs []string
+		}
+	}{
+		{
+			keys: []models.SwitchKey{
+				{Brand: "Kailh", Name: "Box Jade"},
+				{Brand: "Gateron", Name: "Ink"},
+				{Brand: "Cherry", Name: "MX Red"},
+				{Brand: "Cherry", Name: "Red"},
+			},
+			hits: []models.LookupHitEntity{
+				{Index: 0, Switch: jade},
+				{Index: 2, Switch: red},
+				{Index: 3, Switch: red},
+			},
+			expected: struct {
+				res  *models.LookupResult
+				err  *common.AppError
+				logs []string
+			}{
+				res: &models.LookupResult{
+					Found: []models.Switch{
+						{Brand: "Kailh", Name: "Box Jade"},
+						{Brand: "Cherry", Name: "MX Red"},
+					},
+					Missing: []models.SwitchKey{{Brand: "Gateron", Name: "Ink"}},
+				},
+				logs: []string{LogLvlTrace},
+			},
+		},
+		{
+			keys: []models.SwitchKey{{Brand: "Gateron", Name: "Ink"}},
+			expected: struct {
+				res  *models.LookupResult
+				err  *common.AppError
+				logs []string
+			}{
+				res: &models.LookupResult{
+					Found:   []models.Switch{},
+					Missing: []models.SwitchKey{{Brand: "Gateron", Name: "Ink"}},
+				},
+				logs: []string{LogLvlTrace},
+			},
+		},
+		{
+			keys: nil,
+			expected: struct {
+				res  *models.LookupResult
+				err  *common.AppError
+				logs []string
+			}{
+				err:  &switches.ErrEmptyLookup,
+				logs: []string{LogLvlError},
+			},
+		},
+		{
+			keys: make([]models.SwitchKey, switches.MaxLookupKeys+1),
+			expected: struct {
+				res  *models.LookupResult
+				err  *common.AppError
+				logs []string
+			}{
+				err:  &switches.ErrTooManyKeys,
+				logs: []string{LogLvlError},
+			},
+		},
+		{
+			keys: []models.SwitchKey{{Brand: "Cherry"}},
+			expected: struct {
+				res  *models.LookupResult
+				err  *common.AppError
+				logs []string
+			}{
+				err:  &switches.ErrIncompleteKey,
+				logs: []string{LogLvlError},
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		calls := 0
+		repo := fakeRepo{
+			lookupReturner: func(keys []models.SwitchKey) ([]models.LookupHitEntity, error) {
+				calls++
+				return tc.hits, nil
+			},
+		}
+		logger := fakeLogger{}
+
+		res, err := switches.New(&logger, repo).Lookup(context.Background(), tc.keys)
+
+		assertErrorsEqual("Lookup", t, tc.expected.err, err)
+		assertResultsEqual("Lookup", t, tc.expected.res, res)
+		assertLogsEqual("Lookup", t, tc.expected.logs, logger.logs)
+		if err == nil && calls != 1 {
+			t.Errorf("in method Lookup: expected a single repo query, got %d", calls)
+		}
+	}
+}
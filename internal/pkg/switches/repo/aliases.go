@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/switches/models"
+)
+
+// GetAliases implements switches.Repo.
+func (r repo) GetAliases(ctx context.Context, switchID int) ([]models.AliasEntity, error) {
+	result := make([]models.AliasEntity, 0)
+	query := `SELECT id, switch_id, brand, name FROM public.switch_aliases WHERE switch_id = $1 ORDER BY id`
+
+	rows, err := r.pool.Query(ctx, query, switchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a models.AliasEntity
+		if err := rows.Scan(&a.ID, &a.SwitchID, &a.Brand, &a.Name); err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("result is %v", result))
+
+	return result, nil
+}
+
+// AddAlias implements switches.Repo.
+func (r repo) AddAlias(ctx context.Context, alias models.AliasEntity) (*int, error) {
+	query := `INSERT INTO public.switch_aliases (switch_id, brand, name) VALUES ($1, $2, $3) RETURNING id`
+
+	var id int
+	err := r.pool.QueryRow(ctx, query, alias.SwitchID, alias.Brand, alias.Name).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("alias %s/%s added with id %d", alias.Brand, alias.Name, id))
+
+	return &id, nil
+}
+
+// RemoveAlias implements switches.Repo.
+func (r repo) RemoveAlias(ctx context.Context, switchID int, brand, name string) (bool, error) {
+	query := `DELETE FROM public.switch_aliases
+	WHERE switch_id = $1 AND lower(brand) = lower($2) AND lower(name) = lower($3)`
+
+	tag, err := r.pool.Exec(ctx, query, switchID, brand, name)
+	if err != nil {
+		return false, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("removed %d aliases %s/%s", tag.RowsAffected(), brand, name))
+
+	return tag.RowsAffected() > 0, nil
+}
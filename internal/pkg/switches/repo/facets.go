@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/switches/models"
+)
+
+var termFacets = []struct {
+	attr   string
+	column string
+}{
+	{models.AttrProfile, "profile"},
+	{models.AttrTriggerMethod, "triggerMethod"},
+	{models.AttrSoundProfile, "soundProfile"},
+	{models.AttrActuationType, "actuationType"},
+	{models.AttrBrand, "manufacturer"},
+}
+
+var histogramFacets = []struct {
+	attr   string
+	column string
+	width  float64
+}{
+	{models.AttrOperatingForce, "operatingForce", 10},
+	{models.AttrTotalTravel, "totalTravel", 0.5},
+	{models.AttrActivationTravel, "activationTravel", 0.5},
+}
+
+// Facets implements switches.Repo.
+func (r repo) Facets(ctx context.Context, filter models.SwitchFilter) (*models.Facets, error) {
+	result := models.Facets{
+		Terms:      map[string][]models.FacetCount{},
+		Histograms: map[string][]models.HistogramBucket{},
+	}
+
+	for _, f := range termFacets {
+		conditions, args := filterSQL(filter, nil, f.attr)
+		query := fmt.Sprintf(`SELECT coalesce(%[1]s, ''), count(*) FROM public.switches
+		WHERE true%[2]s
+		GROUP BY 1
+		ORDER BY 2 DESC, 1`, f.column, conditions)
+
+		counts, err := r.termCounts(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		result.Terms[f.attr] = counts
+	}
+
+	for _, f := range histogramFacets {
+		conditions, args := filterSQL(filter, []any{f.width}, f.attr)
+		query := fmt.Sprintf(`SELECT floor(%[1]s / $1::float8) * $1::float8 AS bucket, count(*) FROM public.switches
+		WHERE %[1]s IS NOT NULL%[2]s
+		GROUP BY 1
+		ORDER BY 1`, f.column, conditions)
+
+		buckets, err := r.histogram(ctx, query, args, f.width)
+		if err != nil {
+			return nil, err
+		}
+		result.Histograms[f.attr] = buckets
+	}
+	r.logger.LogTrace(fmt.Sprintf("result is %v", result))
+
+	return &result, nil
+}
+
+func (r repo) termCounts(ctx context.Context, query string, args []any) ([]models.FacetCount, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]models.FacetCount, 0)
+	for rows.Next() {
+		var c models.FacetCount
+		if err := rows.Scan(&c.Value, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+func (r repo) histogram(ctx context.Context, query string, args []any, width float64) ([]models.HistogramBucket, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := make([]models.HistogramBucket, 0)
+	for rows.Next() {
+		var b models.HistogramBucket
+		if err := rows.Scan(&b.From, &b.Count); err != nil {
+			return nil, err
+		}
+		b.To = b.From + width
+		buckets = append(buckets, b)
+	}
+
+	return buckets, rows.Err()
+}
@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"fmt"
+	"kbswitch/internal/core/switches/models"
+	"strings"
+)
+
+// filterSQL turns filter into "AND ..." conditions, placeholders continue after already collected args.
+// skip holds attribute names which should not be applied, facets use it to leave out their own dimension
+func filterSQL(filter models.SwitchFilter, args []any, skip ...string) (string, []any) {
+	var sb strings.Builder
+
+	skipped := func(attr string) bool {
+		for _, s := range skip {
+			if s == attr {
+				return true
+			}
+		}
+		return false
+	}
+
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		sb.WriteString(" AND ")
+		sb.WriteString(fmt.Sprintf(cond, len(args)))
+	}
+
+	eq := func(attr, column, value string) {
+		if value != "" && !skipped(attr) {
+			add("lower("+column+") = lower($%d)", value)
+		}
+	}
+
+	eq(models.AttrBrand, "manufacturer", filter.Brand)
+	eq(models.AttrProfile, "profile", filter.Profile)
+	eq(models.AttrTriggerMethod, "triggerMethod", filter.TriggerMethod)
+	eq(models.AttrSoundProfile, "soundProfile", filter.SoundProfile)
+	eq(models.AttrActuationType, "actuationType", filter.ActuationType)
+
+	if !skipped(models.AttrOperatingForce) {
+		if filter.MinOperatingForce != nil {
+			add("operatingForce >= $%d", *filter.MinOperatingForce)
+		}
+		if filter.MaxOperatingForce != nil {
+			add("operatingForce <= $%d", *filter.MaxOperatingForce)
+		}
+	}
+	if !skipped(models.AttrTotalTravel) {
+		if filter.MinTotalTravel != nil {
+			add("totalTravel >= $%d", *filter.MinTotalTravel)
+		}
+		if filter.MaxTotalTravel != nil {
+			add("totalTravel <= $%d", *filter.MaxTotalTravel)
+		}
+	}
+
+	return sb.String(), args
+}
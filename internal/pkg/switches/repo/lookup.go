@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/switches/models"
+)
+
+// Lookup implements switches.Repo.
+func (r repo) Lookup(ctx context.Context, keys []models.SwitchKey) ([]models.LookupHitEntity, error) {
+	brands := make([]string, len(keys))
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		brands[i] = k.Brand
+		names[i] = k.Name
+	}
+
+	// same resolution as GetID, canonical brand and name wins over an alias
+	query := `WITH wanted AS (
+		SELECT brand, name, ord - 1 AS ord
+		FROM unnest($1::text[], $2::text[]) WITH ORDINALITY AS w(brand, name, ord)
+	), resolved AS (
+		SELECT DISTINCT ON (wanted.ord) wanted.ord, ids.switch_id
+		FROM wanted
+		JOIN (
+			SELECT id AS switch_id, manufacturer AS brand, model AS name, 0 AS priority FROM public.switches
+			UNION ALL
+			SELECT switch_id, brand, name, 1 AS priority FROM public.switch_aliases
+		) ids ON lower(ids.brand) = lower(wanted.brand) AND lower(ids.name) = lower(wanted.name)
+		ORDER BY wanted.ord, ids.priority
+	)
+	SELECT ` + switchColumns + `, resolved.ord
+	FROM resolved
+	JOIN public.switches ON switches.id = resolved.switch_id
+	ORDER BY resolved.ord`
+
+	rows, err := r.pool.Query(ctx, query, brands, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]models.LookupHitEntity, 0, len(keys))
+	for rows.Next() {
+		var hit models.LookupHitEntity
+		if err := scanSwitch(rows, &hit.Switch, &hit.Index); err != nil {
+			return nil, err
+		}
+		result = append(result, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d of %d keys resolved", len(result), len(keys)))
+
+	return result, nil
+}
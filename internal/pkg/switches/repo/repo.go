@@ -2,12 +2,15 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"kbswitch/internal/app"
 	"kbswitch/internal/core/common/database"
 	"kbswitch/internal/core/common/logging"
 	"kbswitch/internal/core/switches"
 	"kbswitch/internal/core/switches/models"
+
+	"github.com/jackc/pgx/v5"
 )
 
 func New(logger logging.Logger, pool database.DBPool) switches.Repo {
@@ -23,6 +26,19 @@ func NewObsolete(cfg app.DbConfig) switches.Repo {
 	}
 }
 
+// column order must match scanSwitch
+const switchColumns = `id, manufacturer, actuationType, lifespan, model, image, operatingForce,
+	activationTravel, totalTravel, soundProfile, triggerMethod, profile, description`
+
+// scanSwitch reads switchColumns into e, extra destinations are scanned after them
+func scanSwitch(row pgx.Row, e *models.SwitchEntity, extra ...any) error {
+	dest := []any{&e.ID, &e.Manufacturer, &e.ActuationType, &e.Lifespan,
+		&e.Model, &e.Image, &e.OperatingForce, &e.ActivationTravel, &e.TotalTravel,
+		&e.SoundProfile, &e.TriggerMethod, &e.Profile, &e.Description}
+
+	return row.Scan(append(dest, extra...)...)
+}
+
 type repo struct {
 	logger logging.Logger
 	pool   database.DBPool
@@ -30,8 +46,43 @@ type repo struct {
 }
 
 // AddNew implements switches.Repo.
-func (r repo) AddNew(context.Context, models.SwitchEntity) (*int, error) {
-	panic("unimplemented")
+func (r repo) AddNew(ctx context.Context, e models.SwitchEntity) (*int, error) {
+	query := `INSERT INTO public.switches (manufacturer, actuationType, lifespan, model, image, operatingForce,
+		activationTravel, totalTravel, soundProfile, triggerMethod, profile, description)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	RETURNING id`
+
+	var id int
+	err := r.pool.QueryRow(ctx, query, e.Manufacturer, e.ActuationType, e.Lifespan, e.Model, e.Image,
+		e.OperatingForce, e.ActivationTravel, e.TotalTravel, e.SoundProfile, e.TriggerMethod,
+		e.Profile, e.Description).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("switch %s/%s added with id %d", e.Manufacturer, e.Model, id))
+
+	return &id, nil
+}
+
+// InTx implements switches.Repo.
+func (r repo) InTx(ctx context.Context, fn func(switches.Repo) error) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// does nothing when transaction is already committed
+	defer tx.Rollback(ctx)
+
+	err = fn(repo{
+		logger: r.logger,
+		pool:   tx,
+		cfg:    r.cfg,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 // GetAll implements switches.Repo.
@@ -69,16 +120,14 @@ func (r repo) GetAll(ctx context.Context) ([]models.SwitchEntity, error) {
 	// return result, nil
 
 	result := make([]models.SwitchEntity, 0)
-	query := `SELECT * FROM public.switches`
+	query := `SELECT ` + switchColumns + ` FROM public.switches`
 
 	rows, _ := r.pool.Query(ctx, query)
 	defer rows.Close()
 
 	for rows.Next() {
 		var r models.SwitchEntity
-		rows.Scan(&r.ID, &r.Manufacturer, &r.ActuationType, &r.Lifespan,
-			&r.Model, &r.Image, &r.OperatingForce, &r.ActivationTravel, &r.TotalTravel,
-			&r.SoundProfile, &r.TriggerMethod, &r.Profile)
+		scanSwitch(rows, &r)
 
 		result = append(result, r)
 	}
@@ -89,20 +138,75 @@ func (r repo) GetAll(ctx context.Context) ([]models.SwitchEntity, error) {
 
 // GetID implements switches.Repo.
 func (r repo) GetID(ctx context.Context, brand string, name string) (*int, error) {
-	panic("unimplemented")
+	// canonical brand and name wins over an alias
+	query := `SELECT id, 0 AS priority FROM public.switches
+		WHERE lower(manufacturer) = lower($1) AND lower(model) = lower($2)
+	UNION ALL
+	SELECT switch_id, 1 AS priority FROM public.switch_aliases
+		WHERE lower(brand) = lower($1) AND lower(name) = lower($2)
+	ORDER BY priority
+	LIMIT 1`
+
+	var id, priority int
+	err := r.pool.QueryRow(ctx, query, brand, name).Scan(&id, &priority)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%s/%s resolved to id %d", brand, name, id))
+
+	return &id, nil
 }
 
 // GetSingle implements switches.Repo.
-func (r repo) GetSingle(context.Context, int) (*models.SwitchEntity, error) {
-	panic("unimplemented")
+func (r repo) GetSingle(ctx context.Context, id int) (*models.SwitchEntity, error) {
+	query := `SELECT ` + switchColumns + ` FROM public.switches WHERE id = $1`
+
+	var result models.SwitchEntity
+	err := scanSwitch(r.pool.QueryRow(ctx, query, id), &result)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("result is %v", result))
+
+	return &result, nil
 }
 
 // Remove implements switches.Repo.
-func (r repo) Remove(context.Context, int) error {
-	panic("unimplemented")
+func (r repo) Remove(ctx context.Context, id int) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM public.switches WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("switch %d removed", id))
+
+	return nil
 }
 
 // Update implements switches.Repo.
-func (r repo) Update(context.Context, int, models.SwitchEntity) (*models.SwitchEntity, error) {
-	panic("unimplemented")
+func (r repo) Update(ctx context.Context, id int, e models.SwitchEntity) (*models.SwitchEntity, error) {
+	query := `UPDATE public.switches SET manufacturer = $2, actuationType = $3, lifespan = $4, model = $5,
+		image = $6, operatingForce = $7, activationTravel = $8, totalTravel = $9, soundProfile = $10,
+		triggerMethod = $11, profile = $12, description = $13
+	WHERE id = $1
+	RETURNING ` + switchColumns
+
+	var result models.SwitchEntity
+	err := scanSwitch(r.pool.QueryRow(ctx, query, id, e.Manufacturer, e.ActuationType, e.Lifespan, e.Model,
+		e.Image, e.OperatingForce, e.ActivationTravel, e.TotalTravel, e.SoundProfile, e.TriggerMethod,
+		e.Profile, e.Description), &result)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("result is %v", result))
+
+	return &result, nil
 }
@@ -6,6 +6,7 @@ import (
 	"kbswitch/internal/core/switches/models"
 	"kbswitch/internal/pkg/switches/repo"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/jackc/pgx/v5"
@@ -60,6 +61,7 @@ func (f *fakeLogger) LogTrace(msg string) {
 
 type fakePool struct {
 	getAllReturner func() (pgx.Rows, error)
+	queryReturner  func(string, ...any) (pgx.Rows, error)
 	// getAllReturner func() (pgxmock.Rows, error)
 }
 
@@ -68,8 +70,16 @@ func (f fakePool) Exec(ctx context.Context, sql string, args ...any) (pgconn.Com
 	panic("unimplemented")
 }
 
+// Begin implements database.DBPool.
+func (f fakePool) Begin(ctx context.Context) (pgx.Tx, error) {
+	panic("unimplemented")
+}
+
 // Query implements database.DBPool.
 func (f fakePool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if f.queryReturner != nil {
+		return f.queryReturner(sql, args...)
+	}
 	return f.getAllReturner()
 }
 
@@ -98,11 +108,11 @@ func TestGetAll(t *testing.T) {
 						"id", "manufacturer", "actuationType",
 						"lifespan", "model", "image", "operatingForce",
 						"activationTravel", "totalTravel", "soundProfile",
-						"triggerMethod", "profile",
+						"triggerMethod", "profile", "description",
 					}
 
-					rows := c.NewRows(columns).AddRow(1, "mn", "at", 10, "mm", []byte{1, 1}, 30, float64(30), float64(30), "sp", "tm", "p").
-						AddRow(2, "mn2", "at2", 20, "mm2", []byte{2, 2}, 40, float64(40), float64(40), "sp2", "tm2", "p2").
+					rows := c.NewRows(columns).AddRow(1, "mn", "at", 10, "mm", []byte{1, 1}, 30, float64(30), float64(30), "sp", "tm", "p", "d").
+						AddRow(2, "mn2", "at2", 20, "mm2", []byte{2, 2}, 40, float64(40), float64(40), "sp2", "tm2", "p2", "d2").
 						Kind()
 
 					return rows, nil
@@ -128,6 +138,7 @@ func TestGetAll(t *testing.T) {
 						SoundProfile:     "sp",
 						TriggerMethod:    "tm",
 						Profile:          "p",
+						Description:      "d",
 					},
 					{
 						ID:               2,
@@ -142,6 +153,7 @@ func TestGetAll(t *testing.T) {
 						SoundProfile:     "sp2",
 						TriggerMethod:    "tm2",
 						Profile:          "p2",
+						Description:      "d2",
 					},
 				},
 				err:  nil,
@@ -163,3 +175,170 @@ func TestGetAll(t *testing.T) {
 
 	}
 }
+
+func TestSearch(t *testing.T) {
+	force := 50
+	var gotArgs []any
+
+	pool := fakePool{
+		queryReturner: func(sql string, args ...any) (pgx.Rows, error) {
+			gotArgs = args
+
+			c, _ := pgxmock.NewConn()
+			defer c.Close(context.Background())
+
+			columns := []string{
+				"id", "manufacturer", "actuationType",
+				"lifespan", "model", "image", "operatingForce",
+				"activationTravel", "totalTravel", "soundProfile",
+				"triggerMethod", "profile", "description",
+				"rank", "brandHighlight", "nameHighlight", "descriptionHighlight",
+			}
+
+			rows := c.NewRows(columns).
+				AddRow(1, "Cherry", "Linear", 100, "Red", []byte{}, 45, float64(2), float64(4), "Normal", "mechanical", "MX", "smooth",
+					float64(0.5), "Cherry", "\ue000Red\ue001", "smooth <img src=x onerror=alert(1)> & \ue000red\ue001").
+				Kind()
+
+			return rows, nil
+		},
+	}
+
+	logger := fakeLogger{}
+	sut := repo.New(&logger, pool)
+	got, err := sut.Search(context.Background(), "red", models.SwitchFilter{Profile: "MX", MaxOperatingForce: &force}, 5)
+	if err != nil {
+		t.Fatalf("in method Search: unexpected error %v", err)
+	}
+
+	wantArgs := []any{"red", "StartSel=\"\ue000\", StopSel=\"\ue001\", HighlightAll=true", "\ue000\ue001", "MX", 50, 5}
+	assertResultsEqual("Search", t, wantArgs, gotArgs)
+
+	want := []models.SearchHitEntity{
+		{
+			SwitchEntity: models.SwitchEntity{
+				ID:               1,
+				Manufacturer:     "Cherry",
+				ActuationType:    "Linear",
+				Lifespan:         100,
+				Model:            "Red",
+				Image:            []byte{},
+				OperatingForce:   45,
+				ActivationTravel: 2,
+				TotalTravel:      4,
+				SoundProfile:     "Normal",
+				TriggerMethod:    "mechanical",
+				Profile:          "MX",
+				Description:      "smooth",
+			},
+			Rank:                 0.5,
+			BrandHighlight:       "Cherry",
+			NameHighlight:        "<mark>Red</mark>",
+			DescriptionHighlight: "smooth &lt;img src=x onerror=alert(1)&gt; &amp; <mark>red</mark>",
+		},
+	}
+	assertResultsEqual("Search", t, want, got)
+	assertLogsEqual("Search", t, []string{LogLvlTrace}, logger.logs)
+}
+
+func TestFacets(t *testing.T) {
+	force := 60
+	queries := map[string][]any{}
+
+	pool := fakePool{
+		queryReturner: func(sql string, args ...any) (pgx.Rows, error) {
+			c, _ := pgxmock.NewConn()
+			defer c.Close(context.Background())
+
+			if strings.Contains(sql, "floor(") {
+				column := strings.Fields(sql)[1][len("floor("):]
+				queries[column] = args
+
+				return c.NewRows([]string{"bucket", "count"}).
+					AddRow(float64(40), 2).
+					AddRow(float64(50), 1).
+					Kind(), nil
+			}
+
+			column := strings.TrimSuffix(strings.Fields(sql)[1][len("coalesce("):], ",")
+			queries[column] = args
+
+			return c.NewRows([]string{"value", "count"}).
+				AddRow("MX", 2).
+				AddRow("chocov1", 1).
+				Kind(), nil
+		},
+	}
+
+	logger := fakeLogger{}
+	sut := repo.New(&logger, pool)
+	got, err := sut.Facets(context.Background(), models.SwitchFilter{Profile: "MX", MaxOperatingForce: &force})
+	if err != nil {
+		t.Fatalf("in method Facets: unexpected error %v", err)
+	}
+
+	// filter on the counted attribute is left out, others are applied
+	wantArgs := map[string][]any{
+		"profile":          {60},
+		"triggerMethod":    {"MX", 60},
+		"soundProfile":     {"MX", 60},
+		"actuationType":    {"MX", 60},
+		"manufacturer":     {"MX", 60},
+		"operatingForce":   {float64(10), "MX"},
+		"totalTravel":      {0.5, "MX", 60},
+		"activationTravel": {0.5, "MX", 60},
+	}
+	assertResultsEqual("Facets", t, wantArgs, queries)
+
+	assertResultsEqual("Facets", t,
+		[]models.FacetCount{{Value: "MX", Count: 2}, {Value: "chocov1", Count: 1}},
+		got.Terms[models.AttrProfile])
+	assertResultsEqual("Facets", t,
+		[]models.HistogramBucket{{From: 40, To: 50, Count: 2}, {From: 50, To: 60, Count: 1}},
+		got.Histograms[models.AttrOperatingForce])
+}
+
+func TestLookup(t *testing.T) {
+	var gotArgs []any
+	pool := fakePool{
+		queryReturner: func(sql string, args ...any) (pgx.Rows, error) {
+			gotArgs = args
+			c, _ := pgxmock.NewConn()
+			defer c.Close(context.Background())
+
+			columns := []string{
+				"id", "manufacturer", "actuationType",
+				"lifespan", "model", "image", "operatingForce",
+				"activationTravel", "totalTravel", "soundProfile",
+				"triggerMethod", "profile", "description", "ord",
+			}
+
+			return c.NewRows(columns).
+				AddRow(1, "Cherry", "at", 10, "MX Red", []byte{}, 45, float64(2), float64(4), "sp", "tm", "p", "d", 0).
+				AddRow(1, "Cherry", "at", 10, "MX Red", []byte{}, 45, float64(2), float64(4), "sp", "tm", "p", "d", 2).
+				Kind(), nil
+		},
+	}
+	logger := fakeLogger{}
+
+	keys := []models.SwitchKey{
+		{Brand: "Cherry", Name: "MX Red"},
+		{Brand: "Kailh", Name: "Box Jade"},
+		{Brand: "Cherry", Name: "Red"},
+	}
+	res, err := repo.New(&logger, pool).Lookup(context.Background(), keys)
+
+	assertErrorReturned("Lookup", t, nil, err)
+	assertResultsEqual("Lookup", t, []any{
+		[]string{"Cherry", "Kailh", "Cherry"},
+		[]string{"MX Red", "Box Jade", "Red"},
+	}, gotArgs)
+
+	sw := models.SwitchEntity{
+		ID: 1, Manufacturer: "Cherry", ActuationType: "at", Lifespan: 10, Model: "MX Red", Image: []byte{},
+		OperatingForce: 45, ActivationTravel: 2, TotalTravel: 4, SoundProfile: "sp", TriggerMethod: "tm",
+		Profile: "p", Description: "d",
+	}
+	assertResultsEqual("Lookup", t, []models.LookupHitEntity{{Index: 0, Switch: sw}, {Index: 2, Switch: sw}}, res)
+	assertLogsEqual("Lookup", t, []string{LogLvlTrace}, logger.logs)
+}
@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"kbswitch/internal/core/switches/models"
+	"strings"
+)
+
+// matches are delimited by private use characters, which survive html escaping, so only the marks become markup
+const (
+	startSel = "\ue000"
+	stopSel  = "\ue001"
+)
+
+var headlineOptions = fmt.Sprintf(`StartSel="%s", StopSel="%s", HighlightAll=true`, startSel, stopSel)
+
+// highlight escapes the headline and turns match delimiters into <mark> tags
+func highlight(headline string) string {
+	return strings.NewReplacer(startSel, "<mark>", stopSel, "</mark>").Replace(html.EscapeString(headline))
+}
+
+// Search implements switches.Repo.
+func (r repo) Search(ctx context.Context, query string, filter models.SwitchFilter, limit int) ([]models.SearchHitEntity, error) {
+	result := make([]models.SearchHitEntity, 0)
+
+	// delimiters are taken out of the text first, so users can't make their own marks
+	conditions, args := filterSQL(filter, []any{query, headlineOptions, startSel + stopSel})
+	args = append(args, limit)
+
+	// switches are also found by their aliases, alias match ranks as well as a direct one
+	sql := fmt.Sprintf(`SELECT %s,
+		greatest(
+			ts_rank_cd(search, q),
+			coalesce((SELECT max(ts_rank_cd(a.search, q)) FROM public.switch_aliases a
+				WHERE a.switch_id = switches.id AND a.search @@ q), 0)
+		) AS rank,
+		ts_headline('english', translate(coalesce(manufacturer, ''), $3, ''), q, $2),
+		ts_headline('english', translate(coalesce(model, ''), $3, ''), q, $2),
+		ts_headline('english', translate(description, $3, ''), q, $2)
+	FROM public.switches, websearch_to_tsquery('english', $1) q
+	WHERE (search @@ q OR EXISTS (
+		SELECT 1 FROM public.switch_aliases a WHERE a.switch_id = switches.id AND a.search @@ q
+	))%s
+	ORDER BY rank DESC, id
+	LIMIT $%d`, switchColumns, conditions, len(args))
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hit models.SearchHitEntity
+		err := scanSwitch(rows, &hit.SwitchEntity, &hit.Rank,
+			&hit.BrandHighlight, &hit.NameHighlight, &hit.DescriptionHighlight)
+		if err != nil {
+			return nil, err
+		}
+		hit.BrandHighlight = highlight(hit.BrandHighlight)
+		hit.NameHighlight = highlight(hit.NameHighlight)
+		hit.DescriptionHighlight = highlight(hit.DescriptionHighlight)
+
+		result = append(result, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("search for %q found %d switches", query, len(result)))
+
+	return result, nil
+}
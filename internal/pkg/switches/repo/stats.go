@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/switches/models"
+	"strings"
+)
+
+const topSpringWeights = 10
+
+// distributionSQL gives select expressions matching scan order of distributionDest
+func distributionSQL(column string) string {
+	exprs := []string{
+		"min(%[1]s)",
+		"max(%[1]s)",
+		"avg(%[1]s)",
+		"percentile_cont(0.5) WITHIN GROUP (ORDER BY %[1]s)",
+		"percentile_cont(0.25) WITHIN GROUP (ORDER BY %[1]s)",
+		"percentile_cont(0.75) WITHIN GROUP (ORDER BY %[1]s)",
+		"percentile_cont(0.9) WITHIN GROUP (ORDER BY %[1]s)",
+	}
+	for i, e := range exprs {
+		exprs[i] = fmt.Sprintf("coalesce("+e+", 0)::float8", column)
+	}
+
+	return strings.Join(exprs, ", ")
+}
+
+func distributionDest(d *models.Distribution) []any {
+	return []any{&d.Min, &d.Max, &d.Avg, &d.Median, &d.P25, &d.P75, &d.P90}
+}
+
+// Stats implements switches.Repo.
+func (r repo) Stats(ctx context.Context) (*models.CatalogStats, error) {
+	var result models.CatalogStats
+
+	err := r.pool.QueryRow(ctx, `SELECT count(*) FROM public.switches`).Scan(&result.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	result.PerBrand, err = r.termCounts(ctx, `SELECT coalesce(manufacturer, ''), count(*) FROM public.switches
+	GROUP BY 1 ORDER BY 2 DESC, 1`, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result.PerProfile, err = r.termCounts(ctx, `SELECT coalesce(profile, ''), count(*) FROM public.switches
+	GROUP BY 1 ORDER BY 2 DESC, 1`, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.PerActuationType, err = r.actuationStats(ctx); err != nil {
+		return nil, err
+	}
+	if result.SpringWeights, err = r.springWeights(ctx); err != nil {
+		return nil, err
+	}
+	if result.AddedPerMonth, err = r.addedPerMonth(ctx); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("result is %v", result))
+
+	return &result, nil
+}
+
+func (r repo) actuationStats(ctx context.Context) ([]models.ActuationStats, error) {
+	query := fmt.Sprintf(`SELECT coalesce(actuationType, ''), count(*), %s, %s, %s
+	FROM public.switches
+	GROUP BY 1
+	ORDER BY 2 DESC, 1`,
+		distributionSQL("operatingForce"), distributionSQL("totalTravel"), distributionSQL("activationTravel"))
+
+	rows, err := r.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]models.ActuationStats, 0)
+	for rows.Next() {
+		var s models.ActuationStats
+		dest := []any{&s.ActuationType, &s.Count}
+		dest = append(dest, distributionDest(&s.OperatingForce)...)
+		dest = append(dest, distributionDest(&s.TotalTravel)...)
+		dest = append(dest, distributionDest(&s.ActivationTravel)...)
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+
+	return result, rows.Err()
+}
+
+func (r repo) springWeights(ctx context.Context) ([]models.SpringWeightCount, error) {
+	query := `SELECT operatingForce, count(*) FROM public.switches
+	WHERE operatingForce IS NOT NULL
+	GROUP BY 1
+	ORDER BY 2 DESC, 1
+	LIMIT $1`
+
+	rows, err := r.pool.Query(ctx, query, topSpringWeights)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]models.SpringWeightCount, 0)
+	for rows.Next() {
+		var c models.SpringWeightCount
+		if err := rows.Scan(&c.OperatingForce, &c.Count); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+
+	return result, rows.Err()
+}
+
+func (r repo) addedPerMonth(ctx context.Context) ([]models.MonthCount, error) {
+	query := `SELECT date_trunc('month', created_at), count(*) FROM public.switches
+	GROUP BY 1
+	ORDER BY 1`
+
+	rows, err := r.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]models.MonthCount, 0)
+	for rows.Next() {
+		var c models.MonthCount
+		if err := rows.Scan(&c.Month, &c.Count); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,38 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/switches/models"
+)
+
+// Stream implements switches.Repo.
+func (r repo) Stream(ctx context.Context, filter models.SwitchFilter, fn func(models.SwitchEntity) error) error {
+	conditions, args := filterSQL(filter, nil)
+	query := fmt.Sprintf(`SELECT %s FROM public.switches WHERE true%s ORDER BY id`, switchColumns, conditions)
+
+	// pgx reads rows from the connection as they are consumed, so only one row is held at a time
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var e models.SwitchEntity
+		if err := scanSwitch(rows, &e); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("streamed %d switches", count))
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package switches
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+	"strings"
+)
+
+var ErrEmptyQuery = common.NewError(common.ErrBadRequest, "search query is empty")
+
+func (s service) Search(ctx context.Context, query string, filter models.SwitchFilter, limit int) ([]models.SearchResult, *common.AppError) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		s.logger.LogError("search query was empty")
+		return nil, &ErrEmptyQuery
+	}
+	if limit <= 0 {
+		s.logger.LogError(fmt.Sprintf("invalid search limit %d", limit))
+		return nil, &ErrInvalidLimit
+	}
+
+	resp, err := s.repo.Search(ctx, query, filter, limit)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := make([]models.SearchResult, 0, len(resp))
+	for _, hit := range resp {
+		res = append(res, models.SearchResult{
+			Switch: toSwitch(hit.SwitchEntity),
+			Rank:   hit.Rank,
+			Highlights: map[string]string{
+				models.AttrBrand:       hit.BrandHighlight,
+				models.AttrName:        hit.NameHighlight,
+				models.AttrDescription: hit.DescriptionHighlight,
+			},
+		})
+	}
+	s.logger.LogTrace(fmt.Sprintf("result is %v", res))
+
+	return res, nil
+}
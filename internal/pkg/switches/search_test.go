@@ -0,0 +1,96 @@
+package switches_test
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+	"kbswitch/internal/pkg/switches"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	tcases := []struct {
+		repo     fakeRepo
+		logger   fakeLogger
+		query    string
+		limit    int
+		expected struct {
+			res  []models.SearchResult
+			err  *common.AppError
+			logs []string
+		}
+	}{
+		{
+			repo:  fakeRepo{},
+			query: "   ",
+			limit: 10,
+			expected: struct {
+				res  []models.SearchResult
+				err  *common.AppError
+				logs []string
+			}{
+				err:  &switches.ErrEmptyQuery,
+				logs: []string{LogLvlError},
+			},
+		},
+		{
+			repo: fakeRepo{
+				searchReturner: func(s string, sf models.SwitchFilter, i int) ([]models.SearchHitEntity, error) {
+					return nil, errTest
+				},
+			},
+			query: "red",
+			limit: 10,
+			expected: struct {
+				res  []models.SearchResult
+				err  *common.AppError
+				logs []string
+			}{
+				err:  common.Wrap(errTest),
+				logs: []string{LogLvlError},
+			},
+		},
+		{
+			repo: fakeRepo{
+				searchReturner: func(s string, sf models.SwitchFilter, i int) ([]models.SearchHitEntity, error) {
+					return []models.SearchHitEntity{
+						{
+							SwitchEntity:  models.SwitchEntity{Manufacturer: "Cherry", Model: "Red"},
+							Rank:          0.3,
+							NameHighlight: "<mark>Red</mark>",
+						},
+					}, nil
+				},
+			},
+			query: " red ",
+			limit: 10,
+			expected: struct {
+				res  []models.SearchResult
+				err  *common.AppError
+				logs []string
+			}{
+				res: []models.SearchResult{
+					{
+						Switch: models.Switch{Brand: "Cherry", Name: "Red"},
+						Rank:   0.3,
+						Highlights: map[string]string{
+							models.AttrBrand:       "",
+							models.AttrName:        "<mark>Red</mark>",
+							models.AttrDescription: "",
+						},
+					},
+				},
+				logs: []string{LogLvlTrace},
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		unit := switches.New(&tc.logger, tc.repo)
+		res, err := unit.Search(context.Background(), tc.query, models.SwitchFilter{}, tc.limit)
+
+		assertErrorsEqual("Search", t, tc.expected.err, err)
+		assertResultsEqual("Search", t, tc.expected.res, res)
+		assertLogsEqual("Search", t, tc.expected.logs, tc.logger.logs)
+	}
+}
@@ -0,0 +1,187 @@
+package switches
+
+import (
+	"context"
+	"fmt"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidWeights = common.NewError(common.ErrBadRequest, "similarity weights must be non-negative and at least one must be positive")
+	ErrInvalidLimit   = common.NewError(common.ErrBadRequest, "limit must be a positive number")
+	ErrUnknownWeight  = common.NewError(common.ErrBadRequest, "similarity weights can be given only for known attributes")
+)
+
+type numericAttr struct {
+	name  string
+	value func(models.Switch) float64
+}
+
+type categoryAttr struct {
+	name  string
+	value func(models.Switch) string
+}
+
+var numericAttrs = []numericAttr{
+	{models.AttrOperatingForce, func(s models.Switch) float64 { return float64(s.OperatingForce) }},
+	{models.AttrActivationTravel, func(s models.Switch) float64 { return s.ActivationTravel }},
+	{models.AttrTotalTravel, func(s models.Switch) float64 { return s.TotalTravel }},
+	{models.AttrLifespan, func(s models.Switch) float64 { return float64(s.Lifespan) }},
+}
+
+var categoryAttrs = []categoryAttr{
+	{models.AttrProfile, func(s models.Switch) string { return s.Profile }},
+	{models.AttrTriggerMethod, func(s models.Switch) string { return s.TriggerMethod }},
+	{models.AttrSoundProfile, func(s models.Switch) string { return s.SoundProfile }},
+	{models.AttrActuationType, func(s models.Switch) string { return s.ActuationType }},
+}
+
+func (s service) GetSimilar(ctx context.Context, brand, name string, limit int, weights models.SimilarityWeights) ([]models.SimilarSwitch, *common.AppError) {
+	if limit <= 0 {
+		s.logger.LogError(fmt.Sprintf("invalid similarity limit %d", limit))
+		return nil, &ErrInvalidLimit
+	}
+	if !knownWeights(weights) {
+		s.logger.LogError(fmt.Sprintf("unknown similarity weights %v", weights))
+		return nil, &ErrUnknownWeight
+	}
+	if !validWeights(weights) {
+		s.logger.LogError(fmt.Sprintf("invalid similarity weights %v", weights))
+		return nil, &ErrInvalidWeights
+	}
+
+	target, e := s.GetSingle(ctx, brand, name)
+	if e != nil {
+		return nil, e
+	}
+
+	catalog, e := s.GetAll(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	res := rankSimilar(*target, catalog, weights)
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	s.logger.LogTrace(fmt.Sprintf("result is %v", res))
+
+	return res, nil
+}
+
+// knownWeights tells whether every weight belongs to a compared attribute,
+// weights of anything else would count towards the total without ever scoring
+func knownWeights(weights models.SimilarityWeights) bool {
+	for name := range weights {
+		known := slices.ContainsFunc(numericAttrs, func(a numericAttr) bool { return a.name == name }) ||
+			slices.ContainsFunc(categoryAttrs, func(a categoryAttr) bool { return a.name == name })
+		if !known {
+			return false
+		}
+	}
+	return true
+}
+
+func validWeights(weights models.SimilarityWeights) bool {
+	total := 0.0
+	for _, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return false
+		}
+		total += w
+	}
+
+	return total > 0
+}
+
+func sameSwitch(a, b models.Switch) bool {
+	return strings.EqualFold(a.Brand, b.Brand) && strings.EqualFold(a.Name, b.Name)
+}
+
+// rankSimilar scores every switch of the catalog against target and returns them best first.
+// numeric attributes are min-max normalized over the catalog so each one is on the 0..1 scale
+func rankSimilar(target models.Switch, catalog []models.Switch, weights models.SimilarityWeights) []models.SimilarSwitch {
+	ranges := make(map[string]float64, len(numericAttrs))
+	for _, attr := range numericAttrs {
+		lo, hi := attr.value(target), attr.value(target)
+		for _, item := range catalog {
+			v := attr.value(item)
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+		ranges[attr.name] = hi - lo
+	}
+
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+
+	res := make([]models.SimilarSwitch, 0, len(catalog))
+	for _, item := range catalog {
+		if sameSwitch(target, item) {
+			continue
+		}
+
+		matches := make([]models.AttributeMatch, 0, len(numericAttrs)+len(categoryAttrs))
+		for _, attr := range numericAttrs {
+			sim := 1.0
+			if r := ranges[attr.name]; r > 0 {
+				sim = 1 - math.Abs(attr.value(target)-attr.value(item))/r
+			}
+			matches = append(matches, match(attr.name, sim, weights[attr.name], total))
+		}
+		for _, attr := range categoryAttrs {
+			sim := 0.0
+			if strings.EqualFold(strings.TrimSpace(attr.value(target)), strings.TrimSpace(attr.value(item))) {
+				sim = 1
+			}
+			matches = append(matches, match(attr.name, sim, weights[attr.name], total))
+		}
+
+		score := 0.0
+		explained := make([]models.AttributeMatch, 0, len(matches))
+		for _, m := range matches {
+			if m.Weight == 0 {
+				continue
+			}
+			score += m.Contribution
+			explained = append(explained, m)
+		}
+		sort.SliceStable(explained, func(i, j int) bool {
+			return explained[i].Contribution > explained[j].Contribution
+		})
+
+		res = append(res, models.SimilarSwitch{
+			Switch:  item,
+			Score:   score,
+			Matches: explained,
+		})
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		if res[i].Switch.Brand != res[j].Switch.Brand {
+			return res[i].Switch.Brand < res[j].Switch.Brand
+		}
+		return res[i].Switch.Name < res[j].Switch.Name
+	})
+
+	return res
+}
+
+func match(attr string, similarity, weight, total float64) models.AttributeMatch {
+	return models.AttributeMatch{
+		Attribute:    attr,
+		Similarity:   similarity,
+		Weight:       weight,
+		Contribution: weight * similarity / total,
+	}
+}
@@ -0,0 +1,151 @@
+package switches_test
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+	"kbswitch/internal/pkg/switches"
+	"testing"
+)
+
+func TestGetSimilar(t *testing.T) {
+	catalog := []models.SwitchEntity{
+		{ID: 1, Manufacturer: "Gateron", Model: "Yellow", OperatingForce: 50, ActivationTravel: 2, TotalTravel: 4, Lifespan: 50, Profile: "MX", TriggerMethod: "mechanical", SoundProfile: "Normal", ActuationType: "Linear"},
+		{ID: 2, Manufacturer: "Cherry", Model: "Red", OperatingForce: 45, ActivationTravel: 2, TotalTravel: 4, Lifespan: 100, Profile: "MX", TriggerMethod: "mechanical", SoundProfile: "Normal", ActuationType: "Linear"},
+		{ID: 3, Manufacturer: "Kailh", Model: "Choc White", OperatingForce: 70, ActivationTravel: 1.3, TotalTravel: 3, Lifespan: 50, Profile: "chocov1", TriggerMethod: "mechanical", SoundProfile: "Loud", ActuationType: "Clicky"},
+		{ID: 4, Manufacturer: "Cherry", Model: "Black", OperatingForce: 60, ActivationTravel: 2, TotalTravel: 4, Lifespan: 100, Profile: "MX", TriggerMethod: "mechanical", SoundProfile: "Normal", ActuationType: "Linear"},
+	}
+
+	repo := fakeRepo{
+		getID: func(brand, name string) (*int, error) {
+			for _, item := range catalog {
+				if item.Manufacturer == brand && item.Model == name {
+					return intptr(item.ID), nil
+				}
+			}
+			return nil, nil
+		},
+		getSingleReturner: func(id int) (*models.SwitchEntity, error) {
+			item := catalog[id-1]
+			return &item, nil
+		},
+		getAllReturner: func() ([]models.SwitchEntity, error) {
+			return catalog, nil
+		},
+	}
+
+	tcases := []struct {
+		brand    string
+		name     string
+		limit    int
+		weights  models.SimilarityWeights
+		expected struct {
+			names []string
+			err   *common.AppError
+		}
+	}{
+		{
+			brand:   "Gateron",
+			name:    "Yellow",
+			limit:   10,
+			weights: models.DefaultSimilarityWeights(),
+			expected: struct {
+				names []string
+				err   *common.AppError
+			}{
+				names: []string{"Red", "Black", "Choc White"},
+			},
+		},
+		{
+			brand:   "Gateron",
+			name:    "Yellow",
+			limit:   1,
+			weights: models.DefaultSimilarityWeights(),
+			expected: struct {
+				names []string
+				err   *common.AppError
+			}{
+				names: []string{"Red"},
+			},
+		},
+		{
+			brand:   "Gateron",
+			name:    "Yellow",
+			limit:   10,
+			weights: models.SimilarityWeights{models.AttrLifespan: 1},
+			expected: struct {
+				names []string
+				err   *common.AppError
+			}{
+				names: []string{"Choc White", "Black", "Red"},
+			},
+		},
+		{
+			brand:   "Gateron",
+			name:    "Yellow",
+			limit:   10,
+			weights: models.SimilarityWeights{models.AttrProfile: -1},
+			expected: struct {
+				names []string
+				err   *common.AppError
+			}{
+				err: &switches.ErrInvalidWeights,
+			},
+		},
+		{
+			brand:   "Gateron",
+			name:    "Yellow",
+			limit:   10,
+			weights: models.SimilarityWeights{"foo": 1},
+			expected: struct {
+				names []string
+				err   *common.AppError
+			}{
+				err: &switches.ErrUnknownWeight,
+			},
+		},
+		{
+			brand:   "Gateron",
+			name:    "Yellow",
+			limit:   0,
+			weights: models.DefaultSimilarityWeights(),
+			expected: struct {
+				names []string
+				err   *common.AppError
+			}{
+				err: &switches.ErrInvalidLimit,
+			},
+		},
+		{
+			brand:   "Gateron",
+			name:    "Blue",
+			limit:   10,
+			weights: models.DefaultSimilarityWeights(),
+			expected: struct {
+				names []string
+				err   *common.AppError
+			}{
+				err: &switches.ErrNoSwitch,
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		unit := switches.New(&fakeLogger{}, repo)
+		res, err := unit.GetSimilar(context.Background(), tc.brand, tc.name, tc.limit, tc.weights)
+
+		assertErrorsEqual("GetSimilar", t, tc.expected.err, err)
+		if tc.expected.err != nil {
+			continue
+		}
+
+		names := make([]string, len(res))
+		for i, item := range res {
+			names[i] = item.Switch.Name
+			if len(item.Matches) == 0 {
+				t.Errorf("in method GetSimilar: match %s has no explanation", item.Switch.Name)
+			}
+		}
+		assertResultsEqual("GetSimilar", t, tc.expected.names, names)
+	}
+}
@@ -0,0 +1,76 @@
+package switches_test
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+	"kbswitch/internal/pkg/switches"
+	"testing"
+)
+
+func TestGetStats(t *testing.T) {
+	tcases := []struct {
+		repo     fakeRepo
+		logger   fakeLogger
+		expected struct {
+			res  *models.CatalogStats
+			err  *common.AppError
+			logs []string
+		}
+	}{
+		{
+			repo: fakeRepo{
+				statsReturner: func() (*models.CatalogStats, error) {
+					return nil, errTest
+				},
+			},
+			expected: struct {
+				res  *models.CatalogStats
+				err  *common.AppError
+				logs []string
+			}{
+				err:  common.Wrap(errTest),
+				logs: []string{LogLvlError},
+			},
+		},
+		{
+			repo: fakeRepo{
+				statsReturner: func() (*models.CatalogStats, error) {
+					return nil, nil
+				},
+			},
+			expected: struct {
+				res  *models.CatalogStats
+				err  *common.AppError
+				logs []string
+			}{
+				err:  &switches.ErrErrorMissing,
+				logs: []string{LogLvlError},
+			},
+		},
+		{
+			repo: fakeRepo{
+				statsReturner: func() (*models.CatalogStats, error) {
+					return &models.CatalogStats{Total: 3}, nil
+				},
+			},
+			expected: struct {
+				res  *models.CatalogStats
+				err  *common.AppError
+				logs []string
+			}{
+				res:  &models.CatalogStats{Total: 3},
+				logs: []string{LogLvlTrace},
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		unit := switches.New(&tc.logger, tc.repo)
+		res, err := unit.GetStats(context.Background())
+
+		assertErrorsEqual("GetStats", t, tc.expected.err, err)
+		assertResultsEqual("GetStats", t, tc.expected.res, res)
+		assertLogsEqual("GetStats", t, tc.expected.logs, tc.logger.logs)
+	}
+}
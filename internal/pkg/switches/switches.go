@@ -28,6 +28,11 @@ type service struct {
 }
 
 func (s service) AddNew(ctx context.Context, reqbody models.SwitchRequestBody) (*int, *common.AppError) {
+	if reasons := Validate(reqbody); reasons != nil {
+		s.logger.LogError(fmt.Sprintf("invalid switch %v", reasons))
+		return nil, invalidSwitch(reasons)
+	}
+
 	switchID, err := s.repo.GetID(ctx, reqbody.Brand, reqbody.Name)
 	if err != nil {
 		s.logger.LogError(err.Error())
@@ -38,19 +43,7 @@ func (s service) AddNew(ctx context.Context, reqbody models.SwitchRequestBody) (
 		return nil, &ErrAlreadyExists
 	}
 
-	entity := models.SwitchEntity{
-		Manufacturer:     reqbody.Brand,
-		ActuationType:    reqbody.ActuationType,
-		Lifespan:         reqbody.Lifespan,
-		Model:            reqbody.Name,
-		Image:            []byte(reqbody.Image),
-		OperatingForce:   reqbody.OperatingForce,
-		ActivationTravel: reqbody.ActivationTravel,
-		TotalTravel:      reqbody.TotalTravel,
-		SoundProfile:     reqbody.SoundProfile,
-		TriggerMethod:    reqbody.TriggerMethod,
-		Profile:          reqbody.Profile,
-	}
+	entity := toEntity(reqbody)
 
 	resp, err := s.repo.AddNew(ctx, entity)
 	if err != nil {
@@ -63,6 +56,11 @@ func (s service) AddNew(ctx context.Context, reqbody models.SwitchRequestBody) (
 }
 
 func (s service) Update(ctx context.Context, brand, name string, body models.SwitchRequestBody) (*models.Switch, *common.AppError) {
+	if reasons := Validate(body); reasons != nil {
+		s.logger.LogError(fmt.Sprintf("invalid switch %v", reasons))
+		return nil, invalidSwitch(reasons)
+	}
+
 	switchID, err := s.repo.GetID(ctx, brand, name)
 	if err != nil {
 		s.logger.LogError(err.Error())
@@ -73,19 +71,7 @@ func (s service) Update(ctx context.Context, brand, name string, body models.Swi
 		return nil, &ErrNoSwitch
 	}
 
-	entity := models.SwitchEntity{
-		Manufacturer:     body.Brand,
-		ActuationType:    body.ActuationType,
-		Lifespan:         body.Lifespan,
-		Model:            body.Name,
-		Image:            []byte(body.Image),
-		OperatingForce:   body.OperatingForce,
-		ActivationTravel: body.ActivationTravel,
-		TotalTravel:      body.TotalTravel,
-		SoundProfile:     body.SoundProfile,
-		TriggerMethod:    body.TriggerMethod,
-		Profile:          body.Profile,
-	}
+	entity := toEntity(body)
 	resp, err := s.repo.Update(ctx, *switchID, entity)
 	if err != nil {
 		s.logger.LogError(err.Error())
@@ -95,19 +81,7 @@ func (s service) Update(ctx context.Context, brand, name string, body models.Swi
 		return nil, nil
 	}
 
-	res := models.Switch{
-		Brand:            resp.Manufacturer,
-		ActuationType:    resp.ActuationType,
-		Lifespan:         resp.Lifespan,
-		Name:             resp.Model,
-		Image:            string(resp.Image[:]),
-		OperatingForce:   resp.OperatingForce,
-		ActivationTravel: resp.ActivationTravel,
-		TotalTravel:      resp.TotalTravel,
-		SoundProfile:     resp.SoundProfile,
-		TriggerMethod:    resp.TriggerMethod,
-		Profile:          resp.Profile,
-	}
+	res := toSwitch(*resp)
 	s.logger.LogTrace(fmt.Sprintf("result is %v", res))
 
 	return &res, nil
@@ -143,19 +117,7 @@ func (s service) GetAll(ctx context.Context) ([]models.Switch, *common.AppError)
 	}
 
 	for _, item := range resp {
-		s := models.Switch{
-			Brand:            item.Manufacturer,
-			ActuationType:    item.ActuationType,
-			Lifespan:         item.Lifespan,
-			Name:             item.Model,
-			Image:            string(item.Image[:]),
-			OperatingForce:   item.OperatingForce,
-			ActivationTravel: item.ActivationTravel,
-			TotalTravel:      item.TotalTravel,
-			SoundProfile:     item.SoundProfile,
-			TriggerMethod:    item.TriggerMethod,
-			Profile:          item.Profile,
-		}
+		s := toSwitch(item)
 
 		res = append(res, s)
 	}
@@ -185,20 +147,42 @@ func (s service) GetSingle(ctx context.Context, brand, name string) (*models.Swi
 		s.logger.LogError("response from repo was nil")
 		return nil, &ErrErrorMissing
 	}
-	res := models.Switch{
-		Brand:            resp.Manufacturer,
-		ActuationType:    resp.ActuationType,
-		Lifespan:         resp.Lifespan,
-		Name:             resp.Model,
-		Image:            string(resp.Image[:]),
-		OperatingForce:   resp.OperatingForce,
-		ActivationTravel: resp.ActivationTravel,
-		TotalTravel:      resp.TotalTravel,
-		SoundProfile:     resp.SoundProfile,
-		TriggerMethod:    resp.TriggerMethod,
-		Profile:          resp.Profile,
-	}
+	res := toSwitch(*resp)
 	s.logger.LogTrace(fmt.Sprintf("result is %v", res))
 
 	return &res, nil
 }
+
+func toEntity(body models.SwitchRequestBody) models.SwitchEntity {
+	return models.SwitchEntity{
+		Manufacturer:     body.Brand,
+		ActuationType:    body.ActuationType,
+		Lifespan:         body.Lifespan,
+		Model:            body.Name,
+		Image:            []byte(body.Image),
+		OperatingForce:   body.OperatingForce,
+		ActivationTravel: body.ActivationTravel,
+		TotalTravel:      body.TotalTravel,
+		SoundProfile:     body.SoundProfile,
+		TriggerMethod:    body.TriggerMethod,
+		Profile:          body.Profile,
+		Description:      body.Description,
+	}
+}
+
+func toSwitch(entity models.SwitchEntity) models.Switch {
+	return models.Switch{
+		Brand:            entity.Manufacturer,
+		ActuationType:    entity.ActuationType,
+		Lifespan:         entity.Lifespan,
+		Name:             entity.Model,
+		Image:            string(entity.Image[:]),
+		OperatingForce:   entity.OperatingForce,
+		ActivationTravel: entity.ActivationTravel,
+		TotalTravel:      entity.TotalTravel,
+		SoundProfile:     entity.SoundProfile,
+		TriggerMethod:    entity.TriggerMethod,
+		Profile:          entity.Profile,
+		Description:      entity.Description,
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"kbswitch/internal/core/common"
+	coreswitches "kbswitch/internal/core/switches"
 	"kbswitch/internal/core/switches/models"
 	"kbswitch/internal/pkg/switches"
 	"reflect"
@@ -53,6 +54,71 @@ type fakeRepo struct {
 	addNewAction      func(models.SwitchEntity) (*int, error)
 	removeAction      func(int) error
 	updateAction      func(int, models.SwitchEntity) (*models.SwitchEntity, error)
+	searchReturner    func(string, models.SwitchFilter, int) ([]models.SearchHitEntity, error)
+	getAliases        func(int) ([]models.AliasEntity, error)
+	addAliasAction    func(models.AliasEntity) (*int, error)
+	removeAliasAction func(int, string, string) (bool, error)
+	facetsReturner    func(models.SwitchFilter) (*models.Facets, error)
+	statsReturner     func() (*models.CatalogStats, error)
+	inTxAction        func(fakeRepo, func(coreswitches.Repo) error) error
+	lookupReturner    func([]models.SwitchKey) ([]models.LookupHitEntity, error)
+}
+
+// Lookup implements repositories.SwitchesRepo.
+func (f fakeRepo) Lookup(ctx context.Context, keys []models.SwitchKey) ([]models.LookupHitEntity, error) {
+	return f.lookupReturner(keys)
+}
+
+// Stream implements repositories.SwitchesRepo.
+func (f fakeRepo) Stream(ctx context.Context, filter models.SwitchFilter, fn func(models.SwitchEntity) error) error {
+	all, err := f.getAllReturner()
+	if err != nil {
+		return err
+	}
+	for _, e := range all {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InTx implements repositories.SwitchesRepo.
+func (f fakeRepo) InTx(ctx context.Context, fn func(coreswitches.Repo) error) error {
+	if f.inTxAction != nil {
+		return f.inTxAction(f, fn)
+	}
+	return fn(f)
+}
+
+// Stats implements repositories.SwitchesRepo.
+func (f fakeRepo) Stats(ctx context.Context) (*models.CatalogStats, error) {
+	return f.statsReturner()
+}
+
+// Facets implements repositories.SwitchesRepo.
+func (f fakeRepo) Facets(ctx context.Context, filter models.SwitchFilter) (*models.Facets, error) {
+	return f.facetsReturner(filter)
+}
+
+// GetAliases implements repositories.SwitchesRepo.
+func (f fakeRepo) GetAliases(ctx context.Context, switchID int) ([]models.AliasEntity, error) {
+	return f.getAliases(switchID)
+}
+
+// AddAlias implements repositories.SwitchesRepo.
+func (f fakeRepo) AddAlias(ctx context.Context, alias models.AliasEntity) (*int, error) {
+	return f.addAliasAction(alias)
+}
+
+// RemoveAlias implements repositories.SwitchesRepo.
+func (f fakeRepo) RemoveAlias(ctx context.Context, switchID int, brand, name string) (bool, error) {
+	return f.removeAliasAction(switchID, brand, name)
+}
+
+// Search implements repositories.SwitchesRepo.
+func (f fakeRepo) Search(ctx context.Context, query string, filter models.SwitchFilter, limit int) ([]models.SearchHitEntity, error) {
+	return f.searchReturner(query, filter, limit)
 }
 
 // Update implements repositories.SwitchesRepo.
@@ -0,0 +1,43 @@
+package switches
+
+import (
+	"fmt"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/switches/models"
+	"strings"
+)
+
+// Validate checks request body and returns human readable reasons it is invalid for, nil when valid.
+// every path which stores a switch, single or bulk, must go through it
+func Validate(body models.SwitchRequestBody) []string {
+	var reasons []string
+
+	if strings.TrimSpace(body.Brand) == "" {
+		reasons = append(reasons, "brand is required")
+	}
+	if strings.TrimSpace(body.Name) == "" {
+		reasons = append(reasons, "name is required")
+	}
+	if body.Lifespan < 0 {
+		reasons = append(reasons, "lifespan must not be negative")
+	}
+	if body.OperatingForce < 0 {
+		reasons = append(reasons, "operatingForce must not be negative")
+	}
+	if body.ActivationTravel < 0 {
+		reasons = append(reasons, "activationTravel must not be negative")
+	}
+	if body.TotalTravel < 0 {
+		reasons = append(reasons, "totalTravel must not be negative")
+	}
+	if body.TotalTravel > 0 && body.ActivationTravel > body.TotalTravel {
+		reasons = append(reasons, "activationTravel must not exceed totalTravel")
+	}
+
+	return reasons
+}
+
+func invalidSwitch(reasons []string) *common.AppError {
+	e := common.NewError(common.ErrBadRequest, fmt.Sprintf("invalid switch: %s", strings.Join(reasons, "; ")))
+	return &e
+}
@@ -0,0 +1,49 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"kbswitch/internal/pkg/jwt"
+)
+
+const (
+	hs256KeyID = "hs256"
+	// minSecretLen follows RFC 7518, HS256 secrets must be at least as long as the hash
+	minSecretLen = 32
+)
+
+// NewKeys builds signing and verifying keys for alg, either jwt.AlgEdDSA or jwt.AlgHS256.
+// Ed25519 key is derived from seed, HS256 key is there only when secret is given. Both kinds
+// are accepted whichever signs, so alg can be switched without logging everybody out
+func NewKeys(issuer, alg string, seed, secret []byte) (Keys, error) {
+	if len(seed) != ed25519.SeedSize {
+		return Keys{}, fmt.Errorf("ed25519 seed must be %d bytes long, got %d", ed25519.SeedSize, len(seed))
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+	pub := priv.Public().(ed25519.PublicKey)
+	eddsa := jwt.EdDSA(jwt.Thumbprint(pub), pub, priv)
+
+	var hs256 jwt.Key
+	if len(secret) > 0 {
+		if len(secret) < minSecretLen {
+			return Keys{}, fmt.Errorf("hs256 secret must be at least %d bytes long", minSecretLen)
+		}
+		hs256 = jwt.HS256(hs256KeyID, secret)
+	}
+
+	switch alg {
+	case jwt.AlgEdDSA, "":
+		keys := Keys{Issuer: issuer, Signing: eddsa}
+		if hs256 != nil {
+			keys.Verifying = []jwt.Key{hs256}
+		}
+		return keys, nil
+	case jwt.AlgHS256:
+		if hs256 == nil {
+			return Keys{}, fmt.Errorf("hs256 signing needs a secret")
+		}
+		return Keys{Issuer: issuer, Signing: hs256, Verifying: []jwt.Key{eddsa}}, nil
+	}
+
+	return Keys{}, fmt.Errorf("unknown jwt algorithm %s, must be either %s or %s", alg, jwt.AlgEdDSA, jwt.AlgHS256)
+}
@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/common/database"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/tokens"
+	"kbswitch/internal/core/tokens/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func New(logger logging.Logger, pool database.DBPool) tokens.Repo {
+	return repo{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+type repo struct {
+	logger logging.Logger
+	pool   database.DBPool
+}
+
+// Insert implements tokens.Repo.
+func (r repo) Insert(ctx context.Context, e models.RefreshEntity) error {
+	query := `INSERT INTO public.refresh_tokens (hash, family, user_id, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := r.pool.Exec(ctx, query, e.Hash, e.Family, e.UserID, e.CreatedAt, e.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("refresh token of family %s inserted", e.Family))
+
+	return nil
+}
+
+// Rotate implements tokens.Repo.
+func (r repo) Rotate(ctx context.Context, hash string, successor models.RefreshEntity) (*models.RefreshEntity, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// row lock makes concurrent rotations of one token see each other as reuse
+	query := `SELECT hash, family, user_id, created_at, expires_at, used_at, revoked_at
+	FROM public.refresh_tokens WHERE hash = $1 FOR UPDATE`
+
+	var old models.RefreshEntity
+	err = tx.QueryRow(ctx, query, hash).Scan(&old.Hash, &old.Family, &old.UserID,
+		&old.CreatedAt, &old.ExpiresAt, &old.UsedAt, &old.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if old.RevokedAt != nil {
+		return nil, nil
+	}
+	if old.UsedAt != nil {
+		if _, err := tx.Exec(ctx, `UPDATE public.refresh_tokens SET revoked_at = now()
+			WHERE family = $1 AND revoked_at IS NULL`, old.Family); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		r.logger.LogTrace(fmt.Sprintf("refresh token family %s revoked after reuse", old.Family))
+		return nil, tokens.ErrReused
+	}
+	if !successor.CreatedAt.Before(old.ExpiresAt) {
+		return nil, nil
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE public.refresh_tokens SET used_at = now() WHERE hash = $1`, hash); err != nil {
+		return nil, err
+	}
+
+	successor.Family = old.Family
+	successor.UserID = old.UserID
+	query = `INSERT INTO public.refresh_tokens (hash, family, user_id, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.Exec(ctx, query, successor.Hash, successor.Family, successor.UserID,
+		successor.CreatedAt, successor.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("refresh token of family %s rotated", old.Family))
+
+	return &old, nil
+}
+
+// RevokeAll implements tokens.Repo.
+func (r repo) RevokeAll(ctx context.Context, userID int) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE public.refresh_tokens SET revoked_at = now() WHERE revoked_at IS NULL AND user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d refresh tokens of user %d revoked", tag.RowsAffected(), userID))
+
+	return nil
+}
+
+// RevokeFamily implements tokens.Repo.
+func (r repo) RevokeFamily(ctx context.Context, hash string) error {
+	query := `UPDATE public.refresh_tokens SET revoked_at = now()
+	WHERE revoked_at IS NULL AND family = (SELECT family FROM public.refresh_tokens WHERE hash = $1)`
+
+	tag, err := r.pool.Exec(ctx, query, hash)
+	if err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d refresh tokens revoked", tag.RowsAffected()))
+
+	return nil
+}
@@ -0,0 +1,246 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/tokens"
+	"kbswitch/internal/core/tokens/models"
+	"kbswitch/internal/core/users"
+	usermodels "kbswitch/internal/core/users/models"
+	"kbswitch/internal/pkg/jwt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// access tokens can't be revoked, so they are kept short and carry the role of the time they were issued
+	AccessTTL  = 15 * time.Minute
+	RefreshTTL = 30 * 24 * time.Hour
+
+	subjectPrefix = "user:"
+)
+
+var (
+	ErrUnknownGrant        = common.NewError(common.ErrBadRequest, "grant type must be either 'password' or 'refresh_token'")
+	ErrMissingRefreshToken = common.NewError(common.ErrBadRequest, "refresh token is missing")
+	ErrInvalidRefreshToken = common.NewError(common.ErrUnauthorized, "refresh token is invalid, revoked or has expired")
+	ErrRefreshTokenReused  = common.NewError(common.ErrUnauthorized, "refresh token was used before, every token of its session is revoked")
+	ErrMissingAccessToken  = common.NewError(common.ErrUnauthorized, "access token is missing")
+)
+
+// Keys tells how access tokens are signed and which ones are accepted
+type Keys struct {
+	Issuer string
+	// Signing signs every issued access token
+	Signing jwt.Key
+	// Verifying keys are accepted besides the signing one, so tokens signed before a key change stay valid
+	Verifying []jwt.Key
+}
+
+func New(logger logging.Logger, repo tokens.Repo, accounts users.Service, keys Keys) tokens.Service {
+	all := append([]jwt.Key{keys.Signing}, keys.Verifying...)
+
+	return service{
+		repo:     repo,
+		accounts: accounts,
+		issuer:   keys.Issuer,
+		signing:  keys.Signing,
+		keys:     all,
+		verifier: jwt.NewVerifier(keys.Issuer, all...),
+		logger:   logger,
+	}
+}
+
+type service struct {
+	repo     tokens.Repo
+	accounts users.Service
+	issuer   string
+	signing  jwt.Key
+	keys     []jwt.Key
+	verifier *jwt.Verifier
+	logger   logging.Logger
+}
+
+func newRefreshToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s service) Issue(ctx context.Context, req models.TokenRequest) (*models.TokenPair, *common.AppError) {
+	switch req.GrantType {
+	case models.GrantPassword:
+		return s.issueForPassword(ctx, req)
+	case models.GrantRefreshToken:
+		return s.issueForRefresh(ctx, req.RefreshToken)
+	}
+
+	s.logger.LogError(fmt.Sprintf("unknown grant type %s", req.GrantType))
+	return nil, &ErrUnknownGrant
+}
+
+func (s service) issueForPassword(ctx context.Context, req models.TokenRequest) (*models.TokenPair, *common.AppError) {
+	user, e := s.accounts.CheckCredentials(ctx, usermodels.Credentials{Email: req.Email, Password: req.Password})
+	if e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+
+	refresh, hash, err := newRefreshToken()
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	now := time.Now()
+	err = s.repo.Insert(ctx, models.RefreshEntity{
+		Hash:      hash,
+		Family:    uuid.NewString(),
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(RefreshTTL),
+	})
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	return s.pair(*user, refresh, now)
+}
+
+func (s service) issueForRefresh(ctx context.Context, token string) (*models.TokenPair, *common.AppError) {
+	if token == "" {
+		s.logger.LogError("refresh token is missing")
+		return nil, &ErrMissingRefreshToken
+	}
+
+	refresh, hash, err := newRefreshToken()
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	now := time.Now()
+	old, err := s.repo.Rotate(ctx, hashToken(token), models.RefreshEntity{
+		Hash:      hash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(RefreshTTL),
+	})
+	if errors.Is(err, tokens.ErrReused) {
+		s.logger.LogError("refresh token reused, its family is revoked")
+		return nil, &ErrRefreshTokenReused
+	}
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if old == nil {
+		s.logger.LogError("unknown, revoked or expired refresh token")
+		return nil, &ErrInvalidRefreshToken
+	}
+
+	// role is read again, so changes of it are picked up at the latest with the next refresh
+	user, e := s.accounts.Get(ctx, old.UserID)
+	if e != nil {
+		s.logger.LogError(e.Error())
+		return nil, e
+	}
+
+	return s.pair(*user, refresh, now)
+}
+
+func (s service) pair(user usermodels.User, refresh string, now time.Time) (*models.TokenPair, *common.AppError) {
+	access, err := jwt.Sign(s.signing, jwt.Claims{
+		Issuer:    s.issuer,
+		Subject:   subjectPrefix + strconv.Itoa(user.ID),
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(AccessTTL).Unix(),
+		ID:        uuid.NewString(),
+		Name:      user.Email,
+		Role:      string(user.Role),
+	})
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("tokens issued to user %d", user.ID))
+	return &models.TokenPair{
+		AccessToken:  access,
+		ExpiresIn:    AccessTTL,
+		RefreshToken: refresh,
+	}, nil
+}
+
+func (s service) Revoke(ctx context.Context, refreshToken string) *common.AppError {
+	if refreshToken == "" {
+		s.logger.LogError("refresh token is missing")
+		return &ErrMissingRefreshToken
+	}
+
+	if err := s.repo.RevokeFamily(ctx, hashToken(refreshToken)); err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+
+	s.logger.LogTrace("refresh token family revoked")
+	return nil
+}
+
+func (s service) Verify(ctx context.Context, accessToken string) (*models.Claims, *common.AppError) {
+	if accessToken == "" {
+		return nil, &ErrMissingAccessToken
+	}
+
+	claims, err := s.verifier.Verify(accessToken, time.Now())
+	if err != nil {
+		s.logger.LogError(err.Error())
+		e := common.NewError(common.ErrUnauthorized, "access token is invalid, "+err.Error())
+		return nil, &e
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(claims.Subject, subjectPrefix))
+	if err != nil || !strings.HasPrefix(claims.Subject, subjectPrefix) {
+		s.logger.LogError(fmt.Sprintf("unexpected subject %s", claims.Subject))
+		e := common.NewError(common.ErrUnauthorized, "access token is invalid, subject is not a user")
+		return nil, &e
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("access token of %s verified", claims.Subject))
+	return &models.Claims{
+		Subject:   claims.Subject,
+		UserID:    id,
+		Name:      claims.Name,
+		Role:      claims.Role,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+func (s service) JWKS() []models.JWK {
+	keys := jwt.JWKS(s.keys...)
+
+	res := make([]models.JWK, len(keys))
+	for i, k := range keys {
+		res[i] = models.JWK{Kty: k.Kty, Crv: k.Crv, X: k.X, Kid: k.Kid, Alg: k.Alg, Use: k.Use}
+	}
+	return res
+}
@@ -0,0 +1,305 @@
+package tokens_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	coretokens "kbswitch/internal/core/tokens"
+	"kbswitch/internal/core/tokens/models"
+	"kbswitch/internal/core/users"
+	usermodels "kbswitch/internal/core/users/models"
+	"kbswitch/internal/pkg/jwt"
+	"kbswitch/internal/pkg/tokens"
+	"testing"
+	"time"
+)
+
+type fakeLogger struct{}
+
+func (fakeLogger) LogError(msg string) {}
+func (fakeLogger) LogInfo(msg string)  {}
+func (fakeLogger) LogTrace(msg string) {}
+
+// memRepo rotates tokens the way the database one does
+type memRepo struct {
+	tokens map[string]*models.RefreshEntity
+}
+
+func (m *memRepo) Insert(ctx context.Context, e models.RefreshEntity) error {
+	m.tokens[e.Hash] = &e
+	return nil
+}
+
+func (m *memRepo) Rotate(ctx context.Context, hash string, successor models.RefreshEntity) (*models.RefreshEntity, error) {
+	old, ok := m.tokens[hash]
+	if !ok || old.RevokedAt != nil || !old.ExpiresAt.After(time.Now()) {
+		return nil, nil
+	}
+	if old.UsedAt != nil {
+		m.RevokeFamily(ctx, hash)
+		return nil, coretokens.ErrReused
+	}
+
+	now := time.Now()
+	old.UsedAt = &now
+	successor.Family = old.Family
+	successor.UserID = old.UserID
+	m.tokens[successor.Hash] = &successor
+
+	res := *old
+	return &res, nil
+}
+
+func (m *memRepo) RevokeFamily(ctx context.Context, hash string) error {
+	old, ok := m.tokens[hash]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	for _, t := range m.tokens {
+		if t.Family == old.Family && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *memRepo) RevokeAll(ctx context.Context, userID int) error {
+	now := time.Now()
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// fakeAccounts knows a single user, role can be changed in between
+type fakeAccounts struct {
+	users.Service
+	user usermodels.User
+}
+
+func (f *fakeAccounts) CheckCredentials(ctx context.Context, creds usermodels.Credentials) (*usermodels.User, *common.AppError) {
+	if creds.Email != f.user.Email || creds.Password != "correct horse" {
+		e := common.NewError(common.ErrUnauthorized, "wrong email or password")
+		return nil, &e
+	}
+	u := f.user
+	return &u, nil
+}
+
+func (f *fakeAccounts) Get(ctx context.Context, id int) (*usermodels.User, *common.AppError) {
+	u := f.user
+	return &u, nil
+}
+
+func setup(t *testing.T) (coretokens.Service, *fakeAccounts) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := &fakeAccounts{user: usermodels.User{ID: 7, Email: "jane@example.com", Role: authz.RoleContributor}}
+	keys := tokens.Keys{Issuer: "https://switches.test", Signing: jwt.EdDSA("ed", pub, priv)}
+
+	return tokens.New(fakeLogger{}, &memRepo{tokens: map[string]*models.RefreshEntity{}}, accounts, keys), accounts
+}
+
+func TestIssue(t *testing.T) {
+	service, _ := setup(t)
+
+	tests := []struct {
+		name     string
+		req      models.TokenRequest
+		expected *common.AppError
+	}{
+		{
+			name: "password",
+			req:  models.TokenRequest{GrantType: models.GrantPassword, Email: "jane@example.com", Password: "correct horse"},
+		},
+		{
+			name:     "wrong password",
+			req:      models.TokenRequest{GrantType: models.GrantPassword, Email: "jane@example.com", Password: "battery staple"},
+			expected: &common.AppError{Errtype: common.ErrUnauthorized},
+		},
+		{
+			name:     "unknown refresh token",
+			req:      models.TokenRequest{GrantType: models.GrantRefreshToken, RefreshToken: "made-up"},
+			expected: &tokens.ErrInvalidRefreshToken,
+		},
+		{
+			name:     "missing refresh token",
+			req:      models.TokenRequest{GrantType: models.GrantRefreshToken},
+			expected: &tokens.ErrMissingRefreshToken,
+		},
+		{
+			name:     "unknown grant",
+			req:      models.TokenRequest{GrantType: "client_credentials"},
+			expected: &tokens.ErrUnknownGrant,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pair, err := service.Issue(context.Background(), test.req)
+			if test.expected != nil {
+				if err == nil || err.Errtype != test.expected.Errtype {
+					t.Fatalf("expected error of type %v, got %v", test.expected.Errtype, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			claims, err := service.Verify(context.Background(), pair.AccessToken)
+			if err != nil {
+				t.Fatalf("issued token does not verify: %v", err)
+			}
+			if claims.UserID != 7 || claims.Subject != "user:7" || claims.Role != string(authz.RoleContributor) {
+				t.Errorf("unexpected claims %+v", *claims)
+			}
+			if pair.ExpiresIn != tokens.AccessTTL || pair.RefreshToken == "" {
+				t.Errorf("unexpected pair %+v", *pair)
+			}
+		})
+	}
+}
+
+func TestIssueRotation(t *testing.T) {
+	ctx := context.Background()
+	service, accounts := setup(t)
+
+	first, err := service.Issue(ctx, models.TokenRequest{GrantType: models.GrantPassword, Email: "jane@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accounts.user.Role = authz.RoleModerator
+	second, err := service.Issue(ctx, models.TokenRequest{GrantType: models.GrantRefreshToken, RefreshToken: first.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	claims, _ := service.Verify(ctx, second.AccessToken)
+	if claims.Role != string(authz.RoleModerator) {
+		t.Errorf("expected refreshed token to carry new role, got %s", claims.Role)
+	}
+
+	// replaying the used token revokes the whole family, the current token included
+	if _, err := service.Issue(ctx, models.TokenRequest{GrantType: models.GrantRefreshToken, RefreshToken: first.RefreshToken}); err == nil || err.Reason != tokens.ErrRefreshTokenReused.Reason {
+		t.Fatalf("expected reuse to be detected, got %v", err)
+	}
+	if _, err := service.Issue(ctx, models.TokenRequest{GrantType: models.GrantRefreshToken, RefreshToken: second.RefreshToken}); err == nil || err.Reason != tokens.ErrInvalidRefreshToken.Reason {
+		t.Fatalf("expected family to be revoked, got %v", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	service, _ := setup(t)
+
+	pair, _ := service.Issue(ctx, models.TokenRequest{GrantType: models.GrantPassword, Email: "jane@example.com", Password: "correct horse"})
+	if err := service.Revoke(ctx, pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Issue(ctx, models.TokenRequest{GrantType: models.GrantRefreshToken, RefreshToken: pair.RefreshToken}); err == nil {
+		t.Fatal("expected revoked token to be refused")
+	}
+}
+
+func TestNewKeys(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		name     string
+		alg      string
+		secret   []byte
+		expected struct {
+			alg       string
+			verifying int
+			fails     bool
+		}
+	}{
+		{
+			name: "eddsa",
+			alg:  jwt.AlgEdDSA,
+			expected: struct {
+				alg       string
+				verifying int
+				fails     bool
+			}{alg: jwt.AlgEdDSA},
+		},
+		{
+			name:   "eddsa accepting hs256",
+			alg:    jwt.AlgEdDSA,
+			secret: secret,
+			expected: struct {
+				alg       string
+				verifying int
+				fails     bool
+			}{alg: jwt.AlgEdDSA, verifying: 1},
+		},
+		{
+			name:   "hs256",
+			alg:    jwt.AlgHS256,
+			secret: secret,
+			expected: struct {
+				alg       string
+				verifying int
+				fails     bool
+			}{alg: jwt.AlgHS256, verifying: 1},
+		},
+		{
+			name: "hs256 without secret",
+			alg:  jwt.AlgHS256,
+			expected: struct {
+				alg       string
+				verifying int
+				fails     bool
+			}{fails: true},
+		},
+		{
+			name:   "short secret",
+			alg:    jwt.AlgHS256,
+			secret: []byte("short"),
+			expected: struct {
+				alg       string
+				verifying int
+				fails     bool
+			}{fails: true},
+		},
+		{
+			name: "unknown alg",
+			alg:  "RS256",
+			expected: struct {
+				alg       string
+				verifying int
+				fails     bool
+			}{fails: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys, err := tokens.NewKeys("https://switches.test", test.alg, seed, test.secret)
+			if (err != nil) != test.expected.fails {
+				t.Fatalf("expected failure %v, got %v", test.expected.fails, err)
+			}
+			if err != nil {
+				return
+			}
+			if keys.Signing.Alg() != test.expected.alg || len(keys.Verifying) != test.expected.verifying {
+				t.Errorf("unexpected keys %+v", keys)
+			}
+		})
+	}
+}
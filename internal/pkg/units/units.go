@@ -0,0 +1,144 @@
+// Package units converts and formats switch measurements. Values are stored in grams-force,
+// millimeters and millions of keystrokes, every representation for clients goes through here
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+type System string
+
+const (
+	Metric   System = "metric"
+	Imperial System = "imperial"
+)
+
+type Force string
+
+const (
+	GramForce   Force = "gf"
+	Centinewton Force = "cN"
+	// Gram is what most vendors mean by "g", numerically equal to gram-force
+	Gram  Force = "g"
+	Ounce Force = "oz"
+)
+
+type Length string
+
+const (
+	Millimeter Length = "mm"
+	Inch       Length = "in"
+)
+
+const LifespanUnit = "M"
+
+var (
+	ErrUnknownSystem = errors.New("units must be either 'metric' or 'imperial'")
+	ErrUnknownForce  = errors.New("force unit must be one of gf, cN, g or oz")
+)
+
+// factors from the stored unit
+var (
+	forceFactors = map[Force]float64{
+		GramForce:   1,
+		Centinewton: 0.980665,
+		Gram:        1,
+		Ounce:       1 / 28.349523125,
+	}
+	lengthFactors = map[Length]float64{
+		Millimeter: 1,
+		Inch:       1 / 25.4,
+	}
+	// decimal places kept after conversion
+	forcePrecision = map[Force]int{
+		GramForce:   2,
+		Centinewton: 2,
+		Gram:        2,
+		Ounce:       2,
+	}
+	lengthPrecision = map[Length]int{
+		Millimeter: 3,
+		Inch:       3,
+	}
+)
+
+// Options tell how measurements are presented, zero value is metric with formatted strings
+type Options struct {
+	System System
+	// ForceUnit overrides force unit of the System
+	ForceUnit Force
+	// Raw asks for plain numbers instead of formatted strings
+	Raw bool
+}
+
+// Parse builds options from client input, empty values fall back to defaults
+func Parse(system, force string, raw bool) (Options, error) {
+	opts := Options{System: Metric, Raw: raw}
+
+	switch System(system) {
+	case "", Metric:
+	case Imperial:
+		opts.System = Imperial
+	default:
+		return Options{}, ErrUnknownSystem
+	}
+
+	if force != "" {
+		if _, ok := forceFactors[Force(force)]; !ok {
+			return Options{}, ErrUnknownForce
+		}
+		opts.ForceUnit = Force(force)
+	}
+
+	return opts, nil
+}
+
+func (o Options) Force() Force {
+	if o.ForceUnit != "" {
+		return o.ForceUnit
+	}
+	if o.System == Imperial {
+		return Ounce
+	}
+	return GramForce
+}
+
+func (o Options) Length() Length {
+	if o.System == Imperial {
+		return Inch
+	}
+	return Millimeter
+}
+
+// ConvertForce converts grams-force into unit of the options
+func (o Options) ConvertForce(gf float64) float64 {
+	unit := o.Force()
+	return round(gf*forceFactors[unit], forcePrecision[unit])
+}
+
+// ConvertLength converts millimeters into unit of the options
+func (o Options) ConvertLength(mm float64) float64 {
+	unit := o.Length()
+	return round(mm*lengthFactors[unit], lengthPrecision[unit])
+}
+
+func (o Options) FormatForce(gf float64) string {
+	return strconv.FormatFloat(o.ConvertForce(gf), 'f', -1, 64) + string(o.Force())
+}
+
+func (o Options) FormatLength(mm float64) string {
+	return strconv.FormatFloat(o.ConvertLength(mm), 'f', -1, 64) + string(o.Length())
+}
+
+// FormatLifespan formats millions of keystrokes, it does not depend on the unit system
+func FormatLifespan(millions int) string {
+	return fmt.Sprintf("%d%s", millions, LifespanUnit)
+}
+
+func round(x float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(x*p) / p
+}
@@ -0,0 +1,136 @@
+package units_test
+
+import (
+	"kbswitch/internal/pkg/units"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tcases := []struct {
+		system   string
+		force    string
+		expected struct {
+			force  units.Force
+			length units.Length
+			err    error
+		}
+	}{
+		{
+			expected: struct {
+				force  units.Force
+				length units.Length
+				err    error
+			}{force: units.GramForce, length: units.Millimeter},
+		},
+		{
+			system: "imperial",
+			expected: struct {
+				force  units.Force
+				length units.Length
+				err    error
+			}{force: units.Ounce, length: units.Inch},
+		},
+		{
+			system: "imperial",
+			force:  "cN",
+			expected: struct {
+				force  units.Force
+				length units.Length
+				err    error
+			}{force: units.Centinewton, length: units.Inch},
+		},
+		{
+			system: "nautical",
+			expected: struct {
+				force  units.Force
+				length units.Length
+				err    error
+			}{err: units.ErrUnknownSystem},
+		},
+		{
+			force: "N",
+			expected: struct {
+				force  units.Force
+				length units.Length
+				err    error
+			}{err: units.ErrUnknownForce},
+		},
+	}
+
+	for _, tc := range tcases {
+		opts, err := units.Parse(tc.system, tc.force, false)
+		if tc.expected.err != err {
+			t.Errorf("Parse(%q, %q) error failed\nexpected %v\ngot %v", tc.system, tc.force, tc.expected.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if tc.expected.force != opts.Force() || tc.expected.length != opts.Length() {
+			t.Errorf("Parse(%q, %q) failed\nexpected %s %s\ngot %s %s", tc.system, tc.force,
+				tc.expected.force, tc.expected.length, opts.Force(), opts.Length())
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tcases := []struct {
+		opts     units.Options
+		gf       float64
+		mm       float64
+		expected struct {
+			force  string
+			length string
+		}
+	}{
+		{
+			opts: units.Options{},
+			gf:   45,
+			mm:   1.9,
+			expected: struct {
+				force  string
+				length string
+			}{force: "45gf", length: "1.9mm"},
+		},
+		{
+			opts: units.Options{System: units.Imperial},
+			gf:   45,
+			mm:   4,
+			expected: struct {
+				force  string
+				length string
+			}{force: "1.59oz", length: "0.157in"},
+		},
+		{
+			opts: units.Options{ForceUnit: units.Centinewton},
+			gf:   62,
+			mm:   3.5,
+			expected: struct {
+				force  string
+				length string
+			}{force: "60.8cN", length: "3.5mm"},
+		},
+		{
+			opts: units.Options{ForceUnit: units.Gram},
+			gf:   35,
+			mm:   0,
+			expected: struct {
+				force  string
+				length string
+			}{force: "35g", length: "0mm"},
+		},
+	}
+
+	for _, tc := range tcases {
+		if got := tc.opts.FormatForce(tc.gf); tc.expected.force != got {
+			t.Errorf("FormatForce(%v) with %+v failed\nexpected %s\ngot %s", tc.gf, tc.opts, tc.expected.force, got)
+		}
+		if got := tc.opts.FormatLength(tc.mm); tc.expected.length != got {
+			t.Errorf("FormatLength(%v) with %+v failed\nexpected %s\ngot %s", tc.mm, tc.opts, tc.expected.length, got)
+		}
+	}
+
+	if got := units.FormatLifespan(100); got != "100M" {
+		t.Errorf("FormatLifespan failed\nexpected 100M\ngot %s", got)
+	}
+}
@@ -0,0 +1,196 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/common/database"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/users"
+	"kbswitch/internal/core/users/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func New(logger logging.Logger, pool database.DBPool) users.Repo {
+	return repo{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+type repo struct {
+	logger logging.Logger
+	pool   database.DBPool
+}
+
+// column order must match scanUser
+const userColumns = `id, email, password_hash, role, created_at, email_verified_at`
+
+func scanUser(row pgx.Row) (*models.UserEntity, error) {
+	var e models.UserEntity
+	err := row.Scan(&e.ID, &e.Email, &e.PasswordHash, &e.Role, &e.CreatedAt, &e.EmailVerifiedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// CreateUser implements users.Repo.
+func (r repo) CreateUser(ctx context.Context, e models.UserEntity) (*int, error) {
+	query := `INSERT INTO public.users (email, password_hash, role, created_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (email) DO NOTHING
+	RETURNING id`
+
+	var id int
+	err := r.pool.QueryRow(ctx, query, e.Email, e.PasswordHash, e.Role, e.CreatedAt).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("user %d inserted", id))
+
+	return &id, nil
+}
+
+// GetUser implements users.Repo.
+func (r repo) GetUser(ctx context.Context, id int) (*models.UserEntity, error) {
+	return scanUser(r.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM public.users WHERE id = $1`, id))
+}
+
+// GetUserByEmail implements users.Repo.
+func (r repo) GetUserByEmail(ctx context.Context, email string) (*models.UserEntity, error) {
+	return scanUser(r.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM public.users WHERE email = $1`, email))
+}
+
+// SetPassword implements users.Repo.
+func (r repo) SetPassword(ctx context.Context, userID int, hash string) error {
+	_, err := r.pool.Exec(ctx, `UPDATE public.users SET password_hash = $2 WHERE id = $1`, userID, hash)
+	return err
+}
+
+// MarkVerified implements users.Repo.
+func (r repo) MarkVerified(ctx context.Context, userID int) error {
+	query := `UPDATE public.users SET email_verified_at = now() WHERE id = $1 AND email_verified_at IS NULL`
+
+	_, err := r.pool.Exec(ctx, query, userID)
+	return err
+}
+
+// CreateSession implements users.Repo.
+func (r repo) CreateSession(ctx context.Context, e models.SessionEntity) error {
+	query := `INSERT INTO public.user_sessions (hash, user_id, created_at, expires_at) VALUES ($1, $2, $3, $4)`
+
+	_, err := r.pool.Exec(ctx, query, e.Hash, e.UserID, e.CreatedAt, e.ExpiresAt)
+	return err
+}
+
+// GetSession implements users.Repo.
+func (r repo) GetSession(ctx context.Context, hash string) (*models.SessionEntity, error) {
+	query := `SELECT hash, user_id, created_at, expires_at FROM public.user_sessions
+	WHERE hash = $1 AND expires_at > now()`
+
+	var e models.SessionEntity
+	err := r.pool.QueryRow(ctx, query, hash).Scan(&e.Hash, &e.UserID, &e.CreatedAt, &e.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// DeleteSession implements users.Repo.
+func (r repo) DeleteSession(ctx context.Context, hash string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM public.user_sessions WHERE hash = $1`, hash)
+	return err
+}
+
+// DeleteSessions implements users.Repo.
+func (r repo) DeleteSessions(ctx context.Context, userID int) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM public.user_sessions WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d sessions of user %d deleted", tag.RowsAffected(), userID))
+
+	return nil
+}
+
+// CreateToken implements users.Repo.
+func (r repo) CreateToken(ctx context.Context, e models.TokenEntity) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM public.user_tokens WHERE user_id = $1 AND purpose = $2`, e.UserID, e.Purpose)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO public.user_tokens (hash, user_id, purpose, expires_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(ctx, query, e.Hash, e.UserID, e.Purpose, e.ExpiresAt); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ConsumeToken implements users.Repo.
+func (r repo) ConsumeToken(ctx context.Context, hash string, purpose models.TokenPurpose) (*models.TokenEntity, error) {
+	query := `DELETE FROM public.user_tokens
+	WHERE hash = $1 AND purpose = $2 AND expires_at > now()
+	RETURNING hash, user_id, purpose, expires_at`
+
+	var e models.TokenEntity
+	err := r.pool.QueryRow(ctx, query, hash, purpose).Scan(&e.Hash, &e.UserID, &e.Purpose, &e.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// ListUsers implements users.Repo.
+func (r repo) ListUsers(ctx context.Context) ([]models.UserEntity, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+userColumns+` FROM public.users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.UserEntity{}
+	for rows.Next() {
+		e, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	r.logger.LogTrace(fmt.Sprintf("%d users listed", len(res)))
+
+	return res, nil
+}
+
+// SetRole implements users.Repo.
+func (r repo) SetRole(ctx context.Context, userID int, role string) (*models.UserEntity, error) {
+	query := `UPDATE public.users SET role = $2 WHERE id = $1 RETURNING ` + userColumns
+
+	return scanUser(r.pool.QueryRow(ctx, query, userID, role))
+}
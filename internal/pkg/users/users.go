@@ -0,0 +1,464 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"kbswitch/internal/core/authz"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/common/logging"
+	"kbswitch/internal/core/mail"
+	"kbswitch/internal/core/users"
+	"kbswitch/internal/core/users/models"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	SessionTTL     = 14 * 24 * time.Hour
+	VerifyTokenTTL = 48 * time.Hour
+	ResetTokenTTL  = time.Hour
+
+	minPasswordLen = 8
+	// bcrypt ignores everything past 72 bytes, longer passwords are refused instead of silently cut
+	maxPasswordLen = 72
+	maxEmailLen    = 254
+
+	// DefaultRole of registered users lets them propose switches, moderators decide on the rest
+	DefaultRole = authz.RoleContributor
+)
+
+var (
+	ErrInvalidEmail       = common.NewError(common.ErrBadRequest, "email address is invalid")
+	ErrPasswordTooShort   = common.NewError(common.ErrBadRequest, fmt.Sprintf("password must be at least %d characters long", minPasswordLen))
+	ErrPasswordTooLong    = common.NewError(common.ErrBadRequest, fmt.Sprintf("password can't be longer than %d bytes", maxPasswordLen))
+	ErrEmailTaken         = common.NewError(common.ErrBadRequest, "account with given email already exists")
+	ErrWrongCredentials   = common.NewError(common.ErrUnauthorized, "email or password is wrong")
+	ErrNoSession          = common.NewError(common.ErrUnauthorized, "session is missing or has expired")
+	ErrInvalidToken       = common.NewError(common.ErrBadRequest, "token is invalid or has expired")
+	ErrNoUser             = common.NewError(common.ErrNotFound, "user with given id not found")
+	ErrUnknownRole        = common.NewError(common.ErrBadRequest, "role must be one of viewer, contributor, moderator or admin")
+	ErrUserMissing        = common.NewError(common.ErrInternalServer, "user of a valid session or token is missing")
+	errPasswordComparison = errors.New("password comparison failed")
+)
+
+// dummyHash is compared against when there is no account for an email,
+// so login takes about the same time whether the account exists or not
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("kbswitch dummy password"), bcrypt.DefaultCost)
+
+// New gives users service, links in mails point to baseURL. Password reset revokes
+// credentials of the user through revokers beside ending its sessions
+func New(logger logging.Logger, repo users.Repo, sender mail.Sender, baseURL string, revokers ...users.Revoker) users.Service {
+	return service{
+		repo:     repo,
+		sender:   sender,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		revokers: revokers,
+		logger:   logger,
+	}
+}
+
+type service struct {
+	repo     users.Repo
+	sender   mail.Sender
+	baseURL  string
+	revokers []users.Revoker
+	logger   logging.Logger
+}
+
+func newToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeEmail(email string) (string, *common.AppError) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if len(email) > maxEmailLen {
+		return "", &ErrInvalidEmail
+	}
+	// display names like "Name <a@b.c>" are not an email address on their own
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", &ErrInvalidEmail
+	}
+	return email, nil
+}
+
+func validatePassword(password string) *common.AppError {
+	if len([]rune(password)) < minPasswordLen {
+		return &ErrPasswordTooShort
+	}
+	if len(password) > maxPasswordLen {
+		return &ErrPasswordTooLong
+	}
+	return nil
+}
+
+func (s service) Register(ctx context.Context, creds models.Credentials) (*models.User, *common.AppError) {
+	email, e := normalizeEmail(creds.Email)
+	if e != nil {
+		s.logger.LogError(fmt.Sprintf("invalid email %s", creds.Email))
+		return nil, e
+	}
+	if e := validatePassword(creds.Password); e != nil {
+		s.logger.LogError("invalid password")
+		return nil, e
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	entity := models.UserEntity{
+		Email:        email,
+		PasswordHash: string(hash),
+		Role:         string(DefaultRole),
+		CreatedAt:    time.Now(),
+	}
+	id, err := s.repo.CreateUser(ctx, entity)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if id == nil {
+		s.logger.LogError(fmt.Sprintf("email %s is taken", email))
+		return nil, &ErrEmailTaken
+	}
+	entity.ID = *id
+
+	// account is usable right away, a mail which failed to go out can be asked for again
+	if err := s.sendToken(ctx, entity, models.PurposeVerifyEmail); err != nil {
+		s.logger.LogError("could not send verification mail: " + err.Error())
+	}
+
+	user := toUser(entity)
+	s.logger.LogTrace(fmt.Sprintf("result is %v", user))
+	return &user, nil
+}
+
+func (s service) CheckCredentials(ctx context.Context, creds models.Credentials) (*models.User, *common.AppError) {
+	entity, e := s.checkCredentials(ctx, creds)
+	if e != nil {
+		return nil, e
+	}
+
+	user := toUser(*entity)
+	s.logger.LogTrace(fmt.Sprintf("credentials of user %d checked", user.ID))
+	return &user, nil
+}
+
+func (s service) checkCredentials(ctx context.Context, creds models.Credentials) (*models.UserEntity, *common.AppError) {
+	entity, err := s.repo.GetUserByEmail(ctx, strings.ToLower(strings.TrimSpace(creds.Email)))
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	hash := dummyHash
+	if entity != nil {
+		hash = []byte(entity.PasswordHash)
+	}
+	err = bcrypt.CompareHashAndPassword(hash, []byte(creds.Password))
+	if entity == nil || errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		s.logger.LogError(fmt.Sprintf("failed login for %s", creds.Email))
+		return nil, &ErrWrongCredentials
+	}
+	if err != nil {
+		s.logger.LogError(errors.Join(errPasswordComparison, err).Error())
+		return nil, common.Wrap(err)
+	}
+
+	return entity, nil
+}
+
+func (s service) Login(ctx context.Context, creds models.Credentials) (*models.Session, *common.AppError) {
+	entity, e := s.checkCredentials(ctx, creds)
+	if e != nil {
+		return nil, e
+	}
+
+	token, tokenHash, err := newToken()
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	now := time.Now()
+	session := models.SessionEntity{
+		Hash:      tokenHash,
+		UserID:    entity.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(SessionTTL),
+	}
+	if err := s.repo.CreateSession(ctx, session); err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("user %d logged in", entity.ID))
+	return &models.Session{
+		Token:     token,
+		User:      toUser(*entity),
+		ExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+func (s service) Logout(ctx context.Context, token string) *common.AppError {
+	if token == "" {
+		return &ErrNoSession
+	}
+
+	if err := s.repo.DeleteSession(ctx, hashToken(token)); err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+
+	s.logger.LogTrace("session ended")
+	return nil
+}
+
+func (s service) Authenticate(ctx context.Context, token string) (*models.User, *common.AppError) {
+	if token == "" {
+		return nil, &ErrNoSession
+	}
+
+	session, err := s.repo.GetSession(ctx, hashToken(token))
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if session == nil || !time.Now().Before(session.ExpiresAt) {
+		s.logger.LogError("unknown or expired session used")
+		return nil, &ErrNoSession
+	}
+
+	entity, err := s.repo.GetUser(ctx, session.UserID)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil {
+		s.logger.LogError(fmt.Sprintf("user %d of a session is missing", session.UserID))
+		return nil, &ErrUserMissing
+	}
+
+	user := toUser(*entity)
+	s.logger.LogTrace(fmt.Sprintf("authenticated user %d", user.ID))
+	return &user, nil
+}
+
+func (s service) VerifyEmail(ctx context.Context, token string) *common.AppError {
+	t, e := s.consume(ctx, token, models.PurposeVerifyEmail)
+	if e != nil {
+		return e
+	}
+
+	if err := s.repo.MarkVerified(ctx, t.UserID); err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("email of user %d verified", t.UserID))
+	return nil
+}
+
+func (s service) RequestPasswordReset(ctx context.Context, email string) *common.AppError {
+	entity, err := s.repo.GetUserByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if entity == nil {
+		s.logger.LogTrace(fmt.Sprintf("password reset asked for unknown email %s", email))
+		return nil
+	}
+
+	if err := s.sendToken(ctx, *entity, models.PurposeResetPassword); err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("password reset mailed to user %d", entity.ID))
+	return nil
+}
+
+func (s service) ResetPassword(ctx context.Context, token, password string) *common.AppError {
+	if e := validatePassword(password); e != nil {
+		s.logger.LogError("invalid password")
+		return e
+	}
+
+	t, e := s.consume(ctx, token, models.PurposeResetPassword)
+	if e != nil {
+		return e
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	if err := s.repo.SetPassword(ctx, t.UserID, string(hash)); err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	// whoever knew the old password must not stay logged in
+	if err := s.repo.DeleteSessions(ctx, t.UserID); err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+	for _, revoker := range s.revokers {
+		if err := revoker.RevokeAll(ctx, t.UserID); err != nil {
+			s.logger.LogError(err.Error())
+			return common.Wrap(err)
+		}
+	}
+	// having the reset mail proves the address as well as a verification would
+	if err := s.repo.MarkVerified(ctx, t.UserID); err != nil {
+		s.logger.LogError(err.Error())
+		return common.Wrap(err)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("password of user %d reset", t.UserID))
+	return nil
+}
+
+func (s service) Get(ctx context.Context, id int) (*models.User, *common.AppError) {
+	entity, err := s.repo.GetUser(ctx, id)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil {
+		s.logger.LogError(fmt.Sprintf("no user %d", id))
+		return nil, &ErrNoUser
+	}
+
+	user := toUser(*entity)
+	s.logger.LogTrace(fmt.Sprintf("result is %v", user))
+	return &user, nil
+}
+
+func (s service) List(ctx context.Context) ([]models.User, *common.AppError) {
+	entities, err := s.repo.ListUsers(ctx)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+
+	res := make([]models.User, len(entities))
+	for i, e := range entities {
+		res[i] = toUser(e)
+	}
+
+	s.logger.LogTrace(fmt.Sprintf("result is %v", res))
+	return res, nil
+}
+
+func (s service) SetRole(ctx context.Context, userID int, role authz.Role) (*models.User, *common.AppError) {
+	if !role.Valid() {
+		s.logger.LogError(fmt.Sprintf("unknown role %s", role))
+		return nil, &ErrUnknownRole
+	}
+
+	entity, err := s.repo.SetRole(ctx, userID, string(role))
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if entity == nil {
+		s.logger.LogError(fmt.Sprintf("no user %d to set role of", userID))
+		return nil, &ErrNoUser
+	}
+
+	user := toUser(*entity)
+	s.logger.LogTrace(fmt.Sprintf("result is %v", user))
+	return &user, nil
+}
+
+func (s service) consume(ctx context.Context, token string, purpose models.TokenPurpose) (*models.TokenEntity, *common.AppError) {
+	if token == "" {
+		s.logger.LogError("token is missing")
+		return nil, &ErrInvalidToken
+	}
+
+	t, err := s.repo.ConsumeToken(ctx, hashToken(token), purpose)
+	if err != nil {
+		s.logger.LogError(err.Error())
+		return nil, common.Wrap(err)
+	}
+	if t == nil || !time.Now().Before(t.ExpiresAt) {
+		s.logger.LogError(fmt.Sprintf("unknown or expired %s token", purpose))
+		return nil, &ErrInvalidToken
+	}
+
+	return t, nil
+}
+
+func (s service) sendToken(ctx context.Context, user models.UserEntity, purpose models.TokenPurpose) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	ttl, msg := VerifyTokenTTL, mail.Message{To: user.Email}
+	switch purpose {
+	case models.PurposeVerifyEmail:
+		msg.Subject = "Verify your email"
+		msg.Body = fmt.Sprintf("Welcome to the keyboard switches registry!\n\n"+
+			"Confirm your email address by following the link below, it is valid for %s.\n\n%s\n",
+			VerifyTokenTTL, s.link("/verify-email", token))
+	case models.PurposeResetPassword:
+		ttl = ResetTokenTTL
+		msg.Subject = "Reset your password"
+		msg.Body = fmt.Sprintf("Somebody asked to reset password of your account.\n\n"+
+			"Follow the link below to choose a new one, it is valid for %s. "+
+			"If it wasn't you, just ignore this mail.\n\n%s\n",
+			ResetTokenTTL, s.link("/reset-password", token))
+	}
+
+	err = s.repo.CreateToken(ctx, models.TokenEntity{
+		Hash:      hash,
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.sender.Send(ctx, msg)
+}
+
+func (s service) link(path, token string) string {
+	return s.baseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func toUser(e models.UserEntity) models.User {
+	return models.User{
+		ID:            e.ID,
+		Email:         e.Email,
+		EmailVerified: e.EmailVerifiedAt != nil,
+		Role:          authz.Role(e.Role),
+		CreatedAt:     e.CreatedAt,
+	}
+}
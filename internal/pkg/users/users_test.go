@@ -0,0 +1,273 @@
+package users_test
+
+import (
+	"context"
+	"kbswitch/internal/core/common"
+	"kbswitch/internal/core/mail"
+	"kbswitch/internal/core/users/models"
+	"kbswitch/internal/pkg/users"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+type fakeLogger struct{}
+
+func (fakeLogger) LogError(msg string) {}
+func (fakeLogger) LogInfo(msg string)  {}
+func (fakeLogger) LogTrace(msg string) {}
+
+// memRepo keeps everything in maps, enough to drive whole account flows
+type memRepo struct {
+	users    map[int]*models.UserEntity
+	sessions map[string]models.SessionEntity
+	tokens   map[string]models.TokenEntity
+}
+
+func newMemRepo() *memRepo {
+	return &memRepo{
+		users:    map[int]*models.UserEntity{},
+		sessions: map[string]models.SessionEntity{},
+		tokens:   map[string]models.TokenEntity{},
+	}
+}
+
+func (m *memRepo) CreateUser(ctx context.Context, e models.UserEntity) (*int, error) {
+	for _, u := range m.users {
+		if u.Email == e.Email {
+			return nil, nil
+		}
+	}
+	e.ID = len(m.users) + 1
+	m.users[e.ID] = &e
+	return &e.ID, nil
+}
+
+func (m *memRepo) GetUser(ctx context.Context, id int) (*models.UserEntity, error) {
+	return m.users[id], nil
+}
+
+func (m *memRepo) GetUserByEmail(ctx context.Context, email string) (*models.UserEntity, error) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memRepo) SetPassword(ctx context.Context, userID int, hash string) error {
+	m.users[userID].PasswordHash = hash
+	return nil
+}
+
+func (m *memRepo) MarkVerified(ctx context.Context, userID int) error {
+	now := time.Now()
+	m.users[userID].EmailVerifiedAt = &now
+	return nil
+}
+
+func (m *memRepo) CreateSession(ctx context.Context, e models.SessionEntity) error {
+	m.sessions[e.Hash] = e
+	return nil
+}
+
+func (m *memRepo) GetSession(ctx context.Context, hash string) (*models.SessionEntity, error) {
+	if s, ok := m.sessions[hash]; ok {
+		return &s, nil
+	}
+	return nil, nil
+}
+
+func (m *memRepo) DeleteSession(ctx context.Context, hash string) error {
+	delete(m.sessions, hash)
+	return nil
+}
+
+func (m *memRepo) DeleteSessions(ctx context.Context, userID int) error {
+	for h, s := range m.sessions {
+		if s.UserID == userID {
+			delete(m.sessions, h)
+		}
+	}
+	return nil
+}
+
+func (m *memRepo) CreateToken(ctx context.Context, e models.TokenEntity) error {
+	for h, t := range m.tokens {
+		if t.UserID == e.UserID && t.Purpose == e.Purpose {
+			delete(m.tokens, h)
+		}
+	}
+	m.tokens[e.Hash] = e
+	return nil
+}
+
+func (m *memRepo) ConsumeToken(ctx context.Context, hash string, purpose models.TokenPurpose) (*models.TokenEntity, error) {
+	t, ok := m.tokens[hash]
+	if !ok || t.Purpose != purpose {
+		return nil, nil
+	}
+	delete(m.tokens, hash)
+	return &t, nil
+}
+
+func (m *memRepo) ListUsers(ctx context.Context) ([]models.UserEntity, error) {
+	res := []models.UserEntity{}
+	for id := 1; id <= len(m.users); id++ {
+		res = append(res, *m.users[id])
+	}
+	return res, nil
+}
+
+func (m *memRepo) SetRole(ctx context.Context, userID int, role string) (*models.UserEntity, error) {
+	u, ok := m.users[userID]
+	if !ok {
+		return nil, nil
+	}
+	u.Role = role
+	return u, nil
+}
+
+type outbox struct {
+	sent []mail.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg mail.Message) error {
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+var tokenInLink = regexp.MustCompile(`\?token=([A-Za-z0-9_-]+)`)
+
+func lastToken(t *testing.T, o *outbox) string {
+	t.Helper()
+	if len(o.sent) == 0 {
+		t.Fatal("no mail was sent")
+	}
+	m := tokenInLink.FindStringSubmatch(o.sent[len(o.sent)-1].Body)
+	if m == nil {
+		t.Fatalf("no token in mail %q", o.sent[len(o.sent)-1].Body)
+	}
+	return m[1]
+}
+
+// fakeRevoker records users whose credentials were revoked
+type fakeRevoker struct {
+	revoked []int
+}
+
+func (f *fakeRevoker) RevokeAll(ctx context.Context, userID int) error {
+	f.revoked = append(f.revoked, userID)
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	tcases := []struct {
+		creds    models.Credentials
+		expected *common.AppError
+	}{
+		{creds: models.Credentials{Email: " Someone@Example.com ", Password: "long enough"}},
+		{creds: models.Credentials{Email: "someone@example.com", Password: "long enough"}, expected: &users.ErrEmailTaken},
+		{creds: models.Credentials{Email: "Someone <other@example.com>", Password: "long enough"}, expected: &users.ErrInvalidEmail},
+		{creds: models.Credentials{Email: "nobody", Password: "long enough"}, expected: &users.ErrInvalidEmail},
+		{creds: models.Credentials{Email: "short@example.com", Password: "short"}, expected: &users.ErrPasswordTooShort},
+		{creds: models.Credentials{Email: "long@example.com", Password: string(make([]byte, 73))}, expected: &users.ErrPasswordTooLong},
+	}
+
+	repo, sender := newMemRepo(), &outbox{}
+	service := users.New(fakeLogger{}, repo, sender, "https://kbswitch.test/")
+
+	for _, tc := range tcases {
+		user, err := service.Register(context.Background(), tc.creds)
+
+		if !reflect.DeepEqual(err, tc.expected) {
+			t.Errorf("Register %q failed\nexpected %v\ngot %v", tc.creds.Email, tc.expected, err)
+		}
+		if err == nil && (user.Email != "someone@example.com" || user.EmailVerified) {
+			t.Errorf("Register %q failed\ngot user %+v", tc.creds.Email, user)
+		}
+	}
+
+	if len(sender.sent) != 1 || sender.sent[0].To != "someone@example.com" {
+		t.Fatalf("expected a single verification mail, got %+v", sender.sent)
+	}
+	if !regexp.MustCompile(`https://kbswitch\.test/verify-email\?token=`).MatchString(sender.sent[0].Body) {
+		t.Errorf("verification link is wrong in %q", sender.sent[0].Body)
+	}
+
+	token := lastToken(t, sender)
+	if err := service.VerifyEmail(context.Background(), token); err != nil {
+		t.Fatalf("VerifyEmail failed with %v", err)
+	}
+	if err := service.VerifyEmail(context.Background(), token); !reflect.DeepEqual(err, &users.ErrInvalidToken) {
+		t.Errorf("VerifyEmail reused token\nexpected %v\ngot %v", users.ErrInvalidToken, err)
+	}
+	if repo.users[1].EmailVerifiedAt == nil {
+		t.Errorf("email was not marked verified")
+	}
+}
+
+func TestLoginFlow(t *testing.T) {
+	ctx := context.Background()
+	repo, sender, revoker := newMemRepo(), &outbox{}, &fakeRevoker{}
+	service := users.New(fakeLogger{}, repo, sender, "https://kbswitch.test", revoker)
+
+	if _, err := service.Register(ctx, models.Credentials{Email: "a@example.com", Password: "password one"}); err != nil {
+		t.Fatalf("Register failed with %v", err)
+	}
+
+	if _, err := service.Login(ctx, models.Credentials{Email: "a@example.com", Password: "wrong password"}); !reflect.DeepEqual(err, &users.ErrWrongCredentials) {
+		t.Errorf("Login with wrong password\nexpected %v\ngot %v", users.ErrWrongCredentials, err)
+	}
+	if _, err := service.Login(ctx, models.Credentials{Email: "b@example.com", Password: "password one"}); !reflect.DeepEqual(err, &users.ErrWrongCredentials) {
+		t.Errorf("Login of unknown account\nexpected %v\ngot %v", users.ErrWrongCredentials, err)
+	}
+
+	session, err := service.Login(ctx, models.Credentials{Email: "A@example.com", Password: "password one"})
+	if err != nil {
+		t.Fatalf("Login failed with %v", err)
+	}
+	if _, ok := repo.sessions[session.Token]; ok {
+		t.Errorf("session token is stored in plain")
+	}
+
+	user, err := service.Authenticate(ctx, session.Token)
+	if err != nil || user.Email != "a@example.com" {
+		t.Fatalf("Authenticate failed\ngot %v, %v", user, err)
+	}
+
+	// reset ends every session, including ones started after the reset was asked for
+	if err := service.RequestPasswordReset(ctx, "a@example.com"); err != nil {
+		t.Fatalf("RequestPasswordReset failed with %v", err)
+	}
+	mails := len(sender.sent)
+	if err := service.RequestPasswordReset(ctx, "nobody@example.com"); err != nil || len(sender.sent) != mails {
+		t.Errorf("RequestPasswordReset of unknown email\nexpected silent success\ngot %v and %d mails", err, len(sender.sent)-mails)
+	}
+	if err := service.ResetPassword(ctx, lastToken(t, sender), "password two"); err != nil {
+		t.Fatalf("ResetPassword failed with %v", err)
+	}
+
+	if !reflect.DeepEqual(revoker.revoked, []int{1}) {
+		t.Errorf("ResetPassword revoked credentials of %v, expected user 1", revoker.revoked)
+	}
+	if _, err := service.Authenticate(ctx, session.Token); !reflect.DeepEqual(err, &users.ErrNoSession) {
+		t.Errorf("Authenticate after reset\nexpected %v\ngot %v", users.ErrNoSession, err)
+	}
+	if _, err := service.Login(ctx, models.Credentials{Email: "a@example.com", Password: "password one"}); !reflect.DeepEqual(err, &users.ErrWrongCredentials) {
+		t.Errorf("Login with old password\nexpected %v\ngot %v", users.ErrWrongCredentials, err)
+	}
+
+	session, err = service.Login(ctx, models.Credentials{Email: "a@example.com", Password: "password two"})
+	if err != nil {
+		t.Fatalf("Login with new password failed with %v", err)
+	}
+	if err := service.Logout(ctx, session.Token); err != nil {
+		t.Fatalf("Logout failed with %v", err)
+	}
+	if _, err := service.Authenticate(ctx, session.Token); !reflect.DeepEqual(err, &users.ErrNoSession) {
+		t.Errorf("Authenticate after logout\nexpected %v\ngot %v", users.ErrNoSession, err)
+	}
+}